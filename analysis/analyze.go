@@ -0,0 +1,286 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+// Band is a named frequency band of the analysis.
+type Band struct {
+	Name string  `json:"name"`
+	LoHz float64 `json:"lo_hz"`
+	HiHz float64 `json:"hi_hz"`
+}
+
+// TimeWindow is a named time span of the analysis, relative to the start of
+// the signal.
+type TimeWindow struct {
+	Name     string  `json:"name"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+}
+
+// AnalysisBands are the frequency bands reported per window.
+var AnalysisBands = []Band{
+	{"sub-bass", 20, 100},
+	{"bass", 100, 300},
+	{"low-mid", 300, 1000},
+	{"mid", 1000, 3000},
+	{"hi-mid", 3000, 6000},
+	{"high", 6000, 12000},
+	{"air", 12000, 20000},
+}
+
+// AnalysisWindows are the note phases reported by Analyze.
+var AnalysisWindows = []TimeWindow{
+	{"attack", 0, 0.02},
+	{"early", 0.02, 0.1},
+	{"sustain", 0.1, 0.5},
+	{"decay", 0.5, 2},
+	{"late", 2, 4},
+}
+
+const (
+	analysisMaxFFT         = 4096
+	analysisMinFFT         = 256
+	spectrogramFFT         = 2048
+	spectrogramMaxFrames   = 256
+	spectrogramBins        = 96
+	spectrogramMinHz       = 20.0
+	spectrogramFloorDB     = -120.0
+	analysisEnvelopeFrame  = 256
+	analysisEnvelopeHop    = 128
+	analysisBandFloorPower = 1e-24
+)
+
+// Analysis is a structured single-signal analysis meant for JSON export and
+// plotting.
+type Analysis struct {
+	SampleRate  int     `json:"sample_rate"`
+	Frames      int     `json:"frames"`
+	DurationSec float64 `json:"duration_sec"`
+	PeakDBFS    float64 `json:"peak_dbfs"`
+	RMSDBFS     float64 `json:"rms_dbfs"`
+
+	Envelope      Envelope `json:"envelope"`
+	DecayDetected bool     `json:"decay_detected"`
+	DecayDBPerS   float64  `json:"decay_db_per_s,omitempty"`
+
+	Windows     []WindowAnalysis `json:"windows"`
+	Spectrogram Spectrogram      `json:"spectrogram"`
+}
+
+// WindowAnalysis holds the level and band energies of one time window.
+type WindowAnalysis struct {
+	TimeWindow
+	RMSDBFS  float64        `json:"rms_dbfs"`
+	FFTSize  int            `json:"fft_size"`
+	STFTHops int            `json:"stft_frames"`
+	Bands    []BandAnalysis `json:"bands"`
+
+	// Magnitude is the frame-averaged STFT magnitude per bin (BinHz apart),
+	// kept for bin-level comparisons but not exported to JSON.
+	Magnitude []float64 `json:"-"`
+	BinHz     float64   `json:"-"`
+}
+
+// BandAnalysis is the mean bin power of a band in dB.
+type BandAnalysis struct {
+	Band
+	LevelDB float64 `json:"level_db"`
+}
+
+// Spectrogram is an STFT decimated for plotting: at most
+// spectrogramMaxFrames frames on log-spaced frequency bands.
+type Spectrogram struct {
+	FFTSize  int         `json:"fft_size"`
+	HopSec   float64     `json:"hop_sec"`
+	TimesSec []float64   `json:"times_sec"`
+	FreqsHz  []float64   `json:"freqs_hz"`  // band centers
+	LevelsDB [][]float64 `json:"levels_db"` // [frame][band]
+}
+
+// Analyze measures levels, envelope, decay, per-window band energies and a
+// decimated spectrogram of a mono signal.
+func Analyze(x []float64, sampleRate int) Analysis {
+	a := Analysis{SampleRate: sampleRate, Frames: len(x)}
+	if sampleRate <= 0 || len(x) == 0 {
+		return a
+	}
+	a.DurationSec = float64(len(x)) / float64(sampleRate)
+	peak := 0.0
+	for _, v := range x {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	a.PeakDBFS = linToDB(peak)
+	a.RMSDBFS = linToDB(rms1(x))
+
+	a.Envelope = ExtractEnvelope(x, sampleRate)
+	env := rmsEnvelope(trimLeadingSilence(x, 1e-6), analysisEnvelopeFrame, analysisEnvelopeHop)
+	if d := decaySlopeDBPerS(env, float64(analysisEnvelopeHop)/float64(sampleRate)); isFinite(d) {
+		a.DecayDetected = true
+		a.DecayDBPerS = d
+	}
+
+	for _, tw := range AnalysisWindows {
+		if w, ok := analyzeWindow(x, sampleRate, tw); ok {
+			a.Windows = append(a.Windows, w)
+		}
+	}
+	a.Spectrogram = spectrogram(x, sampleRate)
+	return a
+}
+
+// analyzeWindow averages the Hann-windowed STFT magnitude over tw with an FFT
+// no longer than the window (zero-padding windows shorter than the minimum).
+func analyzeWindow(x []float64, sampleRate int, tw TimeWindow) (WindowAnalysis, bool) {
+	start := int(tw.StartSec * float64(sampleRate))
+	end := int(tw.EndSec * float64(sampleRate))
+	if end > len(x) {
+		end = len(x)
+	}
+	if start >= end {
+		return WindowAnalysis{}, false
+	}
+	span := end - start
+	fftSize := analysisMaxFFT
+	for fftSize > span && fftSize > analysisMinFFT {
+		fftSize /= 2
+	}
+	plan, err := algofft.NewPlanReal64(fftSize)
+	if err != nil {
+		return WindowAnalysis{}, false
+	}
+	w := WindowAnalysis{
+		TimeWindow: tw,
+		RMSDBFS:    linToDB(rms1(x[start:end])),
+		FFTSize:    fftSize,
+		BinHz:      float64(sampleRate) / float64(fftSize),
+		Magnitude:  make([]float64, fftSize/2),
+	}
+	hann := hannWindow(fftSize)
+	buf := make([]float64, fftSize)
+	spec := make([]complex128, fftSize/2+1)
+	accumulate := func(pos int) {
+		clear(buf)
+		for i := 0; i < fftSize && pos+i < end; i++ {
+			buf[i] = x[pos+i] * hann[i]
+		}
+		_ = plan.Forward(spec, buf)
+		for k := 1; k < len(w.Magnitude); k++ {
+			w.Magnitude[k] += cmplx.Abs(spec[k])
+		}
+		w.STFTHops++
+	}
+	for pos := start; pos+fftSize <= end; pos += fftSize / 2 {
+		accumulate(pos)
+	}
+	if w.STFTHops == 0 {
+		accumulate(start)
+	}
+	for k := range w.Magnitude {
+		w.Magnitude[k] /= float64(w.STFTHops)
+	}
+
+	for _, b := range AnalysisBands {
+		lo := int(b.LoHz / w.BinHz)
+		hi := int(b.HiHz / w.BinHz)
+		lo = max(lo, 1)
+		hi = min(hi, len(w.Magnitude)-1)
+		if lo > hi {
+			continue
+		}
+		var pow float64
+		for k := lo; k <= hi; k++ {
+			pow += w.Magnitude[k] * w.Magnitude[k]
+		}
+		w.Bands = append(w.Bands, BandAnalysis{
+			Band:    b,
+			LevelDB: 10 * math.Log10(math.Max(pow/float64(hi-lo+1), analysisBandFloorPower)),
+		})
+	}
+	return w, true
+}
+
+// spectrogram computes a Hann STFT with the hop chosen so at most
+// spectrogramMaxFrames frames cover the signal, and pools the bins into
+// log-spaced bands (mean power, dBFS-scaled, floored).
+func spectrogram(x []float64, sampleRate int) Spectrogram {
+	fftSize := spectrogramFFT
+	for fftSize > len(x) && fftSize > analysisMinFFT {
+		fftSize /= 2
+	}
+	plan, err := algofft.NewPlanReal64(fftSize)
+	if err != nil {
+		return Spectrogram{}
+	}
+	hop := fftSize / 4
+	if frames := (len(x) + hop - 1) / hop; frames > spectrogramMaxFrames {
+		hop = (len(x) + spectrogramMaxFrames - 1) / spectrogramMaxFrames
+	}
+	s := Spectrogram{FFTSize: fftSize, HopSec: float64(hop) / float64(sampleRate)}
+
+	nyquist := float64(sampleRate) / 2
+	binHz := float64(sampleRate) / float64(fftSize)
+	ratio := math.Pow(nyquist/spectrogramMinHz, 1.0/spectrogramBins)
+	type pool struct{ lo, hi int }
+	var pools []pool
+	for b := 0; b < spectrogramBins; b++ {
+		loHz := spectrogramMinHz * math.Pow(ratio, float64(b))
+		hiHz := loHz * ratio
+		lo := int(math.Ceil(loHz / binHz))
+		hi := int(math.Floor(hiHz / binHz))
+		lo = max(lo, 1)
+		hi = min(hi, fftSize/2)
+		if hi < lo {
+			// Narrower than a bin: use the nearest bin.
+			lo = min(max(int(math.Round(math.Sqrt(loHz*hiHz)/binHz)), 1), fftSize/2)
+			hi = lo
+		}
+		if len(pools) > 0 && pools[len(pools)-1] == (pool{lo, hi}) {
+			continue
+		}
+		pools = append(pools, pool{lo, hi})
+		s.FreqsHz = append(s.FreqsHz, math.Sqrt(loHz*hiHz))
+	}
+
+	hann := hannWindow(fftSize)
+	// Scale so a full-scale sinusoid centered in a bin reads about 0 dB.
+	var wsum float64
+	for _, v := range hann {
+		wsum += v
+	}
+	norm := 2 / wsum
+	buf := make([]float64, fftSize)
+	spec := make([]complex128, fftSize/2+1)
+	for pos := 0; pos < len(x); pos += hop {
+		clear(buf)
+		for i := 0; i < fftSize && pos+i < len(x); i++ {
+			buf[i] = x[pos+i] * hann[i]
+		}
+		_ = plan.Forward(spec, buf)
+		levels := make([]float64, len(pools))
+		for i, p := range pools {
+			var pow float64
+			for k := p.lo; k <= p.hi; k++ {
+				m := cmplx.Abs(spec[k]) * norm
+				pow += m * m
+			}
+			db := 10 * math.Log10(math.Max(pow/float64(p.hi-p.lo+1), 1e-30))
+			levels[i] = math.Round(math.Max(db, spectrogramFloorDB)*10) / 10
+		}
+		s.TimesSec = append(s.TimesSec, float64(pos)/float64(sampleRate))
+		s.LevelsDB = append(s.LevelsDB, levels)
+	}
+	return s
+}
+
+func hannWindow(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	return w
+}
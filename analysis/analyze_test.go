@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func decayingSine(n int, sampleRate int, freq float64, decayDBPerS float64) []float64 {
+	x := sine(n, sampleRate, freq, 0.5)
+	for i := range x {
+		x[i] *= math.Pow(10, -decayDBPerS*float64(i)/float64(sampleRate)/20)
+	}
+	return x
+}
+
+func TestAnalyzeMeasuresLevelsDecayAndBands(t *testing.T) {
+	const sr = 48000
+	x := decayingSine(3*sr, sr, 440, 20)
+	a := Analyze(x, sr)
+
+	if a.Frames != len(x) || math.Abs(a.DurationSec-3) > 1e-9 {
+		t.Fatalf("frames=%d duration=%g", a.Frames, a.DurationSec)
+	}
+	if math.Abs(a.PeakDBFS-linToDB(0.5)) > 0.1 {
+		t.Fatalf("peak = %.2f dBFS, want %.2f", a.PeakDBFS, linToDB(0.5))
+	}
+	if !a.DecayDetected || math.Abs(a.DecayDBPerS+20) > 2 {
+		t.Fatalf("decay = %.2f dB/s (detected=%v), want -20", a.DecayDBPerS, a.DecayDetected)
+	}
+	if len(a.Windows) != len(AnalysisWindows) {
+		t.Fatalf("got %d windows, want %d", len(a.Windows), len(AnalysisWindows))
+	}
+	for _, w := range a.Windows {
+		var best BandAnalysis
+		for _, b := range w.Bands {
+			if best.Name == "" || b.LevelDB > best.LevelDB {
+				best = b
+			}
+		}
+		if best.Name != "low-mid" {
+			t.Fatalf("%s: loudest band %q, want low-mid for a 440 Hz tone", w.Name, best.Name)
+		}
+	}
+	if late, sustain := a.Windows[4].RMSDBFS, a.Windows[2].RMSDBFS; late >= sustain-20 {
+		t.Fatalf("late window %.1f dBFS not below sustain %.1f dBFS", late, sustain)
+	}
+}
+
+func TestAnalyzeSpectrogramIsDecimatedAndTracksTheTone(t *testing.T) {
+	const sr = 48000
+	a := Analyze(decayingSine(8*sr, sr, 1000, 6), sr)
+	s := a.Spectrogram
+	if len(s.TimesSec) == 0 || len(s.TimesSec) > spectrogramMaxFrames {
+		t.Fatalf("spectrogram has %d frames, want 1..%d", len(s.TimesSec), spectrogramMaxFrames)
+	}
+	for i := 1; i < len(s.FreqsHz); i++ {
+		if s.FreqsHz[i] <= s.FreqsHz[i-1] {
+			t.Fatalf("frequencies not increasing at %d", i)
+		}
+	}
+	frame := s.LevelsDB[len(s.LevelsDB)/4]
+	peak := 0
+	for i, v := range frame {
+		if v > frame[peak] {
+			peak = i
+		}
+	}
+	if f := s.FreqsHz[peak]; f < 900 || f > 1100 {
+		t.Fatalf("spectrogram peak at %.0f Hz, want ~1000 Hz", f)
+	}
+}
+
+func TestAnalyzeHandlesSilenceAndEncodesToJSON(t *testing.T) {
+	a := Analyze(make([]float64, 4800), 48000)
+	if a.DecayDetected {
+		t.Fatalf("decay detected in silence")
+	}
+	if len(a.Windows) != 2 {
+		t.Fatalf("got %d windows for 100 ms, want 2", len(a.Windows))
+	}
+	if _, err := json.Marshal(a); err != nil {
+		t.Fatalf("marshal silence analysis: %v", err)
+	}
+	if b := Analyze(nil, 48000); b.Frames != 0 || b.Windows != nil {
+		t.Fatalf("empty input produced %+v", b)
+	}
+}
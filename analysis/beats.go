@@ -0,0 +1,224 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+const (
+	// NormBeatRate and NormBeatDepth scale the mean beat-rate (Hz) and
+	// beat-depth (dB) differences to [0,1].
+	NormBeatRate  = 2.0
+	NormBeatDepth = 6.0
+
+	beatPartials    = 3    // fundamental and the two lowest overtones
+	beatSkipSec     = 0.05 // skip the hammer transient
+	beatMaxDuration = 4.0
+	beatMinRateHz   = 0.25
+	beatMaxRateHz   = 15.0
+	beatMinDepthDB  = 0.5  // shallower modulation counts as no beating
+	beatRangeDB     = 40.0 // level track floor below its peak
+	beatSearch      = 0.3  // partial search half-width in multiples of f0
+)
+
+// PartialBeat is the amplitude modulation (beating) of one partial in the
+// reference and the candidate. Rates are 0 where no beating was found.
+type PartialBeat struct {
+	Index       int     `json:"index"`
+	RefRateHz   float64 `json:"ref_rate_hz"`
+	RefDepthDB  float64 `json:"ref_depth_db"`
+	CandRateHz  float64 `json:"cand_rate_hz"`
+	CandDepthDB float64 `json:"cand_depth_db"`
+}
+
+type beatResult struct {
+	partials  []PartialBeat
+	rateDiff  float64
+	depthDiff float64
+	ok        bool
+}
+
+// measureBeats compares the beating of the lowest partials of the aligned
+// reference and candidate near multiples of f0.
+func measureBeats(ref []float64, cand []float64, sampleRate int, f0 float64) beatResult {
+	if f0 <= 0 || sampleRate <= 0 {
+		return beatResult{}
+	}
+	// Resolve partials (bins of about f0/4) with frames short enough to
+	// follow beats up to beatMaxRateHz.
+	n := 512
+	for n < 8192 && float64(sampleRate)/float64(n) > 0.25*f0 {
+		n <<= 1
+	}
+	skip := int(beatSkipSec * float64(sampleRate))
+	length := min(len(ref), len(cand), skip+int(beatMaxDuration*float64(sampleRate)))
+	if length-skip < 4*n {
+		return beatResult{}
+	}
+	plan, err := algofft.NewPlanReal64(n)
+	if err != nil {
+		return beatResult{}
+	}
+	hop := n / 4
+	trackRate := float64(sampleRate) / float64(hop)
+
+	refTracks := partialLevelTracks(plan, ref[skip:length], sampleRate, n, hop, f0)
+	candTracks := partialLevelTracks(plan, cand[skip:length], sampleRate, n, hop, f0)
+
+	var res beatResult
+	for k := range refTracks {
+		if refTracks[k] == nil {
+			continue
+		}
+		b := PartialBeat{Index: k + 1}
+		b.RefRateHz, b.RefDepthDB = modulation(refTracks[k], trackRate)
+		if candTracks[k] != nil {
+			b.CandRateHz, b.CandDepthDB = modulation(candTracks[k], trackRate)
+		}
+		res.partials = append(res.partials, b)
+		res.rateDiff += math.Abs(b.RefRateHz - b.CandRateHz)
+		res.depthDiff += math.Abs(b.RefDepthDB - b.CandDepthDB)
+	}
+	if len(res.partials) == 0 {
+		return beatResult{}
+	}
+	res.rateDiff /= float64(len(res.partials))
+	res.depthDiff /= float64(len(res.partials))
+	res.ok = true
+	return res
+}
+
+// partialLevelTracks returns the dB level of partials 1..beatPartials per
+// frame (nil for partials above Nyquist or lost in the noise).
+func partialLevelTracks(plan *algofft.PlanRealT[float64, complex128], x []float64, sampleRate int, n int, hop int, f0 float64) [][]float64 {
+	binHz := float64(sampleRate) / float64(n)
+	win := hannWindow(n)
+	in := make([]float64, n)
+	spec := make([]complex128, n/2+1)
+	mags := make([][]float64, 0, len(x)/hop)
+	floor := 0.0
+	for start := 0; start+n <= len(x); start += hop {
+		for i := range in {
+			in[i] = x[start+i] * win[i]
+		}
+		if err := plan.Forward(spec, in); err != nil {
+			return nil
+		}
+		mag := make([]float64, len(spec))
+		for i, v := range spec {
+			mag[i] = cmplx.Abs(v)
+			if len(mags) == 0 {
+				floor += mag[i]
+			}
+		}
+		mags = append(mags, mag)
+	}
+	if len(mags) == 0 {
+		return nil
+	}
+	floor /= float64(n/2 + 1)
+
+	tracks := make([][]float64, beatPartials)
+	for k := 1; k <= beatPartials; k++ {
+		lo := max(1, int((float64(k)-beatSearch)*f0/binHz))
+		hi := int(math.Ceil((float64(k) + beatSearch) * f0 / binHz))
+		if hi >= n/2 {
+			break
+		}
+		track := make([]float64, len(mags))
+		peak := 0.0
+		for f, mag := range mags {
+			m := 0.0
+			for i := lo; i <= hi; i++ {
+				m = math.Max(m, mag[i])
+			}
+			if f == 0 {
+				peak = m
+			}
+			track[f] = linToDB(m)
+		}
+		if peak < 4*floor {
+			continue
+		}
+		tracks[k-1] = track
+	}
+	return tracks
+}
+
+// modulation removes the linear decay from a dB level track and returns
+// the rate and peak-to-peak depth of its strongest periodic component in
+// [beatMinRateHz, beatMaxRateHz]. Notches are clipped beatRangeDB below the
+// track peak so beats that cancel fully do not dominate.
+func modulation(track []float64, trackRate float64) (rateHz float64, depthDB float64) {
+	n := len(track)
+	if n < 8 {
+		return 0, 0
+	}
+	peak := math.Inf(-1)
+	for _, v := range track {
+		peak = math.Max(peak, v)
+	}
+	y := make([]float64, n)
+	var sx, sy, sxx, sxy float64
+	for i, v := range track {
+		y[i] = math.Max(v, peak-beatRangeDB)
+		x := float64(i)
+		sx += x
+		sy += y[i]
+		sxx += x * x
+		sxy += x * y[i]
+	}
+	fn := float64(n)
+	slope := (fn*sxy - sx*sy) / (fn*sxx - sx*sx)
+	icept := (sy - slope*sx) / fn
+	for i := range y {
+		y[i] -= icept + slope*float64(i)
+	}
+
+	// Zero-padded DFT of the Hann-windowed residual.
+	win := hannWindow(n)
+	var winSum float64
+	for i := range y {
+		y[i] *= win[i]
+		winSum += win[i]
+	}
+	size := 1
+	for size < 8*n {
+		size <<= 1
+	}
+	plan, err := algofft.NewPlanReal64(size)
+	if err != nil {
+		return 0, 0
+	}
+	in := make([]float64, size)
+	copy(in, y)
+	spec := make([]complex128, size/2+1)
+	if err := plan.Forward(spec, in); err != nil {
+		return 0, 0
+	}
+	binHz := trackRate / float64(size)
+	lo := max(1, int(beatMinRateHz/binHz))
+	hi := min(len(spec)-2, int(math.Min(beatMaxRateHz, 0.4*trackRate)/binHz))
+	best := -1
+	for i := lo; i <= hi; i++ {
+		if best < 0 || cmplx.Abs(spec[i]) > cmplx.Abs(spec[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, 0
+	}
+	// Sinusoid amplitude is 2|X|/sum(w); peak-to-peak depth is twice that.
+	depthDB = 4 * cmplx.Abs(spec[best]) / winSum
+	if depthDB < beatMinDepthDB {
+		return 0, depthDB
+	}
+	a, b, c := cmplx.Abs(spec[best-1]), cmplx.Abs(spec[best]), cmplx.Abs(spec[best+1])
+	delta := 0.0
+	if den := a - 2*b + c; den != 0 {
+		delta = 0.5 * (a - c) / den
+	}
+	return (float64(best) + delta) * binHz, depthDB
+}
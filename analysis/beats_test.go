@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// makeUnison is a decaying pair of equal sines detuned by beatHz, as two
+// unison strings of one note.
+func makeUnison(sr int, freq float64, beatHz float64, durationSec float64) []float64 {
+	out := make([]float64, int(float64(sr)*durationSec))
+	for i := range out {
+		t := float64(i) / float64(sr)
+		env := 0.4 * math.Exp(-t/1.5)
+		out[i] = env * (math.Sin(2*math.Pi*freq*t) + math.Sin(2*math.Pi*(freq+beatHz)*t))
+	}
+	return out
+}
+
+func TestCompareBeatsMeasuresRateAndDepth(t *testing.T) {
+	sr := 48000
+	f0 := 261.63
+	ref := makeUnison(sr, f0, 1.5, 4.5)
+	cand := makeUnison(sr, f0, 2.5, 4.5)
+
+	m := CompareWithOptions(ref, cand, sr, CompareOptions{F0Hz: f0})
+	if !m.BeatDetected || len(m.Beats) == 0 {
+		t.Fatalf("expected beat measurements, got %+v", m.Beats)
+	}
+	fund := m.Beats[0]
+	if fund.Index != 1 || math.Abs(fund.RefRateHz-1.5) > 0.1 || math.Abs(fund.CandRateHz-2.5) > 0.1 {
+		t.Fatalf("expected 1.5 Hz vs 2.5 Hz beating, got %+v", fund)
+	}
+	if fund.RefDepthDB < 10 || fund.CandDepthDB < 10 {
+		t.Fatalf("equal-level unison should beat deeply, got %+v", fund)
+	}
+	if m.BeatRateDiffHz < 0.5 {
+		t.Fatalf("expected a beat-rate difference, got %.3f Hz", m.BeatRateDiffHz)
+	}
+
+	weighted := CompareWithOptions(ref, cand, sr, CompareOptions{F0Hz: f0, BeatWeight: 0.4})
+	if want := 0.6*m.Score + 0.4*m.BeatNorm; math.Abs(weighted.Score-want) > 1e-12 {
+		t.Fatalf("BeatWeight must blend the beat error into the score: got %f want %f", weighted.Score, want)
+	}
+	if same := CompareWithOptions(ref, ref, sr, CompareOptions{F0Hz: f0}); same.BeatNorm > 0.01 {
+		t.Fatalf("identical signals should match their beating, got norm %.4f", same.BeatNorm)
+	}
+}
+
+func TestModulationIgnoresPlainDecay(t *testing.T) {
+	track := make([]float64, 400)
+	for i := range track {
+		track[i] = -6 - 0.05*float64(i)
+	}
+	if rate, depth := modulation(track, 100); rate != 0 || depth > beatMinDepthDB {
+		t.Fatalf("a plain exponential decay has no beating, got %.2f Hz %.2f dB", rate, depth)
+	}
+}
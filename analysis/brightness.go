@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+const (
+	// NormCentroid scales the RMS spectral centroid error, in octaves, to
+	// [0,1]: a brightness half an octave off throughout scores 1.
+	NormCentroid = 0.5
+
+	centroidFrameSec = 0.05
+	centroidMinHz    = 20.0
+	centroidFloorDB  = -60.0 // frames this far below the loudest reference frame are skipped
+)
+
+type centroidResult struct {
+	times   []float64 // seconds, frame centers above the floor in both signals
+	refHz   []float64
+	candHz  []float64
+	rmsLog2 float64
+	ok      bool
+}
+
+// measureCentroid compares the spectral centroid (brightness) of the
+// aligned reference and candidate per 50 ms frame. The error is the RMS of
+// log2(candidate/reference) over the frames where both are above
+// centroidFloorDB, so a spectrum that matches on average but darkens at the
+// wrong rate still shows up.
+func measureCentroid(ref []float64, cand []float64, sampleRate int) centroidResult {
+	if sampleRate <= 0 {
+		return centroidResult{}
+	}
+	frame := int(math.Round(centroidFrameSec * float64(sampleRate)))
+	length := min(len(ref), len(cand))
+	if frame < 16 || length < frame {
+		return centroidResult{}
+	}
+	n := nextPow2(frame)
+	plan, err := algofft.NewPlanReal64(n)
+	if err != nil {
+		return centroidResult{}
+	}
+
+	refC, refE := centroidTrack(plan, ref[:length], sampleRate, frame, n)
+	candC, candE := centroidTrack(plan, cand[:length], sampleRate, frame, n)
+	peak := 0.0
+	for _, e := range refE {
+		peak = max(peak, e)
+	}
+	floor := peak * math.Pow(10, centroidFloorDB/10)
+
+	var res centroidResult
+	var sumSq float64
+	for i := range min(len(refC), len(candC)) {
+		if refE[i] <= floor || candE[i] <= floor || refC[i] <= 0 || candC[i] <= 0 {
+			continue
+		}
+		d := math.Log2(candC[i] / refC[i])
+		res.times = append(res.times, (float64(i)+0.5)*float64(frame)/float64(sampleRate))
+		res.refHz = append(res.refHz, refC[i])
+		res.candHz = append(res.candHz, candC[i])
+		sumSq += d * d
+	}
+	if len(res.times) == 0 {
+		return centroidResult{}
+	}
+	res.rmsLog2 = math.Sqrt(sumSq / float64(len(res.times)))
+	res.ok = true
+	return res
+}
+
+// centroidTrack returns the power-weighted mean frequency above
+// centroidMinHz and the power of each non-overlapping Hann frame of x,
+// zero-padded to the n-point FFT.
+func centroidTrack(plan *algofft.PlanRealT[float64, complex128], x []float64, sampleRate int, frame int, n int) (centroids []float64, energies []float64) {
+	binHz := float64(sampleRate) / float64(n)
+	lo := max(1, int(math.Ceil(centroidMinHz/binHz)))
+	win := hannWindow(frame)
+	in := make([]float64, n)
+	spec := make([]complex128, n/2+1)
+	for start := 0; start+frame <= len(x); start += frame {
+		for i := range frame {
+			in[i] = x[start+i] * win[i]
+		}
+		if err := plan.Forward(spec, in); err != nil {
+			return centroids, energies
+		}
+		var sum, weighted float64
+		for k := lo; k < len(spec); k++ {
+			a := cmplx.Abs(spec[k])
+			p := a * a
+			sum += p
+			weighted += p * float64(k) * binHz
+		}
+		c := 0.0
+		if sum > 0 {
+			c = weighted / sum
+		}
+		centroids = append(centroids, c)
+		energies = append(energies, sum)
+	}
+	return centroids, energies
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCentroidTrackOfSine(t *testing.T) {
+	sr := 48000
+	x := make([]float64, sr/2)
+	for i := range x {
+		x[i] = math.Sin(2 * math.Pi * 1000 * float64(i) / float64(sr))
+	}
+	m := CompareWithOptions(x, x, sr, CompareOptions{})
+	if !m.CentroidDetected || len(m.CentroidRefHz) < 8 {
+		t.Fatalf("expected a centroid track, got %d frames", len(m.CentroidRefHz))
+	}
+	for i, c := range m.CentroidRefHz {
+		if math.Abs(c-1000) > 20 {
+			t.Fatalf("frame %d centroid %.1f Hz, want about 1000", i, c)
+		}
+	}
+	if m.CentroidRMSELog2 > 1e-9 {
+		t.Fatalf("identical signals should share their centroid track, got %.4f octaves", m.CentroidRMSELog2)
+	}
+}
+
+func TestCompareCentroidFindsFastDarkening(t *testing.T) {
+	sr := 48000
+	f0 := 220.0
+	// Equal-level partials so the treble carries weight in the centroid.
+	partials := func(t60 []float64) []float64 {
+		out := make([]float64, 2*sr)
+		for i := range out {
+			t := float64(i) / float64(sr)
+			for k, d := range t60 {
+				kf := float64(k + 1)
+				out[i] += 0.1 * math.Pow(10, -3*t/d) * math.Sin(2*math.Pi*kf*f0*t)
+			}
+		}
+		return out
+	}
+	ref := partials([]float64{6, 5, 4.5, 4, 3.5, 3, 2.8, 2.5})
+	cand := partials([]float64{6, 5, 2, 1, 0.6, 0.4, 0.3, 0.25}) // treble dies early
+
+	m := CompareWithOptions(ref, cand, sr, CompareOptions{})
+	if !m.CentroidDetected {
+		t.Fatal("expected a centroid track")
+	}
+	first, last := 0, len(m.CentroidTimesSec)-1
+	early := m.CentroidCandHz[first] / m.CentroidRefHz[first]
+	late := m.CentroidCandHz[last] / m.CentroidRefHz[last]
+	if late >= 0.75 || late >= early {
+		t.Fatalf("candidate should darken faster: centroid ratio %.2f at onset, %.2f at the end", early, late)
+	}
+	if m.CentroidRMSELog2 < 0.2 {
+		t.Fatalf("centroid RMSE %.3f octaves, want a clear error", m.CentroidRMSELog2)
+	}
+
+	weighted := CompareWithOptions(ref, cand, sr, CompareOptions{CentroidWeight: 0.5})
+	if want := 0.5*m.Score + 0.5*m.CentroidNorm; math.Abs(weighted.Score-want) > 1e-12 {
+		t.Fatalf("CentroidWeight must blend the trajectory error into the score: got %f want %f", weighted.Score, want)
+	}
+}
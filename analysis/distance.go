@@ -4,8 +4,10 @@ import (
 	"errors"
 	"math"
 	"math/cmplx"
+	"sort"
 	"sync"
 
+	dspinterp "github.com/cwbudde/algo-dsp/dsp/interp"
 	algofft "github.com/cwbudde/algo-fft"
 )
 
@@ -55,6 +57,10 @@ type Metrics struct {
 	AlignedFrames   int `json:"aligned_frames"`
 	LagSamples      int `json:"lag_samples"`
 
+	// LagFraction is the sub-sample remainder of the lag in (-0.5,0.5];
+	// the reference is fractionally delayed by it before metering.
+	LagFraction float64 `json:"lag_fraction"`
+
 	TimeRMSE        float64 `json:"time_rmse"`
 	EnvelopeRMSEDB  float64 `json:"envelope_rmse_db"`
 	SpectralRMSEDB  float64 `json:"spectral_rmse_db"`
@@ -62,6 +68,61 @@ type Metrics struct {
 	CandDecayDBPerS float64 `json:"cand_decay_db_per_s"`
 	DecayDiffDBPerS float64 `json:"decay_diff_db_per_s"`
 
+	// Smoothed candidate gain track (dB, one value per window) applied when
+	// CompareOptions.GainMatch is set.
+	GainTrackDB     []float64 `json:"gain_track_db,omitempty"`
+	GainTrackHopSec float64   `json:"gain_track_hop_sec,omitempty"`
+
+	// Release window centered on the NoteOff/damper event detected in the
+	// reference (see CompareOptions.ReleaseWeight).
+	ReleaseDetected       bool    `json:"release_detected"`
+	ReleaseTimeSec        float64 `json:"release_time_sec,omitempty"`
+	ReleaseEnvelopeRMSEDB float64 `json:"release_envelope_rmse_db,omitempty"`
+	ReleaseResidualDiffDB float64 `json:"release_residual_diff_db,omitempty"`
+	// Time the reference takes to fall 20 dB after the release (the damper
+	// speed), and the candidate's difference to it.
+	ReleaseFallMs     float64 `json:"release_fall_ms,omitempty"`
+	ReleaseFallDiffMs float64 `json:"release_fall_diff_ms,omitempty"`
+	ReleaseNorm       float64 `json:"release_norm,omitempty"`
+
+	// Fundamental tracked near CompareOptions.F0Hz in both signals:
+	// candidate minus reference in cents at the frames where both have a
+	// clear fundamental, its RMS and mean, and the normalized error.
+	F0Detected        bool      `json:"f0_detected"`
+	F0ReferenceHz     float64   `json:"f0_reference_hz,omitempty"`
+	F0DeviationCents  float64   `json:"f0_deviation_cents,omitempty"`
+	F0MeanOffsetCents float64   `json:"f0_mean_offset_cents,omitempty"`
+	F0TrackTimesSec   []float64 `json:"f0_track_times_sec,omitempty"`
+	F0TrackCents      []float64 `json:"f0_track_cents,omitempty"`
+	F0Norm            float64   `json:"f0_norm,omitempty"`
+
+	// Beating (amplitude modulation) of the lowest partials near multiples
+	// of CompareOptions.F0Hz, with the mean rate and depth differences and
+	// the normalized error.
+	BeatDetected    bool          `json:"beat_detected"`
+	Beats           []PartialBeat `json:"beats,omitempty"`
+	BeatRateDiffHz  float64       `json:"beat_rate_diff_hz,omitempty"`
+	BeatDepthDiffDB float64       `json:"beat_depth_diff_db,omitempty"`
+	BeatNorm        float64       `json:"beat_norm,omitempty"`
+
+	// Per-partial decay times (T60) of the partials near multiples of
+	// CompareOptions.F0Hz found in both signals, the RMS error in octaves
+	// and the normalized error.
+	T60Detected bool         `json:"t60_detected"`
+	T60Curve    []PartialT60 `json:"t60_curve,omitempty"`
+	T60RMSELog2 float64      `json:"t60_rmse_log2,omitempty"`
+	T60Norm     float64      `json:"t60_norm,omitempty"`
+
+	// Spectral centroid (brightness) of the reference and candidate per
+	// 50 ms frame where both are above the silence floor, the RMS error in
+	// octaves and the normalized error.
+	CentroidDetected bool      `json:"centroid_detected"`
+	CentroidTimesSec []float64 `json:"centroid_times_sec,omitempty"`
+	CentroidRefHz    []float64 `json:"centroid_ref_hz,omitempty"`
+	CentroidCandHz   []float64 `json:"centroid_cand_hz,omitempty"`
+	CentroidRMSELog2 float64   `json:"centroid_rmse_log2,omitempty"`
+	CentroidNorm     float64   `json:"centroid_norm,omitempty"`
+
 	// Per-position spectral detail (evenly spaced across signal).
 	SpectralPositions []SpectralPosition `json:"spectral_positions,omitempty"`
 
@@ -70,6 +131,10 @@ type Metrics struct {
 	SpectralMidRMSEDB  float64 `json:"spectral_mid_rmse_db"`  // 500-2000 Hz
 	SpectralHighRMSEDB float64 `json:"spectral_high_rmse_db"` // 2000+ Hz
 
+	// Share of spectral bins skipped as noise (CompareOptions.NoiseFloorMask,
+	// LevelFloorDBFS).
+	SpectralMaskedFraction float64 `json:"spectral_masked_fraction,omitempty"`
+
 	// Normalized component contributions (0-1 each, weighted sum = Score).
 	TimeNorm     float64 `json:"time_norm"`
 	EnvelopeNorm float64 `json:"envelope_norm"`
@@ -79,6 +144,16 @@ type Metrics struct {
 
 	Score      float64 `json:"score"`
 	Similarity float64 `json:"similarity"`
+
+	// Held-out validation (CompareOptions.Validation, nil without it): the
+	// score with the spectral term measured at windows midway between the
+	// scoring windows.
+	ValidationSpectralRMSEDB *float64 `json:"validation_spectral_rmse_db,omitempty"`
+	ValidationScore          *float64 `json:"validation_score,omitempty"`
+	ValidationSimilarity     *float64 `json:"validation_similarity,omitempty"`
+
+	// Per-reference scores when several takes were compared (CompareMulti).
+	ReferenceScores []float64 `json:"reference_scores,omitempty"`
 }
 
 // SpectralPosition records spectral RMSE at a specific time offset.
@@ -87,8 +162,79 @@ type SpectralPosition struct {
 	RMSEDB    float64 `json:"rmse_db"`
 }
 
+// CompareOptions tunes CompareWithOptions. The zero value matches Compare.
+type CompareOptions struct {
+	// GainMatch applies a slowly-varying gain to the candidate so its level
+	// trajectory follows the reference before time, envelope and spectral
+	// metering. Decay slopes are still measured on the unmatched candidate.
+	GainMatch bool
+	// GainWindowSec is the RMS window (and gain-track hop) length; default 0.1 s.
+	GainWindowSec float64
+	// GainSmoothness penalizes window-to-window gain changes; larger values
+	// give a flatter track. Default 10.
+	GainSmoothness float64
+
+	// ReleaseWeight blends the release-window error into Score as
+	// (1-w)*score + w*ReleaseNorm when a damper event is detected in the
+	// reference (0 = report only).
+	ReleaseWeight float64
+	// ReleaseHalfWindowSec is the half-width of the window centered on the
+	// detected release; default 0.25 s.
+	ReleaseHalfWindowSec float64
+
+	// F0Hz is the nominal fundamental of the note (0 = not tracked). The
+	// fundamental is tracked near it in both signals and the RMS deviation
+	// in cents is blended into Score as (1-w)*score + w*F0Norm with
+	// w = F0Weight (0 = report only), so tuning error cannot be traded for
+	// level matching.
+	F0Hz     float64
+	F0Weight float64
+	// BeatWeight blends the beat-rate/depth error of the partials near
+	// multiples of F0Hz into Score the same way (0 = report only). It gives
+	// unison detune fitting a direct target.
+	BeatWeight float64
+	// T60Weight blends the per-partial T60 curve error into Score the same
+	// way (0 = report only). Unlike the broadband decay term it sees a
+	// treble that dies too early under a well-matched fundamental.
+	T60Weight float64
+	// CentroidWeight blends the spectral centroid trajectory error into
+	// Score the same way (0 = report only). It catches a brightness that
+	// decays at the wrong rate while the average spectrum matches.
+	CentroidWeight float64
+
+	// SpectralBandsPerOctave > 0 measures the spectral term over log-spaced
+	// bands of that many per octave instead of linear FFT bins, so the
+	// treble's many bins no longer outweigh the low and mid octaves
+	// (e.g. 3 for third-octave bands, 12 for semitones).
+	SpectralBandsPerOctave int
+	// NoiseFloorMask estimates the reference's noise floor per third-octave
+	// band and leaves bins where both signals are within 6 dB of it out of
+	// the spectral term (elsewhere only the level above it is compared), so
+	// hiss and decayed late windows add no error.
+	NoiseFloorMask bool
+	// LevelFloorDBFS < 0 is an absolute level floor for the envelope and
+	// spectral terms, in dB relative to a full-scale sinusoid after both
+	// signals are normalized to 0.1 RMS. Envelope frames and spectral bins
+	// (or bands) below it in both signals are skipped and only the level
+	// above it is compared, as with NoiseFloorMask, so silence and
+	// near-empty bins cannot turn tiny sample differences into large dB
+	// ratios. 0 disables it.
+	LevelFloorDBFS float64
+
+	// Validation also scores held-out spectral windows placed midway
+	// between the scoring windows. Score never sees them, so a
+	// ValidationScore that stops improving (or worsens) while Score drops
+	// shows a fit overfitting to the scoring windows.
+	Validation bool
+}
+
 // Compare returns objective distance metrics and a combined score in [0,1].
 func Compare(reference []float64, candidate []float64, sampleRate int) Metrics {
+	return CompareWithOptions(reference, candidate, sampleRate, CompareOptions{})
+}
+
+// CompareWithOptions is Compare with optional pre-metering processing.
+func CompareWithOptions(reference []float64, candidate []float64, sampleRate int, opts CompareOptions) Metrics {
 	m := Metrics{
 		SampleRate:      sampleRate,
 		ReferenceFrames: len(reference),
@@ -124,10 +270,13 @@ func Compare(reference []float64, candidate []float64, sampleRate int) Metrics {
 	if maxLag < 1 {
 		maxLag = 1
 	}
-	lag := estimateLag(ref, cand, maxLag)
+	lagFrac := estimateLagFrac(ref, cand, maxLag)
+	lag := int(math.Round(lagFrac))
 	m.LagSamples = lag
+	m.LagFraction = lagFrac - float64(lag)
 
 	refA, candA := alignByLag(ref, cand, lag)
+	refA = fractionalAdvance(refA, m.LagFraction)
 	n := len(refA)
 	if len(candA) < n {
 		n = len(candA)
@@ -145,6 +294,11 @@ func Compare(reference []float64, candidate []float64, sampleRate int) Metrics {
 	candA = candA[:n]
 	m.AlignedFrames = n
 
+	candRaw := candA
+	if opts.GainMatch {
+		candA, m.GainTrackDB, m.GainTrackHopSec = matchGainTrack(refA, candA, sampleRate, opts)
+	}
+
 	m.TimeRMSE = rmse(refA, candA)
 
 	refEnv := rmsEnvelope(refA, 256, 128)
@@ -154,25 +308,81 @@ func Compare(reference []float64, candidate []float64, sampleRate int) Metrics {
 		envN = len(candEnv)
 	}
 	if envN > 0 {
-		envDiff := make([]float64, envN)
+		// A full-scale sinusoid has an RMS of 1/sqrt(2).
+		floor := math.Inf(-1)
+		if opts.LevelFloorDBFS < 0 {
+			floor = linToDB(math.Sqrt(0.5)) + opts.LevelFloorDBFS
+		}
+		envDiff := make([]float64, 0, envN)
 		for i := 0; i < envN; i++ {
 			r := linToDB(refEnv[i])
 			c := linToDB(candEnv[i])
-			envDiff[i] = r - c
+			if r < floor && c < floor {
+				continue
+			}
+			envDiff = append(envDiff, max(r, floor)-max(c, floor))
 		}
 		m.EnvelopeRMSEDB = rms1(envDiff)
 	}
 
-	spectResult := spectralRMSEDBMulti(refA, candA, sampleRate)
+	spectResult := spectralRMSEDBMulti(refA, candA, sampleRate, opts)
 	m.SpectralRMSEDB = spectResult.overall
 	m.SpectralPositions = spectResult.positions
 	m.SpectralLowRMSEDB = spectResult.lowRMSE
 	m.SpectralMidRMSEDB = spectResult.midRMSE
 	m.SpectralHighRMSEDB = spectResult.highRMSE
+	m.SpectralMaskedFraction = spectResult.maskedFraction
 
+	// Release and decay are measured on the unmatched candidate: the gain
+	// track would cancel most of the level mismatch they score.
+	rawEnv := candEnv
+	if opts.GainMatch {
+		rawEnv = rmsEnvelope(candRaw, 256, 128)
+	}
 	hopSec := 128.0 / float64(sampleRate)
+	if rel := measureRelease(refEnv, rawEnv, hopSec, opts.ReleaseHalfWindowSec); rel.ok {
+		m.ReleaseDetected = true
+		m.ReleaseTimeSec = rel.timeSec
+		m.ReleaseEnvelopeRMSEDB = rel.envRMSEDB
+		m.ReleaseResidualDiffDB = rel.residualDB
+		m.ReleaseFallMs = rel.fallMs
+		m.ReleaseFallDiffMs = rel.fallDiffMs
+		m.ReleaseNorm = clamp01(((rel.envRMSEDB+rel.residualDB)/NormRelease + rel.fallDiffMs/NormReleaseFallMs) / 3)
+	}
+	if f0 := measureF0(refA, candA, sampleRate, opts.F0Hz); f0.ok {
+		m.F0Detected = true
+		m.F0ReferenceHz = f0.referenceF
+		m.F0DeviationCents = f0.rmsCents
+		m.F0MeanOffsetCents = f0.meanCents
+		m.F0TrackTimesSec = f0.times
+		m.F0TrackCents = f0.track
+		m.F0Norm = clamp01(f0.rmsCents / NormF0)
+	}
+	// Like decay, beating is measured on the unmatched candidate: the gain
+	// track would partly follow slow beats.
+	if beats := measureBeats(refA, candRaw, sampleRate, opts.F0Hz); beats.ok {
+		m.BeatDetected = true
+		m.Beats = beats.partials
+		m.BeatRateDiffHz = beats.rateDiff
+		m.BeatDepthDiffDB = beats.depthDiff
+		m.BeatNorm = clamp01(0.5 * (beats.rateDiff/NormBeatRate + beats.depthDiff/NormBeatDepth))
+	}
+	if t60 := measureT60Curve(refA, candRaw, sampleRate, opts.F0Hz); t60.ok {
+		m.T60Detected = true
+		m.T60Curve = t60.curve
+		m.T60RMSELog2 = t60.rmsLog2
+		m.T60Norm = clamp01(t60.rmsLog2 / NormT60)
+	}
+	if c := measureCentroid(refA, candA, sampleRate); c.ok {
+		m.CentroidDetected = true
+		m.CentroidTimesSec = c.times
+		m.CentroidRefHz = c.refHz
+		m.CentroidCandHz = c.candHz
+		m.CentroidRMSELog2 = c.rmsLog2
+		m.CentroidNorm = clamp01(c.rmsLog2 / NormCentroid)
+	}
 	m.RefDecayDBPerS = decaySlopeDBPerS(refEnv, hopSec)
-	m.CandDecayDBPerS = decaySlopeDBPerS(candEnv, hopSec)
+	m.CandDecayDBPerS = decaySlopeDBPerS(rawEnv, hopSec)
 	if isFinite(m.RefDecayDBPerS) && isFinite(m.CandDecayDBPerS) {
 		m.DecayDiffDBPerS = math.Abs(m.RefDecayDBPerS - m.CandDecayDBPerS)
 	}
@@ -182,19 +392,53 @@ func Compare(reference []float64, candidate []float64, sampleRate int) Metrics {
 	m.EnvelopeNorm = clamp01(m.EnvelopeRMSEDB / NormEnvelope)
 	m.SpectralNorm = clamp01(m.SpectralRMSEDB / NormSpectral)
 	m.DecayNorm = clamp01(m.DecayDiffDBPerS / NormDecay)
-	m.Score = clamp01(WeightTime*m.TimeNorm + WeightEnvelope*m.EnvelopeNorm + WeightSpectral*m.SpectralNorm + WeightDecay*m.DecayNorm)
+	// Optional terms, blended in this order as (1-w)*score + w*norm; a
+	// term whose feature was not measured gets weight 0.
+	type extraTerm struct {
+		name   string
+		weight float64
+		norm   float64
+	}
+	extras := []extraTerm{
+		{"release", extraWeight(m.ReleaseDetected, opts.ReleaseWeight), m.ReleaseNorm},
+		{"f0", extraWeight(m.F0Detected, opts.F0Weight), m.F0Norm},
+		{"beat", extraWeight(m.BeatDetected, opts.BeatWeight), m.BeatNorm},
+		{"t60", extraWeight(m.T60Detected, opts.T60Weight), m.T60Norm},
+		{"centroid", extraWeight(m.CentroidDetected, opts.CentroidWeight), m.CentroidNorm},
+	}
+	combine := func(spectralNorm float64) float64 {
+		score := clamp01(WeightTime*m.TimeNorm + WeightEnvelope*m.EnvelopeNorm + WeightSpectral*spectralNorm + WeightDecay*m.DecayNorm)
+		for _, e := range extras {
+			score = clamp01((1-e.weight)*score + e.weight*e.norm)
+		}
+		return score
+	}
+	m.Score = combine(m.SpectralNorm)
 	m.Similarity = clamp01(math.Exp(-4.0 * m.Score))
+	if opts.Validation {
+		spectral := spectralRMSEDBAt(refA, candA, sampleRate, opts, 0.5).overall
+		m.setValidation(spectral, combine(clamp01(spectral/NormSpectral)))
+	}
 
 	// Identify dominant component (highest weighted contribution).
 	type comp struct {
 		name string
 		val  float64
 	}
+	// Each term's share is scaled by the (1-w) of every term blended after it.
+	scale := make([]float64, len(extras)+1)
+	scale[len(extras)] = 1
+	for i := len(extras) - 1; i >= 0; i-- {
+		scale[i] = scale[i+1] * (1 - extras[i].weight)
+	}
 	comps := []comp{
-		{"time", WeightTime * m.TimeNorm},
-		{"envelope", WeightEnvelope * m.EnvelopeNorm},
-		{"spectral", WeightSpectral * m.SpectralNorm},
-		{"decay", WeightDecay * m.DecayNorm},
+		{"time", scale[0] * WeightTime * m.TimeNorm},
+		{"envelope", scale[0] * WeightEnvelope * m.EnvelopeNorm},
+		{"spectral", scale[0] * WeightSpectral * m.SpectralNorm},
+		{"decay", scale[0] * WeightDecay * m.DecayNorm},
+	}
+	for i, e := range extras {
+		comps = append(comps, comp{e.name, scale[i+1] * e.weight * e.norm})
 	}
 	best := comps[0]
 	for _, c := range comps[1:] {
@@ -207,6 +451,15 @@ func Compare(reference []float64, candidate []float64, sampleRate int) Metrics {
 	return m
 }
 
+// extraWeight is the blend weight of an optional score term: w clamped to
+// [0,1] when its feature was measured, 0 otherwise.
+func extraWeight(measured bool, w float64) float64 {
+	if !measured {
+		return 0
+	}
+	return clamp01(w)
+}
+
 func trimLeadingSilence(x []float64, threshold float64) []float64 {
 	for i := 0; i < len(x); i++ {
 		if math.Abs(x[i]) > threshold {
@@ -233,6 +486,12 @@ func normalizeRMS(x []float64, target float64) []float64 {
 }
 
 func estimateLag(ref []float64, cand []float64, maxLag int) int {
+	return int(math.Round(estimateLagFrac(ref, cand, maxLag)))
+}
+
+// estimateLagFrac returns the (sub-sample) lag that best aligns cand to ref,
+// i.e. cand[i] ≈ ref[i+lag].
+func estimateLagFrac(ref []float64, cand []float64, maxLag int) float64 {
 	if len(ref) == 0 || len(cand) == 0 {
 		return 0
 	}
@@ -251,7 +510,7 @@ func estimateLag(ref []float64, cand []float64, maxLag int) int {
 	if lag, ok := estimateLagFFT(ref, cand, maxLag); ok {
 		return lag
 	}
-	return estimateLagExhaustive(ref, cand, maxLag)
+	return float64(estimateLagExhaustive(ref, cand, maxLag))
 }
 
 func estimateLagExhaustive(ref []float64, cand []float64, maxLag int) int {
@@ -271,14 +530,64 @@ func estimateLagExhaustive(ref []float64, cand []float64, maxLag int) int {
 	return bestLag
 }
 
-func estimateLagFFT(ref []float64, cand []float64, maxLag int) (int, bool) {
-	nfft := nextPow2(len(ref) + len(cand) - 1)
+// estimateLagFFT picks the raw cross-correlation peak weighted by the
+// correlation of the amplitude envelopes. The envelope term is insensitive to
+// phase and reverb-tail differences, so it suppresses spurious raw peaks far
+// from the true onset alignment. The chosen peak is refined with parabolic
+// interpolation for a sub-sample lag.
+func estimateLagFFT(ref []float64, cand []float64, maxLag int) (float64, bool) {
+	raw, ok := crossCorrelateFFT(ref, cand, maxLag)
+	if !ok {
+		return 0, false
+	}
+	env, ok := crossCorrelateFFT(lagEnvelope(ref), lagEnvelope(cand), maxLag)
+	if !ok {
+		return 0, false
+	}
+	envMax := 0.0
+	for _, v := range env {
+		if v > envMax {
+			envMax = v
+		}
+	}
+
+	best := 0
+	bestScore := math.Inf(-1)
+	for i, c := range raw {
+		w := 1.0
+		if envMax > 0 {
+			w = 0.5 + 0.5*math.Max(0, env[i]/envMax)
+		}
+		if s := c * w; s > bestScore {
+			bestScore = s
+			best = i
+		}
+	}
+
+	lag := float64(best - maxLag)
+	if best > 0 && best < len(raw)-1 {
+		y0, y1, y2 := raw[best-1], raw[best], raw[best+1]
+		den := y0 - 2*y1 + y2
+		if den < 0 {
+			delta := 0.5 * (y0 - y2) / den
+			if delta > -0.5 && delta < 0.5 {
+				lag += delta
+			}
+		}
+	}
+	return lag, true
+}
+
+// crossCorrelateFFT returns sum_n a[n+lag]*b[n] for lag in [-maxLag,maxLag],
+// indexed by lag+maxLag.
+func crossCorrelateFFT(a []float64, b []float64, maxLag int) ([]float64, bool) {
+	nfft := nextPow2(len(a) + len(b) - 1)
 	if nfft < 2 {
 		nfft = 2
 	}
 	plan, err := getLagFFTPlan(nfft)
 	if err != nil {
-		return 0, false
+		return nil, false
 	}
 
 	plan.mu.Lock()
@@ -286,36 +595,78 @@ func estimateLagFFT(ref []float64, cand []float64, maxLag int) (int, bool) {
 
 	clear(plan.inA)
 	clear(plan.inB)
-	copy(plan.inA, ref)
-	copy(plan.inB, cand)
+	copy(plan.inA, a)
+	copy(plan.inB, b)
 
 	if err := plan.forward(plan.specA, plan.inA); err != nil {
-		return 0, false
+		return nil, false
 	}
 	if err := plan.forward(plan.specB, plan.inB); err != nil {
-		return 0, false
+		return nil, false
 	}
 	for i := range plan.specA {
 		plan.specA[i] *= cmplx.Conj(plan.specB[i])
 	}
 	if err := plan.inverse(plan.corr, plan.specA); err != nil {
-		return 0, false
+		return nil, false
 	}
 
-	bestLag := 0
-	best := math.Inf(-1)
+	out := make([]float64, 2*maxLag+1)
 	for lag := -maxLag; lag <= maxLag; lag++ {
 		idx := lag
 		if idx < 0 {
 			idx += plan.n
 		}
-		s := plan.corr[idx]
-		if s > best {
-			best = s
-			bestLag = lag
+		out[lag+maxLag] = plan.corr[idx]
+	}
+	return out, true
+}
+
+// lagEnvelope returns a mean-removed, smoothed amplitude envelope used to
+// weight lag candidates.
+func lagEnvelope(x []float64) []float64 {
+	const smooth = 1.0 / 64.0
+	out := make([]float64, len(x))
+	state := 0.0
+	sum := 0.0
+	for i, v := range x {
+		state += (math.Abs(v) - state) * smooth
+		out[i] = state
+		sum += state
+	}
+	if len(out) == 0 {
+		return out
+	}
+	mean := sum / float64(len(out))
+	for i := range out {
+		out[i] -= mean
+	}
+	return out
+}
+
+// fractionalAdvance returns x resampled at i+frac (|frac| < 1) using 4-point
+// Hermite interpolation, with edge samples held.
+func fractionalAdvance(x []float64, frac float64) []float64 {
+	if frac == 0 || len(x) == 0 {
+		return x
+	}
+	at := func(i int) float64 {
+		if i < 0 {
+			return x[0]
 		}
+		if i >= len(x) {
+			return x[len(x)-1]
+		}
+		return x[i]
 	}
-	return bestLag, true
+	out := make([]float64, len(x))
+	for i := range out {
+		pos := float64(i) + frac
+		j := int(math.Floor(pos))
+		t := pos - float64(j)
+		out[i] = dspinterp.Hermite4(t, at(j-1), at(j), at(j+1), at(j+2))
+	}
+	return out
 }
 
 func getLagFFTPlan(n int) (*lagFFTPlan, error) {
@@ -468,6 +819,8 @@ type spectralResult struct {
 	lowRMSE   float64 // 0-500 Hz
 	midRMSE   float64 // 500-2000 Hz
 	highRMSE  float64 // 2000+ Hz
+
+	maskedFraction float64 // share of bins (or bands) skipped as noise
 }
 
 // Phase weights for early/sustain/decay portions of the signal.
@@ -481,7 +834,16 @@ const (
 
 // spectralRMSEDBMulti computes spectral RMSE across multiple time positions
 // with phase-aware weighting (attack > sustain > decay) and per-band breakdown.
-func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResult {
+// opts.SpectralBandsPerOctave > 0 takes the error over log-spaced bands
+// instead of linear FFT bins (see logBands); opts.NoiseFloorMask skips bins
+// where both signals are at the reference's noise floor.
+func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int, opts CompareOptions) spectralResult {
+	return spectralRMSEDBAt(a, b, sampleRate, opts, 0)
+}
+
+// spectralRMSEDBAt is spectralRMSEDBMulti with the window positions moved by
+// shift strides; shift 0.5 places them midway between the scoring windows.
+func spectralRMSEDBAt(a []float64, b []float64, sampleRate int, opts CompareOptions, shift float64) spectralResult {
 	n := len(a)
 	if len(b) < n {
 		n = len(b)
@@ -515,13 +877,19 @@ func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResul
 			stride = 1
 		}
 		for i := 0; i < nPos; i++ {
-			pos := i * stride
+			pos := int((float64(i) + shift) * float64(stride))
 			if pos+winSize > n {
+				if shift > 0 {
+					break
+				}
 				pos = n - winSize
 			}
 			positions = append(positions, pos)
 		}
 	}
+	if len(positions) == 0 {
+		return spectralResult{}
+	}
 
 	plan, err := getSpectralFFTPlan(winSize)
 	bins := winSize / 2
@@ -546,6 +914,45 @@ func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResul
 	if midBinEnd > bins {
 		midBinEnd = bins
 	}
+	var bands []logBand
+	if opts.SpectralBandsPerOctave > 0 {
+		bands = logBands(binHz, bins, opts.SpectralBandsPerOctave)
+	}
+	// Per-bin (or per-band) level below which a bin counts as noise; -Inf
+	// masks nothing.
+	floorDB := make([]float64, max(bins, len(bands)))
+	for i := range floorDB {
+		floorDB[i] = math.Inf(-1)
+	}
+	if opts.NoiseFloorMask && err == nil {
+		if floor := referenceNoiseFloor(plan, a[:n], hann, binHz, bins); floor != nil {
+			if bands == nil {
+				copy(floorDB, floor)
+			} else {
+				for i, band := range bands {
+					var p float64
+					for k := band.lo; k < band.hi; k++ {
+						p += math.Pow(10, floor[k]/10)
+					}
+					floorDB[i] = 10 * math.Log10(p/float64(band.hi-band.lo))
+				}
+			}
+			for i := range floorDB {
+				floorDB[i] += noiseFloorMarginDB
+			}
+		}
+	}
+	if opts.LevelFloorDBFS < 0 {
+		// A full-scale sinusoid peaks at half the window sum.
+		var hannSum float64
+		for _, w := range hann {
+			hannSum += w
+		}
+		floor := linToDB(hannSum/2) + opts.LevelFloorDBFS
+		for i := range floorDB {
+			floorDB[i] = math.Max(floorDB[i], floor)
+		}
+	}
 
 	type bandAccum struct {
 		sum float64
@@ -553,6 +960,7 @@ func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResul
 	}
 
 	var weightedSum, weightTotal float64
+	var compared, masked int
 	detail := make([]SpectralPosition, 0, len(positions))
 	var bandLow, bandMid, bandHigh bandAccum
 
@@ -568,55 +976,81 @@ func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResul
 			bw[i] = b[pos+i] * hann[i]
 		}
 
-		var posSum float64
-		var lowSum, midSum, highSum float64
-		cnt := bins - 1
-
-		computeBins := func(getMag func(k int) (float64, float64)) {
-			for k := 1; k < bins; k++ {
-				da, db := getMag(k)
-				d := da - db
-				dsq := d * d
-				posSum += dsq
-				if k < lowBinEnd {
-					lowSum += dsq
-					bandLow.cnt++
-				} else if k < midBinEnd {
-					midSum += dsq
-					bandMid.cnt++
-				} else {
-					highSum += dsq
-					bandHigh.cnt++
-				}
-			}
-		}
-
+		magA := make([]float64, bins)
+		magB := make([]float64, bins)
 		computed := false
 		if err == nil {
 			specA := make([]complex128, bins+1)
 			specB := make([]complex128, bins+1)
 			if e := plan.forward(specA, aw); e == nil {
 				if e := plan.forward(specB, bw); e == nil {
-					computeBins(func(k int) (float64, float64) {
-						return linToDB(cmplx.Abs(specA[k])), linToDB(cmplx.Abs(specB[k]))
-					})
+					for k := 1; k < bins; k++ {
+						magA[k], magB[k] = cmplx.Abs(specA[k]), cmplx.Abs(specB[k])
+					}
 					computed = true
 				}
 			}
 		}
 		if !computed {
-			computeBins(func(k int) (float64, float64) {
-				return linToDB(dftBinMag(aw, k)), linToDB(dftBinMag(bw, k))
-			})
+			for k := 1; k < bins; k++ {
+				magA[k], magB[k] = dftBinMag(aw, k), dftBinMag(bw, k)
+			}
+		}
+
+		var posSum float64
+		var lowSum, midSum, highSum float64
+		cnt := 0
+		add := func(da, db, floor float64, low, mid bool) {
+			if da < floor && db < floor {
+				masked++
+				return
+			}
+			// Only the part above the floor is compared, so a hiss peak
+			// poking out of it adds little.
+			d := max(da, floor) - max(db, floor)
+			dsq := d * d
+			cnt++
+			posSum += dsq
+			switch {
+			case low:
+				lowSum += dsq
+				bandLow.cnt++
+			case mid:
+				midSum += dsq
+				bandMid.cnt++
+			default:
+				highSum += dsq
+				bandHigh.cnt++
+			}
+		}
+		if bands == nil {
+			for k := 1; k < bins; k++ {
+				add(linToDB(magA[k]), linToDB(magB[k]), floorDB[k], k < lowBinEnd, k < midBinEnd)
+			}
+		} else {
+			for i, band := range bands {
+				var pa, pb float64
+				for k := band.lo; k < band.hi; k++ {
+					pa += magA[k] * magA[k]
+					pb += magB[k] * magB[k]
+				}
+				w := float64(band.hi - band.lo)
+				add(linToDB(math.Sqrt(pa/w)), linToDB(math.Sqrt(pb/w)), floorDB[i], band.centerHz < 500, band.centerHz < 2000)
+			}
 		}
 
 		bandLow.sum += lowSum
 		bandMid.sum += midSum
 		bandHigh.sum += highSum
-
-		posRMSE := math.Sqrt(posSum / float64(cnt))
-		weightedSum += weight * posSum / float64(cnt)
-		weightTotal += weight
+		compared += cnt
+
+		// A window that is all noise has no error to report and no weight.
+		posRMSE := 0.0
+		if cnt > 0 {
+			posRMSE = math.Sqrt(posSum / float64(cnt))
+			weightedSum += weight * posSum / float64(cnt)
+			weightTotal += weight
+		}
 		detail = append(detail, SpectralPosition{
 			OffsetSec: float64(pos) / float64(sampleRate),
 			RMSEDB:    posRMSE,
@@ -625,6 +1059,9 @@ func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResul
 
 	var result spectralResult
 	result.positions = detail
+	if masked > 0 {
+		result.maskedFraction = float64(masked) / float64(masked+compared)
+	}
 	if weightTotal > 0 {
 		result.overall = math.Sqrt(weightedSum / weightTotal)
 	}
@@ -640,6 +1077,103 @@ func spectralRMSEDBMulti(a []float64, b []float64, sampleRate int) spectralResul
 	return result
 }
 
+// Noise floor estimate for CompareOptions.NoiseFloorMask.
+const (
+	noiseFloorFrames     = 64   // reference frames sampled
+	noiseFloorMinFrames  = 4    // fewer frames give no estimate
+	noiseFloorPercentile = 0.10 // per-bin level percentile over the frames
+	noiseFloorMarginDB   = 6.0  // bins within this of the floor count as noise
+)
+
+// referenceNoiseFloor estimates the noise floor of x per FFT bin in dB:
+// the mean power of each third-octave band (single bins below the lowest
+// band) in up to noiseFloorFrames half-overlapping Hann frames, and of
+// those the noiseFloorPercentile level, i.e. the quietest stretch (usually
+// the decayed tail or the recording's hiss). Pooling bins into bands before
+// taking the percentile keeps the bins' own scatter out of the estimate.
+// Returns nil when x is too short.
+func referenceNoiseFloor(plan *spectralFFTPlan, x []float64, hann []float64, binHz float64, bins int) []float64 {
+	winSize := len(hann)
+	hop := winSize / 2
+	frames := 1 + (len(x)-winSize)/hop
+	if len(x) < winSize || frames < noiseFloorMinFrames {
+		return nil
+	}
+	thirds := logBands(binHz, bins, 3)
+	first := bins
+	if len(thirds) > 0 {
+		first = thirds[0].lo
+	}
+	var bands []logBand
+	for k := 1; k < first; k++ {
+		bands = append(bands, logBand{lo: k, hi: k + 1})
+	}
+	bands = append(bands, thirds...)
+	used := min(frames, noiseFloorFrames)
+	levels := make([][]float64, len(bands))
+	for i := range levels {
+		levels[i] = make([]float64, used)
+	}
+	in := make([]float64, winSize)
+	spec := make([]complex128, bins+1)
+	for f := range used {
+		start := f * (frames - 1) / (used - 1) * hop
+		for i := range in {
+			in[i] = x[start+i] * hann[i]
+		}
+		if err := plan.forward(spec, in); err != nil {
+			return nil
+		}
+		for i, band := range bands {
+			var p float64
+			for k := band.lo; k < band.hi; k++ {
+				a := cmplx.Abs(spec[k])
+				p += a * a
+			}
+			levels[i][f] = linToDB(math.Sqrt(p / float64(band.hi-band.lo)))
+		}
+	}
+	floor := make([]float64, bins)
+	for i, band := range bands {
+		sort.Float64s(levels[i])
+		level := levels[i][int(noiseFloorPercentile*float64(used-1))]
+		for k := band.lo; k < band.hi; k++ {
+			floor[k] = level
+		}
+	}
+	return floor
+}
+
+// logBandMinHz is the lower edge of the lowest log-spaced spectral band.
+const logBandMinHz = 20.0
+
+// logBand is a run of FFT bins [lo, hi) pooled into one log-spaced band.
+type logBand struct {
+	lo, hi   int
+	centerHz float64
+}
+
+// logBands splits bins 1..bins-1 into bands of 1/perOctave octave from
+// logBandMinHz up. Bands narrower than the bin spacing hold no bin center
+// and are dropped, so the low end falls back to single bins. Returns nil
+// when no band holds a bin.
+func logBands(binHz float64, bins int, perOctave int) []logBand {
+	var bands []logBand
+	k := max(1, int(math.Ceil(logBandMinHz/binHz)))
+	for i := 0; k < bins; i++ {
+		lo := logBandMinHz * math.Pow(2, float64(i)/float64(perOctave))
+		hi := logBandMinHz * math.Pow(2, float64(i+1)/float64(perOctave))
+		start := k
+		for k < bins && float64(k)*binHz < hi {
+			k++
+		}
+		if k > start {
+			bands = append(bands, logBand{lo: start, hi: k, centerHz: math.Sqrt(lo * hi)})
+		}
+	}
+	return bands
+}
+
 // detectPhases finds the sample indices marking the end of the attack phase
 // and the end of the sustain phase, using the RMS envelope.
 // Attack ends at the first envelope peak. Sustain ends when the envelope
@@ -866,6 +1400,13 @@ func decaySlopeDBPerS(env []float64, hopSec float64) float64 {
 	return (n*sxy - sx*sy) / den
 }
 
+// setValidation sets the held-out validation metrics from the spectral
+// RMSE and score of the validation windows.
+func (m *Metrics) setValidation(spectralRMSEDB float64, score float64) {
+	similarity := clamp01(math.Exp(-4.0 * score))
+	m.ValidationSpectralRMSEDB, m.ValidationScore, m.ValidationSimilarity = &spectralRMSEDB, &score, &similarity
+}
+
 func clamp01(x float64) float64 {
 	if x < 0 {
 		return 0
@@ -1,8 +1,10 @@
 package analysis
 
 import (
+	"encoding/json"
 	"math"
 	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -77,6 +79,69 @@ func TestEstimateLagFFTMatchesExhaustive(t *testing.T) {
 	}
 }
 
+func TestEstimateLagFracFindsSubSampleShift(t *testing.T) {
+	const sr = 48000
+	const shift = 0.3
+	ref := makeDecayChord(sr, 0, 0.5)
+	cand := makeDecayChord(sr, shift, 0.5)
+
+	got := estimateLagFrac(ref, cand, 600)
+	if math.Abs(got+shift) > 0.05 {
+		t.Fatalf("estimateLagFrac() = %f, want %f", got, -shift)
+	}
+}
+
+func TestCompareSubSampleAlignmentReducesTimeRMSE(t *testing.T) {
+	const sr = 48000
+	ref := makeDecayChord(sr, 0, 1.0)
+	cand := makeDecayChord(sr, 0.5, 1.0)
+	m := Compare(ref, cand, sr)
+	if math.Abs(m.LagFraction) < 0.3 {
+		t.Fatalf("expected fractional lag near 0.5, got lag=%d frac=%f", m.LagSamples, m.LagFraction)
+	}
+	if m.TimeRMSE > 0.01 {
+		t.Fatalf("expected sub-sample alignment to remove phase error, got TimeRMSE %f", m.TimeRMSE)
+	}
+}
+
+func TestEstimateLagIgnoresReverbTailDifferences(t *testing.T) {
+	const (
+		sr    = 48000
+		shift = 311
+	)
+	dry := makeDecaySine(sr, 261.63, 1.0, 0.15)
+	tail := randomSignal(len(dry), 5)
+	ref := make([]float64, len(dry)+shift)
+	for i := range dry {
+		tt := float64(i) / sr
+		// Late diffuse energy that the candidate does not have.
+		ref[i+shift] = dry[i] + 0.4*tail[i]*(1-math.Exp(-tt/0.05))*math.Exp(-tt/0.6)
+	}
+	cand := dry
+
+	got := estimateLag(ref, cand, 2000)
+	if got != shift {
+		t.Fatalf("estimateLag() = %d, want %d", got, shift)
+	}
+}
+
+// makeDecayChord renders a few decaying partials evaluated at t-delaySamples/sr.
+func makeDecayChord(sr int, delaySamples float64, durationSec float64) []float64 {
+	n := int(float64(sr) * durationSec)
+	out := make([]float64, n)
+	freqs := []float64{220, 331, 447, 1250}
+	for i := range out {
+		t := (float64(i) - delaySamples) / float64(sr)
+		if t < 0 {
+			continue
+		}
+		for k, f := range freqs {
+			out[i] += math.Exp(-t/0.4) * math.Sin(2*math.Pi*f*t+float64(k)) / float64(k+1)
+		}
+	}
+	return out
+}
+
 func makeDecaySine(sr int, freq float64, durationSec float64, decaySec float64) []float64 {
 	n := int(float64(sr) * durationSec)
 	if n < 1 {
@@ -99,3 +164,164 @@ func randomSignal(n int, seed int64) []float64 {
 	}
 	return out
 }
+
+func TestCompareValidationWindowsExposeOverfitting(t *testing.T) {
+	sr := 16000
+	n := 4096 * 15 // scoring windows every 8192 samples, held-out ones in between
+	ref := make([]float64, n)
+	cand := make([]float64, n)
+	for i := range n {
+		tt := float64(i) / float64(sr)
+		ref[i] = 0.5 * math.Sin(2*math.Pi*440*tt)
+		cand[i] = ref[i]
+		// Only the held-out windows hear a different partial.
+		if (i/4096)%2 == 1 {
+			cand[i] = 0.5 * math.Sin(2*math.Pi*1250*tt)
+		}
+	}
+	m := CompareWithOptions(ref, cand, sr, CompareOptions{Validation: true})
+	if m.ValidationScore == nil {
+		t.Fatal("no validation metrics with Validation set")
+	}
+	if *m.ValidationSpectralRMSEDB <= m.SpectralRMSEDB+3 {
+		t.Fatalf("held-out windows should see the mismatch: validation %.2f dB vs scoring %.2f dB", *m.ValidationSpectralRMSEDB, m.SpectralRMSEDB)
+	}
+	if *m.ValidationScore <= m.Score {
+		t.Fatalf("expected validation score above score, got %f <= %f", *m.ValidationScore, m.Score)
+	}
+	plain := Compare(ref, cand, sr)
+	if plain.Score != m.Score || plain.ValidationScore != nil {
+		t.Fatalf("validation must not change Score: %f vs %f", plain.Score, m.Score)
+	}
+}
+
+func TestLogBandsCoverBinsContiguously(t *testing.T) {
+	binHz := 48000.0 / 4096
+	bands := logBands(binHz, 2048, 3)
+	if len(bands) == 0 || bands[0].lo != 2 || bands[len(bands)-1].hi != 2048 {
+		t.Fatalf("bands %+v do not span bins 2..2047", bands)
+	}
+	for i := 1; i < len(bands); i++ {
+		if bands[i].lo != bands[i-1].hi || bands[i].centerHz <= bands[i-1].centerHz {
+			t.Fatalf("band %d %+v does not follow %+v", i, bands[i], bands[i-1])
+		}
+	}
+	// Above the resolution limit every octave holds three bands.
+	var octave int
+	for _, b := range bands {
+		if b.centerHz >= 4000 && b.centerHz < 8000 {
+			octave++
+		}
+	}
+	if octave != 3 {
+		t.Fatalf("got %d bands in 4-8 kHz, want 3", octave)
+	}
+}
+
+func TestSpectralLogBandsWeighLowOctavesEqually(t *testing.T) {
+	sr := 48000
+	rng := rand.New(rand.NewSource(3))
+	a := make([]float64, sr)
+	for i := range a {
+		a[i] = rng.Float64()*2 - 1
+	}
+	// b differs only below a few hundred Hz: a boosted one-pole low-pass.
+	b := make([]float64, len(a))
+	lp := 0.0
+	for i, v := range a {
+		lp += 0.03 * (v - lp)
+		b[i] = v + 3*lp
+	}
+	linear := spectralRMSEDBMulti(a, b, sr, CompareOptions{})
+	banded := spectralRMSEDBMulti(a, b, sr, CompareOptions{SpectralBandsPerOctave: 3})
+	if banded.overall < 2*linear.overall {
+		t.Fatalf("log bands should weigh the low octaves up: %.2f dB banded vs %.2f dB linear", banded.overall, linear.overall)
+	}
+	if banded.lowRMSE < banded.highRMSE {
+		t.Fatalf("mismatch should sit in the low band: low %.2f dB, high %.2f dB", banded.lowRMSE, banded.highRMSE)
+	}
+	if same := spectralRMSEDBMulti(a, a, sr, CompareOptions{SpectralBandsPerOctave: 3}); same.overall > 1e-9 {
+		t.Fatalf("identical signals: %.4f dB", same.overall)
+	}
+}
+
+func TestNoiseFloorMaskIgnoresHiss(t *testing.T) {
+	sr := 48000
+	tone := makeDecaySine(sr, 440, 3, 0.4)
+	hiss := func(seed int64) []float64 {
+		rng := rand.New(rand.NewSource(seed))
+		out := make([]float64, len(tone))
+		for i := range out {
+			out[i] = tone[i] + 1e-3*(rng.Float64()*2-1)
+		}
+		return out
+	}
+	ref, cand := hiss(1), hiss(2) // same note, different recording hiss
+
+	plain := spectralRMSEDBMulti(ref, cand, sr, CompareOptions{})
+	masked := spectralRMSEDBMulti(ref, cand, sr, CompareOptions{NoiseFloorMask: true})
+	if masked.maskedFraction < 0.5 {
+		t.Fatalf("expected most bins masked as hiss, got %.2f", masked.maskedFraction)
+	}
+	if masked.overall > 0.5*plain.overall {
+		t.Fatalf("masking should drop the hiss error: %.2f dB masked vs %.2f dB plain", masked.overall, plain.overall)
+	}
+
+	// A partial the reference lacks stays in the error even where the
+	// reference is only hiss.
+	louder := append([]float64(nil), cand...)
+	for i := range louder {
+		louder[i] += 0.05 * math.Sin(2*math.Pi*3000*float64(i)/float64(sr))
+	}
+	if wrong := spectralRMSEDBMulti(ref, louder, sr, CompareOptions{NoiseFloorMask: true}); wrong.overall <= masked.overall+1 {
+		t.Fatalf("extra partial hidden by the mask: %.2f dB vs %.2f dB", wrong.overall, masked.overall)
+	}
+	if plain.maskedFraction != 0 {
+		t.Fatalf("nothing should be masked without NoiseFloorMask, got %.2f", plain.maskedFraction)
+	}
+}
+
+func TestLevelFloorIgnoresRoundingNoise(t *testing.T) {
+	sr := 48000
+	ref := makeDecaySine(sr, 440, 1, 0.4)
+	rng := rand.New(rand.NewSource(1))
+	cand := make([]float64, len(ref))
+	for i, v := range ref {
+		cand[i] = v + 1e-6*(rng.Float64()*2-1)
+	}
+
+	plain := Compare(ref, cand, sr)
+	floored := CompareWithOptions(ref, cand, sr, CompareOptions{LevelFloorDBFS: -90})
+	if plain.SpectralRMSEDB < 1 {
+		t.Fatalf("expected rounding noise to show in empty bins without a floor, got %.2f dB", plain.SpectralRMSEDB)
+	}
+	if floored.SpectralRMSEDB > 0.1 || floored.EnvelopeRMSEDB > 0.1 {
+		t.Fatalf("rounding noise below the floor: spectral %.2f dB, envelope %.2f dB", floored.SpectralRMSEDB, floored.EnvelopeRMSEDB)
+	}
+
+	// A real change above the floor is still measured.
+	louder := append([]float64(nil), cand...)
+	for i := range louder {
+		louder[i] += 0.05 * math.Sin(2*math.Pi*3000*float64(i)/float64(sr))
+	}
+	if wrong := CompareWithOptions(ref, louder, sr, CompareOptions{LevelFloorDBFS: -90}); wrong.SpectralRMSEDB < 1 {
+		t.Fatalf("extra partial hidden by the floor: %.2f dB", wrong.SpectralRMSEDB)
+	}
+}
+
+func TestPerfectValidationScoreIsReported(t *testing.T) {
+	var perfect Metrics
+	perfect.setValidation(0, 0)
+	b, err := json.Marshal(perfect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"validation_score":0,`) {
+		t.Fatalf("a perfect validation score is dropped from %s", b)
+	}
+	sr := 48000
+	x := makeDecaySine(sr, 440, 1.5, 0.7)
+	if b, _ := json.Marshal(Compare(x, x, sr)); strings.Contains(string(b), "validation_score") {
+		t.Fatalf("validation score reported without Validation: %s", b)
+	}
+}
@@ -0,0 +1,245 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+// Envelope is a short-time RMS level track, using the same framing as the
+// envelope term of Compare.
+type Envelope struct {
+	HopSec   float64   `json:"hop_sec"`
+	LevelsDB []float64 `json:"levels_db"`
+}
+
+// ExtractEnvelope returns the RMS envelope (256-sample frames, 128 hop) of x
+// in dBFS, starting at the first non-silent sample.
+func ExtractEnvelope(x []float64, sampleRate int) Envelope {
+	if sampleRate <= 0 {
+		return Envelope{}
+	}
+	env := rmsEnvelope(trimLeadingSilence(x, 1e-6), 256, 128)
+	out := Envelope{HopSec: 128.0 / float64(sampleRate), LevelsDB: make([]float64, len(env))}
+	for i, v := range env {
+		out.LevelsDB[i] = linToDB(v)
+	}
+	return out
+}
+
+// Partial describes one measured partial of a note.
+type Partial struct {
+	Index       int     `json:"index"`
+	FreqHz      float64 `json:"freq_hz"`
+	AmplitudeDB float64 `json:"amplitude_db"` // sinusoid amplitude at the onset, dBFS
+	DecayDBPerS float64 `json:"decay_db_per_s"`
+	T60Sec      float64 `json:"t60_sec"` // time to decay 60 dB, capped at partialMaxT60
+}
+
+const (
+	partialWindow      = 8192
+	partialSkipSec     = 0.01 // skip the hammer transient
+	partialSearch      = 0.3  // search half-width in multiples of f0
+	partialDecayRange  = 40.0 // dB below a partial's peak used for the decay fit
+	partialMaxDuration = 4.0  // seconds analyzed for decay
+	partialMaxT60      = 60.0 // seconds; T60 of partials that barely decay
+)
+
+// ExtractPartials measures up to maxPartials partials of a note with
+// nominal fundamental f0. Partials are searched near k*f0, following the
+// stretch of the partials found so far so inharmonic strings are tracked.
+// Partials above Nyquist or indistinguishable from the noise floor are
+// omitted.
+func ExtractPartials(x []float64, sampleRate int, f0 float64, maxPartials int) []Partial {
+	if sampleRate <= 0 || f0 <= 0 || maxPartials <= 0 {
+		return nil
+	}
+	x = trimLeadingSilence(x, 1e-6)
+	skip := int(partialSkipSec * float64(sampleRate))
+	if len(x) <= skip {
+		return nil
+	}
+	x = x[skip:]
+
+	n := partialWindow
+	for n > 1024 && n > len(x) {
+		n >>= 1
+	}
+	if len(x) < n {
+		return nil
+	}
+	plan, err := algofft.NewPlanReal64(n)
+	if err != nil {
+		return nil
+	}
+	win := make([]float64, n)
+	var winSum float64
+	for i := range win {
+		win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		winSum += win[i]
+	}
+	binHz := float64(sampleRate) / float64(n)
+
+	// Magnitude spectra (sinusoid amplitude scale) of hop n/2 frames.
+	hop := n / 2
+	maxFrames := int(partialMaxDuration*float64(sampleRate)-float64(n))/hop + 1
+	frames := make([][]float64, 0, maxFrames)
+	in := make([]float64, n)
+	spec := make([]complex128, n/2+1)
+	for start := 0; start+n <= len(x) && len(frames) < maxFrames; start += hop {
+		for i := range in {
+			in[i] = x[start+i] * win[i]
+		}
+		if err := plan.Forward(spec, in); err != nil {
+			return nil
+		}
+		mag := make([]float64, len(spec))
+		for i, v := range spec {
+			mag[i] = 2 * cmplx.Abs(v) / winSum
+		}
+		frames = append(frames, mag)
+	}
+	first := frames[0]
+	floor := 0.0
+	for _, v := range first {
+		floor += v
+	}
+	floor /= float64(len(first))
+
+	hopSec := float64(hop) / float64(sampleRate)
+	stretch := 1.0
+	out := make([]Partial, 0, maxPartials)
+	for k := 1; k <= maxPartials; k++ {
+		pred := float64(k) * f0 * stretch
+		lo := int((pred - partialSearch*f0) / binHz)
+		hi := int(math.Ceil((pred + partialSearch*f0) / binHz))
+		if lo < 1 {
+			lo = 1
+		}
+		if hi > len(first)-2 {
+			hi = len(first) - 2
+		}
+		if lo >= hi {
+			break
+		}
+		peak := lo
+		for i := lo + 1; i <= hi; i++ {
+			if first[i] > first[peak] {
+				peak = i
+			}
+		}
+		if peak == lo || peak == hi || first[peak] < 4*floor {
+			continue // edge of the search band or noise: no clear partial
+		}
+
+		// Parabolic interpolation on log magnitude.
+		a, b, c := linToDB(first[peak-1]), linToDB(first[peak]), linToDB(first[peak+1])
+		delta := 0.0
+		if den := a - 2*b + c; den != 0 {
+			delta = 0.5 * (a - c) / den
+		}
+		freq := (float64(peak) + delta) * binHz
+		stretch = freq / (float64(k) * f0)
+
+		track := make([]float64, len(frames))
+		for f, mag := range frames {
+			m := mag[peak]
+			for i := peak - 1; i <= peak+1; i++ {
+				m = math.Max(m, mag[i])
+			}
+			track[f] = linToDB(m)
+		}
+		decay := partialDecaySlope(track, hopSec)
+		out = append(out, Partial{
+			Index:       k,
+			FreqHz:      freq,
+			AmplitudeDB: b - 0.25*(a-c)*delta,
+			DecayDBPerS: decay,
+			T60Sec:      decayT60(decay),
+		})
+	}
+	return out
+}
+
+// inharmonicityPartials is how many partials EstimateInharmonicity fits.
+const inharmonicityPartials = 16
+
+// EstimateInharmonicity returns the string stiffness coefficient B of a
+// note with nominal fundamental f0, fitted to the partial frequencies of x
+// under f_k = k*f1*sqrt(1 + B*k^2). The regression of (f_k/k)^2 on k^2 has
+// intercept f1^2 and slope f1^2*B. ok is false when fewer than three
+// partials are found; B is clamped to >= 0.
+func EstimateInharmonicity(x []float64, sampleRate int, f0 float64) (b float64, ok bool) {
+	partials := ExtractPartials(x, sampleRate, f0, inharmonicityPartials)
+	if len(partials) < 3 {
+		return 0, false
+	}
+	var sx, sy, sxx, sxy float64
+	for _, p := range partials {
+		k := float64(p.Index)
+		v := p.FreqHz / k
+		sx += k * k
+		sy += v * v
+		sxx += k * k * k * k
+		sxy += k * k * v * v
+	}
+	n := float64(len(partials))
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0, false
+	}
+	slope := (n*sxy - sx*sy) / den
+	intercept := (sy - slope*sx) / n
+	if intercept <= 0 {
+		return 0, false
+	}
+	return math.Max(0, slope/intercept), true
+}
+
+// decayT60 converts a decay slope (dB/s, negative when decaying) to the time
+// to fall 60 dB, capped at partialMaxT60.
+func decayT60(slopeDBPerS float64) float64 {
+	if slopeDBPerS >= -60/partialMaxT60 {
+		return partialMaxT60
+	}
+	return -60 / slopeDBPerS
+}
+
+// partialDecaySlope fits a line to a partial's level track from its peak
+// down to partialDecayRange dB below it.
+func partialDecaySlope(track []float64, hopSec float64) float64 {
+	if len(track) < 3 {
+		return 0
+	}
+	peakIdx := 0
+	for i, v := range track {
+		if v > track[peakIdx] {
+			peakIdx = i
+		}
+	}
+	end := len(track)
+	for i := peakIdx; i < len(track); i++ {
+		if track[i] < track[peakIdx]-partialDecayRange {
+			end = i
+			break
+		}
+	}
+	if end-peakIdx < 2 {
+		return 0
+	}
+	var sx, sy, sxx, sxy float64
+	n := float64(end - peakIdx)
+	for i := peakIdx; i < end; i++ {
+		t := float64(i) * hopSec
+		sx += t
+		sy += track[i]
+		sxx += t * t
+		sxy += t * track[i]
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
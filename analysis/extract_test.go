@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExtractPartialsTracksInharmonicDecayingPartials(t *testing.T) {
+	sr := 48000
+	f0 := 130.81
+	b := 0.0004
+	n := int(2.5 * float64(sr))
+	x := make([]float64, n)
+	type spec struct{ amp, decay float64 }
+	specs := []spec{{0.5, 6}, {0.25, 10}, {0.125, 15}, {0.0625, 20}}
+	freq := func(k int) float64 { return float64(k) * f0 * math.Sqrt(1+b*float64(k*k)) }
+	for i := range x {
+		tt := float64(i) / float64(sr)
+		for k, s := range specs {
+			x[i] += s.amp * math.Pow(10, -s.decay*tt/20) * math.Sin(2*math.Pi*freq(k+1)*tt)
+		}
+	}
+
+	got := ExtractPartials(x, sr, f0, 4)
+	if len(got) != 4 {
+		t.Fatalf("found %d partials, want 4", len(got))
+	}
+	for i, p := range got {
+		k := i + 1
+		if p.Index != k {
+			t.Fatalf("partial %d has index %d", i, p.Index)
+		}
+		if math.Abs(p.FreqHz-freq(k)) > 1.0 {
+			t.Fatalf("partial %d freq %.2f Hz, want %.2f", k, p.FreqHz, freq(k))
+		}
+		wantDB := 20*math.Log10(specs[i].amp) - specs[i].decay*partialSkipSec
+		if math.Abs(p.AmplitudeDB-wantDB) > 1.5 {
+			t.Fatalf("partial %d amplitude %.2f dB, want %.2f", k, p.AmplitudeDB, wantDB)
+		}
+		if math.Abs(p.DecayDBPerS+specs[i].decay) > 1.5 {
+			t.Fatalf("partial %d decay %.2f dB/s, want %.2f", k, p.DecayDBPerS, -specs[i].decay)
+		}
+	}
+}
+
+func TestEstimateInharmonicityRecoversB(t *testing.T) {
+	sr := 48000
+	for _, tc := range []struct {
+		f0, b float64
+	}{{65.41, 0.0002}, {261.63, 0.0008}, {261.63, 0}} {
+		x := make([]float64, 2*sr)
+		for i := range x {
+			tt := float64(i) / float64(sr)
+			for k := 1; k <= 10; k++ {
+				kf := float64(k)
+				f := kf * tc.f0 * math.Sqrt(1+tc.b*kf*kf)
+				x[i] += 0.4 / kf * math.Exp(-tt*kf/2) * math.Sin(2*math.Pi*f*tt)
+			}
+		}
+		got, ok := EstimateInharmonicity(x, sr, tc.f0)
+		if !ok {
+			t.Fatalf("f0 %.2f: no estimate", tc.f0)
+		}
+		if math.Abs(got-tc.b) > 0.1*tc.b+2e-5 {
+			t.Fatalf("f0 %.2f: B = %.6f, want %.6f", tc.f0, got, tc.b)
+		}
+	}
+	if _, ok := EstimateInharmonicity(make([]float64, sr), sr, 261.63); ok {
+		t.Fatal("silence must not yield an estimate")
+	}
+}
+
+func TestExtractEnvelopeMatchesLevel(t *testing.T) {
+	sr := 48000
+	x := make([]float64, sr/2)
+	for i := range x {
+		x[i] = 0.5 * math.Sin(2*math.Pi*1000*float64(i)/float64(sr))
+	}
+	env := ExtractEnvelope(x, sr)
+	if env.HopSec != 128.0/48000 || len(env.LevelsDB) == 0 {
+		t.Fatalf("unexpected envelope framing: hop=%v frames=%d", env.HopSec, len(env.LevelsDB))
+	}
+	want := 20 * math.Log10(0.5/math.Sqrt2)
+	for i, v := range env.LevelsDB {
+		if math.Abs(v-want) > 0.2 {
+			t.Fatalf("frame %d level %.2f dB, want %.2f", i, v, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+const (
+	// NormF0 scales the RMS fundamental deviation (cents) to [0,1].
+	NormF0 = 50.0
+
+	f0SearchCents  = 300.0 // fundamental search half-width around CompareOptions.F0Hz
+	f0BinFraction  = 0.02  // frequency resolution as a fraction of f0
+	f0MinWindow    = 4096
+	f0MaxWindow    = 65536
+	f0MaxDuration  = 4.0 // seconds tracked
+	f0PeakOverMean = 4.0 // a fundamental must stand this far above the frame mean
+)
+
+type f0Result struct {
+	times      []float64 // seconds, frame centers with a fundamental in both signals
+	track      []float64 // cents, candidate vs reference
+	rmsCents   float64
+	meanCents  float64
+	referenceF float64
+	ok         bool
+}
+
+// measureF0 tracks the fundamental of the aligned reference and candidate
+// near f0 and returns their deviation in cents over time.
+func measureF0(ref []float64, cand []float64, sampleRate int, f0 float64) f0Result {
+	if f0 <= 0 || sampleRate <= 0 || f0 >= 0.5*float64(sampleRate) {
+		return f0Result{}
+	}
+	n := f0MinWindow
+	for n < f0MaxWindow && float64(sampleRate)/float64(n) > f0BinFraction*f0 {
+		n <<= 1
+	}
+	length := min(len(ref), len(cand), int(f0MaxDuration*float64(sampleRate)))
+	for n > f0MinWindow && n > length {
+		n >>= 1
+	}
+	if length < n {
+		return f0Result{}
+	}
+	plan, err := algofft.NewPlanReal64(n)
+	if err != nil {
+		return f0Result{}
+	}
+
+	hop := n / 4
+	refTrack := trackFundamental(plan, ref[:length], sampleRate, n, hop, f0)
+	candTrack := trackFundamental(plan, cand[:length], sampleRate, n, hop, f0)
+
+	var res f0Result
+	var sum, sumSq, refSum float64
+	for i := range refTrack {
+		if math.IsNaN(refTrack[i]) || math.IsNaN(candTrack[i]) {
+			continue
+		}
+		c := 1200 * math.Log2(candTrack[i]/refTrack[i])
+		res.times = append(res.times, float64(i*hop+n/2)/float64(sampleRate))
+		res.track = append(res.track, c)
+		sum += c
+		sumSq += c * c
+		refSum += refTrack[i]
+	}
+	frames := float64(len(res.track))
+	if frames == 0 {
+		return f0Result{}
+	}
+	res.meanCents = sum / frames
+	res.rmsCents = math.Sqrt(sumSq / frames)
+	res.referenceF = refSum / frames
+	res.ok = true
+	return res
+}
+
+// trackFundamental returns the interpolated fundamental frequency of each
+// Hann frame of x, searched within f0SearchCents of f0 (NaN when no clear
+// peak stands out).
+func trackFundamental(plan *algofft.PlanRealT[float64, complex128], x []float64, sampleRate int, n int, hop int, f0 float64) []float64 {
+	binHz := float64(sampleRate) / float64(n)
+	lo := max(1, int(f0*math.Pow(2, -f0SearchCents/1200)/binHz))
+	hi := min(n/2-1, int(math.Ceil(f0*math.Pow(2, f0SearchCents/1200)/binHz)))
+
+	win := hannWindow(n)
+	in := make([]float64, n)
+	spec := make([]complex128, n/2+1)
+	mag := make([]float64, n/2+1)
+	var out []float64
+	for start := 0; start+n <= len(x); start += hop {
+		for i := range in {
+			in[i] = x[start+i] * win[i]
+		}
+		if err := plan.Forward(spec, in); err != nil {
+			return out
+		}
+		var mean float64
+		for i, v := range spec {
+			mag[i] = cmplx.Abs(v)
+			mean += mag[i]
+		}
+		mean /= float64(len(mag))
+
+		peak := lo
+		for i := lo + 1; i <= hi; i++ {
+			if mag[i] > mag[peak] {
+				peak = i
+			}
+		}
+		if peak == lo || peak == hi || mag[peak] < f0PeakOverMean*mean {
+			out = append(out, math.NaN())
+			continue
+		}
+		a, b, c := linToDB(mag[peak-1]), linToDB(mag[peak]), linToDB(mag[peak+1])
+		delta := 0.0
+		if den := a - 2*b + c; den != 0 {
+			delta = 0.5 * (a - c) / den
+		}
+		out = append(out, (float64(peak)+delta)*binHz)
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareF0TracksCentsDeviation(t *testing.T) {
+	sr := 48000
+	f0 := 261.63
+	ref := makeDecaySine(sr, f0, 2.0, 1.0)
+	cand := makeDecaySine(sr, f0*math.Pow(2, 10.0/1200), 2.0, 1.0)
+
+	m := CompareWithOptions(ref, cand, sr, CompareOptions{F0Hz: f0})
+	if !m.F0Detected || len(m.F0TrackCents) == 0 || len(m.F0TrackCents) != len(m.F0TrackTimesSec) {
+		t.Fatalf("expected a fundamental track, got %+v", m)
+	}
+	if math.Abs(m.F0MeanOffsetCents-10) > 1 || math.Abs(m.F0DeviationCents-10) > 1 {
+		t.Fatalf("expected +10 cents, got mean %.2f rms %.2f", m.F0MeanOffsetCents, m.F0DeviationCents)
+	}
+	if math.Abs(m.F0ReferenceHz-f0) > 0.5 {
+		t.Fatalf("reference fundamental %.2f Hz, want %.2f", m.F0ReferenceHz, f0)
+	}
+
+	weighted := CompareWithOptions(ref, cand, sr, CompareOptions{F0Hz: f0, F0Weight: 0.5})
+	if want := 0.5*m.Score + 0.5*m.F0Norm; math.Abs(weighted.Score-want) > 1e-12 {
+		t.Fatalf("F0Weight must blend the cents error into the score: got %f want %f", weighted.Score, want)
+	}
+	if same := CompareWithOptions(ref, ref, sr, CompareOptions{F0Hz: f0}); same.F0DeviationCents > 0.01 {
+		t.Fatalf("identical signals should have no f0 deviation, got %.4f cents", same.F0DeviationCents)
+	}
+}
+
+func TestCompareF0SkippedWithoutNominalFrequency(t *testing.T) {
+	sr := 48000
+	x := makeDecaySine(sr, 440, 1.0, 0.5)
+	if m := Compare(x, x, sr); m.F0Detected || m.F0TrackCents != nil {
+		t.Fatalf("f0 must only be tracked with F0Hz set: %+v", m)
+	}
+}
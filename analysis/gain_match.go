@@ -0,0 +1,120 @@
+package analysis
+
+import "math"
+
+const (
+	defaultGainWindowSec  = 0.1
+	defaultGainSmoothness = 10.0
+	gainMatchMaxDB        = 24.0
+	gainMatchFloor        = 1e-5
+)
+
+// matchGainTrack scales cand by a smooth per-window gain that follows the
+// reference RMS trajectory. The track minimizes
+//
+//	sum_i w_i (g_i - d_i)^2 + lambda * sum_i (g_{i+1} - g_i)^2
+//
+// where d_i is the per-window level difference in dB and w_i masks windows
+// where either signal is silent. It returns the matched candidate, the track
+// in dB and the track hop in seconds.
+func matchGainTrack(ref []float64, cand []float64, sampleRate int, opts CompareOptions) ([]float64, []float64, float64) {
+	windowSec := opts.GainWindowSec
+	if windowSec <= 0 {
+		windowSec = defaultGainWindowSec
+	}
+	lambda := opts.GainSmoothness
+	if lambda <= 0 {
+		lambda = defaultGainSmoothness
+	}
+	n := len(ref)
+	if len(cand) < n {
+		n = len(cand)
+	}
+	hop := int(math.Round(windowSec * float64(sampleRate)))
+	if hop < 1 || n == 0 {
+		return cand, nil, 0
+	}
+	windows := (n + hop - 1) / hop
+
+	target := make([]float64, windows)
+	weight := make([]float64, windows)
+	for w := 0; w < windows; w++ {
+		start := w * hop
+		end := start + hop
+		if end > n {
+			end = n
+		}
+		r := rms1(ref[start:end])
+		c := rms1(cand[start:end])
+		if r > gainMatchFloor && c > gainMatchFloor {
+			target[w] = 20 * math.Log10(r/c)
+			weight[w] = 1
+		}
+	}
+	track := solveSmoothTrack(target, weight, lambda)
+	for i := range track {
+		track[i] = math.Max(-gainMatchMaxDB, math.Min(gainMatchMaxDB, track[i]))
+	}
+
+	out := make([]float64, len(cand))
+	copy(out, cand)
+	half := 0.5 * float64(hop)
+	for i := 0; i < n; i++ {
+		// Interpolate between window centers.
+		pos := (float64(i) - half) / float64(hop)
+		j := int(math.Floor(pos))
+		t := pos - float64(j)
+		var g float64
+		switch {
+		case j < 0:
+			g = track[0]
+		case j >= windows-1:
+			g = track[windows-1]
+		default:
+			g = track[j] + t*(track[j+1]-track[j])
+		}
+		out[i] *= math.Pow(10, g/20)
+	}
+	return out, track, float64(hop) / float64(sampleRate)
+}
+
+// solveSmoothTrack solves the tridiagonal system (W + lambda*D'D) g = W d
+// with the Thomas algorithm.
+func solveSmoothTrack(d []float64, w []float64, lambda float64) []float64 {
+	n := len(d)
+	g := make([]float64, n)
+	if n == 0 {
+		return g
+	}
+	diag := make([]float64, n)
+	rhs := make([]float64, n)
+	for i := 0; i < n; i++ {
+		deg := 2.0
+		if i == 0 || i == n-1 {
+			deg = 1
+		}
+		if n == 1 {
+			deg = 0
+		}
+		diag[i] = w[i] + lambda*deg + 1e-9
+		rhs[i] = w[i] * d[i]
+	}
+	off := -lambda
+	// Forward sweep.
+	cp := make([]float64, n)
+	for i := 0; i < n; i++ {
+		den := diag[i]
+		if i > 0 {
+			den -= off * cp[i-1]
+			rhs[i] -= off * rhs[i-1]
+		}
+		cp[i] = off / den
+		rhs[i] /= den
+	}
+	// Back substitution.
+	g[n-1] = rhs[n-1]
+	for i := n - 2; i >= 0; i-- {
+		g[i] = rhs[i] - cp[i]*g[i+1]
+	}
+	return g
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareGainMatchIgnoresLevelTrajectory(t *testing.T) {
+	sr := 48000
+	ref := makeDecayChord(sr, 0, 2.0)
+	noise := randomSignal(len(ref), 7)
+	for i := range ref {
+		ref[i] += 1e-3 * noise[i]
+	}
+	cand := make([]float64, len(ref))
+	for i, v := range ref {
+		// Slow 6 dB level ramp across the note.
+		gDB := -6.0 * float64(i) / float64(len(ref))
+		cand[i] = v * math.Pow(10, gDB/20)
+	}
+
+	plain := Compare(ref, cand, sr)
+	matched := CompareWithOptions(ref, cand, sr, CompareOptions{GainMatch: true})
+	if matched.Score >= plain.Score {
+		t.Fatalf("expected gain matching to lower score: plain=%.4f matched=%.4f", plain.Score, matched.Score)
+	}
+	if len(plain.GainTrackDB) != 0 {
+		t.Fatalf("plain Compare should not report a gain track")
+	}
+	if len(matched.GainTrackDB) < 10 || matched.GainTrackHopSec != 0.1 {
+		t.Fatalf("unexpected gain track: %d windows @ %.3f s", len(matched.GainTrackDB), matched.GainTrackHopSec)
+	}
+	first := matched.GainTrackDB[1]
+	last := matched.GainTrackDB[len(matched.GainTrackDB)-2]
+	if first > 1.5 || last < 4.0 {
+		t.Fatalf("gain track should follow the 0..6 dB ramp: first=%.2f last=%.2f", first, last)
+	}
+}
+
+func TestSolveSmoothTrackSmoothnessFlattensTrack(t *testing.T) {
+	d := []float64{0, 6, 0, 6, 0, 6}
+	w := []float64{1, 1, 1, 1, 1, 1}
+	loose := solveSmoothTrack(d, w, 0.01)
+	stiff := solveSmoothTrack(d, w, 100)
+	spread := func(g []float64) float64 {
+		lo, hi := g[0], g[0]
+		for _, v := range g {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		return hi - lo
+	}
+	if spread(loose) < 5 {
+		t.Fatalf("low smoothness should follow targets, spread=%.2f", spread(loose))
+	}
+	if spread(stiff) > 0.5 {
+		t.Fatalf("high smoothness should flatten track, spread=%.2f", spread(stiff))
+	}
+	for _, v := range stiff {
+		if math.Abs(v-3) > 0.5 {
+			t.Fatalf("stiff track should settle near mean 3 dB, got %.2f", v)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package analysis
+
+import "math"
+
+const (
+	loudnessBlockSec    = 0.4
+	loudnessStepSec     = 0.1
+	loudnessAbsGateLUFS = -70.0
+	loudnessRelGateLU   = -10.0
+)
+
+// IntegratedLoudness returns the gated integrated loudness of the channels
+// in LUFS per ITU-R BS.1770-4 (K-weighting, 400 ms blocks with 75% overlap,
+// absolute and relative gates), weighting every channel as a front channel.
+// Signals shorter than one block are measured as a single block. Silence
+// returns -Inf.
+func IntegratedLoudness(channels [][]float64, sampleRate int) float64 {
+	if sampleRate <= 0 || len(channels) == 0 {
+		return math.Inf(-1)
+	}
+	n := len(channels[0])
+	for _, ch := range channels[1:] {
+		if len(ch) < n {
+			n = len(ch)
+		}
+	}
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	// Running sums of the squared K-weighted signal, summed over channels.
+	cum := make([]float64, n+1)
+	for _, ch := range channels {
+		w := kWeighting(sampleRate)
+		for i := 0; i < n; i++ {
+			y := w.process(ch[i])
+			cum[i+1] += y * y
+		}
+	}
+	for i := 1; i <= n; i++ {
+		cum[i] += cum[i-1]
+	}
+
+	block := int(loudnessBlockSec * float64(sampleRate))
+	step := int(loudnessStepSec * float64(sampleRate))
+	if block > n {
+		block = n
+	}
+	var powers []float64
+	for start := 0; start+block <= n; start += step {
+		powers = append(powers, (cum[start+block]-cum[start])/float64(block))
+	}
+
+	gated := func(threshold float64) (float64, int) {
+		var sum float64
+		count := 0
+		for _, p := range powers {
+			if powerToLUFS(p) > threshold {
+				sum += p
+				count++
+			}
+		}
+		return sum, count
+	}
+	sum, count := gated(loudnessAbsGateLUFS)
+	if count == 0 {
+		return math.Inf(-1)
+	}
+	sum, count = gated(powerToLUFS(sum/float64(count)) + loudnessRelGateLU)
+	if count == 0 {
+		return math.Inf(-1)
+	}
+	return powerToLUFS(sum / float64(count))
+}
+
+// IntegratedLoudnessInterleaved is IntegratedLoudness for interleaved
+// float32 samples with numChannels channels.
+func IntegratedLoudnessInterleaved(samples []float32, numChannels int, sampleRate int) float64 {
+	if numChannels <= 0 {
+		return math.Inf(-1)
+	}
+	frames := len(samples) / numChannels
+	channels := make([][]float64, numChannels)
+	for c := range channels {
+		channels[c] = make([]float64, frames)
+		for i := 0; i < frames; i++ {
+			channels[c][i] = float64(samples[i*numChannels+c])
+		}
+	}
+	return IntegratedLoudness(channels, sampleRate)
+}
+
+func powerToLUFS(p float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(p)
+}
+
+// biquad64 is a direct form I biquad with a0-normalized coefficients.
+type biquad64 struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad64) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeightingFilter is the BS.1770 pre-filter: a high shelf modeling the head
+// followed by the RLB high-pass.
+type kWeightingFilter struct {
+	shelf    biquad64
+	highpass biquad64
+}
+
+func (k *kWeightingFilter) process(x float64) float64 {
+	return k.highpass.process(k.shelf.process(x))
+}
+
+// kWeighting designs the K-weighting stages for sampleRate from their analog
+// prototypes (bilinear transform); at 48 kHz this reproduces the BS.1770
+// coefficient table.
+func kWeighting(sampleRate int) *kWeightingFilter {
+	fs := float64(sampleRate)
+
+	const shelfGainDB = 3.999843853973347
+	const shelfQ = 0.7071752369554196
+	const shelfHz = 1681.974450955533
+	k := math.Tan(math.Pi * shelfHz / fs)
+	vh := math.Pow(10, shelfGainDB/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf := biquad64{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	const highpassHz = 38.13547087602444
+	const highpassQ = 0.5003270373238773
+	k = math.Tan(math.Pi * highpassHz / fs)
+	a0 = 1 + k/highpassQ + k*k
+	highpass := biquad64{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/highpassQ + k*k) / a0,
+	}
+	return &kWeightingFilter{shelf: shelf, highpass: highpass}
+}
@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(n int, sampleRate int, freq float64, amp float64) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+	}
+	return x
+}
+
+func TestIntegratedLoudnessFullScaleSineReference(t *testing.T) {
+	// BS.1770: a 0 dBFS 1 kHz sine in one front channel reads -3.01 LKFS.
+	for _, sr := range []int{44100, 48000, 96000} {
+		x := sine(2*sr, sr, 1000, 1)
+		if got := IntegratedLoudness([][]float64{x}, sr); math.Abs(got+3.01) > 0.05 {
+			t.Fatalf("sr=%d: mono 0 dBFS sine = %.3f LUFS, want -3.01", sr, got)
+		}
+		stereo := IntegratedLoudness([][]float64{x, x}, sr)
+		if math.Abs(stereo) > 0.05 {
+			t.Fatalf("sr=%d: stereo 0 dBFS sine = %.3f LUFS, want 0", sr, stereo)
+		}
+	}
+}
+
+func TestIntegratedLoudnessGatesSilenceAndQuietTails(t *testing.T) {
+	const sr = 48000
+	loud := sine(4*sr, sr, 1000, 0.5)
+	want := IntegratedLoudness([][]float64{loud}, sr)
+
+	withSilence := append(append([]float64{}, loud...), make([]float64, 3*sr)...)
+	if got := IntegratedLoudness([][]float64{withSilence}, sr); math.Abs(got-want) > 0.2 {
+		t.Fatalf("silence not gated: %.3f LUFS, want %.3f", got, want)
+	}
+
+	// A tail 30 dB down is below the relative gate.
+	withTail := append(append([]float64{}, loud...), sine(3*sr, sr, 1000, 0.5*math.Pow(10, -30.0/20))...)
+	if got := IntegratedLoudness([][]float64{withTail}, sr); math.Abs(got-want) > 0.3 {
+		t.Fatalf("quiet tail not gated: %.3f LUFS, want %.3f", got, want)
+	}
+
+	if got := IntegratedLoudness([][]float64{make([]float64, sr)}, sr); !math.IsInf(got, -1) {
+		t.Fatalf("silence = %.3f LUFS, want -Inf", got)
+	}
+}
+
+func TestIntegratedLoudnessInterleavedMatchesChannels(t *testing.T) {
+	const sr = 48000
+	l := sine(sr/10, sr, 440, 0.3) // shorter than one gating block
+	r := sine(sr/10, sr, 660, 0.2)
+	inter := make([]float32, 2*len(l))
+	for i := range l {
+		inter[2*i] = float32(l[i])
+		inter[2*i+1] = float32(r[i])
+	}
+	want := IntegratedLoudness([][]float64{l, r}, sr)
+	got := IntegratedLoudnessInterleaved(inter, 2, sr)
+	if math.IsInf(want, -1) || math.Abs(got-want) > 1e-3 {
+		t.Fatalf("interleaved = %.4f LUFS, channels = %.4f", got, want)
+	}
+}
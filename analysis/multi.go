@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// CompareMulti compares candidate against several references of the same
+// note (e.g. takes from different sessions or microphones). Each reference
+// is scored with CompareWithOptions and the combined Score is the median of
+// the per-reference scores, so one take's room or microphone quirks cannot
+// dominate a fit. The component metrics are those of the median-scoring
+// reference; ReferenceScores lists every take's score in input order.
+func CompareMulti(references [][]float64, candidate []float64, sampleRate int, opts CompareOptions) Metrics {
+	if len(references) == 0 {
+		return CompareWithOptions(nil, candidate, sampleRate, opts)
+	}
+	if len(references) == 1 {
+		return CompareWithOptions(references[0], candidate, sampleRate, opts)
+	}
+
+	all := make([]Metrics, len(references))
+	scores := make([]float64, len(references))
+	for i, ref := range references {
+		all[i] = CompareWithOptions(ref, candidate, sampleRate, opts)
+		scores[i] = all[i].Score
+	}
+	order := make([]int, len(all))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+
+	m := all[order[(len(order)-1)/2]]
+	m.Score = median(scores)
+	m.Similarity = clamp01(math.Exp(-4.0 * m.Score))
+	m.ReferenceScores = scores
+	if opts.Validation {
+		validation := make([]float64, len(all))
+		for i := range all {
+			validation[i] = *all[i].ValidationScore
+		}
+		m.setValidation(*m.ValidationSpectralRMSEDB, median(validation))
+	}
+	return m
+}
+
+func median(x []float64) float64 {
+	s := append([]float64(nil), x...)
+	sort.Float64s(s)
+	mid := len(s) / 2
+	if len(s)%2 == 0 {
+		return 0.5 * (s[mid-1] + s[mid])
+	}
+	return s[mid]
+}
@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareMultiUsesMedianScore(t *testing.T) {
+	sr := 16000
+	cand := makeDecaySine(sr, 440, 1.5, 0.7)
+	close1 := makeDecaySine(sr, 440, 1.5, 0.65)
+	close2 := makeDecaySine(sr, 440, 1.5, 0.75)
+	outlier := makeDecaySine(sr, 330, 1.5, 0.2)
+
+	refs := [][]float64{close1, outlier, close2}
+	m := CompareMulti(refs, cand, sr, CompareOptions{})
+	if len(m.ReferenceScores) != 3 {
+		t.Fatalf("expected 3 reference scores, got %v", m.ReferenceScores)
+	}
+	want := median3(m.ReferenceScores)
+	if m.Score != want {
+		t.Fatalf("expected median score %f, got %f (%v)", want, m.Score, m.ReferenceScores)
+	}
+	if outlierScore := m.ReferenceScores[1]; m.Score >= outlierScore {
+		t.Fatalf("outlier take should not set the score: %f >= %f", m.Score, outlierScore)
+	}
+	if math.Abs(m.Similarity-math.Exp(-4*m.Score)) > 1e-12 {
+		t.Fatalf("similarity must follow the combined score, got %f", m.Similarity)
+	}
+}
+
+func TestCompareMultiEvenCountAveragesMiddleScores(t *testing.T) {
+	sr := 16000
+	cand := makeDecaySine(sr, 440, 1.5, 0.7)
+	refs := [][]float64{makeDecaySine(sr, 440, 1.5, 0.5), makeDecaySine(sr, 330, 1.5, 0.2)}
+	m := CompareMulti(refs, cand, sr, CompareOptions{})
+	want := 0.5 * (m.ReferenceScores[0] + m.ReferenceScores[1])
+	if math.Abs(m.Score-want) > 1e-12 {
+		t.Fatalf("expected mean of the two scores %f, got %f", want, m.Score)
+	}
+}
+
+func TestCompareMultiSingleReferenceMatchesCompare(t *testing.T) {
+	sr := 16000
+	ref := makeDecaySine(sr, 440, 1.5, 0.6)
+	cand := makeDecaySine(sr, 440, 1.5, 0.7)
+	m := CompareMulti([][]float64{ref}, cand, sr, CompareOptions{})
+	if want := Compare(ref, cand, sr); m.Score != want.Score || m.ReferenceScores != nil {
+		t.Fatalf("single reference must match Compare: %f vs %f", m.Score, want.Score)
+	}
+}
+
+func median3(x []float64) float64 {
+	a, b, c := x[0], x[1], x[2]
+	return math.Max(math.Min(a, b), math.Min(math.Max(a, b), c))
+}
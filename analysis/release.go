@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// NormRelease scales the combined release-window error (dB) to [0,1].
+	NormRelease = 20.0
+	// NormReleaseFallMs scales the release fall-time difference to [0,1].
+	NormReleaseFallMs = 50.0
+
+	defaultReleaseHalfWindowSec = 0.25
+	releaseSlopeWindowSec       = 0.05
+	releaseSearchDelaySec       = 0.1  // skip the attack when searching for the damper event
+	releaseMinSlopeDBPerS       = -60  // damping must be at least this steep
+	releaseSlopeRatio           = 3.0  // ...and this much steeper than the free decay
+	releaseFloorDB              = 50.0 // ignore events this far below the peak
+	releaseFallDB               = 20.0 // level drop that ends the release fall time
+)
+
+// detectRelease finds the NoteOff/damper event in an RMS envelope as the
+// steepest short-term level drop after the attack. It returns the envelope
+// frame where the drop starts.
+func detectRelease(env []float64, hopSec float64) (int, bool) {
+	if len(env) < 8 || hopSec <= 0 {
+		return 0, false
+	}
+	k := int(math.Round(releaseSlopeWindowSec / hopSec))
+	if k < 2 {
+		k = 2
+	}
+	db := make([]float64, len(env))
+	peak, peakIdx := -math.MaxFloat64, 0
+	for i, v := range env {
+		db[i] = linToDB(v)
+		if db[i] > peak {
+			peak, peakIdx = db[i], i
+		}
+	}
+	start := peakIdx + int(math.Round(releaseSearchDelaySec/hopSec))
+	if start+k >= len(db) {
+		return 0, false
+	}
+
+	slopes := make([]float64, 0, len(db)-k-start)
+	best, bestIdx := 0.0, -1
+	for i := start; i+k < len(db); i++ {
+		if db[i] < peak-releaseFloorDB {
+			break
+		}
+		s := (db[i+k] - db[i]) / (float64(k) * hopSec)
+		slopes = append(slopes, s)
+		if bestIdx < 0 || s < best {
+			best, bestIdx = s, i
+		}
+	}
+	if bestIdx < 0 || best > releaseMinSlopeDBPerS {
+		return 0, false
+	}
+	// The steepest window can sit anywhere on the damped slope; place the
+	// event where the short-term slope first reaches half the steepest drop
+	// (the slope window then straddles the knee).
+	onset := bestIdx - start
+	for i, sl := range slopes {
+		if sl <= 0.5*best {
+			onset = i
+			break
+		}
+	}
+	// The drop must also clearly exceed the free decay before the event.
+	before := append([]float64(nil), slopes[:onset]...)
+	if len(before) > 0 {
+		sort.Float64s(before)
+		if med := before[len(before)/2]; med < 0 && best > releaseSlopeRatio*med {
+			return 0, false
+		}
+	}
+	return start + onset + k/2, true
+}
+
+// releaseResult holds release-window measurements.
+type releaseResult struct {
+	timeSec    float64
+	envRMSEDB  float64
+	residualDB float64
+	fallMs     float64 // reference fall time
+	fallDiffMs float64
+	ok         bool
+}
+
+// releaseFall returns the frames from the release event at center until
+// env has fallen releaseFallDB below its level there, or the frames to hi
+// when it does not fall that far inside the window. It measures how fast
+// the damper stops the strings.
+func releaseFall(env []float64, center int, hi int) int {
+	stop := linToDB(env[center]) - releaseFallDB
+	for i := center; i < hi; i++ {
+		if linToDB(env[i]) <= stop {
+			return i - center
+		}
+	}
+	return hi - center
+}
+
+// measureRelease compares the reference and candidate envelopes in a window
+// centered on the reference damper event. Envelope error is measured after
+// removing the pre-release level offset so it isolates the release
+// transient; the residual term compares how far each signal falls by the
+// end of the window and the fall time how fast it falls.
+func measureRelease(refEnv []float64, candEnv []float64, hopSec float64, halfWindowSec float64) releaseResult {
+	n := len(refEnv)
+	if len(candEnv) < n {
+		n = len(candEnv)
+	}
+	center, ok := detectRelease(refEnv[:n], hopSec)
+	if !ok {
+		return releaseResult{}
+	}
+	if halfWindowSec <= 0 {
+		halfWindowSec = defaultReleaseHalfWindowSec
+	}
+	half := int(math.Round(halfWindowSec / hopSec))
+	lo := center - half
+	if lo < 0 {
+		lo = 0
+	}
+	hi := center + half
+	if hi > n {
+		hi = n
+	}
+	if center-lo < 1 || hi-center < 2 {
+		return releaseResult{}
+	}
+
+	meanDB := func(env []float64, a, b int) float64 {
+		var sum float64
+		for i := a; i < b; i++ {
+			sum += linToDB(env[i])
+		}
+		return sum / float64(b-a)
+	}
+	refPre := meanDB(refEnv, lo, center)
+	candPre := meanDB(candEnv, lo, center)
+	offset := refPre - candPre
+
+	diff := make([]float64, 0, hi-lo)
+	for i := lo; i < hi; i++ {
+		diff = append(diff, linToDB(refEnv[i])-linToDB(candEnv[i])-offset)
+	}
+
+	tail := center + (hi-center)/2
+	refResidual := meanDB(refEnv, tail, hi) - refPre
+	candResidual := meanDB(candEnv, tail, hi) - candPre
+	refFall := releaseFall(refEnv, center, hi)
+	candFall := releaseFall(candEnv, center, hi)
+	return releaseResult{
+		timeSec:    float64(center) * hopSec,
+		envRMSEDB:  rms1(diff),
+		residualDB: math.Abs(refResidual - candResidual),
+		fallMs:     1000 * float64(refFall) * hopSec,
+		fallDiffMs: 1000 * math.Abs(float64(candFall-refFall)) * hopSec,
+		ok:         true,
+	}
+}
@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// makeDampedNote renders a decaying partial stack whose decay switches to
+// dampedDBPerS at releaseSec (dampedDBPerS = 0 keeps the free decay).
+func makeDampedNote(sr int, durationSec float64, releaseSec float64, dampedDBPerS float64) []float64 {
+	n := int(float64(sr) * durationSec)
+	out := make([]float64, n)
+	freqs := []float64{261.63, 523.25, 784.88}
+	for i := range out {
+		t := float64(i) / float64(sr)
+		levelDB := -8.0 * t
+		if dampedDBPerS != 0 && t > releaseSec {
+			levelDB = -8.0*releaseSec - dampedDBPerS*(t-releaseSec)
+		}
+		env := math.Pow(10, levelDB/20)
+		for k, f := range freqs {
+			out[i] += env * math.Sin(2*math.Pi*f*t) / float64(k+1)
+		}
+	}
+	return out
+}
+
+func TestDetectReleaseFindsDamperEvent(t *testing.T) {
+	sr := 48000
+	x := makeDampedNote(sr, 2.0, 1.2, 200)
+	env := rmsEnvelope(x, 256, 128)
+	hopSec := 128.0 / float64(sr)
+	idx, ok := detectRelease(env, hopSec)
+	if !ok {
+		t.Fatalf("release not detected")
+	}
+	if got := float64(idx) * hopSec; math.Abs(got-1.2) > 0.03 {
+		t.Fatalf("release at %.3f s, want ~1.2 s", got)
+	}
+
+	free := rmsEnvelope(makeDampedNote(sr, 2.0, 1.2, 0), 256, 128)
+	if _, ok := detectRelease(free, hopSec); ok {
+		t.Fatalf("free decay must not be detected as a release")
+	}
+}
+
+func TestCompareReleaseWindowPenalizesMissingDamping(t *testing.T) {
+	sr := 48000
+	ref := makeDampedNote(sr, 2.0, 1.2, 200)
+	good := makeDampedNote(sr, 2.0, 1.2, 180)
+	undamped := makeDampedNote(sr, 2.0, 1.2, 20)
+
+	opts := CompareOptions{ReleaseWeight: 0.5}
+	mGood := CompareWithOptions(ref, good, sr, opts)
+	mBad := CompareWithOptions(ref, undamped, sr, opts)
+	if !mGood.ReleaseDetected || !mBad.ReleaseDetected {
+		t.Fatalf("release window not detected")
+	}
+	if math.Abs(mGood.ReleaseTimeSec-1.2) > 0.03 {
+		t.Fatalf("release time %.3f s, want ~1.2 s", mGood.ReleaseTimeSec)
+	}
+	if mBad.ReleaseResidualDiffDB <= mGood.ReleaseResidualDiffDB+10 {
+		t.Fatalf("undamped residual diff %.2f dB should exceed matched %.2f dB", mBad.ReleaseResidualDiffDB, mGood.ReleaseResidualDiffDB)
+	}
+	if mBad.Score <= mGood.Score {
+		t.Fatalf("missing damping should score worse: good=%.4f bad=%.4f", mGood.Score, mBad.Score)
+	}
+
+	// Report-only by default: the release term does not change Score.
+	plain := Compare(ref, undamped, sr)
+	if !plain.ReleaseDetected || plain.Score != CompareWithOptions(ref, undamped, sr, CompareOptions{}).Score {
+		t.Fatalf("default options must report but not weight the release window")
+	}
+}
+
+func TestCompareReleaseIgnoresGainMatch(t *testing.T) {
+	sr := 48000
+	ref := makeDampedNote(sr, 2.0, 1.2, 200)
+	undamped := makeDampedNote(sr, 2.0, 1.2, 20)
+
+	plain := Compare(ref, undamped, sr)
+	matched := CompareWithOptions(ref, undamped, sr, CompareOptions{GainMatch: true})
+	if !plain.ReleaseDetected || !matched.ReleaseDetected {
+		t.Fatalf("release window not detected")
+	}
+	if matched.ReleaseResidualDiffDB != plain.ReleaseResidualDiffDB ||
+		matched.ReleaseEnvelopeRMSEDB != plain.ReleaseEnvelopeRMSEDB ||
+		matched.ReleaseFallDiffMs != plain.ReleaseFallDiffMs {
+		t.Fatalf("gain matching changed the release: residual %.2f/%.2f dB, envelope %.2f/%.2f dB, fall %.1f/%.1f ms",
+			matched.ReleaseResidualDiffDB, plain.ReleaseResidualDiffDB,
+			matched.ReleaseEnvelopeRMSEDB, plain.ReleaseEnvelopeRMSEDB,
+			matched.ReleaseFallDiffMs, plain.ReleaseFallDiffMs)
+	}
+}
+
+func TestCompareReleaseFallTimeTracksDamperSpeed(t *testing.T) {
+	sr := 48000
+	ref := makeDampedNote(sr, 2.0, 1.2, 400)
+	same := makeDampedNote(sr, 2.0, 1.2, 400)
+	slow := makeDampedNote(sr, 2.0, 1.2, 100)
+
+	mSame := Compare(ref, same, sr)
+	mSlow := Compare(ref, slow, sr)
+	if !mSame.ReleaseDetected || !mSlow.ReleaseDetected {
+		t.Fatalf("release window not detected")
+	}
+	// 20 dB at 400 dB/s.
+	if math.Abs(mSame.ReleaseFallMs-50) > 15 {
+		t.Fatalf("reference fall time %.1f ms, want ~50 ms", mSame.ReleaseFallMs)
+	}
+	if mSlow.ReleaseFallDiffMs < mSame.ReleaseFallDiffMs+80 {
+		t.Fatalf("slow damper fall diff %.1f ms should exceed matched %.1f ms", mSlow.ReleaseFallDiffMs, mSame.ReleaseFallDiffMs)
+	}
+	if mSlow.ReleaseNorm <= mSame.ReleaseNorm {
+		t.Fatalf("slow damper should have a larger release error: same=%.4f slow=%.4f", mSame.ReleaseNorm, mSlow.ReleaseNorm)
+	}
+}
@@ -0,0 +1,254 @@
+package analysis
+
+import (
+	"math"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+const (
+	residualWindowSec  = 0.25 // analyzed span after the onset
+	residualOnsetDB    = 50.0 // onset level below the peak
+	residualPartials   = 64
+	residualMaskBins   = 2    // half-width of the bins removed around a partial (the Hann main lobe)
+	residualResolution = 8    // FFT bins between harmonics
+	residualMinHz      = 20.0 // DC and subsonic drift are not residual
+	residualFrameSec   = 0.002
+	residualMinFFT     = 512
+	residualMaxFFT     = 16384
+	residualMaxDecayMs = 2000.0
+	residualFloorDB    = -60.0 // lowest band level relative to the loudest band
+)
+
+// AttackResidual is the non-harmonic part of a note's attack (the hammer
+// knock and other strike noise), left after the partials are removed. Its
+// envelope and octave band levels parameterize the engine's hammer knock.
+type AttackResidual struct {
+	OnsetSec   float64   `json:"onset_sec"`
+	PeakRMS    float64   `json:"peak_rms"` // residual RMS at its envelope peak
+	PeakDB     float64   `json:"peak_db"`
+	AttackMs   float64   `json:"attack_ms"`   // onset to envelope peak
+	DecayMs    float64   `json:"decay_ms"`    // time to fall 60 dB after the peak
+	ResidualDB float64   `json:"residual_db"` // residual energy relative to the note's over the window
+	BandsHz    []float64 `json:"bands_hz"`
+	BandsDB    []float64 `json:"bands_db"` // power density per band relative to the loudest band
+	Residual   []float64 `json:"-"`        // the residual from the onset
+}
+
+// ExtractAttackResidual removes the partials of a note with nominal
+// fundamental f0 from the first residualWindowSec after its onset and
+// measures what is left: the residual's envelope and its power density in
+// octave bands centred on bandsHz. The partials are found with
+// ExtractPartials and their bins zeroed in a Hann STFT long enough to
+// resolve them, then the residual is resynthesized by overlap-add. ok is
+// false when x is silent or too short to analyze.
+func ExtractAttackResidual(x []float64, sampleRate int, f0 float64, bandsHz []float64) (AttackResidual, bool) {
+	if sampleRate <= 0 || f0 <= 0 {
+		return AttackResidual{}, false
+	}
+	sr := float64(sampleRate)
+	peak := 0.0
+	for _, v := range x {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	if peak == 0 {
+		return AttackResidual{}, false
+	}
+	threshold := peak * math.Pow(10, -residualOnsetDB/20)
+	onset := 0
+	for onset < len(x) && math.Abs(x[onset]) < threshold {
+		onset++
+	}
+	seg := x[onset:min(len(x), onset+int(residualWindowSec*sr))]
+	frame := max(int(residualFrameSec*sr), 1)
+	if len(seg) < 8*frame {
+		return AttackResidual{}, false
+	}
+
+	// The STFT resolves partials residualResolution bins apart and runs a
+	// frame past the window so the partials do not end abruptly inside it.
+	n := min(max(nextPow2(int(residualResolution*sr/f0)), residualMinFFT), residualMaxFFT)
+	pr, ok := removePartials(x[onset:min(len(x), onset+len(seg)+n)], sampleRate, n, ExtractPartials(x[onset:], sampleRate, f0, residualPartials))
+	if !ok {
+		return AttackResidual{}, false
+	}
+	residual := pr.out[:len(seg)]
+	out := AttackResidual{OnsetSec: float64(onset) / sr, Residual: residual}
+	if total := sumSquares(seg); total > 0 {
+		out.ResidualDB = 10 * math.Log10(math.Max(sumSquares(residual)/total, 1e-12))
+	}
+
+	// Envelope in residualFrameSec frames: peak, attack and the decay fitted
+	// from the peak down partialDecayRange dB (or to 3 dB above the floor).
+	env := make([]float64, len(residual)/frame)
+	peakFrame := 0
+	floor := math.Inf(1)
+	for i := range env {
+		env[i] = linToDB(rms1(residual[i*frame : (i+1)*frame]))
+		if env[i] > env[peakFrame] {
+			peakFrame = i
+		}
+		floor = math.Min(floor, env[i])
+	}
+	out.PeakDB = env[peakFrame]
+	out.PeakRMS = math.Pow(10, out.PeakDB/20)
+	out.AttackMs = 1000 * (float64(peakFrame) + 0.5) * float64(frame) / sr
+	stop := math.Max(out.PeakDB-partialDecayRange, floor+3)
+	end := peakFrame
+	for end+1 < len(env) && env[end+1] > stop {
+		end++
+	}
+	out.DecayMs = residualMaxDecayMs
+	if slope := partialDecaySlope(env[peakFrame:end+1], float64(frame)/sr); slope < 0 {
+		out.DecayMs = math.Min(-60/slope*1000, residualMaxDecayMs)
+	}
+
+	out.BandsHz = append([]float64(nil), bandsHz...)
+	out.BandsDB = pr.bandLevels(bandsHz)
+	return out, true
+}
+
+// partialResidual is the STFT of a note with the bins of its partials
+// removed.
+type partialResidual struct {
+	out    []float64 // overlap-added residual
+	power  []float64 // mean power per bin over the frames
+	masked []bool    // bins removed around a partial
+	binHz  float64
+}
+
+// removePartials zeroes the bins within residualMaskBins of each partial
+// and those below residualMinHz in an n-point Hann STFT (hop n/4) of x and
+// overlap-adds the rest into a residual the same length as x.
+func removePartials(x []float64, sampleRate int, n int, partials []Partial) (partialResidual, bool) {
+	plan, err := algofft.NewPlanReal64(n)
+	if err != nil {
+		return partialResidual{}, false
+	}
+	r := partialResidual{
+		power:  make([]float64, n/2+1),
+		masked: make([]bool, n/2+1),
+		binHz:  float64(sampleRate) / float64(n),
+	}
+	for b := 0; float64(b)*r.binHz < residualMinHz && b < len(r.masked); b++ {
+		r.masked[b] = true
+	}
+	for _, p := range partials {
+		c := int(math.Round(p.FreqHz / r.binHz))
+		for b := max(c-residualMaskBins, 0); b <= min(c+residualMaskBins, n/2); b++ {
+			r.masked[b] = true
+		}
+	}
+
+	// Pad by a frame on both sides so every sample is covered by the same
+	// number of frames.
+	hop := n / 4
+	padded := make([]float64, len(x)+2*n)
+	copy(padded[n:], x)
+	acc := make([]float64, len(padded))
+	norm := make([]float64, len(padded))
+	win := make([]float64, n)
+	for i := range win {
+		win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	in := make([]float64, n)
+	spec := make([]complex128, n/2+1)
+	frames := 0
+	for start := 0; start+n <= len(padded); start += hop {
+		for i := range in {
+			in[i] = padded[start+i] * win[i]
+		}
+		if err := plan.Forward(spec, in); err != nil {
+			return partialResidual{}, false
+		}
+		for b, masked := range r.masked {
+			if masked {
+				spec[b] = 0
+			}
+			r.power[b] += real(spec[b])*real(spec[b]) + imag(spec[b])*imag(spec[b])
+		}
+		frames++
+		if err := plan.Inverse(in, spec); err != nil {
+			return partialResidual{}, false
+		}
+		for i, v := range in {
+			acc[start+i] += v * win[i]
+			norm[start+i] += win[i] * win[i]
+		}
+	}
+	for b := range r.power {
+		r.power[b] /= float64(max(frames, 1))
+	}
+	r.out = make([]float64, len(x))
+	for i := range r.out {
+		if w := norm[n+i]; w > 0 {
+			r.out[i] = acc[n+i] / w
+		}
+	}
+	return r, true
+}
+
+// bandLevels returns the mean power density of the residual's unmasked
+// bins in octave bands centred on bandsHz, in dB relative to the loudest
+// band and clamped to residualFloorDB. A band whose bins are all masked
+// (dense partials in the bass) takes the level of the nearest band
+// measured; bands reaching past Nyquist are at the floor.
+func (r partialResidual) bandLevels(bandsHz []float64) []float64 {
+	out := make([]float64, len(bandsHz))
+	measured := make([]bool, len(bandsHz))
+	nyquist := float64(len(r.power)-1) * r.binHz
+	loudest := math.Inf(-1)
+	for i, fc := range bandsHz {
+		out[i] = math.Inf(-1)
+		if fc*math.Sqrt2 > nyquist {
+			continue
+		}
+		lo := max(int(math.Ceil(fc/math.Sqrt2/r.binHz)), 1)
+		hi := int(fc * math.Sqrt2 / r.binHz)
+		sum, count := 0.0, 0
+		for b := lo; b <= hi; b++ {
+			if !r.masked[b] {
+				sum += r.power[b]
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		out[i] = 10 * math.Log10(math.Max(sum/float64(count), 1e-300))
+		measured[i] = true
+		loudest = math.Max(loudest, out[i])
+	}
+	for i, fc := range bandsHz {
+		if measured[i] || fc*math.Sqrt2 > nyquist {
+			continue
+		}
+		for d := 1; d < len(bandsHz); d++ {
+			if j := i - d; j >= 0 && measured[j] {
+				out[i] = out[j]
+				break
+			}
+			if j := i + d; j < len(bandsHz) && measured[j] {
+				out[i] = out[j]
+				break
+			}
+		}
+	}
+	for i, v := range out {
+		if math.IsInf(loudest, -1) {
+			// No band measured.
+			out[i] = residualFloorDB
+			continue
+		}
+		out[i] = math.Max(v-loudest, residualFloorDB)
+	}
+	return out
+}
+
+func sumSquares(x []float64) float64 {
+	var s float64
+	for _, v := range x {
+		s += v * v
+	}
+	return s
+}
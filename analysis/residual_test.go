@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRemovePartialsWithoutPartialsKeepsTheSignal(t *testing.T) {
+	x := make([]float64, 5000)
+	for i := range x {
+		tt := float64(i) / 48000
+		x[i] = 0.3*math.Sin(2*math.Pi*500*tt) + 0.2*math.Sin(2*math.Pi*1234*tt) + 0.1*math.Sin(2*math.Pi*7000*tt)
+	}
+	r, ok := removePartials(x, 48000, 1024, nil)
+	if !ok {
+		t.Fatal("removePartials failed")
+	}
+	// Away from the edges, where the signal starts and stops abruptly.
+	for i := 1024; i < len(x)-1024; i++ {
+		if math.Abs(r.out[i]-x[i]) > 1e-4 {
+			t.Fatalf("sample %d: got %g, want %g", i, r.out[i], x[i])
+		}
+	}
+}
+
+func TestExtractAttackResidualSeparatesKnockFromPartials(t *testing.T) {
+	const sr = 48000
+	const f0 = 220.0
+	const lead = 0.05 // silence before the strike
+	n := int(1.0 * sr)
+	x := make([]float64, n)
+	rng := rand.New(rand.NewSource(3))
+	// Knock: white noise through a 500 Hz one-pole low-pass, rising over
+	// 2 ms and falling 60 dB in 40 ms.
+	lp := 0.0
+	a := math.Exp(-2 * math.Pi * 500 / sr)
+	for i := int(lead * sr); i < n; i++ {
+		tt := float64(i)/sr - lead
+		for k := 1; k <= 8; k++ {
+			x[i] += 0.3 / float64(k) * math.Pow(10, -3*tt/20) * math.Sin(2*math.Pi*float64(k)*f0*tt)
+		}
+		env := math.Min(tt/0.002, math.Pow(10, -60*(tt-0.002)/0.040/20))
+		lp = a*lp + (1-a)*(rng.Float64()*2-1)
+		x[i] += 1.5 * env * lp
+	}
+
+	bands := []float64{63, 125, 250, 500, 1000, 2000, 4000, 8000}
+	res, ok := ExtractAttackResidual(x, sr, f0, bands)
+	if !ok {
+		t.Fatal("no residual extracted")
+	}
+	if math.Abs(res.OnsetSec-lead) > 0.002 {
+		t.Fatalf("onset %.4f s, want %.3f", res.OnsetSec, lead)
+	}
+	if res.AttackMs < 1 || res.AttackMs > 8 {
+		t.Fatalf("attack %.2f ms, want ~2", res.AttackMs)
+	}
+	if res.DecayMs < 25 || res.DecayMs > 80 {
+		t.Fatalf("decay %.1f ms, want ~40", res.DecayMs)
+	}
+	// After the knock dies away only the partials are left, and they are
+	// removed.
+	tail := rms1(res.Residual[int(0.15*sr):])
+	if tail > 1e-3 {
+		t.Fatalf("residual RMS %.2g after the knock, want the partials removed", tail)
+	}
+	if res.PeakDB < -40 || res.PeakDB > -10 {
+		t.Fatalf("residual peak %.1f dBFS, want the knock level", res.PeakDB)
+	}
+	if len(res.BandsDB) != len(bands) {
+		t.Fatalf("%d band levels, want %d", len(res.BandsDB), len(bands))
+	}
+	if !(res.BandsDB[2]-res.BandsDB[6] > 10) {
+		t.Fatalf("band levels %v: want the low-passed knock well above 4 kHz at 250 Hz", res.BandsDB)
+	}
+	for i, v := range res.BandsDB {
+		if v > 0 || v < residualFloorDB {
+			t.Fatalf("band %d level %.1f dB outside [%g,0]", i, v, residualFloorDB)
+		}
+	}
+}
+
+func TestExtractAttackResidualRejectsSilence(t *testing.T) {
+	if _, ok := ExtractAttackResidual(make([]float64, 48000), 48000, 220, []float64{1000}); ok {
+		t.Fatal("expected silence to be rejected")
+	}
+}
+
+func TestBandLevelsWithoutMeasuredBandsAreAtTheFloor(t *testing.T) {
+	r := partialResidual{power: make([]float64, 513), masked: make([]bool, 513), binHz: 48000.0 / 1024}
+	for b := range r.masked {
+		r.masked[b] = true
+	}
+	for i, v := range r.bandLevels([]float64{125, 1000, 30000}) {
+		if v != residualFloorDB {
+			t.Fatalf("band %d level %v, want the floor %g", i, v, residualFloorDB)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import "math"
+
+const (
+	// NormT60 scales the RMS per-partial T60 error, in octaves (log2 of the
+	// candidate/reference ratio), to [0,1]: a curve off by a factor of two
+	// throughout scores 1.
+	NormT60 = 1.0
+
+	t60Partials = 12
+)
+
+// PartialT60 is the decay time of one partial in the reference and the
+// candidate.
+type PartialT60 struct {
+	Index      int     `json:"index"`
+	FreqHz     float64 `json:"freq_hz"` // reference partial frequency
+	RefT60Sec  float64 `json:"ref_t60_sec"`
+	CandT60Sec float64 `json:"cand_t60_sec"`
+}
+
+type t60Result struct {
+	curve   []PartialT60
+	rmsLog2 float64
+	ok      bool
+}
+
+// measureT60Curve compares the T60 of the partials near multiples of f0
+// found in both the aligned reference and candidate. The error is the RMS
+// of log2(candidate/reference) so a too-short and a too-long sustain of the
+// same ratio weigh the same.
+func measureT60Curve(ref []float64, cand []float64, sampleRate int, f0 float64) t60Result {
+	if f0 <= 0 || sampleRate <= 0 {
+		return t60Result{}
+	}
+	refPartials := ExtractPartials(ref, sampleRate, f0, t60Partials)
+	if len(refPartials) == 0 {
+		return t60Result{}
+	}
+	candByIndex := make(map[int]Partial, t60Partials)
+	for _, p := range ExtractPartials(cand, sampleRate, f0, t60Partials) {
+		candByIndex[p.Index] = p
+	}
+
+	var res t60Result
+	var sumSq float64
+	for _, rp := range refPartials {
+		cp, ok := candByIndex[rp.Index]
+		if !ok {
+			continue
+		}
+		res.curve = append(res.curve, PartialT60{
+			Index:      rp.Index,
+			FreqHz:     rp.FreqHz,
+			RefT60Sec:  rp.T60Sec,
+			CandT60Sec: cp.T60Sec,
+		})
+		d := math.Log2(cp.T60Sec / rp.T60Sec)
+		sumSq += d * d
+	}
+	if len(res.curve) == 0 {
+		return t60Result{}
+	}
+	res.rmsLog2 = math.Sqrt(sumSq / float64(len(res.curve)))
+	res.ok = true
+	return res
+}
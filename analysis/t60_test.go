@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// makeDecayingPartials sums harmonic partials of f0 with per-partial T60s.
+func makeDecayingPartials(sr int, f0 float64, t60 []float64, durationSec float64) []float64 {
+	out := make([]float64, int(float64(sr)*durationSec))
+	for i := range out {
+		t := float64(i) / float64(sr)
+		for k, d := range t60 {
+			kf := float64(k + 1)
+			out[i] += 0.4 / kf * math.Pow(10, -3*t/d) * math.Sin(2*math.Pi*kf*f0*t)
+		}
+	}
+	return out
+}
+
+func TestCompareT60CurveFindsShortTreble(t *testing.T) {
+	sr := 48000
+	f0 := 196.0
+	refT60 := []float64{8, 6, 4.5, 3.5, 2.8, 2.2}
+	candT60 := []float64{8, 6, 4.5, 1.75, 1.4, 1.1} // upper partials die twice as fast
+	ref := makeDecayingPartials(sr, f0, refT60, 3)
+	cand := makeDecayingPartials(sr, f0, candT60, 3)
+
+	m := CompareWithOptions(ref, cand, sr, CompareOptions{F0Hz: f0})
+	if !m.T60Detected || len(m.T60Curve) != len(refT60) {
+		t.Fatalf("expected a %d-partial T60 curve, got %+v", len(refT60), m.T60Curve)
+	}
+	for i, p := range m.T60Curve {
+		if math.Abs(p.RefT60Sec-refT60[i]) > 0.15*refT60[i] || math.Abs(p.CandT60Sec-candT60[i]) > 0.15*candT60[i] {
+			t.Fatalf("partial %d T60 ref %.2f cand %.2f, want %.2f and %.2f", p.Index, p.RefT60Sec, p.CandT60Sec, refT60[i], candT60[i])
+		}
+	}
+	// Half the partials off by one octave: RMS sqrt(1/2).
+	if math.Abs(m.T60RMSELog2-math.Sqrt(0.5)) > 0.1 {
+		t.Fatalf("T60 RMSE %.3f octaves, want about %.3f", m.T60RMSELog2, math.Sqrt(0.5))
+	}
+
+	weighted := CompareWithOptions(ref, cand, sr, CompareOptions{F0Hz: f0, T60Weight: 0.5})
+	if want := 0.5*m.Score + 0.5*m.T60Norm; math.Abs(weighted.Score-want) > 1e-12 {
+		t.Fatalf("T60Weight must blend the curve error into the score: got %f want %f", weighted.Score, want)
+	}
+	if weighted.Dominant != "t60" {
+		t.Fatalf("dominant = %q, want t60", weighted.Dominant)
+	}
+	if same := CompareWithOptions(ref, ref, sr, CompareOptions{F0Hz: f0}); same.T60RMSELog2 > 0.01 {
+		t.Fatalf("identical signals should share their T60 curve, got %.4f octaves", same.T60RMSELog2)
+	}
+}
+
+func TestDecayT60CapsFlatPartials(t *testing.T) {
+	if got := decayT60(-20); math.Abs(got-3) > 1e-12 {
+		t.Fatalf("decayT60(-20) = %v, want 3", got)
+	}
+	if decayT60(0) != partialMaxT60 || decayT60(0.5) != partialMaxT60 {
+		t.Fatal("non-decaying partials must cap at partialMaxT60")
+	}
+}
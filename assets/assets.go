@@ -0,0 +1,11 @@
+// Package assets embeds the default impulse response and preset, so the
+// engine and the command-line tools work without the repository checkout.
+// Paths inside FS are relative to this directory, e.g. "ir/default_96k.wav".
+package assets
+
+import "embed"
+
+// FS holds the bundled assets.
+//
+//go:embed ir/default_96k.wav presets/default.json
+var FS embed.FS
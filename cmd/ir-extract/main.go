@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/irsynth"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+func main() {
+	cfg := irsynth.DefaultExtractConfig()
+
+	referencePath := flag.String("reference", "reference/c4.wav", "Reference recording WAV path")
+	dryPath := flag.String("dry", "", "Dry proxy WAV path; if empty, render a dry proxy from the preset with all IRs bypassed")
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON path for the rendered dry proxy")
+	note := flag.Int("note", 60, "MIDI note for the rendered dry proxy")
+	velocity := flag.Int("velocity", 100, "MIDI velocity for the rendered dry proxy")
+	releaseAfter := flag.Float64("release-after", 2.0, "Note hold time before NoteOff for the rendered dry proxy")
+	renderDuration := flag.Float64("render-duration", 4.0, "Rendered dry proxy duration in seconds")
+	writeDry := flag.String("write-dry", "", "Optional path to write the rendered dry proxy WAV")
+	output := flag.String("output", "assets/ir/extracted.wav", "Output IR WAV path (mono)")
+	flag.IntVar(&cfg.SampleRate, "sample-rate", cfg.SampleRate, "Analysis and output sample rate in Hz")
+	flag.Float64Var(&cfg.DurationS, "duration", cfg.DurationS, "Extracted IR length in seconds")
+	flag.Float64Var(&cfg.RegularizationDB, "regularization-db", cfg.RegularizationDB, "Deconvolution regularization floor relative to peak dry power (dB, < 0)")
+	flag.Float64Var(&cfg.WindowDecayS, "window-decay", cfg.WindowDecayS, "Exponential window time constant in seconds (0 = off)")
+	flag.Float64Var(&cfg.OnsetThresholdDB, "onset-db", cfg.OnsetThresholdDB, "Leading-silence trim threshold relative to peak (dB, < 0)")
+	flag.Float64Var(&cfg.FadeOutS, "fade-out", cfg.FadeOutS, "Cosine fade-out length at the IR end in seconds")
+	flag.Float64Var(&cfg.NormalizePeak, "normalize", cfg.NormalizePeak, "Peak normalization target")
+	fitcommon.ParseFlags()
+
+	ref, refSR, err := fitcommon.ReadWAVMono(*referencePath)
+	if err != nil {
+		die("failed to read reference: %v", err)
+	}
+	ref, err = fitcommon.ResampleIfNeeded(ref, refSR, cfg.SampleRate)
+	if err != nil {
+		die("failed to resample reference: %v", err)
+	}
+
+	var dry []float64
+	if *dryPath != "" {
+		raw, sr, err := fitcommon.ReadWAVMono(*dryPath)
+		if err != nil {
+			die("failed to read dry proxy: %v", err)
+		}
+		dry, err = fitcommon.ResampleIfNeeded(raw, sr, cfg.SampleRate)
+		if err != nil {
+			die("failed to resample dry proxy: %v", err)
+		}
+	} else {
+		stereo, err := renderDry(*presetPath, *note, *velocity, cfg.SampleRate, *renderDuration, *releaseAfter)
+		if err != nil {
+			die("failed to render dry proxy: %v", err)
+		}
+		if *writeDry != "" {
+			if err := fitcommon.WriteStereoInterleavedWAV(*writeDry, stereo, cfg.SampleRate); err != nil {
+				die("failed to write dry proxy: %v", err)
+			}
+		}
+		dry = fitcommon.StereoToMono64(stereo)
+	}
+
+	ir, err := irsynth.Extract(ref, dry, cfg)
+	if err != nil {
+		die("ir-extract error: %v", err)
+	}
+	if err := fitcommon.WriteMonoWAV(*output, ir, cfg.SampleRate); err != nil {
+		die("wav write error: %v", err)
+	}
+
+	fmt.Printf("Wrote %s\n", *output)
+	fmt.Printf("SampleRate: %d Hz, Duration: %.3f s, Samples: %d\n", cfg.SampleRate, cfg.DurationS, len(ir))
+	fmt.Printf("Reference: %d samples, dry proxy: %d samples\n", len(ref), len(dry))
+}
+
+// renderDry renders the preset with body and room convolution bypassed, so the
+// output is the bare string-bank signal the IR has to be estimated against.
+func renderDry(presetPath string, note int, velocity int, sampleRate int, duration float64, releaseAfter float64) ([]float32, error) {
+	params, err := preset.LoadJSON(presetPath)
+	if err != nil {
+		return nil, err
+	}
+	params.IRWavPath = ""
+	params.BodyIRWavPath = ""
+	params.BodyIRClosedWavPath = ""
+	params.RoomIRWavPath = ""
+	params.BodyDryMix = 1
+	params.BodyIRGain = 1
+	params.RoomWetMix = 0
+	params.OutputEQ = nil
+
+	totalFrames := int(float64(sampleRate) * duration)
+	if totalFrames < 1 {
+		return nil, fmt.Errorf("render duration too small")
+	}
+	releaseAt := int(float64(sampleRate) * releaseAfter)
+
+	p := piano.NewPiano(sampleRate, 16, params)
+	p.NoteOn(note, velocity)
+
+	const blockSize = 128
+	stereo := make([]float32, 0, totalFrames*2)
+	released := false
+	for rendered := 0; rendered < totalFrames; {
+		n := blockSize
+		if rendered+n > totalFrames {
+			n = totalFrames - rendered
+		}
+		if !released && rendered >= releaseAt {
+			p.NoteOff(note)
+			released = true
+		}
+		stereo = append(stereo, p.Process(n)...)
+		rendered += n
+	}
+	return stereo, nil
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,137 @@
+// Command ir-inspect reports the acoustic properties of an IR WAV (RT60 per
+// octave band, early/late energy, stereo correlation, DC offset, clipping)
+// and can clean it up for use in a preset with --fix.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/irsynth"
+)
+
+func main() {
+	fix := irsynth.DefaultFixConfig()
+
+	input := flag.String("input", "", "IR WAV path (required)")
+	jsonOut := flag.Bool("json", false, "Print the report as JSON")
+	doFix := flag.Bool("fix", false, "Trim, fade, de-click and normalize the IR and write it to --output")
+	output := flag.String("output", "", "Output WAV path for --fix")
+	flag.Float64Var(&fix.OnsetThresholdDB, "onset-db", fix.OnsetThresholdDB, "Leading-silence trim threshold relative to peak (dB, < 0)")
+	flag.Float64Var(&fix.PreRollS, "pre-roll", fix.PreRollS, "Time kept ahead of the onset when trimming (s)")
+	flag.Float64Var(&fix.TailThresholdDB, "tail-db", fix.TailThresholdDB, "Tail trim threshold relative to the envelope peak (dB, 0 = off)")
+	keepDC := flag.Bool("keep-dc", false, "Do not remove the DC offset")
+	flag.Float64Var(&fix.FadeInS, "fade-in", fix.FadeInS, "De-click fade-in length (s)")
+	flag.Float64Var(&fix.FadeOutS, "fade-out", fix.FadeOutS, "Cosine fade-out length at the IR end (s)")
+	flag.Float64Var(&fix.NormalizePeak, "normalize", fix.NormalizePeak, "Peak normalization target")
+	fitcommon.ParseFlags()
+
+	if *input == "" {
+		die("--input is required")
+	}
+	if *doFix && *output == "" {
+		die("--fix requires --output")
+	}
+	fix.RemoveDC = !*keepDC
+
+	channels, sampleRate, err := fitcommon.ReadWAVChannels(*input)
+	if err != nil {
+		die("failed to read %s: %v", *input, err)
+	}
+	if len(channels) > 2 {
+		die("%s has %d channels; IRs must be mono or stereo", *input, len(channels))
+	}
+	rep, err := irsynth.Inspect(channels, sampleRate)
+	if err != nil {
+		die("ir-inspect error: %v", err)
+	}
+
+	if !*doFix {
+		emit(*input, rep, *jsonOut)
+		return
+	}
+
+	fixed, err := irsynth.Fix(channels, sampleRate, fix)
+	if err != nil {
+		die("ir-inspect fix error: %v", err)
+	}
+	if len(fixed) == 1 {
+		err = fitcommon.WriteMonoWAV(*output, toFloat32(fixed[0]), sampleRate)
+	} else {
+		err = fitcommon.WriteStereoWAVLR(*output, toFloat32(fixed[0]), toFloat32(fixed[1]), sampleRate)
+	}
+	if err != nil {
+		die("wav write error: %v", err)
+	}
+	after, err := irsynth.Inspect(fixed, sampleRate)
+	if err != nil {
+		die("ir-inspect error: %v", err)
+	}
+	if *jsonOut {
+		writeJSON(map[string]irsynth.Report{"input": rep, "output": after})
+		return
+	}
+	emit(*input, rep, false)
+	fmt.Println()
+	emit(*output, after, false)
+}
+
+func emit(path string, rep irsynth.Report, asJSON bool) {
+	if asJSON {
+		writeJSON(rep)
+		return
+	}
+	fmt.Printf("File: %s\n", path)
+	fmt.Printf("SampleRate: %d Hz, Channels: %d, Duration: %.3f s, Samples: %d\n", rep.SampleRate, rep.Channels, rep.DurationS, rep.Frames)
+	fmt.Printf("Peak: %.2f dBFS, Onset: %.2f ms, Tail level: %.1f dB\n", rep.PeakDBFS, 1000*rep.OnsetS, rep.TailLevelDB)
+	dc := make([]string, len(rep.DCOffset))
+	clipped := make([]string, len(rep.ClippedSamples))
+	for c := range rep.DCOffset {
+		dc[c] = fmt.Sprintf("%+.6f", rep.DCOffset[c])
+		clipped[c] = fmt.Sprintf("%d", rep.ClippedSamples[c])
+	}
+	fmt.Printf("DC offset: %s, Clipped samples: %s\n", strings.Join(dc, " / "), strings.Join(clipped, " / "))
+	if rep.Channels > 1 {
+		fmt.Printf("Stereo correlation: %.3f\n", rep.StereoCorrelation)
+	}
+	fmt.Printf("C50: %.2f dB, C80: %.2f dB\n", rep.C50DB, rep.C80DB)
+	fmt.Printf("RT60: %s\n", formatRT60(rep.RT60S, rep.RT60Method))
+	for _, b := range rep.Bands {
+		fmt.Printf("  %5.0f Hz  %s\n", b.CenterHz, formatRT60(b.RT60S, b.Method))
+	}
+	for _, w := range rep.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+}
+
+func formatRT60(rt60 float64, method string) string {
+	if method == "" {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f s (%s)", rt60, method)
+}
+
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		die("failed to encode JSON: %v", err)
+	}
+}
+
+func toFloat32(x []float64) []float32 {
+	out := make([]float32, len(x))
+	for i, v := range x {
+		out[i] = float32(v)
+	}
+	return out
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"os"
 
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
 	"github.com/cwbudde/algo-piano/irsynth"
 	"github.com/cwbudde/wav"
 	"github.com/go-audio/audio"
@@ -13,6 +14,7 @@ import (
 
 func main() {
 	cfg := irsynth.DefaultConfig()
+	fx := irsynth.DefaultEffectsConfig()
 
 	output := flag.String("output", "assets/ir/synth_96k.wav", "Output WAV path")
 	flag.IntVar(&cfg.SampleRate, "sample-rate", cfg.SampleRate, "Output sample rate")
@@ -27,13 +29,30 @@ func main() {
 	flag.Float64Var(&cfg.LowDecayS, "low-decay", cfg.LowDecayS, "Low-frequency decay time (s)")
 	flag.Float64Var(&cfg.HighDecayS, "high-decay", cfg.HighDecayS, "High-frequency decay time (s)")
 	flag.Float64Var(&cfg.NormalizePeak, "normalize", cfg.NormalizePeak, "Peak normalization target")
-	flag.Parse()
+	flag.BoolVar(&fx.Reverse, "reverse", fx.Reverse, "Reverse the IR (swelling tail)")
+	flag.Float64Var(&fx.Speed, "speed", fx.Speed, "Playback speed of the IR in [0.125,1] (0.5 = half speed)")
+	flag.Float64Var(&fx.FreezeHz, "freeze-hz", fx.FreezeHz, "Freeze the spectrum above this frequency (0 = off)")
+	flag.Float64Var(&fx.FreezeStartS, "freeze-start", fx.FreezeStartS, "Time at which the frozen spectrum is captured (s)")
+	flag.Float64Var(&fx.FreezeLevel, "freeze-level", fx.FreezeLevel, "Level of the frozen layer relative to the capture")
+	flag.Float64Var(&fx.ShimmerSemitones, "shimmer", fx.ShimmerSemitones, "Shimmer pitch shift in semitones (0 = off)")
+	flag.Float64Var(&fx.ShimmerFeedback, "shimmer-feedback", fx.ShimmerFeedback, "Shimmer feedback gain per pass in [0,0.95]")
+	flag.Float64Var(&fx.ShimmerDelayS, "shimmer-delay", fx.ShimmerDelayS, "Delay between shimmer passes (s)")
+	fitcommon.ParseFlags()
 
 	left, right, err := irsynth.GenerateStereo(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ir-synth error: %v\n", err)
 		os.Exit(1)
 	}
+	if fx.Enabled() {
+		fx.Seed = cfg.Seed
+		fx.NormalizePeak = cfg.NormalizePeak
+		left, right, err = irsynth.ApplyEffects(left, right, cfg.SampleRate, fx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ir-synth effects error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	if err := writeStereoWAV(*output, left, right, cfg.SampleRate); err != nil {
 		fmt.Fprintf(os.Stderr, "wav write error: %v\n", err)
@@ -42,7 +61,7 @@ func main() {
 
 	peak, rms := stats(left, right)
 	fmt.Printf("Wrote %s\n", *output)
-	fmt.Printf("SampleRate: %d Hz, Duration: %.3f s, Samples: %d\n", cfg.SampleRate, cfg.DurationS, len(left))
+	fmt.Printf("SampleRate: %d Hz, Duration: %.3f s, Samples: %d\n", cfg.SampleRate, float64(len(left))/float64(cfg.SampleRate), len(left))
 	fmt.Printf("Peak: %.6f, RMS: %.6f\n", peak, rms)
 }
 
@@ -0,0 +1,72 @@
+/*
+ * algopiano.h - C API of libalgopiano (go build -buildmode=c-shared).
+ *
+ * Engines are addressed by opaque integer handles (> 0). All functions are
+ * safe to call from multiple threads; calls on one handle are serialized.
+ * Functions returning int32_t report ALGOPIANO_OK (0) or a negative error
+ * code; algopiano_last_error() describes the most recent failure.
+ *
+ * This header is the stable interface. Additions bump
+ * ALGOPIANO_API_VERSION; existing declarations do not change.
+ */
+#ifndef ALGOPIANO_H
+#define ALGOPIANO_H
+
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+#define ALGOPIANO_API_VERSION 2
+
+#define ALGOPIANO_OK 0
+#define ALGOPIANO_ERR_HANDLE -1 /* unknown or destroyed handle */
+#define ALGOPIANO_ERR_ARG -2    /* invalid argument */
+#define ALGOPIANO_ERR_PARAM -3  /* unknown parameter name */
+#define ALGOPIANO_ERR_LOAD -4   /* preset could not be loaded */
+
+typedef int32_t algopiano_handle;
+
+/* Version of this header the library was built from. */
+int32_t algopiano_api_version(void);
+
+/* Lifecycle. preset_path may be NULL for default parameters. Returns a
+ * handle > 0, or a negative error code. */
+algopiano_handle algopiano_create(int32_t sample_rate, const char *preset_path);
+int32_t algopiano_destroy(algopiano_handle h);
+
+/* Events. */
+int32_t algopiano_note_on(algopiano_handle h, int32_t note, int32_t velocity);
+int32_t algopiano_note_off(algopiano_handle h, int32_t note);
+int32_t algopiano_key_down(algopiano_handle h, int32_t note);
+int32_t algopiano_set_sustain_pedal(algopiano_handle h, int32_t down);
+int32_t algopiano_set_soft_pedal(algopiano_handle h, int32_t down);
+
+/* Parameters by name (preset JSON key, e.g. "output_gain"). Mixer
+ * parameters apply immediately; others rebuild the string state, which
+ * silences ringing notes. */
+int32_t algopiano_param_count(void);
+/* Name of parameter index in [0, algopiano_param_count()), or NULL. The
+ * string is owned by the library and valid for its lifetime. */
+const char *algopiano_param_name(int32_t index);
+int32_t algopiano_set_param(algopiano_handle h, const char *name, double value);
+int32_t algopiano_get_param(algopiano_handle h, const char *name, double *value);
+
+/* Renders frames of interleaved stereo float samples into out, which must
+ * hold 2*frames floats. Returns frames rendered or a negative error code. */
+int32_t algopiano_process(algopiano_handle h, float *out, int32_t frames);
+
+/* Algorithmic latency of the output in frames, for host delay
+ * compensation, or a negative error code. Since API version 2. */
+int32_t algopiano_latency(algopiano_handle h);
+
+/* Description of the most recent error, or "" if none. Owned by the
+ * library; valid until the next failing call. */
+const char *algopiano_last_error(void);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* ALGOPIANO_H */
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+// Status codes mirrored in algopiano.h.
+const (
+	statusOK        = 0
+	statusErrHandle = -1
+	statusErrArg    = -2
+	statusErrParam  = -3
+	statusErrLoad   = -4
+)
+
+const apiVersion = 2
+
+// statusError carries the C status code of a failed call.
+type statusError struct {
+	code int32
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func errorf(code int32, format string, args ...any) error {
+	return &statusError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// paramDef maps a preset key onto a numeric Params field. Live parameters
+// are read by the engine on every block; changing any other one rebuilds
+// the engine.
+type paramDef struct {
+	name     string
+	min, max float64
+	live     bool
+	field    func(p *piano.Params) *float32
+}
+
+var paramDefs = []paramDef{
+	{"output_gain", 0.001, 10, true, func(p *piano.Params) *float32 { return &p.OutputGain }},
+	{"body_dry_mix", 0, 4, true, func(p *piano.Params) *float32 { return &p.BodyDryMix }},
+	{"body_ir_gain", 0.001, 10, true, func(p *piano.Params) *float32 { return &p.BodyIRGain }},
+	{"room_wet_mix", 0, 4, true, func(p *piano.Params) *float32 { return &p.RoomWetMix }},
+	{"room_gain", 0.001, 10, true, func(p *piano.Params) *float32 { return &p.RoomGain }},
+	{"lid_position", 0, 1, true, func(p *piano.Params) *float32 { return &p.LidPosition }},
+	{"hammer_stiffness_scale", 0.01, 10, false, func(p *piano.Params) *float32 { return &p.HammerStiffnessScale }},
+	{"hammer_exponent_scale", 0.01, 10, false, func(p *piano.Params) *float32 { return &p.HammerExponentScale }},
+	{"hammer_damping_scale", 0.01, 10, false, func(p *piano.Params) *float32 { return &p.HammerDampingScale }},
+	{"hammer_initial_velocity_scale", 0.01, 10, false, func(p *piano.Params) *float32 { return &p.HammerInitialVelocityScale }},
+	{"hammer_contact_time_scale", 0.01, 10, false, func(p *piano.Params) *float32 { return &p.HammerContactTimeScale }},
+	{"high_freq_damping", 0, 0.99, false, func(p *piano.Params) *float32 { return &p.HighFreqDamping }},
+	{"unison_detune_scale", 0, 10, false, func(p *piano.Params) *float32 { return &p.UnisonDetuneScale }},
+	{"unison_crossfeed", 0, 1, false, func(p *piano.Params) *float32 { return &p.UnisonCrossfeed }},
+	{"resonance_gain", 0, 1, false, func(p *piano.Params) *float32 { return &p.ResonanceGain }},
+	{"coupling_amount", 0, 1, true, func(p *piano.Params) *float32 { return &p.CouplingAmount }},
+	{"coupling_octave_gain", 0, 1, false, func(p *piano.Params) *float32 { return &p.CouplingOctaveGain }},
+	{"coupling_fifth_gain", 0, 1, false, func(p *piano.Params) *float32 { return &p.CouplingFifthGain }},
+	{"attack_noise_level", 0, 1, false, func(p *piano.Params) *float32 { return &p.AttackNoiseLevel }},
+	{"variation_amount", 0, 1, false, func(p *piano.Params) *float32 { return &p.VariationAmount }},
+	{"tuning_drift_cents", 0, 50, false, func(p *piano.Params) *float32 { return &p.TuningDriftCents }},
+}
+
+func lookupParam(name string) (paramDef, bool) {
+	for _, d := range paramDefs {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return paramDef{}, false
+}
+
+// engine is one library-owned piano instance.
+type engine struct {
+	mu         sync.Mutex
+	sampleRate int
+	params     *piano.Params
+	p          *piano.Piano
+	sustain    bool
+	soft       bool
+}
+
+var (
+	enginesMu  sync.Mutex
+	engines    = map[int32]*engine{}
+	nextHandle int32
+)
+
+func createEngine(sampleRate int, presetPath string) (int32, error) {
+	if sampleRate < 8000 || sampleRate > 384000 {
+		return 0, errorf(statusErrArg, "sample rate out of range: %d", sampleRate)
+	}
+	params := piano.NewDefaultParams()
+	if presetPath != "" {
+		loaded, err := preset.LoadJSON(presetPath)
+		if err != nil {
+			return 0, errorf(statusErrLoad, "load preset %s: %v", presetPath, err)
+		}
+		params = loaded
+	}
+	e := &engine{sampleRate: sampleRate, params: params}
+	e.rebuild()
+
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	nextHandle++
+	engines[nextHandle] = e
+	return nextHandle, nil
+}
+
+func destroyEngine(h int32) error {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	if _, ok := engines[h]; !ok {
+		return errorf(statusErrHandle, "unknown handle %d", h)
+	}
+	delete(engines, h)
+	return nil
+}
+
+// withEngine runs fn with the engine for h locked.
+func withEngine(h int32, fn func(e *engine) error) error {
+	enginesMu.Lock()
+	e, ok := engines[h]
+	enginesMu.Unlock()
+	if !ok {
+		return errorf(statusErrHandle, "unknown handle %d", h)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return fn(e)
+}
+
+// rebuild recreates the piano from params, keeping pedal state.
+func (e *engine) rebuild() {
+	e.p = piano.NewPiano(e.sampleRate, 16, e.params)
+	if e.sustain {
+		e.p.SetSustainPedal(true)
+	}
+	if e.soft {
+		e.p.SetSoftPedal(true)
+	}
+}
+
+func (e *engine) setParam(name string, v float64) error {
+	d, ok := lookupParam(name)
+	if !ok {
+		return errorf(statusErrParam, "unknown parameter %q", name)
+	}
+	if v < d.min || v > d.max || v != v {
+		return errorf(statusErrArg, "%s must be in [%g,%g]", name, d.min, d.max)
+	}
+	field := d.field(e.params)
+	old := *field
+	*field = float32(v)
+	switch {
+	case name == "lid_position":
+		e.p.SetLidPosition(float32(v))
+	case name == "coupling_amount":
+		// A zero amount builds no coupling graph, so leaving it needs a rebuild.
+		if old > 0 {
+			e.p.SetCouplingAmount(float32(v))
+		} else {
+			e.rebuild()
+		}
+	case !d.live:
+		e.rebuild()
+	}
+	return nil
+}
+
+func (e *engine) getParam(name string) (float64, error) {
+	d, ok := lookupParam(name)
+	if !ok {
+		return 0, errorf(statusErrParam, "unknown parameter %q", name)
+	}
+	return float64(*d.field(e.params)), nil
+}
+
+func checkNote(note int) error {
+	if note < 0 || note > 127 {
+		return errorf(statusErrArg, "note out of MIDI range: %d", note)
+	}
+	return nil
+}
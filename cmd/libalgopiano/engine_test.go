@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func statusOf(err error) int32 {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code
+	}
+	return statusOK
+}
+
+func TestEngineLifecycleAndParams(t *testing.T) {
+	h, err := createEngine(48000, "")
+	if err != nil {
+		t.Fatalf("createEngine: %v", err)
+	}
+	defer destroyEngine(h)
+
+	err = withEngine(h, func(e *engine) error {
+		if err := e.setParam("output_gain", 0.5); err != nil {
+			return err
+		}
+		if v, err := e.getParam("output_gain"); err != nil || v != 0.5 {
+			t.Fatalf("output_gain = %v, %v", v, err)
+		}
+		before := e.p
+		if err := e.setParam("room_wet_mix", 0.2); err != nil {
+			return err
+		}
+		if e.p != before {
+			t.Fatalf("live parameter must not rebuild the engine")
+		}
+		if err := e.setParam("hammer_stiffness_scale", 1.2); err != nil {
+			return err
+		}
+		if e.p == before {
+			t.Fatalf("non-live parameter must rebuild the engine")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("set params: %v", err)
+	}
+
+	_ = withEngine(h, func(e *engine) error {
+		if got := statusOf(e.setParam("no_such_param", 1)); got != statusErrParam {
+			t.Fatalf("unknown param status = %d", got)
+		}
+		if got := statusOf(e.setParam("lid_position", 2)); got != statusErrArg {
+			t.Fatalf("out-of-range status = %d", got)
+		}
+		return nil
+	})
+}
+
+func TestEngineHandles(t *testing.T) {
+	if _, err := createEngine(100, ""); statusOf(err) != statusErrArg {
+		t.Fatalf("low sample rate: %v", err)
+	}
+	if _, err := createEngine(48000, "missing.json"); statusOf(err) != statusErrLoad {
+		t.Fatalf("missing preset: %v", err)
+	}
+	h, err := createEngine(44100, "")
+	if err != nil {
+		t.Fatalf("createEngine: %v", err)
+	}
+	err = withEngine(h, func(e *engine) error {
+		if l := e.p.Latency(); l < 0 {
+			t.Fatalf("latency = %d", l)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withEngine: %v", err)
+	}
+	if err := destroyEngine(h); err != nil {
+		t.Fatalf("destroyEngine: %v", err)
+	}
+	if err := destroyEngine(h); statusOf(err) != statusErrHandle {
+		t.Fatalf("double destroy: %v", err)
+	}
+	if err := withEngine(h, func(*engine) error { return nil }); statusOf(err) != statusErrHandle {
+		t.Fatalf("stale handle: %v", err)
+	}
+}
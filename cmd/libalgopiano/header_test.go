@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestHeaderMatchesExports(t *testing.T) {
+	header, err := os.ReadFile("algopiano.h")
+	if err != nil {
+		t.Fatalf("read header: %v", err)
+	}
+	src, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatalf("read main.go: %v", err)
+	}
+
+	declared := regexp.MustCompile(`(?m)^[a-z0-9_ ]+\*?(algopiano_[a-z_]+)\(`).FindAllSubmatch(header, -1)
+	exported := regexp.MustCompile(`(?m)^//export (algopiano_[a-z_]+)$`).FindAllSubmatch(src, -1)
+	names := func(ms [][][]byte) []string {
+		out := make([]string, 0, len(ms))
+		for _, m := range ms {
+			out = append(out, string(m[1]))
+		}
+		sort.Strings(out)
+		return out
+	}
+	h, g := names(declared), names(exported)
+	if len(h) == 0 || len(h) != len(g) {
+		t.Fatalf("header declares %v, Go exports %v", h, g)
+	}
+	for i := range h {
+		if h[i] != g[i] {
+			t.Fatalf("header declares %v, Go exports %v", h, g)
+		}
+	}
+
+	defines := map[string]int{
+		"ALGOPIANO_API_VERSION": apiVersion,
+		"ALGOPIANO_OK":          statusOK,
+		"ALGOPIANO_ERR_HANDLE":  statusErrHandle,
+		"ALGOPIANO_ERR_ARG":     statusErrArg,
+		"ALGOPIANO_ERR_PARAM":   statusErrParam,
+		"ALGOPIANO_ERR_LOAD":    statusErrLoad,
+	}
+	for name, want := range defines {
+		m := regexp.MustCompile(`#define ` + name + ` (-?\d+)`).FindSubmatch(header)
+		if m == nil {
+			t.Fatalf("header missing %s", name)
+		}
+		if got, _ := strconv.Atoi(string(m[1])); got != want {
+			t.Fatalf("%s = %d in header, %d in Go", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Command libalgopiano builds the engine as a C shared library:
+//
+//	go build -buildmode=c-shared -o libalgopiano.so ./cmd/libalgopiano
+//
+// The stable C interface is declared in algopiano.h. cgo cannot express
+// const-qualified parameters, so the header is not included here; its
+// declarations are ABI-identical to the exports below (header_test.go keeps
+// the two in sync).
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef int32_t algopiano_handle;
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+var (
+	lastErrMu sync.Mutex
+	lastErr   *C.char
+	noErr     = C.CString("")
+
+	paramNamesOnce sync.Once
+	paramNames     []*C.char
+)
+
+// status records err as the last error and converts it to a C status code.
+func status(err error) C.int32_t {
+	if err == nil {
+		return statusOK
+	}
+	lastErrMu.Lock()
+	if lastErr != nil {
+		C.free(unsafe.Pointer(lastErr))
+	}
+	lastErr = C.CString(err.Error())
+	lastErrMu.Unlock()
+	var se *statusError
+	if errors.As(err, &se) {
+		return C.int32_t(se.code)
+	}
+	return statusErrArg
+}
+
+//export algopiano_api_version
+func algopiano_api_version() C.int32_t {
+	return apiVersion
+}
+
+//export algopiano_create
+func algopiano_create(sampleRate C.int32_t, presetPath *C.char) C.algopiano_handle {
+	path := ""
+	if presetPath != nil {
+		path = C.GoString(presetPath)
+	}
+	h, err := createEngine(int(sampleRate), path)
+	if err != nil {
+		return C.algopiano_handle(status(err))
+	}
+	return C.algopiano_handle(h)
+}
+
+//export algopiano_destroy
+func algopiano_destroy(h C.algopiano_handle) C.int32_t {
+	return status(destroyEngine(int32(h)))
+}
+
+//export algopiano_note_on
+func algopiano_note_on(h C.algopiano_handle, note C.int32_t, velocity C.int32_t) C.int32_t {
+	return status(withEngine(int32(h), func(e *engine) error {
+		if err := checkNote(int(note)); err != nil {
+			return err
+		}
+		if velocity < 1 || velocity > 127 {
+			return errorf(statusErrArg, "velocity out of range: %d", int(velocity))
+		}
+		e.p.NoteOn(int(note), int(velocity))
+		return nil
+	}))
+}
+
+//export algopiano_note_off
+func algopiano_note_off(h C.algopiano_handle, note C.int32_t) C.int32_t {
+	return status(withEngine(int32(h), func(e *engine) error {
+		if err := checkNote(int(note)); err != nil {
+			return err
+		}
+		e.p.NoteOff(int(note))
+		return nil
+	}))
+}
+
+//export algopiano_key_down
+func algopiano_key_down(h C.algopiano_handle, note C.int32_t) C.int32_t {
+	return status(withEngine(int32(h), func(e *engine) error {
+		if err := checkNote(int(note)); err != nil {
+			return err
+		}
+		e.p.KeyDown(int(note))
+		return nil
+	}))
+}
+
+//export algopiano_set_sustain_pedal
+func algopiano_set_sustain_pedal(h C.algopiano_handle, down C.int32_t) C.int32_t {
+	return status(withEngine(int32(h), func(e *engine) error {
+		e.sustain = down != 0
+		e.p.SetSustainPedal(e.sustain)
+		return nil
+	}))
+}
+
+//export algopiano_set_soft_pedal
+func algopiano_set_soft_pedal(h C.algopiano_handle, down C.int32_t) C.int32_t {
+	return status(withEngine(int32(h), func(e *engine) error {
+		e.soft = down != 0
+		e.p.SetSoftPedal(e.soft)
+		return nil
+	}))
+}
+
+//export algopiano_param_count
+func algopiano_param_count() C.int32_t {
+	return C.int32_t(len(paramDefs))
+}
+
+//export algopiano_param_name
+func algopiano_param_name(index C.int32_t) *C.char {
+	paramNamesOnce.Do(func() {
+		paramNames = make([]*C.char, len(paramDefs))
+		for i, d := range paramDefs {
+			paramNames[i] = C.CString(d.name)
+		}
+	})
+	if index < 0 || int(index) >= len(paramNames) {
+		return nil
+	}
+	return paramNames[index]
+}
+
+//export algopiano_set_param
+func algopiano_set_param(h C.algopiano_handle, name *C.char, value C.double) C.int32_t {
+	if name == nil {
+		return status(errorf(statusErrArg, "nil parameter name"))
+	}
+	n := C.GoString(name)
+	return status(withEngine(int32(h), func(e *engine) error {
+		return e.setParam(n, float64(value))
+	}))
+}
+
+//export algopiano_get_param
+func algopiano_get_param(h C.algopiano_handle, name *C.char, value *C.double) C.int32_t {
+	if name == nil || value == nil {
+		return status(errorf(statusErrArg, "nil argument"))
+	}
+	n := C.GoString(name)
+	return status(withEngine(int32(h), func(e *engine) error {
+		v, err := e.getParam(n)
+		if err != nil {
+			return err
+		}
+		*value = C.double(v)
+		return nil
+	}))
+}
+
+//export algopiano_process
+func algopiano_process(h C.algopiano_handle, out *C.float, frames C.int32_t) C.int32_t {
+	if out == nil || frames < 0 {
+		return status(errorf(statusErrArg, "invalid output buffer"))
+	}
+	if frames == 0 {
+		return 0
+	}
+	dst := unsafe.Slice((*float32)(unsafe.Pointer(out)), int(frames)*2)
+	err := withEngine(int32(h), func(e *engine) error {
+		e.p.ProcessInto(dst)
+		return nil
+	})
+	if err != nil {
+		return status(err)
+	}
+	return frames
+}
+
+//export algopiano_latency
+func algopiano_latency(h C.algopiano_handle) C.int32_t {
+	var latency int
+	if err := withEngine(int32(h), func(e *engine) error {
+		latency = e.p.Latency()
+		return nil
+	}); err != nil {
+		return status(err)
+	}
+	return C.int32_t(latency)
+}
+
+//export algopiano_last_error
+func algopiano_last_error() *C.char {
+	lastErrMu.Lock()
+	defer lastErrMu.Unlock()
+	if lastErr == nil {
+		return noErr
+	}
+	return lastErr
+}
+
+func main() {}
@@ -0,0 +1,132 @@
+// Command piano-analyze runs the analysis package on WAV files and prints
+// JSON, so the objective metric and feature extraction can be scripted from
+// other languages (see python/algopiano_analysis.py).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+type signalReport struct {
+	Path     string                   `json:"path"`
+	Frames   int                      `json:"frames"`
+	Envelope *analysis.Envelope       `json:"envelope,omitempty"`
+	Partials []analysis.Partial       `json:"partials,omitempty"`
+	Residual *analysis.AttackResidual `json:"attack_residual,omitempty"`
+}
+
+type report struct {
+	SampleRate int               `json:"sample_rate"`
+	F0Hz       float64           `json:"f0_hz,omitempty"`
+	Reference  *signalReport     `json:"reference"`
+	Candidate  *signalReport     `json:"candidate,omitempty"`
+	Metrics    *analysis.Metrics `json:"metrics,omitempty"`
+}
+
+func main() {
+	referencePath := flag.String("reference", "", "Reference WAV path (required)")
+	candidatePath := flag.String("candidate", "", "Optional candidate WAV path; enables the Compare metrics")
+	sampleRate := flag.Int("sample-rate", 48000, "Analysis sample rate in Hz (inputs are resampled)")
+	envelope := flag.Bool("envelope", false, "Include RMS envelopes (dB) of each signal")
+	partials := flag.Int("partials", 0, "Number of partials to extract per signal (0 = off)")
+	residual := flag.Bool("residual", false, "Include the attack residual (partials removed) of each signal: the hammer knock's envelope and octave band levels")
+	residualWAV := flag.String("residual-wav", "", "Write the reference's attack residual to this mono WAV (implies --residual)")
+	note := flag.Int("note", 60, "MIDI note giving the nominal f0 for partial and residual extraction")
+	f0 := flag.Float64("f0", 0, "Nominal f0 in Hz for partial and residual extraction (overrides --note)")
+	gainMatch := flag.Bool("gain-match", false, "Match the candidate's slowly-varying level trajectory before metering")
+	releaseWeight := flag.Float64("release-weight", 0.0, "Blend weight in [0,1] of the release-window error in the score")
+	output := flag.String("output", "", "Write JSON to this path instead of stdout")
+	fitcommon.ParseFlags()
+
+	if *referencePath == "" {
+		die("--reference is required")
+	}
+	if *f0 <= 0 {
+		*f0 = 440.0 * math.Pow(2, float64(*note-69)/12.0)
+	}
+
+	if *residualWAV != "" {
+		*residual = true
+	}
+
+	rep := report{SampleRate: *sampleRate}
+	if *partials > 0 || *residual {
+		rep.F0Hz = *f0
+	}
+	analyze := func(path string) (*signalReport, []float64) {
+		raw, sr, err := fitcommon.ReadWAVMono(path)
+		if err != nil {
+			die("failed to read %s: %v", path, err)
+		}
+		x, err := fitcommon.ResampleIfNeeded(raw, sr, *sampleRate)
+		if err != nil {
+			die("failed to resample %s: %v", path, err)
+		}
+		s := &signalReport{Path: path, Frames: len(x)}
+		if *envelope {
+			env := analysis.ExtractEnvelope(x, *sampleRate)
+			s.Envelope = &env
+		}
+		if *partials > 0 {
+			s.Partials = analysis.ExtractPartials(x, *sampleRate, *f0, *partials)
+		}
+		if *residual {
+			if r, ok := analysis.ExtractAttackResidual(x, *sampleRate, *f0, piano.HammerKnockBandHz[:]); ok {
+				s.Residual = &r
+			}
+		}
+		return s, x
+	}
+
+	var ref []float64
+	rep.Reference, ref = analyze(*referencePath)
+	if *residualWAV != "" {
+		if rep.Reference.Residual == nil {
+			die("no attack residual found in %s", *referencePath)
+		}
+		res := make([]float32, len(rep.Reference.Residual.Residual))
+		for i, v := range rep.Reference.Residual.Residual {
+			res[i] = float32(v)
+		}
+		if err := fitcommon.WriteMonoWAV(*residualWAV, res, *sampleRate); err != nil {
+			die("failed to write %s: %v", *residualWAV, err)
+		}
+	}
+	if *candidatePath != "" {
+		var cand []float64
+		rep.Candidate, cand = analyze(*candidatePath)
+		m := analysis.CompareWithOptions(ref, cand, *sampleRate, analysis.CompareOptions{
+			GainMatch:     *gainMatch,
+			ReleaseWeight: *releaseWeight,
+		})
+		rep.Metrics = &m
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			die("failed to create output: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		die("json encode failed: %v", err)
+	}
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
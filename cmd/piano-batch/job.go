@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+	"github.com/cwbudde/algo-piano/render"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultSampleRate  = 48000
+	defaultVelocity    = 100
+	defaultMaxDuration = 30.0
+)
+
+// jobFile is the JSON schema of a batch job file; YAML job files use the
+// same keys. Relative preset and output paths are resolved against the job
+// file directory (outputs against OutputDir when set).
+type jobFile struct {
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Preset     string `json:"preset,omitempty"`
+	OutputDir  string `json:"output_dir,omitempty"`
+	// NormalizeLUFS is the default loudness target of jobs without their own.
+	NormalizeLUFS *float64 `json:"normalize_lufs,omitempty"`
+	Jobs          []job    `json:"jobs"`
+}
+
+// job is one render: a set of timed notes, pedal events and automation
+// lanes rendered with a preset to a stereo WAV file.
+type job struct {
+	Name       string                  `json:"name,omitempty"`
+	Preset     string                  `json:"preset,omitempty"`
+	Notes      []noteEvent             `json:"notes"`
+	Velocity   int                     `json:"velocity,omitempty"`
+	Pedal      []pedalEvent            `json:"pedal,omitempty"`
+	Automation []render.AutomationLane `json:"automation,omitempty"`
+	// Duration is the render length, or the minimum length with DecayDBFS.
+	Duration float64 `json:"duration_seconds"`
+	// DecayDBFS ends the render once the output decays below this level
+	// after all events, but not later than MaxDuration.
+	DecayDBFS   *float64 `json:"decay_dbfs,omitempty"`
+	MaxDuration float64  `json:"max_duration_seconds,omitempty"`
+	// NormalizeLUFS scales the render to this integrated loudness
+	// (ITU-R BS.1770) before writing.
+	NormalizeLUFS *float64 `json:"normalize_lufs,omitempty"`
+	Output        string   `json:"output"`
+}
+
+// noteEvent strikes a note at Onset and releases it at Release (nil holds it
+// to the end of the render).
+type noteEvent struct {
+	Note     int      `json:"note"`
+	Velocity int      `json:"velocity,omitempty"`
+	Onset    float64  `json:"onset_seconds"`
+	Release  *float64 `json:"release_seconds,omitempty"`
+}
+
+// pedalEvent presses or lifts the sustain or soft pedal at At.
+type pedalEvent struct {
+	Pedal string  `json:"pedal"`
+	At    float64 `json:"at_seconds"`
+	Down  bool    `json:"down"`
+}
+
+func loadJobFile(path string) (*jobFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if b, err = yamlToJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	var f jobFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if err := f.normalize(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so YAML job files share the
+// JSON schema and its validation.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// normalize validates the jobs, fills defaults and resolves paths relative
+// to base.
+func (f *jobFile) normalize(base string) error {
+	if f.SampleRate == 0 {
+		f.SampleRate = defaultSampleRate
+	}
+	if f.SampleRate < 8000 || f.SampleRate > 384000 {
+		return fmt.Errorf("sample_rate must be in [8000,384000]")
+	}
+	if len(f.Jobs) == 0 {
+		return fmt.Errorf("jobs must not be empty")
+	}
+	outDir := base
+	if f.OutputDir != "" {
+		outDir = resolvePath(base, f.OutputDir)
+	}
+	names := make(map[string]bool, len(f.Jobs))
+	outputs := make(map[string]bool, len(f.Jobs))
+	for i := range f.Jobs {
+		j := &f.Jobs[i]
+		if j.Preset == "" {
+			j.Preset = f.Preset
+		}
+		if j.NormalizeLUFS == nil {
+			j.NormalizeLUFS = f.NormalizeLUFS
+		}
+		j.Preset = resolvePath(base, j.Preset)
+		if j.Output == "" {
+			return fmt.Errorf("jobs[%d].output must be set", i)
+		}
+		j.Output = resolvePath(outDir, j.Output)
+		if j.Name == "" {
+			j.Name = strings.TrimSuffix(filepath.Base(j.Output), filepath.Ext(j.Output))
+		}
+		if names[j.Name] {
+			return fmt.Errorf("jobs[%d]: duplicate name %q", i, j.Name)
+		}
+		names[j.Name] = true
+		if outputs[j.Output] {
+			return fmt.Errorf("jobs[%d]: duplicate output %q", i, j.Output)
+		}
+		outputs[j.Output] = true
+		if err := j.validate(); err != nil {
+			return fmt.Errorf("jobs[%d] (%s): %w", i, j.Name, err)
+		}
+	}
+	return nil
+}
+
+func (j *job) validate() error {
+	if j.Velocity == 0 {
+		j.Velocity = defaultVelocity
+	}
+	if j.Velocity < 1 || j.Velocity > 127 {
+		return fmt.Errorf("velocity must be in [1,127]")
+	}
+	if len(j.Notes) == 0 {
+		return fmt.Errorf("notes must not be empty")
+	}
+	for i := range j.Notes {
+		n := &j.Notes[i]
+		if n.Note < 0 || n.Note > 127 {
+			return fmt.Errorf("notes[%d].note must be in [0,127]", i)
+		}
+		if n.Velocity == 0 {
+			n.Velocity = j.Velocity
+		}
+		if n.Velocity < 1 || n.Velocity > 127 {
+			return fmt.Errorf("notes[%d].velocity must be in [1,127]", i)
+		}
+		if n.Onset < 0 {
+			return fmt.Errorf("notes[%d].onset_seconds must be >= 0", i)
+		}
+		if n.Release != nil && *n.Release < n.Onset {
+			return fmt.Errorf("notes[%d].release_seconds must be >= onset_seconds", i)
+		}
+	}
+	for i, p := range j.Pedal {
+		switch p.Pedal {
+		case "sustain", "soft":
+		default:
+			return fmt.Errorf("pedal[%d].pedal must be one of sustain|soft", i)
+		}
+		if p.At < 0 {
+			return fmt.Errorf("pedal[%d].at_seconds must be >= 0", i)
+		}
+	}
+	for i := range j.Automation {
+		if err := j.Automation[i].Validate(); err != nil {
+			return fmt.Errorf("automation[%d]: %w", i, err)
+		}
+	}
+	if j.Duration <= 0 {
+		return fmt.Errorf("duration_seconds must be > 0")
+	}
+	if j.DecayDBFS != nil {
+		if j.MaxDuration == 0 {
+			j.MaxDuration = defaultMaxDuration
+		}
+		if j.MaxDuration < j.Duration {
+			return fmt.Errorf("max_duration_seconds must be >= duration_seconds")
+		}
+	}
+	return nil
+}
+
+// setNormalizeLUFS overrides the loudness target of every job.
+func (f *jobFile) setNormalizeLUFS(target float64) {
+	for i := range f.Jobs {
+		t := target
+		f.Jobs[i].NormalizeLUFS = &t
+	}
+}
+
+// fingerprint identifies the render settings of j: the job, the sample
+// rate and the contents of its preset and of the IR files the preset loads,
+// as hashed by digest. The state file uses it to tell an unchanged finished
+// job from one whose job entry, preset or IRs were edited since.
+func (j job) fingerprint(sampleRate int, digest func(path string) string) string {
+	params := piano.NewDefaultParams()
+	inputs := map[string]string{}
+	if j.Preset != "" {
+		inputs[j.Preset] = digest(j.Preset)
+		if loaded, err := preset.LoadJSON(j.Preset); err == nil {
+			params = loaded
+		}
+	}
+	for _, path := range []string{params.IRWavPath, params.BodyIRWavPath, params.BodyIRClosedWavPath, params.RoomIRWavPath} {
+		if path != "" {
+			inputs[path] = digest(path)
+		}
+	}
+	b, _ := json.Marshal(struct {
+		SampleRate int               `json:"sample_rate"`
+		Job        job               `json:"job"`
+		Inputs     map[string]string `json:"inputs"`
+	}{sampleRate, j, inputs})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// fileDigests returns a digest function for job.fingerprint that hashes each
+// file once, so jobs sharing a preset and its IRs read them once. An
+// unreadable file digests to "".
+func fileDigests() func(path string) string {
+	sums := map[string]string{}
+	return func(path string) string {
+		if s, ok := sums[path]; ok {
+			return s
+		}
+		var s string
+		if b, err := piano.ReadAsset(path); err == nil {
+			sum := sha256.Sum256(b)
+			s = hex.EncodeToString(sum[:])
+		}
+		sums[path] = s
+		return s
+	}
+}
+
+func resolvePath(base string, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Clean(filepath.Join(base, p))
+}
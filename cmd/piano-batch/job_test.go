@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeJobFile(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "jobs.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write job file: %v", err)
+	}
+	return path
+}
+
+func TestLoadJobFileResolvesPathsAndDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := writeJobFile(t, dir, `{
+		"preset": "presets/base.json",
+		"output_dir": "out",
+		"jobs": [
+			{"notes": [{"note": 60}], "duration_seconds": 1, "output": "c4.wav"},
+			{"name": "chord", "preset": "/abs/other.json", "velocity": 80,
+			 "notes": [{"note": 60}, {"note": 64, "velocity": 90, "onset_seconds": 0.1}],
+			 "duration_seconds": 1, "decay_dbfs": -80, "output": "chords/ce.wav"}
+		]
+	}`)
+	f, err := loadJobFile(path)
+	if err != nil {
+		t.Fatalf("loadJobFile: %v", err)
+	}
+	if f.SampleRate != defaultSampleRate {
+		t.Fatalf("sample rate = %d, want %d", f.SampleRate, defaultSampleRate)
+	}
+	first, second := f.Jobs[0], f.Jobs[1]
+	if first.Name != "c4" || first.Output != filepath.Join(dir, "out", "c4.wav") {
+		t.Fatalf("first job name/output = %q %q", first.Name, first.Output)
+	}
+	if first.Preset != filepath.Join(dir, "presets", "base.json") {
+		t.Fatalf("first job preset = %q", first.Preset)
+	}
+	if first.Notes[0].Velocity != defaultVelocity {
+		t.Fatalf("default velocity = %d, want %d", first.Notes[0].Velocity, defaultVelocity)
+	}
+	if second.Preset != "/abs/other.json" || second.Output != filepath.Join(dir, "out", "chords", "ce.wav") {
+		t.Fatalf("second job preset/output = %q %q", second.Preset, second.Output)
+	}
+	if second.Notes[0].Velocity != 80 || second.Notes[1].Velocity != 90 {
+		t.Fatalf("chord velocities = %d,%d, want 80,90", second.Notes[0].Velocity, second.Notes[1].Velocity)
+	}
+	if second.MaxDuration != defaultMaxDuration {
+		t.Fatalf("max duration = %g, want %g", second.MaxDuration, defaultMaxDuration)
+	}
+}
+
+func TestLoadJobFileRejectsInvalidJobs(t *testing.T) {
+	cases := []string{
+		`{"jobs": []}`,
+		`{"jobs": [{"notes": [{"note": 60}], "duration_seconds": 1}]}`,
+		`{"jobs": [{"notes": [], "duration_seconds": 1, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 128}], "duration_seconds": 1, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 60}], "duration_seconds": 0, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 60, "onset_seconds": 1, "release_seconds": 0.5}], "duration_seconds": 1, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 60}], "pedal": [{"pedal": "middle"}], "duration_seconds": 1, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 60}], "duration_seconds": 5, "decay_dbfs": -80, "max_duration_seconds": 2, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 60}], "duration_seconds": 1, "output": "a.wav"}, {"notes": [{"note": 62}], "duration_seconds": 1, "output": "a.wav"}]}`,
+		`{"jobs": [{"notes": [{"note": 60}], "automation": [{"param": "lid_position", "points": [{"value": 2}]}], "duration_seconds": 1, "output": "a.wav"}]}`,
+		`{"sample_rate": 1000, "jobs": [{"notes": [{"note": 60}], "duration_seconds": 1, "output": "a.wav"}]}`,
+	}
+	for _, content := range cases {
+		path := writeJobFile(t, t.TempDir(), content)
+		if _, err := loadJobFile(path); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJobFileReadsYAML(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := writeJobFile(t, dir, `{
+		"sample_rate": 44100,
+		"jobs": [
+			{"name": "chord", "velocity": 80,
+			 "notes": [{"note": 60, "release_seconds": 0.5}, {"note": 64, "onset_seconds": 0.1}],
+			 "pedal": [{"pedal": "sustain", "at_seconds": 0, "down": true}],
+			 "automation": [{"param": "room_wet", "points": [{"at_seconds": 0, "value": 0.2}, {"at_seconds": 1, "value": 0.5, "ramp": true}]}],
+			 "duration_seconds": 1, "decay_dbfs": -80, "output": "chord.wav"}
+		]
+	}`)
+	yamlPath := filepath.Join(dir, "jobs.yaml")
+	content := `sample_rate: 44100
+jobs:
+  - name: chord
+    velocity: 80
+    notes:
+      - {note: 60, release_seconds: 0.5}
+      - {note: 64, onset_seconds: 0.1}
+    pedal:
+      - {pedal: sustain, at_seconds: 0, down: true}
+    automation:
+      - param: room_wet
+        points:
+          - {at_seconds: 0, value: 0.2}
+          - {at_seconds: 1, value: 0.5, ramp: true}
+    duration_seconds: 1
+    decay_dbfs: -80
+    output: chord.wav
+`
+	if err := os.WriteFile(yamlPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write job file: %v", err)
+	}
+	want, err := loadJobFile(jsonPath)
+	if err != nil {
+		t.Fatalf("loadJobFile json: %v", err)
+	}
+	got, err := loadJobFile(yamlPath)
+	if err != nil {
+		t.Fatalf("loadJobFile yaml: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("yaml job file = %+v, want %+v", got, want)
+	}
+}
+
+func TestScheduleEventsOrdersByFrame(t *testing.T) {
+	release := 0.5
+	j := job{
+		Notes: []noteEvent{
+			{Note: 64, Velocity: 100, Onset: 0.25},
+			{Note: 60, Velocity: 100, Onset: 0, Release: &release},
+		},
+		Pedal: []pedalEvent{{Pedal: "sustain", At: 0, Down: true}},
+	}
+	events, last := scheduleEvents(j, 1000)
+	var frames []int
+	for _, e := range events {
+		frames = append(frames, e.frame)
+	}
+	want := []int{0, 0, 250, 500}
+	if len(frames) != len(want) {
+		t.Fatalf("frames = %v, want %v", frames, want)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Fatalf("frames = %v, want %v", frames, want)
+		}
+	}
+	if last != 500 {
+		t.Fatalf("last event frame = %d, want 500", last)
+	}
+}
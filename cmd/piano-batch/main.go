@@ -0,0 +1,213 @@
+// Command piano-batch renders many notes, chords and pedal scenarios from a
+// JSON or YAML job file in parallel, e.g. to build evaluation corpora or sample
+// libraries. Finished jobs are recorded in a state file so an interrupted
+// batch resumes where it stopped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/render"
+)
+
+type batchConfig struct {
+	jobs      *jobFile
+	state     *batchState
+	workers   int
+	force     bool
+	log       *slog.Logger
+	renderJob func(j job, sampleRate int, write func(block []float32) error) (int, error)
+}
+
+type batchSummary struct {
+	rendered int
+	skipped  int
+	failed   int
+}
+
+func main() {
+	jobsPath := flag.String("jobs", "", "Batch job file (JSON, or YAML with a .yaml/.yml extension)")
+	statePath := flag.String("state", "", "Resumable state file (default: <jobs>.state.json)")
+	workersRaw := flag.String("workers", "auto", "Parallel render workers (integer >= 1 or 'auto')")
+	force := flag.Bool("force", false, "Re-render jobs that the state file records as done")
+	normalizeLUFS := flag.Float64("normalize-lufs", math.Inf(1), "Normalize every job to this integrated loudness (ITU-R BS.1770, e.g. -16), overriding the job file")
+	logConfig := fitcommon.RegisterLogFlags()
+	fitcommon.ParseFlags()
+
+	log, err := logConfig.NewLogger(os.Stdout)
+	if err != nil {
+		die("%v", err)
+	}
+
+	if *jobsPath == "" {
+		die("--jobs is required")
+	}
+	workers, err := fitcommon.ParseWorkers(*workersRaw)
+	if err != nil {
+		die("invalid --workers: %v", err)
+	}
+	jobs, err := loadJobFile(*jobsPath)
+	if err != nil {
+		die("load jobs %s: %v", *jobsPath, err)
+	}
+	if !math.IsInf(*normalizeLUFS, 1) {
+		jobs.setNormalizeLUFS(*normalizeLUFS)
+	}
+	if *statePath == "" {
+		*statePath = *jobsPath + ".state.json"
+	}
+	state, err := loadState(*statePath)
+	if err != nil {
+		die("load state %s: %v", *statePath, err)
+	}
+
+	start := time.Now()
+	sum := runBatch(batchConfig{
+		jobs:      jobs,
+		state:     state,
+		workers:   workers,
+		force:     *force,
+		log:       log,
+		renderJob: streamJob,
+	})
+	log.Info("batch finished", "elapsed", time.Since(start).Round(time.Millisecond),
+		"rendered", sum.rendered, "skipped", sum.skipped, "failed", sum.failed, "state", *statePath)
+	if sum.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runBatch renders all pending jobs with a worker pool. Job failures are
+// recorded in the state and counted, they do not stop the other workers.
+func runBatch(cfg batchConfig) batchSummary {
+	var sum batchSummary
+	pending := make([]job, 0, len(cfg.jobs.Jobs))
+	fingerprints := make(map[string]string, len(cfg.jobs.Jobs))
+	digest := fileDigests()
+	for _, j := range cfg.jobs.Jobs {
+		fingerprint := j.fingerprint(cfg.jobs.SampleRate, digest)
+		if !cfg.force && cfg.state.done(j, fingerprint) {
+			sum.skipped++
+			continue
+		}
+		fingerprints[j.Name] = fingerprint
+		pending = append(pending, j)
+	}
+
+	workers := cfg.workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = fitcommon.MaxInt(1, fitcommon.MinInt(workers, len(pending)))
+
+	queue := make(chan job)
+	var rendered, failed, finished int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				st := runJob(cfg, j, fingerprints[j.Name])
+				n := atomic.AddInt64(&finished, 1)
+				if st.Status == statusDone {
+					atomic.AddInt64(&rendered, 1)
+					cfg.log.Info("rendered", "n", n, "of", len(pending), "job", j.Name, "output", j.Output,
+						"duration_s", float64(st.Frames)/float64(cfg.jobs.SampleRate))
+				} else {
+					atomic.AddInt64(&failed, 1)
+					cfg.log.Error("job failed", "n", n, "of", len(pending), "job", j.Name, "err", st.Error)
+				}
+				if err := cfg.state.record(j.Name, st); err != nil {
+					cfg.log.Error("save state", "err", err)
+				}
+			}
+		}()
+	}
+	for _, j := range pending {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+
+	sum.rendered = int(rendered)
+	sum.failed = int(failed)
+	return sum
+}
+
+func runJob(cfg batchConfig, j job, fingerprint string) jobState {
+	st := jobState{
+		Fingerprint: fingerprint,
+		Status:      statusFailed,
+		Output:      j.Output,
+	}
+	var frames int
+	var err error
+	if j.NormalizeLUFS != nil {
+		frames, err = renderNormalized(cfg, j)
+	} else {
+		frames, err = renderStreamed(cfg, j)
+	}
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Status = statusDone
+	st.Frames = frames
+	return st
+}
+
+// renderStreamed writes the job's render to its output as it is produced,
+// so long renders are never held in memory. A failed render leaves no file.
+func renderStreamed(cfg batchConfig, j job) (int, error) {
+	w, err := render.CreateWAV(j.Output, cfg.jobs.SampleRate, 2)
+	if err != nil {
+		return 0, err
+	}
+	frames, err := cfg.renderJob(j, cfg.jobs.SampleRate, w.Write)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(j.Output)
+	}
+	return frames, err
+}
+
+// renderNormalized keeps the whole render in memory: loudness
+// normalization measures all of it before the file is written.
+func renderNormalized(cfg batchConfig, j job) (int, error) {
+	var samples []float32
+	_, err := cfg.renderJob(j, cfg.jobs.SampleRate, func(block []float32) error {
+		samples = append(samples, block...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := render.NormalizeLUFS(samples, cfg.jobs.SampleRate, *j.NormalizeLUFS)
+	if err != nil {
+		return 0, err
+	}
+	if n.PeakDBFS > 0 {
+		cfg.log.Warn("normalized peak clips", "job", j.Name, "peak_dbfs", n.PeakDBFS)
+	}
+	if err := fitcommon.WriteStereoInterleavedWAV(j.Output, samples, cfg.jobs.SampleRate); err != nil {
+		return 0, err
+	}
+	return len(samples) / 2, nil
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
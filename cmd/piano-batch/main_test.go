@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/analysis"
+	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/render"
+)
+
+func TestRunBatchResumesFinishedJobs(t *testing.T) {
+	dir := t.TempDir()
+	path := writeJobFile(t, dir, `{
+		"sample_rate": 16000,
+		"jobs": [
+			{"name": "a", "notes": [{"note": 60}], "duration_seconds": 0.05, "output": "a.wav"},
+			{"name": "b", "notes": [{"note": 64}], "duration_seconds": 0.05, "output": "b.wav"},
+			{"name": "c", "notes": [{"note": 67}], "duration_seconds": 0.05, "output": "c.wav"}
+		]
+	}`)
+	statePath := filepath.Join(dir, "state.json")
+
+	var calls int64
+	run := func(jobs *jobFile, failName string) batchSummary {
+		state, err := loadState(statePath)
+		if err != nil {
+			t.Fatalf("loadState: %v", err)
+		}
+		return runBatch(batchConfig{
+			jobs:    jobs,
+			state:   state,
+			workers: 2,
+			log:     slog.New(slog.DiscardHandler),
+			renderJob: func(j job, sampleRate int, write func([]float32) error) (int, error) {
+				atomic.AddInt64(&calls, 1)
+				if j.Name == failName {
+					return 0, errors.New("boom")
+				}
+				return streamJob(j, sampleRate, write)
+			},
+		})
+	}
+
+	jobs, err := loadJobFile(path)
+	if err != nil {
+		t.Fatalf("loadJobFile: %v", err)
+	}
+	if sum := run(jobs, "b"); sum.rendered != 2 || sum.failed != 1 {
+		t.Fatalf("first run = %+v, want 2 rendered and 1 failed", sum)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.wav")); !os.IsNotExist(err) {
+		t.Fatalf("failed job left an output file: %v", err)
+	}
+	if a, _, err := fitcommon.ReadWAVMono(filepath.Join(dir, "a.wav")); err != nil || len(a) != 800 {
+		t.Fatalf("streamed a.wav: %d frames, %v; want 800", len(a), err)
+	}
+	if sum := run(jobs, ""); sum.rendered != 1 || sum.skipped != 2 {
+		t.Fatalf("resumed run = %+v, want only the failed job rendered", sum)
+	}
+
+	jobs.Jobs[0].Notes[0].Velocity = 50
+	if sum := run(jobs, ""); sum.rendered != 1 || sum.skipped != 2 {
+		t.Fatalf("edited run = %+v, want only the edited job rendered", sum)
+	}
+	if calls != 5 {
+		t.Fatalf("render calls = %d, want 5", calls)
+	}
+}
+
+func TestRunBatchRerendersAfterPresetOrIREdit(t *testing.T) {
+	dir := t.TempDir()
+	writeIR := func(tail float32) {
+		t.Helper()
+		w, err := render.CreateWAV(filepath.Join(dir, "room.wav"), 16000, 2)
+		if err != nil {
+			t.Fatalf("create IR: %v", err)
+		}
+		if err := w.Write([]float32{0.5, 0.5, tail, tail}); err != nil {
+			t.Fatalf("write IR: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close IR: %v", err)
+		}
+	}
+	writePreset := func(gain string) {
+		t.Helper()
+		content := `{"output_gain": ` + gain + `, "room_ir_wav_path": "room.wav"}`
+		if err := os.WriteFile(filepath.Join(dir, "preset.json"), []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+	}
+	writeIR(0.25)
+	writePreset("1")
+	path := writeJobFile(t, dir, `{
+		"sample_rate": 16000,
+		"preset": "preset.json",
+		"jobs": [{"name": "a", "notes": [{"note": 60}], "duration_seconds": 0.05, "output": "a.wav"}]
+	}`)
+	jobs, err := loadJobFile(path)
+	if err != nil {
+		t.Fatalf("loadJobFile: %v", err)
+	}
+	state, err := loadState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	cfg := batchConfig{jobs: jobs, state: state, workers: 1, log: slog.New(slog.DiscardHandler), renderJob: streamJob}
+	if sum := runBatch(cfg); sum.rendered != 1 {
+		t.Fatalf("first run = %+v, want 1 rendered", sum)
+	}
+	if sum := runBatch(cfg); sum.skipped != 1 {
+		t.Fatalf("unchanged run = %+v, want the job skipped", sum)
+	}
+	writePreset("0.5")
+	if sum := runBatch(cfg); sum.rendered != 1 {
+		t.Fatalf("run after a preset edit = %+v, want the job rendered", sum)
+	}
+	writeIR(-0.25)
+	if sum := runBatch(cfg); sum.rendered != 1 {
+		t.Fatalf("run after an IR edit = %+v, want the job rendered", sum)
+	}
+}
+
+func TestStreamJobFixedAndDecayLengths(t *testing.T) {
+	const sr = 16000
+	release := 0.05
+	fixed := job{Notes: []noteEvent{{Note: 60, Velocity: 100, Onset: 0.2}}, Duration: 0.1}
+	discard := func([]float32) error { return nil }
+	got, err := streamJob(fixed, sr, discard)
+	if err != nil {
+		t.Fatalf("streamJob: %v", err)
+	}
+	if want := int(0.2 * sr); got != want {
+		t.Fatalf("fixed render frames = %d, want %d (extended to the last event)", got, want)
+	}
+
+	decay := -40.0
+	auto := job{
+		Notes:       []noteEvent{{Note: 60, Velocity: 100, Release: &release}},
+		Duration:    0.1,
+		DecayDBFS:   &decay,
+		MaxDuration: 5,
+	}
+	frames, err := streamJob(auto, sr, discard)
+	if err != nil {
+		t.Fatalf("streamJob: %v", err)
+	}
+	if frames < int(0.1*sr) || frames >= 5*sr {
+		t.Fatalf("decay render frames = %d, want between min and max duration", frames)
+	}
+}
+
+func TestRunBatchNormalizesLoudness(t *testing.T) {
+	dir := t.TempDir()
+	path := writeJobFile(t, dir, `{
+		"sample_rate": 16000,
+		"normalize_lufs": -20,
+		"jobs": [
+			{"name": "soft", "notes": [{"note": 60, "velocity": 30}], "duration_seconds": 0.5, "output": "soft.wav"},
+			{"name": "loud", "notes": [{"note": 60, "velocity": 120}], "duration_seconds": 0.5, "normalize_lufs": -30, "output": "loud.wav"}
+		]
+	}`)
+	jobs, err := loadJobFile(path)
+	if err != nil {
+		t.Fatalf("loadJobFile: %v", err)
+	}
+	state, err := loadState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	cfg := batchConfig{jobs: jobs, state: state, workers: 1, log: slog.New(slog.DiscardHandler), renderJob: streamJob}
+	if sum := runBatch(cfg); sum.rendered != 2 {
+		t.Fatalf("run = %+v, want 2 rendered", sum)
+	}
+	for name, want := range map[string]float64{"soft": -20, "loud": -30} {
+		samples, sr, err := fitcommon.ReadWAVMono(filepath.Join(dir, name+".wav"))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		// 16-bit output and the mono downmix of a near-mono render.
+		if got := analysis.IntegratedLoudness([][]float64{samples, samples}, sr); math.Abs(got-want) > 0.5 {
+			t.Fatalf("%s loudness = %.2f LUFS, want %.0f", name, got, want)
+		}
+	}
+
+	jobs.setNormalizeLUFS(-18)
+	if sum := runBatch(cfg); sum.rendered != 2 || sum.skipped != 0 {
+		t.Fatalf("retargeted run = %+v, want both jobs re-rendered", sum)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+	"github.com/cwbudde/algo-piano/render"
+)
+
+const renderBlockSize = 128
+
+// timedEvent is a note or pedal event at a frame offset of the render.
+type timedEvent struct {
+	frame int
+	order int // keeps the job file order for events on the same frame
+	apply func(p *piano.Piano)
+}
+
+// scheduleEvents converts the notes and pedal events of j into frame-sorted
+// engine calls, and returns the frame of the last event.
+func scheduleEvents(j job, sampleRate int) ([]timedEvent, int) {
+	toFrame := func(sec float64) int {
+		return int(math.Round(sec * float64(sampleRate)))
+	}
+	var events []timedEvent
+	add := func(sec float64, fn func(p *piano.Piano)) {
+		events = append(events, timedEvent{frame: toFrame(sec), order: len(events), apply: fn})
+	}
+	for _, pe := range j.Pedal {
+		pe := pe
+		add(pe.At, func(p *piano.Piano) {
+			if pe.Pedal == "soft" {
+				p.SetSoftPedal(pe.Down)
+				return
+			}
+			p.SetSustainPedal(pe.Down)
+		})
+	}
+	for _, n := range j.Notes {
+		n := n
+		add(n.Onset, func(p *piano.Piano) { p.NoteOn(n.Note, n.Velocity) })
+		if n.Release != nil {
+			add(*n.Release, func(p *piano.Piano) { p.NoteOff(n.Note) })
+		}
+	}
+	sort.SliceStable(events, func(a, b int) bool {
+		if events[a].frame != events[b].frame {
+			return events[a].frame < events[b].frame
+		}
+		return events[a].order < events[b].order
+	})
+	last := 0
+	if len(events) > 0 {
+		last = events[len(events)-1].frame
+	}
+	return events, last
+}
+
+// streamJob renders j and passes the interleaved stereo blocks to write as
+// they are produced. It returns the number of frames rendered.
+func streamJob(j job, sampleRate int, write func(block []float32) error) (int, error) {
+	params := piano.NewDefaultParams()
+	if j.Preset != "" {
+		loaded, err := preset.LoadJSON(j.Preset)
+		if err != nil {
+			return 0, err
+		}
+		params = loaded
+	}
+	p := piano.NewPiano(sampleRate, 16, params)
+	events, lastEvent := scheduleEvents(j, sampleRate)
+	automation, err := render.NewAutomation(j.Automation, sampleRate, params)
+	if err != nil {
+		return 0, err
+	}
+	lastEvent = fitcommon.MaxInt(lastEvent, automation.LastFrame())
+
+	// Renders never end before the last event; without DecayDBFS the
+	// stopper degenerates to a fixed length.
+	minDuration := math.Max(j.Duration, float64(lastEvent)/float64(sampleRate))
+	cfg := render.AutoStopConfig{
+		SampleRate:  sampleRate,
+		HoldBlocks:  6,
+		MinDuration: minDuration,
+		MaxDuration: minDuration,
+	}
+	if j.DecayDBFS != nil {
+		cfg.DecayDBFS = *j.DecayDBFS
+		cfg.MaxDuration = math.Max(j.MaxDuration, minDuration)
+	}
+	stop, err := render.NewAutoStopper(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	next := 0
+	for !stop.Done() {
+		for next < len(events) && events[next].frame <= stop.Rendered() {
+			events[next].apply(p)
+			next++
+		}
+		automation.Apply(p, stop.Rendered())
+		n := stop.NextBlock(renderBlockSize)
+		if next < len(events) {
+			n = fitcommon.MinInt(n, events[next].frame-stop.Rendered())
+		}
+		if f := automation.NextFrame(stop.Rendered()); f >= 0 {
+			n = fitcommon.MinInt(n, f-stop.Rendered())
+		}
+		block := p.Process(n)
+		if err := write(block); err != nil {
+			return stop.Rendered(), err
+		}
+		stop.Observe(block, p.ActiveVoices())
+	}
+	return stop.Rendered(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	statusDone   = "done"
+	statusFailed = "failed"
+)
+
+// jobState is the persisted outcome of one job.
+type jobState struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      string `json:"status"`
+	Output      string `json:"output"`
+	Frames      int    `json:"frames,omitempty"`
+	Error       string `json:"error,omitempty"`
+	FinishedAt  string `json:"finished_at"`
+}
+
+// batchState is the resumable state file of a batch run. It is rewritten
+// after every finished job, so an interrupted run resumes where it stopped.
+type batchState struct {
+	mu   sync.Mutex
+	path string
+	Jobs map[string]jobState `json:"jobs"`
+}
+
+func loadState(path string) (*batchState, error) {
+	s := &batchState{path: path, Jobs: map[string]jobState{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if s.Jobs == nil {
+		s.Jobs = map[string]jobState{}
+	}
+	return s, nil
+}
+
+// done reports whether j already finished with the same settings and its
+// output file still exists.
+func (s *batchState) done(j job, fingerprint string) bool {
+	s.mu.Lock()
+	st, ok := s.Jobs[j.Name]
+	s.mu.Unlock()
+	if !ok || st.Status != statusDone || st.Fingerprint != fingerprint || st.Output != j.Output {
+		return false
+	}
+	_, err := os.Stat(j.Output)
+	return err == nil
+}
+
+// record stores the outcome of a job and saves the state file.
+func (s *batchState) record(name string, st jobState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	s.Jobs[name] = st
+	return s.saveLocked()
+}
+
+// saveLocked writes the state through a temporary file so a crash never
+// leaves a truncated state file behind.
+func (s *batchState) saveLocked() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+type graphJSON struct {
+	Mode       string     `json:"mode"`
+	SampleRate int        `json:"sample_rate"`
+	Nodes      []nodeJSON `json:"nodes"`
+	Edges      []edgeJSON `json:"edges"`
+}
+
+type nodeJSON struct {
+	Note          int     `json:"note"`
+	FundamentalHz float32 `json:"fundamental_hz"`
+	Strings       int     `json:"strings"`
+}
+
+type edgeJSON struct {
+	From int     `json:"from"`
+	To   int     `json:"to"`
+	Gain float32 `json:"gain"`
+}
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file path")
+	sampleRate := flag.Int("sample-rate", 48000, "Sample rate in Hz used to build the coupling graph")
+	mode := flag.String("mode", "", "Coupling mode override: off|static|physical (default: preset value)")
+	format := flag.String("format", "json", "Output format: json|dot")
+	minGain := flag.Float64("min-gain", 0, "Drop edges with gain below this value")
+	output := flag.String("output", "", "Output file path (default: stdout)")
+	fitcommon.ParseFlags()
+
+	params, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	if *mode != "" {
+		m := piano.CouplingMode(*mode)
+		switch m {
+		case piano.CouplingModeOff, piano.CouplingModeStatic, piano.CouplingModePhysical:
+		default:
+			die("invalid -mode %q (expected off|static|physical)", *mode)
+		}
+		params.CouplingMode = m
+		params.CouplingEnabled = m != piano.CouplingModeOff
+	}
+
+	graph := piano.NewStringBank(*sampleRate, params).CouplingGraph()
+	edges := make([]piano.CouplingEdge, 0, len(graph.Edges))
+	for _, e := range graph.Edges {
+		if float64(e.Gain) < *minGain {
+			continue
+		}
+		edges = append(edges, e)
+	}
+	graph.Edges = edges
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			die("failed to create output: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "json":
+		err = writeJSON(w, graph, *sampleRate)
+	case "dot":
+		err = writeDOT(w, graph)
+	default:
+		die("invalid -format %q (expected json|dot)", *format)
+	}
+	if err != nil {
+		die("failed to write graph: %v", err)
+	}
+}
+
+func writeJSON(w io.Writer, graph piano.CouplingGraph, sampleRate int) error {
+	out := graphJSON{
+		Mode:       string(graph.Mode),
+		SampleRate: sampleRate,
+		Nodes:      make([]nodeJSON, 0, len(graph.Nodes)),
+		Edges:      make([]edgeJSON, 0, len(graph.Edges)),
+	}
+	for _, n := range graph.Nodes {
+		out.Nodes = append(out.Nodes, nodeJSON{Note: n.Note, FundamentalHz: n.Fundamental, Strings: n.Strings})
+	}
+	for _, e := range graph.Edges {
+		out.Edges = append(out.Edges, edgeJSON{From: e.From, To: e.To, Gain: e.Gain})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func writeDOT(w io.Writer, graph piano.CouplingGraph) error {
+	maxGain := float32(0)
+	for _, e := range graph.Edges {
+		if e.Gain > maxGain {
+			maxGain = e.Gain
+		}
+	}
+	// Only emit notes that take part in at least one edge to keep the plot readable.
+	used := make(map[int]bool, len(graph.Nodes))
+	for _, e := range graph.Edges {
+		used[e.From] = true
+		used[e.To] = true
+	}
+
+	if _, err := fmt.Fprintf(w, "digraph coupling {\n  label=\"coupling mode: %s\";\n  node [shape=circle];\n", graph.Mode); err != nil {
+		return err
+	}
+	for _, n := range graph.Nodes {
+		if !used[n.Note] {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "  n%d [label=\"%s\\n%.1f Hz\"];\n", n.Note, noteName(n.Note), n.Fundamental); err != nil {
+			return err
+		}
+	}
+	for _, e := range graph.Edges {
+		width := float32(1)
+		if maxGain > 0 {
+			width = 0.5 + 3.5*e.Gain/maxGain
+		}
+		if _, err := fmt.Fprintf(w, "  n%d -> n%d [label=\"%.3g\", penwidth=%.2f];\n", e.From, e.To, e.Gain, width); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func noteName(note int) string {
+	names := [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	return fmt.Sprintf("%s%d", names[note%12], note/12-1)
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,106 @@
+// Command piano-dataset prepares reference dataset manifests for piano-fit
+// --dataset: it imports a checked-out public sample dataset (MAPS,
+// OrchideaSOL/TinySOL naming) into a manifest, lists a manifest's notes and
+// layers, and prefetches its recordings into the download cache.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/dataset"
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+)
+
+func main() {
+	importDir := flag.String("import", "", "Dataset directory to import into a manifest by its file naming convention")
+	output := flag.String("output", "", "Manifest path for --import (default: <import dir>/manifest.json)")
+	name := flag.String("name", "", "Manifest name for --import (default: the directory name)")
+	manifestPath := flag.String("manifest", "", "Manifest to list (or prefetch with --fetch)")
+	notesOnly := flag.Bool("notes", false, "With --manifest, print only the space-separated notes (for shell loops over the keyboard)")
+	fetch := flag.Bool("fetch", false, "With --manifest, download every recording into the cache")
+	cacheDir := flag.String("cache", dataset.DefaultCacheDir(), "Download cache for remote recordings")
+	fitcommon.ParseFlags()
+
+	switch {
+	case *importDir != "":
+		m, err := dataset.Import(*importDir)
+		if err != nil {
+			die("import failed: %v", err)
+		}
+		if *name != "" {
+			m.Name = *name
+		}
+		path := *output
+		if path == "" {
+			path = filepath.Join(*importDir, "manifest.json")
+		}
+		if err := m.Save(path); err != nil {
+			die("failed to write %s: %v", path, err)
+		}
+		fmt.Printf("Wrote %s: %d recordings of %d notes (%s)\n", path, len(m.Entries), len(m.Notes()), m.Description)
+	case *manifestPath != "":
+		m, err := dataset.Load(*manifestPath)
+		if err != nil {
+			die("failed to load manifest: %v", err)
+		}
+		if *notesOnly {
+			notes := make([]string, 0, len(m.Notes()))
+			for _, n := range m.Notes() {
+				notes = append(notes, fmt.Sprint(n))
+			}
+			fmt.Println(strings.Join(notes, " "))
+			return
+		}
+		if *fetch {
+			f := &dataset.Fetcher{CacheDir: *cacheDir}
+			if _, err := f.FetchAll(context.Background(), m, m.Entries); err != nil {
+				die("fetch failed: %v", err)
+			}
+		}
+		printLayers(m)
+	default:
+		die("one of --import or --manifest is required")
+	}
+}
+
+// printLayers lists each note's velocity layers with their take counts,
+// pedal recordings marked "P" and unknown velocities "?".
+func printLayers(m *dataset.Manifest) {
+	fmt.Printf("%s: %d recordings of %d notes\n", m.Name, len(m.Entries), len(m.Notes()))
+	for _, note := range m.Notes() {
+		var layers []string
+		takes := make(map[string]int)
+		for _, e := range m.Entries {
+			if e.Note != note {
+				continue
+			}
+			layer := fmt.Sprint(e.Velocity)
+			if e.Velocity == 0 {
+				layer = "?"
+			}
+			if e.Pedal {
+				layer += "P"
+			}
+			if takes[layer] == 0 {
+				layers = append(layers, layer)
+			}
+			takes[layer]++
+		}
+		for i, layer := range layers {
+			if n := takes[layer]; n > 1 {
+				layers[i] = fmt.Sprintf("%s×%d", layer, n)
+			}
+		}
+		fmt.Printf("  %3d: %s\n", note, strings.Join(layers, " "))
+	}
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"math"
@@ -10,8 +9,10 @@ import (
 
 	dspresample "github.com/cwbudde/algo-dsp/dsp/resample"
 	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
 	"github.com/cwbudde/algo-piano/piano"
 	"github.com/cwbudde/algo-piano/preset"
+	"github.com/cwbudde/algo-piano/render"
 	"github.com/cwbudde/wav"
 	"github.com/go-audio/audio"
 )
@@ -19,7 +20,7 @@ import (
 func main() {
 	referencePath := flag.String("reference", "reference/c4.wav", "Reference WAV path")
 	candidatePath := flag.String("candidate", "", "Candidate WAV path; if empty, render candidate from piano model")
-	presetPath := flag.String("preset", "assets/presets/default.json", "Preset JSON path for rendered candidate")
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON path for rendered candidate")
 	note := flag.Int("note", 60, "MIDI note for rendered candidate")
 	velocity := flag.Int("velocity", 100, "MIDI velocity for rendered candidate")
 	sampleRate := flag.Int("sample-rate", 48000, "Analysis sample rate in Hz")
@@ -28,9 +29,14 @@ func main() {
 	minDuration := flag.Float64("min-duration", 2.0, "Minimum rendered duration in seconds")
 	maxDuration := flag.Float64("max-duration", 30.0, "Maximum rendered duration in seconds")
 	releaseAfter := flag.Float64("release-after", 2.0, "Note hold time before NoteOff for rendered candidate")
+	stopOnInactive := flag.Bool("stop-on-inactive", false, "Stop the rendered candidate once no voices are active instead of on the dBFS threshold")
 	writeCandidate := flag.String("write-candidate", "", "Optional path to write rendered candidate WAV")
+	gainMatch := flag.Bool("gain-match", false, "Match the candidate's slowly-varying level trajectory to the reference before metering")
+	gainWindow := flag.Float64("gain-window", 0.1, "Gain-match RMS window in seconds")
+	gainSmoothness := flag.Float64("gain-smoothness", 10.0, "Gain-match smoothness penalty (larger = flatter gain track)")
+	releaseWeight := flag.Float64("release-weight", 0.0, "Blend weight in [0,1] of the release-window error in the score")
 	jsonOut := flag.Bool("json", false, "Print metrics as JSON")
-	flag.Parse()
+	fitcommon.ParseFlags()
 
 	ref, refSR, err := readWAVMono(*referencePath)
 	if err != nil {
@@ -56,11 +62,14 @@ func main() {
 			*presetPath,
 			*note,
 			*velocity,
-			*sampleRate,
-			*decayDBFS,
-			*decayHoldBlocks,
-			*minDuration,
-			*maxDuration,
+			render.AutoStopConfig{
+				SampleRate:  *sampleRate,
+				DecayDBFS:   *decayDBFS,
+				HoldBlocks:  *decayHoldBlocks,
+				MinDuration: *minDuration,
+				MaxDuration: *maxDuration,
+				Mode:        stopMode(*stopOnInactive),
+			},
 			*releaseAfter,
 		)
 		if err != nil {
@@ -74,7 +83,12 @@ func main() {
 		}
 	}
 
-	metrics := analysis.Compare(ref, cand, *sampleRate)
+	metrics := analysis.CompareWithOptions(ref, cand, *sampleRate, analysis.CompareOptions{
+		GainMatch:      *gainMatch,
+		GainWindowSec:  *gainWindow,
+		GainSmoothness: *gainSmoothness,
+		ReleaseWeight:  *releaseWeight,
+	})
 	if *jsonOut {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
@@ -87,7 +101,16 @@ func main() {
 	fmt.Printf("Reference frames: %d\n", metrics.ReferenceFrames)
 	fmt.Printf("Candidate frames: %d\n", metrics.CandidateFrames)
 	fmt.Printf("Aligned frames:   %d\n", metrics.AlignedFrames)
-	fmt.Printf("Lag:              %d samples (%.3f ms)\n", metrics.LagSamples, 1000.0*float64(metrics.LagSamples)/float64(metrics.SampleRate))
+	lag := float64(metrics.LagSamples) + metrics.LagFraction
+	fmt.Printf("Lag:              %.2f samples (%.3f ms)\n", lag, 1000.0*lag/float64(metrics.SampleRate))
+	if len(metrics.GainTrackDB) > 0 {
+		lo, hi := metrics.GainTrackDB[0], metrics.GainTrackDB[0]
+		for _, g := range metrics.GainTrackDB {
+			lo = math.Min(lo, g)
+			hi = math.Max(hi, g)
+		}
+		fmt.Printf("Gain track:       %d windows @ %.0f ms, %.2f..%.2f dB\n", len(metrics.GainTrackDB), 1000.0*metrics.GainTrackHopSec, lo, hi)
+	}
 	fmt.Println()
 	fmt.Printf("Component        Raw          Norm   Weight  Contribution\n")
 	fmt.Printf("─────────────────────────────────────────────────────────\n")
@@ -99,15 +122,28 @@ func main() {
 		}
 		fmt.Printf("%-16s %-12s %5.1f%%  ×%.2f   → %.4f%s\n", name, raw, norm*100, weight, contrib, marker)
 	}
-	printComp("Time RMSE", fmt.Sprintf("%.6f", metrics.TimeRMSE), metrics.TimeNorm, analysis.WeightTime, metrics.Dominant == "time")
-	printComp("Envelope RMSE", fmt.Sprintf("%.1f dB", metrics.EnvelopeRMSEDB), metrics.EnvelopeNorm, analysis.WeightEnvelope, metrics.Dominant == "envelope")
-	printComp("Spectral RMSE", fmt.Sprintf("%.1f dB", metrics.SpectralRMSEDB), metrics.SpectralNorm, analysis.WeightSpectral, metrics.Dominant == "spectral")
-	printComp("Decay diff", fmt.Sprintf("%.1f dB/s", metrics.DecayDiffDBPerS), metrics.DecayNorm, analysis.WeightDecay, metrics.Dominant == "decay")
+	// The release term, when weighted, scales the base components down.
+	rw := 0.0
+	if metrics.ReleaseDetected {
+		rw = math.Max(0, math.Min(1, *releaseWeight))
+	}
+	bw := 1 - rw
+	printComp("Time RMSE", fmt.Sprintf("%.6f", metrics.TimeRMSE), metrics.TimeNorm, bw*analysis.WeightTime, metrics.Dominant == "time")
+	printComp("Envelope RMSE", fmt.Sprintf("%.1f dB", metrics.EnvelopeRMSEDB), metrics.EnvelopeNorm, bw*analysis.WeightEnvelope, metrics.Dominant == "envelope")
+	printComp("Spectral RMSE", fmt.Sprintf("%.1f dB", metrics.SpectralRMSEDB), metrics.SpectralNorm, bw*analysis.WeightSpectral, metrics.Dominant == "spectral")
+	printComp("Decay diff", fmt.Sprintf("%.1f dB/s", metrics.DecayDiffDBPerS), metrics.DecayNorm, bw*analysis.WeightDecay, metrics.Dominant == "decay")
+	if rw > 0 {
+		printComp("Release", fmt.Sprintf("%.1f dB", metrics.ReleaseEnvelopeRMSEDB+metrics.ReleaseResidualDiffDB), metrics.ReleaseNorm, rw, metrics.Dominant == "release")
+	}
 	fmt.Printf("─────────────────────────────────────────────────────────\n")
 	fmt.Printf("Score:            %.4f  (0 best, 1 worst)\n", metrics.Score)
 	fmt.Printf("Similarity:       %.2f%%\n", metrics.Similarity*100.0)
 	fmt.Printf("Dominant factor:  %s\n", metrics.Dominant)
 	fmt.Printf("\nDecay slopes: ref=%.1f dB/s  cand=%.1f dB/s\n", metrics.RefDecayDBPerS, metrics.CandDecayDBPerS)
+	if metrics.ReleaseDetected {
+		fmt.Printf("\nRelease window:   at %.3f s  envelope=%.1f dB  residual diff=%.1f dB  fall=%.0f ms (diff %.0f ms)\n",
+			metrics.ReleaseTimeSec, metrics.ReleaseEnvelopeRMSEDB, metrics.ReleaseResidualDiffDB, metrics.ReleaseFallMs, metrics.ReleaseFallDiffMs)
+	}
 	fmt.Printf("\nSpectral bands:   low(0-500Hz)=%.1f dB  mid(500-2k)=%.1f dB  high(2k+)=%.1f dB\n",
 		metrics.SpectralLowRMSEDB, metrics.SpectralMidRMSEDB, metrics.SpectralHighRMSEDB)
 }
@@ -116,11 +152,7 @@ func renderCandidate(
 	presetPath string,
 	note int,
 	velocity int,
-	sampleRate int,
-	decayDBFS float64,
-	decayHoldBlocks int,
-	minDuration float64,
-	maxDuration float64,
+	stopCfg render.AutoStopConfig,
 	releaseAfter float64,
 ) ([]float32, []float64, error) {
 	params, err := preset.LoadJSON(presetPath)
@@ -130,66 +162,44 @@ func renderCandidate(
 	if params.IRWavPath == "" {
 		params.IRWavPath = piano.DefaultIRWavPath
 	}
+	stop, err := render.NewAutoStopper(stopCfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	p := piano.NewPiano(sampleRate, 16, params)
+	p := piano.NewPiano(stopCfg.SampleRate, 16, params)
 	p.NoteOn(note, velocity)
 
-	if decayHoldBlocks < 1 {
-		decayHoldBlocks = 1
-	}
-	if minDuration < 0 {
-		minDuration = 0
-	}
-	if maxDuration < minDuration {
-		maxDuration = minDuration
-	}
-
-	minFrames := int(float64(sampleRate) * minDuration)
-	maxFrames := int(float64(sampleRate) * maxDuration)
-	releaseAtFrame := int(float64(sampleRate) * releaseAfter)
+	releaseAtFrame := int(float64(stopCfg.SampleRate) * releaseAfter)
 	if releaseAtFrame < 0 {
 		releaseAtFrame = 0
 	}
-	if maxFrames < 1 {
-		return nil, nil, errors.New("max duration too small")
-	}
 
-	threshold := math.Pow(10.0, decayDBFS/20.0)
-	blockSize := 128
-	framesRendered := 0
-	belowCount := 0
+	const blockSize = 128
 	noteReleased := false
-	stereo := make([]float32, 0, maxFrames*2)
+	stereo := make([]float32, 0, stop.MaxFrames()*2)
 
-	for framesRendered < maxFrames {
-		framesToRender := blockSize
-		if framesRendered+framesToRender > maxFrames {
-			framesToRender = maxFrames - framesRendered
-		}
-		if !noteReleased && framesRendered >= releaseAtFrame {
+	for !stop.Done() {
+		if !noteReleased && stop.Rendered() >= releaseAtFrame {
 			p.NoteOff(note)
 			noteReleased = true
 		}
-		block := p.Process(framesToRender)
+		block := p.Process(stop.NextBlock(blockSize))
 		stereo = append(stereo, block...)
-		framesRendered += framesToRender
-
-		if framesRendered >= minFrames {
-			if stereoRMS(block) < threshold {
-				belowCount++
-				if belowCount >= decayHoldBlocks {
-					break
-				}
-			} else {
-				belowCount = 0
-			}
-		}
+		stop.Observe(block, p.ActiveVoices())
 	}
 
 	mono := stereoToMono64(stereo)
 	return stereo, mono, nil
 }
 
+func stopMode(onInactive bool) render.StopMode {
+	if onInactive {
+		return render.StopOnVoiceInactive
+	}
+	return render.StopOnDecay
+}
+
 func readWAVMono(path string) ([]float64, int, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -269,18 +279,6 @@ func stereoToMono64(st []float32) []float64 {
 	return out
 }
 
-func stereoRMS(interleaved []float32) float64 {
-	if len(interleaved) == 0 {
-		return 0
-	}
-	var sum float64
-	for _, s := range interleaved {
-		v := float64(s)
-		sum += v * v
-	}
-	return math.Sqrt(sum / float64(len(interleaved)))
-}
-
 func die(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
 	os.Exit(1)
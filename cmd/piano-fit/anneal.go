@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// annealedRestarts re-seeds Mayfly rounds around the best candidate once
+// the search has plateaued (--restart-after). Independent rounds differ only
+// in their random seed, so late in a run they rarely find anything the best
+// has not; instead, after `after` rounds in a row finished without an
+// improvement, the next round starts with a fraction of its males drawn
+// from a Gaussian around the best position. The standard deviation, in
+// normalized knob units, is the temperature: it is multiplied by cooling at
+// every restart, so early restarts jump to neighbouring basins and later
+// ones search ever closer to the best.
+type annealedRestarts struct {
+	after    int
+	fraction float64
+	temp     float64 // temperature of the next restart
+	cooling  float64
+	rng      *rand.Rand
+
+	stale    int   // rounds finished without improvement in a row
+	improves int64 // improvement count when the last round finished
+	count    int   // restarts so far
+}
+
+// minRestartTemp keeps cooled restarts from collapsing onto the best
+// position itself.
+const minRestartTemp = 1e-3
+
+// newAnnealedRestarts returns nil (no restarts) when after <= 0.
+func newAnnealedRestarts(after int, fraction, temp, cooling float64, seed int64) *annealedRestarts {
+	if after <= 0 {
+		return nil
+	}
+	return &annealedRestarts{
+		after:    after,
+		fraction: fraction,
+		temp:     temp,
+		cooling:  cooling,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// roundDone records a finished round, given the number of improvements of
+// the best candidate so far.
+func (a *annealedRestarts) roundDone(improves int64) {
+	if a == nil {
+		return
+	}
+	if improves != a.improves {
+		a.improves = improves
+		a.stale = 0
+		return
+	}
+	a.stale++
+}
+
+// seeds returns the starting males of a round about to begin when a
+// restart is due, and the temperature they were drawn at: perturbations of
+// the normalized best position restricted to the active knobs (see
+// knobFreezer.space). It returns nil otherwise.
+func (a *annealedRestarts) seeds(best []float64, active []int, pop int) ([][]float64, float64) {
+	if a == nil || a.stale < a.after {
+		return nil, 0
+	}
+	a.stale = 0
+	a.count++
+	temp := a.temp
+	a.temp = max(a.temp*a.cooling, minRestartTemp)
+
+	n := max(1, int(math.Round(a.fraction*float64(pop))))
+	out := make([][]float64, n)
+	for i := range out {
+		pos := make([]float64, len(active))
+		for k, d := range active {
+			v := best[d] + temp*a.rng.NormFloat64()
+			// Multiples of 2^-53 in [0,1) replay exactly through seededSource.
+			pos[k] = math.Floor(clamp(v, 0, 1)*(1<<53)) / (1 << 53)
+			if pos[k] >= 1 {
+				pos[k] = 1 - 1.0/(1<<53)
+			}
+		}
+		out[i] = pos
+	}
+	return out, temp
+}
+
+// restarts returns the number of restarts so far.
+func (a *annealedRestarts) restarts() int {
+	if a == nil {
+		return 0
+	}
+	return a.count
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestAnnealedRestartsWaitForPlateau(t *testing.T) {
+	a := newAnnealedRestarts(2, 0.5, 0.2, 0.5, 1)
+	best := []float64{0.5, 0.5, 0.5}
+	active := []int{0, 1, 2}
+	a.roundDone(1) // improved
+	a.roundDone(1)
+	if s, _ := a.seeds(best, active, 10); s != nil {
+		t.Fatal("restart after one stale round, want two")
+	}
+	a.roundDone(2) // improved again: the count restarts
+	a.roundDone(2)
+	if s, _ := a.seeds(best, active, 10); s != nil {
+		t.Fatal("an improvement did not reset the stale rounds")
+	}
+	a.roundDone(2)
+	s, temp := a.seeds(best, active, 10)
+	if len(s) != 5 || temp != 0.2 {
+		t.Fatalf("restart gave %d seeds at %g, want 5 at 0.2", len(s), temp)
+	}
+	if again, _ := a.seeds(best, active, 10); again != nil {
+		t.Fatal("one plateau restarted two rounds")
+	}
+	a.roundDone(2)
+	a.roundDone(2)
+	if _, temp := a.seeds(best, active, 10); temp != 0.1 {
+		t.Fatalf("second restart at %g, want the cooled 0.1", temp)
+	}
+	if a.restarts() != 2 {
+		t.Fatalf("restarts = %d", a.restarts())
+	}
+	if newAnnealedRestarts(0, 0.5, 0.2, 0.5, 1) != nil {
+		t.Fatal("restart-after 0 does not turn restarts off")
+	}
+}
+
+func TestAnnealedRestartSeedsSurroundBest(t *testing.T) {
+	best := []float64{0.3, 0.99, 0.7}
+	spread := func(temp float64) float64 {
+		a := newAnnealedRestarts(1, 1, temp, 1, 7)
+		a.roundDone(0)
+		s, _ := a.seeds(best, []int{0, 2}, 200)
+		sum := 0.0
+		for _, pos := range s {
+			if len(pos) != 2 {
+				t.Fatalf("seed %v not in the space of the two active knobs", pos)
+			}
+			for k, d := range []int{0, 2} {
+				if pos[k] < 0 || pos[k] >= 1 || pos[k] != math.Floor(pos[k]*(1<<53))/(1<<53) {
+					t.Fatalf("coordinate %v cannot be replayed by seededSource", pos[k])
+				}
+				sum += math.Abs(pos[k] - best[d])
+			}
+		}
+		return sum / float64(2*len(s))
+	}
+	hot, cold := spread(0.2), spread(0.02)
+	// Mean absolute deviation of a Gaussian is 0.8 sigma.
+	if math.Abs(hot-0.16) > 0.03 || math.Abs(cold-0.016) > 0.003 {
+		t.Fatalf("mean distance from the best %g at 0.2, %g at 0.02", hot, cold)
+	}
+}
+
+func TestAnnealedRestartSeedsStartMayflyRound(t *testing.T) {
+	a := newAnnealedRestarts(1, 0.5, 0.1, 0.5, 3)
+	a.roundDone(0)
+	restart, _ := a.seeds([]float64{0.2, 0.8}, []int{0, 1}, 10)
+	var seeds []designPoint
+	for _, pos := range restart {
+		seeds = append(seeds, designPoint{pos: pos})
+	}
+	cfg, err := newMayflyConfig("desma", 10, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Rand = rand.New(newSeededSource(seeds, rand.NewSource(4)))
+	var calls [][]float64
+	cfg.ObjectiveFunc = func(pos []float64) float64 {
+		calls = append(calls, slices.Clone(pos))
+		return pos[0]
+	}
+	if _, err := runMayfly(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range seeds {
+		if !slices.Equal(calls[i], s.pos) {
+			t.Fatalf("call %d evaluated %v, want restart seed %v", i, calls[i], s.pos)
+		}
+	}
+}
+
+func TestRunOptimizationRestartsPlateauedSearch(t *testing.T) {
+	const sampleRate = 16000
+	params := piano.NewDefaultParams()
+	params.IRWavPath = ""
+	notes := singleNote(60)
+	// The initial candidate renders the reference itself, so no round can
+	// improve on it.
+	ref, _, err := renderCandidateFromParams(nil, params, notes, 100, sampleRate, -200, 1, 0.2, 0.2, 128, 0.1, noPedal)
+	if err != nil {
+		t.Fatalf("render reference: %v", err)
+	}
+	groups := map[string]bool{"mix": true}
+	defs, init := initCandidate(params, sampleRate, 60, 100, 0.1, groups)
+	cfg := &optimizationConfig{
+		references:       [][]float64{ref},
+		finalReferences:  [][]float64{ref},
+		baseParams:       params,
+		defs:             defs,
+		initCandidate:    init,
+		note:             60,
+		baseVelocity:     100,
+		baseReleaseAfter: 0.1,
+		sampleRate:       sampleRate,
+		finalSampleRate:  sampleRate,
+		timeBudget:       60,
+		maxEvals:         80,
+		decayDBFS:        -200,
+		decayHoldBlocks:  1,
+		minDuration:      0.2,
+		maxDuration:      0.2,
+		finalMinDuration: 0.2,
+		finalMaxDuration: 0.2,
+		renderBlockSize:  128,
+		refineTopK:       1,
+		restartAfter:     1,
+		restartFraction:  0.5,
+		restartTemp:      0.2,
+		restartCooling:   0.5,
+		mayflyVariant:    "ma",
+		mayflyPop:        2,
+		mayflyRoundEvals: 8,
+		workers:          1,
+		topK:             1,
+		groups:           groups,
+		workDir:          t.TempDir(),
+		outputPreset:     filepath.Join(t.TempDir(), "fitted.json"),
+		notes:            notes,
+		pedal:            noPedal,
+	}
+	res, err := runOptimization(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("runOptimization: %v", err)
+	}
+	if res.restarts == 0 {
+		t.Fatalf("no restart in %d evaluations without improvement", res.evals)
+	}
+}
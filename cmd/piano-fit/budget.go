@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// roundPlanner sizes the Mayfly rounds of runOptimization from measured
+// costs: the wall time of one evaluation and the objective calls a round
+// makes per iteration (initialization and offspring included, which varies
+// by variant). Rounds shrink towards the end of the time budget so the last
+// one completes instead of being cut off mid-iteration, and the search stops
+// early enough to leave the refinement of the best candidates its time.
+type roundPlanner struct {
+	mu         sync.Mutex
+	evalTime   time.Duration // summed wall time of measured evaluations
+	evals      int
+	roundCalls int // objective calls of finished rounds
+	roundIters int // iterations of finished rounds, plus one each for initialization
+
+	pop           int
+	maxRoundEvals int
+	refineEvals   int
+	finalScale    float64 // cost of a final-settings evaluation relative to a search one
+}
+
+func newRoundPlanner(cfg *optimizationConfig) *roundPlanner {
+	// Render cost grows with the number of rendered samples.
+	scale := 1.0
+	if opt := float64(cfg.sampleRate) * cfg.maxDuration; opt > 0 {
+		scale = float64(cfg.finalSampleRate) * cfg.finalMaxDuration / opt
+	}
+	return &roundPlanner{
+		pop:           cfg.mayflyPop,
+		maxRoundEvals: cfg.mayflyRoundEvals,
+		refineEvals:   cfg.refineTopK,
+		finalScale:    scale,
+	}
+}
+
+// observeEval records the wall time of one evaluation.
+func (p *roundPlanner) observeEval(d time.Duration) {
+	p.mu.Lock()
+	p.evalTime += d
+	p.evals++
+	p.mu.Unlock()
+}
+
+// observeRound records the objective calls of a finished round of iters
+// iterations.
+func (p *roundPlanner) observeRound(iters, calls int) {
+	p.mu.Lock()
+	p.roundCalls += calls
+	p.roundIters += iters + 1
+	p.mu.Unlock()
+}
+
+// evalCost returns the mean wall time of one evaluation.
+func (p *roundPlanner) evalCost() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.evals == 0 {
+		return 0
+	}
+	return p.evalTime / time.Duration(p.evals)
+}
+
+// callsPerIter returns the measured objective calls per round iteration,
+// or twice the population before the first round has finished.
+func (p *roundPlanner) callsPerIter() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.roundIters == 0 {
+		return float64(2 * p.pop)
+	}
+	return float64(p.roundCalls) / float64(p.roundIters)
+}
+
+// refineReserve returns the time kept back from the search for the
+// refinement evaluations at the final settings, at most half of budget.
+func (p *roundPlanner) refineReserve(budget time.Duration) time.Duration {
+	reserve := time.Duration(float64(p.evalCost()) * p.finalScale * float64(p.refineEvals))
+	return min(reserve, budget/2)
+}
+
+// plan returns the iterations of the next round for a worker with
+// remaining search time, or false when not even a one-iteration round fits
+// into it. Rounds are also sized to remainingEvals, but the evaluation cap
+// never stops a round: calls past it return without rendering.
+func (p *roundPlanner) plan(remaining time.Duration, remainingEvals int) (iters int, ok bool) {
+	perIter := p.callsPerIter()
+	// One iteration's worth of calls goes to initialization.
+	iters = maxInt(1, int(float64(minInt(p.maxRoundEvals, remainingEvals))/perIter)-1)
+	if cost := p.evalCost(); cost > 0 {
+		fit := int(float64(remaining/cost)/perIter) - 1
+		if fit < 1 {
+			return 0, false
+		}
+		iters = minInt(iters, fit)
+	}
+	return iters, true
+}
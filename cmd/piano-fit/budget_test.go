@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundPlannerFitsRoundsIntoRemainingTime(t *testing.T) {
+	p := &roundPlanner{pop: 4, maxRoundEvals: 240, refineEvals: 1, finalScale: 1}
+	if iters, ok := p.plan(time.Hour, 1000); !ok || iters != 29 {
+		t.Fatalf("unmeasured plan = %d, %v; want 2*pop calls per iteration (29)", iters, ok)
+	}
+
+	p.observeEval(10 * time.Millisecond)
+	p.observeRound(2, 60) // 20 calls per iteration, initialization included
+	tests := []struct {
+		remaining time.Duration
+		evals     int
+		want      int
+		ok        bool
+	}{
+		{time.Hour, 1000, 11, true},             // round cap: 240 calls
+		{time.Second, 1000, 4, true},            // 100 affordable calls
+		{30 * time.Millisecond, 1000, 0, false}, // not even one iteration
+		{time.Hour, 30, 1, true},                // the eval cap never stops a round
+	}
+	for _, tt := range tests {
+		iters, ok := p.plan(tt.remaining, tt.evals)
+		if iters != tt.want || ok != tt.ok {
+			t.Fatalf("plan(%v, %d) = %d, %v; want %d, %v", tt.remaining, tt.evals, iters, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRoundPlannerRefineReserve(t *testing.T) {
+	p := &roundPlanner{pop: 4, maxRoundEvals: 240, refineEvals: 3, finalScale: 4}
+	if got := p.refineReserve(time.Minute); got != 0 {
+		t.Fatalf("reserve before any evaluation = %v, want 0", got)
+	}
+	p.observeEval(10 * time.Millisecond)
+	p.observeEval(30 * time.Millisecond)
+	if got, want := p.refineReserve(time.Minute), 240*time.Millisecond; got != want {
+		t.Fatalf("reserve = %v, want %v (3 evals at 4x the 20 ms search cost)", got, want)
+	}
+	if got, want := p.refineReserve(100*time.Millisecond), 50*time.Millisecond; got != want {
+		t.Fatalf("reserve = %v, want it capped at half the budget (%v)", got, want)
+	}
+}
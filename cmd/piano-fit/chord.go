@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxChordNotes bounds --notes-chord; references are intervals or triads,
+// with one spare slot for added-tone chords.
+const maxChordNotes = 4
+
+// chordNote is one struck note of the rendered reference scenario.
+type chordNote struct {
+	Note  int     `json:"note"`
+	Onset float64 `json:"onset_seconds"`
+}
+
+// parseChord parses --notes-chord (MIDI notes) and --chord-onsets (seconds,
+// relative to the render start). Empty onsets strike all notes together; a
+// single onset value is used as the spacing between consecutive notes. The
+// first listed note is the one whose per-note knobs are fitted.
+func parseChord(notesRaw string, onsetsRaw string) ([]chordNote, error) {
+	var notes []int
+	for _, s := range strings.Split(notesRaw, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid note %q", s)
+		}
+		if n < 0 || n > 127 {
+			return nil, fmt.Errorf("note %d out of MIDI range", n)
+		}
+		for _, prev := range notes {
+			if prev == n {
+				return nil, fmt.Errorf("duplicate note %d", n)
+			}
+		}
+		notes = append(notes, n)
+	}
+	if len(notes) < 2 || len(notes) > maxChordNotes {
+		return nil, fmt.Errorf("chord needs 2..%d notes, got %d", maxChordNotes, len(notes))
+	}
+
+	var onsets []float64
+	for _, s := range strings.Split(onsetsRaw, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid onset %q", s)
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("onset must be >= 0, got %g", v)
+		}
+		onsets = append(onsets, v)
+	}
+
+	chord := make([]chordNote, len(notes))
+	for i, n := range notes {
+		chord[i].Note = n
+		switch {
+		case len(onsets) == 0:
+		case len(onsets) == 1:
+			chord[i].Onset = float64(i) * onsets[0]
+		case len(onsets) == len(notes):
+			chord[i].Onset = onsets[i]
+		default:
+			return nil, fmt.Errorf("got %d onsets for %d notes", len(onsets), len(notes))
+		}
+	}
+	return chord, nil
+}
+
+// singleNote is the render scenario for a plain one-note reference.
+func singleNote(note int) []chordNote {
+	return []chordNote{{Note: note}}
+}
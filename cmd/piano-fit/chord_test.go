@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestParseChord(t *testing.T) {
+	tests := []struct {
+		name    string
+		notes   string
+		onsets  string
+		want    []chordNote
+		wantErr bool
+	}{
+		{
+			name:  "simultaneous triad",
+			notes: "60,64,67",
+			want:  []chordNote{{Note: 60}, {Note: 64}, {Note: 67}},
+		},
+		{
+			name:   "per-note onsets",
+			notes:  "60, 67",
+			onsets: "0.01, 0",
+			want:   []chordNote{{Note: 60, Onset: 0.01}, {Note: 67}},
+		},
+		{
+			name:   "spacing onset",
+			notes:  "48,55,64",
+			onsets: "0.02",
+			want:   []chordNote{{Note: 48}, {Note: 55, Onset: 0.02}, {Note: 64, Onset: 0.04}},
+		},
+		{name: "single note", notes: "60", wantErr: true},
+		{name: "too many notes", notes: "60,62,64,65,67", wantErr: true},
+		{name: "duplicate", notes: "60,60", wantErr: true},
+		{name: "out of range", notes: "60,128", wantErr: true},
+		{name: "onset count mismatch", notes: "60,64,67", onsets: "0,0.1", wantErr: true},
+		{name: "negative onset", notes: "60,64", onsets: "-0.1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChord(tt.notes, tt.onsets)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseChord(%q, %q) expected error", tt.notes, tt.onsets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseChord(%q, %q) unexpected error: %v", tt.notes, tt.onsets, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d notes, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].Note != tt.want[i].Note || math.Abs(got[i].Onset-tt.want[i].Onset) > 1e-12 {
+					t.Fatalf("note %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderPianoChordOnsetsAreSampleAccurate(t *testing.T) {
+	const sampleRate = 48000
+	params := piano.NewDefaultParams()
+	params.IRWavPath = ""
+	// The first onset is not block aligned; the second lands exactly seven
+	// blocks later so both renders share block boundaries up to it.
+	first := 480
+	second := first + 7*128
+	notes := []chordNote{
+		{Note: 60, Onset: float64(first) / sampleRate},
+		{Note: 67, Onset: float64(second) / sampleRate},
+	}
+
+	single, _, err := renderCandidateFromParams(nil, params, notes[:1], 100, sampleRate, -200, 1, 0.1, 0.1, 128, 1.0, noPedal)
+	if err != nil {
+		t.Fatalf("render single: %v", err)
+	}
+	chord, _, err := renderCandidateFromParams(nil, params, notes, 100, sampleRate, -200, 1, 0.1, 0.1, 128, 1.0, noPedal)
+	if err != nil {
+		t.Fatalf("render chord: %v", err)
+	}
+
+	// The FFT convolver spreads float rounding noise (~1e-9) through the
+	// partition an onset falls in, so compare below -120 dBFS.
+	const eps = 1e-6
+	for i := 0; i < first; i++ {
+		if math.Abs(chord[i]) > eps {
+			t.Fatalf("sample %d non-zero before first onset", i)
+		}
+	}
+	if chord[first+64] == 0 {
+		t.Fatalf("expected sound after first onset")
+	}
+	// Until the second onset the chord render equals the single-note render.
+	for i := 0; i < second; i++ {
+		if math.Abs(chord[i]-single[i]) > eps {
+			t.Fatalf("chord diverges from single note at %d, before second onset %d", i, second)
+		}
+	}
+	diverged := false
+	for i := second; i < second+256; i++ {
+		if math.Abs(chord[i]-single[i]) > eps {
+			diverged = true
+			break
+		}
+	}
+	if !diverged {
+		t.Fatalf("second note did not sound at its onset")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+// lhsTries is how many Latin hypercube designs latinHypercube draws to keep
+// the one whose closest pair of points is furthest apart (maximin).
+const lhsTries = 8
+
+// latinHypercube returns n points in [0,1)^dims such that every dimension
+// has exactly one point in each of its n equal strata. Coordinates are
+// multiples of 2^-53 so seededSource replays them exactly.
+func latinHypercube(n, dims int, rng *rand.Rand) [][]float64 {
+	if n <= 0 || dims <= 0 {
+		return nil
+	}
+	var best [][]float64
+	bestDist := -1.0
+	for range lhsTries {
+		design := make([][]float64, n)
+		for i := range design {
+			design[i] = make([]float64, dims)
+		}
+		for d := range dims {
+			for i, stratum := range rng.Perm(n) {
+				v := (float64(stratum) + rng.Float64()) / float64(n)
+				design[i][d] = math.Floor(v*(1<<53)) / (1 << 53)
+			}
+		}
+		if dist := minPairDistance(design); dist > bestDist {
+			best, bestDist = design, dist
+		}
+	}
+	return best
+}
+
+func minPairDistance(points [][]float64) float64 {
+	dist := math.Inf(1)
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			s := 0.0
+			for d := range points[i] {
+				diff := points[i][d] - points[j][d]
+				s += diff * diff
+			}
+			dist = min(dist, s)
+		}
+	}
+	return dist
+}
+
+// designPoint is an evaluated point of the initial design.
+type designPoint struct {
+	pos     []float64
+	metrics analysis.Metrics
+}
+
+// rankDesign returns the evaluated points (metrics[i] != nil) best first.
+func rankDesign(design [][]float64, metrics []*analysis.Metrics) []designPoint {
+	var ranked []designPoint
+	for i, m := range metrics {
+		if m != nil {
+			ranked = append(ranked, designPoint{pos: design[i], metrics: *m})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].metrics.Score < ranked[j].metrics.Score })
+	return ranked
+}
+
+// roundSeeds picks the design points that start a Mayfly round: up to half
+// of the male population, taken from the ranking at an offset that rotates
+// with the round, so the first round starts from the best points and later
+// rounds from other well-scoring regions.
+func roundSeeds(ranked []designPoint, pop, round int) []designPoint {
+	n := min(len(ranked), max(1, pop/2))
+	if n == 0 {
+		return nil
+	}
+	seeds := make([]designPoint, n)
+	for i := range seeds {
+		seeds[i] = ranked[((round-1)*n+i)%len(ranked)]
+	}
+	return seeds
+}
+
+// seededSource replays the coordinates of seeds as its first uniform draws
+// and then defers to src. Mayfly draws the initial male positions from
+// Config.Rand first, one coordinate at a time, so a round using it starts
+// with the seeds as its first males.
+type seededSource struct {
+	vals []float64
+	src  rand.Source
+}
+
+func newSeededSource(seeds []designPoint, src rand.Source) *seededSource {
+	s := &seededSource{src: src}
+	for _, p := range seeds {
+		s.vals = append(s.vals, p.pos...)
+	}
+	return s
+}
+
+func (s *seededSource) Int63() int64 {
+	if len(s.vals) == 0 {
+		return s.src.Int63()
+	}
+	v := s.vals[0]
+	s.vals = s.vals[1:]
+	return int64(v * (1 << 63))
+}
+
+func (s *seededSource) Seed(seed int64) {
+	s.vals = nil
+	s.src.Seed(seed)
+}
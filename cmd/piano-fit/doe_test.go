@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+func TestLatinHypercubeStratifiesEveryDimension(t *testing.T) {
+	const n, dims = 16, 5
+	design := latinHypercube(n, dims, rand.New(rand.NewSource(1)))
+	if len(design) != n {
+		t.Fatalf("got %d points, want %d", len(design), n)
+	}
+	for d := range dims {
+		seen := make([]bool, n)
+		for _, p := range design {
+			s := int(p[d] * n)
+			if p[d] < 0 || p[d] >= 1 || seen[s] {
+				t.Fatalf("dimension %d: %v is outside [0,1) or in a taken stratum", d, p[d])
+			}
+			seen[s] = true
+		}
+	}
+	if latinHypercube(0, dims, rand.New(rand.NewSource(1))) != nil {
+		t.Fatal("expected no design for n = 0")
+	}
+}
+
+func TestRoundSeedsRotateThroughRanking(t *testing.T) {
+	design := [][]float64{{0.1}, {0.2}, {0.3}, {0.4}, {0.5}}
+	scores := []float64{5, 1, 4, 2, 3}
+	metrics := make([]*analysis.Metrics, len(design))
+	for i, s := range scores {
+		if i != 4 { // last point was never evaluated
+			metrics[i] = &analysis.Metrics{Score: s}
+		}
+	}
+	ranked := rankDesign(design, metrics)
+	if len(ranked) != 4 || ranked[0].pos[0] != 0.2 || ranked[3].pos[0] != 0.1 {
+		t.Fatalf("ranked = %+v", ranked)
+	}
+	first := roundSeeds(ranked, 6, 1)
+	second := roundSeeds(ranked, 6, 2)
+	if len(first) != 3 || first[0].pos[0] != 0.2 || first[2].pos[0] != 0.3 {
+		t.Fatalf("round 1 seeds = %+v", first)
+	}
+	if len(second) != 3 || second[0].pos[0] != 0.1 || second[1].pos[0] != 0.2 {
+		t.Fatalf("round 2 seeds = %+v", second)
+	}
+	if roundSeeds(nil, 6, 1) != nil {
+		t.Fatal("expected no seeds without a design")
+	}
+}
+
+func TestSeededSourceStartsMayflyFromSeeds(t *testing.T) {
+	const dims = 3
+	seeds := make([]designPoint, 0, 4)
+	for _, p := range latinHypercube(4, dims, rand.New(rand.NewSource(2))) {
+		seeds = append(seeds, designPoint{pos: p})
+	}
+	cfg, err := newMayflyConfig("desma", 10, dims, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Rand = rand.New(newSeededSource(seeds, rand.NewSource(3)))
+	var calls [][]float64
+	cfg.ObjectiveFunc = func(pos []float64) float64 {
+		calls = append(calls, slices.Clone(pos))
+		return pos[0]
+	}
+	if _, err := runMayfly(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range seeds {
+		if !slices.Equal(calls[i], s.pos) {
+			t.Fatalf("call %d evaluated %v, want seed %v", i, calls[i], s.pos)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import "math"
+
+// frozenKnob records when --freeze-after froze a knob and at which value.
+type frozenKnob struct {
+	Name        string  `json:"name"`
+	Value       float64 `json:"value"`
+	Eval        int     `json:"eval"`
+	Improvement int     `json:"improvement"`
+	ElapsedSec  float64 `json:"elapsed_seconds"`
+}
+
+// knobFreezer shrinks the search once knobs have converged: a knob whose
+// best value stays within tol (a fraction of its normalized range) of where
+// it settled for after consecutive improvements is frozen at that value,
+// and later Mayfly rounds search only the remaining knobs. One knob always
+// stays free.
+type knobFreezer struct {
+	after  int
+	tol    float64
+	anchor []float64 // normalized value each free knob last settled at
+	stable []int     // improvements since it last moved by more than tol
+	frozen []bool
+	pos    []float64 // normalized best position; frozen knobs keep theirs
+	log    []frozenKnob
+}
+
+// newKnobFreezer returns nil (no freezing) when after <= 0 or there is
+// only one knob.
+func newKnobFreezer(defs []knobDef, init candidate, after int, tol float64) *knobFreezer {
+	if after <= 0 || len(defs) < 2 {
+		return nil
+	}
+	pos := toNormalized(init, defs)
+	return &knobFreezer{
+		after:  after,
+		tol:    tol,
+		anchor: append([]float64(nil), pos...),
+		stable: make([]int, len(defs)),
+		frozen: make([]bool, len(defs)),
+		pos:    pos,
+	}
+}
+
+// observe records a new best candidate and returns the knobs it froze.
+func (f *knobFreezer) observe(defs []knobDef, best candidate, eval, improvement int, elapsed float64) []frozenKnob {
+	if f == nil {
+		return nil
+	}
+	pos := toNormalized(best, defs)
+	free := 0
+	for _, fr := range f.frozen {
+		if !fr {
+			free++
+		}
+	}
+	var out []frozenKnob
+	for i := range pos {
+		if f.frozen[i] {
+			continue
+		}
+		f.pos[i] = pos[i]
+		if math.Abs(pos[i]-f.anchor[i]) > f.tol {
+			f.anchor[i] = pos[i]
+			f.stable[i] = 0
+			continue
+		}
+		f.stable[i]++
+		if f.stable[i] >= f.after && free > 1 {
+			f.frozen[i] = true
+			free--
+			k := frozenKnob{Name: defs[i].Name, Value: best.Vals[i], Eval: eval, Improvement: improvement, ElapsedSec: elapsed}
+			f.log = append(f.log, k)
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// space returns the indexes of the knobs a round searches and the
+// normalized position that fills in the frozen ones (nil when nothing is
+// frozen).
+func (f *knobFreezer) space(dims int) (active []int, base []float64) {
+	for i := 0; i < dims; i++ {
+		if f == nil || !f.frozen[i] {
+			active = append(active, i)
+		}
+	}
+	if len(active) == dims {
+		return active, nil
+	}
+	return active, append([]float64(nil), f.pos...)
+}
+
+// frozenKnobs returns the knobs frozen so far in freezing order.
+func (f *knobFreezer) frozenKnobs() []frozenKnob {
+	if f == nil {
+		return nil
+	}
+	return append([]frozenKnob(nil), f.log...)
+}
+
+// expandPosition maps a position in the reduced search space of space()
+// back to all knobs.
+func expandPosition(pos []float64, active []int, base []float64) []float64 {
+	if base == nil {
+		return pos
+	}
+	full := append([]float64(nil), base...)
+	for k, i := range active {
+		if k < len(pos) {
+			full[i] = pos[k]
+		}
+	}
+	return full
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func freezeDefs() []knobDef {
+	return []knobDef{
+		{Name: "a", Min: 0, Max: 1},
+		{Name: "b", Min: 0, Max: 10},
+		{Name: "c", Min: 0.01, Max: 100, LogScale: true},
+	}
+}
+
+func TestKnobFreezerFreezesStableKnobs(t *testing.T) {
+	defs := freezeDefs()
+	f := newKnobFreezer(defs, candidate{Vals: []float64{0.5, 5, 1}}, 3, 0.02)
+	var frozen []frozenKnob
+	for i := 1; i <= 3; i++ {
+		// a stays put, b wanders, c drifts within tolerance.
+		best := candidate{Vals: []float64{0.5 + 0.001*float64(i), 5 + float64(i), 1}}
+		frozen = append(frozen, f.observe(defs, best, 10*i, i, 0)...)
+	}
+	if len(frozen) != 2 || frozen[0].Name != "a" || frozen[1].Name != "c" {
+		t.Fatalf("frozen = %+v, want a and c", frozen)
+	}
+	if frozen[0].Eval != 30 || frozen[0].Improvement != 3 || frozen[0].Value != 0.503 {
+		t.Fatalf("frozen a = %+v", frozen[0])
+	}
+	active, base := f.space(len(defs))
+	if len(active) != 1 || active[0] != 1 || base == nil {
+		t.Fatalf("space = %v, %v; want only b active", active, base)
+	}
+	full := expandPosition([]float64{0.9}, active, base)
+	got := fromNormalized(full, defs)
+	if math.Abs(got.Vals[0]-0.503) > 1e-9 || math.Abs(got.Vals[1]-9) > 1e-9 || math.Abs(got.Vals[2]-1) > 1e-9 {
+		t.Fatalf("expanded candidate = %v", got.Vals)
+	}
+	if len(f.frozenKnobs()) != 2 {
+		t.Fatalf("frozenKnobs = %+v", f.frozenKnobs())
+	}
+}
+
+func TestKnobFreezerKeepsOneKnobFree(t *testing.T) {
+	defs := freezeDefs()
+	init := candidate{Vals: []float64{0.5, 5, 1}}
+	f := newKnobFreezer(defs, init, 1, 0.02)
+	if got := f.observe(defs, init, 1, 1, 0); len(got) != 2 {
+		t.Fatalf("froze %+v, want two of three knobs", got)
+	}
+	if got := f.observe(defs, init, 2, 2, 0); len(got) != 0 {
+		t.Fatalf("froze the last free knob: %+v", got)
+	}
+	if active, _ := f.space(len(defs)); len(active) != 1 {
+		t.Fatalf("active = %v", active)
+	}
+}
+
+func TestKnobFreezerDisabled(t *testing.T) {
+	defs := freezeDefs()
+	f := newKnobFreezer(defs, candidate{Vals: []float64{0.5, 5, 1}}, 0, 0.02)
+	if f != nil {
+		t.Fatal("expected nil freezer for --freeze-after 0")
+	}
+	if got := f.observe(defs, candidate{Vals: []float64{0.5, 5, 1}}, 1, 1, 0); got != nil {
+		t.Fatalf("nil freezer froze %+v", got)
+	}
+	active, base := f.space(len(defs))
+	if len(active) != len(defs) || base != nil {
+		t.Fatalf("space = %v, %v", active, base)
+	}
+	pos := []float64{0.1, 0.2, 0.3}
+	if got := expandPosition(pos, active, base); &got[0] != &pos[0] {
+		t.Fatal("expandPosition copied an unreduced position")
+	}
+}
+
+func TestToNormalizedInvertsFromNormalized(t *testing.T) {
+	defs := freezeDefs()
+	pos := []float64{0.25, 0.6, 0.75}
+	got := toNormalized(fromNormalized(pos, defs), defs)
+	for i := range pos {
+		if math.Abs(got[i]-pos[i]) > 1e-12 {
+			t.Fatalf("round trip = %v, want %v", got, pos)
+		}
+	}
+}
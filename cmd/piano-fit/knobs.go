@@ -5,6 +5,8 @@ import (
 	"math"
 	"strings"
 
+	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitknobs"
 	"github.com/cwbudde/algo-piano/irsynth"
 	"github.com/cwbudde/algo-piano/piano"
 )
@@ -27,9 +29,10 @@ type irConfigs struct {
 }
 
 // parseOptimizeGroups parses a comma-separated string of group names.
-// Valid groups: piano, body-ir, room-ir, mix.
+// Valid groups: piano, body-ir, room-ir, mix, eq, coupling, resonance,
+// register, hammer, damper, knock.
 func parseOptimizeGroups(raw string) (map[string]bool, error) {
-	valid := map[string]bool{"piano": true, "body-ir": true, "room-ir": true, "mix": true}
+	valid := map[string]bool{"piano": true, "body-ir": true, "room-ir": true, "mix": true, "eq": true, "coupling": true, "resonance": true, "register": true, "hammer": true, "damper": true, "knock": true}
 	groups := make(map[string]bool)
 	for _, s := range strings.Split(raw, ",") {
 		s = strings.TrimSpace(s)
@@ -37,7 +40,7 @@ func parseOptimizeGroups(raw string) (map[string]bool, error) {
 			continue
 		}
 		if !valid[s] {
-			return nil, fmt.Errorf("unknown optimize group %q (valid: piano, body-ir, room-ir, mix)", s)
+			return nil, fmt.Errorf("unknown optimize group %q (valid: piano, body-ir, room-ir, mix, eq, coupling, resonance, register, hammer, damper, knock)", s)
 		}
 		groups[s] = true
 	}
@@ -52,6 +55,59 @@ func needsIRSynthesis(groups map[string]bool) bool {
 	return groups["body-ir"] || groups["room-ir"]
 }
 
+// inharmonicityKnobMax is the upper bound of the per-note inharmonicity knob.
+const inharmonicityKnobMax = 0.6
+
+// seedInharmonicity sets the per-note inharmonicity of note in params to
+// the value at which its string model renders a measured stiffness
+// coefficient b at sampleRate, clamped to the knob range, so the fit
+// starts from the reference's stretch. The note's other per-note values
+// are kept; the returned value is the one set. ok is false, and params is
+// left alone, when the string model cannot reach b.
+func seedInharmonicity(params *piano.Params, sampleRate int, note int, b float64) (float32, bool) {
+	v, ok := piano.InharmonicityForB(sampleRate, params, note, b)
+	if !ok {
+		return 0, false
+	}
+	if params.PerNote == nil {
+		params.PerNote = make(map[int]*piano.NoteParams)
+	}
+	np := piano.NoteParams{Loss: 0.9990, StrikePosition: 0.18}
+	if cur := params.PerNote[note]; cur != nil {
+		np = *cur
+	}
+	np.Inharmonicity = min(v, inharmonicityKnobMax)
+	params.PerNote[note] = &np
+	return np.Inharmonicity, true
+}
+
+// knockBandKnobMin and knockBandKnobMax bound the hammer knock band gain
+// knobs in dB.
+const (
+	knockBandKnobMin = -40.0
+	knockBandKnobMax = 12.0
+)
+
+// seedKnock sets the hammer knock of params from the attack residual of a
+// reference played at velocity: the knock level is the residual's peak RMS
+// scaled to velocity 127 and taken back through the output gain, and its
+// envelope and band gains are the measured ones, clamped to the knob
+// ranges.
+func seedKnock(params *piano.Params, res analysis.AttackResidual, velocity int) {
+	level := res.PeakRMS * 127 / float64(max(velocity, 1))
+	if params.OutputGain > 0 {
+		level /= float64(params.OutputGain)
+	}
+	params.HammerKnockLevel = float32(clamp(level, 0.0001, 0.3))
+	params.HammerKnockAttackMs = float32(clamp(res.AttackMs, 0.2, 10))
+	params.HammerKnockDecayMs = float32(clamp(res.DecayMs, 5, 500))
+	for b := range params.HammerKnockBandsDB {
+		if b < len(res.BandsDB) {
+			params.HammerKnockBandsDB[b] = float32(clamp(res.BandsDB[b], knockBandKnobMin, knockBandKnobMax))
+		}
+	}
+}
+
 func initCandidate(
 	base *piano.Params,
 	sampleRate int,
@@ -62,8 +118,10 @@ func initCandidate(
 ) ([]knobDef, candidate) {
 	bodyCfg := irsynth.DefaultBodyConfig()
 	bodyCfg.SampleRate = sampleRate
+	bodyCfg.Seed = base.Seed
 	roomCfg := irsynth.DefaultRoomConfig()
 	roomCfg.SampleRate = sampleRate
+	roomCfg.Seed = base.Seed
 
 	np := base.PerNote[note]
 	if np == nil {
@@ -93,9 +151,9 @@ func initCandidate(
 		addKnob(knobDef{Name: "high_freq_damping", Min: 0.0, Max: 0.6}, float64(base.HighFreqDamping))
 		addKnob(knobDef{Name: "unison_detune_scale", Min: 0.0, Max: 2.0}, float64(base.UnisonDetuneScale))
 		addKnob(knobDef{Name: "unison_crossfeed", Min: 0.0, Max: 0.005}, float64(base.UnisonCrossfeed))
-		addKnob(knobDef{Name: fmt.Sprintf("per_note.%d.loss", note), Min: 0.985, Max: 0.99995}, float64(np.Loss))
-		addKnob(knobDef{Name: fmt.Sprintf("per_note.%d.inharmonicity", note), Min: 0.0, Max: 0.6}, float64(np.Inharmonicity))
-		addKnob(knobDef{Name: fmt.Sprintf("per_note.%d.strike_position", note), Min: 0.08, Max: 0.45}, float64(np.StrikePosition))
+		addKnob(knobDef{Name: fitknobs.PerNoteKnobName(note, "loss"), Min: 0.985, Max: 0.99995}, float64(np.Loss))
+		addKnob(knobDef{Name: fitknobs.PerNoteKnobName(note, "inharmonicity"), Min: 0.0, Max: inharmonicityKnobMax}, float64(np.Inharmonicity))
+		addKnob(knobDef{Name: fitknobs.PerNoteKnobName(note, "strike_position"), Min: 0.08, Max: 0.45}, float64(np.StrikePosition))
 		addKnob(knobDef{Name: "attack_noise_level", Min: 0.0, Max: 0.5}, float64(base.AttackNoiseLevel))
 		addKnob(knobDef{Name: "attack_noise_duration_ms", Min: 0.5, Max: 8.0}, float64(base.AttackNoiseDurationMs))
 		addKnob(knobDef{Name: "attack_noise_color", Min: -12.0, Max: 0.0}, float64(base.AttackNoiseColor))
@@ -145,6 +203,65 @@ func initCandidate(
 		}
 	}
 
+	// EQ group knobs: band gains of the output EQ. Presets without an EQ
+	// start from the neutral default layout.
+	if groups["eq"] {
+		for i, b := range outputEQBands(base) {
+			addKnob(knobDef{Name: fmt.Sprintf("eq.%d.gain_db", i), Min: -12, Max: 12}, float64(b.GainDB))
+		}
+	}
+
+	// Coupling group knobs: inter-string coupling strength. Only constrained
+	// by multi-note references (--notes-chord).
+	if groups["coupling"] {
+		addKnob(knobDef{Name: "coupling_amount", Min: 0.0, Max: 1.0}, float64(base.CouplingAmount))
+		addKnob(knobDef{Name: "coupling_octave_gain", Min: 0.0, Max: 0.001}, float64(base.CouplingOctaveGain))
+		addKnob(knobDef{Name: "coupling_fifth_gain", Min: 0.0, Max: 0.0005}, float64(base.CouplingFifthGain))
+		addKnob(knobDef{Name: "coupling_max_force", Min: 0.0001, Max: 0.002, LogScale: true}, float64(base.CouplingMaxForce))
+	}
+
+	// Hammer group knobs: the fitted note's own hammer scales on top of the
+	// global ones, e.g. to fit bass and treble hammers note by note.
+	if groups["hammer"] {
+		orOne := func(v float32) float64 {
+			if v <= 0 {
+				return 1
+			}
+			return float64(v)
+		}
+		addKnob(knobDef{Name: fitknobs.PerNoteKnobName(note, "hammer_stiffness_scale"), Min: 0.5, Max: 2.0}, orOne(np.HammerStiffnessScale))
+		addKnob(knobDef{Name: fitknobs.PerNoteKnobName(note, "hammer_exponent_scale"), Min: 0.85, Max: 1.15}, orOne(np.HammerExponentScale))
+		addKnob(knobDef{Name: fitknobs.PerNoteKnobName(note, "hammer_contact_time_scale"), Min: 0.6, Max: 1.6}, orOne(np.HammerContactTimeScale))
+	}
+
+	// Resonance group knobs: sympathetic resonance level and pedal bloom.
+	// Only constrained by pedal-down references (--sustain-pedal).
+	if groups["resonance"] {
+		addKnob(knobDef{Name: "resonance_gain", Min: 0.00001, Max: 0.002, LogScale: true}, float64(base.ResonanceGain))
+		addKnob(knobDef{Name: "resonance_attack_ms", Min: 0.0, Max: 300.0}, float64(base.ResonanceAttackMs))
+	}
+
+	// Damper group knobs: how fast and how firmly the dampers stop the
+	// strings at key-off. Only constrained by references with a release
+	// (weight it with --release-weight).
+	if groups["damper"] {
+		addKnob(knobDef{Name: "damper_engage_ms", Min: 0.5, Max: 80, LogScale: true}, float64(base.DamperEngageMs))
+		addKnob(knobDef{Name: "damper_engage_register_slope", Min: 0.0, Max: 1.5}, float64(base.DamperEngageRegisterSlope))
+		addKnob(knobDef{Name: "damper_reflection", Min: 0.7, Max: 0.995}, float64(base.DamperReflection))
+	}
+
+	// Knock group knobs: level, envelope and band gains of the hammer knock
+	// noise. --estimate-knock starts them from the reference's attack
+	// residual.
+	if groups["knock"] {
+		addKnob(knobDef{Name: "hammer_knock_level", Min: 0.0001, Max: 0.3, LogScale: true}, float64(base.HammerKnockLevel))
+		addKnob(knobDef{Name: "hammer_knock_attack_ms", Min: 0.2, Max: 10}, float64(base.HammerKnockAttackMs))
+		addKnob(knobDef{Name: "hammer_knock_decay_ms", Min: 5, Max: 500, LogScale: true}, float64(base.HammerKnockDecayMs))
+		for b, g := range base.HammerKnockBandsDB {
+			addKnob(knobDef{Name: fitknobs.HammerKnockBandKnobName(b), Min: knockBandKnobMin, Max: knockBandKnobMax}, float64(g))
+		}
+	}
+
 	for i := range vals {
 		vals[i] = clamp(vals[i], defs[i].Min, defs[i].Max)
 		if defs[i].IsInt {
@@ -154,6 +271,65 @@ func initCandidate(
 	return defs, candidate{Vals: vals}
 }
 
+// irKnobs set the synthesized body and room IR configs.
+var irKnobs = map[string]func(c *irConfigs, v float64){
+	"body_modes":           func(c *irConfigs, v float64) { c.body.Modes = int(math.Round(v)) },
+	"body_brightness":      func(c *irConfigs, v float64) { c.body.Brightness = v },
+	"body_plate_ratio":     func(c *irConfigs, v float64) { c.body.PlateRatio = v },
+	"body_stiffness_ratio": func(c *irConfigs, v float64) { c.body.StiffnessRatio = v },
+	"body_mode_warp":       func(c *irConfigs, v float64) { c.body.ModeWarp = v },
+	"body_direct":          func(c *irConfigs, v float64) { c.body.DirectLevel = v },
+	"body_low_decay":       func(c *irConfigs, v float64) { c.body.LowDecayS = v },
+	"body_high_decay":      func(c *irConfigs, v float64) { c.body.HighDecayS = v },
+	"body_crossover":       func(c *irConfigs, v float64) { c.body.CrossoverHz = v },
+	"body_duration":        func(c *irConfigs, v float64) { c.body.DurationS = v },
+	"body_fadeout":         func(c *irConfigs, v float64) { c.body.FadeOutS = v },
+	"room_early":           func(c *irConfigs, v float64) { c.room.EarlyCount = int(math.Round(v)) },
+	"room_late":            func(c *irConfigs, v float64) { c.room.LateLevel = v },
+	"room_stereo_width":    func(c *irConfigs, v float64) { c.room.StereoWidth = v },
+	"room_brightness":      func(c *irConfigs, v float64) { c.room.Brightness = v },
+	"room_low_decay":       func(c *irConfigs, v float64) { c.room.LowDecayS = v },
+	"room_high_decay":      func(c *irConfigs, v float64) { c.room.HighDecayS = v },
+	"room_duration":        func(c *irConfigs, v float64) { c.room.DurationS = v },
+	"room_fadeout":         func(c *irConfigs, v float64) { c.room.FadeOutS = v },
+}
+
+// knownKnob reports whether name is a knob piano-fit can search: a Params
+// knob (fitknobs.LookupParamKnob), an IR synthesis, render, EQ or
+// register knob.
+func knownKnob(name string) bool {
+	if _, ok := parseEQKnob(name); ok {
+		return true
+	}
+	if _, _, ok := parseRegisterKnob(name); ok {
+		return true
+	}
+	if _, _, ok := fitknobs.LookupParamKnob(name); ok {
+		return true
+	}
+	switch name {
+	case "render.velocity", "render.release_after", "render.pedal_down_at", "render.pedal_up_at":
+		return true
+	}
+	_, ok := irKnobs[name]
+	return ok
+}
+
+// checkKnobDefs rejects knob definitions applyCandidate would drop or
+// search badly: unknown names and ranges fitknobs.CheckSearchRange
+// refuses.
+func checkKnobDefs(defs []knobDef) error {
+	for _, d := range defs {
+		if !knownKnob(d.Name) {
+			return fmt.Errorf("unknown knob %q", d.Name)
+		}
+		if err := fitknobs.CheckSearchRange(d.Name, d.Min, d.Max, d.LogScale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func applyCandidate(
 	base *piano.Params,
 	sampleRate int,
@@ -162,125 +338,62 @@ func applyCandidate(
 	baseReleaseAfter float64,
 	defs []knobDef,
 	c candidate,
-) (irConfigs, *piano.Params, int, float64) {
+) (irConfigs, *piano.Params, int, float64, error) {
 	bodyCfg := irsynth.DefaultBodyConfig()
 	bodyCfg.SampleRate = sampleRate
+	bodyCfg.Seed = base.Seed
 	roomCfg := irsynth.DefaultRoomConfig()
 	roomCfg.SampleRate = sampleRate
+	roomCfg.Seed = base.Seed
+	irs := irConfigs{body: bodyCfg, room: roomCfg}
 	params := cloneParams(base)
 	if params.PerNote == nil {
 		params.PerNote = make(map[int]*piano.NoteParams)
 	}
-	np := params.PerNote[note]
-	if np == nil {
-		np = &piano.NoteParams{}
-		params.PerNote[note] = np
+	if params.PerNote[note] == nil {
+		params.PerNote[note] = &piano.NoteParams{}
 	}
 	velocity := baseVelocity
 	releaseAfter := baseReleaseAfter
 
 	for i, def := range defs {
 		v := c.Vals[i]
+		if band, ok := parseEQKnob(def.Name); ok {
+			if len(params.OutputEQ) == 0 {
+				params.OutputEQ = piano.DefaultOutputEQBands()
+			}
+			if band < len(params.OutputEQ) {
+				params.OutputEQ[band].GainDB = float32(v)
+			}
+			continue
+		}
+		if curve, point, ok := parseRegisterKnob(def.Name); ok {
+			applyRegisterKnob(params, base, curve, point, v)
+			continue
+		}
+		if set, ok := irKnobs[def.Name]; ok {
+			set(&irs, v)
+			continue
+		}
 		switch def.Name {
-		// Piano knobs.
-		case "output_gain":
-			params.OutputGain = float32(v)
-		case "hammer_stiffness_scale":
-			params.HammerStiffnessScale = float32(v)
-		case "hammer_exponent_scale":
-			params.HammerExponentScale = float32(v)
-		case "hammer_damping_scale":
-			params.HammerDampingScale = float32(v)
-		case "hammer_initial_velocity_scale":
-			params.HammerInitialVelocityScale = float32(v)
-		case "hammer_contact_time_scale":
-			params.HammerContactTimeScale = float32(v)
-		case "high_freq_damping":
-			params.HighFreqDamping = float32(v)
-		case "unison_detune_scale":
-			params.UnisonDetuneScale = float32(v)
-		case "unison_crossfeed":
-			params.UnisonCrossfeed = float32(v)
-		case "attack_noise_level":
-			params.AttackNoiseLevel = float32(v)
-		case "attack_noise_duration_ms":
-			params.AttackNoiseDurationMs = float32(v)
-		case "attack_noise_color":
-			params.AttackNoiseColor = float32(v)
-		case fmt.Sprintf("per_note.%d.loss", note):
-			np.Loss = float32(v)
-		case fmt.Sprintf("per_note.%d.inharmonicity", note):
-			np.Inharmonicity = float32(v)
-		case fmt.Sprintf("per_note.%d.strike_position", note):
-			np.StrikePosition = float32(v)
 		case "render.velocity":
 			velocity = int(math.Round(v))
 		case "render.release_after":
 			releaseAfter = v
-		// Body IR knobs.
-		case "body_modes":
-			bodyCfg.Modes = int(math.Round(v))
-		case "body_brightness":
-			bodyCfg.Brightness = v
-		case "body_plate_ratio":
-			bodyCfg.PlateRatio = v
-		case "body_stiffness_ratio":
-			bodyCfg.StiffnessRatio = v
-		case "body_mode_warp":
-			bodyCfg.ModeWarp = v
-		case "body_direct":
-			bodyCfg.DirectLevel = v
-		case "body_low_decay":
-			bodyCfg.LowDecayS = v
-		case "body_high_decay":
-			bodyCfg.HighDecayS = v
-		case "body_crossover":
-			bodyCfg.CrossoverHz = v
-		case "body_duration":
-			bodyCfg.DurationS = v
-		case "body_fadeout":
-			bodyCfg.FadeOutS = v
-		// Room IR knobs.
-		case "room_early":
-			roomCfg.EarlyCount = int(math.Round(v))
-		case "room_late":
-			roomCfg.LateLevel = v
-		case "room_stereo_width":
-			roomCfg.StereoWidth = v
-		case "room_brightness":
-			roomCfg.Brightness = v
-		case "room_low_decay":
-			roomCfg.LowDecayS = v
-		case "room_high_decay":
-			roomCfg.HighDecayS = v
-		case "room_duration":
-			roomCfg.DurationS = v
-		case "room_fadeout":
-			roomCfg.FadeOutS = v
-		// Mix knobs (dual-IR).
-		case "body_dry":
-			params.BodyDryMix = float32(v)
-		case "body_gain":
-			params.BodyIRGain = float32(v)
-		case "room_wet":
-			params.RoomWetMix = float32(v)
-		case "room_gain":
-			params.RoomGain = float32(v)
-		// Mix knobs (legacy).
-		case "ir_wet_mix":
-			params.IRWetMix = float32(v)
-		case "ir_dry_mix":
-			params.IRDryMix = float32(v)
-		case "ir_gain":
-			params.IRGain = float32(v)
+		case "render.pedal_down_at", "render.pedal_up_at":
+			// applyPedalKnobs
+		default:
+			if err := fitknobs.SetParamKnob(params, def.Name, v); err != nil {
+				return irConfigs{}, nil, 0, 0, err
+			}
 		}
 	}
 
-	if bodyCfg.Modes < 1 {
-		bodyCfg.Modes = 1
+	if irs.body.Modes < 1 {
+		irs.body.Modes = 1
 	}
-	if roomCfg.EarlyCount < 0 {
-		roomCfg.EarlyCount = 0
+	if irs.room.EarlyCount < 0 {
+		irs.room.EarlyCount = 0
 	}
 	if velocity < 1 {
 		velocity = 1
@@ -291,7 +404,24 @@ func applyCandidate(
 	if releaseAfter < 0.05 {
 		releaseAfter = 0.05
 	}
-	return irConfigs{body: bodyCfg, room: roomCfg}, params, velocity, releaseAfter
+	return irs, params, velocity, releaseAfter, nil
+}
+
+// outputEQBands returns the EQ layout fitted by the eq group.
+func outputEQBands(base *piano.Params) []piano.EQBand {
+	if len(base.OutputEQ) > 0 {
+		return base.OutputEQ
+	}
+	return piano.DefaultOutputEQBands()
+}
+
+// parseEQKnob returns the band index of an "eq.<i>.gain_db" knob name.
+func parseEQKnob(name string) (int, bool) {
+	var band int
+	if _, err := fmt.Sscanf(name, "eq.%d.gain_db", &band); err != nil || band < 0 {
+		return 0, false
+	}
+	return band, true
 }
 
 func fromNormalized(pos []float64, defs []knobDef) candidate {
@@ -314,3 +444,21 @@ func fromNormalized(pos []float64, defs []knobDef) candidate {
 	}
 	return candidate{Vals: vals}
 }
+
+// toNormalized is the inverse of fromNormalized: the position of c in the
+// [0,1] search space.
+func toNormalized(c candidate, defs []knobDef) []float64 {
+	pos := make([]float64, len(defs))
+	for i, d := range defs {
+		if i >= len(c.Vals) || d.Max <= d.Min {
+			continue
+		}
+		v := clamp(c.Vals[i], d.Min, d.Max)
+		if d.LogScale {
+			pos[i] = (math.Log(v) - math.Log(d.Min)) / (math.Log(d.Max) - math.Log(d.Min))
+		} else {
+			pos[i] = (v - d.Min) / (d.Max - d.Min)
+		}
+	}
+	return pos
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
+	"github.com/cwbudde/algo-piano/analysis"
 	"github.com/cwbudde/algo-piano/piano"
 )
 
@@ -29,6 +31,31 @@ func TestParseOptimizeGroups(t *testing.T) {
 			input: "piano,body-ir,room-ir,mix",
 			want:  map[string]bool{"piano": true, "body-ir": true, "room-ir": true, "mix": true},
 		},
+		{
+			name:  "eq group",
+			input: "piano,eq",
+			want:  map[string]bool{"piano": true, "eq": true},
+		},
+		{
+			name:  "coupling group",
+			input: "piano,coupling",
+			want:  map[string]bool{"piano": true, "coupling": true},
+		},
+		{
+			name:  "resonance group",
+			input: "resonance,coupling",
+			want:  map[string]bool{"resonance": true, "coupling": true},
+		},
+		{
+			name:  "register group",
+			input: "piano,register",
+			want:  map[string]bool{"piano": true, "register": true},
+		},
+		{
+			name:  "knock group",
+			input: "piano,knock",
+			want:  map[string]bool{"piano": true, "knock": true},
+		},
 		{
 			name:  "with whitespace",
 			input: " piano , mix ",
@@ -260,7 +287,7 @@ func TestApplyCandidatePianoKnobs(t *testing.T) {
 		}
 	}
 
-	_, params, velocity, releaseAfter := applyCandidate(base, 48000, 60, 118, 3.5, defs, candidate{Vals: vals})
+	_, params, velocity, releaseAfter := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, candidate{Vals: vals})
 
 	if params.OutputGain != float32(1.1) {
 		t.Fatalf("OutputGain = %v, want 1.1", params.OutputGain)
@@ -304,7 +331,7 @@ func TestApplyCandidateDualIRMix(t *testing.T) {
 		}
 	}
 
-	_, params, _, _ := applyCandidate(base, 48000, 60, 118, 3.5, defs, candidate{Vals: vals})
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, candidate{Vals: vals})
 	if params.BodyDryMix != float32(0.9) {
 		t.Fatalf("BodyDryMix = %v, want 0.9", params.BodyDryMix)
 	}
@@ -318,3 +345,297 @@ func TestApplyCandidateDualIRMix(t *testing.T) {
 		t.Fatalf("RoomGain = %v, want 1.2", params.RoomGain)
 	}
 }
+
+func TestApplyCandidateEQKnobs(t *testing.T) {
+	base := piano.NewDefaultParams()
+	groups := map[string]bool{"eq": true}
+	defs, cand := initCandidate(base, 48000, 60, 118, 3.5, groups)
+	if len(defs) != len(piano.DefaultOutputEQBands()) {
+		t.Fatalf("defs len = %d, want %d", len(defs), len(piano.DefaultOutputEQBands()))
+	}
+	for i, v := range cand.Vals {
+		if v != 0 {
+			t.Fatalf("expected neutral initial gain for %s, got %v", defs[i].Name, v)
+		}
+	}
+
+	vals := make([]float64, len(defs))
+	vals[0] = -3
+	vals[len(vals)-1] = 4.5
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, candidate{Vals: vals})
+	if len(params.OutputEQ) != len(defs) {
+		t.Fatalf("OutputEQ len = %d, want %d", len(params.OutputEQ), len(defs))
+	}
+	if params.OutputEQ[0].GainDB != -3 || params.OutputEQ[len(defs)-1].GainDB != 4.5 {
+		t.Fatalf("unexpected band gains: %+v", params.OutputEQ)
+	}
+	if len(base.OutputEQ) != 0 {
+		t.Fatalf("expected base params untouched, got %+v", base.OutputEQ)
+	}
+}
+
+func TestApplyCandidateCouplingKnobs(t *testing.T) {
+	base := piano.NewDefaultParams()
+	groups := map[string]bool{"coupling": true}
+	defs, cand := initCandidate(base, 48000, 60, 118, 3.5, groups)
+	names := knobNameSet(defs)
+	for _, want := range []string{"coupling_amount", "coupling_octave_gain", "coupling_fifth_gain", "coupling_max_force"} {
+		if !names[want] {
+			t.Fatalf("missing knob %q", want)
+		}
+	}
+	for i, d := range defs {
+		if d.Name == "coupling_octave_gain" {
+			cand.Vals[i] = 0.0004
+		}
+	}
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, cand)
+	if params.CouplingOctaveGain != float32(0.0004) {
+		t.Fatalf("CouplingOctaveGain = %v, want 0.0004", params.CouplingOctaveGain)
+	}
+	if base.CouplingOctaveGain == params.CouplingOctaveGain {
+		t.Fatalf("applyCandidate mutated base params")
+	}
+}
+
+func TestApplyCandidateResonanceKnobs(t *testing.T) {
+	base := piano.NewDefaultParams()
+	defs, cand := initCandidate(base, 48000, 60, 118, 3.5, map[string]bool{"resonance": true})
+	for i, d := range defs {
+		switch d.Name {
+		case "resonance_gain":
+			cand.Vals[i] = 0.0005
+		case "resonance_attack_ms":
+			cand.Vals[i] = 80
+		}
+	}
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, cand)
+	if params.ResonanceGain != float32(0.0005) {
+		t.Fatalf("ResonanceGain = %v, want 0.0005", params.ResonanceGain)
+	}
+	if params.ResonanceAttackMs != 80 {
+		t.Fatalf("ResonanceAttackMs = %v, want 80", params.ResonanceAttackMs)
+	}
+}
+
+func TestApplyCandidateHammerKnobs(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.PerNote[36] = &piano.NoteParams{HammerStiffnessScale: 1.2}
+	defs, cand := initCandidate(base, 48000, 36, 118, 3.5, map[string]bool{"hammer": true})
+	if len(defs) != 3 || cand.Vals[0] != float64(float32(1.2)) || cand.Vals[1] != 1 || cand.Vals[2] != 1 {
+		t.Fatalf("hammer knobs %v start at %v, want the note's scales (unset = 1)", defs, cand.Vals)
+	}
+	cand.Vals[0], cand.Vals[2] = 0.7, 1.4
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 36, 118, 3.5, defs, cand)
+	np := params.PerNote[36]
+	if np.HammerStiffnessScale != 0.7 || np.HammerExponentScale != 1 || np.HammerContactTimeScale != 1.4 {
+		t.Fatalf("note 36 hammer scales %+v", np)
+	}
+	if base.PerNote[36].HammerStiffnessScale != 1.2 {
+		t.Fatal("applyCandidate modified the base params")
+	}
+}
+
+func TestApplyCandidateDamperKnobs(t *testing.T) {
+	base := piano.NewDefaultParams()
+	defs, cand := initCandidate(base, 48000, 36, 118, 3.5, map[string]bool{"damper": true})
+	if len(defs) != 3 || cand.Vals[0] != float64(base.DamperEngageMs) || cand.Vals[2] != float64(base.DamperReflection) {
+		t.Fatalf("damper knobs %v start at %v, want the preset values", defs, cand.Vals)
+	}
+	cand.Vals[0], cand.Vals[1], cand.Vals[2] = 20, 0.3, 0.9
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 36, 118, 3.5, defs, cand)
+	if params.DamperEngageMs != 20 || params.DamperEngageRegisterSlope != 0.3 || params.DamperReflection != 0.9 {
+		t.Fatalf("damper params = %v/%v/%v, want 20/0.3/0.9", params.DamperEngageMs, params.DamperEngageRegisterSlope, params.DamperReflection)
+	}
+}
+
+func TestApplyCandidateKnockKnobs(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.HammerKnockLevel = 0.02
+	base.HammerKnockBandsDB[6] = -20
+	defs, cand := initCandidate(base, 48000, 60, 118, 3.5, map[string]bool{"knock": true})
+	if len(defs) != 3+piano.HammerKnockBands {
+		t.Fatalf("knock knobs %v, want level, attack, decay and %d bands", defs, piano.HammerKnockBands)
+	}
+	if cand.Vals[0] != float64(float32(0.02)) || defs[3+6].Name != "hammer_knock_bands_db.6" || cand.Vals[3+6] != -20 {
+		t.Fatalf("knock knobs %v start at %v, want the preset values", defs, cand.Vals)
+	}
+	cand.Vals[0], cand.Vals[2], cand.Vals[3] = 0.05, 120, -12
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, cand)
+	if params.HammerKnockLevel != 0.05 || params.HammerKnockDecayMs != 120 || params.HammerKnockBandsDB[0] != -12 || params.HammerKnockBandsDB[6] != -20 {
+		t.Fatalf("knock params = %v/%v/%v, want 0.05/120/[-12 .. -20 ..]", params.HammerKnockLevel, params.HammerKnockDecayMs, params.HammerKnockBandsDB)
+	}
+	if base.HammerKnockBandsDB[0] != 0 {
+		t.Fatal("applyCandidate modified the base params")
+	}
+}
+
+func TestSeedKnockStartsFromMeasuredResidual(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.OutputGain = 0.5
+	res := analysis.AttackResidual{
+		PeakRMS:  0.01,
+		AttackMs: 2,
+		DecayMs:  1500,
+		BandsDB:  []float64{-3, 0, -6, -60, -10, -20, -30, -40},
+	}
+	seedKnock(base, res, 64)
+	if want := 0.01 * 127 / 64 / 0.5; math.Abs(float64(base.HammerKnockLevel)-want) > 1e-6 {
+		t.Fatalf("seeded level %v, want %v (velocity 127, before the output gain)", base.HammerKnockLevel, want)
+	}
+	if base.HammerKnockAttackMs != 2 || base.HammerKnockDecayMs != 500 {
+		t.Fatalf("seeded envelope %v/%v ms, want 2/500 (decay clamped)", base.HammerKnockAttackMs, base.HammerKnockDecayMs)
+	}
+	want := [piano.HammerKnockBands]float32{-3, 0, -6, knockBandKnobMin, -10, -20, -30, knockBandKnobMin}
+	if base.HammerKnockBandsDB != want {
+		t.Fatalf("seeded bands %v, want %v", base.HammerKnockBandsDB, want)
+	}
+}
+
+func TestSeedInharmonicityStartsKnobFromMeasuredB(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.StringModel = piano.StringModelModal
+	orig := &piano.NoteParams{Loss: 0.997, Inharmonicity: 0.12, StrikePosition: 0.2}
+	base.PerNote[60] = orig
+
+	got, ok := seedInharmonicity(base, 48000, 60, 0.0012)
+	if !ok || math.Abs(float64(got)-0.01) > 1e-6 {
+		t.Fatalf("seeded inharmonicity = %v, %t; want 0.01", got, ok)
+	}
+	np := base.PerNote[60]
+	if np.Loss != 0.997 || np.StrikePosition != 0.2 || orig.Inharmonicity != 0.12 {
+		t.Fatalf("seeding must keep other per-note values and not alias the preset entry: %+v, orig %+v", np, orig)
+	}
+	defs, cand := initCandidate(base, 48000, 60, 118, 3.5, map[string]bool{"piano": true})
+	for i, d := range defs {
+		if d.Name == "per_note.60.inharmonicity" && cand.Vals[i] != float64(got) {
+			t.Fatalf("knob starts at %v, want %v", cand.Vals[i], got)
+		}
+	}
+
+	if got, _ := seedInharmonicity(base, 48000, 30, 1); got != inharmonicityKnobMax {
+		t.Fatalf("large B must clamp to the knob range, got %v", got)
+	}
+}
+
+func TestSeedInharmonicityMatchesMeasuredBOnWaveguide(t *testing.T) {
+	const sr = 48000
+	const note = 100
+	const b = 1e-4
+	base := piano.NewDefaultParams()
+	if _, ok := seedInharmonicity(base, sr, note, b); !ok {
+		t.Fatalf("B = %g must be within the waveguide's reach at note %d", b, note)
+	}
+	// The treble waveguide loses its upper partials within milliseconds,
+	// too fast for the estimator's window, so it measures a tone ringing
+	// at the seeded string's partial frequencies instead.
+	partials := piano.NotePartials(sr, base, note, 16)
+	x := make([]float64, sr)
+	for i := range x {
+		tm := float64(i) / sr
+		for _, f := range partials {
+			x[i] += 0.1 * math.Sin(2*math.Pi*f*tm) * math.Exp(-tm)
+		}
+	}
+	f0 := 440 * math.Pow(2, float64(note-69)/12)
+	got, ok := analysis.EstimateInharmonicity(x, sr, f0)
+	if !ok || math.Abs(got-b) > 0.05*b {
+		t.Fatalf("seeded note measures B = %g (ok %t), want %g", got, ok, b)
+	}
+
+	mid := piano.NewDefaultParams()
+	before := mid.PerNote[60]
+	if _, ok := seedInharmonicity(mid, sr, 60, 4e-4); ok || mid.PerNote[60] != before {
+		t.Fatal("an unreachable B must leave the preset alone")
+	}
+}
+
+// mustApplyCandidate is applyCandidate failing the test on an error.
+func mustApplyCandidate(t *testing.T, base *piano.Params, sampleRate, note, velocity int, releaseAfter float64, defs []knobDef, c candidate) (irConfigs, *piano.Params, int, float64) {
+	t.Helper()
+	irs, params, v, r, err := applyCandidate(base, sampleRate, note, velocity, releaseAfter, defs, c)
+	if err != nil {
+		t.Fatalf("applyCandidate: %v", err)
+	}
+	return irs, params, v, r
+}
+
+func TestApplyCandidateRejectsUnknownKnob(t *testing.T) {
+	base := piano.NewDefaultParams()
+	defs := []knobDef{{Name: "output_gian", Min: 0.01, Max: 5}}
+	if _, _, _, _, err := applyCandidate(base, 48000, 60, 118, 3.5, defs, candidate{Vals: []float64{1}}); err == nil {
+		t.Fatal("a misspelled knob was applied without an error")
+	}
+	defs = []knobDef{{Name: "per_note.060.loss", Min: 0.985, Max: 0.99995}}
+	if _, _, _, _, err := applyCandidate(base, 48000, 60, 118, 3.5, defs, candidate{Vals: []float64{0.99}}); err == nil {
+		t.Fatal("a non-canonical per-note knob was applied without an error")
+	}
+	defs = []knobDef{{Name: "damper_reflection", Min: 0, Max: 1}}
+	if _, _, _, _, err := applyCandidate(base, 48000, 60, 118, 3.5, defs, candidate{Vals: []float64{0}}); err == nil {
+		t.Fatal("damper_reflection 0, outside (0,1], was applied without an error")
+	}
+}
+
+func TestCheckKnobDefs(t *testing.T) {
+	all := map[string]bool{}
+	for _, g := range []string{"piano", "body-ir", "room-ir", "mix", "eq", "coupling", "resonance", "hammer", "damper"} {
+		all[g] = true
+	}
+	base := piano.NewDefaultParams()
+	defs, _ := initCandidate(base, 48000, 60, 118, 3.5, all)
+	defs, _ = addRegisterKnobs(defs, candidate{Vals: make([]float64, len(defs))}, base, singleNote(60))
+	if err := checkKnobDefs(defs); err != nil {
+		t.Fatalf("default knobs rejected: %v", err)
+	}
+	legacy, _ := initCandidate(base, 48000, 60, 118, 3.5, map[string]bool{"mix": true})
+	if err := checkKnobDefs(legacy); err != nil {
+		t.Fatalf("default legacy mix knobs rejected: %v", err)
+	}
+
+	bad := []knobDef{
+		{Name: "hammer_stifness_scale", Min: 0.6, Max: 1.8},
+		{Name: "output_gain", Min: 2, Max: 2},
+		{Name: "body_low_decay", Min: 0, Max: 0.5, LogScale: true},
+		{Name: "coupling_max_force", Min: 0.001, Max: 10},
+		{Name: "high_freq_damping", Min: 0, Max: 1.2},
+		{Name: "per_note.60.loss", Min: 0.985, Max: math.NaN()},
+	}
+	for _, d := range bad {
+		if err := checkKnobDefs([]knobDef{d}); err == nil {
+			t.Errorf("knob %+v accepted", d)
+		}
+	}
+}
+
+func TestCandidateFromKnobsValidatesNames(t *testing.T) {
+	defs := []knobDef{
+		{Name: "output_gain", Min: 0.01, Max: 5},
+		{Name: "per_note.60.loss", Min: 0.985, Max: 0.99995},
+	}
+	fallback := candidate{Vals: []float64{1, 0.999}}
+
+	got, ok, skipped, err := candidateFromKnobs(map[string]float64{
+		"output_gain":      9,
+		"body_brightness":  1.2,
+		"per_note.62.loss": 0.998,
+	}, defs, fallback)
+	if err != nil || !ok {
+		t.Fatalf("ok %v, err %v", ok, err)
+	}
+	if got.Vals[0] != 5 || got.Vals[1] != 0.999 {
+		t.Fatalf("candidate %v, want output_gain clamped to 5 and loss kept", got.Vals)
+	}
+	if fmt.Sprint(skipped) != "[body_brightness per_note.62.loss]" {
+		t.Fatalf("skipped %v", skipped)
+	}
+
+	for _, knobs := range []map[string]float64{
+		{"output_gian": 1},
+		{"per_note.60.los": 0.998},
+		{"output_gain": math.NaN()},
+	} {
+		if _, _, _, err := candidateFromKnobs(knobs, defs, fallback); err == nil {
+			t.Errorf("knobs %v accepted", knobs)
+		}
+	}
+}
@@ -1,31 +1,45 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"math"
 	"os"
-	"runtime/pprof"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
 
+	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/dataset"
 	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/internal/fitknobs"
 	"github.com/cwbudde/algo-piano/piano"
 	"github.com/cwbudde/algo-piano/preset"
 )
 
 func main() {
-	referencePath := flag.String("reference", "reference/c4.wav", "Reference WAV path")
-	presetPath := flag.String("preset", "assets/presets/default.json", "Base preset JSON path")
+	referencePath := flag.String("reference", "reference/c4.wav", "Reference WAV path, or a glob of several takes of the same note scored by their median (e.g. 'reference/c4-take*.wav')")
+	datasetPath := flag.String("dataset", "", "Reference dataset manifest JSON; the takes of --note (with or without pedal, per --sustain-pedal) at the velocity layer nearest --velocity are fetched (and cached) and used instead of --reference")
+	datasetCache := flag.String("dataset-cache", dataset.DefaultCacheDir(), "Download cache for --dataset references")
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Base preset JSON path")
 	outputIR := flag.String("output-ir", "", "Path to write best synthesized IR WAV (required when body-ir or room-ir groups active)")
 	outputPreset := flag.String("output-preset", "assets/presets/fitted-c4.json", "Path to write best fitted preset JSON")
 	reportPath := flag.String("report", "", "Optional report JSON path (default: <output-preset>.report.json)")
 	workDir := flag.String("work-dir", "out/fit", "Directory for temporary candidates")
-	optimize := flag.String("optimize", "piano,mix", "Comma-separated knob groups to optimize: piano, body-ir, room-ir, mix")
+	optimize := flag.String("optimize", "piano,mix", "Comma-separated knob groups to optimize: piano, body-ir, room-ir, mix, eq, coupling, resonance, register, hammer, damper")
 	note := flag.Int("note", 60, "MIDI note to fit")
+	notesChord := flag.String("notes-chord", "", "Comma-separated MIDI notes of a chord/interval reference, e.g. 60,64,67 (overrides --note; per-note knobs fit the first note)")
+	chordOnsets := flag.String("chord-onsets", "", "Comma-separated per-note onsets in seconds for --notes-chord, or a single value used as the spacing between notes (default: all at 0)")
 	velocity := flag.Int("velocity", 118, "MIDI velocity for rendering during fit")
 	releaseAfter := flag.Float64("release-after", 3.5, "Seconds before NoteOff for each evaluation render")
+	sustainPedal := flag.Bool("sustain-pedal", false, "Render a pedal-down scenario; pedal timing becomes optimizable render knobs")
+	pedalDownAt := flag.Float64("pedal-down-at", 0.0, "Sustain pedal press time in seconds (with --sustain-pedal)")
+	pedalUpAt := flag.Float64("pedal-up-at", -1, "Sustain pedal lift time in seconds (<0 keeps the pedal down to the end)")
 	sampleRate := flag.Int("sample-rate", 48000, "Render/analysis sample rate")
 	seed := flag.Int64("seed", 1, "Random seed")
 	timeBudget := flag.Float64("time-budget", 120.0, "Optimization time budget in seconds")
@@ -42,24 +56,46 @@ func main() {
 	renderBlockSize := flag.Int("render-block-size", 128, "Audio render block size for candidate evaluation")
 	refineTopK := flag.Int("refine-top-k", 3, "After optimization, re-evaluate best N candidates at full settings")
 	topK := flag.Int("top-k", 5, "How many top candidates to keep in report")
+	freezeAfter := flag.Int("freeze-after", 0, "Freeze a knob once its best value has stayed within --freeze-tol for this many consecutive improvements, shrinking the search to the remaining knobs (0 = off)")
+	freezeTol := flag.Float64("freeze-tol", 0.02, "Movement a knob's best value may make and still count as stable for --freeze-after, as a fraction of its range")
 	resume := flag.Bool("resume", true, "Resume from previous best_knobs report when available")
 	resumeReport := flag.String("resume-report", "", "Optional report JSON path to resume from (default: current report path)")
+	warmStartFrom := flag.String("warm-start-from", "", "Start from the best knobs of an adjacent note's report (e.g. the N-1 or N+1 fit), per-note knobs scaled to this note's frequency; --resume takes precedence when this note's own report exists")
 	workers := flag.String("workers", "1", "Parallel optimization workers running independent Mayfly rounds (number or 'auto')")
 
+	gainMatch := flag.Bool("gain-match", false, "Match the candidate's slowly-varying level trajectory to the reference before metering (timbre-focused fits)")
+	gainWindow := flag.Float64("gain-window", 0.1, "Gain-match RMS window in seconds")
+	gainSmoothness := flag.Float64("gain-smoothness", 10.0, "Gain-match smoothness penalty (larger = flatter gain track)")
+	f0Weight := flag.Float64("f0-weight", 0.0, "Blend weight in [0,1] of the fundamental deviation in cents (tracked over time near the note's nominal pitch) in the fit score; single-note fits only")
+	beatWeight := flag.Float64("beat-weight", 0.0, "Blend weight in [0,1] of the beat-rate and beat-depth mismatch of the lowest partials (unison detune) in the fit score; single-note fits only")
+	t60Weight := flag.Float64("t60-weight", 0.0, "Blend weight in [0,1] of the per-partial decay-time (T60) curve error in the fit score; single-note fits only")
+	centroidWeight := flag.Float64("centroid-weight", 0.0, "Blend weight in [0,1] of the spectral centroid (brightness) trajectory error, per 50 ms frame, in the fit score")
+	spectralBands := flag.Int("spectral-bands-per-octave", 0, "Measure the spectral term over log-spaced bands with this many per octave (e.g. 3 or 12) instead of linear FFT bins, so the treble does not outweigh the low and mid octaves (0 = linear bins)")
+	noiseFloorMask := flag.Bool("noise-floor-mask", false, "Leave spectral bins where both signals are at the reference's noise floor (hiss, decayed tail) out of the spectral term")
+	estimateInharmonicity := flag.Bool("estimate-inharmonicity", true, "Start the per-note inharmonicity knob from the B coefficient measured in the reference instead of the preset value (single-note fits with the piano group)")
+	estimateKnock := flag.Bool("estimate-knock", true, "Start the hammer knock knobs from the attack residual measured in the reference (partials removed) instead of the preset values (single-note fits with the knock group)")
+	validation := flag.Bool("validation", false, "Also score held-out spectral windows between the scoring windows and report the validation score (overfitting check; never optimized)")
+	releaseWeight := flag.Float64("release-weight", 0.0, "Blend weight in [0,1] of the release-window error (window centered on the reference NoteOff) in the fit score")
 	noResonance := flag.Bool("no-resonance", false, "Disable sympathetic resonance during optimization (faster evals)")
-	cpuProfile := flag.String("cpuprofile", "", "Write CPU profile to file")
 	mayflyVariant := flag.String("mayfly-variant", "desma", "Mayfly variant: ma|desma|olce|eobbma|gsasma|mpma|aoblmoa")
 	mayflyPop := flag.Int("mayfly-pop", 10, "Male and female population size per Mayfly run")
-	mayflyRoundEvals := flag.Int("mayfly-round-evals", 240, "Target eval budget per Mayfly round")
-	flag.Parse()
+	warmEngine := flag.Bool("warm-engine", false, "Keep one engine per worker and reset it between candidates instead of building a new one (and reloading the IR WAV) per evaluation")
+	restartAfter := flag.Int("restart-after", 0, "After this many Mayfly rounds in a row without improvement, seed part of the next round's population around the best candidate (annealed restart; 0 = off)")
+	restartFraction := flag.Float64("restart-fraction", 0.5, "Share of a restarted round's males seeded around the best candidate for --restart-after")
+	restartTemp := flag.Float64("restart-temp", 0.2, "Spread of the first --restart-after restart around the best candidate, as a fraction of each knob's range")
+	restartCooling := flag.Float64("restart-cooling", 0.6, "Factor the --restart-after spread shrinks by with every restart")
+	initSamples := flag.Int("init-samples", 0, "Evaluate this many space-filling Latin hypercube candidates before the Mayfly rounds; the best seed the top-k list and the rounds' populations (0 = off)")
+	mayflyRoundEvals := flag.Int("mayfly-round-evals", 240, "Maximum eval budget per Mayfly round (rounds shrink to fit the remaining time budget)")
+	pareto := flag.Bool("pareto", false, "Multi-objective mode: track the Pareto front over the spectral, envelope and decay distances (each Mayfly round minimizes its own random weighting of them) and write the front candidates as presets")
+	paretoDirPath := flag.String("pareto-dir", "", "Directory for the Pareto front presets and front.json (default: <output-preset>.pareto)")
+	paretoSize := flag.Int("pareto-size", 16, "Maximum Pareto front candidates kept; the most crowded ones are dropped")
+	logConfig := fitcommon.RegisterLogFlags()
+	profileConfig := fitcommon.RegisterProfileFlags()
+	fitcommon.ParseFlags()
 
-	if *cpuProfile != "" {
-		file, err := os.Create(*cpuProfile)
-		if err != nil {
-			die("cpuprofile: %v", err)
-		}
-		pprof.StartCPUProfile(file)
-		defer pprof.StopCPUProfile()
+	log, err := logConfig.NewLogger(os.Stdout)
+	if err != nil {
+		die("%v", err)
 	}
 
 	groups, err := parseOptimizeGroups(*optimize)
@@ -118,6 +154,80 @@ func main() {
 	if *refineTopK > *topK {
 		*refineTopK = *topK
 	}
+	if *initSamples < 0 {
+		die("init-samples must be >= 0")
+	}
+	if *freezeAfter < 0 {
+		die("freeze-after must be >= 0")
+	}
+	if *freezeTol <= 0 || *freezeTol >= 1 {
+		die("freeze-tol must be in (0,1)")
+	}
+	if *restartAfter < 0 {
+		die("restart-after must be >= 0")
+	}
+	if *restartFraction <= 0 || *restartFraction > 1 {
+		die("restart-fraction must be in (0,1]")
+	}
+	if *restartTemp <= 0 || *restartTemp > 1 {
+		die("restart-temp must be in (0,1]")
+	}
+	if *restartCooling <= 0 || *restartCooling > 1 {
+		die("restart-cooling must be in (0,1]")
+	}
+	notes := singleNote(*note)
+	if *notesChord != "" {
+		notes, err = parseChord(*notesChord, *chordOnsets)
+		if err != nil {
+			die("invalid --notes-chord: %v", err)
+		}
+		*note = notes[0].Note
+	}
+	if *f0Weight < 0 || *f0Weight > 1 {
+		die("f0-weight must be in [0,1]")
+	}
+	if *beatWeight < 0 || *beatWeight > 1 {
+		die("beat-weight must be in [0,1]")
+	}
+	if *t60Weight < 0 || *t60Weight > 1 {
+		die("t60-weight must be in [0,1]")
+	}
+	if *centroidWeight < 0 || *centroidWeight > 1 {
+		die("centroid-weight must be in [0,1]")
+	}
+	if *spectralBands < 0 {
+		die("spectral-bands-per-octave must be >= 0")
+	}
+	// A chord's other notes would mask the partials of the fitted note.
+	f0Hz := 0.0
+	if len(notes) == 1 {
+		f0Hz = 440 * math.Pow(2, float64(*note-69)/12)
+	} else {
+		for _, term := range []struct {
+			flag   string
+			weight float64
+		}{{"f0-weight", *f0Weight}, {"beat-weight", *beatWeight}, {"t60-weight", *t60Weight}} {
+			if term.weight > 0 {
+				log.Warn("weight ignored for chord references", "flag", "--"+term.flag)
+			}
+		}
+	}
+	pedal := noPedal
+	if *sustainPedal {
+		if *pedalDownAt < 0 {
+			die("pedal-down-at must be >= 0")
+		}
+		pedal = pedalTiming{DownAt: *pedalDownAt, UpAt: *pedalUpAt}
+		if pedal.UpAt >= 0 && pedal.UpAt < pedal.DownAt {
+			die("pedal-up-at must not be before pedal-down-at")
+		}
+	}
+	if *pareto && *paretoSize < 2 {
+		die("pareto-size must be >= 2")
+	}
+	if groups["resonance"] && *noResonance {
+		die("--no-resonance conflicts with the resonance optimize group")
+	}
 	parsedWorkers, err := parseWorkersFlag(*workers)
 	if err != nil {
 		die("invalid workers value: %v", err)
@@ -133,18 +243,59 @@ func main() {
 	if *noResonance {
 		baseParams.ResonanceEnabled = false
 	}
+	if groups["resonance"] && !baseParams.ResonanceEnabled {
+		log.Info("resonance group active: enabling sympathetic resonance")
+		baseParams.ResonanceEnabled = true
+	}
 
-	refRaw, refSR, err := readWAVMono(*referencePath)
-	if err != nil {
-		die("failed to read reference: %v", err)
+	var refPaths []string
+	if *datasetPath != "" {
+		if *notesChord != "" {
+			die("--dataset references are single notes; it cannot be combined with --notes-chord")
+		}
+		var layer int
+		refPaths, layer, err = datasetTakes(context.Background(), *datasetPath, *datasetCache, *note, *velocity, *sustainPedal)
+		if err != nil {
+			die("failed to fetch dataset references: %v", err)
+		}
+		log.Info("fitting against dataset references", "dataset", *datasetPath, "note", *note, "layer_velocity", layer, "takes", len(refPaths))
+		if layer != 0 && layer != *velocity {
+			log.Warn("no dataset layer at the render velocity; using the nearest", "velocity", *velocity, "layer_velocity", layer)
+		}
+		*referencePath = fmt.Sprintf("%s#note=%d,velocity=%d", *datasetPath, *note, layer)
+	} else {
+		refPaths, err = referenceTakes(*referencePath)
+		if err != nil {
+			die("failed to resolve reference: %v", err)
+		}
 	}
-	refOpt, err := resampleIfNeeded(refRaw, refSR, *optSampleRate)
+	refOpt, refFull, err := loadReferences(refPaths, *optSampleRate, *sampleRate)
 	if err != nil {
-		die("failed to resample optimization reference: %v", err)
+		die("failed to load reference: %v", err)
 	}
-	refFull, err := resampleIfNeeded(refRaw, refSR, *sampleRate)
-	if err != nil {
-		die("failed to resample full reference: %v", err)
+	if len(refPaths) > 1 {
+		log.Info("fitting against reference takes (median score)", "takes", len(refPaths))
+	}
+
+	estimatedB := false
+	if *estimateInharmonicity && groups["piano"] && f0Hz > 0 {
+		if b, ok := referenceInharmonicity(refFull, *sampleRate, f0Hz); !ok {
+			log.Warn("inharmonicity estimate failed (too few partials found); keeping the preset value")
+		} else if v, ok := seedInharmonicity(baseParams, *sampleRate, *note, b); ok {
+			estimatedB = true
+			log.Info("estimated inharmonicity", "b", b, "knob", fitknobs.PerNoteKnobName(*note, "inharmonicity"), "start", v)
+		} else {
+			log.Warn("estimated inharmonicity is out of the string model's reach; keeping the preset value", "b", b)
+		}
+	}
+
+	if *estimateKnock && groups["knock"] && f0Hz > 0 {
+		if res, ok := referenceKnock(refFull, *sampleRate, f0Hz); ok {
+			seedKnock(baseParams, res, *velocity)
+			log.Info("estimated hammer knock", "peak_db", res.PeakDB, "attack_ms", res.AttackMs, "decay_ms", res.DecayMs, "bands_db", res.BandsDB, "level", baseParams.HammerKnockLevel)
+		} else {
+			log.Warn("hammer knock estimate failed (no attack found); keeping the preset values")
+		}
 	}
 
 	defs, initCand := initCandidate(
@@ -155,6 +306,40 @@ func main() {
 		*releaseAfter,
 		groups,
 	)
+	defs, initCand = addPedalKnobs(defs, initCand, pedal)
+	if groups["register"] {
+		defs, initCand = addRegisterKnobs(defs, initCand, baseParams, notes)
+	}
+	if err := checkKnobDefs(defs); err != nil {
+		die("invalid knobs: %v", err)
+	}
+	if *warmStartFrom != "" {
+		from, knobs, err := loadWarmStart(*warmStartFrom)
+		if err != nil {
+			die("invalid --warm-start-from: %v", err)
+		}
+		if d := from - *note; d < -2 || d > 2 {
+			log.Warn("warm start from a distant note; the frequency scaling is only a rough guess", "from", from, "note", *note)
+		}
+		knobs = transferKnobs(knobs, from, *note)
+		if estimatedB {
+			// The B measured in the reference beats the scaled one.
+			delete(knobs, fitknobs.PerNoteKnobName(*note, "inharmonicity"))
+		}
+		warm, ok, skipped, err := candidateFromKnobs(knobs, defs, initCand)
+		if err != nil {
+			die("invalid --warm-start-from %s: %v", *warmStartFrom, err)
+		}
+		if len(skipped) > 0 {
+			log.Warn("warm start knobs outside the optimized groups ignored", "knobs", skipped)
+		}
+		if ok {
+			initCand = warm
+			log.Info("warm start", "report", *warmStartFrom, "from", from)
+		} else {
+			log.Warn("warm start report shares no knobs with this fit", "report", *warmStartFrom)
+		}
+	}
 	if *resume {
 		resumePath := *resumeReport
 		if resumePath == "" {
@@ -164,17 +349,29 @@ func main() {
 				resumePath = *outputPreset + ".report.json"
 			}
 		}
-		if resumed, ok, err := loadCandidateFromReport(resumePath, defs, initCand); err != nil {
-			fmt.Fprintf(os.Stderr, "resume skipped (%s): %v\n", resumePath, err)
+		if resumed, ok, skipped, err := loadCandidateFromReport(resumePath, defs, initCand); err != nil {
+			log.Warn("resume skipped", "report", resumePath, "err", err)
 		} else if ok {
+			if len(skipped) > 0 {
+				log.Warn("resumed knobs outside the optimized groups ignored", "knobs", skipped)
+			}
 			initCand = resumed
-			fmt.Printf("Resumed candidate from %s\n", resumePath)
+			log.Info("resumed candidate", "report", resumePath)
 		}
 	}
 
+	sourceMeta, err := preset.LoadMeta(*presetPath)
+	if err != nil {
+		die("failed to load preset meta: %v", err)
+	}
+	provenance, err := fitcommon.NewProvenance("piano-fit", *seed, refPaths, sourceMeta)
+	if err != nil {
+		die("failed to record provenance: %v", err)
+	}
+
 	cfg := &optimizationConfig{
-		reference:        refOpt,
-		finalReference:   refFull,
+		references:       refOpt,
+		finalReferences:  refFull,
 		baseParams:       baseParams,
 		defs:             defs,
 		initCandidate:    initCand,
@@ -196,6 +393,14 @@ func main() {
 		finalMaxDuration: *maxDuration,
 		renderBlockSize:  *renderBlockSize,
 		refineTopK:       *refineTopK,
+		initSamples:      *initSamples,
+		warmEngine:       *warmEngine,
+		freezeAfter:      *freezeAfter,
+		freezeTol:        *freezeTol,
+		restartAfter:     *restartAfter,
+		restartFraction:  *restartFraction,
+		restartTemp:      *restartTemp,
+		restartCooling:   *restartCooling,
 		mayflyVariant:    *mayflyVariant,
 		mayflyPop:        *mayflyPop,
 		mayflyRoundEvals: *mayflyRoundEvals,
@@ -208,9 +413,43 @@ func main() {
 		reportPath:       *reportPath,
 		referencePath:    *referencePath,
 		presetPath:       *presetPath,
+		provenance:       provenance,
+		notes:            notes,
+		pedal:            pedal,
+		log:              log,
+		compareOptions: analysis.CompareOptions{
+			GainMatch:      *gainMatch,
+			GainWindowSec:  *gainWindow,
+			GainSmoothness: *gainSmoothness,
+			ReleaseWeight:  *releaseWeight,
+			F0Hz:           f0Hz,
+			F0Weight:       *f0Weight,
+			BeatWeight:     *beatWeight,
+			T60Weight:      *t60Weight,
+			CentroidWeight: *centroidWeight,
+			Validation:     *validation,
+
+			SpectralBandsPerOctave: *spectralBands,
+			NoiseFloorMask:         *noiseFloorMask,
+		},
 	}
 
-	result, err := runOptimization(cfg)
+	// SIGINT/SIGTERM stop the fit early; the best candidate so far is still
+	// written below. A second signal terminates as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	if *pareto {
+		cfg.paretoSize = *paretoSize
+	}
+
+	stopProfile, err := profileConfig.Start(log)
+	if err != nil {
+		die("%v", err)
+	}
+	result, err := runOptimization(ctx, cfg)
+	stopProfile()
 	if err != nil {
 		die("optimization failed: %v", err)
 	}
@@ -223,8 +462,10 @@ func main() {
 		*presetPath,
 		*sampleRate,
 		*note,
+		notes,
 		result.bestVelocity,
 		result.bestReleaseAfter,
+		result.bestPedal,
 		result.elapsed,
 		result.evals,
 		strings.ToLower(*mayflyVariant),
@@ -237,24 +478,56 @@ func main() {
 		result.bestRoomIRR,
 		result.checkpoints,
 		result.top,
+		result.interrupted,
+		result.frozen,
+		cfg.provenance,
 	); err != nil {
 		die("failed to write outputs: %v", err)
 	}
 
-	fmt.Printf("Done evals=%d elapsed=%.1fs best_score=%.4f best_similarity=%.2f%% variant=%s\n", result.evals, result.elapsed, result.bestMetrics.Score, result.bestMetrics.Similarity*100.0, strings.ToLower(*mayflyVariant))
+	if *pareto {
+		dir := *paretoDirPath
+		if dir == "" {
+			dir = paretoDir(*outputPreset)
+		}
+		if err := writeParetoFront(cfg, dir, result.front, result.elapsed, result.evals); err != nil {
+			die("failed to write pareto front: %v", err)
+		}
+		log.Info("pareto front written", "dir", dir, "candidates", len(result.front))
+	}
+
+	done := []any{
+		"evals", result.evals,
+		"elapsed_s", result.elapsed,
+		"best_score", result.bestMetrics.Score,
+		"best_similarity_pct", result.bestMetrics.Similarity * 100.0,
+		"variant", strings.ToLower(*mayflyVariant),
+		"interrupted", result.interrupted,
+	}
+	if *restartAfter > 0 {
+		done = append(done, "restarts", result.restarts)
+	}
+	if *validation {
+		done = append(done,
+			"validation_score", *result.bestMetrics.ValidationScore,
+			"validation_similarity_pct", *result.bestMetrics.ValidationSimilarity*100.0)
+	}
+	log.Info("done", done...)
 }
 
 func parseWorkersFlag(raw string) (int, error) {
 	return fitcommon.ParseWorkers(raw)
 }
 
-func loadCandidateFromReport(path string, defs []knobDef, fallback candidate) (candidate, bool, error) {
+// loadCandidateFromReport reads the best knobs of a fit report into a
+// candidate with candidateFromKnobs. A missing report is not an error.
+func loadCandidateFromReport(path string, defs []knobDef, fallback candidate) (candidate, bool, []string, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return fallback, false, nil
+			return fallback, false, nil, nil
 		}
-		return fallback, false, err
+		return fallback, false, nil, err
 	}
 
 	// Use a flexible struct to check both unified best_knobs and legacy best_ir_knobs.
@@ -263,31 +536,51 @@ func loadCandidateFromReport(path string, defs []knobDef, fallback candidate) (c
 		BestIRKnobs map[string]float64 `json:"best_ir_knobs"`
 	}
 	if err := json.Unmarshal(b, &rep); err != nil {
-		return fallback, false, err
+		return fallback, false, nil, err
 	}
 
 	knobs := rep.BestKnobs
 	if len(knobs) == 0 {
 		knobs = rep.BestIRKnobs // backwards compat with piano-fit-ir reports
 	}
-	if len(knobs) == 0 {
-		return fallback, false, nil
-	}
+	return candidateFromKnobs(knobs, defs, fallback)
+}
 
+// candidateFromKnobs returns fallback with the knobs found in knobs (by
+// name) replaced, clamped to their ranges, and whether any was found.
+// Known knobs this fit does not search are returned in skipped; names no
+// fit knows (typos, per-note names of another spelling) and non-finite
+// values are errors rather than silently dropped.
+func candidateFromKnobs(knobs map[string]float64, defs []knobDef, fallback candidate) (c candidate, ok bool, skipped []string, err error) {
+	index := make(map[string]int, len(defs))
+	for i, d := range defs {
+		index[d.Name] = i
+	}
 	vals := make([]float64, len(fallback.Vals))
 	copy(vals, fallback.Vals)
 	updated := false
-	for i, d := range defs {
-		if v, ok := knobs[d.Name]; ok {
-			vals[i] = clamp(v, d.Min, d.Max)
-			if d.IsInt {
-				vals[i] = math.Round(vals[i])
+	for _, name := range slices.Sorted(maps.Keys(knobs)) {
+		v := knobs[name]
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fallback, false, nil, fmt.Errorf("knob %s: value %g is not finite", name, v)
+		}
+		i, found := index[name]
+		if !found {
+			if !knownKnob(name) {
+				return fallback, false, nil, fmt.Errorf("unknown knob %q", name)
 			}
-			updated = true
+			skipped = append(skipped, name)
+			continue
+		}
+		d := defs[i]
+		vals[i] = clamp(v, d.Min, d.Max)
+		if d.IsInt {
+			vals[i] = math.Round(vals[i])
 		}
+		updated = true
 	}
 	if !updated {
-		return fallback, false, nil
+		return fallback, false, skipped, nil
 	}
-	return candidate{Vals: vals}, true, nil
+	return candidate{Vals: vals}, true, skipped, nil
 }
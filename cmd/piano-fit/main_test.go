@@ -6,23 +6,6 @@ import (
 	"testing"
 )
 
-func TestPresetIRPathRelativizesFromPresetDir(t *testing.T) {
-	presetPath := filepath.Join("assets", "presets", "fitted-c4.json")
-	irPath := filepath.Join("assets", "ir", "default_96k.wav")
-
-	got := presetIRPath(presetPath, irPath)
-	want := filepath.ToSlash(filepath.Join("..", "ir", "default_96k.wav"))
-	if got != want {
-		t.Fatalf("presetIRPath() = %q, want %q", got, want)
-	}
-}
-
-func TestPresetIRPathEmpty(t *testing.T) {
-	if got := presetIRPath("assets/presets/fitted.json", ""); got != "" {
-		t.Fatalf("presetIRPath() = %q, want empty", got)
-	}
-}
-
 func TestParseWorkersFlag(t *testing.T) {
 	tests := []struct {
 		in      string
@@ -68,7 +51,7 @@ func TestLoadCandidateFromReportBestKnobs(t *testing.T) {
 	}
 	fallback := candidate{Vals: []float64{1.0, 1.0}}
 
-	got, ok, err := loadCandidateFromReport(reportPath, defs, fallback)
+	got, ok, _, err := loadCandidateFromReport(reportPath, defs, fallback)
 	if err != nil {
 		t.Fatalf("load report: %v", err)
 	}
@@ -96,7 +79,7 @@ func TestLoadCandidateFromReportBestIRKnobs(t *testing.T) {
 	}
 	fallback := candidate{Vals: []float64{48, 1.0}}
 
-	got, ok, err := loadCandidateFromReport(reportPath, defs, fallback)
+	got, ok, _, err := loadCandidateFromReport(reportPath, defs, fallback)
 	if err != nil {
 		t.Fatalf("load report: %v", err)
 	}
@@ -116,7 +99,7 @@ func TestLoadCandidateFromReportMissingFile(t *testing.T) {
 	defs := []knobDef{{Name: "x", Min: 0, Max: 1}}
 	fallback := candidate{Vals: []float64{0.5}}
 
-	_, ok, err := loadCandidateFromReport("/nonexistent/path.json", defs, fallback)
+	_, ok, _, err := loadCandidateFromReport("/nonexistent/path.json", defs, fallback)
 	if err != nil {
 		t.Fatalf("unexpected error for missing file: %v", err)
 	}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -15,11 +18,22 @@ import (
 	"time"
 
 	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
 	"github.com/cwbudde/algo-piano/irsynth"
 	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/render"
 	"github.com/cwbudde/mayfly"
 )
 
+// The random streams of a fit all derive from --seed: round r runs from
+// seed + r*roundSeedStride (+1 for its Pareto weights), and the initial
+// design and the annealed restarts use offsets below the first round.
+const (
+	roundSeedStride   = 7919
+	designSeedOffset  = -1
+	restartSeedOffset = -2
+)
+
 type topCandidate struct {
 	Eval       int                `json:"eval"`
 	Score      float64            `json:"score"`
@@ -28,8 +42,8 @@ type topCandidate struct {
 }
 
 type optimizationConfig struct {
-	reference        []float64
-	finalReference   []float64
+	references       [][]float64 // one per reference take
+	finalReferences  [][]float64
 	baseParams       *piano.Params
 	defs             []knobDef
 	initCandidate    candidate
@@ -51,6 +65,14 @@ type optimizationConfig struct {
 	finalMaxDuration float64
 	renderBlockSize  int
 	refineTopK       int
+	initSamples      int     // Latin hypercube points evaluated before the rounds (0 = off)
+	warmEngine       bool    // reuse one engine per worker across evaluations
+	freezeAfter      int     // improvements a knob must stay put before it is frozen (0 = off)
+	freezeTol        float64 // normalized distance that counts as staying put
+	restartAfter     int     // rounds without improvement before an annealed restart (0 = off)
+	restartFraction  float64 // share of a restarted round's males seeded around the best
+	restartTemp      float64 // first restart's perturbation, in normalized knob units
+	restartCooling   float64 // temperature factor per restart
 	mayflyVariant    string
 	mayflyPop        int
 	mayflyRoundEvals int
@@ -63,10 +85,16 @@ type optimizationConfig struct {
 	reportPath       string
 	referencePath    string
 	presetPath       string
+	notes            []chordNote // rendered scenario; notes[0].Note == note
+	pedal            pedalTiming // sustain-pedal schedule (noPedal = pedal up)
+	compareOptions   analysis.CompareOptions
+	provenance       *fitcommon.Provenance
+	log              *slog.Logger // progress records (nil discards them)
+	paretoSize       int          // >0 tracks a Pareto front of at most this many candidates (--pareto)
 }
 
 type evalSettings struct {
-	reference       []float64
+	references      [][]float64
 	sampleRate      int
 	minDuration     float64
 	maxDuration     float64
@@ -83,6 +111,7 @@ type optimizationEval struct {
 	roomIRR      []float32 // stereo room IR right
 	velocity     int
 	releaseAfter float64
+	pedal        pedalTiming
 }
 
 type optimizationResult struct {
@@ -94,10 +123,15 @@ type optimizationResult struct {
 	bestRoomIRR      []float32
 	bestVelocity     int
 	bestReleaseAfter float64
+	bestPedal        pedalTiming
 	top              []topCandidate
 	evals            int
 	elapsed          float64
 	checkpoints      int
+	interrupted      bool           // ctx was cancelled before the budget was used up
+	front            []paretoMember // Pareto front by spectral objective (--pareto)
+	frozen           []frozenKnob   // knobs frozen during the search (--freeze-after)
+	restarts         int            // annealed restarts (--restart-after)
 }
 
 type optimizationState struct {
@@ -106,18 +140,40 @@ type optimizationState struct {
 	bestEval    optimizationEval
 	top         []topCandidate
 	checkpoints int
+	front       *paretoFront      // nil unless cfg.paretoSize > 0
+	freezer     *knobFreezer      // nil unless cfg.freezeAfter > 0
+	restarts    *annealedRestarts // nil unless cfg.restartAfter > 0
 }
 
-func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
+// runOptimization runs the Mayfly rounds until the time or evaluation
+// budget is used up or ctx is cancelled, then re-evaluates the best
+// candidates at the final settings. Cancellation lets in-flight
+// evaluations finish and skips the remaining refinement, so the result
+// still holds the best candidate found so far for the caller to write.
+func runOptimization(ctx context.Context, cfg *optimizationConfig) (*optimizationResult, error) {
 	if err := os.MkdirAll(cfg.workDir, 0o755); err != nil {
 		return nil, fmt.Errorf("failed to create work-dir: %w", err)
 	}
 
+	log := cfg.log
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
+
 	start := time.Now()
-	deadline := start.Add(time.Duration(cfg.timeBudget * float64(time.Second)))
+	budget := time.Duration(cfg.timeBudget * float64(time.Second))
+	deadline := start.Add(budget)
+	planner := newRoundPlanner(cfg)
+	// The search ends early enough to leave the refinement its time.
+	searchEnd := func() time.Time {
+		return deadline.Add(-planner.refineReserve(budget))
+	}
+	stopped := func() bool {
+		return ctx.Err() != nil || time.Now().After(searchEnd())
+	}
 	variant := strings.ToLower(cfg.mayflyVariant)
 	optEvalSettings := evalSettings{
-		reference:       cfg.reference,
+		references:      cfg.references,
 		sampleRate:      cfg.sampleRate,
 		minDuration:     cfg.minDuration,
 		maxDuration:     cfg.maxDuration,
@@ -126,7 +182,7 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 		renderBlockSize: cfg.renderBlockSize,
 	}
 	finalEvalSettings := evalSettings{
-		reference:       cfg.finalReference,
+		references:      cfg.finalReferences,
 		sampleRate:      cfg.finalSampleRate,
 		minDuration:     cfg.finalMinDuration,
 		maxDuration:     cfg.finalMaxDuration,
@@ -137,16 +193,31 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 
 	initialScratch := filepath.Join(cfg.workDir, "candidate_ir_init.wav")
 	best := cloneCandidate(cfg.initCandidate)
-	initialEval, err := evaluateCandidate(cfg, best, initialScratch, optEvalSettings)
+	evalStart := time.Now()
+	initialEval, err := evaluateCandidate(cfg, best, initialScratch, nil, optEvalSettings)
 	if err != nil {
 		return nil, fmt.Errorf("initial evaluation failed: %w", err)
 	}
-	fmt.Printf("Start score=%.4f similarity=%.2f%% [%s]\n", initialEval.metrics.Score, initialEval.metrics.Similarity*100.0, formatDominant(initialEval.metrics))
+	planner.observeEval(time.Since(evalStart))
+	log.Info("start",
+		append([]any{
+			"score", initialEval.metrics.Score,
+			"similarity_pct", initialEval.metrics.Similarity * 100.0,
+			"dominant", formatDominant(initialEval.metrics),
+			"knobs", len(cfg.defs),
+			"workers", cfg.workers,
+		}, extraTermAttrs(initialEval.metrics, cfg.compareOptions)...)...)
 
 	state := &optimizationState{
 		best:     best,
 		bestEval: cloneOptimizationEval(initialEval),
 		top:      updateTopCandidates(nil, cfg.topK, 1, initialEval.metrics, cfg.defs, best),
+		freezer:  newKnobFreezer(cfg.defs, best, cfg.freezeAfter, cfg.freezeTol),
+		restarts: newAnnealedRestarts(cfg.restartAfter, cfg.restartFraction, cfg.restartTemp, cfg.restartCooling, cfg.seed+restartSeedOffset),
+	}
+	if cfg.paretoSize > 0 {
+		state.front = &paretoFront{size: cfg.paretoSize}
+		state.front.add(1, best, initialEval)
 	}
 
 	if _, err := os.Stat(cfg.outputPreset); err != nil && errors.Is(err, os.ErrNotExist) {
@@ -158,8 +229,10 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 			cfg.presetPath,
 			optEvalSettings.sampleRate,
 			cfg.note,
+			cfg.notes,
 			initialEval.velocity,
 			initialEval.releaseAfter,
+			initialEval.pedal,
 			time.Since(start).Seconds(),
 			1,
 			variant,
@@ -172,8 +245,11 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 			initialEval.roomIRR,
 			0,
 			state.top,
+			false,
+			nil,
+			cfg.provenance,
 		); err != nil {
-			fmt.Fprintf(os.Stderr, "initial write failed: %v\n", err)
+			log.Warn("initial write failed", "err", err)
 		}
 	}
 
@@ -183,6 +259,126 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 	var outputMu sync.Mutex
 	var latestPersistedImprove int64
 
+	// evaluate renders and scores cand for a worker and records it in the
+	// best candidate, top-K list, Pareto front and freezer, writing a
+	// checkpoint when one is due. It returns nil and a penalty score when the
+	// search is stopped, the evaluation budget is used up or rendering fails.
+	evaluate := func(workerID int, scratch string, engine *warmEngine, cand candidate) (*analysis.Metrics, float64) {
+		if stopped() {
+			return nil, currentBestScore(state) + 1.0
+		}
+		evalNum, ok := reserveEval(&evals, cfg.maxEvals)
+		if !ok {
+			return nil, currentBestScore(state) + 1.0
+		}
+
+		evalStart := time.Now()
+		evalRes, err := evaluateCandidate(cfg, cand, scratch, engine, optEvalSettings)
+		planner.observeEval(time.Since(evalStart))
+		if err != nil {
+			log.Debug("eval failed", "worker", workerID, "eval", evalNum, "err", err)
+			return nil, currentBestScore(state) + 0.8
+		}
+		log.Debug("eval", "worker", workerID, "eval", evalNum, "score", evalRes.metrics.Score)
+
+		improved := false
+		var improveNum int64
+		checkpointDue := false
+		var bestSnapshot candidate
+		var bestEvalSnapshot optimizationEval
+		var topSnapshot []topCandidate
+		var frozenSnapshot []frozenKnob
+		bestScore := 0.0
+
+		state.mu.Lock()
+		state.top = updateTopCandidates(state.top, cfg.topK, int(evalNum), evalRes.metrics, cfg.defs, cand)
+		if state.front != nil && state.front.add(int(evalNum), cand, evalRes) {
+			log.Debug("pareto front", "eval", evalNum, "size", len(state.front.members))
+		}
+		if evalRes.metrics.Score < state.bestEval.metrics.Score {
+			state.best = cloneCandidate(cand)
+			state.bestEval = cloneOptimizationEval(evalRes)
+			improved = true
+			improveNum = atomic.AddInt64(&improves, 1)
+			for _, k := range state.freezer.observe(cfg.defs, cand, int(evalNum), int(improveNum), time.Since(start).Seconds()) {
+				log.Info("knob frozen", "knob", k.Name, "value", k.Value, "eval", k.Eval, "improvement", k.Improvement, "elapsed_s", k.ElapsedSec)
+			}
+			if cfg.checkpointEvery > 0 && improveNum%int64(cfg.checkpointEvery) == 0 {
+				checkpointDue = true
+			}
+			bestSnapshot = cloneCandidate(state.best)
+			bestEvalSnapshot = cloneOptimizationEval(state.bestEval)
+			topSnapshot = cloneTopCandidates(state.top)
+			frozenSnapshot = state.freezer.frozenKnobs()
+		}
+		bestScore = state.bestEval.metrics.Score
+		state.mu.Unlock()
+
+		if improved {
+			log.Info("improved",
+				append([]any{
+					"n", improveNum,
+					"eval", evalNum,
+					"worker", workerID,
+					"score", bestEvalSnapshot.metrics.Score,
+					"similarity_pct", bestEvalSnapshot.metrics.Similarity * 100.0,
+					"dominant", formatDominant(bestEvalSnapshot.metrics),
+				}, extraTermAttrs(bestEvalSnapshot.metrics, cfg.compareOptions)...)...)
+			outputMu.Lock()
+			if improveNum > latestPersistedImprove {
+				latestPersistedImprove = improveNum
+				if checkpointDue {
+					state.mu.Lock()
+					checkpointNum := state.checkpoints + 1
+					state.mu.Unlock()
+					if err := writeOutputs(
+						cfg.outputIR,
+						cfg.outputPreset,
+						cfg.reportPath,
+						cfg.referencePath,
+						cfg.presetPath,
+						optEvalSettings.sampleRate,
+						cfg.note,
+						cfg.notes,
+						bestEvalSnapshot.velocity,
+						bestEvalSnapshot.releaseAfter,
+						bestEvalSnapshot.pedal,
+						time.Since(start).Seconds(),
+						int(atomic.LoadInt64(&evals)),
+						variant,
+						cfg.defs,
+						bestSnapshot,
+						bestEvalSnapshot.metrics,
+						bestEvalSnapshot.params,
+						bestEvalSnapshot.bodyIR,
+						bestEvalSnapshot.roomIRL,
+						bestEvalSnapshot.roomIRR,
+						checkpointNum,
+						topSnapshot,
+						false,
+						frozenSnapshot,
+						cfg.provenance,
+					); err != nil {
+						log.Warn("checkpoint write failed", "checkpoint", checkpointNum, "err", err)
+					} else {
+						log.Debug("checkpoint", "checkpoint", checkpointNum, "eval", evalNum)
+						state.mu.Lock()
+						if checkpointNum > state.checkpoints {
+							state.checkpoints = checkpointNum
+						}
+						state.mu.Unlock()
+					}
+				}
+			}
+			outputMu.Unlock()
+		}
+
+		if cfg.reportEvery > 0 && evalNum%int64(cfg.reportEvery) == 0 {
+			log.Info("progress", "eval", evalNum, "max_evals", cfg.maxEvals, "elapsed_s", time.Since(start).Seconds(), "best", bestScore)
+		}
+		return &evalRes.metrics, 0
+	}
+
 	workers := cfg.workers
 	if workers == 0 {
 		workers = runtime.GOMAXPROCS(0)
@@ -191,138 +387,146 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 		workers = 1
 	}
 
+	// The initial design spreads --init-samples Latin hypercube points over
+	// the search space before any round runs; its best points seed the
+	// rounds' male populations.
+	var design []designPoint
+	if cfg.initSamples > 0 {
+		points := latinHypercube(cfg.initSamples, len(cfg.defs), rand.New(rand.NewSource(cfg.seed+designSeedOffset)))
+		metrics := make([]*analysis.Metrics, len(points))
+		var next int64
+		var dwg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			dwg.Add(1)
+			go func(workerID int) {
+				defer dwg.Done()
+				scratch := filepath.Join(cfg.workDir, fmt.Sprintf("candidate_ir_worker_%d.wav", workerID))
+				engine := newWarmEngine(cfg.warmEngine)
+				for !stopped() {
+					j := int(atomic.AddInt64(&next, 1)) - 1
+					if j >= len(points) {
+						return
+					}
+					metrics[j], _ = evaluate(workerID, scratch, engine, fromNormalized(points[j], cfg.defs))
+				}
+			}(i + 1)
+		}
+		dwg.Wait()
+		design = rankDesign(points, metrics)
+		log.Info("initial design", "samples", len(points), "evaluated", len(design), "best", currentBestScore(state))
+	}
+
 	var wg sync.WaitGroup
 	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
 			workerScratch := filepath.Join(cfg.workDir, fmt.Sprintf("candidate_ir_worker_%d.wav", workerID))
+			engine := newWarmEngine(cfg.warmEngine)
 			for {
-				if time.Now().After(deadline) {
+				if stopped() {
 					return
 				}
 				if atomic.LoadInt64(&evals) >= int64(cfg.maxEvals) {
 					return
 				}
 
-				round := int(atomic.AddInt64(&rounds, 1))
 				remaining := cfg.maxEvals - int(atomic.LoadInt64(&evals))
 				if remaining <= 0 {
 					return
 				}
-				budget := minInt(cfg.mayflyRoundEvals, remaining)
-				iters := maxInt(1, budget/(2*cfg.mayflyPop))
+				iters, ok := planner.plan(time.Until(searchEnd()), remaining)
+				if !ok {
+					log.Debug("no round fits the remaining search time", "worker", workerID, "eval_cost_s", planner.evalCost().Seconds())
+					return
+				}
+				round := int(atomic.AddInt64(&rounds, 1))
 
-				mayflyConfig, err := newMayflyConfig(variant, cfg.mayflyPop, len(cfg.defs), iters)
+				// Rounds search only the knobs that are not frozen yet. A
+				// plateaued search restarts around the best candidate.
+				state.mu.Lock()
+				active, frozenPos := state.freezer.space(len(cfg.defs))
+				restart, temp := state.restarts.seeds(toNormalized(state.best, cfg.defs), active, cfg.mayflyPop)
+				restartNum := state.restarts.restarts()
+				state.mu.Unlock()
+				mayflyConfig, err := newMayflyConfig(variant, cfg.mayflyPop, len(active), iters)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "mayfly round %d setup failed: %v\n", round, err)
+					log.Error("mayfly round setup failed", "round", round, "err", err)
 					return
 				}
-				mayflyConfig.Rand = rand.New(rand.NewSource(cfg.seed + int64(round)*7919))
-				mayflyConfig.ObjectiveFunc = func(pos []float64) float64 {
-					if time.Now().After(deadline) {
-						return currentBestScore(state) + 1.0
-					}
-					evalNum, ok := reserveEval(&evals, cfg.maxEvals)
-					if !ok {
-						return currentBestScore(state) + 1.0
-					}
-
-					cand := fromNormalized(pos, cfg.defs)
-					evalRes, err := evaluateCandidate(cfg, cand, workerScratch, optEvalSettings)
-					if err != nil {
-						return currentBestScore(state) + 0.8
-					}
-
-					improved := false
-					var improveNum int64
-					checkpointDue := false
-					var bestSnapshot candidate
-					var bestEvalSnapshot optimizationEval
-					var topSnapshot []topCandidate
-					bestScore := 0.0
-
-					state.mu.Lock()
-					state.top = updateTopCandidates(state.top, cfg.topK, int(evalNum), evalRes.metrics, cfg.defs, cand)
-					if evalRes.metrics.Score < state.bestEval.metrics.Score {
-						state.best = cloneCandidate(cand)
-						state.bestEval = cloneOptimizationEval(evalRes)
-						improved = true
-						improveNum = atomic.AddInt64(&improves, 1)
-						if cfg.checkpointEvery > 0 && improveNum%int64(cfg.checkpointEvery) == 0 {
-							checkpointDue = true
-						}
-						bestSnapshot = cloneCandidate(state.best)
-						bestEvalSnapshot = cloneOptimizationEval(state.bestEval)
-						topSnapshot = cloneTopCandidates(state.top)
+				log.Debug("round", "worker", workerID, "round", round, "iters", iters, "dims", len(active), "calls_per_iter", planner.callsPerIter(), "eval_cost_s", planner.evalCost().Seconds())
+				// Pareto rounds each minimize their own weighting of the objectives.
+				var weights *paretoPoint
+				if state.front != nil {
+					w := paretoWeights(rand.New(rand.NewSource(cfg.seed + int64(round)*roundSeedStride + 1)))
+					weights = &w
+					log.Debug("pareto weights", "round", round, "spectral", w[0], "envelope", w[1], "decay", w[2])
+				}
+				// Rounds over all knobs start from points of the initial
+				// design, whose scores are already known; restarted rounds
+				// from the unscored perturbations of the best.
+				var seeds []designPoint
+				known := 0
+				if restart != nil {
+					for _, pos := range restart {
+						seeds = append(seeds, designPoint{pos: pos})
 					}
-					bestScore = state.bestEval.metrics.Score
-					state.mu.Unlock()
-
-					if improved {
-						fmt.Printf("Improved #%d eval=%d score=%.4f sim=%.2f%% [%s]\n", improveNum, evalNum, bestEvalSnapshot.metrics.Score, bestEvalSnapshot.metrics.Similarity*100.0, formatDominant(bestEvalSnapshot.metrics))
-						outputMu.Lock()
-						if improveNum > latestPersistedImprove {
-							latestPersistedImprove = improveNum
-							if checkpointDue {
-								state.mu.Lock()
-								checkpointNum := state.checkpoints + 1
-								state.mu.Unlock()
-								if err := writeOutputs(
-									cfg.outputIR,
-									cfg.outputPreset,
-									cfg.reportPath,
-									cfg.referencePath,
-									cfg.presetPath,
-									optEvalSettings.sampleRate,
-									cfg.note,
-									bestEvalSnapshot.velocity,
-									bestEvalSnapshot.releaseAfter,
-									time.Since(start).Seconds(),
-									int(atomic.LoadInt64(&evals)),
-									variant,
-									cfg.defs,
-									bestSnapshot,
-									bestEvalSnapshot.metrics,
-									bestEvalSnapshot.params,
-									bestEvalSnapshot.bodyIR,
-									bestEvalSnapshot.roomIRL,
-									bestEvalSnapshot.roomIRR,
-									checkpointNum,
-									topSnapshot,
-								); err != nil {
-									fmt.Fprintf(os.Stderr, "checkpoint write failed: %v\n", err)
-								} else {
-									state.mu.Lock()
-									if checkpointNum > state.checkpoints {
-										state.checkpoints = checkpointNum
-									}
-									state.mu.Unlock()
-								}
-							}
+					log.Info("annealed restart", "round", round, "restart", restartNum, "temperature", temp, "seeds", len(seeds))
+				} else if frozenPos == nil {
+					seeds = roundSeeds(design, cfg.mayflyPop, round)
+					known = len(seeds)
+				}
+				src := rand.NewSource(cfg.seed + int64(round)*roundSeedStride)
+				if len(seeds) > 0 {
+					src = newSeededSource(seeds, src)
+				}
+				mayflyConfig.Rand = rand.New(src)
+				calls := 0
+				mayflyConfig.ObjectiveFunc = func(pos []float64) float64 {
+					calls++
+					var metrics *analysis.Metrics
+					if calls <= known && slices.Equal(pos, seeds[calls-1].pos) {
+						metrics = &seeds[calls-1].metrics
+					} else {
+						cand := fromNormalized(expandPosition(pos, active, frozenPos), cfg.defs)
+						var penalty float64
+						if metrics, penalty = evaluate(workerID, workerScratch, engine, cand); metrics == nil {
+							return penalty
 						}
-						outputMu.Unlock()
 					}
-
-					if cfg.reportEvery > 0 && evalNum%int64(cfg.reportEvery) == 0 {
-						fmt.Printf("Progress eval=%d/%d elapsed=%.1fs best=%.4f\n", evalNum, cfg.maxEvals, time.Since(start).Seconds(), bestScore)
+					if weights != nil {
+						return paretoScore(*metrics, *weights)
 					}
-					return evalRes.metrics.Score
+					return metrics.Score
 				}
 
 				if _, err := runMayfly(mayflyConfig); err != nil {
-					fmt.Fprintf(os.Stderr, "mayfly round %d failed: %v\n", round, err)
+					log.Error("mayfly round failed", "round", round, "err", err)
+				} else {
+					planner.observeRound(iters, calls)
 				}
+				state.mu.Lock()
+				state.restarts.roundDone(atomic.LoadInt64(&improves))
+				state.mu.Unlock()
 			}
 		}(i + 1)
 	}
 	wg.Wait()
+	if ctx.Err() != nil {
+		log.Warn("interrupted: skipping refinement, writing the best candidate", "evals", atomic.LoadInt64(&evals))
+	}
 
 	state.mu.Lock()
 	finalBest := cloneCandidate(state.best)
 	finalEval := cloneOptimizationEval(state.bestEval)
 	finalTop := cloneTopCandidates(state.top)
 	finalCheckpoints := state.checkpoints
+	frozen := state.freezer.frozenKnobs()
+	restarts := state.restarts.restarts()
+	var front []paretoMember
+	if state.front != nil {
+		front = state.front.sorted()
+	}
 	state.mu.Unlock()
 
 	refineTopK := cfg.refineTopK
@@ -354,13 +558,18 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 	var refinedBest candidate
 	var refinedEval optimizationEval
 	hasRefinedBest := false
+	refineEngine := newWarmEngine(cfg.warmEngine)
 	for i, cand := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
 		scratchPath := filepath.Join(cfg.workDir, fmt.Sprintf("candidate_ir_refine_%d.wav", i+1))
-		evalRes, err := evaluateCandidate(cfg, cand, scratchPath, finalEvalSettings)
+		evalRes, err := evaluateCandidate(cfg, cand, scratchPath, refineEngine, finalEvalSettings)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "refine eval %d failed: %v\n", i+1, err)
+			log.Warn("refine eval failed", "candidate", i+1, "err", err)
 			continue
 		}
+		log.Debug("refine", "candidate", i+1, "score", evalRes.metrics.Score)
 		refinedTop = updateTopCandidates(refinedTop, cfg.topK, i+1, evalRes.metrics, cfg.defs, cand)
 		if !hasRefinedBest || evalRes.metrics.Score < refinedEval.metrics.Score {
 			refinedBest = cloneCandidate(cand)
@@ -385,15 +594,20 @@ func runOptimization(cfg *optimizationConfig) (*optimizationResult, error) {
 		bestRoomIRR:      finalEval.roomIRR,
 		bestVelocity:     finalEval.velocity,
 		bestReleaseAfter: finalEval.releaseAfter,
+		bestPedal:        finalEval.pedal,
 		top:              finalTop,
 		evals:            int(atomic.LoadInt64(&evals)),
 		elapsed:          time.Since(start).Seconds(),
 		checkpoints:      finalCheckpoints,
+		interrupted:      ctx.Err() != nil,
+		front:            front,
+		frozen:           frozen,
+		restarts:         restarts,
 	}, nil
 }
 
-func evaluateCandidate(cfg *optimizationConfig, cand candidate, scratchPath string, settings evalSettings) (optimizationEval, error) {
-	irCfgs, params, evalVelocity, evalReleaseAfter := applyCandidate(
+func evaluateCandidate(cfg *optimizationConfig, cand candidate, scratchPath string, engine *warmEngine, settings evalSettings) (optimizationEval, error) {
+	irCfgs, params, evalVelocity, evalReleaseAfter, err := applyCandidate(
 		cfg.baseParams,
 		settings.sampleRate,
 		cfg.note,
@@ -402,33 +616,26 @@ func evaluateCandidate(cfg *optimizationConfig, cand candidate, scratchPath stri
 		cfg.defs,
 		cand,
 	)
+	if err != nil {
+		return optimizationEval{}, err
+	}
+	evalPedal := applyPedalKnobs(cfg.pedal, cfg.defs, cand)
 
 	if needsIRSynthesis(cfg.groups) {
 		// IR synthesis mode: generate body/room IR, render with dual IR buffers.
-		var bodyIR []float32
-		var roomL, roomR []float32
-		if cfg.groups["body-ir"] {
-			ir, err := irsynth.GenerateBody(irCfgs.body)
-			if err != nil {
-				return optimizationEval{}, fmt.Errorf("body IR: %w", err)
-			}
-			bodyIR = ir
-		}
-		if cfg.groups["room-ir"] {
-			l, r, err := irsynth.GenerateRoom(irCfgs.room)
-			if err != nil {
-				return optimizationEval{}, fmt.Errorf("room IR: %w", err)
-			}
-			roomL, roomR = l, r
+		bodyIR, roomL, roomR, err := synthesizeIRs(cfg.groups, irCfgs)
+		if err != nil {
+			return optimizationEval{}, err
 		}
 		// Clear IR paths so NewPiano won't load from disk; we set buffers directly.
 		params.IRWavPath = ""
 		params.BodyIRWavPath = ""
 		params.RoomIRWavPath = ""
 		mono, _, err := renderCandidateWithDualIR(
+			engine,
 			params,
 			bodyIR, roomL, roomR,
-			cfg.note,
+			cfg.notes,
 			evalVelocity,
 			settings.sampleRate,
 			settings.decayDBFS,
@@ -437,25 +644,28 @@ func evaluateCandidate(cfg *optimizationConfig, cand candidate, scratchPath stri
 			settings.maxDuration,
 			settings.renderBlockSize,
 			evalReleaseAfter,
+			evalPedal,
 		)
 		if err != nil {
 			return optimizationEval{}, err
 		}
 		return optimizationEval{
-			metrics:      analysis.Compare(settings.reference, mono, settings.sampleRate),
+			metrics:      compareCandidate(cfg, settings, mono),
 			params:       params,
 			bodyIR:       bodyIR,
 			roomIRL:      roomL,
 			roomIRR:      roomR,
 			velocity:     evalVelocity,
 			releaseAfter: evalReleaseAfter,
+			pedal:        evalPedal,
 		}, nil
 	}
 
 	// Non-IR mode: load IR from disk via renderCandidateFromParams.
 	mono, _, err := renderCandidateFromParams(
+		engine,
 		params,
-		cfg.note,
+		cfg.notes,
 		evalVelocity,
 		settings.sampleRate,
 		settings.decayDBFS,
@@ -464,24 +674,78 @@ func evaluateCandidate(cfg *optimizationConfig, cand candidate, scratchPath stri
 		settings.maxDuration,
 		settings.renderBlockSize,
 		evalReleaseAfter,
+		evalPedal,
 	)
 	if err != nil {
 		return optimizationEval{}, err
 	}
 	return optimizationEval{
-		metrics:      analysis.Compare(settings.reference, mono, settings.sampleRate),
+		metrics:      analysis.CompareMulti(settings.references, mono, settings.sampleRate, cfg.compareOptions),
 		params:       params,
 		velocity:     evalVelocity,
 		releaseAfter: evalReleaseAfter,
+		pedal:        evalPedal,
 	}, nil
 }
 
+// synthesizeIRs generates the body and room IRs of the active IR groups
+// (nil for an inactive group).
+func synthesizeIRs(groups map[string]bool, irCfgs irConfigs) (bodyIR, roomL, roomR []float32, err error) {
+	defer fitcommon.StartStage("ir-synth")()
+	if groups["body-ir"] {
+		if bodyIR, err = irsynth.GenerateBody(irCfgs.body); err != nil {
+			return nil, nil, nil, fmt.Errorf("body IR: %w", err)
+		}
+	}
+	if groups["room-ir"] {
+		if roomL, roomR, err = irsynth.GenerateRoom(irCfgs.room); err != nil {
+			return nil, nil, nil, fmt.Errorf("room IR: %w", err)
+		}
+	}
+	return bodyIR, roomL, roomR, nil
+}
+
+// compareCandidate scores a render against the references.
+func compareCandidate(cfg *optimizationConfig, settings evalSettings, mono []float64) analysis.Metrics {
+	defer fitcommon.StartStage("metrics")()
+	return analysis.CompareMulti(settings.references, mono, settings.sampleRate, cfg.compareOptions)
+}
+
+// warmEngine keeps one engine across evaluations for --warm-engine so a
+// candidate resets it instead of rebuilding the strings and reloading the IR
+// WAV. A nil warmEngine renders every candidate on a fresh engine.
+type warmEngine struct {
+	p          *piano.Piano
+	sampleRate int
+}
+
+func newWarmEngine(enabled bool) *warmEngine {
+	if !enabled {
+		return nil
+	}
+	return &warmEngine{}
+}
+
+func (w *warmEngine) engine(sampleRate int, params *piano.Params) *piano.Piano {
+	if w == nil {
+		return piano.NewPiano(sampleRate, 16, params)
+	}
+	if w.p == nil || w.sampleRate != sampleRate {
+		w.p = piano.NewPiano(sampleRate, 16, params)
+		w.sampleRate = sampleRate
+		return w.p
+	}
+	w.p.Reset(params)
+	return w.p
+}
+
 func renderCandidateWithDualIR(
+	engine *warmEngine,
 	params *piano.Params,
 	bodyIR []float32,
 	roomIRL []float32,
 	roomIRR []float32,
-	note int,
+	notes []chordNote,
 	velocity int,
 	sampleRate int,
 	decayDBFS float64,
@@ -490,23 +754,27 @@ func renderCandidateWithDualIR(
 	maxDuration float64,
 	blockSize int,
 	releaseAfter float64,
+	pedal pedalTiming,
 ) ([]float64, []float32, error) {
 	if params == nil {
 		return nil, nil, errors.New("nil params")
 	}
-	p := piano.NewPiano(sampleRate, 16, params)
+	endSetup := fitcommon.StartStage("setup")
+	p := engine.engine(sampleRate, params)
 	if len(bodyIR) > 0 {
 		p.SetBodyIR(bodyIR)
 	}
 	if len(roomIRL) > 0 && len(roomIRR) > 0 {
 		p.SetRoomIR(roomIRL, roomIRR)
 	}
-	return renderPiano(p, note, velocity, sampleRate, decayDBFS, decayHoldBlocks, minDuration, maxDuration, blockSize, releaseAfter)
+	endSetup()
+	return renderPiano(p, notes, velocity, sampleRate, decayDBFS, decayHoldBlocks, minDuration, maxDuration, blockSize, releaseAfter, pedal)
 }
 
 func renderCandidateFromParams(
+	engine *warmEngine,
 	params *piano.Params,
-	note int,
+	notes []chordNote,
 	velocity int,
 	sampleRate int,
 	decayDBFS float64,
@@ -515,17 +783,20 @@ func renderCandidateFromParams(
 	maxDuration float64,
 	blockSize int,
 	releaseAfter float64,
+	pedal pedalTiming,
 ) ([]float64, []float32, error) {
 	if params == nil {
 		return nil, nil, errors.New("nil params")
 	}
-	p := piano.NewPiano(sampleRate, 16, params)
-	return renderPiano(p, note, velocity, sampleRate, decayDBFS, decayHoldBlocks, minDuration, maxDuration, blockSize, releaseAfter)
+	endSetup := fitcommon.StartStage("setup")
+	p := engine.engine(sampleRate, params)
+	endSetup()
+	return renderPiano(p, notes, velocity, sampleRate, decayDBFS, decayHoldBlocks, minDuration, maxDuration, blockSize, releaseAfter, pedal)
 }
 
 func renderPiano(
 	p *piano.Piano,
-	note int,
+	notes []chordNote,
 	velocity int,
 	sampleRate int,
 	decayDBFS float64,
@@ -534,63 +805,71 @@ func renderPiano(
 	maxDuration float64,
 	blockSize int,
 	releaseAfter float64,
+	pedal pedalTiming,
 ) ([]float64, []float32, error) {
-	p.NoteOn(note, velocity)
-
-	if decayHoldBlocks < 1 {
-		decayHoldBlocks = 1
-	}
-	if minDuration < 0 {
-		minDuration = 0
-	}
-	if maxDuration < minDuration {
-		maxDuration = minDuration
+	defer fitcommon.StartStage("render")()
+	stop, err := render.NewAutoStopper(render.AutoStopConfig{
+		SampleRate:  sampleRate,
+		DecayDBFS:   decayDBFS,
+		HoldBlocks:  decayHoldBlocks,
+		MinDuration: minDuration,
+		MaxDuration: maxDuration,
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-
-	minFrames := int(float64(sampleRate) * minDuration)
-	maxFrames := int(float64(sampleRate) * maxDuration)
 	releaseAtFrame := int(float64(sampleRate) * releaseAfter)
 	if releaseAtFrame < 0 {
 		releaseAtFrame = 0
 	}
-	if maxFrames < 1 {
-		return nil, nil, errors.New("max duration too small")
+	if len(notes) == 0 {
+		return nil, nil, errors.New("no notes to render")
+	}
+	// Schedule every pedal and note event up front so chord and pedal
+	// timing is sample-accurate. Notes whose onset falls after the release
+	// are never struck.
+	if pedal.active() {
+		p.ScheduleEvent(int(math.Round(float64(sampleRate)*pedal.DownAt)), piano.Event{Kind: piano.EventSustainPedal, Down: true})
+		if pedal.UpAt >= 0 {
+			p.ScheduleEvent(int(math.Round(float64(sampleRate)*pedal.UpAt)), piano.Event{Kind: piano.EventSustainPedal, Down: false})
+		}
+	}
+	// The render may not stop before the last strike.
+	strikesUntil := 0
+	var struck []int
+	for _, n := range notes {
+		onset := int(math.Round(float64(sampleRate) * n.Onset))
+		if onset > releaseAtFrame {
+			continue
+		}
+		p.ScheduleEvent(onset, piano.Event{Kind: piano.EventNoteOn, Note: n.Note, Velocity: velocity})
+		struck = append(struck, n.Note)
+		strikesUntil = max(strikesUntil, onset)
+	}
+	for _, note := range struck {
+		p.ScheduleEvent(releaseAtFrame, piano.Event{Kind: piano.EventNoteOff, Note: note})
 	}
 
-	threshold := math.Pow(10.0, decayDBFS/20.0)
 	if blockSize < 16 {
 		blockSize = 16
 	}
-	framesRendered := 0
-	belowCount := 0
-	noteReleased := false
-	stereo := make([]float32, 0, maxFrames*2)
-
-	for framesRendered < maxFrames {
-		framesToRender := blockSize
-		if framesRendered+framesToRender > maxFrames {
-			framesToRender = maxFrames - framesRendered
-		}
-		if !noteReleased && framesRendered >= releaseAtFrame {
-			p.NoteOff(note)
-			noteReleased = true
-		}
+	stereo := make([]float32, 0, stop.MaxFrames()*2)
+	for !stop.Done() {
+		framesRendered := stop.Rendered()
+		framesToRender := stop.NextBlock(blockSize)
 		block := p.Process(framesToRender)
 		stereo = append(stereo, block...)
-		framesRendered += framesToRender
-
-		if framesRendered >= minFrames {
-			if stereoRMS(block) < threshold {
-				belowCount++
-				if belowCount >= decayHoldBlocks {
-					break
-				}
-			} else {
-				belowCount = 0
-			}
+		if framesRendered+framesToRender <= strikesUntil {
+			stop.Skip(framesToRender)
+			continue
 		}
+		stop.Observe(block, p.ActiveVoices())
 	}
 
+	// Drop the engine's algorithmic latency so the candidate starts at its
+	// onset like the reference; cross-correlation then only absorbs the
+	// recording's own offset.
+	stereo = stereo[min(len(stereo), 2*p.Latency()):]
 	return stereoToMono64(stereo), stereo, nil
 }
 
@@ -606,6 +885,7 @@ func cloneOptimizationEval(in optimizationEval) optimizationEval {
 		params:       cloneParams(in.params),
 		velocity:     in.velocity,
 		releaseAfter: in.releaseAfter,
+		pedal:        in.pedal,
 	}
 	if len(in.bodyIR) > 0 {
 		out.bodyIR = append([]float32(nil), in.bodyIR...)
@@ -757,6 +1037,10 @@ func cloneParams(src *piano.Params) *piano.Params {
 		nv := *v
 		d.PerNote[k] = &nv
 	}
+	d.OutputEQ = append([]piano.EQBand(nil), src.OutputEQ...)
+	d.HighFreqDampingCurve = append(piano.RegisterCurve(nil), src.HighFreqDampingCurve...)
+	d.LossCurve = append(piano.RegisterCurve(nil), src.LossCurve...)
+	d.DamperCurve = append(piano.RegisterCurve(nil), src.DamperCurve...)
 	return &d
 }
 
@@ -813,3 +1097,26 @@ func formatDominant(m analysis.Metrics) string {
 
 	return fmt.Sprintf("%s:%.0f%%", label, pct)
 }
+
+// extraTermAttrs returns the fundamental deviation, the beat-rate mismatch,
+// the T60 curve error and the held-out score as log attributes when they
+// are measured.
+func extraTermAttrs(m analysis.Metrics, opts analysis.CompareOptions) []any {
+	var attrs []any
+	if m.F0Detected {
+		attrs = append(attrs, "f0_cents", m.F0MeanOffsetCents)
+	}
+	if m.BeatDetected {
+		attrs = append(attrs, "beat_hz", m.BeatRateDiffHz)
+	}
+	if m.T60Detected {
+		attrs = append(attrs, "t60_oct", m.T60RMSELog2)
+	}
+	if opts.CentroidWeight > 0 && m.CentroidDetected {
+		attrs = append(attrs, "centroid_oct", m.CentroidRMSELog2)
+	}
+	if opts.Validation {
+		attrs = append(attrs, "validation", *m.ValidationScore)
+	}
+	return attrs
+}
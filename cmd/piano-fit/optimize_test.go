@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
 )
 
 func TestNewMayflyConfig(t *testing.T) {
@@ -87,3 +91,98 @@ func TestCloneCandidateCopiesSlice(t *testing.T) {
 		t.Fatalf("clone mutated original: got %.1f want 1.0", orig.Vals[0])
 	}
 }
+
+func TestWarmEngineRenderMatchesFreshEngine(t *testing.T) {
+	const sampleRate = 16000
+	first := piano.NewDefaultParams()
+	first.IRWavPath = "../../assets/ir/default_96k.wav"
+	second := piano.NewDefaultParams()
+	second.IRWavPath = first.IRWavPath
+	second.OutputGain *= 0.5
+	second.HammerStiffnessScale *= 1.3
+	notes := singleNote(60)
+
+	engine := newWarmEngine(true)
+	if _, _, err := renderCandidateFromParams(engine, first, notes, 100, sampleRate, -200, 1, 0.3, 0.3, 128, 0.2, noPedal); err != nil {
+		t.Fatalf("render first: %v", err)
+	}
+	warm, _, err := renderCandidateFromParams(engine, second, notes, 100, sampleRate, -200, 1, 0.3, 0.3, 128, 0.2, noPedal)
+	if err != nil {
+		t.Fatalf("render warm: %v", err)
+	}
+	fresh, _, err := renderCandidateFromParams(nil, second, notes, 100, sampleRate, -200, 1, 0.3, 0.3, 128, 0.2, noPedal)
+	if err != nil {
+		t.Fatalf("render fresh: %v", err)
+	}
+	if len(warm) != len(fresh) {
+		t.Fatalf("warm render length = %d, want %d", len(warm), len(fresh))
+	}
+	for i := range fresh {
+		if warm[i] != fresh[i] {
+			t.Fatalf("sample %d: warm %g, fresh %g", i, warm[i], fresh[i])
+		}
+	}
+}
+
+func TestRunOptimizationCancelledKeepsBestCandidate(t *testing.T) {
+	const sampleRate = 16000
+	params := piano.NewDefaultParams()
+	params.IRWavPath = ""
+	notes := singleNote(60)
+	ref, _, err := renderCandidateFromParams(nil, params, notes, 100, sampleRate, -200, 1, 0.3, 0.3, 128, 0.2, noPedal)
+	if err != nil {
+		t.Fatalf("render reference: %v", err)
+	}
+	groups := map[string]bool{"mix": true}
+	defs, init := initCandidate(params, sampleRate, 60, 100, 0.2, groups)
+	cfg := &optimizationConfig{
+		references:       [][]float64{ref},
+		finalReferences:  [][]float64{ref},
+		baseParams:       params,
+		defs:             defs,
+		initCandidate:    init,
+		note:             60,
+		baseVelocity:     100,
+		baseReleaseAfter: 0.2,
+		sampleRate:       sampleRate,
+		finalSampleRate:  sampleRate,
+		timeBudget:       60,
+		maxEvals:         1000,
+		reportEvery:      1,
+		checkpointEvery:  1,
+		decayDBFS:        -200,
+		decayHoldBlocks:  1,
+		minDuration:      0.3,
+		maxDuration:      0.3,
+		finalMinDuration: 0.3,
+		finalMaxDuration: 0.3,
+		renderBlockSize:  128,
+		refineTopK:       1,
+		mayflyVariant:    "ma",
+		mayflyPop:        2,
+		mayflyRoundEvals: 4,
+		workers:          2,
+		topK:             1,
+		groups:           groups,
+		workDir:          t.TempDir(),
+		outputPreset:     filepath.Join(t.TempDir(), "fitted.json"),
+		notes:            notes,
+		pedal:            noPedal,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := runOptimization(ctx, cfg)
+	if err != nil {
+		t.Fatalf("runOptimization: %v", err)
+	}
+	if !res.interrupted {
+		t.Fatal("result not marked interrupted")
+	}
+	if res.evals != 1 {
+		t.Fatalf("evals = %d after cancellation, want only the initial evaluation", res.evals)
+	}
+	if candidateKey(res.best) != candidateKey(init) || res.bestParams == nil {
+		t.Fatalf("best = %v, want the initial candidate %v", res.best.Vals, init.Vals)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"strings"
 
 	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
 	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
 )
 
 type runReport struct {
@@ -19,17 +21,22 @@ type runReport struct {
 	OutputIR        string             `json:"output_ir,omitempty"`
 	SampleRate      int                `json:"sample_rate"`
 	Note            int                `json:"note"`
+	Chord           []chordNote        `json:"chord,omitempty"`
 	Velocity        int                `json:"velocity"`
 	ReleaseAfterSec float64            `json:"release_after_seconds"`
+	Pedal           *pedalTiming       `json:"sustain_pedal,omitempty"`
 	DurationSec     float64            `json:"elapsed_seconds"`
 	Evaluations     int                `json:"evaluations"`
 	MayflyVariant   string             `json:"mayfly_variant"`
 	BestScore       float64            `json:"best_score"`
 	BestSimilarity  float64            `json:"best_similarity"`
+	ValidationScore *float64           `json:"validation_score,omitempty"` // held-out windows (--validation)
 	BestMetrics     analysis.Metrics   `json:"best_metrics"`
 	BestKnobs       map[string]float64 `json:"best_knobs"`
 	CheckpointCount int                `json:"checkpoint_count"`
 	TopCandidates   []topCandidate     `json:"top_candidates,omitempty"`
+	FrozenKnobs     []frozenKnob       `json:"frozen_knobs,omitempty"`
+	Interrupted     bool               `json:"interrupted,omitempty"` // stopped by a signal before the budget ran out
 }
 
 func writeOutputs(
@@ -40,8 +47,10 @@ func writeOutputs(
 	presetPath string,
 	sampleRate int,
 	note int,
+	notes []chordNote,
 	velocity int,
 	releaseAfter float64,
+	pedal pedalTiming,
 	elapsed float64,
 	evals int,
 	variant string,
@@ -54,6 +63,9 @@ func writeOutputs(
 	bestRoomIRR []float32,
 	checkpoints int,
 	top []topCandidate,
+	interrupted bool,
+	frozen []frozenKnob,
+	meta *fitcommon.Provenance,
 ) error {
 	p := cloneParams(bestParams)
 
@@ -70,6 +82,9 @@ func writeOutputs(
 		if err := writeStereoWAV(roomIRPath, bestRoomIRL, bestRoomIRR, sampleRate); err != nil {
 			return err
 		}
+		// The files are rewritten at every checkpoint; drop cached copies.
+		piano.InvalidateIRCache(bodyIRPath)
+		piano.InvalidateIRCache(roomIRPath)
 
 		p.BodyIRWavPath = bodyIRPath
 		p.RoomIRWavPath = roomIRPath
@@ -77,7 +92,7 @@ func writeOutputs(
 		p.IRWavPath = ""
 	}
 
-	if err := writePresetJSON(outputPreset, p); err != nil {
+	if err := writePresetJSON(outputPreset, p, fitcommon.WithScore(meta, bestM.Score)); err != nil {
 		return err
 	}
 
@@ -104,6 +119,16 @@ func writeOutputs(
 		BestKnobs:       knobs,
 		CheckpointCount: checkpoints,
 		TopCandidates:   top,
+		FrozenKnobs:     frozen,
+		Interrupted:     interrupted,
+	}
+
+	if len(notes) > 1 {
+		rep.Chord = notes
+	}
+	rep.ValidationScore = bestM.ValidationScore
+	if pedal.active() {
+		rep.Pedal = &pedal
 	}
 
 	if reportPath == "" {
@@ -112,15 +137,49 @@ func writeOutputs(
 	return writeJSON(reportPath, rep)
 }
 
-func writePresetJSON(path string, p *piano.Params) error {
+func writePresetJSON(path string, p *piano.Params, meta *fitcommon.Provenance) error {
+	type prepEntry struct {
+		Type      string  `json:"type"`
+		Amount    float32 `json:"amount"`
+		Harmonic  int     `json:"harmonic,omitempty"`
+		Threshold float32 `json:"threshold,omitempty"`
+	}
 	type noteEntry struct {
-		F0             float32 `json:"f0,omitempty"`
-		Inharmonicity  float32 `json:"inharmonicity,omitempty"`
-		Loss           float32 `json:"loss,omitempty"`
-		StrikePosition float32 `json:"strike_position,omitempty"`
+		F0             float32     `json:"f0,omitempty"`
+		Inharmonicity  float32     `json:"inharmonicity,omitempty"`
+		Loss           float32     `json:"loss,omitempty"`
+		StrikePosition float32     `json:"strike_position,omitempty"`
+		Preparations   []prepEntry `json:"preparations,omitempty"`
+		UnisonDetunes  []float32   `json:"unison_detunes_cents,omitempty"`
+		UnisonGains    []float32   `json:"unison_gains,omitempty"`
+
+		HammerStiffnessScale   float32 `json:"hammer_stiffness_scale,omitempty"`
+		HammerExponentScale    float32 `json:"hammer_exponent_scale,omitempty"`
+		HammerContactTimeScale float32 `json:"hammer_contact_time_scale,omitempty"`
+	}
+	type unisonRegister struct {
+		BelowNote int       `json:"below_note"`
+		Detunes   []float32 `json:"detunes_cents"`
+		Gains     []float32 `json:"gains,omitempty"`
+	}
+	type registerPoint struct {
+		Note  int     `json:"note"`
+		Value float32 `json:"value"`
+	}
+	type micBus struct {
+		GainDB  float32 `json:"gain_db,omitempty"`
+		Pan     float32 `json:"pan,omitempty"`
+		DelayMs float32 `json:"delay_ms,omitempty"`
+	}
+	type eqBand struct {
+		Type   string  `json:"type"`
+		FreqHz float32 `json:"freq_hz"`
+		GainDB float32 `json:"gain_db"`
+		Q      float32 `json:"q,omitempty"`
 	}
 	type out struct {
 		OutputGain                 float32              `json:"output_gain,omitempty"`
+		Seed                       int64                `json:"seed"`
 		MinNote                    int                  `json:"min_note"`
 		MaxNote                    int                  `json:"max_note"`
 		IRWavPath                  string               `json:"ir_wav_path,omitempty"`
@@ -130,12 +189,23 @@ func writePresetJSON(path string, p *piano.Params) error {
 		BodyIRWavPath              string               `json:"body_ir_wav_path,omitempty"`
 		BodyIRGain                 float32              `json:"body_ir_gain,omitempty"`
 		BodyDryMix                 float32              `json:"body_dry_mix,omitempty"`
+		BodyIRClosedWavPath        string               `json:"body_ir_closed_wav_path,omitempty"`
+		LidPosition                *float32             `json:"lid_position,omitempty"`
 		RoomIRWavPath              string               `json:"room_ir_wav_path,omitempty"`
 		RoomWetMix                 float32              `json:"room_wet_mix,omitempty"`
 		RoomGain                   float32              `json:"room_gain,omitempty"`
+		ConvolutionBackend         string               `json:"convolution_backend,omitempty"`
 		ResonanceEnabled           bool                 `json:"resonance_enabled,omitempty"`
 		ResonanceGain              float32              `json:"resonance_gain,omitempty"`
 		ResonancePerNoteFilter     bool                 `json:"resonance_per_note_filter,omitempty"`
+		ResonanceAttackMs          float32              `json:"resonance_attack_ms,omitempty"`
+		ResonanceSaturation        float32              `json:"resonance_saturation,omitempty"`
+		CouplingEnabled            bool                 `json:"coupling_enabled"`
+		CouplingMode               string               `json:"coupling_mode,omitempty"`
+		CouplingAmount             float32              `json:"coupling_amount"`
+		CouplingOctaveGain         float32              `json:"coupling_octave_gain"`
+		CouplingFifthGain          float32              `json:"coupling_fifth_gain"`
+		CouplingMaxForce           float32              `json:"coupling_max_force,omitempty"`
 		HammerStiffnessScale       float32              `json:"hammer_stiffness_scale,omitempty"`
 		HammerExponentScale        float32              `json:"hammer_exponent_scale,omitempty"`
 		HammerDampingScale         float32              `json:"hammer_damping_scale,omitempty"`
@@ -149,32 +219,76 @@ func writePresetJSON(path string, p *piano.Params) error {
 		AttackNoiseLevel           float32              `json:"attack_noise_level,omitempty"`
 		AttackNoiseDurationMs      float32              `json:"attack_noise_duration_ms,omitempty"`
 		AttackNoiseColor           float32              `json:"attack_noise_color,omitempty"`
+		HammerKnockLevel           float32              `json:"hammer_knock_level,omitempty"`
+		HammerKnockAttackMs        float32              `json:"hammer_knock_attack_ms,omitempty"`
+		HammerKnockDecayMs         float32              `json:"hammer_knock_decay_ms,omitempty"`
+		HammerKnockBandsDB         []float32            `json:"hammer_knock_bands_db,omitempty"`
+		HammerPreTriggerMs         float32              `json:"hammer_pre_trigger_ms,omitempty"`
+		VariationAmount            float32              `json:"variation_amount,omitempty"`
+		TuningDriftCents           float32              `json:"tuning_drift_cents,omitempty"`
+		TuningDriftTimeSec         float32              `json:"tuning_drift_time_sec,omitempty"`
+		TuningDriftCorrelationKeys *float32             `json:"tuning_drift_correlation_keys,omitempty"`
+		HighFreqDampingCurve       []registerPoint      `json:"high_freq_damping_curve,omitempty"`
+		LossCurve                  []registerPoint      `json:"loss_curve,omitempty"`
+		DamperReflection           float32              `json:"damper_reflection,omitempty"`
+		DamperEngageMs             float32              `json:"damper_engage_ms"`
+		DamperEngageRegisterSlope  float32              `json:"damper_engage_register_slope"`
+		DamperVelocitySensitivity  float32              `json:"damper_velocity_sensitivity"`
+		DamperReleaseMs            float32              `json:"damper_release_ms,omitempty"`
+		DamperCurve                []registerPoint      `json:"damper_curve,omitempty"`
+		DamperlessFromNote         int                  `json:"damperless_from_note"`
+		UnisonRegisters            []unisonRegister     `json:"unison_registers,omitempty"`
+		CloseMic                   *micBus              `json:"close_mic,omitempty"`
+		RoomMic                    *micBus              `json:"room_mic,omitempty"`
+		SoundboardNonlinearity     float32              `json:"soundboard_nonlinearity,omitempty"`
+		SoundboardStressLevel      float32              `json:"soundboard_stress_level,omitempty"`
+		OutputEQ                   []eqBand             `json:"output_eq,omitempty"`
+		BassMonoHz                 float32              `json:"bass_mono_hz,omitempty"`
 		PerNote                    map[string]noteEntry `json:"per_note,omitempty"`
+
+		// Meta records the run that produced the preset.
+		Meta *fitcommon.Provenance `json:"meta,omitempty"`
 	}
 
 	o := out{
 		OutputGain:                 p.OutputGain,
+		Seed:                       p.Seed,
 		MinNote:                    p.MinNote,
 		MaxNote:                    p.MaxNote,
-		IRWavPath:                  presetIRPath(path, p.IRWavPath),
+		IRWavPath:                  preset.RelativeIRPath(path, p.IRWavPath),
 		IRWetMix:                   p.IRWetMix,
 		IRDryMix:                   p.IRDryMix,
 		IRGain:                     p.IRGain,
-		BodyIRWavPath:              presetIRPath(path, p.BodyIRWavPath),
+		BodyIRWavPath:              preset.RelativeIRPath(path, p.BodyIRWavPath),
 		BodyIRGain:                 p.BodyIRGain,
 		BodyDryMix:                 p.BodyDryMix,
-		RoomIRWavPath:              presetIRPath(path, p.RoomIRWavPath),
+		BodyIRClosedWavPath:        preset.RelativeIRPath(path, p.BodyIRClosedWavPath),
+		RoomIRWavPath:              preset.RelativeIRPath(path, p.RoomIRWavPath),
 		RoomWetMix:                 p.RoomWetMix,
 		RoomGain:                   p.RoomGain,
 		ResonanceEnabled:           p.ResonanceEnabled,
 		ResonanceGain:              p.ResonanceGain,
 		ResonancePerNoteFilter:     p.ResonancePerNoteFilter,
+		ResonanceAttackMs:          p.ResonanceAttackMs,
+		ResonanceSaturation:        p.ResonanceSaturation,
+		CouplingEnabled:            p.CouplingEnabled,
+		CouplingMode:               string(p.CouplingMode),
+		CouplingAmount:             p.CouplingAmount,
+		CouplingOctaveGain:         p.CouplingOctaveGain,
+		CouplingFifthGain:          p.CouplingFifthGain,
+		CouplingMaxForce:           p.CouplingMaxForce,
 		HammerStiffnessScale:       p.HammerStiffnessScale,
 		HammerExponentScale:        p.HammerExponentScale,
 		HammerDampingScale:         p.HammerDampingScale,
 		HammerInitialVelocityScale: p.HammerInitialVelocityScale,
 		HammerContactTimeScale:     p.HammerContactTimeScale,
 		HighFreqDamping:            p.HighFreqDamping,
+		DamperReflection:           p.DamperReflection,
+		DamperEngageMs:             p.DamperEngageMs,
+		DamperEngageRegisterSlope:  p.DamperEngageRegisterSlope,
+		DamperVelocitySensitivity:  p.DamperVelocitySensitivity,
+		DamperReleaseMs:            p.DamperReleaseMs,
+		DamperlessFromNote:         p.DamperlessFromNote,
 		UnisonDetuneScale:          p.UnisonDetuneScale,
 		UnisonCrossfeed:            p.UnisonCrossfeed,
 		SoftPedalStrikeOffset:      p.SoftPedalStrikeOffset,
@@ -182,7 +296,53 @@ func writePresetJSON(path string, p *piano.Params) error {
 		AttackNoiseLevel:           p.AttackNoiseLevel,
 		AttackNoiseDurationMs:      p.AttackNoiseDurationMs,
 		AttackNoiseColor:           p.AttackNoiseColor,
+		HammerKnockLevel:           p.HammerKnockLevel,
+		HammerKnockAttackMs:        p.HammerKnockAttackMs,
+		HammerKnockDecayMs:         p.HammerKnockDecayMs,
+		HammerPreTriggerMs:         p.HammerPreTriggerMs,
+		VariationAmount:            p.VariationAmount,
+		TuningDriftCents:           p.TuningDriftCents,
+		TuningDriftTimeSec:         p.TuningDriftTimeSec,
+		SoundboardNonlinearity:     p.SoundboardNonlinearity,
+		SoundboardStressLevel:      p.SoundboardStressLevel,
+		BassMonoHz:                 p.BassMonoHz,
 		PerNote:                    map[string]noteEntry{},
+		Meta:                       meta,
+	}
+	if p.BodyIRClosedWavPath != "" {
+		lid := p.LidPosition
+		o.LidPosition = &lid
+	}
+	if p.ConvolutionBackend != piano.ConvolutionAuto {
+		o.ConvolutionBackend = string(p.ConvolutionBackend)
+	}
+	if p.TuningDriftCents > 0 {
+		keys := p.TuningDriftCorrelationKeys
+		o.TuningDriftCorrelationKeys = &keys
+	}
+	for _, pt := range p.HighFreqDampingCurve {
+		o.HighFreqDampingCurve = append(o.HighFreqDampingCurve, registerPoint{Note: pt.Note, Value: pt.Value})
+	}
+	for _, pt := range p.LossCurve {
+		o.LossCurve = append(o.LossCurve, registerPoint{Note: pt.Note, Value: pt.Value})
+	}
+	for _, pt := range p.DamperCurve {
+		o.DamperCurve = append(o.DamperCurve, registerPoint{Note: pt.Note, Value: pt.Value})
+	}
+	for _, r := range p.UnisonRegisters {
+		o.UnisonRegisters = append(o.UnisonRegisters, unisonRegister{BelowNote: r.BelowNote, Detunes: r.Detunes, Gains: r.Gains})
+	}
+	if p.HammerKnockBandsDB != ([piano.HammerKnockBands]float32{}) {
+		o.HammerKnockBandsDB = p.HammerKnockBandsDB[:]
+	}
+	if p.CloseMic != (piano.MicBus{}) {
+		o.CloseMic = &micBus{GainDB: p.CloseMic.GainDB, Pan: p.CloseMic.Pan, DelayMs: p.CloseMic.DelayMs}
+	}
+	if p.RoomMic != (piano.MicBus{}) {
+		o.RoomMic = &micBus{GainDB: p.RoomMic.GainDB, Pan: p.RoomMic.Pan, DelayMs: p.RoomMic.DelayMs}
+	}
+	for _, b := range p.OutputEQ {
+		o.OutputEQ = append(o.OutputEQ, eqBand{Type: string(b.Type), FreqHz: b.FreqHz, GainDB: b.GainDB, Q: b.Q})
 	}
 	keys := make([]int, 0, len(p.PerNote))
 	for k := range p.PerNote {
@@ -194,41 +354,29 @@ func writePresetJSON(path string, p *piano.Params) error {
 		if np == nil {
 			continue
 		}
-		o.PerNote[strconv.Itoa(k)] = noteEntry{
+		entry := noteEntry{
 			F0:             np.F0,
 			Inharmonicity:  np.Inharmonicity,
 			Loss:           np.Loss,
 			StrikePosition: np.StrikePosition,
-		}
-	}
-	return writeJSON(path, o)
-}
-
-func presetIRPath(presetPath string, irPath string) string {
-	irPath = strings.TrimSpace(irPath)
-	if irPath == "" {
-		return ""
-	}
+			UnisonDetunes:  np.UnisonDetunes,
+			UnisonGains:    np.UnisonGains,
 
-	presetDir := filepath.Dir(presetPath)
-	presetDirAbs, err := filepath.Abs(presetDir)
-	if err != nil {
-		return irPath
-	}
-
-	irAbs := irPath
-	if !filepath.IsAbs(irAbs) {
-		irAbs, err = filepath.Abs(irAbs)
-		if err != nil {
-			return irPath
+			HammerStiffnessScale:   np.HammerStiffnessScale,
+			HammerExponentScale:    np.HammerExponentScale,
+			HammerContactTimeScale: np.HammerContactTimeScale,
 		}
+		for _, prep := range np.Preparations {
+			entry.Preparations = append(entry.Preparations, prepEntry{
+				Type:      string(prep.Type),
+				Amount:    prep.Amount,
+				Harmonic:  prep.Harmonic,
+				Threshold: prep.Threshold,
+			})
+		}
+		o.PerNote[strconv.Itoa(k)] = entry
 	}
-
-	rel, err := filepath.Rel(presetDirAbs, irAbs)
-	if err != nil {
-		return irPath
-	}
-	return filepath.ToSlash(rel)
+	return writeJSON(path, o)
 }
 
 func writeJSON(path string, v any) error {
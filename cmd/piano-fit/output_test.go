@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+func TestWritePresetJSONKeepsSeed(t *testing.T) {
+	for _, seed := range []int64{0, 42} {
+		p := piano.NewDefaultParams()
+		p.Seed = seed
+		path := filepath.Join(t.TempDir(), "fit.json")
+		if err := writePresetJSON(path, p, nil); err != nil {
+			t.Fatalf("writePresetJSON: %v", err)
+		}
+		got, err := preset.LoadJSON(path)
+		if err != nil {
+			t.Fatalf("LoadJSON: %v", err)
+		}
+		if got.Seed != seed {
+			t.Fatalf("seed %d reloads as %d", seed, got.Seed)
+		}
+	}
+}
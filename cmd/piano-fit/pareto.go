@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+// paretoObjectives names the objectives of --pareto fits, all minimized:
+// the spectral, envelope and decay distances of analysis.Compare.
+var paretoObjectives = []string{"spectral", "envelope", "decay"}
+
+// paretoPoint holds a candidate's value of each of paretoObjectives.
+type paretoPoint [3]float64
+
+func paretoPointOf(m analysis.Metrics) paretoPoint {
+	return paretoPoint{m.SpectralNorm, m.EnvelopeNorm, m.DecayNorm}
+}
+
+// dominates reports whether a is no worse than b in every objective and
+// better in at least one.
+func (a paretoPoint) dominates(b paretoPoint) bool {
+	better := false
+	for i := range a {
+		if a[i] > b[i] {
+			return false
+		}
+		if a[i] < b[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// paretoWeights draws the objective weights of one round uniformly from
+// the simplex, so successive rounds pull the search towards different
+// parts of the front.
+func paretoWeights(rng *rand.Rand) paretoPoint {
+	var w paretoPoint
+	sum := 0.0
+	for i := range w {
+		w[i] = -math.Log(1 - rng.Float64())
+		sum += w[i]
+	}
+	for i := range w {
+		w[i] /= sum
+	}
+	return w
+}
+
+// paretoScore scalarizes m with round weights w on top of the default
+// component weights, so equal weights rank like the single-score fit.
+func paretoScore(m analysis.Metrics, w paretoPoint) float64 {
+	base := paretoPoint{analysis.WeightSpectral, analysis.WeightEnvelope, analysis.WeightDecay}
+	p := paretoPointOf(m)
+	num, den := 0.0, 0.0
+	for i := range p {
+		num += w[i] * base[i] * p[i]
+		den += w[i] * base[i]
+	}
+	return num / den
+}
+
+type paretoMember struct {
+	eval  int
+	point paretoPoint
+	cand  candidate
+	res   optimizationEval
+}
+
+// paretoFront keeps the non-dominated candidates seen so far, at most
+// size of them: beyond that the member in the most crowded part of the
+// front is dropped, keeping the extremes of each objective.
+type paretoFront struct {
+	size    int
+	members []paretoMember
+}
+
+// add offers an evaluated candidate to the front and reports whether it
+// was kept. Kept candidates and their evaluation are cloned.
+func (f *paretoFront) add(eval int, cand candidate, res optimizationEval) bool {
+	p := paretoPointOf(res.metrics)
+	for i := range p {
+		if math.IsNaN(p[i]) || math.IsInf(p[i], 0) {
+			return false
+		}
+	}
+	kept := f.members[:0]
+	for _, m := range f.members {
+		if m.point.dominates(p) || m.point == p {
+			return false
+		}
+	}
+	for _, m := range f.members {
+		if !p.dominates(m.point) {
+			kept = append(kept, m)
+		}
+	}
+	f.members = append(kept, paretoMember{eval: eval, point: p, cand: cloneCandidate(cand), res: cloneOptimizationEval(res)})
+	for len(f.members) > f.size {
+		f.dropMostCrowded()
+	}
+	return true
+}
+
+// dropMostCrowded removes the member with the smallest crowding distance
+// (the sum over objectives of the normalized gap between its neighbours).
+func (f *paretoFront) dropMostCrowded() {
+	n := len(f.members)
+	crowding := make([]float64, n)
+	order := make([]int, n)
+	for k := range paretoObjectives {
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool {
+			return f.members[order[a]].point[k] < f.members[order[b]].point[k]
+		})
+		lo, hi := f.members[order[0]].point[k], f.members[order[n-1]].point[k]
+		crowding[order[0]] = math.Inf(1)
+		crowding[order[n-1]] = math.Inf(1)
+		if hi <= lo {
+			continue
+		}
+		for i := 1; i < n-1; i++ {
+			crowding[order[i]] += (f.members[order[i+1]].point[k] - f.members[order[i-1]].point[k]) / (hi - lo)
+		}
+	}
+	drop := 0
+	for i := range crowding {
+		if crowding[i] < crowding[drop] {
+			drop = i
+		}
+	}
+	f.members = append(f.members[:drop], f.members[drop+1:]...)
+}
+
+// sorted returns the members ordered by the spectral objective, so the
+// written candidates run from one end of the trade-off to the other.
+func (f *paretoFront) sorted() []paretoMember {
+	out := append([]paretoMember(nil), f.members...)
+	sort.Slice(out, func(a, b int) bool { return out[a].point[0] < out[b].point[0] })
+	return out
+}
+
+type paretoEntry struct {
+	Eval       int                `json:"eval"`
+	Objectives map[string]float64 `json:"objectives"`
+	Score      float64            `json:"score"` // default weighted score
+	Similarity float64            `json:"similarity"`
+	Preset     string             `json:"preset"`
+	Report     string             `json:"report"`
+	Knobs      map[string]float64 `json:"knobs"`
+}
+
+type paretoReport struct {
+	Objectives []string      `json:"objectives"`
+	Candidates []paretoEntry `json:"candidates"`
+}
+
+// paretoDir returns the default --pareto-dir next to the output preset.
+func paretoDir(outputPreset string) string {
+	return strings.TrimSuffix(outputPreset, filepath.Ext(outputPreset)) + ".pareto"
+}
+
+// writeParetoFront writes every front member as a preset with its report
+// (and IRs when the fit synthesizes them) into dir, plus front.json
+// listing their objectives. Members keep their search-settings metrics;
+// the front is not re-evaluated at the final settings.
+func writeParetoFront(cfg *optimizationConfig, dir string, members []paretoMember, elapsed float64, evals int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	rep := paretoReport{Objectives: paretoObjectives}
+	for i, m := range members {
+		name := fmt.Sprintf("front-%02d", i+1)
+		presetPath := filepath.Join(dir, name+".json")
+		reportPath := filepath.Join(dir, name+".report.json")
+		outputIR := ""
+		if cfg.outputIR != "" {
+			outputIR = filepath.Join(dir, name+filepath.Ext(cfg.outputIR))
+		}
+		if err := writeOutputs(
+			outputIR,
+			presetPath,
+			reportPath,
+			cfg.referencePath,
+			cfg.presetPath,
+			cfg.sampleRate,
+			cfg.note,
+			cfg.notes,
+			m.res.velocity,
+			m.res.releaseAfter,
+			m.res.pedal,
+			elapsed,
+			evals,
+			strings.ToLower(cfg.mayflyVariant),
+			cfg.defs,
+			m.cand,
+			m.res.metrics,
+			m.res.params,
+			m.res.bodyIR,
+			m.res.roomIRL,
+			m.res.roomIRR,
+			0,
+			nil,
+			false,
+			nil,
+			cfg.provenance,
+		); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		objectives := make(map[string]float64, len(paretoObjectives))
+		for k, name := range paretoObjectives {
+			objectives[name] = m.point[k]
+		}
+		knobs := make(map[string]float64, len(cfg.defs))
+		for k, d := range cfg.defs {
+			knobs[d.Name] = m.cand.Vals[k]
+		}
+		rep.Candidates = append(rep.Candidates, paretoEntry{
+			Eval:       m.eval,
+			Objectives: objectives,
+			Score:      m.res.metrics.Score,
+			Similarity: m.res.metrics.Similarity,
+			Preset:     presetPath,
+			Report:     reportPath,
+			Knobs:      knobs,
+		})
+	}
+	return writeJSON(filepath.Join(dir, "front.json"), rep)
+}
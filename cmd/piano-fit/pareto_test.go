@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+func paretoEval(spectral, envelope, decay float64) optimizationEval {
+	return optimizationEval{metrics: analysis.Metrics{SpectralNorm: spectral, EnvelopeNorm: envelope, DecayNorm: decay}}
+}
+
+func TestParetoFrontKeepsNonDominated(t *testing.T) {
+	f := &paretoFront{size: 8}
+	c := candidate{Vals: []float64{0.5}}
+	if !f.add(1, c, paretoEval(1, 1, 1)) {
+		t.Fatal("first candidate rejected")
+	}
+	if !f.add(2, c, paretoEval(0.5, 2, 1)) {
+		t.Fatal("trade-off candidate rejected")
+	}
+	if f.add(3, c, paretoEval(1, 1.5, 1)) || f.add(4, c, paretoEval(1, 1, 1)) {
+		t.Fatal("dominated or duplicate candidate kept")
+	}
+	if !f.add(5, c, paretoEval(0.9, 0.9, 1)) {
+		t.Fatal("dominating candidate rejected")
+	}
+	got := f.sorted()
+	if len(got) != 2 || got[0].eval != 2 || got[1].eval != 5 {
+		t.Fatalf("front = %+v, want evals 2 and 5 by spectral objective", got)
+	}
+	if f.add(6, c, paretoEval(math.NaN(), 0, 0)) {
+		t.Fatal("non-finite candidate kept")
+	}
+}
+
+func TestParetoFrontDropsMostCrowded(t *testing.T) {
+	f := &paretoFront{size: 3}
+	for i, x := range []float64{0, 10, 1, 1.2} {
+		f.add(i+1, candidate{}, paretoEval(x, 10-x, 0))
+	}
+	got := f.sorted()
+	if len(got) != 3 {
+		t.Fatalf("front size %d, want 3", len(got))
+	}
+	for i, want := range []float64{0, 1.2, 10} {
+		if got[i].point[0] != want {
+			t.Fatalf("front spectral objectives %v, %v, %v; want 0, 1.2, 10 (the extremes and the less crowded point)",
+				got[0].point[0], got[1].point[0], got[2].point[0])
+		}
+	}
+}
+
+func TestParetoWeightsAndScore(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 100 {
+		w := paretoWeights(rng)
+		if sum := w[0] + w[1] + w[2]; math.Abs(sum-1) > 1e-12 || w[0] < 0 || w[1] < 0 || w[2] < 0 {
+			t.Fatalf("weights %v not on the simplex", w)
+		}
+	}
+	m := paretoEval(0.2, 0.4, 0.8).metrics
+	if got := paretoScore(m, paretoPoint{1, 0, 0}); math.Abs(got-0.2) > 1e-12 {
+		t.Fatalf("spectral-only score %v, want 0.2", got)
+	}
+	want := (analysis.WeightSpectral*0.2 + analysis.WeightEnvelope*0.4 + analysis.WeightDecay*0.8) /
+		(analysis.WeightSpectral + analysis.WeightEnvelope + analysis.WeightDecay)
+	if got := paretoScore(m, paretoPoint{1, 1, 1}); math.Abs(got-want) > 1e-12 {
+		t.Fatalf("equal-weight score %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "math"
+
+// pedalTiming is the sustain-pedal schedule of the render scenario, in
+// seconds from the render start. Negative times mean "never".
+type pedalTiming struct {
+	DownAt float64 `json:"down_at_seconds"`
+	UpAt   float64 `json:"up_at_seconds"`
+}
+
+// noPedal is the plain (pedal-up) scenario.
+var noPedal = pedalTiming{DownAt: -1, UpAt: -1}
+
+func (t pedalTiming) active() bool {
+	return t.DownAt >= 0
+}
+
+// addPedalKnobs appends the render.pedal_* timing knobs for a pedal-down
+// scenario. The release time is only exposed when the reference lifts the
+// pedal before its end.
+func addPedalKnobs(defs []knobDef, c candidate, t pedalTiming) ([]knobDef, candidate) {
+	if !t.active() {
+		return defs, c
+	}
+	vals := append([]float64(nil), c.Vals...)
+	defs = append(defs, knobDef{Name: "render.pedal_down_at", Min: 0.0, Max: math.Max(0.5, 2*t.DownAt)})
+	vals = append(vals, t.DownAt)
+	if t.UpAt >= 0 {
+		defs = append(defs, knobDef{Name: "render.pedal_up_at", Min: 0.05, Max: math.Max(1.0, 2*t.UpAt)})
+		vals = append(vals, t.UpAt)
+	}
+	for i := len(c.Vals); i < len(vals); i++ {
+		vals[i] = clamp(vals[i], defs[i].Min, defs[i].Max)
+	}
+	return defs, candidate{Vals: vals}
+}
+
+// applyPedalKnobs returns the pedal schedule for candidate c. The pedal is
+// never lifted before it is pressed.
+func applyPedalKnobs(base pedalTiming, defs []knobDef, c candidate) pedalTiming {
+	t := base
+	if !t.active() {
+		return t
+	}
+	for i, def := range defs {
+		switch def.Name {
+		case "render.pedal_down_at":
+			t.DownAt = c.Vals[i]
+		case "render.pedal_up_at":
+			t.UpAt = c.Vals[i]
+		}
+	}
+	if t.UpAt >= 0 && t.UpAt < t.DownAt {
+		t.UpAt = t.DownAt
+	}
+	return t
+}
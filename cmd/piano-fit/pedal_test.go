@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestAddPedalKnobs(t *testing.T) {
+	defs, cand := initCandidate(piano.NewDefaultParams(), 48000, 60, 118, 3.5, map[string]bool{"mix": true})
+	n := len(defs)
+
+	gotDefs, gotCand := addPedalKnobs(defs, cand, noPedal)
+	if len(gotDefs) != n || len(gotCand.Vals) != n {
+		t.Fatalf("pedal-up scenario must not add knobs")
+	}
+
+	gotDefs, gotCand = addPedalKnobs(defs, cand, pedalTiming{DownAt: 0, UpAt: -1})
+	names := knobNameSet(gotDefs)
+	if !names["render.pedal_down_at"] || names["render.pedal_up_at"] {
+		t.Fatalf("held pedal should only expose pedal_down_at, got %v", names)
+	}
+	if len(gotCand.Vals) != len(gotDefs) {
+		t.Fatalf("candidate has %d values for %d knobs", len(gotCand.Vals), len(gotDefs))
+	}
+
+	gotDefs, _ = addPedalKnobs(defs, cand, pedalTiming{DownAt: 0.1, UpAt: 2})
+	if !knobNameSet(gotDefs)["render.pedal_up_at"] {
+		t.Fatalf("pedal lift should expose pedal_up_at")
+	}
+	if len(defs) != n {
+		t.Fatalf("addPedalKnobs mutated input defs")
+	}
+}
+
+func TestApplyPedalKnobsKeepsUpAfterDown(t *testing.T) {
+	base := pedalTiming{DownAt: 0.1, UpAt: 2}
+	defs, cand := addPedalKnobs(nil, candidate{}, base)
+	for i, d := range defs {
+		switch d.Name {
+		case "render.pedal_down_at":
+			cand.Vals[i] = 0.4
+		case "render.pedal_up_at":
+			cand.Vals[i] = 0.2
+		}
+	}
+	got := applyPedalKnobs(base, defs, cand)
+	if got.DownAt != 0.4 || got.UpAt != 0.4 {
+		t.Fatalf("applyPedalKnobs = %+v, want down=up=0.4", got)
+	}
+	if got := applyPedalKnobs(noPedal, defs, cand); got.active() {
+		t.Fatalf("pedal-up scenario must stay inactive")
+	}
+}
+
+func TestRenderPianoSustainPedalHoldsAfterRelease(t *testing.T) {
+	const sampleRate = 48000
+	params := piano.NewDefaultParams()
+	params.IRWavPath = ""
+	notes := singleNote(60)
+
+	dry, _, err := renderCandidateFromParams(nil, params, notes, 100, sampleRate, -200, 1, 0.6, 0.6, 128, 0.1, noPedal)
+	if err != nil {
+		t.Fatalf("render without pedal: %v", err)
+	}
+	held, _, err := renderCandidateFromParams(nil, params, notes, 100, sampleRate, -200, 1, 0.6, 0.6, 128, 0.1, pedalTiming{DownAt: 0, UpAt: -1})
+	if err != nil {
+		t.Fatalf("render with pedal: %v", err)
+	}
+
+	tail := func(x []float64) float64 {
+		var e float64
+		for _, v := range x[int(0.4*sampleRate):] {
+			e += v * v
+		}
+		return e
+	}
+	if tail(held) < 10*tail(dry) {
+		t.Fatalf("pedal-down tail energy %.3g should far exceed damped tail %.3g", tail(held), tail(dry))
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+
+	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/dataset"
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// referenceTakes expands --reference: a plain path, or a glob matching
+// several recordings of the same note that are scored together
+// (analysis.CompareMulti). Matches are returned sorted.
+func referenceTakes(pattern string) ([]string, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		// Not a glob (or nothing matched): let the WAV reader report a
+		// missing plain path.
+		return []string{pattern}, nil
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// datasetTakes resolves --dataset: the manifest's takes of note with the
+// given pedal state at the velocity layer nearest velocity, fetched into
+// cacheDir. layer is the velocity of the chosen layer (0 when the manifest
+// does not know it).
+func datasetTakes(ctx context.Context, manifestPath string, cacheDir string, note int, velocity int, pedal bool) (paths []string, layer int, err error) {
+	m, err := dataset.Load(manifestPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := m.Select(note, velocity, pedal)
+	if len(entries) == 0 {
+		return nil, 0, fmt.Errorf("%s has no recording of note %d (pedal %t)", manifestPath, note, pedal)
+	}
+	f := &dataset.Fetcher{CacheDir: cacheDir}
+	paths, err = f.FetchAll(ctx, m, entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	return paths, entries[0].Velocity, nil
+}
+
+// loadReferences reads every take and resamples it to both the
+// optimization and the final sample rate.
+func loadReferences(paths []string, optSampleRate int, sampleRate int) (opt [][]float64, full [][]float64, err error) {
+	for _, path := range paths {
+		raw, sr, err := readWAVMono(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		o, err := resampleIfNeeded(raw, sr, optSampleRate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resample %s for optimization: %w", path, err)
+		}
+		f, err := resampleIfNeeded(raw, sr, sampleRate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resample %s: %w", path, err)
+		}
+		opt = append(opt, o)
+		full = append(full, f)
+	}
+	return opt, full, nil
+}
+
+// referenceKnock is the attack residual (hammer knock) of the takes, each
+// measure the median across the takes that yield one; ok is false when
+// none does.
+func referenceKnock(refs [][]float64, sampleRate int, f0 float64) (analysis.AttackResidual, bool) {
+	var found []analysis.AttackResidual
+	for _, ref := range refs {
+		if r, ok := analysis.ExtractAttackResidual(ref, sampleRate, f0, piano.HammerKnockBandHz[:]); ok {
+			found = append(found, r)
+		}
+	}
+	if len(found) == 0 {
+		return analysis.AttackResidual{}, false
+	}
+	measure := func(v func(analysis.AttackResidual) float64) float64 {
+		vs := make([]float64, len(found))
+		for i, r := range found {
+			vs[i] = v(r)
+		}
+		return median(vs)
+	}
+	out := analysis.AttackResidual{
+		PeakRMS:  measure(func(r analysis.AttackResidual) float64 { return r.PeakRMS }),
+		AttackMs: measure(func(r analysis.AttackResidual) float64 { return r.AttackMs }),
+		DecayMs:  measure(func(r analysis.AttackResidual) float64 { return r.DecayMs }),
+		BandsHz:  found[0].BandsHz,
+		BandsDB:  make([]float64, len(found[0].BandsDB)),
+	}
+	out.PeakDB = 20 * math.Log10(out.PeakRMS)
+	for b := range out.BandsDB {
+		out.BandsDB[b] = measure(func(r analysis.AttackResidual) float64 { return r.BandsDB[b] })
+	}
+	return out, true
+}
+
+// referenceInharmonicity is the median stiffness coefficient B measured
+// across the takes; ok is false when no take yields an estimate.
+func referenceInharmonicity(refs [][]float64, sampleRate int, f0 float64) (float64, bool) {
+	var bs []float64
+	for _, ref := range refs {
+		if b, ok := analysis.EstimateInharmonicity(ref, sampleRate, f0); ok {
+			bs = append(bs, b)
+		}
+	}
+	if len(bs) == 0 {
+		return 0, false
+	}
+	return median(bs), true
+}
+
+// median sorts vs and returns its median.
+func median(vs []float64) float64 {
+	sort.Float64s(vs)
+	mid := len(vs) / 2
+	if len(vs)%2 == 0 {
+		return 0.5 * (vs[mid-1] + vs[mid])
+	}
+	return vs[mid]
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReferenceTakesExpandsGlobSorted(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"c4-take2.wav", "c4-take1.wav", "d4.wav"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := referenceTakes(filepath.Join(dir, "c4-*.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || filepath.Base(got[0]) != "c4-take1.wav" || filepath.Base(got[1]) != "c4-take2.wav" {
+		t.Fatalf("unexpected takes %v", got)
+	}
+
+	plain := filepath.Join(dir, "missing.wav")
+	if got, err := referenceTakes(plain); err != nil || len(got) != 1 || got[0] != plain {
+		t.Fatalf("plain path must pass through, got %v, %v", got, err)
+	}
+	if _, err := referenceTakes("[bad"); err == nil {
+		t.Fatal("expected an error for a malformed pattern")
+	}
+}
+
+func TestLoadReferencesResamplesEveryTake(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i, name := range []string{"a.wav", "b.wav"} {
+		path := filepath.Join(dir, name)
+		x := make([]float32, 4800)
+		x[10*i] = 0.5
+		if err := writeStereoWAV(path, x, x, 48000); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	opt, full, err := loadReferences(paths, 24000, 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opt) != 2 || len(full) != 2 {
+		t.Fatalf("expected 2 takes, got %d / %d", len(opt), len(full))
+	}
+	if len(full[0]) != 4800 || len(opt[0]) < 2300 || len(opt[0]) > 2500 {
+		t.Fatalf("unexpected lengths full=%d opt=%d", len(full[0]), len(opt[0]))
+	}
+	if _, _, err := loadReferences([]string{filepath.Join(dir, "missing.wav")}, 24000, 48000); err == nil {
+		t.Fatal("expected an error for a missing take")
+	}
+}
+
+func TestDatasetTakesSelectsNearestLayer(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"c4-p.wav", "c4-f1.wav", "c4-f2.wav"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifest, []byte(`{"name": "test", "entries": [
+		{"note": 60, "velocity": 40, "url": "c4-p.wav"},
+		{"note": 60, "velocity": 100, "url": "c4-f1.wav"},
+		{"note": 60, "velocity": 100, "url": "c4-f2.wav"}
+	]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	paths, layer, err := datasetTakes(context.Background(), manifest, t.TempDir(), 60, 118, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer != 100 || len(paths) != 2 || paths[0] != filepath.Join(dir, "c4-f1.wav") {
+		t.Fatalf("got layer %d, paths %v; want the two velocity-100 takes", layer, paths)
+	}
+	if _, _, err := datasetTakes(context.Background(), manifest, t.TempDir(), 62, 118, false); err == nil {
+		t.Fatal("expected an error for a note missing from the manifest")
+	}
+}
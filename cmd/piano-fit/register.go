@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// Register curves fitted by the register group, by knob-name prefix.
+const (
+	registerHFDCurve  = "high_freq_damping_curve"
+	registerLossCurve = "loss_curve"
+)
+
+// registerLossSeed is the flat loss curve value presets without a loss
+// curve start from: the engine's default loop loss.
+const registerLossSeed = piano.DefaultLoopLoss
+
+// registerCurve returns the curve the register group fits: the preset's
+// own, or a flat one at the global value so the fit starts from how the
+// preset already sounds.
+func registerCurve(base *piano.Params, name string) piano.RegisterCurve {
+	switch name {
+	case registerHFDCurve:
+		if len(base.HighFreqDampingCurve) > 0 {
+			return base.HighFreqDampingCurve
+		}
+		return piano.FlatRegisterCurve(base.HighFreqDamping)
+	case registerLossCurve:
+		if len(base.LossCurve) > 0 {
+			return base.LossCurve
+		}
+		return piano.FlatRegisterCurve(registerLossSeed)
+	}
+	return nil
+}
+
+// registerPoints returns the indexes of the curve breakpoints that shape
+// the rendered notes: the two bracketing each note, or the end point for
+// notes outside the curve. The others cannot change the render.
+func registerPoints(c piano.RegisterCurve, notes []chordNote) []int {
+	seen := make(map[int]bool)
+	for _, n := range notes {
+		switch i := sort.Search(len(c), func(i int) bool { return c[i].Note >= n.Note }); {
+		case i == len(c):
+			seen[len(c)-1] = true
+		case i == 0 || c[i].Note == n.Note:
+			seen[i] = true
+		default:
+			seen[i-1] = true
+			seen[i] = true
+		}
+	}
+	idx := make([]int, 0, len(seen))
+	for i := range seen {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// addRegisterKnobs appends the register group's knobs: the high-frequency
+// damping and loss curve breakpoints around the rendered notes. A fitted
+// note's per_note loss (piano group) overrides the loss curve for that note.
+func addRegisterKnobs(defs []knobDef, c candidate, base *piano.Params, notes []chordNote) ([]knobDef, candidate) {
+	vals := append([]float64(nil), c.Vals...)
+	hfd := registerCurve(base, registerHFDCurve)
+	for _, i := range registerPoints(hfd, notes) {
+		defs = append(defs, knobDef{Name: fmt.Sprintf("%s.%d", registerHFDCurve, i), Min: 0.0, Max: 0.6})
+		vals = append(vals, float64(hfd[i].Value))
+	}
+	loss := registerCurve(base, registerLossCurve)
+	for _, i := range registerPoints(loss, notes) {
+		defs = append(defs, knobDef{Name: fmt.Sprintf("%s.%d", registerLossCurve, i), Min: 0.985, Max: 0.99995})
+		vals = append(vals, float64(loss[i].Value))
+	}
+	for i := len(c.Vals); i < len(vals); i++ {
+		vals[i] = clamp(vals[i], defs[i].Min, defs[i].Max)
+	}
+	return defs, candidate{Vals: vals}
+}
+
+// parseRegisterKnob splits a "<curve>.<i>" register knob name.
+func parseRegisterKnob(name string) (curve string, point int, ok bool) {
+	for _, curve := range []string{registerHFDCurve, registerLossCurve} {
+		if _, err := fmt.Sscanf(name, curve+".%d", &point); err == nil && point >= 0 {
+			return curve, point, true
+		}
+	}
+	return "", 0, false
+}
+
+// applyRegisterKnob sets breakpoint point of curve in params to v, seeding
+// the curve from base first when the preset has none.
+func applyRegisterKnob(params, base *piano.Params, curve string, point int, v float64) {
+	var c *piano.RegisterCurve
+	switch curve {
+	case registerHFDCurve:
+		c = &params.HighFreqDampingCurve
+	case registerLossCurve:
+		c = &params.LossCurve
+	default:
+		return
+	}
+	if len(*c) == 0 {
+		*c = append(piano.RegisterCurve(nil), registerCurve(base, curve)...)
+	}
+	if point < len(*c) {
+		(*c)[point].Value = float32(v)
+	}
+}
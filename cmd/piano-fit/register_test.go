@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestRegisterPointsBracketRenderedNotes(t *testing.T) {
+	c := piano.FlatRegisterCurve(0.05) // 21, 36, 48, 60, 72, 84, 96, 108
+	cases := []struct {
+		notes []chordNote
+		want  []int
+	}{
+		{singleNote(60), []int{3}},
+		{singleNote(64), []int{3, 4}},
+		{singleNote(12), []int{0}},
+		{singleNote(110), []int{7}},
+		{[]chordNote{{Note: 40}, {Note: 64}, {Note: 67}}, []int{1, 2, 3, 4}},
+	}
+	for _, tc := range cases {
+		if got := registerPoints(c, tc.notes); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("registerPoints(%v) = %v, want %v", tc.notes, got, tc.want)
+		}
+	}
+}
+
+func TestRegisterKnobsSeedAndApplyCurves(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.HighFreqDamping = 0.12
+	defs, cand := initCandidate(base, 48000, 64, 118, 3.5, map[string]bool{"mix": true})
+	n := len(defs)
+	defs, cand = addRegisterKnobs(defs, cand, base, singleNote(64))
+	names := knobNameSet(defs)
+	for _, want := range []string{"high_freq_damping_curve.3", "high_freq_damping_curve.4", "loss_curve.3", "loss_curve.4"} {
+		if !names[want] {
+			t.Fatalf("missing knob %q in %v", want, names)
+		}
+	}
+	if len(defs) != n+4 || len(cand.Vals) != len(defs) {
+		t.Fatalf("got %d knobs and %d values, want %d", len(defs), len(cand.Vals), n+4)
+	}
+	if cand.Vals[n] != float64(float32(0.12)) {
+		t.Fatalf("HFD curve seeded at %v, want the global 0.12", cand.Vals[n])
+	}
+
+	for i, d := range defs {
+		switch d.Name {
+		case "high_freq_damping_curve.4":
+			cand.Vals[i] = 0.3
+		case "loss_curve.3":
+			cand.Vals[i] = 0.999
+		}
+	}
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 64, 118, 3.5, defs, cand)
+	if len(params.HighFreqDampingCurve) != len(piano.RegisterCurveNotes) {
+		t.Fatalf("HFD curve has %d points, want the seeded %d", len(params.HighFreqDampingCurve), len(piano.RegisterCurveNotes))
+	}
+	if got := params.HighFreqDampingCurve[4].Value; got != 0.3 {
+		t.Fatalf("HFD curve point 4 = %v, want 0.3", got)
+	}
+	if got := params.HighFreqDampingCurve[0].Value; got != 0.12 {
+		t.Fatalf("unfitted HFD curve point = %v, want the seed 0.12", got)
+	}
+	if got := params.LossCurve[3].Value; got != 0.999 {
+		t.Fatalf("loss curve point 3 = %v, want 0.999", got)
+	}
+	if len(base.HighFreqDampingCurve) != 0 || len(base.LossCurve) != 0 {
+		t.Fatal("applyCandidate modified the base curves")
+	}
+}
+
+func TestApplyRegisterKnobsDoNotShareBaseCurve(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.LossCurve = piano.RegisterCurve{{Note: 48, Value: 0.9998}, {Note: 72, Value: 0.9994}}
+	defs, cand := addRegisterKnobs(nil, candidate{}, base, singleNote(60))
+	for i, d := range defs {
+		if d.Name == "loss_curve.1" {
+			cand.Vals[i] = 0.99
+		}
+	}
+	_, params, _, _ := mustApplyCandidate(t, base, 48000, 60, 118, 3.5, defs, cand)
+	if params.LossCurve[1].Value != 0.99 || base.LossCurve[1].Value != 0.9994 {
+		t.Fatalf("fitted curve %+v, base curve %+v", params.LossCurve, base.LossCurve)
+	}
+}
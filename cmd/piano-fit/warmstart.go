@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/internal/fitknobs"
+)
+
+// warmStartT60Exponent models how string decay times change across the
+// keyboard for --warm-start-from: T60 falls roughly with the square root
+// of the fundamental (about 20:1 over the compass).
+const warmStartT60Exponent = -0.5
+
+// loadWarmStart reads the note and best knobs of a piano-fit report.
+func loadWarmStart(path string) (note int, knobs map[string]float64, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	var rep struct {
+		Note      *int               `json:"note"`
+		BestKnobs map[string]float64 `json:"best_knobs"`
+	}
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return 0, nil, err
+	}
+	if rep.Note == nil || len(rep.BestKnobs) == 0 {
+		return 0, nil, fmt.Errorf("%s: not a piano-fit report (no note or best_knobs)", path)
+	}
+	return *rep.Note, rep.BestKnobs, nil
+}
+
+// transferKnobs maps the best knobs of a fit of note from onto note to.
+// The per-note knobs of from become those of to, scaled by the frequency
+// ratio r of the two notes: inharmonicity (proportional to the stiffness
+// coefficient B) grows with r² as for strings scaled in length, and the
+// loop loss, applied once per period, is set so T60 follows
+// warmStartT60Exponent. Strike position and all other knobs carry over
+// unchanged. Per-note knobs of other notes are dropped.
+func transferKnobs(knobs map[string]float64, from, to int) map[string]float64 {
+	r := math.Pow(2, float64(to-from)/12)
+	fromPrefix := fitknobs.PerNoteKnobName(from, "")
+	out := make(map[string]float64, len(knobs))
+	for name, v := range knobs {
+		if !strings.HasPrefix(name, "per_note.") {
+			out[name] = v
+			continue
+		}
+		field, ok := strings.CutPrefix(name, fromPrefix)
+		if !ok {
+			continue
+		}
+		switch field {
+		case "inharmonicity":
+			v *= r * r
+		case "loss":
+			// T60 = ln(1000) / (-ln(loss) * f0) for a per-period loss.
+			v = math.Pow(v, math.Pow(r, -1-warmStartT60Exponent))
+		}
+		out[fitknobs.PerNoteKnobName(to, field)] = v
+	}
+	return out
+}
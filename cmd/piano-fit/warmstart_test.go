@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTransferKnobsScalesPerNoteKnobs(t *testing.T) {
+	knobs := map[string]float64{
+		"output_gain":                 1.2,
+		"per_note.60.inharmonicity":   0.1,
+		"per_note.60.loss":            0.999,
+		"per_note.60.strike_position": 0.2,
+		"per_note.48.loss":            0.99,
+	}
+	got := transferKnobs(knobs, 60, 61)
+	r := math.Pow(2, 1.0/12)
+
+	if len(got) != 4 || got["output_gain"] != 1.2 || got["per_note.61.strike_position"] != 0.2 {
+		t.Fatalf("transferred knobs %v", got)
+	}
+	if want := 0.1 * r * r; math.Abs(got["per_note.61.inharmonicity"]-want) > 1e-12 {
+		t.Fatalf("inharmonicity %v, want %v (B scaled by r²)", got["per_note.61.inharmonicity"], want)
+	}
+	t60 := func(loss, f0 float64) float64 { return math.Log(1000) / (-math.Log(loss) * f0) }
+	ratio := t60(got["per_note.61.loss"], 440*r) / t60(0.999, 440)
+	if want := math.Pow(r, warmStartT60Exponent); math.Abs(ratio-want) > 1e-9 {
+		t.Fatalf("T60 ratio %v, want %v", ratio, want)
+	}
+}
+
+func TestLoadWarmStart(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "c4.report.json")
+	if err := os.WriteFile(good, []byte(`{"note": 59, "best_knobs": {"output_gain": 1.1}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	note, knobs, err := loadWarmStart(good)
+	if err != nil || note != 59 || knobs["output_gain"] != 1.1 {
+		t.Fatalf("loadWarmStart = %d, %v, %v", note, knobs, err)
+	}
+
+	bad := filepath.Join(dir, "other.json")
+	if err := os.WriteFile(bad, []byte(`{"best_score": 0.3}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadWarmStart(bad); err == nil {
+		t.Fatal("expected error for a report without note and knobs")
+	}
+	if _, _, err := loadWarmStart(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected error for a missing report")
+	}
+}
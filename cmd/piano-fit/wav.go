@@ -21,7 +21,3 @@ func writeMonoWAV(path string, data []float32, sampleRate int) error {
 func stereoToMono64(st []float32) []float64 {
 	return fitcommon.StereoToMono64(st)
 }
-
-func stereoRMS(interleaved []float32) float64 {
-	return fitcommon.StereoRMS(interleaved)
-}
@@ -0,0 +1,246 @@
+// Command piano-listening exports a listening test that checks objective
+// fit improvements by ear: onset-aligned segments of a reference recording
+// and of candidate renders (WAV files or presets) cut with identical fades,
+// loudness-matched, and arranged as randomized A/B pairs and MUSHRA trials
+// (hidden reference, low-pass anchor) with a JSON manifest that holds the
+// key.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+// manifest describes the exported test; Stimuli is the key from blind file
+// names to conditions.
+type manifest struct {
+	Tool         string        `json:"tool"`
+	SampleRate   int           `json:"sample_rate"`
+	Seed         int64         `json:"seed"`
+	LevelLUFS    float64       `json:"level_lufs"` // loudness of every stimulus
+	FadeMs       float64       `json:"fade_ms"`
+	Reference    string        `json:"reference"`
+	Conditions   []condition   `json:"conditions"`
+	AnchorHz     float64       `json:"anchor_hz,omitempty"`
+	Segments     []segment     `json:"segments"`
+	ABTrials     []abTrial     `json:"ab_trials"`
+	MUSHRATrials []mushraTrial `json:"mushra_trials"`
+	Stimuli      []*stimulus   `json:"stimuli"`
+}
+
+// condition is a candidate under test.
+type condition struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// source is the audio of a condition at the output rate.
+type source struct {
+	stereo []float32
+	onset  int
+}
+
+func main() {
+	referencePath := flag.String("reference", "reference/c4.wav", "Reference WAV path")
+	var candidates []condition
+	flag.Func("candidate", "Candidate as name=path, a WAV or a preset JSON rendered at --note/--velocity (repeatable)", func(v string) error {
+		name, path, ok := strings.Cut(v, "=")
+		if !ok || name == "" || path == "" {
+			return errors.New("want name=path")
+		}
+		candidates = append(candidates, condition{Name: name, Source: path})
+		return nil
+	})
+	outputDir := flag.String("output", "", "Directory for the stimuli WAVs and manifest.json (required)")
+	segmentsRaw := flag.String("segments", "0-3", "Comma-separated start-end windows in seconds after the note onset, e.g. 0-1.5,1.5-6")
+	fadeMs := flag.Float64("fade-ms", 10, "Raised-cosine fade length outside each window in ms")
+	lufs := flag.Float64("lufs", -23, "Integrated loudness every stimulus is matched to")
+	maxPeak := flag.Float64("max-peak-dbfs", -1, "Sample peak limit; all stimuli are lowered together to stay below it")
+	anchorHz := flag.Float64("anchor-hz", 3500, "Low-pass cutoff of the MUSHRA anchor (0 = no anchor)")
+	seed := flag.Int64("seed", 1, "Seed of the trial randomization")
+	sampleRate := flag.Int("sample-rate", 48000, "Output sample rate in Hz")
+	note := flag.Int("note", 60, "MIDI note rendered for preset candidates")
+	velocity := flag.Int("velocity", 100, "MIDI velocity rendered for preset candidates")
+	releaseAfter := flag.Float64("release-after", 2.0, "Note hold time before NoteOff for preset candidates, in seconds")
+	fitcommon.ParseFlags()
+
+	if *outputDir == "" {
+		die("--output is required")
+	}
+	if len(candidates) == 0 {
+		die("at least one --candidate is required")
+	}
+	if err := checkConditionNames(candidates); err != nil {
+		die("%v", err)
+	}
+	segments, err := parseSegments(*segmentsRaw)
+	if err != nil {
+		die("invalid --segments: %v", err)
+	}
+	if *fadeMs < 0 {
+		die("fade-ms must be >= 0")
+	}
+	if *anchorHz < 0 || *anchorHz >= 0.5*float64(*sampleRate) {
+		die("anchor-hz must be in [0, %d)", *sampleRate/2)
+	}
+	fade := int(math.Round(*fadeMs * 0.001 * float64(*sampleRate)))
+	renderSec := 0.0
+	for _, s := range segments {
+		renderSec = math.Max(renderSec, s.End)
+	}
+	// Renders leave room for the fade-out and a late onset.
+	renderSec += *fadeMs*0.001 + 0.5
+
+	sources := make(map[string]source, len(candidates)+2)
+	ref, err := loadWAV(*referencePath, *sampleRate)
+	if err != nil {
+		die("failed to read reference: %v", err)
+	}
+	sources[referenceCondition] = newSource(ref)
+	if *anchorHz > 0 {
+		sources[anchorCondition] = source{
+			stereo: lowpassAnchor(ref, *sampleRate, *anchorHz),
+			onset:  sources[referenceCondition].onset,
+		}
+	}
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		audio, err := loadCandidate(c.Source, *sampleRate, *note, *velocity, *releaseAfter, renderSec)
+		if err != nil {
+			die("candidate %s: %v", c.Name, err)
+		}
+		sources[c.Name] = newSource(audio)
+		names[i] = c.Name
+	}
+
+	ab, mushra, stimuli := planTrials(len(segments), names, *anchorHz > 0, rand.New(rand.NewSource(*seed)))
+	for _, s := range stimuli {
+		src := sources[s.Condition]
+		s.samples = cutSegment(src.stereo, *sampleRate, src.onset, segments[s.Segment], fade)
+	}
+	level, err := loudnessMatch(stimuli, *sampleRate, *lufs, *maxPeak)
+	if err != nil {
+		die("loudness matching failed: %v", err)
+	}
+	if level < *lufs {
+		fmt.Fprintf(os.Stderr, "warning: stimuli lowered to %.1f LUFS to keep peaks below %.1f dBFS\n", level, *maxPeak)
+	}
+
+	if err := os.MkdirAll(*outputDir, 0o755); err != nil {
+		die("failed to create output dir: %v", err)
+	}
+	for _, s := range stimuli {
+		if err := fitcommon.WriteStereoInterleavedWAV(filepath.Join(*outputDir, s.File), s.samples, *sampleRate); err != nil {
+			die("failed to write %s: %v", s.File, err)
+		}
+	}
+	m := manifest{
+		Tool:         "piano-listening",
+		SampleRate:   *sampleRate,
+		Seed:         *seed,
+		LevelLUFS:    level,
+		FadeMs:       *fadeMs,
+		Reference:    *referencePath,
+		Conditions:   candidates,
+		AnchorHz:     *anchorHz,
+		Segments:     segments,
+		ABTrials:     ab,
+		MUSHRATrials: mushra,
+		Stimuli:      stimuli,
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		die("failed to encode manifest: %v", err)
+	}
+	manifestPath := filepath.Join(*outputDir, "manifest.json")
+	if err := os.WriteFile(manifestPath, append(b, '\n'), 0o644); err != nil {
+		die("failed to write manifest: %v", err)
+	}
+	fmt.Printf("Wrote %d A/B and %d MUSHRA trials (%d stimuli at %.1f LUFS) to %s\n", len(ab), len(mushra), len(stimuli), level, manifestPath)
+}
+
+// checkConditionNames rejects duplicate and reserved candidate names.
+func checkConditionNames(conds []condition) error {
+	seen := make(map[string]bool, len(conds))
+	for _, c := range conds {
+		switch {
+		case c.Name == referenceCondition || c.Name == anchorCondition:
+			return fmt.Errorf("candidate name %q is reserved", c.Name)
+		case seen[c.Name]:
+			return fmt.Errorf("duplicate candidate name %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+func newSource(stereo []float32) source {
+	return source{stereo: stereo, onset: onsetFrame(stereo)}
+}
+
+// loadWAV reads a WAV file as interleaved stereo at sampleRate; mono files
+// play on both channels and further channels are dropped.
+func loadWAV(path string, sampleRate int) ([]float32, error) {
+	channels, sr, err := fitcommon.ReadWAVChannels(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 1 {
+		channels = append(channels, channels[0])
+	}
+	left, err := fitcommon.ResampleIfNeeded(channels[0], sr, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	right, err := fitcommon.ResampleIfNeeded(channels[1], sr, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float32, 2*min(len(left), len(right)))
+	for i := 0; i < len(out)/2; i++ {
+		out[2*i] = float32(left[i])
+		out[2*i+1] = float32(right[i])
+	}
+	return out, nil
+}
+
+// loadCandidate reads a WAV candidate or renders a preset one (a .json
+// path) for seconds.
+func loadCandidate(path string, sampleRate, note, velocity int, releaseAfter, seconds float64) ([]float32, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return loadWAV(path, sampleRate)
+	}
+	params, err := preset.LoadJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	p := piano.NewPiano(sampleRate, 16, params)
+	p.NoteOn(note, velocity)
+	frames := int(seconds * float64(sampleRate))
+	releaseAt := int(releaseAfter * float64(sampleRate))
+	const blockSize = 128
+	out := make([]float32, 0, 2*frames)
+	for done := 0; done < frames; done += blockSize {
+		if done <= releaseAt && releaseAt < done+blockSize {
+			p.NoteOff(note)
+		}
+		out = append(out, p.Process(min(blockSize, frames-done))...)
+	}
+	return out, nil
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+// tone returns frames of interleaved stereo silence followed by a decaying
+// sine from frame onset.
+func tone(frames, onset int, amp float64) []float32 {
+	out := make([]float32, 2*frames)
+	for i := onset; i < frames; i++ {
+		v := float32(amp * math.Exp(-float64(i-onset)/20000) * math.Sin(2*math.Pi*440*float64(i)/48000))
+		out[2*i], out[2*i+1] = v, v
+	}
+	return out
+}
+
+func TestParseSegments(t *testing.T) {
+	segs, err := parseSegments("0-1.5, 1.5-4")
+	if err != nil || len(segs) != 2 || segs[1] != (segment{Start: 1.5, End: 4}) {
+		t.Fatalf("segments %v, err %v", segs, err)
+	}
+	for _, bad := range []string{"", "3", "2-1", "-1-2", "a-b"} {
+		if _, err := parseSegments(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestCutSegmentAlignsOnsetsAndFadesOutside(t *testing.T) {
+	const fade = 480
+	early, late := tone(96000, 1000, 0.5), tone(96000, 7000, 0.5)
+	a := cutSegment(early, 48000, onsetFrame(early), segment{Start: 0, End: 0.5}, fade)
+	b := cutSegment(late, 48000, onsetFrame(late), segment{Start: 0, End: 0.5}, fade)
+	if len(a) != len(b) || len(a) != 2*(24000+2*fade) {
+		t.Fatalf("segment lengths %d and %d", len(a), len(b))
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-6 {
+			t.Fatalf("frame %d differs between onset-aligned cuts: %g vs %g", i/2, a[i], b[i])
+		}
+	}
+	// The fade-in lies before the onset, so the attack is not attenuated.
+	src := 2 * (onsetFrame(early) + 10)
+	if got := a[2*(fade+10)]; got != early[src] {
+		t.Fatalf("attack sample %g, want the unfaded %g", got, early[src])
+	}
+	// The fade-out ends in silence.
+	if last := a[len(a)-2]; math.Abs(float64(last)) > 1e-3 {
+		t.Fatalf("last sample %g, want faded out", last)
+	}
+}
+
+func TestLoudnessMatchEqualizesStimuliUnderPeakLimit(t *testing.T) {
+	loud := &stimulus{File: "a.wav", samples: tone(48000, 0, 0.9)}
+	soft := &stimulus{File: "b.wav", samples: tone(48000, 0, 0.01)}
+	level, err := loudnessMatch([]*stimulus{loud, soft}, 48000, -23, -1)
+	if err != nil || level != -23 {
+		t.Fatalf("level %g, err %v", level, err)
+	}
+	for _, s := range []*stimulus{loud, soft} {
+		if got := analysis.IntegratedLoudnessInterleaved(s.samples, 2, 48000); math.Abs(got+23) > 0.01 {
+			t.Fatalf("%s at %.2f LUFS after matching", s.File, got)
+		}
+	}
+
+	// A target above the peak limit lowers both by the same amount.
+	loud.samples, soft.samples = tone(48000, 0, 0.9), tone(48000, 0, 0.01)
+	level, err = loudnessMatch([]*stimulus{loud, soft}, 48000, 0, -1)
+	if err != nil || level >= 0 {
+		t.Fatalf("level %g, err %v; want lowered below 0 LUFS", level, err)
+	}
+	if math.Max(loud.PeakDBFS, soft.PeakDBFS) > -1+1e-6 {
+		t.Fatalf("peaks %g and %g dBFS above the limit", loud.PeakDBFS, soft.PeakDBFS)
+	}
+	la := analysis.IntegratedLoudnessInterleaved(loud.samples, 2, 48000)
+	lb := analysis.IntegratedLoudnessInterleaved(soft.samples, 2, 48000)
+	if math.Abs(la-lb) > 0.01 || math.Abs(la-level) > 0.01 {
+		t.Fatalf("loudness %.2f and %.2f LUFS, want both at %.2f", la, lb, level)
+	}
+
+	if _, err := loudnessMatch([]*stimulus{{File: "s.wav", samples: make([]float32, 9600)}}, 48000, -23, -1); err == nil {
+		t.Fatal("a silent stimulus was matched")
+	}
+}
+
+func TestPlanTrialsCoversConditionsBlind(t *testing.T) {
+	cands := []string{"fit", "default", "aged"}
+	ab, mushra, stimuli := planTrials(2, cands, true, rand.New(rand.NewSource(3)))
+	if len(ab) != 6 || len(mushra) != 2 {
+		t.Fatalf("%d A/B and %d MUSHRA trials, want 6 and 2", len(ab), len(mushra))
+	}
+	byFile := make(map[string]*stimulus, len(stimuli))
+	for _, s := range stimuli {
+		if byFile[s.File] != nil {
+			t.Fatalf("file %s used twice", s.File)
+		}
+		byFile[s.File] = s
+		for _, c := range append(cands, referenceCondition, anchorCondition) {
+			if strings.Contains(s.File, c) {
+				t.Fatalf("file name %s gives away the condition", s.File)
+			}
+		}
+	}
+
+	pairs := map[[2]any]bool{}
+	refSides := map[string]int{}
+	for _, tr := range ab {
+		pairs[[2]any{tr.Segment, tr.Condition}] = true
+		refSides[tr.Reference]++
+		ref, other := byFile[tr.A], byFile[tr.B]
+		if tr.Reference == "b" {
+			ref, other = other, ref
+		}
+		if ref.Condition != referenceCondition || other.Condition != tr.Condition || ref.Segment != tr.Segment || other.Segment != tr.Segment {
+			t.Fatalf("trial %s plays %s/%s", tr.ID, byFile[tr.A].Condition, byFile[tr.B].Condition)
+		}
+	}
+	if len(pairs) != 6 || refSides["a"] == 0 || refSides["b"] == 0 {
+		t.Fatalf("pairs %v, reference sides %v", pairs, refSides)
+	}
+
+	for _, tr := range mushra {
+		if byFile[tr.Reference].Condition != referenceCondition {
+			t.Fatalf("trial %s open reference is %s", tr.ID, byFile[tr.Reference].Condition)
+		}
+		seen := map[string]bool{}
+		for _, f := range tr.Stimuli {
+			seen[byFile[f].Condition] = true
+		}
+		if len(tr.Stimuli) != 5 || !seen[referenceCondition] || !seen[anchorCondition] || !seen["aged"] {
+			t.Fatalf("trial %s rates %v", tr.ID, seen)
+		}
+	}
+
+	again, _, _ := planTrials(2, cands, true, rand.New(rand.NewSource(3)))
+	for i := range ab {
+		if ab[i] != again[i] {
+			t.Fatal("the same seed planned different trials")
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cwbudde/algo-dsp/dsp/filter/biquad"
+	"github.com/cwbudde/algo-dsp/dsp/filter/design"
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+// onsetThresholdDB is the level below the peak a signal must first exceed
+// to count as the note onset that segments are measured from.
+const onsetThresholdDB = -40
+
+// segment is a time window relative to the note onset, in seconds.
+type segment struct {
+	Start float64 `json:"start_s"`
+	End   float64 `json:"end_s"`
+}
+
+// parseSegments parses a comma-separated list of start-end windows in
+// seconds, e.g. "0-2.5,2.5-6".
+func parseSegments(raw string) ([]segment, error) {
+	var segs []segment
+	for _, s := range strings.Split(raw, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		lo, hi, ok := strings.Cut(s, "-")
+		if !ok {
+			return nil, fmt.Errorf("segment %q is not start-end", s)
+		}
+		start, err := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+		if err != nil {
+			return nil, fmt.Errorf("segment %q: %w", s, err)
+		}
+		end, err := strconv.ParseFloat(strings.TrimSpace(hi), 64)
+		if err != nil {
+			return nil, fmt.Errorf("segment %q: %w", s, err)
+		}
+		if start < 0 || end <= start {
+			return nil, fmt.Errorf("segment %q must satisfy 0 <= start < end", s)
+		}
+		segs = append(segs, segment{Start: start, End: end})
+	}
+	if len(segs) == 0 {
+		return nil, errors.New("no segments specified")
+	}
+	return segs, nil
+}
+
+// onsetFrame returns the first frame of interleaved stereo samples whose
+// level exceeds onsetThresholdDB below the peak (0 for silence).
+func onsetFrame(stereo []float32) int {
+	peak := 0.0
+	for _, v := range stereo {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	if peak == 0 {
+		return 0
+	}
+	thresh := peak * math.Pow(10, onsetThresholdDB/20.0)
+	for i, v := range stereo {
+		if math.Abs(float64(v)) >= thresh {
+			return i / 2
+		}
+	}
+	return 0
+}
+
+// cutSegment returns seg of stereo measured from onset, with raised-cosine
+// fades of fade frames outside the window: the fade-in ends where the
+// window starts and the fade-out begins where it ends, so a window starting
+// at the onset keeps the attack intact. Frames outside the signal are
+// silence. Every stimulus cut with the same fade gets identical fades.
+func cutSegment(stereo []float32, sampleRate, onset int, seg segment, fade int) []float32 {
+	start := onset + int(math.Round(seg.Start*float64(sampleRate))) - fade
+	frames := int(math.Round((seg.End-seg.Start)*float64(sampleRate))) + 2*fade
+	out := make([]float32, 2*frames)
+	for i := range frames {
+		src := start + i
+		if src < 0 || 2*src+1 >= len(stereo) {
+			continue
+		}
+		g := float32(1)
+		if i < fade {
+			g = raisedCosine(i, fade)
+		} else if j := frames - 1 - i; j < fade {
+			g = raisedCosine(j, fade)
+		}
+		out[2*i] = g * stereo[2*src]
+		out[2*i+1] = g * stereo[2*src+1]
+	}
+	return out
+}
+
+// raisedCosine is the gain of frame i of an n-frame fade-in.
+func raisedCosine(i, n int) float32 {
+	return float32(0.5 - 0.5*math.Cos(math.Pi*(float64(i)+0.5)/float64(n)))
+}
+
+// lowpassAnchor returns stereo low-passed at hz (8th-order Butterworth), the
+// MUSHRA low anchor.
+func lowpassAnchor(stereo []float32, sampleRate int, hz float64) []float32 {
+	coeffs := design.ButterworthLP(hz, 8, float64(sampleRate))
+	left, right := biquad.NewChain(coeffs), biquad.NewChain(coeffs)
+	out := make([]float32, len(stereo))
+	for i := 0; i+1 < len(stereo); i += 2 {
+		out[i] = float32(left.ProcessSample(float64(stereo[i])))
+		out[i+1] = float32(right.ProcessSample(float64(stereo[i+1])))
+	}
+	return out
+}
+
+// loudnessMatch scales every stimulus in place to targetLUFS integrated
+// loudness (ITU-R BS.1770). When that would push a sample peak above
+// maxPeakDBFS, all stimuli are lowered by the same amount, so they stay
+// matched; the level reached is returned.
+func loudnessMatch(stimuli []*stimulus, sampleRate int, targetLUFS, maxPeakDBFS float64) (float64, error) {
+	peak := math.Inf(-1)
+	for _, s := range stimuli {
+		s.MeasuredLUFS = analysis.IntegratedLoudnessInterleaved(s.samples, 2, sampleRate)
+		if math.IsInf(s.MeasuredLUFS, -1) {
+			return 0, fmt.Errorf("stimulus %s (%s, segment %d) is silent", s.File, s.Condition, s.Segment+1)
+		}
+		s.GainDB = targetLUFS - s.MeasuredLUFS
+		peak = math.Max(peak, s.rawPeakDBFS()+s.GainDB)
+	}
+	level := targetLUFS
+	if excess := peak - maxPeakDBFS; excess > 0 {
+		level -= excess
+		for _, s := range stimuli {
+			s.GainDB -= excess
+		}
+	}
+	for _, s := range stimuli {
+		g := float32(math.Pow(10, s.GainDB/20))
+		for i := range s.samples {
+			s.samples[i] *= g
+		}
+		s.PeakDBFS = s.rawPeakDBFS()
+	}
+	return level, nil
+}
+
+// rawPeakDBFS is the sample peak of the stimulus as it stands.
+func (s *stimulus) rawPeakDBFS() float64 {
+	peak := 0.0
+	for _, v := range s.samples {
+		peak = math.Max(peak, math.Abs(float64(v)))
+	}
+	return 20 * math.Log10(peak)
+}
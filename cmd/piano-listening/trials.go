@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Condition names of the stimuli that are not candidates.
+const (
+	referenceCondition = "reference"
+	anchorCondition    = "anchor"
+)
+
+// stimulus is one WAV file of the test: a segment of one condition.
+type stimulus struct {
+	File         string  `json:"file"`
+	Condition    string  `json:"condition"`
+	Segment      int     `json:"segment"` // index into manifest.Segments
+	MeasuredLUFS float64 `json:"measured_lufs"`
+	GainDB       float64 `json:"gain_db"`
+	PeakDBFS     float64 `json:"peak_dbfs"`
+
+	samples []float32 // interleaved stereo
+}
+
+// abTrial pairs the reference with one candidate on one segment, in random
+// order.
+type abTrial struct {
+	ID        string `json:"id"`
+	Segment   int    `json:"segment"`
+	Condition string `json:"condition"` // the candidate
+	A         string `json:"a"`
+	B         string `json:"b"`
+	Reference string `json:"reference"` // "a" or "b"
+}
+
+// mushraTrial rates all conditions of one segment against the open
+// reference: the candidates, the hidden reference and the anchor, in
+// random order under blind file names.
+type mushraTrial struct {
+	ID        string   `json:"id"`
+	Segment   int      `json:"segment"`
+	Reference string   `json:"reference"`
+	Stimuli   []string `json:"stimuli"`
+}
+
+// planTrials lays out the A/B and MUSHRA trials of candidates over
+// segments and returns them with the stimuli they play (without audio).
+// Sides, presentation orders and trial orders are drawn from rng; file
+// names carry only the trial, so the conditions stay blind until the
+// stimuli list of the manifest is looked up.
+func planTrials(segments int, candidates []string, anchor bool, rng *rand.Rand) ([]abTrial, []mushraTrial, []*stimulus) {
+	var stimuli []*stimulus
+	add := func(file, cond string, seg int) string {
+		stimuli = append(stimuli, &stimulus{File: file, Condition: cond, Segment: seg})
+		return file
+	}
+
+	type pair struct {
+		seg  int
+		cand string
+	}
+	var pairs []pair
+	for seg := range segments {
+		for _, c := range candidates {
+			pairs = append(pairs, pair{seg, c})
+		}
+	}
+	rng.Shuffle(len(pairs), func(i, j int) { pairs[i], pairs[j] = pairs[j], pairs[i] })
+	ab := make([]abTrial, len(pairs))
+	for i, p := range pairs {
+		id := fmt.Sprintf("ab-%02d", i+1)
+		a, b, ref := referenceCondition, p.cand, "a"
+		if rng.Intn(2) == 1 {
+			a, b, ref = b, a, "b"
+		}
+		ab[i] = abTrial{
+			ID:        id,
+			Segment:   p.seg,
+			Condition: p.cand,
+			A:         add(id+"-a.wav", a, p.seg),
+			B:         add(id+"-b.wav", b, p.seg),
+			Reference: ref,
+		}
+	}
+
+	conds := append([]string{referenceCondition}, candidates...)
+	if anchor {
+		conds = append(conds, anchorCondition)
+	}
+	order := rng.Perm(segments)
+	mushra := make([]mushraTrial, segments)
+	for i, seg := range order {
+		id := fmt.Sprintf("mushra-%02d", i+1)
+		t := mushraTrial{ID: id, Segment: seg, Reference: add(id+"-ref.wav", referenceCondition, seg)}
+		for k, c := range rng.Perm(len(conds)) {
+			t.Stimuli = append(t.Stimuli, add(fmt.Sprintf("%s-%d.wav", id, k+1), conds[c], seg))
+		}
+		mushra[i] = t
+	}
+	return ab, mushra, stimuli
+}
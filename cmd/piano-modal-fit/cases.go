@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// calibrationCase is one DWG reference the modal model is matched to: a
+// note at a velocity, with the sustain pedal up or held down from before
+// the strike. The damped and undamped modal losses trade off differently
+// across velocities and pedal states, so fitting several keeps one state
+// from being bought at the cost of another.
+type calibrationCase struct {
+	Note      int  `json:"note"`
+	Velocity  int  `json:"velocity"`
+	PedalDown bool `json:"pedal_down,omitempty"`
+}
+
+func (c calibrationCase) String() string {
+	return fmt.Sprintf("note %d velocity %d pedal %s", c.Note, c.Velocity, pedalName(c.PedalDown))
+}
+
+// settings returns rs set up to render c.
+func (c calibrationCase) settings(rs renderSettings) renderSettings {
+	rs.note = c.Note
+	rs.velocity = c.Velocity
+	rs.pedal = c.PedalDown
+	return rs
+}
+
+// calibrationCases returns every combination of notes, velocities and
+// pedal states, note by note.
+func calibrationCases(notes, velocities []int, pedals []bool) []calibrationCase {
+	cases := make([]calibrationCase, 0, len(notes)*len(velocities)*len(pedals))
+	for _, n := range notes {
+		for _, v := range velocities {
+			for _, down := range pedals {
+				cases = append(cases, calibrationCase{Note: n, Velocity: v, PedalDown: down})
+			}
+		}
+	}
+	return cases
+}
+
+// parseVelocities parses a comma-separated list of MIDI velocities,
+// dropping duplicates.
+func parseVelocities(raw string) ([]int, error) {
+	var out []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid velocity %q", part)
+		}
+		if v < 1 || v > 127 {
+			return nil, fmt.Errorf("velocity out of range [1,127]: %d", v)
+		}
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("empty velocity list")
+	}
+	return out, nil
+}
+
+// parsePedalStates parses a comma-separated list of up/down sustain pedal
+// states.
+func parsePedalStates(raw string) ([]bool, error) {
+	var out []bool
+	seen := make(map[bool]bool)
+	for _, part := range strings.Split(raw, ",") {
+		var down bool
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "":
+			continue
+		case "up":
+		case "down":
+			down = true
+		default:
+			return nil, fmt.Errorf("pedal state %q must be up or down", part)
+		}
+		if !seen[down] {
+			seen[down] = true
+			out = append(out, down)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("empty pedal state list")
+	}
+	return out, nil
+}
+
+func pedalName(down bool) string {
+	if down {
+		return "down"
+	}
+	return "up"
+}
+
+// pedalNames returns the names of pedal states as --pedal takes them.
+func pedalNames(pedals []bool) []string {
+	out := make([]string, len(pedals))
+	for i, down := range pedals {
+		out[i] = pedalName(down)
+	}
+	return out
+}
+
+// stateScore is the mean combined score of the notes of one velocity and
+// pedal state.
+type stateScore struct {
+	Velocity  int     `json:"velocity"`
+	PedalDown bool    `json:"pedal_down,omitempty"`
+	Score     float64 `json:"score"`
+}
+
+// stateScores averages the combined scores of per-case results by velocity
+// and pedal state, in first-seen order.
+func stateScores(results []noteCalibration) []stateScore {
+	type state struct {
+		velocity int
+		down     bool
+	}
+	var order []state
+	sums := make(map[state]float64)
+	counts := make(map[state]int)
+	for _, r := range results {
+		s := state{r.Velocity, r.PedalDown}
+		if counts[s] == 0 {
+			order = append(order, s)
+		}
+		sums[s] += r.CombinedScore
+		counts[s]++
+	}
+	out := make([]stateScore, len(order))
+	for i, s := range order {
+		out[i] = stateScore{Velocity: s.velocity, PedalDown: s.down, Score: sums[s] / float64(counts[s])}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+// silentLUFS stands in for the loudness of a window the BS.1770 gate finds
+// silent, so reports stay valid JSON.
+const silentLUFS = -120.0
+
+// windowWeight is the weight of a match window in a note's windowed score:
+// the window's base weight scaled by the reference's perceived loudness in
+// the window relative to its loudest window. Loudness ratios follow
+// Stevens' sone scale, halving for every 10 LU, so a window 40 LU below
+// the attack counts 1/16 as much; a window the gate finds silent does not
+// count.
+type windowWeight struct {
+	Window       string  `json:"window"`
+	BaseWeight   float64 `json:"base_weight"`
+	LoudnessLUFS float64 `json:"loudness_lufs"`
+	RelativeLU   float64 `json:"relative_lu"` // loudness below the loudest window
+	Factor       float64 `json:"loudness_factor"`
+	Weight       float64 `json:"weight"`
+}
+
+// noteReference is the reference render of a note with its window weights.
+type noteReference struct {
+	mono    []float64
+	windows []windowWeight
+}
+
+// referenceWindowWeights measures the loudness of ref in each window and
+// returns the windows' weights; with loudness weighting off (or a silent
+// reference) the base weights are used unchanged.
+func referenceWindowWeights(ref []float64, sampleRate int, windows []windowSpec, loudnessWeighting bool) []windowWeight {
+	out := make([]windowWeight, len(windows))
+	loudest := math.Inf(-1)
+	for i, w := range windows {
+		start := min(max(int(w.startS*float64(sampleRate)), 0), len(ref))
+		end := min(max(int(w.endS*float64(sampleRate)), start), len(ref))
+		l := analysis.IntegratedLoudness([][]float64{ref[start:end]}, sampleRate)
+		if math.IsInf(l, -1) || l < silentLUFS {
+			l = silentLUFS
+		}
+		out[i] = windowWeight{Window: w.name, BaseWeight: w.weight, LoudnessLUFS: l, Factor: 1, Weight: w.weight}
+		loudest = math.Max(loudest, l)
+	}
+	if !loudnessWeighting || loudest <= silentLUFS {
+		return out
+	}
+	for i := range out {
+		out[i].RelativeLU = out[i].LoudnessLUFS - loudest
+		out[i].Factor = math.Pow(2, out[i].RelativeLU/10)
+		if out[i].LoudnessLUFS <= silentLUFS {
+			out[i].Factor = 0
+		}
+		out[i].Weight = out[i].BaseWeight * out[i].Factor
+	}
+	return out
+}
+
+// weights returns the Weight of each window.
+func (r noteReference) weights() []float64 {
+	w := make([]float64, len(r.windows))
+	for i, ww := range r.windows {
+		w[i] = ww.Weight
+	}
+	return w
+}
@@ -1,21 +1,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"math"
 	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/cwbudde/algo-piano/analysis"
+	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/internal/fitknobs"
 	"github.com/cwbudde/algo-piano/piano"
 	"github.com/cwbudde/algo-piano/preset"
+	"github.com/cwbudde/algo-piano/render"
 	"github.com/cwbudde/mayfly"
 )
 
@@ -27,35 +34,54 @@ type knobSet struct {
 	ModalDampedLoss   float64 `json:"modal_damped_loss"`
 }
 
+// LogValue logs the knobs as a group keyed like the report.
+func (k knobSet) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("modal_partials", k.ModalPartials),
+		slog.Float64("modal_gain_exponent", k.ModalGainExponent),
+		slog.Float64("modal_excitation", k.ModalExcitation),
+		slog.Float64("modal_undamped_loss", k.ModalUndampedLoss),
+		slog.Float64("modal_damped_loss", k.ModalDampedLoss),
+	)
+}
+
 type noteCalibration struct {
 	Note          int              `json:"note"`
+	Velocity      int              `json:"velocity"`
+	PedalDown     bool             `json:"pedal_down,omitempty"`
 	Full          analysis.Metrics `json:"full"`
 	Attack        analysis.Metrics `json:"attack"`
 	EarlySustain  analysis.Metrics `json:"early_sustain"`
 	Decay         analysis.Metrics `json:"decay"`
 	WindowedScore float64          `json:"windowed_score"`
 	CombinedScore float64          `json:"combined_score"`
+	Windows       []windowWeight   `json:"windows"` // weighting curve of the windowed score
 }
 
 type calibrationReport struct {
-	ProfileVersion string            `json:"profile_version"`
-	TimestampUTC   string            `json:"timestamp_utc"`
-	BasePreset     string            `json:"base_preset"`
-	OutputPreset   string            `json:"output_preset"`
-	SampleRate     int               `json:"sample_rate"`
-	Velocity       int               `json:"velocity"`
-	ReleaseAfter   float64           `json:"release_after_seconds"`
-	Notes          []int             `json:"notes"`
-	Evaluations    int               `json:"evaluations"`
-	BestScore      float64           `json:"best_score"`
-	BestKnobs      knobSet           `json:"best_knobs"`
-	PerNote        []noteCalibration `json:"per_note"`
-	ElapsedSec     float64           `json:"elapsed_seconds"`
+	ProfileVersion    string            `json:"profile_version"`
+	TimestampUTC      string            `json:"timestamp_utc"`
+	BasePreset        string            `json:"base_preset"`
+	OutputPreset      string            `json:"output_preset"`
+	SampleRate        int               `json:"sample_rate"`
+	Velocities        []int             `json:"velocities"`
+	PedalStates       []string          `json:"pedal_states"`
+	ReleaseAfter      float64           `json:"release_after_seconds"`
+	Notes             []int             `json:"notes"`
+	LoudnessWeighting bool              `json:"loudness_weighting"`
+	Evaluations       int               `json:"evaluations"`
+	BestScore         float64           `json:"best_score"`
+	BestKnobs         knobSet           `json:"best_knobs"`
+	StateScores       []stateScore      `json:"state_scores"` // mean combined score per velocity and pedal state
+	PerNote           []noteCalibration `json:"per_note"`
+	ElapsedSec        float64           `json:"elapsed_seconds"`
+	Interrupted       bool              `json:"interrupted,omitempty"` // stopped by a signal before the budget ran out
 }
 
 type renderSettings struct {
 	note           int
 	velocity       int
+	pedal          bool // sustain pedal held down from before the strike
 	sampleRate     int
 	decayDBFS      float64
 	decayHold      int
@@ -81,11 +107,13 @@ var matchWindows = []windowSpec{
 const modalKnobDims = 5
 
 func main() {
-	basePreset := flag.String("preset", "assets/presets/default.json", "DWG reference preset JSON path")
+	basePreset := flag.String("preset", preset.DefaultPresetPath, "DWG reference preset JSON path")
 	outputPreset := flag.String("output-preset", "assets/presets/modal-calibrated.json", "Path to write calibrated modal preset JSON")
 	reportPath := flag.String("report", "", "Optional report JSON path (default: <output-preset>.report.json)")
 	notesRaw := flag.String("notes", "36,48,60,72,84", "Comma-separated MIDI notes to match")
 	velocity := flag.Int("velocity", 118, "Velocity used for calibration renders")
+	velocitiesRaw := flag.String("velocities", "", "Comma-separated velocities to calibrate across (default: --velocity)")
+	pedalRaw := flag.String("pedal", "up", "Comma-separated sustain pedal states to calibrate across: up, down or up,down")
 	releaseAfter := flag.Float64("release-after", 3.2, "Seconds before NoteOff during calibration renders")
 	sampleRate := flag.Int("sample-rate", 48000, "Render/analysis sample rate")
 	decayDBFS := flag.Float64("decay-dbfs", -90.0, "Auto-stop threshold in dBFS")
@@ -97,7 +125,15 @@ func main() {
 	mayflyVariant := flag.String("mayfly-variant", "desma", "Mayfly variant: ma|desma|olce|eobbma|gsasma|mpma|aoblmoa")
 	mayflyPop := flag.Int("mayfly-pop", 10, "Male/female population size per Mayfly run")
 	seed := flag.Int64("seed", 1, "Random seed")
-	flag.Parse()
+	loudnessWeighting := flag.Bool("loudness-weighting", true, "Scale each match window's weight by the reference's loudness in it (halved per 10 LU below the loudest window), so quiet late windows cannot dominate the windowed score")
+	logConfig := fitcommon.RegisterLogFlags()
+	profileConfig := fitcommon.RegisterProfileFlags()
+	fitcommon.ParseFlags()
+
+	log, err := logConfig.NewLogger(os.Stdout)
+	if err != nil {
+		die("%v", err)
+	}
 
 	if *sampleRate < 8000 {
 		die("sample-rate must be >= 8000")
@@ -105,6 +141,9 @@ func main() {
 	if *velocity < 1 || *velocity > 127 {
 		die("velocity must be in [1,127]")
 	}
+	if strings.TrimSpace(*velocitiesRaw) == "" {
+		*velocitiesRaw = strconv.Itoa(*velocity)
+	}
 	if *releaseAfter < 0.05 {
 		die("release-after must be >= 0.05")
 	}
@@ -131,6 +170,15 @@ func main() {
 	if err != nil {
 		die("notes: %v", err)
 	}
+	velocities, err := parseVelocities(*velocitiesRaw)
+	if err != nil {
+		die("velocities: %v", err)
+	}
+	pedals, err := parsePedalStates(*pedalRaw)
+	if err != nil {
+		die("pedal: %v", err)
+	}
+	cases := calibrationCases(notes, velocities, pedals)
 
 	base, err := preset.LoadJSON(*basePreset)
 	if err != nil {
@@ -138,7 +186,6 @@ func main() {
 	}
 
 	rs := renderSettings{
-		velocity:       *velocity,
 		sampleRate:     *sampleRate,
 		decayDBFS:      *decayDBFS,
 		decayHold:      *decayHoldBlocks,
@@ -151,27 +198,38 @@ func main() {
 	start := time.Now()
 
 	// Build DWG references once.
-	fmt.Printf("Rendering DWG references for notes: %v\n", notes)
+	log.Info("rendering DWG references", "notes", notes, "velocities", velocities, "pedal", pedalNames(pedals))
 	refParams := cloneParams(base)
 	refParams.StringModel = piano.StringModelDWG
-	references := make(map[int][]float64, len(notes))
-	for _, n := range notes {
-		rs.note = n
-		mono, err := renderNote(refParams, rs)
+	references := make(map[calibrationCase]noteReference, len(cases))
+	for _, c := range cases {
+		mono, err := renderNote(refParams, c.settings(rs))
 		if err != nil {
-			die("render DWG reference note %d: %v", n, err)
+			die("render DWG reference %v: %v", c, err)
 		}
-		references[n] = mono
+		ref := noteReference{mono: mono, windows: referenceWindowWeights(mono, rs.sampleRate, matchWindows, *loudnessWeighting)}
+		log.Debug("window weights", "note", c.Note, "velocity", c.Velocity, "pedal_down", c.PedalDown, "weights", ref.weights())
+		references[c] = ref
 	}
 
+	stopProfile, err := profileConfig.Start(log)
+	if err != nil {
+		die("%v", err)
+	}
 	rng := rand.New(rand.NewSource(*seed))
 	best := initialKnobs(base)
-	bestScore, _, err := evaluateKnobs(base, best, notes, references, rs)
+	bestScore, _, err := evaluateKnobs(base, best, cases, references, rs)
 	if err != nil {
 		die("initial evaluation failed: %v", err)
 	}
 	evals := 1
-	fmt.Printf("Initial score=%.4f knobs=%+v\n", bestScore, best)
+	log.Info("initial", "score", bestScore, "knobs", best)
+
+	// SIGINT/SIGTERM end the search early; the best knobs so far are still
+	// written below. A second signal terminates as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
 
 	variant := strings.ToLower(strings.TrimSpace(*mayflyVariant))
 	mayflyBudget := *iters
@@ -189,24 +247,26 @@ func main() {
 	cfg.Rand = rng
 	cfg.ObjectiveFunc = func(pos []float64) float64 {
 		objectiveCalls++
-		if expensiveEvals >= mayflyBudget {
+		if ctx.Err() != nil || expensiveEvals >= mayflyBudget {
 			return bestScore + 0.25
 		}
 		expensiveEvals++
 		cand := knobsFromNormalized(pos)
-		score, _, evalErr := evaluateKnobs(base, cand, notes, references, rs)
+		score, _, evalErr := evaluateKnobs(base, cand, cases, references, rs)
 		if evalErr != nil || !isFiniteFloat(score) {
+			log.Debug("eval failed", "eval", expensiveEvals, "score", score, "err", evalErr)
 			if expensiveEvals%progressEvery == 0 {
-				fmt.Printf("Progress eval=%d/%d score=%.4f\n", expensiveEvals, mayflyBudget, bestScore)
+				log.Info("progress", "eval", expensiveEvals, "max_evals", mayflyBudget, "score", bestScore)
 			}
 			return 10.0
 		}
+		log.Debug("eval", "eval", expensiveEvals, "score", score, "knobs", cand)
 		if score < bestScore {
 			best = cand
 			bestScore = score
-			fmt.Printf("Improved eval=%d/%d score=%.4f knobs=%+v\n", expensiveEvals, mayflyBudget, bestScore, best)
+			log.Info("improved", "eval", expensiveEvals, "max_evals", mayflyBudget, "score", bestScore, "knobs", best)
 		} else if expensiveEvals%progressEvery == 0 {
-			fmt.Printf("Progress eval=%d/%d score=%.4f\n", expensiveEvals, mayflyBudget, bestScore)
+			log.Info("progress", "eval", expensiveEvals, "max_evals", mayflyBudget, "score", bestScore)
 		}
 		return score
 	}
@@ -217,24 +277,38 @@ func main() {
 	if res != nil && res.FuncEvalCount > objectiveCalls {
 		objectiveCalls = res.FuncEvalCount
 	}
+	if ctx.Err() != nil {
+		log.Warn("interrupted: skipping refinement, writing the best knobs", "evals", expensiveEvals)
+	}
 	evals += expensiveEvals
-	fmt.Printf("Mayfly done variant=%s pop=%d iterations=%d evals=%d objective-calls=%d best=%.4f\n", variant, *mayflyPop, mayflyIters, expensiveEvals, objectiveCalls, bestScore)
+	log.Info("mayfly done", "variant", variant, "pop", *mayflyPop, "iterations", mayflyIters, "evals", expensiveEvals, "objective_calls", objectiveCalls, "best", bestScore)
 
 	// Lightweight coordinate refinement.
-	best, bestScore, refinedEvals := refineLocally(base, best, bestScore, notes, references, rs)
+	best, bestScore, refinedEvals := refineLocally(ctx, base, best, bestScore, cases, references, rs)
 	evals += refinedEvals
+	stopProfile()
 
 	// Final per-note metrics for report.
-	_, perNote, err := evaluateKnobs(base, best, notes, references, rs)
+	_, perNote, err := evaluateKnobs(base, best, cases, references, rs)
 	if err != nil {
 		die("final evaluation failed: %v", err)
 	}
 
 	outParams := cloneParams(base)
-	applyModalKnobs(outParams, best)
+	if err := applyModalKnobs(outParams, best); err != nil {
+		die("apply best knobs: %v", err)
+	}
 	outParams.StringModel = piano.StringModelModal
 
-	if err := writePreset(*outputPreset, outParams); err != nil {
+	sourceMeta, err := preset.LoadMeta(*basePreset)
+	if err != nil {
+		die("load preset meta: %v", err)
+	}
+	provenance, err := fitcommon.NewProvenance("piano-modal-fit", *seed, nil, sourceMeta)
+	if err != nil {
+		die("record provenance: %v", err)
+	}
+	if err := writePreset(*outputPreset, outParams, fitcommon.WithScore(provenance, bestScore)); err != nil {
 		die("write output preset: %v", err)
 	}
 
@@ -242,74 +316,84 @@ func main() {
 		*reportPath = *outputPreset + ".report.json"
 	}
 	report := calibrationReport{
-		ProfileVersion: "modal-calibration-v1",
-		TimestampUTC:   time.Now().UTC().Format(time.RFC3339),
-		BasePreset:     *basePreset,
-		OutputPreset:   *outputPreset,
-		SampleRate:     *sampleRate,
-		Velocity:       *velocity,
-		ReleaseAfter:   *releaseAfter,
-		Notes:          notes,
-		Evaluations:    evals,
-		BestScore:      bestScore,
-		BestKnobs:      best,
-		PerNote:        perNote,
-		ElapsedSec:     time.Since(start).Seconds(),
+		ProfileVersion:    "modal-calibration-v2",
+		TimestampUTC:      time.Now().UTC().Format(time.RFC3339),
+		BasePreset:        *basePreset,
+		OutputPreset:      *outputPreset,
+		SampleRate:        *sampleRate,
+		Velocities:        velocities,
+		PedalStates:       pedalNames(pedals),
+		ReleaseAfter:      *releaseAfter,
+		Notes:             notes,
+		LoudnessWeighting: *loudnessWeighting,
+		Evaluations:       evals,
+		BestScore:         bestScore,
+		BestKnobs:         best,
+		StateScores:       stateScores(perNote),
+		PerNote:           perNote,
+		ElapsedSec:        time.Since(start).Seconds(),
+		Interrupted:       ctx.Err() != nil,
 	}
 	if err := writeJSON(*reportPath, report); err != nil {
 		die("write report: %v", err)
 	}
 
-	fmt.Printf("Done evals=%d score=%.4f output=%s report=%s\n", evals, bestScore, *outputPreset, *reportPath)
+	log.Info("done", "evals", evals, "score", bestScore, "output", *outputPreset, "report", *reportPath, "interrupted", ctx.Err() != nil)
 }
 
-func evaluateKnobs(base *piano.Params, knobs knobSet, notes []int, refs map[int][]float64, rs renderSettings) (float64, []noteCalibration, error) {
+// evaluateKnobs renders every calibration case with the modal model and
+// returns the mean combined score against the DWG references, with the
+// metrics of each case.
+func evaluateKnobs(base *piano.Params, knobs knobSet, cases []calibrationCase, refs map[calibrationCase]noteReference, rs renderSettings) (float64, []noteCalibration, error) {
 	params := cloneParams(base)
-	applyModalKnobs(params, knobs)
+	if err := applyModalKnobs(params, knobs); err != nil {
+		return 0, nil, err
+	}
 	params.StringModel = piano.StringModelModal
 
 	total := 0.0
-	perNote := make([]noteCalibration, 0, len(notes))
-	for _, note := range notes {
-		ref := refs[note]
+	perNote := make([]noteCalibration, 0, len(cases))
+	for _, c := range cases {
+		ref := refs[c].mono
 		if len(ref) == 0 {
-			return 0, nil, fmt.Errorf("missing reference for note %d", note)
+			return 0, nil, fmt.Errorf("missing reference for %v", c)
 		}
 
-		rs.note = note
-		cand, err := renderNote(params, rs)
+		cand, err := renderNote(params, c.settings(rs))
 		if err != nil {
-			return 0, nil, fmt.Errorf("render modal note %d: %w", note, err)
+			return 0, nil, fmt.Errorf("render modal %v: %w", c, err)
 		}
 
+		endMetrics := fitcommon.StartStage("metrics")
 		full := sanitizeMetrics(analysis.Compare(ref, cand, rs.sampleRate))
 		attack := compareWindow(ref, cand, rs.sampleRate, matchWindows[0])
 		early := compareWindow(ref, cand, rs.sampleRate, matchWindows[1])
 		decay := compareWindow(ref, cand, rs.sampleRate, matchWindows[2])
-		windowed := weightedScore(
-			[]analysis.Metrics{attack, early, decay},
-			[]float64{matchWindows[0].weight, matchWindows[1].weight, matchWindows[2].weight},
-		)
+		windowed := weightedScore([]analysis.Metrics{attack, early, decay}, refs[c].weights())
 		combined := 0.65*windowed + 0.35*full.Score
 		if !isFiniteFloat(combined) {
 			combined = 1.0
 		}
+		endMetrics()
 
 		total += combined
 		perNote = append(perNote, noteCalibration{
-			Note:          note,
+			Note:          c.Note,
+			Velocity:      c.Velocity,
+			PedalDown:     c.PedalDown,
 			Full:          full,
 			Attack:        attack,
 			EarlySustain:  early,
 			Decay:         decay,
 			WindowedScore: windowed,
 			CombinedScore: combined,
+			Windows:       refs[c].windows,
 		})
 	}
-	if len(notes) == 0 {
+	if len(cases) == 0 {
 		return 0, perNote, nil
 	}
-	score := total / float64(len(notes))
+	score := total / float64(len(cases))
 	if !isFiniteFloat(score) {
 		score = 1.0
 	}
@@ -362,7 +446,7 @@ func weightedScore(metrics []analysis.Metrics, weights []float64) float64 {
 	return out
 }
 
-func refineLocally(base *piano.Params, start knobSet, startScore float64, notes []int, refs map[int][]float64, rs renderSettings) (knobSet, float64, int) {
+func refineLocally(ctx context.Context, base *piano.Params, start knobSet, startScore float64, cases []calibrationCase, refs map[calibrationCase]noteReference, rs renderSettings) (knobSet, float64, int) {
 	best := start
 	bestScore := startScore
 	evals := 0
@@ -375,7 +459,10 @@ func refineLocally(base *piano.Params, start knobSet, startScore float64, notes
 
 	for round := 0; round < 4; round++ {
 		try := func(next knobSet) {
-			score, _, err := evaluateKnobs(base, next, notes, refs, rs)
+			if ctx.Err() != nil {
+				return
+			}
+			score, _, err := evaluateKnobs(base, next, cases, refs, rs)
 			if err != nil {
 				return
 			}
@@ -542,68 +629,66 @@ func normalizeUnit(v float64, lo float64, hi float64) float64 {
 	return clamp((v-lo)/(hi-lo), 0, 1)
 }
 
-func applyModalKnobs(p *piano.Params, k knobSet) {
+// applyModalKnobs sets the modal Params of k through the shared fit knob
+// table, keyed by the knobSet JSON names.
+func applyModalKnobs(p *piano.Params, k knobSet) error {
 	if p == nil {
-		return
+		return nil
 	}
 	k = normalizeKnobs(k)
-	p.ModalPartials = k.ModalPartials
-	p.ModalGainExponent = float32(k.ModalGainExponent)
-	p.ModalExcitation = float32(k.ModalExcitation)
-	p.ModalUndampedLoss = float32(k.ModalUndampedLoss)
-	p.ModalDampedLoss = float32(k.ModalDampedLoss)
+	return fitknobs.SetParamKnobs(p, map[string]float64{
+		"modal_partials":      float64(k.ModalPartials),
+		"modal_gain_exponent": k.ModalGainExponent,
+		"modal_excitation":    k.ModalExcitation,
+		"modal_undamped_loss": k.ModalUndampedLoss,
+		"modal_damped_loss":   k.ModalDampedLoss,
+	})
 }
 
 func renderNote(params *piano.Params, rs renderSettings) ([]float64, error) {
 	if params == nil {
 		return nil, errors.New("nil params")
 	}
+	endSetup := fitcommon.StartStage("setup")
 	p := piano.NewPiano(rs.sampleRate, 16, params)
+	endSetup()
+	defer fitcommon.StartStage("render")()
+	if rs.pedal {
+		p.SetSustainPedal(true)
+	}
 	p.NoteOn(rs.note, rs.velocity)
 
-	minFrames := int(float64(rs.sampleRate) * rs.minDurationSec)
-	maxFrames := int(float64(rs.sampleRate) * rs.maxDurationSec)
+	stop, err := render.NewAutoStopper(render.AutoStopConfig{
+		SampleRate:  rs.sampleRate,
+		DecayDBFS:   rs.decayDBFS,
+		HoldBlocks:  rs.decayHold,
+		MinDuration: rs.minDurationSec,
+		MaxDuration: rs.maxDurationSec,
+	})
+	if err != nil {
+		return nil, err
+	}
 	releaseFrame := int(float64(rs.sampleRate) * rs.releaseAfter)
 	if releaseFrame < 0 {
 		releaseFrame = 0
 	}
-	if maxFrames < 1 {
-		return nil, errors.New("invalid max duration")
-	}
 
-	threshold := math.Pow(10.0, rs.decayDBFS/20.0)
-	frames := 0
-	belowCount := 0
+	block := rs.blockSize
+	if block < 16 {
+		block = 16
+	}
 	noteReleased := false
-	stereo := make([]float32, 0, maxFrames*2)
+	stereo := make([]float32, 0, stop.MaxFrames()*2)
 
-	for frames < maxFrames {
-		block := rs.blockSize
-		if block < 16 {
-			block = 16
-		}
-		if frames+block > maxFrames {
-			block = maxFrames - frames
-		}
-		if !noteReleased && frames >= releaseFrame {
+	for !stop.Done() {
+		if !noteReleased && stop.Rendered() >= releaseFrame {
 			p.NoteOff(rs.note)
 			noteReleased = true
 		}
 
-		out := p.Process(block)
+		out := p.Process(stop.NextBlock(block))
 		stereo = append(stereo, out...)
-		frames += block
-
-		if frames >= minFrames {
-			if stereoRMS(out) < threshold {
-				belowCount++
-				if belowCount >= rs.decayHold {
-					break
-				}
-			} else {
-				belowCount = 0
-			}
-		}
+		stop.Observe(out, p.ActiveVoices())
 	}
 
 	mono := make([]float64, len(stereo)/2)
@@ -619,18 +704,6 @@ func renderNote(params *piano.Params, rs renderSettings) ([]float64, error) {
 	return mono, nil
 }
 
-func stereoRMS(interleaved []float32) float64 {
-	if len(interleaved) == 0 {
-		return 0
-	}
-	var sum float64
-	for _, s := range interleaved {
-		v := float64(s)
-		sum += v * v
-	}
-	return math.Sqrt(sum / float64(len(interleaved)))
-}
-
 func parseNotes(raw string) ([]int, error) {
 	parts := strings.Split(raw, ",")
 	notes := make([]int, 0, len(parts))
@@ -673,18 +746,44 @@ func cloneParams(src *piano.Params) *piano.Params {
 		nv := *v
 		d.PerNote[k] = &nv
 	}
+	d.OutputEQ = append([]piano.EQBand(nil), src.OutputEQ...)
+	d.HighFreqDampingCurve = append(piano.RegisterCurve(nil), src.HighFreqDampingCurve...)
+	d.LossCurve = append(piano.RegisterCurve(nil), src.LossCurve...)
+	d.DamperCurve = append(piano.RegisterCurve(nil), src.DamperCurve...)
 	return &d
 }
 
-func writePreset(path string, p *piano.Params) error {
+func writePreset(path string, p *piano.Params, meta *fitcommon.Provenance) error {
 	if p == nil {
 		return errors.New("nil params")
 	}
+	type prepEntry struct {
+		Type      string  `json:"type"`
+		Amount    float32 `json:"amount"`
+		Harmonic  int     `json:"harmonic,omitempty"`
+		Threshold float32 `json:"threshold,omitempty"`
+	}
 	type noteEntry struct {
-		F0             float32 `json:"f0,omitempty"`
-		Inharmonicity  float32 `json:"inharmonicity,omitempty"`
-		Loss           float32 `json:"loss,omitempty"`
-		StrikePosition float32 `json:"strike_position,omitempty"`
+		F0             float32     `json:"f0,omitempty"`
+		Inharmonicity  float32     `json:"inharmonicity,omitempty"`
+		Loss           float32     `json:"loss,omitempty"`
+		StrikePosition float32     `json:"strike_position,omitempty"`
+		Preparations   []prepEntry `json:"preparations,omitempty"`
+		UnisonDetunes  []float32   `json:"unison_detunes_cents,omitempty"`
+		UnisonGains    []float32   `json:"unison_gains,omitempty"`
+
+		HammerStiffnessScale   float32 `json:"hammer_stiffness_scale,omitempty"`
+		HammerExponentScale    float32 `json:"hammer_exponent_scale,omitempty"`
+		HammerContactTimeScale float32 `json:"hammer_contact_time_scale,omitempty"`
+	}
+	type unisonRegister struct {
+		BelowNote int       `json:"below_note"`
+		Detunes   []float32 `json:"detunes_cents"`
+		Gains     []float32 `json:"gains,omitempty"`
+	}
+	type registerPoint struct {
+		Note  int     `json:"note"`
+		Value float32 `json:"value"`
 	}
 	type out struct {
 		OutputGain                 float32              `json:"output_gain"`
@@ -732,21 +831,41 @@ func writePreset(path string, p *piano.Params) error {
 		AttackNoiseLevel           float32              `json:"attack_noise_level,omitempty"`
 		AttackNoiseDurationMs      float32              `json:"attack_noise_duration_ms,omitempty"`
 		AttackNoiseColor           float32              `json:"attack_noise_color,omitempty"`
+		HammerKnockLevel           float32              `json:"hammer_knock_level,omitempty"`
+		HammerKnockAttackMs        float32              `json:"hammer_knock_attack_ms,omitempty"`
+		HammerKnockDecayMs         float32              `json:"hammer_knock_decay_ms,omitempty"`
+		HammerKnockBandsDB         []float32            `json:"hammer_knock_bands_db,omitempty"`
+		HighFreqDampingCurve       []registerPoint      `json:"high_freq_damping_curve,omitempty"`
+		LossCurve                  []registerPoint      `json:"loss_curve,omitempty"`
+		DamperReflection           float32              `json:"damper_reflection,omitempty"`
+		DamperEngageMs             float32              `json:"damper_engage_ms"`
+		DamperEngageRegisterSlope  float32              `json:"damper_engage_register_slope"`
+		DamperVelocitySensitivity  float32              `json:"damper_velocity_sensitivity"`
+		DamperReleaseMs            float32              `json:"damper_release_ms,omitempty"`
+		DamperCurve                []registerPoint      `json:"damper_curve,omitempty"`
+		DamperlessFromNote         int                  `json:"damperless_from_note"`
+		UnisonRegisters            []unisonRegister     `json:"unison_registers,omitempty"`
+		SoundboardNonlinearity     float32              `json:"soundboard_nonlinearity,omitempty"`
+		SoundboardStressLevel      float32              `json:"soundboard_stress_level,omitempty"`
+		BassMonoHz                 float32              `json:"bass_mono_hz,omitempty"`
 		PerNote                    map[string]noteEntry `json:"per_note,omitempty"`
+
+		// Meta records the run that produced the preset.
+		Meta *fitcommon.Provenance `json:"meta,omitempty"`
 	}
 
 	o := out{
 		OutputGain:                 p.OutputGain,
 		MinNote:                    p.MinNote,
 		MaxNote:                    p.MaxNote,
-		IRWavPath:                  p.IRWavPath,
+		IRWavPath:                  preset.RelativeIRPath(path, p.IRWavPath),
 		IRWetMix:                   p.IRWetMix,
 		IRDryMix:                   p.IRDryMix,
 		IRGain:                     p.IRGain,
-		BodyIRWavPath:              p.BodyIRWavPath,
+		BodyIRWavPath:              preset.RelativeIRPath(path, p.BodyIRWavPath),
 		BodyIRGain:                 p.BodyIRGain,
 		BodyDryMix:                 p.BodyDryMix,
-		RoomIRWavPath:              p.RoomIRWavPath,
+		RoomIRWavPath:              preset.RelativeIRPath(path, p.RoomIRWavPath),
 		RoomWetMix:                 p.RoomWetMix,
 		RoomGain:                   p.RoomGain,
 		ResonanceEnabled:           p.ResonanceEnabled,
@@ -758,6 +877,12 @@ func writePreset(path string, p *piano.Params) error {
 		HammerInitialVelocityScale: p.HammerInitialVelocityScale,
 		HammerContactTimeScale:     p.HammerContactTimeScale,
 		HighFreqDamping:            p.HighFreqDamping,
+		DamperReflection:           p.DamperReflection,
+		DamperEngageMs:             p.DamperEngageMs,
+		DamperEngageRegisterSlope:  p.DamperEngageRegisterSlope,
+		DamperVelocitySensitivity:  p.DamperVelocitySensitivity,
+		DamperReleaseMs:            p.DamperReleaseMs,
+		DamperlessFromNote:         p.DamperlessFromNote,
 		UnisonDetuneScale:          p.UnisonDetuneScale,
 		UnisonCrossfeed:            p.UnisonCrossfeed,
 		StringModel:                string(p.StringModel),
@@ -781,18 +906,55 @@ func writePreset(path string, p *piano.Params) error {
 		AttackNoiseLevel:           p.AttackNoiseLevel,
 		AttackNoiseDurationMs:      p.AttackNoiseDurationMs,
 		AttackNoiseColor:           p.AttackNoiseColor,
+		HammerKnockLevel:           p.HammerKnockLevel,
+		HammerKnockAttackMs:        p.HammerKnockAttackMs,
+		HammerKnockDecayMs:         p.HammerKnockDecayMs,
+		SoundboardNonlinearity:     p.SoundboardNonlinearity,
+		SoundboardStressLevel:      p.SoundboardStressLevel,
+		BassMonoHz:                 p.BassMonoHz,
 		PerNote:                    map[string]noteEntry{},
+		Meta:                       meta,
+	}
+	for _, pt := range p.HighFreqDampingCurve {
+		o.HighFreqDampingCurve = append(o.HighFreqDampingCurve, registerPoint{Note: pt.Note, Value: pt.Value})
+	}
+	for _, pt := range p.LossCurve {
+		o.LossCurve = append(o.LossCurve, registerPoint{Note: pt.Note, Value: pt.Value})
+	}
+	for _, pt := range p.DamperCurve {
+		o.DamperCurve = append(o.DamperCurve, registerPoint{Note: pt.Note, Value: pt.Value})
+	}
+	for _, r := range p.UnisonRegisters {
+		o.UnisonRegisters = append(o.UnisonRegisters, unisonRegister{BelowNote: r.BelowNote, Detunes: r.Detunes, Gains: r.Gains})
+	}
+	if p.HammerKnockBandsDB != ([piano.HammerKnockBands]float32{}) {
+		o.HammerKnockBandsDB = p.HammerKnockBandsDB[:]
 	}
 	for note, np := range p.PerNote {
 		if np == nil {
 			continue
 		}
-		o.PerNote[strconv.Itoa(note)] = noteEntry{
+		entry := noteEntry{
 			F0:             np.F0,
 			Inharmonicity:  np.Inharmonicity,
 			Loss:           np.Loss,
 			StrikePosition: np.StrikePosition,
+			UnisonDetunes:  np.UnisonDetunes,
+			UnisonGains:    np.UnisonGains,
+
+			HammerStiffnessScale:   np.HammerStiffnessScale,
+			HammerExponentScale:    np.HammerExponentScale,
+			HammerContactTimeScale: np.HammerContactTimeScale,
+		}
+		for _, prep := range np.Preparations {
+			entry.Preparations = append(entry.Preparations, prepEntry{
+				Type:      string(prep.Type),
+				Amount:    prep.Amount,
+				Harmonic:  prep.Harmonic,
+				Threshold: prep.Threshold,
+			})
 		}
+		o.PerNote[strconv.Itoa(note)] = entry
 	}
 	return writeJSON(path, o)
 }
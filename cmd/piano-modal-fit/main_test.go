@@ -2,9 +2,12 @@ package main
 
 import (
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitknobs"
+	"github.com/cwbudde/algo-piano/piano"
 )
 
 func TestSanitizeMetricsReplacesNonFiniteValues(t *testing.T) {
@@ -101,3 +104,147 @@ func TestNewMayflyConfigVariantValidation(t *testing.T) {
 		t.Fatalf("population mismatch: male=%d female=%d", cfg.NPop, cfg.NPopF)
 	}
 }
+
+func TestKnobSetNamesAreParamKnobs(t *testing.T) {
+	typ := reflect.TypeFor[knobSet]()
+	for i := range typ.NumField() {
+		name := typ.Field(i).Tag.Get("json")
+		if _, _, ok := fitknobs.LookupParamKnob(name); !ok {
+			t.Errorf("knob %s is not in the fit knob table", name)
+		}
+	}
+	p := piano.NewDefaultParams()
+	if err := applyModalKnobs(p, knobSet{ModalPartials: 12, ModalGainExponent: 1.1, ModalExcitation: 0.9, ModalUndampedLoss: 0.5, ModalDampedLoss: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if p.ModalPartials != 12 || p.ModalDampedLoss != 2 {
+		t.Fatalf("applied partials %d, damped loss %g", p.ModalPartials, p.ModalDampedLoss)
+	}
+}
+
+func TestReferenceWindowWeightsFollowLoudness(t *testing.T) {
+	const sr = 48000
+	// A tone that falls 20 dB from the first window to the second and is
+	// silent in the third.
+	ref := make([]float64, sr)
+	for i := range ref {
+		amp := 0.5
+		switch {
+		case i >= sr/2:
+			amp = 0
+		case i >= sr/4:
+			amp = 0.05
+		}
+		ref[i] = amp * math.Sin(2*math.Pi*440*float64(i)/sr)
+	}
+	windows := []windowSpec{
+		{name: "loud", startS: 0, endS: 0.25, weight: 0.2},
+		{name: "quiet", startS: 0.25, endS: 0.5, weight: 0.5},
+		{name: "silent", startS: 0.5, endS: 1, weight: 0.3},
+	}
+	got := referenceWindowWeights(ref, sr, windows, true)
+	if got[0].Weight != 0.2 || got[0].RelativeLU != 0 {
+		t.Fatalf("loudest window %+v keeps its base weight", got[0])
+	}
+	if math.Abs(got[1].RelativeLU+20) > 0.5 || math.Abs(got[1].Factor-0.25) > 0.02 {
+		t.Fatalf("window 20 LU down: %+v, want factor 1/4", got[1])
+	}
+	if got[2].LoudnessLUFS != silentLUFS || got[2].Weight > 1e-9 {
+		t.Fatalf("silent window %+v still weighs in", got[2])
+	}
+
+	// A silent late window no longer dominates the windowed score.
+	ref0 := noteReference{windows: got}
+	metrics := []analysis.Metrics{{Score: 0.1}, {Score: 0.1}, {Score: 1}}
+	if s := weightedScore(metrics, ref0.weights()); s > 0.11 {
+		t.Fatalf("windowed score %g, want the silent window's 1.0 ignored", s)
+	}
+
+	off := referenceWindowWeights(ref, sr, windows, false)
+	for i, w := range off {
+		if w.Weight != windows[i].weight || w.Factor != 1 {
+			t.Fatalf("unweighted window %+v", w)
+		}
+	}
+}
+
+func TestCalibrationCasesCoverVelocitiesAndPedal(t *testing.T) {
+	velocities, err := parseVelocities("40, 118,40")
+	if err != nil || !reflect.DeepEqual(velocities, []int{40, 118}) {
+		t.Fatalf("velocities %v, err %v", velocities, err)
+	}
+	pedals, err := parsePedalStates("up,DOWN")
+	if err != nil || !reflect.DeepEqual(pedals, []bool{false, true}) {
+		t.Fatalf("pedal states %v, err %v", pedals, err)
+	}
+	for _, bad := range []string{"", "0", "128", "loud"} {
+		if _, err := parseVelocities(bad); err == nil {
+			t.Errorf("velocities %q accepted", bad)
+		}
+	}
+	for _, bad := range []string{"", "half"} {
+		if _, err := parsePedalStates(bad); err == nil {
+			t.Errorf("pedal states %q accepted", bad)
+		}
+	}
+
+	cases := calibrationCases([]int{48, 60}, velocities, pedals)
+	if len(cases) != 8 || cases[3] != (calibrationCase{Note: 48, Velocity: 118, PedalDown: true}) {
+		t.Fatalf("cases %v", cases)
+	}
+	rs := cases[3].settings(renderSettings{note: 1, velocity: 1, sampleRate: 48000})
+	if rs.note != 48 || rs.velocity != 118 || !rs.pedal || rs.sampleRate != 48000 {
+		t.Fatalf("settings %+v", rs)
+	}
+
+	scores := stateScores([]noteCalibration{
+		{Note: 48, Velocity: 40, CombinedScore: 0.2},
+		{Note: 48, Velocity: 40, PedalDown: true, CombinedScore: 0.6},
+		{Note: 60, Velocity: 40, CombinedScore: 0.4},
+		{Note: 60, Velocity: 40, PedalDown: true, CombinedScore: 0.8},
+	})
+	want := []stateScore{{Velocity: 40, Score: 0.3}, {Velocity: 40, PedalDown: true, Score: 0.7}}
+	if len(scores) != len(want) {
+		t.Fatalf("state scores %v, want %v", scores, want)
+	}
+	for i, s := range scores {
+		if s.Velocity != want[i].Velocity || s.PedalDown != want[i].PedalDown || math.Abs(s.Score-want[i].Score) > 1e-12 {
+			t.Fatalf("state scores %v, want %v", scores, want)
+		}
+	}
+}
+
+func TestRenderNoteHoldsPedalThroughRelease(t *testing.T) {
+	params := piano.NewDefaultParams()
+	params.StringModel = piano.StringModelModal
+	rs := renderSettings{
+		note:           60,
+		velocity:       100,
+		sampleRate:     16000,
+		decayDBFS:      -200,
+		decayHold:      1,
+		minDurationSec: 1,
+		maxDurationSec: 1,
+		blockSize:      128,
+		releaseAfter:   0.2,
+	}
+	tailEnergy := func(mono []float64) float64 {
+		e := 0.0
+		for _, v := range mono[len(mono)/2:] {
+			e += v * v
+		}
+		return e
+	}
+	up, err := renderNote(params, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.pedal = true
+	down, err := renderNote(params, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eu, ed := tailEnergy(up), tailEnergy(down); ed < 4*eu {
+		t.Fatalf("tail energy after release %g with pedal down, %g up; want the pedal to keep the note ringing", ed, eu)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// stringModels are the string cores a comparison can render. A new core
+// joins the comparison by adding it here.
+var stringModels = []piano.StringModel{piano.StringModelDWG, piano.StringModelModal}
+
+// renderConfig is the fixed render every model gets, so timings and
+// metrics compare like with like.
+type renderConfig struct {
+	sampleRate   int
+	polyphony    int
+	blockSize    int
+	velocity     int
+	durationSec  float64
+	releaseAfter float64
+	repeats      int // timed renders per note; the fastest counts
+}
+
+// noteCost is the CPU cost of rendering one note.
+type noteCost struct {
+	Note           int     `json:"note"`
+	SetupMs        float64 `json:"setup_ms"`
+	RenderMs       float64 `json:"render_ms"`
+	RealtimeFactor float64 `json:"realtime_factor"` // audio seconds per render second
+}
+
+// modelCost is the CPU cost of a model over the note battery.
+type modelCost struct {
+	Model          piano.StringModel `json:"model"`
+	SetupMs        float64           `json:"setup_ms"` // mean engine construction time per note
+	AudioSec       float64           `json:"audio_seconds"`
+	RenderSec      float64           `json:"render_seconds"`
+	RealtimeFactor float64           `json:"realtime_factor"`
+	CPUPercent     float64           `json:"cpu_percent"`           // of one core for a single sounding note
+	ProjectedCPU   float64           `json:"projected_cpu_percent"` // CPUPercent on the target platform
+	FitsBudget     *bool             `json:"fits_budget,omitempty"`
+	PerNote        []noteCost        `json:"per_note"`
+}
+
+// noteMetrics is how close one note of the candidate model comes to the
+// reference model.
+type noteMetrics struct {
+	Note            int     `json:"note"`
+	Score           float64 `json:"score"`
+	Similarity      float64 `json:"similarity"`
+	SpectralRMSEDB  float64 `json:"spectral_rmse_db"`
+	EnvelopeRMSEDB  float64 `json:"envelope_rmse_db"`
+	DecayDiffDBPerS float64 `json:"decay_diff_db_per_s"`
+}
+
+// pairMetrics compares a candidate model with a reference model over the
+// note battery; lower scores are closer.
+type pairMetrics struct {
+	Reference      piano.StringModel `json:"reference"`
+	Candidate      piano.StringModel `json:"candidate"`
+	MeanScore      float64           `json:"mean_score"`
+	MeanSimilarity float64           `json:"mean_similarity"`
+	WorstNote      int               `json:"worst_note"`
+	WorstScore     float64           `json:"worst_score"`
+	PerNote        []noteMetrics     `json:"per_note"`
+}
+
+// parseModels parses a comma-separated list of string models, dropping
+// duplicates. At least two are needed for a comparison.
+func parseModels(raw string) ([]piano.StringModel, error) {
+	var out []piano.StringModel
+	for _, part := range strings.Split(raw, ",") {
+		m := piano.StringModel(strings.ToLower(strings.TrimSpace(part)))
+		if m == "" {
+			continue
+		}
+		if !slices.Contains(stringModels, m) {
+			return nil, fmt.Errorf("unknown string model %q (available: %s)", m, modelList())
+		}
+		if !slices.Contains(out, m) {
+			out = append(out, m)
+		}
+	}
+	if len(out) < 2 {
+		return nil, errors.New("need at least two string models")
+	}
+	return out, nil
+}
+
+// parseNotes parses a comma-separated list of MIDI notes, dropping
+// duplicates.
+func parseNotes(raw string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid note %q", part)
+		}
+		if n < 0 || n > 127 {
+			return nil, fmt.Errorf("note out of range [0,127]: %d", n)
+		}
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("empty notes list")
+	}
+	return out, nil
+}
+
+func modelList() string {
+	names := make([]string, len(stringModels))
+	for i, m := range stringModels {
+		names[i] = string(m)
+	}
+	return strings.Join(names, ", ")
+}
+
+// modelParams returns a copy of base that rings every note on model,
+// dropping per-note string model overrides.
+func modelParams(base *piano.Params, model piano.StringModel) *piano.Params {
+	p := *base
+	p.StringModel = model
+	p.PerNote = make(map[int]*piano.NoteParams, len(base.PerNote))
+	for note, np := range base.PerNote {
+		if np == nil {
+			continue
+		}
+		c := *np
+		c.StringModel = ""
+		p.PerNote[note] = &c
+	}
+	return &p
+}
+
+// renderNote renders note for rc.durationSec, releasing it after
+// rc.releaseAfter, and returns the mono mix with the fastest of rc.repeats
+// timings. Only engine construction and Process calls are timed.
+func renderNote(params *piano.Params, note int, rc renderConfig) ([]float64, noteCost) {
+	frames := int(rc.durationSec * float64(rc.sampleRate))
+	releaseAt := int(rc.releaseAfter * float64(rc.sampleRate))
+	cost := noteCost{Note: note, SetupMs: math.Inf(1), RenderMs: math.Inf(1)}
+	var mono []float64
+	for range max(rc.repeats, 1) {
+		start := time.Now()
+		p := piano.NewPiano(rc.sampleRate, rc.polyphony, params)
+		setup := time.Since(start)
+
+		out := make([]float32, 0, 2*frames)
+		var render time.Duration
+		p.NoteOn(note, rc.velocity)
+		for done := 0; done < frames; done += rc.blockSize {
+			if done <= releaseAt && releaseAt < done+rc.blockSize {
+				p.NoteOff(note)
+			}
+			start := time.Now()
+			block := p.Process(min(rc.blockSize, frames-done))
+			render += time.Since(start)
+			out = append(out, block...)
+		}
+		cost.SetupMs = math.Min(cost.SetupMs, durationMs(setup))
+		cost.RenderMs = math.Min(cost.RenderMs, durationMs(render))
+		if mono == nil {
+			mono = fitcommon.StereoToMono64(out)
+		}
+	}
+	if cost.RenderMs > 0 {
+		cost.RealtimeFactor = 1000 * rc.durationSec / cost.RenderMs
+	}
+	return mono, cost
+}
+
+// summarizeCost totals the per-note costs of a model. scale is how many
+// times slower the target platform is than this machine, and budget the
+// CPU percent it allows a note (0 = no budget).
+func summarizeCost(model piano.StringModel, perNote []noteCost, durationSec, scale, budget float64) modelCost {
+	c := modelCost{Model: model, PerNote: perNote}
+	for _, n := range perNote {
+		c.SetupMs += n.SetupMs
+		c.RenderSec += n.RenderMs / 1000
+		c.AudioSec += durationSec
+	}
+	if len(perNote) > 0 {
+		c.SetupMs /= float64(len(perNote))
+	}
+	if c.AudioSec > 0 {
+		c.CPUPercent = 100 * c.RenderSec / c.AudioSec
+	}
+	if c.RenderSec > 0 {
+		c.RealtimeFactor = c.AudioSec / c.RenderSec
+	}
+	c.ProjectedCPU = c.CPUPercent * scale
+	if budget > 0 {
+		fits := c.ProjectedCPU <= budget
+		c.FitsBudget = &fits
+	}
+	return c
+}
+
+// comparePair meters cand against ref note by note. Both map notes to the
+// mono renders of one model.
+func comparePair(refModel, candModel piano.StringModel, notes []int, ref, cand map[int][]float64, sampleRate int) pairMetrics {
+	pm := pairMetrics{Reference: refModel, Candidate: candModel, WorstScore: math.Inf(-1)}
+	for _, note := range notes {
+		m := analysis.Compare(ref[note], cand[note], sampleRate)
+		nm := noteMetrics{
+			Note:            note,
+			Score:           finiteOr(m.Score, 1),
+			Similarity:      finiteOr(m.Similarity, 0),
+			SpectralRMSEDB:  finiteOr(m.SpectralRMSEDB, 0),
+			EnvelopeRMSEDB:  finiteOr(m.EnvelopeRMSEDB, 0),
+			DecayDiffDBPerS: finiteOr(m.DecayDiffDBPerS, 0),
+		}
+		pm.PerNote = append(pm.PerNote, nm)
+		pm.MeanScore += nm.Score
+		pm.MeanSimilarity += nm.Similarity
+		if nm.Score > pm.WorstScore {
+			pm.WorstNote, pm.WorstScore = note, nm.Score
+		}
+	}
+	if n := float64(len(pm.PerNote)); n > 0 {
+		pm.MeanScore /= n
+		pm.MeanSimilarity /= n
+	} else {
+		pm.WorstScore = 0
+	}
+	return pm
+}
+
+func finiteOr(v, fallback float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fallback
+	}
+	return v
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// recommend returns the model closest to the reference model (the first
+// one) among those that fit the CPU budget, or "" if none does or no
+// budget was set.
+func recommend(costs []modelCost, pairs []pairMetrics) piano.StringModel {
+	if len(costs) == 0 {
+		return ""
+	}
+	reference := costs[0].Model
+	best, bestScore := piano.StringModel(""), math.Inf(1)
+	for _, c := range costs {
+		if c.FitsBudget == nil || !*c.FitsBudget {
+			continue
+		}
+		score := 0.0
+		if c.Model != reference {
+			score = math.Inf(1)
+			for _, p := range pairs {
+				if p.Reference == reference && p.Candidate == c.Model {
+					score = p.MeanScore
+				}
+			}
+		}
+		if score < bestScore {
+			best, bestScore = c.Model, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestParseModels(t *testing.T) {
+	models, err := parseModels(" Modal,dwg,modal")
+	if err != nil || !reflect.DeepEqual(models, []piano.StringModel{piano.StringModelModal, piano.StringModelDWG}) {
+		t.Fatalf("models %v, err %v", models, err)
+	}
+	for _, bad := range []string{"", "dwg", "dwg,dwg", "dwg,hybrid"} {
+		if _, err := parseModels(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestModelParamsDropsPerNoteOverrides(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.PerNote[60] = &piano.NoteParams{StringModel: piano.StringModelModal, Loss: 0.5}
+	p := modelParams(base, piano.StringModelDWG)
+	if p.StringModel != piano.StringModelDWG || p.PerNote[60].StringModel != "" || p.PerNote[60].Loss != 0.5 {
+		t.Fatalf("model %s, note 60 %+v", p.StringModel, *p.PerNote[60])
+	}
+	if base.PerNote[60].StringModel != piano.StringModelModal {
+		t.Fatal("the base preset was modified")
+	}
+}
+
+func TestRenderAndCompareModels(t *testing.T) {
+	rc := renderConfig{sampleRate: 16000, polyphony: 4, blockSize: 128, velocity: 100, durationSec: 0.5, releaseAfter: 0.3, repeats: 2}
+	base := piano.NewDefaultParams()
+	renders := map[piano.StringModel]map[int][]float64{}
+	var costs []modelCost
+	for _, m := range stringModels {
+		renders[m] = map[int][]float64{}
+		mono, cost := renderNote(modelParams(base, m), 60, rc)
+		if len(mono) != 8000 || cost.RenderMs <= 0 || math.IsInf(cost.SetupMs, 0) {
+			t.Fatalf("%s: %d frames, cost %+v", m, len(mono), cost)
+		}
+		renders[m][60] = mono
+		costs = append(costs, summarizeCost(m, []noteCost{cost}, rc.durationSec, 1, 1e6))
+	}
+
+	self := comparePair(piano.StringModelDWG, piano.StringModelDWG, []int{60}, renders[piano.StringModelDWG], renders[piano.StringModelDWG], rc.sampleRate)
+	other := comparePair(piano.StringModelDWG, piano.StringModelModal, []int{60}, renders[piano.StringModelDWG], renders[piano.StringModelModal], rc.sampleRate)
+	if self.MeanScore >= other.MeanScore || other.WorstNote != 60 {
+		t.Fatalf("dwg scores %g against itself and modal %g against it", self.MeanScore, other.MeanScore)
+	}
+	if got := recommend(costs, []pairMetrics{other}); got != piano.StringModelDWG {
+		t.Fatalf("recommended %q with an unlimited budget, want the reference model", got)
+	}
+}
+
+func TestSummarizeCostAndRecommend(t *testing.T) {
+	dwg := summarizeCost(piano.StringModelDWG, []noteCost{{Note: 48, RenderMs: 300}, {Note: 60, RenderMs: 100}}, 2, 3, 25)
+	if dwg.CPUPercent != 10 || dwg.RealtimeFactor != 10 || dwg.ProjectedCPU != 30 || *dwg.FitsBudget {
+		t.Fatalf("dwg cost %+v", dwg)
+	}
+	modal := summarizeCost(piano.StringModelModal, []noteCost{{Note: 48, RenderMs: 100}, {Note: 60, RenderMs: 100}}, 2, 3, 25)
+	if modal.ProjectedCPU != 15 || !*modal.FitsBudget {
+		t.Fatalf("modal cost %+v", modal)
+	}
+	pairs := []pairMetrics{{Reference: piano.StringModelDWG, Candidate: piano.StringModelModal, MeanScore: 0.3}}
+	if got := recommend([]modelCost{dwg, modal}, pairs); got != piano.StringModelModal {
+		t.Fatalf("recommended %q, want the model that fits", got)
+	}
+	if got := recommend([]modelCost{summarizeCost(piano.StringModelDWG, dwg.PerNote, 2, 3, 0), modal}, nil); got != "" {
+		t.Fatalf("recommended %q without pair metrics for the fitting model", got)
+	}
+}
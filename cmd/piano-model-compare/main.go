@@ -0,0 +1,214 @@
+// Command piano-model-compare renders one battery of notes with each string
+// model (dwg, modal) from the same preset, meters every pair of models
+// against each other with analysis.Compare and times the renders, and
+// prints a comparison table to guide the choice of model for a target
+// platform: how close the cheaper models come to the first one, and what
+// each costs in CPU.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+// compareReport is the JSON report of a run.
+type compareReport struct {
+	Timestamp     string            `json:"timestamp"`
+	GoVersion     string            `json:"go_version"`
+	GOOS          string            `json:"goos"`
+	GOARCH        string            `json:"goarch"`
+	CPUs          int               `json:"cpus"`
+	Preset        string            `json:"preset"`
+	SampleRate    int               `json:"sample_rate"`
+	BlockSize     int               `json:"block_size"`
+	Velocity      int               `json:"velocity"`
+	DurationSec   float64           `json:"duration_seconds"`
+	ReleaseAfter  float64           `json:"release_after_seconds"`
+	Notes         []int             `json:"notes"`
+	StringsOnly   bool              `json:"strings_only,omitempty"`
+	PlatformScale float64           `json:"platform_scale"`
+	CPUBudget     float64           `json:"cpu_budget_percent,omitempty"`
+	Models        []modelCost       `json:"models"`
+	Pairs         []pairMetrics     `json:"pairs"`
+	Recommended   piano.StringModel `json:"recommended,omitempty"` // closest to the first model within the budget
+}
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file path")
+	modelsRaw := flag.String("models", "dwg,modal", "Comma-separated string models to compare; the first is the reference the others are metered against ("+modelList()+")")
+	notesRaw := flag.String("notes", "36,48,60,72,84", "Comma-separated MIDI notes of the battery")
+	velocity := flag.Int("velocity", 100, "MIDI velocity of every note")
+	sampleRate := flag.Int("sample-rate", 48000, "Render sample rate in Hz")
+	blockSize := flag.Int("block-size", 128, "Frames per Process call")
+	polyphony := flag.Int("polyphony", 16, "Engine max polyphony")
+	duration := flag.Float64("duration", 3.0, "Render length of every note in seconds")
+	releaseAfter := flag.Float64("release-after", 2.0, "Note hold time before NoteOff in seconds")
+	repeats := flag.Int("repeats", 1, "Timed renders per note and model; the fastest counts")
+	stringsOnly := flag.Bool("strings-only", false, "Drop the body and room IRs so metrics and timings reflect the string models alone")
+	platformScale := flag.Float64("platform-scale", 1, "How many times slower the target platform is than this machine; scales the projected CPU")
+	cpuBudget := flag.Float64("cpu-budget", 0, "CPU percent of one target core a sounding note may use; models above it are marked as not fitting (0 = no budget)")
+	jsonPath := flag.String("json", "", "Write the report as JSON to this path")
+	fitcommon.ParseFlags()
+
+	models, err := parseModels(*modelsRaw)
+	if err != nil {
+		die("invalid --models: %v", err)
+	}
+	notes, err := parseNotes(*notesRaw)
+	if err != nil {
+		die("invalid --notes: %v", err)
+	}
+	if *velocity < 1 || *velocity > 127 {
+		die("--velocity must be in [1,127]")
+	}
+	if *sampleRate < 8000 || *blockSize < 1 || *polyphony < 1 {
+		die("--sample-rate must be >= 8000, --block-size and --polyphony >= 1")
+	}
+	if *duration <= 0 || *releaseAfter < 0 {
+		die("--duration must be > 0 and --release-after >= 0")
+	}
+	if *platformScale <= 0 || *cpuBudget < 0 {
+		die("--platform-scale must be > 0 and --cpu-budget >= 0")
+	}
+	base, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	if *stringsOnly {
+		base.IRWavPath, base.BodyIRWavPath, base.BodyIRClosedWavPath, base.RoomIRWavPath = "", "", "", ""
+	}
+	for _, n := range notes {
+		if n < base.MinNote || n > base.MaxNote {
+			die("note %d outside the preset's range [%d,%d]", n, base.MinNote, base.MaxNote)
+		}
+	}
+
+	rc := renderConfig{
+		sampleRate:   *sampleRate,
+		polyphony:    *polyphony,
+		blockSize:    *blockSize,
+		velocity:     *velocity,
+		durationSec:  *duration,
+		releaseAfter: *releaseAfter,
+		repeats:      *repeats,
+	}
+	rep := compareReport{
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		GoVersion:     runtime.Version(),
+		GOOS:          runtime.GOOS,
+		GOARCH:        runtime.GOARCH,
+		CPUs:          runtime.NumCPU(),
+		Preset:        *presetPath,
+		SampleRate:    *sampleRate,
+		BlockSize:     *blockSize,
+		Velocity:      *velocity,
+		DurationSec:   *duration,
+		ReleaseAfter:  *releaseAfter,
+		Notes:         notes,
+		StringsOnly:   *stringsOnly,
+		PlatformScale: *platformScale,
+		CPUBudget:     *cpuBudget,
+	}
+
+	renders := make(map[piano.StringModel]map[int][]float64, len(models))
+	for _, m := range models {
+		params := modelParams(base, m)
+		renders[m] = make(map[int][]float64, len(notes))
+		costs := make([]noteCost, 0, len(notes))
+		for _, n := range notes {
+			mono, cost := renderNote(params, n, rc)
+			renders[m][n] = mono
+			costs = append(costs, cost)
+		}
+		rep.Models = append(rep.Models, summarizeCost(m, costs, *duration, *platformScale, *cpuBudget))
+	}
+	for i, ref := range models {
+		for _, cand := range models[i+1:] {
+			rep.Pairs = append(rep.Pairs, comparePair(ref, cand, notes, renders[ref], renders[cand], *sampleRate))
+		}
+	}
+	rep.Recommended = recommend(rep.Models, rep.Pairs)
+
+	printReport(rep)
+	if *jsonPath != "" {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			die("failed to encode report: %v", err)
+		}
+		if err := os.WriteFile(*jsonPath, append(b, '\n'), 0o644); err != nil {
+			die("failed to write %s: %v", *jsonPath, err)
+		}
+	}
+}
+
+// printReport prints the cost table, the pairwise metrics and the per-note
+// scores of every pair.
+func printReport(rep compareReport) {
+	fmt.Printf("%-8s %9s %8s %8s %8s %10s %6s\n", "Model", "Setup ms", "RT x", "CPU %", "Proj %", "Slowest", "Fits")
+	for _, c := range rep.Models {
+		slowest := c.PerNote[0]
+		for _, n := range c.PerNote {
+			if n.RenderMs > slowest.RenderMs {
+				slowest = n
+			}
+		}
+		fits := "-"
+		switch {
+		case c.FitsBudget == nil:
+		case *c.FitsBudget:
+			fits = "yes"
+		default:
+			fits = "no"
+		}
+		fmt.Printf("%-8s %9.2f %8.2f %8.2f %8.2f %10s %6s\n",
+			c.Model, c.SetupMs, c.RealtimeFactor, c.CPUPercent, c.ProjectedCPU, fmt.Sprintf("note %d", slowest.Note), fits)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-17s %8s %10s %12s\n", "Pair", "Score", "Similarity", "Worst note")
+	for _, p := range rep.Pairs {
+		fmt.Printf("%-17s %8.4f %10.4f %12s\n",
+			pairName(p), p.MeanScore, p.MeanSimilarity, fmt.Sprintf("%d (%.3f)", p.WorstNote, p.WorstScore))
+	}
+
+	fmt.Println()
+	header := []string{fmt.Sprintf("%-5s", "Note")}
+	for _, p := range rep.Pairs {
+		header = append(header, fmt.Sprintf("%17s", pairName(p)))
+	}
+	fmt.Println(strings.Join(header, " "))
+	for i, n := range rep.Notes {
+		row := []string{fmt.Sprintf("%-5d", n)}
+		for _, p := range rep.Pairs {
+			row = append(row, fmt.Sprintf("%17.4f", p.PerNote[i].Score))
+		}
+		fmt.Println(strings.Join(row, " "))
+	}
+
+	if rep.CPUBudget > 0 {
+		fmt.Println()
+		if rep.Recommended == "" {
+			fmt.Printf("No model fits %.1f%% CPU per note on the target platform\n", rep.CPUBudget)
+		} else {
+			fmt.Printf("Recommended: %s (closest to %s within %.1f%% CPU per note)\n", rep.Recommended, rep.Models[0].Model, rep.CPUBudget)
+		}
+	}
+}
+
+func pairName(p pairMetrics) string {
+	return fmt.Sprintf("%s vs %s", p.Candidate, p.Reference)
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
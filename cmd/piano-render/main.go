@@ -1,15 +1,19 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
 	"github.com/cwbudde/algo-piano/piano"
 	"github.com/cwbudde/algo-piano/preset"
-	"github.com/cwbudde/wav"
-	"github.com/go-audio/audio"
+	"github.com/cwbudde/algo-piano/render"
 )
 
 func main() {
@@ -22,14 +26,85 @@ func main() {
 	minDuration := flag.Float64("min-duration", 0.5, "Minimum render duration in seconds when using -decay-dbfs")
 	maxDuration := flag.Float64("max-duration", 20.0, "Maximum render duration in seconds when using -decay-dbfs")
 	releaseAfter := flag.Float64("release-after", 0.12, "Send NoteOff after this many seconds in auto-decay mode")
+	stopOnInactive := flag.Bool("stop-on-inactive", false, "Auto-stop once no voices are active instead of on the -decay-dbfs threshold")
+	untilSilence := flag.Bool("until-silence", false, "After -release-after, render the release and body/room tail and stop at true silence (below 24-bit resolution); automation ends at the release")
 	sampleRate := flag.Int("sample-rate", 48000, "Render sample rate in Hz")
-	presetPath := flag.String("preset", "assets/presets/default.json", "Preset JSON file path")
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file path")
 	irPath := flag.String("ir", "", "IR WAV path override (optional)")
+	normalizeLUFS := flag.Float64("normalize-lufs", math.Inf(1), "Scale the output to this integrated loudness (ITU-R BS.1770, e.g. -16). Disabled by default")
+	lidPosition := flag.Float64("lid-position", -1, "Lid position in [0,1] crossfading closed (0) and open (1) body IRs; negative keeps the preset value")
+	automationPath := flag.String("automation", "", "JSON file with automation lanes (output_gain, soft_pedal, lid_position, room_wet, body_dry, coupling_amount)")
+	chord := flag.String("chord", "", "Comma-separated MIDI notes struck together (overrides -note)")
+	replayPath := flag.String("replay", "", "Capture JSON (piano.Capture.WriteJSON) to replay instead of striking -note/-chord; must match -sample-rate")
+	loop := flag.Bool("loop", false, "Hold the notes with sustain and write a seamlessly looping WAV of the steady tail")
+	loopStart := flag.Float64("loop-start", 1.0, "Loop start in seconds (past the attack) in -loop mode")
+	loopLength := flag.Float64("loop-length", 4.0, "Loop length in seconds in -loop mode")
+	loopCrossfade := flag.Float64("loop-crossfade", 0.5, "Spectral crossfade length at the loop point in seconds in -loop mode")
+	stems := flag.Bool("stems", false, "Also write the close-mic and room-mic buses as <output>.close.wav and <output>.room.wav")
+	channels := flag.String("channels", "stereo", "Output channel layout: stereo or mono (mono convolves a mono mid of the room IR, about half the CPU)")
 	output := flag.String("output", "output.wav", "Output WAV file path")
-	flag.Parse()
+	fitcommon.ParseFlags()
+
+	notes := []int{*note}
+	if *chord != "" {
+		var err error
+		notes, err = parseNotes(*chord)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -chord: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	loopCfg := render.LoopConfig{SampleRate: *sampleRate, StartS: *loopStart, LengthS: *loopLength, CrossfadeS: *loopCrossfade}
+	if *loop {
+		if err := loopCfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !math.IsInf(*decayDBFS, 1) || *stopOnInactive || *untilSilence {
+			fmt.Fprintf(os.Stderr, "Error: -loop cannot be combined with -decay-dbfs, -stop-on-inactive or -until-silence\n")
+			os.Exit(1)
+		}
+	}
+
+	var capture *piano.Capture
+	if *replayPath != "" {
+		var err error
+		capture, err = piano.LoadCapture(*replayPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading capture %q: %v\n", *replayPath, err)
+			os.Exit(1)
+		}
+		if capture.SampleRate != *sampleRate {
+			fmt.Fprintf(os.Stderr, "Error: capture was recorded at %d Hz, -sample-rate is %d\n", capture.SampleRate, *sampleRate)
+			os.Exit(1)
+		}
+		if *loop {
+			fmt.Fprintf(os.Stderr, "Error: -replay cannot be combined with -loop\n")
+			os.Exit(1)
+		}
+		notes = nil
+	}
+
+	if *stems && (*loop || *untilSilence) {
+		fmt.Fprintf(os.Stderr, "Error: -stems cannot be combined with -loop or -until-silence\n")
+		os.Exit(1)
+	}
 
 	// Create piano engine
-	numChannels := 2 // stereo
+	var numChannels int
+	switch *channels {
+	case "stereo":
+		numChannels = 2
+	case "mono":
+		numChannels = 1
+		if *stems || *loop || *untilSilence {
+			fmt.Fprintf(os.Stderr, "Error: -channels mono cannot be combined with -stems, -loop or -until-silence\n")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: -channels must be stereo or mono, got %q\n", *channels)
+		os.Exit(1)
+	}
 	maxPolyphony := 16
 
 	params, err := preset.LoadJSON(*presetPath)
@@ -43,20 +118,57 @@ func main() {
 	if params.IRWavPath == "" {
 		params.IRWavPath = piano.DefaultIRWavPath
 	}
+	if *lidPosition >= 0 {
+		if *lidPosition > 1 {
+			fmt.Fprintf(os.Stderr, "Error: --lid-position must be in [0,1]\n")
+			os.Exit(1)
+		}
+		params.LidPosition = float32(*lidPosition)
+	}
 
-	fmt.Printf("Rendering note %d, velocity %d, for %.2f seconds at %d Hz (preset: %s, IR: %s)...\n", *note, *velocity, *duration, *sampleRate, *presetPath, params.IRWavPath)
+	renderSeconds := *duration
+	if *loop {
+		renderSeconds = float64(loopCfg.RequiredFrames()) / float64(*sampleRate)
+	}
+	if capture != nil {
+		fmt.Printf("Replaying %d events from %s for %.2f seconds at %d Hz (preset: %s, IR: %s)...\n", len(capture.Events), *replayPath, renderSeconds, *sampleRate, *presetPath, params.IRWavPath)
+	} else {
+		fmt.Printf("Rendering notes %v, velocity %d, for %.2f seconds at %d Hz (preset: %s, IR: %s)...\n", notes, *velocity, renderSeconds, *sampleRate, *presetPath, params.IRWavPath)
+	}
 
 	p := piano.NewPiano(*sampleRate, maxPolyphony, params)
 
-	// Trigger note
-	p.NoteOn(*note, *velocity)
+	var lanes []render.AutomationLane
+	if *automationPath != "" {
+		lanes, err = render.LoadAutomation(*automationPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading automation %q: %v\n", *automationPath, err)
+			os.Exit(1)
+		}
+	}
+	automation, err := render.NewAutomation(lanes, *sampleRate, params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Trigger notes; loop mode holds them with the sustain pedal.
+	if *loop {
+		p.SetSustainPedal(true)
+	}
+	for _, n := range notes {
+		p.NoteOn(n, *velocity)
+	}
+	if capture != nil {
+		p.Replay(capture.Events)
+	}
 
 	blockSize := 128 // process in blocks
-	autoStop := !math.IsInf(*decayDBFS, 1)
+	autoStop := !math.IsInf(*decayDBFS, 1) || *stopOnInactive || *untilSilence
 
 	var totalFrames int
 	if !autoStop {
-		totalFrames = int(float64(*sampleRate) * (*duration))
+		totalFrames = int(float64(*sampleRate) * renderSeconds)
 		if totalFrames < 1 {
 			totalFrames = 1
 		}
@@ -70,109 +182,236 @@ func main() {
 			initialFrames = blockSize
 		}
 	}
-	samples := make([]float32, 0, initialFrames*numChannels)
+	// The take streams to the output files block by block unless -loop or
+	// -normalize-lufs need all of it first.
+	var samples, closeSamples, roomSamples []float32
+	var outWAV, closeWAV, roomWAV *render.WAVWriter
+	if *loop || !math.IsInf(*normalizeLUFS, 1) {
+		samples = make([]float32, 0, initialFrames*numChannels)
+	} else {
+		outWAV = createWAV(*output, *sampleRate, numChannels)
+		if *stems {
+			closeWAV = createWAV(stemPath(*output, "close"), *sampleRate, numChannels)
+			roomWAV = createWAV(stemPath(*output, "room"), *sampleRate, numChannels)
+		}
+	}
+	emit := func(w *render.WAVWriter, buf *[]float32, block []float32) {
+		if w == nil {
+			*buf = append(*buf, block...)
+			return
+		}
+		if err := w.Write(block); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing WAV file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	process := func(n int) []float32 {
+		if numChannels == 1 {
+			return p.ProcessMono(n)
+		}
+		if !*stems {
+			return p.Process(n)
+		}
+		block := make([]float32, n*numChannels)
+		closeBlock := make([]float32, n*numChannels)
+		roomBlock := make([]float32, n*numChannels)
+		p.ProcessStemsInto(block, closeBlock, roomBlock)
+		emit(closeWAV, &closeSamples, closeBlock)
+		emit(roomWAV, &roomSamples, roomBlock)
+		return block
+	}
 
 	framesRendered := 0
 	if autoStop {
-		minFrames := int(float64(*sampleRate) * (*minDuration))
-		maxFrames := int(float64(*sampleRate) * (*maxDuration))
+		mode := render.StopOnDecay
+		if *stopOnInactive || *untilSilence {
+			mode = render.StopOnVoiceInactive
+		}
+		stop, err := render.NewAutoStopper(render.AutoStopConfig{
+			SampleRate:  *sampleRate,
+			DecayDBFS:   *decayDBFS,
+			HoldBlocks:  *decayHoldBlocks,
+			MinDuration: *minDuration,
+			MaxDuration: *maxDuration,
+			Mode:        mode,
+			Channels:    numChannels,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 		releaseAtFrame := int(float64(*sampleRate) * (*releaseAfter))
 		if releaseAtFrame < 0 {
 			releaseAtFrame = 0
 		}
-		if maxFrames < minFrames {
-			maxFrames = minFrames
-		}
-		if maxFrames < 1 {
-			maxFrames = blockSize
-		}
 
-		thresholdLin := math.Pow(10.0, *decayDBFS/20.0)
 		noteReleased := false
-		belowCount := 0
-		if *decayHoldBlocks < 1 {
-			*decayHoldBlocks = 1
-		}
-		for framesRendered < maxFrames {
-			framesToRender := blockSize
-			if framesRendered+framesToRender > maxFrames {
-				framesToRender = maxFrames - framesRendered
-			}
-
-			if !noteReleased && framesRendered >= releaseAtFrame {
-				p.NoteOff(*note)
+		for !stop.Done() {
+			if !noteReleased && stop.Rendered() >= releaseAtFrame {
+				for _, n := range notes {
+					p.NoteOff(n)
+				}
 				noteReleased = true
-			}
-
-			block := p.Process(framesToRender)
-			samples = append(samples, block...)
-			framesRendered += framesToRender
-
-			if framesRendered >= minFrames {
-				if stereoRMS(block) < thresholdLin {
-					belowCount++
-					if belowCount >= *decayHoldBlocks {
-						break
-					}
-				} else {
-					belowCount = 0
+				if *untilSilence {
+					break
 				}
 			}
+
+			automation.Apply(p, stop.Rendered())
+			block := process(automationBlock(automation, stop.Rendered(), stop.NextBlock(blockSize)))
+			emit(outWAV, &samples, block)
+			stop.Observe(block, p.ActiveVoices())
+		}
+		framesRendered = stop.Rendered()
+		if *untilSilence {
+			tail := p.FlushTail(float64(stop.MaxFrames()-framesRendered) / float64(*sampleRate))
+			emit(outWAV, &samples, tail)
+			framesRendered += len(tail) / numChannels
 		}
 		totalFrames = framesRendered
-		fmt.Printf("Auto-stop at %d frames (%.3fs), threshold %.1f dBFS\n", totalFrames, float64(totalFrames)/float64(*sampleRate), *decayDBFS)
+		if *untilSilence {
+			fmt.Printf("Auto-stop at %d frames (%.3fs), true silence\n", totalFrames, float64(totalFrames)/float64(*sampleRate))
+		} else if *stopOnInactive {
+			fmt.Printf("Auto-stop at %d frames (%.3fs), voices inactive\n", totalFrames, float64(totalFrames)/float64(*sampleRate))
+		} else {
+			fmt.Printf("Auto-stop at %d frames (%.3fs), threshold %.1f dBFS\n", totalFrames, float64(totalFrames)/float64(*sampleRate), *decayDBFS)
+		}
 	} else {
 		for framesRendered < totalFrames {
 			framesToRender := blockSize
 			if framesRendered+framesToRender > totalFrames {
 				framesToRender = totalFrames - framesRendered
 			}
+			automation.Apply(p, framesRendered)
+			framesToRender = automationBlock(automation, framesRendered, framesToRender)
 
-			block := p.Process(framesToRender)
-			samples = append(samples, block...)
+			block := process(framesToRender)
+			emit(outWAV, &samples, block)
 			framesRendered += framesToRender
 		}
 	}
 
-	// Write to WAV file
-	file, err := os.Create(*output)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
-		os.Exit(1)
+	if *loop {
+		samples, err = render.MakeLoop(samples, loopCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		totalFrames = len(samples) / numChannels
+		fmt.Printf("Loop %.3f s from %.3f s (%.3f s spectral crossfade)\n", *loopLength, *loopStart, *loopCrossfade)
 	}
-	defer file.Close()
 
-	// Create encoder with 16-bit PCM (audioFormat = 1)
-	encoder := wav.NewEncoder(file, *sampleRate, 16, numChannels, 1)
-	defer encoder.Close()
+	if !math.IsInf(*normalizeLUFS, 1) {
+		n, err := render.NormalizeLUFSInterleaved(samples, numChannels, *sampleRate, *normalizeLUFS)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Normalized %.2f LUFS -> %.2f LUFS (gain %+.2f dB, peak %.2f dBFS)\n", n.MeasuredLUFS, *normalizeLUFS, n.GainDB, n.PeakDBFS)
+		if n.PeakDBFS > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: normalized peak exceeds full scale and will clip\n")
+		}
+		// Stems get the mixdown's gain so they still sum to it.
+		gain := float32(math.Pow(10, n.GainDB/20))
+		for i := range closeSamples {
+			closeSamples[i] *= gain
+			roomSamples[i] *= gain
+		}
+	}
 
-	// Create audio buffer
-	buf := &audio.Float32Buffer{
-		Format: &audio.Format{
-			SampleRate:  *sampleRate,
-			NumChannels: numChannels,
-		},
-		Data:           samples,
-		SourceBitDepth: 16,
+	if outWAV != nil {
+		for _, w := range []*render.WAVWriter{outWAV, closeWAV, roomWAV} {
+			if w == nil {
+				continue
+			}
+			if err := w.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing WAV file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Successfully wrote %s (%d frames)\n", *output, totalFrames)
+		if *stems {
+			fmt.Printf("Wrote close mic stem %s\n", stemPath(*output, "close"))
+			fmt.Printf("Wrote room mic stem %s\n", stemPath(*output, "room"))
+		}
+		return
 	}
 
-	// Write the buffer
-	if err := encoder.Write(buf); err != nil {
+	if err := writeWAV(*output, *sampleRate, numChannels, samples); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing WAV file: %v\n", err)
 		os.Exit(1)
 	}
-
 	fmt.Printf("Successfully wrote %s (%d frames)\n", *output, totalFrames)
+	if *stems {
+		for _, stem := range []struct {
+			name    string
+			samples []float32
+		}{{"close", closeSamples}, {"room", roomSamples}} {
+			path := stemPath(*output, stem.name)
+			if err := writeWAV(path, *sampleRate, numChannels, stem.samples); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing %s stem: %v\n", stem.name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Wrote %s mic stem %s\n", stem.name, path)
+		}
+	}
+}
+
+// writeWAV writes interleaved samples as a 16-bit PCM WAV file.
+func writeWAV(path string, sampleRate int, numChannels int, samples []float32) error {
+	w, err := render.CreateWAV(path, sampleRate, numChannels)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(samples); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// createWAV opens a streaming WAV output or exits.
+func createWAV(path string, sampleRate int, numChannels int) *render.WAVWriter {
+	w, err := render.CreateWAV(path, sampleRate, numChannels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating WAV file: %v\n", err)
+		os.Exit(1)
+	}
+	return w
 }
 
-func stereoRMS(interleaved []float32) float64 {
-	if len(interleaved) == 0 {
-		return 0
+// stemPath derives a stem file name from the output path: out.wav becomes
+// out.close.wav.
+func stemPath(output string, stem string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + "." + stem + ".wav"
+}
+
+// automationBlock shortens a block so it ends at the next automation point.
+func automationBlock(a *render.Automation, rendered int, n int) int {
+	if f := a.NextFrame(rendered); f >= 0 && f-rendered < n {
+		return f - rendered
+	}
+	return n
+}
+
+func parseNotes(raw string) ([]int, error) {
+	var notes []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid note %q", part)
+		}
+		if n < 0 || n > 127 {
+			return nil, fmt.Errorf("note out of range [0,127]: %d", n)
+		}
+		notes = append(notes, n)
 	}
-	var sum float64
-	for _, s := range interleaved {
-		v := float64(s)
-		sum += v * v
+	if len(notes) == 0 {
+		return nil, errors.New("empty notes list")
 	}
-	return math.Sqrt(sum / float64(len(interleaved)))
+	return notes, nil
 }
@@ -0,0 +1,117 @@
+// Command piano-stress renders chromatic scales, fast repeated notes and a
+// sustained 30-note cluster while timing every block, and reports render
+// time against the realtime budget, deadline overruns, non-finite output
+// and runaway levels. Run it before releases to catch performance and
+// stability regressions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+// stressReport is the JSON report of a run.
+type stressReport struct {
+	Timestamp  string           `json:"timestamp"`
+	GoVersion  string           `json:"go_version"`
+	GOOS       string           `json:"goos"`
+	GOARCH     string           `json:"goarch"`
+	CPUs       int              `json:"cpus"`
+	Preset     string           `json:"preset"`
+	SampleRate int              `json:"sample_rate"`
+	BlockSize  int              `json:"block_size"`
+	Scenarios  []scenarioReport `json:"scenarios"`
+	Failures   []string         `json:"failures,omitempty"`
+}
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file path")
+	sampleRate := flag.Int("sample-rate", 48000, "Render sample rate in Hz")
+	blockSize := flag.Int("block-size", 128, "Frames per Process call (the realtime budget unit)")
+	polyphony := flag.Int("polyphony", 16, "Engine max polyphony")
+	scenariosRaw := flag.String("scenarios", "all", "Comma-separated scenarios: chromatic|repeated|cluster|all")
+	notesPerSecond := flag.Float64("notes-per-second", 16, "Chromatic scale rate")
+	clusterNotes := flag.Int("cluster-notes", 30, "Keys in the sustained cluster")
+	jsonPath := flag.String("json", "", "Write the report as JSON to this path")
+	maxOverruns := flag.Int("max-overruns", -1, "Fail when a scenario has more block overruns than this (-1 = do not fail on overruns)")
+	maxPeak := flag.Float64("max-peak", 100, "Fail when a scenario peaks above this absolute level, i.e. the engine runs away (0 = off)")
+	fitcommon.ParseFlags()
+
+	if *sampleRate <= 0 || *blockSize <= 0 {
+		die("--sample-rate and --block-size must be > 0")
+	}
+	if *notesPerSecond <= 0 {
+		die("--notes-per-second must be > 0")
+	}
+	if *clusterNotes < 1 {
+		die("--cluster-notes must be >= 1")
+	}
+	names, err := parseScenarios(*scenariosRaw)
+	if err != nil {
+		die("invalid --scenarios: %v", err)
+	}
+	params, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+
+	cfg := defaultScenarioConfig(*sampleRate)
+	cfg.MinNote, cfg.MaxNote = params.MinNote, params.MaxNote
+	cfg.NotesPerSecond = *notesPerSecond
+	cfg.ClusterNotes = *clusterNotes
+
+	rep := stressReport{
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		CPUs:       runtime.NumCPU(),
+		Preset:     *presetPath,
+		SampleRate: *sampleRate,
+		BlockSize:  *blockSize,
+	}
+	fmt.Printf("%-10s %8s %8s %9s %9s %9s %9s %9s %10s %7s\n",
+		"Scenario", "Audio s", "RT x", "Mean ms", "P99 ms", "Max ms", "Budget", "Overruns", "Peak", "Voices")
+	for _, name := range names {
+		p := piano.NewPiano(*sampleRate, *polyphony, params)
+		r := runScenario(p, buildScenario(name, cfg), *sampleRate, *blockSize)
+		rep.Scenarios = append(rep.Scenarios, r)
+		fmt.Printf("%-10s %8.2f %8.3f %9.3f %9.3f %9.3f %9.3f %9d %10.3g %7d\n",
+			r.Name, r.RenderedS, r.RealtimeFactor, r.MeanBlockMs, r.P99BlockMs, r.MaxBlockMs, r.BudgetMs, r.Overruns, r.PeakAbs, r.MaxActiveVoices)
+		for _, f := range r.failures(*maxOverruns, *maxPeak) {
+			rep.Failures = append(rep.Failures, name+": "+f)
+		}
+		if r.ClippedSamples > 0 {
+			fmt.Printf("Warning: %s: %d samples at or above full scale\n", name, r.ClippedSamples)
+		}
+	}
+
+	if *jsonPath != "" {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			die("failed to encode report: %v", err)
+		}
+		if err := os.WriteFile(*jsonPath, append(b, '\n'), 0o644); err != nil {
+			die("failed to write %s: %v", *jsonPath, err)
+		}
+	}
+	for _, f := range rep.Failures {
+		fmt.Fprintf(os.Stderr, "FAIL %s\n", f)
+	}
+	if len(rep.Failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// Scenario names.
+const (
+	scenarioChromatic = "chromatic"
+	scenarioRepeated  = "repeated"
+	scenarioCluster   = "cluster"
+)
+
+var allScenarios = []string{scenarioChromatic, scenarioRepeated, scenarioCluster}
+
+// engine is the part of *piano.Piano a stress run drives.
+type engine interface {
+	ScheduleEvent(frameOffset int, event piano.Event)
+	Process(numFrames int) []float32
+	ActiveVoices() int
+}
+
+type eventKind int
+
+const (
+	eventNoteOn eventKind = iota
+	eventNoteOff
+	eventPedalDown
+	eventPedalUp
+)
+
+type stressEvent struct {
+	frame    int
+	kind     eventKind
+	note     int
+	velocity int
+}
+
+// scenario is a timed event list rendered for a fixed number of frames.
+type scenario struct {
+	name   string
+	frames int
+	events []stressEvent
+}
+
+// scenarioConfig shapes the generated scenarios.
+type scenarioConfig struct {
+	SampleRate int
+	MinNote    int
+	MaxNote    int
+	// NotesPerSecond is the chromatic scale rate.
+	NotesPerSecond float64
+	// RepeatNote is struck RepeatsPerSecond times for RepeatSeconds.
+	RepeatNote       int
+	RepeatsPerSecond float64
+	RepeatSeconds    float64
+	// ClusterNotes adjacent keys from ClusterLow are struck within
+	// ClusterSpreadS with the sustain pedal down and held ClusterHoldS.
+	ClusterLow     int
+	ClusterNotes   int
+	ClusterSpreadS float64
+	ClusterHoldS   float64
+	// TailS is rendered after the last release.
+	TailS float64
+}
+
+func defaultScenarioConfig(sampleRate int) scenarioConfig {
+	return scenarioConfig{
+		SampleRate:       sampleRate,
+		MinNote:          piano.StandardMinNote,
+		MaxNote:          piano.StandardMaxNote,
+		NotesPerSecond:   16,
+		RepeatNote:       60,
+		RepeatsPerSecond: 20,
+		RepeatSeconds:    3,
+		ClusterLow:       36,
+		ClusterNotes:     30,
+		ClusterSpreadS:   0.05,
+		ClusterHoldS:     4,
+		TailS:            1,
+	}
+}
+
+// parseScenarios resolves a comma-separated scenario list ("all" = every
+// scenario).
+func parseScenarios(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "all" {
+		return allScenarios, nil
+	}
+	var out []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case scenarioChromatic, scenarioRepeated, scenarioCluster:
+			out = append(out, name)
+		default:
+			return nil, fmt.Errorf("unknown scenario %q (expected %s)", name, strings.Join(allScenarios, "|"))
+		}
+	}
+	return out, nil
+}
+
+func buildScenario(name string, cfg scenarioConfig) scenario {
+	toFrame := func(sec float64) int {
+		return int(math.Round(sec * float64(cfg.SampleRate)))
+	}
+	sc := scenario{name: name}
+	end := 0.0
+	switch name {
+	case scenarioChromatic:
+		// Legato up and down the keyboard: each release coincides with
+		// the next strike.
+		step := 1 / cfg.NotesPerSecond
+		var notes []int
+		for n := cfg.MinNote; n <= cfg.MaxNote; n++ {
+			notes = append(notes, n)
+		}
+		for n := cfg.MaxNote - 1; n >= cfg.MinNote; n-- {
+			notes = append(notes, n)
+		}
+		for i, n := range notes {
+			on := float64(i) * step
+			sc.events = append(sc.events,
+				stressEvent{frame: toFrame(on), kind: eventNoteOn, note: n, velocity: 40 + (i*37)%80},
+				stressEvent{frame: toFrame(on + step), kind: eventNoteOff, note: n})
+		}
+		end = float64(len(notes)) * step
+	case scenarioRepeated:
+		step := 1 / cfg.RepeatsPerSecond
+		count := int(cfg.RepeatSeconds * cfg.RepeatsPerSecond)
+		for i := range count {
+			on := float64(i) * step
+			sc.events = append(sc.events,
+				stressEvent{frame: toFrame(on), kind: eventNoteOn, note: cfg.RepeatNote, velocity: 60 + (i%4)*15},
+				stressEvent{frame: toFrame(on + 0.6*step), kind: eventNoteOff, note: cfg.RepeatNote})
+		}
+		end = float64(count) * step
+	case scenarioCluster:
+		sc.events = append(sc.events, stressEvent{frame: 0, kind: eventPedalDown})
+		for i := range cfg.ClusterNotes {
+			on := cfg.ClusterSpreadS * float64(i) / float64(max(1, cfg.ClusterNotes-1))
+			n := cfg.ClusterLow + i
+			sc.events = append(sc.events,
+				stressEvent{frame: toFrame(on), kind: eventNoteOn, note: n, velocity: 100},
+				stressEvent{frame: toFrame(on + 0.2), kind: eventNoteOff, note: n})
+		}
+		end = cfg.ClusterHoldS
+		sc.events = append(sc.events, stressEvent{frame: toFrame(end), kind: eventPedalUp})
+	}
+	sort.SliceStable(sc.events, func(a, b int) bool { return sc.events[a].frame < sc.events[b].frame })
+	sc.frames = toFrame(end + cfg.TailS)
+	return sc
+}
+
+// scenarioReport is the performance and health summary of one scenario.
+type scenarioReport struct {
+	Name            string  `json:"name"`
+	RenderedS       float64 `json:"rendered_s"`
+	WallS           float64 `json:"wall_s"`
+	RealtimeFactor  float64 `json:"realtime_factor"` // wall time / rendered time
+	Blocks          int     `json:"blocks"`
+	BudgetMs        float64 `json:"block_budget_ms"`
+	MeanBlockMs     float64 `json:"mean_block_ms"`
+	P99BlockMs      float64 `json:"p99_block_ms"`
+	MaxBlockMs      float64 `json:"max_block_ms"`
+	MaxBlockAtS     float64 `json:"max_block_at_s"`
+	Overruns        int     `json:"overruns"` // blocks slower than their realtime budget
+	NonFinite       int     `json:"non_finite_samples"`
+	FirstNonFiniteS float64 `json:"first_non_finite_s,omitempty"`
+	PeakAbs         float64 `json:"peak_abs"`
+	ClippedSamples  int     `json:"clipped_samples"`
+	MaxActiveVoices int     `json:"max_active_voices"`
+}
+
+// runScenario renders sc in blocks of blockSize, timing every Process call.
+// Events due within a block are scheduled at their offset in it, as a host
+// delivering timestamped MIDI per audio callback would; the budget of a
+// block is its own length in realtime.
+func runScenario(e engine, sc scenario, sampleRate int, blockSize int) scenarioReport {
+	rep := scenarioReport{Name: sc.name, BudgetMs: 1000 * float64(blockSize) / float64(sampleRate)}
+	var times []float64
+	var wall time.Duration
+	next := 0
+	for rendered := 0; rendered < sc.frames; {
+		n := min(blockSize, sc.frames-rendered)
+		for next < len(sc.events) && sc.events[next].frame < rendered+n {
+			e.ScheduleEvent(sc.events[next].frame-rendered, sc.events[next].pianoEvent())
+			next++
+		}
+
+		start := time.Now()
+		block := e.Process(n)
+		elapsed := time.Since(start)
+
+		wall += elapsed
+		ms := float64(elapsed) / float64(time.Millisecond)
+		times = append(times, ms)
+		if ms > rep.MaxBlockMs {
+			rep.MaxBlockMs = ms
+			rep.MaxBlockAtS = float64(rendered) / float64(sampleRate)
+		}
+		if ms > 1000*float64(n)/float64(sampleRate) {
+			rep.Overruns++
+		}
+		for i, v := range block {
+			a := math.Abs(float64(v))
+			if math.IsNaN(a) || math.IsInf(a, 0) {
+				if rep.NonFinite == 0 {
+					rep.FirstNonFiniteS = float64(rendered+i/2) / float64(sampleRate)
+				}
+				rep.NonFinite++
+				continue
+			}
+			rep.PeakAbs = math.Max(rep.PeakAbs, a)
+			if a >= 1 {
+				rep.ClippedSamples++
+			}
+		}
+		rep.MaxActiveVoices = max(rep.MaxActiveVoices, e.ActiveVoices())
+		rendered += n
+	}
+
+	rep.Blocks = len(times)
+	rep.RenderedS = float64(sc.frames) / float64(sampleRate)
+	rep.WallS = wall.Seconds()
+	if rep.RenderedS > 0 {
+		rep.RealtimeFactor = rep.WallS / rep.RenderedS
+	}
+	if len(times) > 0 {
+		rep.MeanBlockMs = 1000 * rep.WallS / float64(len(times))
+		sort.Float64s(times)
+		rep.P99BlockMs = times[min(len(times)-1, int(0.99*float64(len(times))))]
+	}
+	return rep
+}
+
+func (ev stressEvent) pianoEvent() piano.Event {
+	switch ev.kind {
+	case eventNoteOn:
+		return piano.Event{Kind: piano.EventNoteOn, Note: ev.note, Velocity: ev.velocity}
+	case eventNoteOff:
+		return piano.Event{Kind: piano.EventNoteOff, Note: ev.note}
+	case eventPedalDown:
+		return piano.Event{Kind: piano.EventSustainPedal, Down: true}
+	default:
+		return piano.Event{Kind: piano.EventSustainPedal, Down: false}
+	}
+}
+
+// failures lists why a report should fail a release check. A peak above
+// maxPeak is taken as a runaway (unstable) engine; 0 disables the check.
+func (r scenarioReport) failures(maxOverruns int, maxPeak float64) []string {
+	var out []string
+	if r.NonFinite > 0 {
+		out = append(out, fmt.Sprintf("%d non-finite samples (first at %.3f s)", r.NonFinite, r.FirstNonFiniteS))
+	}
+	if maxPeak > 0 && r.PeakAbs > maxPeak {
+		out = append(out, fmt.Sprintf("peak %.3g exceeds %.3g", r.PeakAbs, maxPeak))
+	}
+	if maxOverruns >= 0 && r.Overruns > maxOverruns {
+		out = append(out, fmt.Sprintf("%d block overruns (max %d)", r.Overruns, maxOverruns))
+	}
+	return out
+}
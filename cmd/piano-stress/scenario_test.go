@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// fakeEngine applies note events when scheduled, checks they fall inside
+// the next block, and outputs NaN from nanAt on.
+type fakeEngine struct {
+	t      *testing.T
+	frame  int
+	nanAt  int
+	held   map[int]bool
+	pedal  bool
+	maxOn  int
+	events int
+}
+
+func (f *fakeEngine) ScheduleEvent(frameOffset int, ev piano.Event) {
+	if frameOffset < 0 || frameOffset >= 128 {
+		f.t.Fatalf("event offset %d outside the block", frameOffset)
+	}
+	switch ev.Kind {
+	case piano.EventNoteOn:
+		f.held[ev.Note] = true
+		f.maxOn = max(f.maxOn, len(f.held))
+		f.events++
+	case piano.EventNoteOff:
+		delete(f.held, ev.Note)
+		f.events++
+	case piano.EventSustainPedal:
+		f.pedal = ev.Down
+	}
+}
+
+func (f *fakeEngine) Process(numFrames int) []float32 {
+	out := make([]float32, 2*numFrames)
+	for i := range numFrames {
+		if f.frame+i >= f.nanAt {
+			out[2*i] = float32(math.NaN())
+		}
+	}
+	f.frame += numFrames
+	return out
+}
+
+func (f *fakeEngine) ActiveVoices() int { return len(f.held) }
+
+func TestBuildScenarioCluster(t *testing.T) {
+	cfg := defaultScenarioConfig(48000)
+	sc := buildScenario(scenarioCluster, cfg)
+	if sc.events[0].kind != eventPedalDown {
+		t.Fatalf("cluster must press the pedal first, got %+v", sc.events[0])
+	}
+	ons := 0
+	for _, ev := range sc.events {
+		if ev.kind == eventNoteOn {
+			ons++
+			if ev.frame > int(cfg.ClusterSpreadS*48000)+1 {
+				t.Fatalf("cluster strike at frame %d is later than the spread", ev.frame)
+			}
+		}
+	}
+	if ons != 30 {
+		t.Fatalf("expected 30 cluster notes, got %d", ons)
+	}
+	if want := int((cfg.ClusterHoldS + cfg.TailS) * 48000); sc.frames != want {
+		t.Fatalf("expected %d frames, got %d", want, sc.frames)
+	}
+}
+
+func TestRunScenarioAppliesEventsPerBlock(t *testing.T) {
+	cfg := defaultScenarioConfig(8000)
+	cfg.MinNote, cfg.MaxNote = 60, 64
+	sc := buildScenario(scenarioChromatic, cfg)
+	e := &fakeEngine{t: t, nanAt: math.MaxInt, held: map[int]bool{}}
+	rep := runScenario(e, sc, 8000, 128)
+
+	if e.events != len(sc.events) || len(e.held) != 0 {
+		t.Fatalf("expected all %d events applied and no note held, got %d events, held %v", len(sc.events), e.events, e.held)
+	}
+	if e.frame != sc.frames {
+		t.Fatalf("rendered %d frames, want %d", e.frame, sc.frames)
+	}
+	if e.maxOn != 1 || rep.MaxActiveVoices != 1 {
+		t.Fatalf("legato scale should hold one note at block ends, got %d / %d", e.maxOn, rep.MaxActiveVoices)
+	}
+	if rep.Blocks < sc.frames/128 || rep.NonFinite != 0 || len(rep.failures(-1, 100)) != 0 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestRunScenarioReportsNonFiniteOutput(t *testing.T) {
+	sc := buildScenario(scenarioRepeated, defaultScenarioConfig(8000))
+	e := &fakeEngine{t: t, nanAt: 8000, held: map[int]bool{}}
+	rep := runScenario(e, sc, 8000, 128)
+	if rep.NonFinite != sc.frames-8000 || math.Abs(rep.FirstNonFiniteS-1) > 1e-9 {
+		t.Fatalf("expected NaNs from 1 s on, got %d (first %.4f s)", rep.NonFinite, rep.FirstNonFiniteS)
+	}
+	if len(rep.failures(-1, 100)) != 1 {
+		t.Fatalf("non-finite output must fail: %v", rep.failures(-1, 100))
+	}
+}
+
+func TestReportFailures(t *testing.T) {
+	rep := scenarioReport{Overruns: 3, PeakAbs: 1e6}
+	if got := rep.failures(-1, 0); len(got) != 0 {
+		t.Fatalf("disabled checks must not fail: %v", got)
+	}
+	if got := rep.failures(2, 100); len(got) != 2 {
+		t.Fatalf("expected overrun and runaway failures, got %v", got)
+	}
+}
+
+func TestParseScenarios(t *testing.T) {
+	got, err := parseScenarios("cluster, repeated")
+	if err != nil || len(got) != 2 || got[0] != scenarioCluster {
+		t.Fatalf("unexpected result %v, %v", got, err)
+	}
+	if all, _ := parseScenarios("all"); len(all) != 3 {
+		t.Fatalf("all must select every scenario, got %v", all)
+	}
+	if _, err := parseScenarios("arpeggio"); err == nil {
+		t.Fatal("expected an error for an unknown scenario")
+	}
+}
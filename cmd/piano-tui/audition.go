@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// auditionRequest is one note to render with a snapshot of the params.
+type auditionRequest struct {
+	params   *piano.Params
+	note     int
+	velocity int
+}
+
+// auditioner renders requests on its own goroutine and plays them through
+// an external player, so the UI stays responsive while a note renders.
+// Only the newest pending request is kept: holding an arrow key renders
+// the value the knob ends on, not every step on the way.
+type auditioner struct {
+	sampleRate int
+	seconds    float64
+	hold       float64
+	player     []string
+	wavPath    string
+
+	req    chan auditionRequest
+	status chan string
+	done   chan struct{}
+	cmd    *exec.Cmd // owned by run
+}
+
+func newAuditioner(sampleRate int, seconds, hold float64, player []string) (*auditioner, error) {
+	dir, err := os.MkdirTemp("", "piano-tui-")
+	if err != nil {
+		return nil, err
+	}
+	a := &auditioner{
+		sampleRate: sampleRate,
+		seconds:    seconds,
+		hold:       hold,
+		player:     player,
+		wavPath:    filepath.Join(dir, "audition.wav"),
+		req:        make(chan auditionRequest, 1),
+		status:     make(chan string, 4),
+		done:       make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+// request queues a render of note with params, replacing any request that
+// has not started yet. params must not change afterwards; session states
+// are never modified in place.
+func (a *auditioner) request(params *piano.Params, note, velocity int) {
+	r := auditionRequest{params: params, note: note, velocity: velocity}
+	for {
+		select {
+		case a.req <- r:
+			return
+		default:
+			select {
+			case <-a.req:
+			default:
+			}
+		}
+	}
+}
+
+func (a *auditioner) run() {
+	defer close(a.done)
+	defer a.stopPlayback()
+	for r := range a.req {
+		samples := renderNote(r.params, a.sampleRate, r.note, r.velocity, a.seconds, a.hold)
+		if err := fitcommon.WriteStereoInterleavedWAV(a.wavPath, samples, a.sampleRate); err != nil {
+			a.report(fmt.Sprintf("audition failed: %v", err))
+			continue
+		}
+		a.stopPlayback()
+		if len(a.player) == 0 {
+			a.report("no audio player found (use --player); last audition in " + a.wavPath)
+			continue
+		}
+		args := append(append([]string(nil), a.player[1:]...), a.wavPath)
+		cmd := exec.Command(a.player[0], args...)
+		if err := cmd.Start(); err != nil {
+			a.report(fmt.Sprintf("failed to start %s: %v", a.player[0], err))
+			continue
+		}
+		a.cmd = cmd
+		go func() { _ = cmd.Wait() }()
+		a.report(fmt.Sprintf("playing %s (%d), peak %.1f dBFS", noteName(r.note), r.note, peakDBFS(samples)))
+	}
+}
+
+func (a *auditioner) report(msg string) {
+	select {
+	case a.status <- msg:
+	default:
+	}
+}
+
+func (a *auditioner) stopPlayback() {
+	if a.cmd != nil && a.cmd.Process != nil {
+		_ = a.cmd.Process.Kill()
+	}
+	a.cmd = nil
+}
+
+// close stops playback once a running render finishes and removes the
+// temporary audition file.
+func (a *auditioner) close() {
+	close(a.req)
+	<-a.done
+	_ = os.RemoveAll(filepath.Dir(a.wavPath))
+}
+
+// renderNote renders note struck at velocity and released after hold
+// seconds, as interleaved stereo of the given length.
+func renderNote(params *piano.Params, sampleRate, note, velocity int, seconds, hold float64) []float32 {
+	p := piano.NewPiano(sampleRate, 16, params)
+	p.NoteOn(note, velocity)
+	frames := max(int(seconds*float64(sampleRate)), 1)
+	release := min(max(int(hold*float64(sampleRate)), 0), frames)
+	out := make([]float32, frames*2)
+	p.ProcessInto(out[:release*2])
+	p.NoteOff(note)
+	p.ProcessInto(out[release*2:])
+	return out
+}
+
+func peakDBFS(samples []float32) float64 {
+	var peak float32
+	for _, v := range samples {
+		if v < 0 {
+			v = -v
+		}
+		peak = max(peak, v)
+	}
+	if peak == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(float64(peak))
+}
+
+// defaultPlayer picks a command-line WAV player for the platform.
+func defaultPlayer() []string {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"afplay"}}
+	case "windows":
+		candidates = [][]string{{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"}}
+	default:
+		candidates = [][]string{
+			{"paplay"},
+			{"pw-play"},
+			{"aplay", "-q"},
+			{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet"},
+		}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c
+		}
+	}
+	return nil
+}
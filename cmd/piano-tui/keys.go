@@ -0,0 +1,73 @@
+package main
+
+// Key names produced by decodeKeys for non-printable keys; printable keys
+// decode to their own character.
+const (
+	keyUp         = "up"
+	keyDown       = "down"
+	keyLeft       = "left"
+	keyRight      = "right"
+	keyShiftLeft  = "shift-left"
+	keyShiftRight = "shift-right"
+	keyTab        = "tab"
+	keyShiftTab   = "shift-tab"
+	keyEnter      = "enter"
+	keySpace      = "space"
+	keyEscape     = "esc"
+	keyCtrlC      = "ctrl-c"
+	keyCtrlR      = "ctrl-r"
+)
+
+// escapeKeys maps the CSI sequences (after "ESC [") that terminals send
+// for the keys the TUI uses.
+var escapeKeys = map[string]string{
+	"A":    keyUp,
+	"B":    keyDown,
+	"C":    keyRight,
+	"D":    keyLeft,
+	"Z":    keyShiftTab,
+	"1;2C": keyShiftRight,
+	"1;2D": keyShiftLeft,
+}
+
+// decodeKeys splits one read from a raw-mode terminal into key names.
+// Unknown escape sequences are dropped.
+func decodeKeys(b []byte) []string {
+	var keys []string
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == 0x1b:
+			if i+1 >= len(b) || (b[i+1] != '[' && b[i+1] != 'O') {
+				keys = append(keys, keyEscape)
+				continue
+			}
+			// CSI/SS3: parameters and intermediates, then a final byte in
+			// 0x40..0x7e.
+			j := i + 2
+			for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+				j++
+			}
+			if j >= len(b) {
+				return keys
+			}
+			if k, ok := escapeKeys[string(b[i+2:j+1])]; ok {
+				keys = append(keys, k)
+			}
+			i = j
+		case c == '\t':
+			keys = append(keys, keyTab)
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyEnter)
+		case c == ' ':
+			keys = append(keys, keySpace)
+		case c == 0x03:
+			keys = append(keys, keyCtrlC)
+		case c == 0x12:
+			keys = append(keys, keyCtrlR)
+		case c >= 0x21 && c < 0x7f:
+			keys = append(keys, string(rune(c)))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// knob is one adjustable parameter. Per-note knobs act on the audition
+// note, so get and set receive it alongside the params.
+type knob struct {
+	name     string
+	perNote  bool
+	min, max float32
+	step     float64
+	get      func(p *piano.Params, note int) float32
+	set      func(p *piano.Params, note int, v float32)
+	// reset restores the value base holds, including "unset" for per-note
+	// parameters.
+	reset func(p, base *piano.Params, note int)
+}
+
+type knobGroup struct {
+	name  string
+	knobs []knob
+}
+
+// adjust moves the knob by steps and clamps it to its range, returning
+// whether the value changed.
+func (k knob) adjust(p *piano.Params, note int, steps int) bool {
+	cur := k.get(p, note)
+	// Snap to the step grid, computed in float64 so repeated nudges save
+	// as short decimals instead of accumulated float32 error.
+	v := float32(math.Round(float64(cur)/k.step+float64(steps)) * k.step)
+	v = min(max(v, k.min), k.max)
+	if v == cur {
+		return false
+	}
+	k.set(p, note, v)
+	return true
+}
+
+// field builds a knob for a global float32 parameter.
+func field(name string, lo, hi float32, step float64, ptr func(p *piano.Params) *float32) knob {
+	return knob{
+		name:  name,
+		min:   lo,
+		max:   hi,
+		step:  step,
+		get:   func(p *piano.Params, _ int) float32 { return *ptr(p) },
+		set:   func(p *piano.Params, _ int, v float32) { *ptr(p) = v },
+		reset: func(p, base *piano.Params, _ int) { *ptr(p) = *ptr(base) },
+	}
+}
+
+// noteField builds a knob for a per-note parameter. Unset entries read as
+// def, the value the engine uses when the note has no override.
+func noteField(name string, lo, hi float32, step float64, def func(p *piano.Params, note int) float32, ptr func(np *piano.NoteParams) *float32) knob {
+	return knob{
+		name:    name,
+		perNote: true,
+		min:     lo,
+		max:     hi,
+		step:    step,
+		get: func(p *piano.Params, note int) float32 {
+			if np, ok := p.PerNote[note]; ok && np != nil && *ptr(np) > 0 {
+				return *ptr(np)
+			}
+			return def(p, note)
+		},
+		set: func(p *piano.Params, note int, v float32) {
+			if p.PerNote == nil {
+				p.PerNote = make(map[int]*piano.NoteParams)
+			}
+			np, ok := p.PerNote[note]
+			if !ok || np == nil {
+				np = &piano.NoteParams{}
+				p.PerNote[note] = np
+			}
+			*ptr(np) = v
+		},
+		reset: func(p, base *piano.Params, note int) {
+			np, ok := p.PerNote[note]
+			if !ok || np == nil {
+				return
+			}
+			*ptr(np) = 0
+			if b, ok := base.PerNote[note]; ok && b != nil {
+				*ptr(np) = *ptr(b)
+			}
+			if reflect.DeepEqual(*np, piano.NoteParams{}) {
+				delete(p.PerNote, note)
+			}
+		},
+	}
+}
+
+// registerLoss is the loop loss of a note without a per-note override: the
+// preset's loss curve, else the engine default.
+func registerLoss(p *piano.Params, note int) float32 {
+	if v, ok := p.LossCurve.At(note); ok && v > 0 && v <= 1 {
+		return v
+	}
+	return piano.DefaultLoopLoss
+}
+
+func constant(v float32) func(*piano.Params, int) float32 {
+	return func(*piano.Params, int) float32 { return v }
+}
+
+// knobGroups lists the parameters the TUI exposes. Ranges stay inside
+// what preset.LoadJSON accepts so every saved preset loads again.
+func knobGroups() []knobGroup {
+	return []knobGroup{
+		{name: "Hammer", knobs: []knob{
+			field("stiffness scale", 0.1, 5, 0.05, func(p *piano.Params) *float32 { return &p.HammerStiffnessScale }),
+			field("exponent scale", 0.5, 2, 0.01, func(p *piano.Params) *float32 { return &p.HammerExponentScale }),
+			field("damping scale", 0.1, 5, 0.05, func(p *piano.Params) *float32 { return &p.HammerDampingScale }),
+			field("initial velocity scale", 0.1, 3, 0.02, func(p *piano.Params) *float32 { return &p.HammerInitialVelocityScale }),
+			field("contact time scale", 0.2, 3, 0.02, func(p *piano.Params) *float32 { return &p.HammerContactTimeScale }),
+		}},
+		{name: "Strings", knobs: []knob{
+			field("high freq damping", 0, 0.99, 0.005, func(p *piano.Params) *float32 { return &p.HighFreqDamping }),
+			field("unison detune scale", 0, 4, 0.05, func(p *piano.Params) *float32 { return &p.UnisonDetuneScale }),
+			field("unison crossfeed", 0, 0.01, 0.0001, func(p *piano.Params) *float32 { return &p.UnisonCrossfeed }),
+			noteField("note loss", 0.99, 1, 0.00005, registerLoss, func(np *piano.NoteParams) *float32 { return &np.Loss }),
+			noteField("note inharmonicity", 0, 2, 0.01, constant(0), func(np *piano.NoteParams) *float32 { return &np.Inharmonicity }),
+		}},
+		{name: "Mix", knobs: []knob{
+			field("output gain", 0.05, 4, 0.05, func(p *piano.Params) *float32 { return &p.OutputGain }),
+			field("body dry mix", 0, 2, 0.02, func(p *piano.Params) *float32 { return &p.BodyDryMix }),
+			field("body IR gain", 0.05, 4, 0.05, func(p *piano.Params) *float32 { return &p.BodyIRGain }),
+			field("room wet mix", 0, 2, 0.02, func(p *piano.Params) *float32 { return &p.RoomWetMix }),
+			field("room gain", 0.05, 4, 0.05, func(p *piano.Params) *float32 { return &p.RoomGain }),
+			field("lid position", 0, 1, 0.05, func(p *piano.Params) *float32 { return &p.LidPosition }),
+		}},
+		{name: "Attack noise", knobs: []knob{
+			field("level", 0, 1, 0.01, func(p *piano.Params) *float32 { return &p.AttackNoiseLevel }),
+			field("duration ms", 0.5, 20, 0.5, func(p *piano.Params) *float32 { return &p.AttackNoiseDurationMs }),
+			field("color dB/oct", -12, 6, 0.5, func(p *piano.Params) *float32 { return &p.AttackNoiseColor }),
+		}},
+		{name: "Resonance", knobs: []knob{
+			field("resonance gain", 0, 0.002, 0.00001, func(p *piano.Params) *float32 { return &p.ResonanceGain }),
+			field("coupling amount", 0, 1, 0.05, func(p *piano.Params) *float32 { return &p.CouplingAmount }),
+		}},
+	}
+}
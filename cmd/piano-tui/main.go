@@ -0,0 +1,168 @@
+// Command piano-tui is a terminal UI for tweaking a preset by ear: it loads
+// a preset, exposes grouped parameters as keyboard-driven sliders, renders
+// and plays a note through the local audio player after each change, and
+// saves the result as a preset JSON file. It complements piano-fit's
+// optimizer with a fast manual loop.
+//
+// Edits are journaled in a preset.Session, so they can be undone and
+// redone, and the net change against the loaded preset can be exported as
+// a diff to apply onto other presets (--apply here, or preset.ApplyJSON).
+// With --watch, saving the preset from a text editor reloads it as an
+// edit of its own.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file to edit")
+	outputPath := flag.String("output", "", "Where to save the edited preset (default: overwrite --preset)")
+	diffPath := flag.String("diff", "", "Where to export the session diff (default: --output with .diff.json)")
+	applyPath := flag.String("apply", "", "Diff (or preset) JSON to apply onto the loaded preset as a first, undoable edit")
+	note := flag.Int("note", 60, "MIDI note to audition")
+	velocity := flag.Int("velocity", 100, "Audition velocity (1-127)")
+	duration := flag.Float64("duration", 2.5, "Audition length in seconds")
+	hold := flag.Float64("hold", 1.5, "Seconds the audition note is held before release")
+	sampleRate := flag.Int("sample-rate", 48000, "Audition sample rate in Hz")
+	watch := flag.Bool("watch", false, "Reload --preset as an undoable edit whenever it changes on disk, e.g. saved from a text editor")
+	player := flag.String("player", "", "WAV player command; the file path is appended (default: afplay, paplay, pw-play, aplay or ffplay)")
+	fitcommon.ParseFlags()
+
+	if *velocity < 1 || *velocity > 127 {
+		die("--velocity must be in [1,127]")
+	}
+	if *duration <= 0 {
+		die("--duration must be > 0")
+	}
+	if *sampleRate <= 0 {
+		die("--sample-rate must be > 0")
+	}
+	if *outputPath == "" {
+		*outputPath = *presetPath
+	}
+	if *diffPath == "" {
+		*diffPath = strings.TrimSuffix(*outputPath, filepath.Ext(*outputPath)) + ".diff.json"
+	}
+	params, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	// Saves keep the provenance of the preset being tweaked.
+	meta, err := preset.LoadMeta(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	session := preset.NewSession(params)
+	if *applyPath != "" {
+		var applyErr error
+		session.Edit("apply "+filepath.Base(*applyPath), func(p *piano.Params) {
+			applyErr = preset.ApplyJSON(p, *applyPath)
+		})
+		if applyErr != nil {
+			die("failed to apply %q: %v", *applyPath, applyErr)
+		}
+		params = session.Params()
+	}
+	if *note < params.MinNote || *note > params.MaxNote {
+		die("--note %d outside the preset range [%d,%d]", *note, params.MinNote, params.MaxNote)
+	}
+
+	playerCmd := strings.Fields(*player)
+	if len(playerCmd) == 0 {
+		playerCmd = defaultPlayer()
+	}
+	aud, err := newAuditioner(*sampleRate, *duration, *hold, playerCmd)
+	if err != nil {
+		die("failed to set up audition: %v", err)
+	}
+	defer aud.close()
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		die("piano-tui needs an interactive terminal: %v", err)
+	}
+	defer restore()
+	// Hide the cursor while running and show it again on exit.
+	fmt.Print("\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\r\n")
+
+	m := newModel(*presetPath, *outputPath, *diffPath, session, *note, *velocity)
+	aud.request(m.params(), m.note, m.velocity)
+
+	reloads := make(chan *piano.Params)
+	reloadErrs := make(chan error)
+	if *watch {
+		// Watching ends with the process.
+		_, err := preset.WatchErrors(*presetPath,
+			func(p *piano.Params) { reloads <- p },
+			func(err error) { reloadErrs <- err })
+		if err != nil {
+			die("failed to watch %q: %v", *presetPath, err)
+		}
+	}
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	for {
+		fmt.Print("\x1b[H\x1b[2J" + m.view())
+		select {
+		case msg := <-aud.status:
+			m.status = msg
+		case p := <-reloads:
+			if m.reload(p) == actAudition {
+				aud.request(m.params(), m.note, m.velocity)
+			}
+		case err := <-reloadErrs:
+			m.status = fmt.Sprintf("reload failed: %v", err)
+		case b, ok := <-keys:
+			if !ok {
+				return
+			}
+			for _, k := range decodeKeys(b) {
+				switch m.handle(k) {
+				case actAudition:
+					aud.request(m.params(), m.note, m.velocity)
+				case actSave:
+					if err := preset.SaveJSONWithMeta(m.outputPath, m.params(), meta); err != nil {
+						m.status = fmt.Sprintf("save failed: %v", err)
+					} else {
+						m.saved()
+					}
+				case actExportDiff:
+					if err := session.SaveDiffJSON(m.diffPath); err != nil {
+						m.status = fmt.Sprintf("diff export failed: %v", err)
+					} else {
+						m.status = "exported diff to " + m.diffPath
+					}
+				case actQuit:
+					return
+				}
+			}
+		}
+	}
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+type action int
+
+const (
+	actNone action = iota
+	actAudition
+	actSave
+	actExportDiff
+	actQuit
+)
+
+// coarseSteps is how many knob steps a shifted adjustment moves.
+const coarseSteps = 10
+
+// model is the TUI state. handle applies one key to it and returns the
+// side effect the caller has to perform; view renders it. Neither touches
+// the terminal or the audio backend, so both are testable.
+type model struct {
+	presetPath string
+	outputPath string
+	diffPath   string
+
+	// session journals every edit for undo/redo; its base is the preset
+	// as loaded, used for reset and change markers.
+	session *preset.Session
+	groups  []knobGroup
+
+	group, row int
+	note       int
+	velocity   int
+	autoPlay   bool
+
+	quitArmed bool
+	status    string
+}
+
+func newModel(presetPath, outputPath, diffPath string, session *preset.Session, note, velocity int) *model {
+	return &model{
+		presetPath: presetPath,
+		outputPath: outputPath,
+		diffPath:   diffPath,
+		session:    session,
+		groups:     knobGroups(),
+		note:       note,
+		velocity:   velocity,
+		autoPlay:   true,
+	}
+}
+
+func (m *model) params() *piano.Params {
+	return m.session.Params()
+}
+
+func (m *model) current() knob {
+	return m.groups[m.group].knobs[m.row]
+}
+
+// label names the journal entry for an edit of k; per-note knobs include
+// the note so edits on different notes undo separately.
+func (m *model) label(k knob) string {
+	if k.perNote {
+		return fmt.Sprintf("%s %s", k.name, noteName(m.note))
+	}
+	return k.name
+}
+
+func (m *model) handle(key string) action {
+	if key != "q" && key != keyEscape {
+		m.quitArmed = false
+	}
+	switch key {
+	case keyUp, "k":
+		n := len(m.groups[m.group].knobs)
+		m.row = (m.row + n - 1) % n
+	case keyDown, "j":
+		m.row = (m.row + 1) % len(m.groups[m.group].knobs)
+	case keyTab:
+		m.group = (m.group + 1) % len(m.groups)
+		m.row = 0
+	case keyShiftTab:
+		m.group = (m.group + len(m.groups) - 1) % len(m.groups)
+		m.row = 0
+	case keyLeft, "h":
+		return m.nudge(-1)
+	case keyRight, "l":
+		return m.nudge(1)
+	case keyShiftLeft, "H":
+		return m.nudge(-coarseSteps)
+	case keyShiftRight, "L":
+		return m.nudge(coarseSteps)
+	case "r":
+		k := m.current()
+		base := m.session.Base()
+		if m.session.Edit("reset "+m.label(k), func(p *piano.Params) { k.reset(p, base, m.note) }) {
+			return m.changed()
+		}
+	case "R":
+		base := m.session.Base()
+		if m.session.Edit("reset all", func(p *piano.Params) { *p = *base }) {
+			m.status = "reset all parameters"
+			return m.changed()
+		}
+	case "u":
+		if label, ok := m.session.Undo(); ok {
+			m.status = "undid " + label
+			return m.changed()
+		}
+		m.status = "nothing to undo"
+	case "U", keyCtrlR:
+		if label, ok := m.session.Redo(); ok {
+			m.status = "redid " + label
+			return m.changed()
+		}
+		m.status = "nothing to redo"
+	case "[":
+		return m.setNote(m.note - 1)
+	case "]":
+		return m.setNote(m.note + 1)
+	case "{":
+		return m.setNote(m.note - 12)
+	case "}":
+		return m.setNote(m.note + 12)
+	case "a":
+		m.autoPlay = !m.autoPlay
+	case keySpace, keyEnter:
+		return actAudition
+	case "s":
+		return actSave
+	case "d":
+		return actExportDiff
+	case "q", keyEscape:
+		if m.session.Modified() && !m.quitArmed {
+			m.quitArmed = true
+			m.status = "unsaved changes: press q again to quit without saving"
+			return actNone
+		}
+		return actQuit
+	case keyCtrlC:
+		return actQuit
+	}
+	return actNone
+}
+
+func (m *model) nudge(steps int) action {
+	k := m.current()
+	if !m.session.Edit(m.label(k), func(p *piano.Params) { k.adjust(p, m.note, steps) }) {
+		return actNone
+	}
+	return m.changed()
+}
+
+func (m *model) changed() action {
+	if m.autoPlay {
+		return actAudition
+	}
+	return actNone
+}
+
+func (m *model) setNote(note int) action {
+	note = min(max(note, m.params().MinNote), m.params().MaxNote)
+	if note == m.note {
+		return actNone
+	}
+	m.note = note
+	return actAudition
+}
+
+// reload journals a preset reloaded from disk as one undoable edit. Reloads
+// of the tool's own saves change nothing and are ignored.
+func (m *model) reload(p *piano.Params) action {
+	if !m.session.Edit("reload "+filepath.Base(m.presetPath), func(dst *piano.Params) { *dst = *p }) {
+		return actNone
+	}
+	m.note = min(max(m.note, p.MinNote), p.MaxNote)
+	m.status = "reloaded " + m.presetPath
+	return m.changed()
+}
+
+// saved records a successful save of the current params.
+func (m *model) saved() {
+	m.session.MarkSaved()
+	m.status = "saved " + m.outputPath
+}
+
+// view renders the screen as CRLF-separated lines for a raw-mode terminal.
+func (m *model) view() string {
+	var lines []string
+	title := fmt.Sprintf("piano-tui  %s", m.presetPath)
+	if m.session.Modified() {
+		title += " [modified]"
+	}
+	lines = append(lines, title, "")
+
+	var tabs []string
+	for i, g := range m.groups {
+		if i == m.group {
+			tabs = append(tabs, "["+g.name+"]")
+		} else {
+			tabs = append(tabs, " "+g.name+" ")
+		}
+	}
+	lines = append(lines, strings.Join(tabs, " "), "")
+
+	for i, k := range m.groups[m.group].knobs {
+		cursor := "  "
+		if i == m.row {
+			cursor = "> "
+		}
+		v := k.get(m.params(), m.note)
+		mark := " "
+		if v != k.get(m.session.Base(), m.note) {
+			mark = "*"
+		}
+		lines = append(lines, fmt.Sprintf("%s%-24s %12.6g  %s %s", cursor, k.name, v, slider(v, k.min, k.max, 24), mark))
+	}
+
+	play := "on"
+	if !m.autoPlay {
+		play = "off"
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("note %s (%d)  velocity %d  auto-audition %s", noteName(m.note), m.note, m.velocity, play),
+		"",
+		"up/down select  left/right adjust  shift+left/right or H/L coarse  tab next group",
+		"r reset knob  R reset all  u undo  U redo  [ ] note  { } octave  space play  a auto",
+		"s save  d export diff  q quit",
+		"",
+		m.status,
+	)
+	return strings.Join(lines, "\r\n")
+}
+
+// slider draws v's position in [lo,hi] as a bar of width cells.
+func slider(v, lo, hi float32, width int) string {
+	n := 0
+	if hi > lo {
+		n = int((v - lo) / (hi - lo) * float32(width))
+	}
+	n = min(max(n, 0), width)
+	return "[" + strings.Repeat("#", n) + strings.Repeat("-", width-n) + "]"
+}
+
+func noteName(note int) string {
+	names := [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	return fmt.Sprintf("%s%d", names[note%12], note/12-1)
+}
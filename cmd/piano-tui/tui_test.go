@@ -0,0 +1,165 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+func TestKnobAdjustSnapsAndClamps(t *testing.T) {
+	p := piano.NewDefaultParams()
+	k := field("output gain", 0.05, 4, 0.05, func(p *piano.Params) *float32 { return &p.OutputGain })
+
+	for i := 0; i < 7; i++ {
+		k.adjust(p, 60, 1)
+	}
+	if p.OutputGain != 1.35 {
+		t.Fatalf("after 7 steps output gain = %v, want 1.35", p.OutputGain)
+	}
+	if !k.adjust(p, 60, 1000) || p.OutputGain != 4 {
+		t.Fatalf("coarse overshoot gave %v, want clamp to 4", p.OutputGain)
+	}
+	if k.adjust(p, 60, 1) {
+		t.Fatal("adjust past the maximum reported a change")
+	}
+}
+
+func TestNoteKnobCreatesPerNoteEntryFromEngineDefault(t *testing.T) {
+	p := piano.NewDefaultParams()
+	var loss knob
+	for _, g := range knobGroups() {
+		for _, k := range g.knobs {
+			if k.name == "note loss" {
+				loss = k
+			}
+		}
+	}
+	if got := loss.get(p, 60); got != 0.9998 {
+		t.Fatalf("unset note loss reads %v, want engine default 0.9998", got)
+	}
+	loss.adjust(p, 60, -2)
+	np := p.PerNote[60]
+	if np == nil || np.Loss != 0.9997 {
+		t.Fatalf("per-note entry = %+v, want loss 0.9997", np)
+	}
+	if _, ok := p.PerNote[61]; ok {
+		t.Fatal("adjusting note 60 touched note 61")
+	}
+}
+
+func newTestModel() *model {
+	return newModel("in.json", "out.json", "out.diff.json", preset.NewSession(piano.NewDefaultParams()), 60, 100)
+}
+
+func TestModelKeysAdjustAuditionAndConfirmQuit(t *testing.T) {
+	m := newTestModel()
+	loaded := m.params().HammerStiffnessScale
+
+	if act := m.handle(keyRight); act != actAudition {
+		t.Fatalf("adjusting with auto-audition returned %v, want actAudition", act)
+	}
+	if m.params().HammerStiffnessScale == loaded {
+		t.Fatal("right arrow did not change the selected knob")
+	}
+	if !strings.Contains(m.view(), "[modified]") {
+		t.Fatal("view does not flag unsaved changes")
+	}
+	if act := m.handle("q"); act != actNone {
+		t.Fatalf("first q with unsaved changes returned %v, want actNone", act)
+	}
+	if act := m.handle("q"); act != actQuit {
+		t.Fatalf("second q returned %v, want actQuit", act)
+	}
+
+	m.handle("r")
+	if m.params().HammerStiffnessScale != loaded {
+		t.Fatalf("reset left stiffness at %v, want %v", m.params().HammerStiffnessScale, loaded)
+	}
+	m.handle("a")
+	m.handle(keyTab)
+	if act := m.handle(keyShiftRight); act != actNone || m.group != 1 {
+		t.Fatalf("adjust without auto-audition returned %v in group %d", act, m.group)
+	}
+	m.note = m.params().MaxNote
+	if act := m.handle("]"); act != actNone || m.note != m.params().MaxNote {
+		t.Fatalf("note moved past the preset range to %d", m.note)
+	}
+}
+
+func TestModelUndoRedoAndNoteKnobReset(t *testing.T) {
+	m := newTestModel()
+	m.handle(keyTab)
+	for range 3 {
+		m.handle(keyDown) // note loss
+	}
+	m.handle(keyLeft)
+	m.handle(keyLeft)
+	if got := m.params().PerNote[60].Loss; got != 0.9997 {
+		t.Fatalf("note loss = %v, want 0.9997", got)
+	}
+	m.handle("u")
+	if _, ok := m.params().PerNote[60]; ok || m.session.Modified() {
+		t.Fatal("one undo did not revert both merged nudges")
+	}
+	m.handle("U")
+	if m.params().PerNote[60] == nil {
+		t.Fatal("redo did not restore the note loss edit")
+	}
+	m.handle("r")
+	if _, ok := m.params().PerNote[60]; ok {
+		t.Fatal("resetting the note knob left an empty per-note entry")
+	}
+	if m.session.Modified() {
+		t.Fatal("reset back to the loaded preset still reports modified")
+	}
+}
+
+func TestEditedPresetSavesAndReloads(t *testing.T) {
+	m := newTestModel()
+	for _, k := range []string{keyRight, keyTab, keyDown, keyDown, keyDown, keyLeft, keyTab, keyShiftLeft} {
+		m.handle(k)
+	}
+	path := filepath.Join(t.TempDir(), "tweaked.json")
+	if err := preset.SaveJSON(path, m.params()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := preset.LoadJSON(path)
+	if err != nil {
+		t.Fatalf("saved preset does not load: %v", err)
+	}
+	if !reflect.DeepEqual(got, m.params()) {
+		t.Fatalf("reloaded params differ:\n got %+v\nwant %+v", got, m.params())
+	}
+}
+
+func TestDecodeKeys(t *testing.T) {
+	in := []byte("\x1b[A\x1b[1;2Cq \t\x1b[Z\r\x1bOB\x1b[5~")
+	want := []string{keyUp, keyShiftRight, "q", keySpace, keyTab, keyShiftTab, keyEnter, keyDown}
+	if got := decodeKeys(in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeKeys = %q, want %q", got, want)
+	}
+}
+
+func TestModelReloadIsUndoableAndIgnoresOwnSave(t *testing.T) {
+	m := newTestModel()
+	if act := m.reload(piano.NewDefaultParams()); act != actNone || m.session.Modified() {
+		t.Fatalf("reloading unchanged params gave %v, modified %v", act, m.session.Modified())
+	}
+
+	edited := piano.NewDefaultParams()
+	edited.OutputGain = 0.3
+	if act := m.reload(edited); act != actAudition {
+		t.Fatalf("reload action = %v, want audition", act)
+	}
+	if m.params().OutputGain != 0.3 || !strings.Contains(m.status, "reloaded") {
+		t.Fatalf("after reload output gain %v, status %q", m.params().OutputGain, m.status)
+	}
+	m.handle("u")
+	if m.params().OutputGain != piano.NewDefaultParams().OutputGain {
+		t.Fatal("undo did not revert the reload")
+	}
+}
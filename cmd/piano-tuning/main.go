@@ -0,0 +1,71 @@
+// Command piano-tuning exports the tuning a preset renders with, per note,
+// as an MTS (MIDI Tuning Standard) bulk dump and as a tuning chart, so it
+// can be loaded into other instruments or compared with tuners' data.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file path")
+	sampleRate := flag.Int("sample-rate", 48000, "Sample rate in Hz the string model is evaluated at")
+	outputSyx := flag.String("output-syx", "", "Write an MTS bulk tuning dump (.syx) to this path")
+	chart := flag.String("chart", "", "Tuning chart output path (default: stdout)")
+	format := flag.String("format", "text", "Chart format: text|csv")
+	program := flag.Int("program", 0, "MTS tuning program number (0..127)")
+	name := flag.String("name", "algo-piano", "MTS tuning name (up to 16 ASCII characters)")
+	device := flag.Int("device", 0x7F, "SysEx device ID (127 = all devices)")
+	fitcommon.ParseFlags()
+
+	if *sampleRate <= 0 {
+		die("--sample-rate must be > 0")
+	}
+	if *format != "text" && *format != "csv" {
+		die("invalid --format %q (expected text|csv)", *format)
+	}
+	params, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	rows := computeRows(*sampleRate, params)
+
+	if *outputSyx != "" {
+		syx, err := encodeMTSBulkDump(rows, *device, *program, *name)
+		if err != nil {
+			die("invalid MTS settings: %v", err)
+		}
+		if err := os.WriteFile(*outputSyx, syx, 0o644); err != nil {
+			die("failed to write %s: %v", *outputSyx, err)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *chart != "" {
+		f, err := os.Create(*chart)
+		if err != nil {
+			die("failed to create chart: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		err = writeChartCSV(w, rows)
+	} else {
+		err = writeChartText(w, rows)
+	}
+	if err != nil {
+		die("failed to write chart: %v", err)
+	}
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// tuningRow is the measured tuning of one note of the preset.
+type tuningRow struct {
+	Note int
+	// ETHz is the equal-tempered target at A4 = 440 Hz.
+	ETHz float64
+	// F1Hz and F2Hz are the first two partials the string model renders.
+	F1Hz float64
+	F2Hz float64
+	// CentsET is the deviation of F1 from ETHz, which is what a
+	// technician's tuning device reads.
+	CentsET float64
+	// InharmonicityB is estimated from F2/F1 as B = (r²-1)/(4-r²),
+	// r = F2/(2*F1).
+	InharmonicityB float64
+	// OctaveCents is the stretch of F1 against the note an octave below
+	// (0 for notes without one in range).
+	OctaveCents float64
+	HasOctave   bool
+}
+
+// computeRows measures every note of the preset range.
+func computeRows(sampleRate int, params *piano.Params) []tuningRow {
+	rows := make([]tuningRow, 0, params.MaxNote-params.MinNote+1)
+	f1 := make(map[int]float64, cap(rows))
+	for note := params.MinNote; note <= params.MaxNote; note++ {
+		partials := piano.NotePartials(sampleRate, params, note, 2)
+		if len(partials) == 0 {
+			continue
+		}
+		r := tuningRow{Note: note, ETHz: etFrequency(note), F1Hz: partials[0]}
+		r.CentsET = cents(r.F1Hz, r.ETHz)
+		r.InharmonicityB = math.NaN()
+		if len(partials) > 1 {
+			r.F2Hz = partials[1]
+			ratio := r.F2Hz / (2 * r.F1Hz)
+			r.InharmonicityB = (ratio*ratio - 1) / (4 - ratio*ratio)
+		}
+		if below, ok := f1[note-12]; ok {
+			r.OctaveCents = cents(r.F1Hz, 2*below)
+			r.HasOctave = true
+		}
+		f1[note] = r.F1Hz
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+func etFrequency(note int) float64 {
+	return 440 * math.Pow(2, float64(note-69)/12)
+}
+
+func cents(f, ref float64) float64 {
+	return 1200 * math.Log2(f/ref)
+}
+
+// encodeMTSBulkDump builds a MIDI Tuning Standard bulk tuning dump
+// (non-realtime universal SysEx, sub-IDs 08 01) for all 128 keys. Keys
+// without a row keep equal temperament.
+func encodeMTSBulkDump(rows []tuningRow, device, program int, name string) ([]byte, error) {
+	if device < 0 || device > 0x7F {
+		return nil, fmt.Errorf("device must be in [0,127]")
+	}
+	if program < 0 || program > 0x7F {
+		return nil, fmt.Errorf("program must be in [0,127]")
+	}
+	freqs := make([]float64, 128)
+	for note := range freqs {
+		freqs[note] = etFrequency(note)
+	}
+	for _, r := range rows {
+		if r.Note >= 0 && r.Note < 128 {
+			freqs[r.Note] = r.F1Hz
+		}
+	}
+
+	msg := []byte{0xF0, 0x7E, byte(device), 0x08, 0x01, byte(program)}
+	for i := 0; i < 16; i++ {
+		c := byte(' ')
+		if i < len(name) && name[i] >= 0x20 && name[i] < 0x7F {
+			c = name[i]
+		}
+		msg = append(msg, c)
+	}
+	for _, f := range freqs {
+		xx, yy, zz := mtsFrequency(f)
+		msg = append(msg, xx, yy, zz)
+	}
+	var sum byte
+	for _, b := range msg[1:] {
+		sum ^= b
+	}
+	return append(msg, sum&0x7F, 0xF7), nil
+}
+
+// mtsFrequency encodes f as the semitone below it plus a 14-bit fraction
+// of a semitone (100/16384 cent steps), clamped to the representable range.
+// 7F 7F 7F is reserved for "no change" and is never produced.
+func mtsFrequency(f float64) (byte, byte, byte) {
+	semis := 69 + 12*math.Log2(f/440)
+	if !(semis > 0) {
+		return 0, 0, 0
+	}
+	xx := math.Floor(semis)
+	frac := math.Round((semis - xx) * 16384)
+	if frac >= 16384 {
+		xx++
+		frac = 0
+	}
+	if xx >= 127 {
+		xx = 127
+		frac = math.Min(frac, 16382)
+		if semis >= 128 {
+			frac = 16382
+		}
+	}
+	v := int(frac)
+	return byte(xx), byte(v >> 7), byte(v & 0x7F)
+}
+
+func writeChartText(w io.Writer, rows []tuningRow) error {
+	if _, err := fmt.Fprintf(w, "%-5s %-4s %10s %10s %8s %10s %10s %8s\n",
+		"Note", "Name", "ET Hz", "F1 Hz", "Cents", "F2 Hz", "B", "Octave"); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		octave := "-"
+		if r.HasOctave {
+			octave = fmt.Sprintf("%+.2f", r.OctaveCents)
+		}
+		if _, err := fmt.Fprintf(w, "%-5d %-4s %10.3f %10.3f %+8.2f %10.3f %10.2e %8s\n",
+			r.Note, noteName(r.Note), r.ETHz, r.F1Hz, r.CentsET, r.F2Hz, r.InharmonicityB, octave); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeChartCSV(w io.Writer, rows []tuningRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"note", "name", "et_hz", "f1_hz", "cents_et", "f2_hz", "inharmonicity_b", "octave_cents"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		octave := ""
+		if r.HasOctave {
+			octave = formatFloat(r.OctaveCents)
+		}
+		if err := cw.Write([]string{
+			strconv.Itoa(r.Note), noteName(r.Note), formatFloat(r.ETHz), formatFloat(r.F1Hz),
+			formatFloat(r.CentsET), formatFloat(r.F2Hz), formatFloat(r.InharmonicityB), octave,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'g', 8, 64)
+}
+
+func noteName(note int) string {
+	names := [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+	return fmt.Sprintf("%s%d", names[note%12], note/12-1)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestMTSFrequencyEncoding(t *testing.T) {
+	if xx, yy, zz := mtsFrequency(440); xx != 69 || yy != 0 || zz != 0 {
+		t.Fatalf("A4: got %02X %02X %02X", xx, yy, zz)
+	}
+	if xx, yy, zz := mtsFrequency(440 * math.Pow(2, 50.0/1200)); xx != 69 || yy != 0x40 || zz != 0 {
+		t.Fatalf("A4+50c: got %02X %02X %02X", xx, yy, zz)
+	}
+	if xx, yy, zz := mtsFrequency(20000); xx != 0x7F || yy != 0x7F || zz != 0x7E {
+		t.Fatalf("above range must clamp below the reserved value: got %02X %02X %02X", xx, yy, zz)
+	}
+}
+
+func TestEncodeMTSBulkDumpLayout(t *testing.T) {
+	rows := []tuningRow{{Note: 69, F1Hz: 440 * math.Pow(2, 50.0/1200)}}
+	syx, err := encodeMTSBulkDump(rows, 0x7F, 3, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(syx) != 408 {
+		t.Fatalf("bulk dump must be 408 bytes, got %d", len(syx))
+	}
+	if !bytes.Equal(syx[:6], []byte{0xF0, 0x7E, 0x7F, 0x08, 0x01, 0x03}) || syx[len(syx)-1] != 0xF7 {
+		t.Fatalf("unexpected framing: % X ... % X", syx[:6], syx[len(syx)-2:])
+	}
+	if string(syx[6:22]) != "test            " {
+		t.Fatalf("unexpected name %q", syx[6:22])
+	}
+	if got := syx[22+3*69 : 22+3*70]; !bytes.Equal(got, []byte{69, 0x40, 0}) {
+		t.Fatalf("retuned A4: got % X", got)
+	}
+	if got := syx[22+3*60 : 22+3*61]; !bytes.Equal(got, []byte{60, 0, 0}) {
+		t.Fatalf("keys without a row must stay equal tempered: got % X", got)
+	}
+	var sum byte
+	for _, b := range syx[1 : len(syx)-2] {
+		sum ^= b
+	}
+	if syx[len(syx)-2] != sum&0x7F {
+		t.Fatalf("checksum mismatch: got %02X want %02X", syx[len(syx)-2], sum&0x7F)
+	}
+	if _, err := encodeMTSBulkDump(rows, 0x80, 0, ""); err == nil {
+		t.Fatal("expected device out of range to fail")
+	}
+}
+
+func TestComputeRowsFollowsPerNoteF0(t *testing.T) {
+	params := piano.NewDefaultParams()
+	params.StringModel = piano.StringModelModal
+	params.MinNote, params.MaxNote = 57, 69
+	params.PerNote[69] = &piano.NoteParams{F0: 445, Inharmonicity: 0.3}
+	rows := computeRows(48000, params)
+	if len(rows) != 13 {
+		t.Fatalf("expected 13 rows, got %d", len(rows))
+	}
+	a4 := rows[len(rows)-1]
+	want := 445 * math.Sqrt(1+0.12*0.3)
+	if math.Abs(a4.F1Hz-want) > 1e-2 || math.Abs(a4.CentsET-cents(a4.F1Hz, 440)) > 1e-6 {
+		t.Fatalf("A4 must follow its per-note F0: %+v", a4)
+	}
+	if !a4.HasOctave || math.Abs(a4.OctaveCents-cents(a4.F1Hz, 2*rows[0].F1Hz)) > 1e-6 {
+		t.Fatalf("A4 octave stretch must be measured against A3: %+v", a4)
+	}
+	if a4.InharmonicityB <= 0 {
+		t.Fatalf("modal partials are stretched, expected B > 0: %+v", a4)
+	}
+
+	var buf bytes.Buffer
+	if err := writeChartCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 14 || records[13][1] != "A4" || records[1][7] != "" {
+		t.Fatalf("unexpected CSV chart: %v", records)
+	}
+}
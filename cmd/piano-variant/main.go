@@ -0,0 +1,101 @@
+// Command piano-variant derives a characterful variant (honky-tonk, tack
+// piano, aged instrument) from a base preset, typically a fitted one, with
+// the preset package transforms and saves it as a new preset JSON file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+// variant is a set of transform amounts; zero leaves that aspect alone.
+type variant struct {
+	detune   float32 // unison spread in cents (preset.ApplyDetune)
+	hardness float32 // hammer stiffness change in octaves (preset.ApplyHardness)
+	age      float32 // wear in [0,1] (preset.ApplyAge)
+}
+
+// styles are the named starting points of --style.
+var styles = map[string]variant{
+	"honky-tonk": {detune: 15, age: 0.2},
+	"tack":       {detune: 4, hardness: 1.5},
+	"aged":       {detune: 3, age: 0.7},
+}
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Base preset JSON file")
+	output := flag.String("output", "", "Where to save the variant preset (required)")
+	style := flag.String("style", "", "Named variant: "+strings.Join(styleNames(), "|")+" (the amount flags below add to it)")
+	detune := flag.Float64("detune", 0, "Extra unison spread in cents (negative narrows)")
+	hardness := flag.Float64("hardness", 0, "Hammer stiffness change in octaves (negative softens)")
+	age := flag.Float64("age", 0, "Extra wear in [0,1]: faster, duller decay, drifting tuning, harder felt")
+	fitcommon.ParseFlags()
+
+	if *output == "" {
+		die("--output is required")
+	}
+	v, err := styleVariant(*style)
+	if err != nil {
+		die("%v", err)
+	}
+	v.detune += float32(*detune)
+	v.hardness += float32(*hardness)
+	v.age += float32(*age)
+	if v.age < 0 || v.age > 1 {
+		die("total age %.2f outside [0,1]", v.age)
+	}
+
+	params, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	meta, err := preset.LoadMeta(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	v.apply(params)
+	if err := preset.SaveJSONWithMeta(*output, params, meta); err != nil {
+		die("failed to save %q: %v", *output, err)
+	}
+	fmt.Printf("Wrote %s (detune %+.1f cents, hardness %+.2f oct, age %.2f)\n", *output, v.detune, v.hardness, v.age)
+}
+
+// apply runs the transforms on p. Detune goes last so its spread is the
+// heard one after aging widened the unison scale.
+func (v variant) apply(p *piano.Params) {
+	preset.ApplyAge(p, v.age)
+	preset.ApplyHardness(p, v.hardness)
+	preset.ApplyDetune(p, v.detune)
+}
+
+func styleVariant(name string) (variant, error) {
+	if name == "" {
+		return variant{}, nil
+	}
+	v, ok := styles[name]
+	if !ok {
+		return variant{}, fmt.Errorf("unknown --style %q (valid: %s)", name, strings.Join(styleNames(), ", "))
+	}
+	return v, nil
+}
+
+func styleNames() []string {
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
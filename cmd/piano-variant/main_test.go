@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestStyleVariant(t *testing.T) {
+	if v, err := styleVariant(""); err != nil || v != (variant{}) {
+		t.Fatalf("empty style = %+v, %v; want no transform", v, err)
+	}
+	if v, err := styleVariant("tack"); err != nil || v.hardness <= 0 {
+		t.Fatalf("tack style = %+v, %v; want harder hammers", v, err)
+	}
+	if _, err := styleVariant("harpsichord"); err == nil {
+		t.Fatal("unknown style was accepted")
+	}
+}
+
+func TestVariantDetuneIsHeardSpreadAfterAging(t *testing.T) {
+	p := piano.NewDefaultParams()
+	variant{detune: 10, age: 0.5}.apply(p)
+	trichord := p.UnisonRegisters[len(p.UnisonRegisters)-1].Detunes
+	heard := (trichord[2] - trichord[0]) * p.UnisonDetuneScale
+	// Default trichord spans 6 cents, scaled 2x by aging, plus the spread.
+	if heard < 21.99 || heard > 22.01 {
+		t.Fatalf("heard trichord spread = %v cents, want 22", heard)
+	}
+}
@@ -3,16 +3,29 @@
 package main
 
 import (
+	"encoding/json"
 	"strings"
 	"syscall/js"
 	"unsafe"
 
 	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
 )
 
 var (
 	globalPiano  *piano.Piano
 	outputBuffer []float32
+	sampleRate   int
+	// session journals the demo's parameter changes for undo/redo and
+	// diff export; its base is the params the piano starts with.
+	session *preset.Session
+	// Click track and reference tone mixed into the live output; both
+	// start silent.
+	metronome *piano.Metronome
+	testTone  *piano.TestTone
+	// bodyIRClosed is the lid-closed body IR loaded by the page, kept so
+	// offline renders crossfade towards it too.
+	bodyIRClosed []float32
 )
 
 // Provisional modal profile from initial DWG->modal calibration run (notes 36,48,60,72,84).
@@ -34,13 +47,25 @@ func main() {
 	js.Global().Set("wasmInit", js.FuncOf(wasmInit))
 	js.Global().Set("wasmNoteOn", js.FuncOf(wasmNoteOn))
 	js.Global().Set("wasmKeyDown", js.FuncOf(wasmKeyDown))
+	js.Global().Set("wasmKeyPress", js.FuncOf(wasmKeyPress))
+	js.Global().Set("wasmSetPreTrigger", js.FuncOf(wasmSetPreTrigger))
 	js.Global().Set("wasmNoteOff", js.FuncOf(wasmNoteOff))
 	js.Global().Set("wasmSetSustain", js.FuncOf(wasmSetSustain))
 	js.Global().Set("wasmSetCouplingMode", js.FuncOf(wasmSetCouplingMode))
 	js.Global().Set("wasmSetStringModel", js.FuncOf(wasmSetStringModel))
+	js.Global().Set("wasmSetLidPosition", js.FuncOf(wasmSetLidPosition))
+	js.Global().Set("wasmSetBodyIRClosed", js.FuncOf(wasmSetBodyIRClosed))
+	js.Global().Set("wasmSetMetronome", js.FuncOf(wasmSetMetronome))
+	js.Global().Set("wasmSetTestTone", js.FuncOf(wasmSetTestTone))
 	js.Global().Set("wasmLoadIR", js.FuncOf(wasmLoadIR))
 	js.Global().Set("wasmProcessBlock", js.FuncOf(wasmProcessBlock))
+	js.Global().Set("wasmRenderOffline", js.FuncOf(wasmRenderOffline))
 	js.Global().Set("wasmGetMemoryBuffer", js.FuncOf(wasmGetMemoryBuffer))
+	js.Global().Set("wasmGetLevels", js.FuncOf(wasmGetLevels))
+	js.Global().Set("wasmUndo", js.FuncOf(wasmUndo))
+	js.Global().Set("wasmRedo", js.FuncOf(wasmRedo))
+	js.Global().Set("wasmGetSessionState", js.FuncOf(wasmGetSessionState))
+	js.Global().Set("wasmExportDiff", js.FuncOf(wasmExportDiff))
 
 	println("WASM piano module loaded")
 	<-c
@@ -50,7 +75,7 @@ func wasmInit(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 {
 		return nil
 	}
-	sampleRate := args[0].Int()
+	sampleRate = args[0].Int()
 
 	params := piano.NewDefaultParams()
 	params.ModalPartials = webModalPartials
@@ -60,8 +85,12 @@ func wasmInit(this js.Value, args []js.Value) interface{} {
 	params.ModalDampedLoss = webModalDampedLoss
 	params.MinNote = webMinNote
 	params.MaxNote = webMaxNote
+	session = preset.NewSession(params)
 	globalPiano = piano.NewPiano(sampleRate, 16, params)
 
+	metronome = piano.NewMetronome(sampleRate, 0, 4, 0)
+	testTone = piano.NewTestTone(sampleRate, 440, 69, 0)
+
 	// Pre-allocate output buffer for 128 stereo frames
 	outputBuffer = make([]float32, 128*2)
 
@@ -88,6 +117,27 @@ func wasmKeyDown(this js.Value, args []js.Value) interface{} {
 	return nil
 }
 
+// wasmKeyPress reports the start of a key's travel ahead of its note-on,
+// for keyboards that send one; see wasmSetPreTrigger.
+func wasmKeyPress(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || globalPiano == nil {
+		return nil
+	}
+	globalPiano.KeyPress(args[0].Int())
+	return nil
+}
+
+// wasmSetPreTrigger launches hammers the given number of milliseconds
+// (at most piano.MaxHammerPreTriggerMs) ahead of the note-on expected after
+// wasmKeyPress, hiding that much output latency; 0 turns it off.
+func wasmSetPreTrigger(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || globalPiano == nil {
+		return nil
+	}
+	globalPiano.SetHammerPreTrigger(float32(args[0].Float()))
+	return nil
+}
+
 func wasmNoteOff(this js.Value, args []js.Value) interface{} {
 	if len(args) < 1 || globalPiano == nil {
 		return nil
@@ -112,7 +162,14 @@ func wasmSetCouplingMode(this js.Value, args []js.Value) interface{} {
 	}
 	modeRaw := strings.TrimSpace(strings.ToLower(args[0].String()))
 	mode := piano.CouplingMode(modeRaw)
-	return globalPiano.SetCouplingMode(mode)
+	if !globalPiano.SetCouplingMode(mode) {
+		return false
+	}
+	session.Edit("coupling mode", func(p *piano.Params) {
+		p.CouplingMode = mode
+		p.CouplingEnabled = mode != piano.CouplingModeOff
+	})
+	return true
 }
 
 func wasmSetStringModel(this js.Value, args []js.Value) interface{} {
@@ -121,7 +178,130 @@ func wasmSetStringModel(this js.Value, args []js.Value) interface{} {
 	}
 	modelRaw := strings.TrimSpace(strings.ToLower(args[0].String()))
 	model := piano.StringModel(modelRaw)
-	return globalPiano.SetStringModel(model)
+	if !globalPiano.SetStringModel(model) {
+		return false
+	}
+	session.Edit("string model", func(p *piano.Params) { p.StringModel = model })
+	return true
+}
+
+func wasmSetLidPosition(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || globalPiano == nil {
+		return nil
+	}
+	lid := float32(args[0].Float())
+	globalPiano.SetLidPosition(lid)
+	session.Edit("lid position", func(p *piano.Params) { p.LidPosition = lid })
+	return nil
+}
+
+// wasmSetBodyIRClosed installs args[0], a Float32Array at the context
+// sample rate, as the lid-closed body IR that wasmSetLidPosition crossfades
+// towards; an empty array removes it. Returns false on bad input.
+func wasmSetBodyIRClosed(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || globalPiano == nil || !args[0].InstanceOf(js.Global().Get("Float32Array")) {
+		return false
+	}
+	src := args[0]
+	ir := make([]float32, src.Get("length").Int())
+	if len(ir) > 0 {
+		u8 := js.Global().Get("Uint8Array").New(src.Get("buffer"), src.Get("byteOffset"), src.Get("byteLength"))
+		js.CopyBytesToGo(unsafe.Slice((*byte)(unsafe.Pointer(&ir[0])), len(ir)*4), u8)
+	}
+	bodyIRClosed = ir
+	globalPiano.SetBodyIRClosed(ir)
+	return true
+}
+
+// wasmSetMetronome sets the click track tempo and level; a bpm or level
+// of 0 stops it.
+func wasmSetMetronome(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 || metronome == nil {
+		return nil
+	}
+	metronome.SetBPM(args[0].Float())
+	metronome.SetLevel(float32(args[1].Float()))
+	return nil
+}
+
+// wasmSetTestTone plays an A4 reference sine at the given A4 frequency and
+// level; a level of 0 stops it.
+func wasmSetTestTone(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 || testTone == nil {
+		return nil
+	}
+	testTone.SetPitch(args[0].Float(), 69)
+	testTone.SetLevel(float32(args[1].Float()))
+	return nil
+}
+
+// wasmUndo reverts the last parameter change and returns the session
+// state, or null when there is nothing to undo.
+func wasmUndo(this js.Value, args []js.Value) interface{} {
+	if globalPiano == nil {
+		return js.Null()
+	}
+	label, ok := session.Undo()
+	if !ok {
+		return js.Null()
+	}
+	applySession()
+	return sessionState(label)
+}
+
+// wasmRedo re-applies the last undone change and returns the session
+// state, or null when there is nothing to redo.
+func wasmRedo(this js.Value, args []js.Value) interface{} {
+	if globalPiano == nil {
+		return js.Null()
+	}
+	label, ok := session.Redo()
+	if !ok {
+		return js.Null()
+	}
+	applySession()
+	return sessionState(label)
+}
+
+func wasmGetSessionState(this js.Value, args []js.Value) interface{} {
+	if globalPiano == nil {
+		return js.Null()
+	}
+	return sessionState("")
+}
+
+// wasmExportDiff returns the session's net change as preset JSON, ready to
+// apply onto another preset with preset.ApplyJSON.
+func wasmExportDiff(this js.Value, args []js.Value) interface{} {
+	if session == nil {
+		return js.Null()
+	}
+	b, err := json.MarshalIndent(session.Diff(""), "", "  ")
+	if err != nil {
+		return js.Null()
+	}
+	return string(b)
+}
+
+// applySession pushes the demo-controlled parameters of the current
+// session state to the engine after an undo or redo.
+func applySession() {
+	p := session.Params()
+	globalPiano.SetCouplingMode(p.CouplingMode)
+	globalPiano.SetStringModel(p.StringModel)
+	globalPiano.SetLidPosition(p.LidPosition)
+}
+
+func sessionState(label string) map[string]interface{} {
+	p := session.Params()
+	return map[string]interface{}{
+		"label":        label,
+		"canUndo":      session.CanUndo(),
+		"canRedo":      session.CanRedo(),
+		"couplingMode": string(p.CouplingMode),
+		"stringModel":  string(p.StringModel),
+		"lidPosition":  float64(p.LidPosition),
+	}
 }
 
 func wasmLoadIR(this js.Value, args []js.Value) interface{} {
@@ -170,17 +350,103 @@ func wasmProcessBlock(this js.Value, args []js.Value) interface{} {
 		numFrames = 128
 	}
 
-	// Process audio
-	output := globalPiano.Process(numFrames)
-
-	// Copy to persistent buffer
-	copy(outputBuffer, output)
+	// Render straight into the persistent buffer
+	globalPiano.ProcessInto(outputBuffer[:numFrames*2])
+	metronome.MixInto(outputBuffer[:numFrames*2])
+	testTone.MixInto(outputBuffer[:numFrames*2])
 
 	// Return pointer to buffer in WASM linear memory
 	ptr := &outputBuffer[0]
 	return float64(uintptr(unsafe.Pointer(ptr)))
 }
 
+// Offline bounce limits: the longest take and release tail rendered.
+const (
+	offlineMaxSeconds     = 600
+	offlineDefaultTailSec = 10
+)
+
+// wasmRenderOffline renders a list of events with a fresh engine on the
+// current session params, away from the realtime callback, and returns the
+// interleaved stereo result as a Float32Array (null on bad input). args[0]
+// is an array of {time, type, note, velocity, down}: time in seconds from
+// the start, type one of "note_on", "note_off", "key_down", "key_press",
+// "sustain" or "soft". args[1] optionally caps the release tail after the last event in
+// seconds.
+func wasmRenderOffline(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || session == nil || sampleRate <= 0 || args[0].Type() != js.TypeObject {
+		return js.Null()
+	}
+	tailSec := float64(offlineDefaultTailSec)
+	if len(args) > 1 && args[1].Type() == js.TypeNumber {
+		tailSec = min(max(args[1].Float(), 0), offlineMaxSeconds)
+	}
+
+	p := piano.NewPiano(sampleRate, 16, session.Params())
+	if len(bodyIRClosed) > 0 {
+		p.SetBodyIRClosed(bodyIRClosed)
+	}
+	lastFrame := 0
+	list := args[0]
+	for i := 0; i < list.Length(); i++ {
+		frame, ev, ok := parseOfflineEvent(list.Index(i))
+		if !ok {
+			continue
+		}
+		p.ScheduleEvent(frame, ev)
+		lastFrame = max(lastFrame, frame)
+	}
+	out := p.Process(lastFrame + 1)
+	out = append(out, p.FlushTail(tailSec)...)
+
+	bytes := unsafe.Slice((*byte)(unsafe.Pointer(&out[0])), len(out)*4)
+	u8 := js.Global().Get("Uint8Array").New(len(bytes))
+	js.CopyBytesToJS(u8, bytes)
+	return js.Global().Get("Float32Array").New(u8.Get("buffer"))
+}
+
+// parseOfflineEvent converts one wasmRenderOffline event to its frame and
+// engine event.
+func parseOfflineEvent(v js.Value) (int, piano.Event, bool) {
+	if v.Type() != js.TypeObject {
+		return 0, piano.Event{}, false
+	}
+	t := v.Get("time")
+	if t.Type() != js.TypeNumber || t.Float() < 0 || t.Float() > offlineMaxSeconds {
+		return 0, piano.Event{}, false
+	}
+	intField := func(name string) int {
+		if f := v.Get(name); f.Type() == js.TypeNumber {
+			return f.Int()
+		}
+		return 0
+	}
+	kind, ok := piano.ParseEventKind(v.Get("type").String())
+	if !ok {
+		return 0, piano.Event{}, false
+	}
+	ev := piano.Event{Kind: kind, Note: intField("note"), Velocity: intField("velocity"), Down: v.Get("down").Truthy()}
+	return int(t.Float() * float64(sampleRate)), ev, true
+}
+
+// wasmGetLevels returns [{note, rms, peak}, ...] for every note that sounded
+// since the previous call.
+func wasmGetLevels(this js.Value, args []js.Value) interface{} {
+	if globalPiano == nil {
+		return js.Null()
+	}
+	levels := globalPiano.Levels()
+	out := make([]interface{}, len(levels))
+	for i, lv := range levels {
+		out[i] = map[string]interface{}{
+			"note": lv.Note,
+			"rms":  float64(lv.RMS),
+			"peak": float64(lv.Peak),
+		}
+	}
+	return out
+}
+
 func wasmGetMemoryBuffer(this js.Value, args []js.Value) interface{} {
 	mem := js.Global().Get("__algoPianoWasmMemory")
 	if !mem.Truthy() {
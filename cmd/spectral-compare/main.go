@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math"
@@ -21,7 +22,8 @@ func main() {
 	velocity := flag.Int("velocity", 121, "MIDI velocity")
 	releaseAfter := flag.Float64("release-after", 3.39, "Release after seconds")
 	sampleRate := flag.Int("sample-rate", 48000, "Sample rate")
-	flag.Parse()
+	jsonOut := flag.String("json", "", "Optional path for the structured analysis JSON (reference, candidate, metrics)")
+	fitcommon.ParseFlags()
 
 	sr := *sampleRate
 
@@ -96,155 +98,31 @@ func main() {
 		n = len(cand)
 	}
 
-	// STFT analysis with adaptive FFT size.
-	maxFFTSize := 4096
-
-	type band struct {
-		name string
-		loHz float64
-		hiHz float64
-	}
-	bands := []band{
-		{"sub-bass (20-100Hz)", 20, 100},
-		{"bass (100-300Hz)", 100, 300},
-		{"low-mid (300-1kHz)", 300, 1000},
-		{"mid (1-3kHz)", 1000, 3000},
-		{"hi-mid (3-6kHz)", 3000, 6000},
-		{"high (6-12kHz)", 6000, 12000},
-		{"air (12-20kHz)", 12000, 20000},
-	}
-
-	type timeWindow struct {
-		name    string
-		startMs float64
-		endMs   float64
-	}
-	windows := []timeWindow{
-		{"attack (0-20ms)", 0, 20},
-		{"early (20-100ms)", 20, 100},
-		{"sustain (100-500ms)", 100, 500},
-		{"decay (0.5-2s)", 500, 2000},
-		{"late (2-4s)", 2000, 4000},
-	}
+	refA := analysis.Analyze(ref[:n], sr)
+	candA := analysis.Analyze(cand[:n], sr)
 
 	var totalSumSq float64
 	var totalCnt int
 
-	for _, tw := range windows {
-		startSamp := int(tw.startMs / 1000.0 * float64(sr))
-		endSamp := int(tw.endMs / 1000.0 * float64(sr))
-		if endSamp > n {
-			endSamp = n
-		}
-		if startSamp >= endSamp {
-			continue
-		}
-
-		// Per-window time-domain RMS.
-		winRef := ref[startSamp:endSamp]
-		winCand := cand[startSamp:endSamp]
-		wRefRMS := rms(winRef)
-		wCandRMS := rms(winCand)
-
-		// Adaptive FFT size: use smaller FFT for short windows.
-		winSamples := endSamp - startSamp
-		fftSize := maxFFTSize
-		for fftSize > winSamples && fftSize > 256 {
-			fftSize /= 2
-		}
-		if fftSize < 256 {
-			fftSize = 256
-		}
-		hop := fftSize / 2
-		nBins := fftSize / 2
-
-		plan, planErr := algofft.NewPlanReal64(fftSize)
-		if planErr != nil {
-			fmt.Fprintf(os.Stderr, "fft plan (%d): %v\n", fftSize, planErr)
-			continue
-		}
-
-		binHz := float64(sr) / float64(fftSize)
-		hann := makeHann(fftSize)
-		specRef := make([]complex128, fftSize/2+1)
-		specCand := make([]complex128, fftSize/2+1)
-		bufRef := make([]float64, fftSize)
-		bufCand := make([]float64, fftSize)
-
-		avgRef := make([]float64, nBins)
-		avgCand := make([]float64, nBins)
-		nFrames := 0
-
-		for pos := startSamp; pos+fftSize <= endSamp; pos += hop {
-			for i := 0; i < fftSize; i++ {
-				bufRef[i] = ref[pos+i] * hann[i]
-				bufCand[i] = cand[pos+i] * hann[i]
-			}
-			plan.Forward(specRef, bufRef)
-			plan.Forward(specCand, bufCand)
-			for k := 1; k < nBins; k++ {
-				avgRef[k] += cmplx.Abs(specRef[k])
-				avgCand[k] += cmplx.Abs(specCand[k])
-			}
-			nFrames++
-		}
-
-		// Fallback for very short windows: zero-pad single frame.
-		if nFrames == 0 {
-			clear(bufRef)
-			clear(bufCand)
-			for i := 0; i < winSamples && i < fftSize; i++ {
-				bufRef[i] = ref[startSamp+i] * hann[i]
-				bufCand[i] = cand[startSamp+i] * hann[i]
-			}
-			plan.Forward(specRef, bufRef)
-			plan.Forward(specCand, bufCand)
-			for k := 1; k < nBins; k++ {
-				avgRef[k] = cmplx.Abs(specRef[k])
-				avgCand[k] = cmplx.Abs(specCand[k])
-			}
-			nFrames = 1
-		}
-
-		scale := 1.0 / float64(nFrames)
-		for k := range avgRef {
-			avgRef[k] *= scale
-			avgCand[k] *= scale
-		}
-
-		fmt.Printf("--- %s (%d STFT frames, FFT=%d) ---\n", tw.name, nFrames, fftSize)
+	for wi, rw := range refA.Windows {
+		cw := candA.Windows[wi]
+		fmt.Printf("--- %s (%s) (%d STFT frames, FFT=%d) ---\n",
+			rw.Name, formatSpan(rw.StartSec, rw.EndSec), rw.STFTHops, rw.FFTSize)
 		fmt.Printf("  RMS: ref=%.1f dB  cand=%.1f dB  gap=%+.1f dB\n",
-			toDB(wRefRMS), toDB(wCandRMS), toDB(wCandRMS)-toDB(wRefRMS))
-
-		for _, b := range bands {
-			loK := int(b.loHz / binHz)
-			hiK := int(b.hiHz / binHz)
-			if loK < 1 {
-				loK = 1
-			}
-			if hiK >= nBins {
-				hiK = nBins - 1
-			}
-			if loK > hiK {
-				continue
-			}
+			rw.RMSDBFS, cw.RMSDBFS, cw.RMSDBFS-rw.RMSDBFS)
 
+		for bi, rb := range rw.Bands {
+			cb := cw.Bands[bi]
+			loK := max(int(rb.LoHz/rw.BinHz), 1)
+			hiK := min(int(rb.HiHz/rw.BinHz), len(rw.Magnitude)-1)
 			var sumSq float64
-			var refPow, candPow float64
 			cnt := 0
 			for k := loK; k <= hiK; k++ {
-				rDB := 20 * math.Log10(math.Max(avgRef[k], 1e-12))
-				cDB := 20 * math.Log10(math.Max(avgCand[k], 1e-12))
-				d := rDB - cDB
+				d := 20*math.Log10(math.Max(rw.Magnitude[k], 1e-12)) - 20*math.Log10(math.Max(cw.Magnitude[k], 1e-12))
 				sumSq += d * d
-				refPow += avgRef[k] * avgRef[k]
-				candPow += avgCand[k] * avgCand[k]
 				cnt++
 			}
 			rmseDB := math.Sqrt(sumSq / float64(cnt))
-			refDB := 10 * math.Log10(math.Max(refPow/float64(cnt), 1e-24))
-			candDB := 10 * math.Log10(math.Max(candPow/float64(cnt), 1e-24))
-			diff := candDB - refDB
 			marker := ""
 			if rmseDB > 15 {
 				marker = " <<<"
@@ -253,7 +131,7 @@ func main() {
 				marker = " <<< !!!"
 			}
 			fmt.Printf("  %-22s RMSE=%5.1fdB  ref=%6.1fdB  cand=%6.1fdB  diff=%+5.1fdB%s\n",
-				b.name, rmseDB, refDB, candDB, diff, marker)
+				fmt.Sprintf("%s (%s)", rb.Name, formatBand(rb.LoHz, rb.HiHz)), rmseDB, rb.LevelDB, cb.LevelDB, cb.LevelDB-rb.LevelDB, marker)
 			totalSumSq += sumSq
 			totalCnt += cnt
 		}
@@ -276,6 +154,45 @@ func main() {
 		m.SpectralLowRMSEDB, m.SpectralMidRMSEDB, m.SpectralHighRMSEDB)
 	fmt.Printf("  DecayDiffDBPerS: %.1f dB/s (norm'd to 40 → %.1f%%)\n", m.DecayDiffDBPerS, clamp01(m.DecayDiffDBPerS/40)*100)
 	fmt.Printf("  Score:           %.3f  Similarity: %.3f\n", m.Score, m.Similarity)
+
+	if *jsonOut != "" {
+		report := struct {
+			Reference analysis.Analysis `json:"reference"`
+			Candidate analysis.Analysis `json:"candidate"`
+			LagFrames int               `json:"lag_frames"`
+			Metrics   analysis.Metrics  `json:"metrics"`
+		}{refA, candA, lag, m}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "json: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(*jsonOut, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "json: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nWrote analysis JSON: %s\n", *jsonOut)
+	}
+}
+
+// formatSpan renders a time window as "0-20ms" or "0.5-2s".
+func formatSpan(startSec, endSec float64) string {
+	if endSec <= 0.5 {
+		return fmt.Sprintf("%g-%gms", startSec*1000, endSec*1000)
+	}
+	return fmt.Sprintf("%g-%gs", startSec, endSec)
+}
+
+// formatBand renders a band as "20-100Hz" or "1-3kHz".
+func formatBand(loHz, hiHz float64) string {
+	switch {
+	case hiHz <= 300:
+		return fmt.Sprintf("%g-%gHz", loHz, hiHz)
+	case loHz < 1000:
+		return fmt.Sprintf("%g-%gkHz", loHz, hiHz/1000)
+	default:
+		return fmt.Sprintf("%g-%gkHz", loHz/1000, hiHz/1000)
+	}
 }
 
 func toDB(x float64) float64 {
@@ -316,14 +233,6 @@ func clamp01(x float64) float64 {
 	return x
 }
 
-func makeHann(n int) []float64 {
-	w := make([]float64, n)
-	for i := range w {
-		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
-	}
-	return w
-}
-
 // estimateLagXCorr uses FFT-based cross-correlation to find the best alignment.
 func estimateLagXCorr(ref, cand []float64, maxLag int) int {
 	if len(ref) == 0 || len(cand) == 0 || maxLag < 1 {
@@ -0,0 +1,137 @@
+// Command string-ir excites a single string of one note, as the preset's
+// string model (digital waveguide or modal) builds it, with a unit impulse
+// and writes its impulse response plus a table of the partials extracted
+// from it next to the frequencies the model is designed to produce, so the
+// effect of inharmonicity and loss parameters on the actual modal content
+// can be inspected.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+func main() {
+	presetPath := flag.String("preset", preset.DefaultPresetPath, "Preset JSON file path")
+	note := flag.Int("note", 60, "MIDI note of the string")
+	model := flag.String("model", "", "String model override: dwg|modal (default: preset value)")
+	sampleRate := flag.Int("sample-rate", 48000, "Sample rate in Hz")
+	duration := flag.Float64("duration", 3.0, "Impulse response length in seconds")
+	strikePos := flag.Float64("strike-pos", 0.12, "Impulse position as a fraction of the string length")
+	loss := flag.Float64("loss", -1, "Per-note loop loss override in (0,1] (-1 = preset value)")
+	inharmonicity := flag.Float64("inharmonicity", -1, "Per-note inharmonicity override (-1 = preset value)")
+	highFreqDamping := flag.Float64("high-freq-damping", -1, "High-frequency damping override (-1 = preset value)")
+	partials := flag.Int("partials", 16, "Number of partials to extract")
+	output := flag.String("output", "", "Write the impulse response as a mono WAV to this path")
+	normalize := flag.Bool("normalize", true, "Scale the WAV to a peak of -1 dBFS (the table reports the raw levels)")
+	table := flag.String("table", "", "Partial table output path (default: stdout)")
+	format := flag.String("format", "text", "Table format: text|csv")
+	fitcommon.ParseFlags()
+
+	if *sampleRate <= 0 {
+		die("--sample-rate must be > 0")
+	}
+	if *duration <= 0 {
+		die("--duration must be > 0")
+	}
+	if *strikePos <= 0 || *strikePos >= 1 {
+		die("--strike-pos must be in (0,1)")
+	}
+	if *format != "text" && *format != "csv" {
+		die("invalid --format %q (expected text|csv)", *format)
+	}
+	params, err := preset.LoadJSON(*presetPath)
+	if err != nil {
+		die("failed to load preset %q: %v", *presetPath, err)
+	}
+	if *note < params.MinNote || *note > params.MaxNote {
+		die("--note %d outside the preset range [%d,%d]", *note, params.MinNote, params.MaxNote)
+	}
+	switch piano.StringModel(*model) {
+	case "":
+	case piano.StringModelDWG, piano.StringModelModal:
+		params.StringModel = piano.StringModel(*model)
+	default:
+		die("invalid --model %q (expected dwg|modal)", *model)
+	}
+	if *highFreqDamping >= 0 {
+		params.HighFreqDamping = float32(*highFreqDamping)
+	}
+	if *loss >= 0 || *inharmonicity >= 0 {
+		np := &piano.NoteParams{}
+		if cur, ok := params.PerNote[*note]; ok && cur != nil {
+			c := *cur
+			np = &c
+		}
+		if *loss >= 0 {
+			np.Loss = float32(*loss)
+		}
+		if *inharmonicity >= 0 {
+			np.Inharmonicity = float32(*inharmonicity)
+		}
+		params.PerNote[*note] = np
+	}
+
+	frames := int(*duration * float64(*sampleRate))
+	ir := piano.StringImpulseResponse(*sampleRate, params, *note, float32(*strikePos), frames)
+	rows := partialRows(ir, *sampleRate, params, *note, *partials)
+
+	if *output != "" {
+		data := ir
+		if *normalize {
+			data = normalizePeak(ir, 0.891)
+		}
+		if err := fitcommon.WriteMonoWAV(*output, data, *sampleRate); err != nil {
+			die("failed to write %s: %v", *output, err)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *table != "" {
+		f, err := os.Create(*table)
+		if err != nil {
+			die("failed to create table: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		err = writeTableCSV(w, rows)
+	} else {
+		err = writeTableText(w, rows)
+	}
+	if err != nil {
+		die("failed to write table: %v", err)
+	}
+}
+
+// normalizePeak returns a copy of x scaled to the given peak.
+func normalizePeak(x []float32, peak float32) []float32 {
+	var m float32
+	for _, v := range x {
+		if v < 0 {
+			v = -v
+		}
+		m = max(m, v)
+	}
+	out := make([]float32, len(x))
+	if m == 0 {
+		return out
+	}
+	g := peak / m
+	for i, v := range x {
+		out[i] = v * g
+	}
+	return out
+}
+
+func die(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/cwbudde/algo-piano/analysis"
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// partialRow is one partial extracted from the string's impulse response.
+type partialRow struct {
+	Index int
+	// ModelHz is the frequency the string model is designed to ring at
+	// (piano.NotePartials).
+	ModelHz    float64
+	MeasuredHz float64
+	// ErrorCents is MeasuredHz against ModelHz.
+	ErrorCents float64
+	// StretchCents is MeasuredHz against Index times the measured
+	// fundamental: the audible inharmonicity.
+	StretchCents float64
+	AmplitudeDB  float64
+	T60Sec       float64
+}
+
+// partialRows extracts up to n partials from the impulse response ir of
+// note and labels each with the nearest of the model's own partial
+// frequencies, since strongly stretched partials can be found out of
+// order.
+func partialRows(ir []float32, sampleRate int, params *piano.Params, note int, n int) []partialRow {
+	model := piano.NotePartials(sampleRate, params, note, n)
+	if len(model) == 0 {
+		return nil
+	}
+	x := make([]float64, len(ir))
+	for i, v := range ir {
+		x[i] = float64(v)
+	}
+	found := analysis.ExtractPartials(x, sampleRate, model[0], n)
+	rows := make([]partialRow, 0, len(found))
+	f1 := 0.0
+	for _, p := range found {
+		k := nearestPartial(model, p.FreqHz)
+		r := partialRow{
+			Index:        k + 1,
+			ModelHz:      model[k],
+			MeasuredHz:   p.FreqHz,
+			ErrorCents:   cents(p.FreqHz, model[k]),
+			AmplitudeDB:  p.AmplitudeDB,
+			T60Sec:       p.T60Sec,
+			StretchCents: math.NaN(),
+		}
+		if r.Index == 1 {
+			f1 = p.FreqHz
+		}
+		if f1 > 0 {
+			r.StretchCents = cents(r.MeasuredHz, float64(r.Index)*f1)
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// nearestPartial returns the index of the model partial closest to f in
+// cents.
+func nearestPartial(model []float64, f float64) int {
+	best := 0
+	for i := range model {
+		if math.Abs(cents(f, model[i])) < math.Abs(cents(f, model[best])) {
+			best = i
+		}
+	}
+	return best
+}
+
+func cents(f, ref float64) float64 {
+	return 1200 * math.Log2(f/ref)
+}
+
+func writeTableText(w io.Writer, rows []partialRow) error {
+	if _, err := fmt.Fprintf(w, "%-3s %10s %10s %8s %9s %8s %8s\n",
+		"k", "Model Hz", "Meas Hz", "Error", "Stretch", "Amp dB", "T60 s"); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if _, err := fmt.Fprintf(w, "%-3d %10.3f %10.3f %+8.2f %+9.2f %8.1f %8.2f\n",
+			r.Index, r.ModelHz, r.MeasuredHz, r.ErrorCents, r.StretchCents, r.AmplitudeDB, r.T60Sec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTableCSV(w io.Writer, rows []partialRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"index", "model_hz", "measured_hz", "error_cents", "stretch_cents", "amplitude_db", "t60_sec"}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := cw.Write([]string{
+			strconv.Itoa(r.Index), formatFloat(r.ModelHz), formatFloat(r.MeasuredHz), formatFloat(r.ErrorCents),
+			formatFloat(r.StretchCents), formatFloat(r.AmplitudeDB), formatFloat(r.T60Sec),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'g', 8, 64)
+}
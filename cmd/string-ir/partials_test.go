@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestPartialRowsMatchModelForBothStringModels(t *testing.T) {
+	const sr = 48000
+	for _, model := range []piano.StringModel{piano.StringModelDWG, piano.StringModelModal} {
+		params := piano.NewDefaultParams()
+		params.StringModel = model
+		params.PerNote[45] = &piano.NoteParams{Loss: 0.9995, Inharmonicity: 0.3}
+		ir := piano.StringImpulseResponse(sr, params, 45, 0.12, sr)
+		rows := partialRows(ir, sr, params, 45, 6)
+		if len(rows) < 3 {
+			t.Fatalf("%s: found %d partials, want at least 3", model, len(rows))
+		}
+		for _, r := range rows {
+			if math.Abs(r.ErrorCents) > 5 {
+				t.Fatalf("%s: partial %d measured %.2f Hz, model %.2f Hz", model, r.Index, r.MeasuredHz, r.ModelHz)
+			}
+		}
+		if model == piano.StringModelModal && rows[len(rows)-1].StretchCents < 100 {
+			t.Fatalf("expected strong modal stretch, got %+.1f cents", rows[len(rows)-1].StretchCents)
+		}
+	}
+}
+
+func TestWriteTableCSVLeavesMissingStretchEmpty(t *testing.T) {
+	rows := []partialRow{{Index: 2, ModelHz: 200, MeasuredHz: 201, ErrorCents: 8.6, StretchCents: math.NaN()}}
+	var buf bytes.Buffer
+	if err := writeTableCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "2,200,201,8.6,,") {
+		t.Fatalf("unexpected CSV:\n%s", buf.String())
+	}
+}
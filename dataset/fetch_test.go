@@ -0,0 +1,83 @@
+package dataset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchDownloadsOnceAndVerifiesChecksum(t *testing.T) {
+	body := []byte("RIFF fake reference")
+	sum := sha256.Sum256(body)
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := writeManifest(t, dir, `{"name": "test", "entries": [
+		{"note": 60, "url": "`+srv.URL+`/c4.wav?raw=1", "sha256": "`+hex.EncodeToString(sum[:])+`"},
+		{"note": 62, "url": "`+srv.URL+`/d4.wav", "sha256": "`+hex.EncodeToString(make([]byte, 32))+`"}
+	]}`)
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	f := &Fetcher{CacheDir: filepath.Join(dir, "cache")}
+	for range 2 {
+		got, err := f.Fetch(context.Background(), m, m.Entries[0])
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if filepath.Ext(got) != ".wav" {
+			t.Fatalf("cached file %s lost the .wav extension", got)
+		}
+		if b, _ := os.ReadFile(got); string(b) != string(body) {
+			t.Fatalf("cached file = %q", b)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d, want 1 (second fetch from the cache)", requests)
+	}
+
+	if _, err := f.Fetch(context.Background(), m, m.Entries[1]); err == nil {
+		t.Fatal("expected a checksum mismatch")
+	}
+	left, _ := os.ReadDir(f.CacheDir)
+	if len(left) != 1 {
+		t.Fatalf("cache holds %d files after a failed download, want 1", len(left))
+	}
+}
+
+func TestFetchResolvesLocalEntriesAgainstManifest(t *testing.T) {
+	dir := t.TempDir()
+	body := []byte("local take")
+	if err := os.WriteFile(filepath.Join(dir, "c4.wav"), body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(body)
+	path := writeManifest(t, dir, `{"name": "test", "entries": [
+		{"note": 60, "url": "c4.wav", "sha256": "`+hex.EncodeToString(sum[:])+`"},
+		{"note": 60, "url": "c4.wav", "sha256": "`+hex.EncodeToString(make([]byte, 32))+`"}
+	]}`)
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	f := &Fetcher{CacheDir: filepath.Join(dir, "cache")}
+	got, err := f.Fetch(context.Background(), m, m.Entries[0])
+	if err != nil || got != filepath.Join(dir, "c4.wav") {
+		t.Fatalf("Fetch = %q, %v; want the file next to the manifest", got, err)
+	}
+	if _, err := f.Fetch(context.Background(), m, m.Entries[1]); err == nil {
+		t.Fatal("expected a checksum mismatch for the local file")
+	}
+}
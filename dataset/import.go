@@ -0,0 +1,150 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dynamic markings as MIDI velocities, the usual notation-software mapping.
+var dynamicVelocity = map[string]int{
+	"ppp": 16, "pp": 32, "p": 48, "mp": 64, "mf": 80, "f": 96, "ff": 112, "fff": 127,
+}
+
+// A Convention maps the file names of a public sample dataset to entry
+// metadata.
+type Convention struct {
+	Name string
+	// Parse returns the note, velocity and pedal state encoded in a file's
+	// base name; ok is false for files outside the convention (other
+	// instruments or playing techniques).
+	Parse func(base string) (e Entry, ok bool)
+}
+
+// Conventions are the dataset naming conventions Import recognizes.
+var Conventions = []Convention{
+	{Name: "maps", Parse: parseMAPS},
+	{Name: "orchideasol", Parse: parseOrchideaSOL},
+}
+
+// mapsName matches the isolated notes of MAPS, e.g.
+// MAPS_ISOL_NO_F_S1_M60_AkPnBcht.wav: normal or long notes (staccato and
+// repeated notes would not match a held render), loudness P/M/F, sustain
+// pedal S0/S1 and the MIDI note.
+var mapsName = regexp.MustCompile(`^MAPS_ISOL_(?:NO|LG)_([PMF])_S([01])_M(\d+)_\w+\.wav$`)
+
+func parseMAPS(base string) (Entry, bool) {
+	m := mapsName.FindStringSubmatch(base)
+	if m == nil {
+		return Entry{}, false
+	}
+	note, _ := strconv.Atoi(m[3])
+	velocity := map[string]int{"P": dynamicVelocity["p"], "M": dynamicVelocity["mf"], "F": dynamicVelocity["f"]}[m[1]]
+	return Entry{Note: note, Velocity: velocity, Pedal: m[2] == "1"}, true
+}
+
+// orchideaName matches the ordinary piano notes of OrchideaSOL and TinySOL,
+// e.g. Pno-ord-C#4-mf-N-N.wav: instrument, technique, pitch (C4 = MIDI 60)
+// and dynamic.
+var orchideaName = regexp.MustCompile(`^Pno-ord-([A-G])([#b]?)(-?\d)-(ppp|pp|p|mp|mf|f|ff|fff)(?:-[^.]*)?\.wav$`)
+
+func parseOrchideaSOL(base string) (Entry, bool) {
+	m := orchideaName.FindStringSubmatch(base)
+	if m == nil {
+		return Entry{}, false
+	}
+	pc := map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}[m[1]]
+	switch m[2] {
+	case "#":
+		pc++
+	case "b":
+		pc--
+	}
+	octave, _ := strconv.Atoi(m[3])
+	note := 12*(octave+1) + pc
+	if note < 0 || note > 127 {
+		return Entry{}, false
+	}
+	return Entry{Note: note, Velocity: dynamicVelocity[m[4]]}, true
+}
+
+// Import builds a manifest from a dataset checked out under dir, recognizing
+// its files by a naming convention. Entries are paths relative to dir with
+// their checksums, so the manifest belongs in dir. Files no convention
+// recognizes are skipped.
+func Import(dir string) (*Manifest, error) {
+	m := &Manifest{Name: filepath.Base(filepath.Clean(dir)), dir: dir}
+	counts := make(map[string]int)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		for _, c := range Conventions {
+			e, ok := c.Parse(d.Name())
+			if !ok {
+				continue
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if e.SHA256, err = fileSHA256(path); err != nil {
+				return err
+			}
+			e.URL = filepath.ToSlash(rel)
+			e.Name = strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+			m.Entries = append(m.Entries, e)
+			counts[c.Name]++
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Entries) == 0 {
+		return nil, fmt.Errorf("%s: no files match a known dataset naming convention", dir)
+	}
+	var names []string
+	for name, n := range counts {
+		names = append(names, fmt.Sprintf("%s (%d files)", name, n))
+	}
+	sort.Strings(names)
+	m.Description = "Imported from " + strings.Join(names, ", ")
+	sort.SliceStable(m.Entries, func(i, j int) bool {
+		a, b := m.Entries[i], m.Entries[j]
+		if a.Note != b.Note {
+			return a.Note < b.Note
+		}
+		if a.Pedal != b.Pedal {
+			return !a.Pedal
+		}
+		if a.Velocity != b.Velocity {
+			return a.Velocity < b.Velocity
+		}
+		return a.URL < b.URL
+	})
+	return m, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,94 @@
+package dataset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConventionsParseDatasetNames(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want Entry
+		ok   bool
+	}{
+		{"MAPS_ISOL_NO_F_S1_M60_AkPnBcht.wav", Entry{Note: 60, Velocity: 96, Pedal: true}, true},
+		{"MAPS_ISOL_LG_P_S0_M21_ENSTDkCl.wav", Entry{Note: 21, Velocity: 48}, true},
+		{"MAPS_ISOL_RE_F_S0_M60_AkPnBcht.wav", Entry{}, false},
+		{"MAPS_ISOL_ST_F_S0_M60_AkPnBcht.wav", Entry{}, false},
+		{"MAPS_ISOL_NO_F_S1_M60_AkPnBcht.txt", Entry{}, false},
+		{"Pno-ord-C4-mf-N-N.wav", Entry{Note: 60, Velocity: 80}, true},
+		{"Pno-ord-A#0-ff.wav", Entry{Note: 22, Velocity: 112}, true},
+		{"Pno-ord-Db5-pp-N-R100d.wav", Entry{Note: 73, Velocity: 32}, true},
+		{"Pno-prep-C4-mf-N-N.wav", Entry{}, false},
+		{"Vc-ord-C2-pp-1c-N.wav", Entry{}, false},
+	} {
+		var got Entry
+		ok := false
+		for _, c := range Conventions {
+			if got, ok = c.Parse(tc.name); ok {
+				break
+			}
+		}
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("%s: got %+v (ok %t), want %+v (ok %t)", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestImportBuildsLoadableManifest(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"ISOL/NO/MAPS_ISOL_NO_F_S0_M62_AkPnBcht.wav",
+		"ISOL/NO/MAPS_ISOL_NO_P_S0_M60_AkPnBcht.wav",
+		"ISOL/NO/MAPS_ISOL_NO_P_S0_M60_AkPnBcht.txt",
+		"Keyboards/Pno-ord-C4-ff-N-N.wav",
+		"README.md",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := Import(dir)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(m.Entries) != 3 {
+		t.Fatalf("imported %d entries, want 3", len(m.Entries))
+	}
+	first := m.Entries[0]
+	if first.Note != 60 || first.Velocity != 48 || first.URL != "ISOL/NO/MAPS_ISOL_NO_P_S0_M60_AkPnBcht.wav" {
+		t.Fatalf("first entry = %+v, want the MAPS piano C4", first)
+	}
+
+	path := filepath.Join(dir, "manifest.json")
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Notes(); len(got) != 2 || got[0] != 60 || got[1] != 62 {
+		t.Fatalf("Notes = %v, want [60 62]", got)
+	}
+	// Local entries resolve next to the manifest and match their checksums.
+	f := &Fetcher{CacheDir: t.TempDir()}
+	if _, err := f.FetchAll(t.Context(), loaded, loaded.Select(60, 127, false)); err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+}
+
+func TestImportRejectsUnknownLayout(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "c4.wav"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(dir); err == nil {
+		t.Fatal("expected an error for a directory without dataset files")
+	}
+}
@@ -0,0 +1,167 @@
+// Package dataset reads reference dataset manifests (JSON lists of
+// recordings with their note, velocity and checksum) and fetches the
+// recordings into a local cache, so fits against a standard reference set
+// are reproducible on any machine.
+package dataset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry is one reference recording.
+type Entry struct {
+	Note int `json:"note"`
+	// Velocity is the MIDI velocity the note was played at (0 = unknown).
+	Velocity int `json:"velocity,omitempty"`
+	// Pedal marks a recording made with the sustain pedal down.
+	Pedal bool `json:"pedal,omitempty"`
+	// URL is an http(s) URL or a path relative to the manifest.
+	URL string `json:"url"`
+	// SHA256 is the hex checksum of the file, required for remote entries.
+	SHA256 string `json:"sha256,omitempty"`
+	// Name labels the entry in logs, e.g. "C4 mf take 2".
+	Name string `json:"name,omitempty"`
+}
+
+// Manifest lists the recordings of a reference set.
+type Manifest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	License     string  `json:"license,omitempty"`
+	Entries     []Entry `json:"entries"`
+
+	// dir is the manifest's directory, the base of relative entry paths.
+	dir string
+}
+
+// Load reads and validates a manifest file.
+func Load(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.dir = filepath.Dir(path)
+	return &m, nil
+}
+
+// Save writes the manifest as indented JSON.
+func (m *Manifest) Save(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// Validate checks the entries: note and velocity in MIDI range, a URL, and
+// a well-formed checksum (required for remote files).
+func (m *Manifest) Validate() error {
+	if len(m.Entries) == 0 {
+		return fmt.Errorf("entries must not be empty")
+	}
+	for i, e := range m.Entries {
+		if e.Note < 0 || e.Note > 127 {
+			return fmt.Errorf("entries[%d].note must be in [0,127]", i)
+		}
+		if e.Velocity < 0 || e.Velocity > 127 {
+			return fmt.Errorf("entries[%d].velocity must be in [0,127]", i)
+		}
+		if strings.TrimSpace(e.URL) == "" {
+			return fmt.Errorf("entries[%d].url must not be empty", i)
+		}
+		if e.SHA256 == "" {
+			if e.Remote() {
+				return fmt.Errorf("entries[%d].sha256 is required for remote files", i)
+			}
+			continue
+		}
+		if !validSHA256(e.SHA256) {
+			return fmt.Errorf("entries[%d].sha256 must be 64 hex digits", i)
+		}
+	}
+	return nil
+}
+
+// Remote reports whether the entry is downloaded over http(s).
+func (e Entry) Remote() bool {
+	return strings.HasPrefix(e.URL, "http://") || strings.HasPrefix(e.URL, "https://")
+}
+
+// Label names the entry for logs.
+func (e Entry) Label() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return e.URL
+}
+
+// Select returns the entries of note recorded with the given pedal state at
+// the velocity layer nearest to velocity, several entries being takes of
+// that layer. Entries of unknown velocity form their own layer, used only
+// when the note has no other.
+func (m *Manifest) Select(note int, velocity int, pedal bool) []Entry {
+	best := -1
+	for _, e := range m.Entries {
+		if e.Note != note || e.Pedal != pedal || e.Velocity == 0 {
+			continue
+		}
+		if best < 0 || abs(e.Velocity-velocity) < abs(best-velocity) {
+			best = e.Velocity
+		}
+	}
+	if best < 0 {
+		best = 0
+	}
+	var out []Entry
+	for _, e := range m.Entries {
+		if e.Note == note && e.Pedal == pedal && e.Velocity == best {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Notes returns the distinct notes of the entries in ascending order.
+func (m *Manifest) Notes() []int {
+	seen := make(map[int]bool)
+	var notes []int
+	for _, e := range m.Entries {
+		if !seen[e.Note] {
+			seen[e.Note] = true
+			notes = append(notes, e.Note)
+		}
+	}
+	sort.Ints(notes)
+	return notes
+}
+
+func validSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
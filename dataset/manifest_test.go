@@ -0,0 +1,67 @@
+package dataset
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeManifest(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRejectsInvalidEntries(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	for name, entries := range map[string]string{
+		"empty":           `[]`,
+		"note":            `[{"note": 128, "url": "c4.wav"}]`,
+		"velocity":        `[{"note": 60, "velocity": -1, "url": "c4.wav"}]`,
+		"url":             `[{"note": 60, "url": " "}]`,
+		"remote checksum": `[{"note": 60, "url": "https://example.org/c4.wav"}]`,
+		"bad checksum":    `[{"note": 60, "url": "c4.wav", "sha256": "` + sum[:63] + `x"}]`,
+	} {
+		path := writeManifest(t, t.TempDir(), `{"name": "test", "entries": `+entries+`}`)
+		if _, err := Load(path); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSelectPicksNearestVelocityLayer(t *testing.T) {
+	m := &Manifest{Entries: []Entry{
+		{Note: 60, Velocity: 40, URL: "c4-p.wav"},
+		{Note: 60, Velocity: 100, URL: "c4-f-1.wav"},
+		{Note: 60, Velocity: 100, URL: "c4-f-2.wav"},
+		{Note: 60, URL: "c4-unknown.wav"},
+		{Note: 62, URL: "d4.wav"},
+		{Note: 62, Velocity: 100, Pedal: true, URL: "d4-pedal.wav"},
+	}}
+	urls := func(entries []Entry) string {
+		var s []string
+		for _, e := range entries {
+			s = append(s, e.URL)
+		}
+		return strings.Join(s, ",")
+	}
+	if got := urls(m.Select(60, 118, false)); got != "c4-f-1.wav,c4-f-2.wav" {
+		t.Fatalf("Select(60, 118) = %s, want the two forte takes", got)
+	}
+	if got := urls(m.Select(60, 50, false)); got != "c4-p.wav" {
+		t.Fatalf("Select(60, 50) = %s, want the piano layer", got)
+	}
+	if got := urls(m.Select(62, 100, false)); got != "d4.wav" {
+		t.Fatalf("Select(62, 100) = %s, want the entry of unknown velocity", got)
+	}
+	if got := urls(m.Select(62, 100, true)); got != "d4-pedal.wav" {
+		t.Fatalf("Select(62, 100, pedal) = %s, want the pedal take", got)
+	}
+	if got := m.Select(64, 100, false); len(got) != 0 {
+		t.Fatalf("Select(64, 100) = %v, want none", got)
+	}
+}
@@ -10,10 +10,11 @@ require (
 	github.com/cwbudde/mayfly v0.1.0
 	github.com/cwbudde/wav v0.0.0-20260207095734-97d781a5fb8a
 	github.com/go-audio/audio v1.0.0
+	golang.org/x/sys v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/cwbudde/algo-vecmath v0.1.0 // indirect
 	github.com/go-audio/riff v1.0.0 // indirect
-	golang.org/x/sys v0.40.0 // indirect
 )
@@ -0,0 +1,182 @@
+package fitcommon
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseFlags parses the command line like flag.Parse and adds --config: a
+// JSON or TOML file of flag defaults, so a long fit or render setup can be
+// kept next to its results and rerun. Flags given on the command line win
+// over the file.
+func ParseFlags() {
+	path := flag.String("config", "", "JSON or TOML file of flag defaults keyed by flag name (e.g. {\"note\": 60}); command line flags override it")
+	flag.Parse()
+	if *path == "" {
+		return
+	}
+	if err := ApplyConfigFile(flag.CommandLine, *path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --config %s: %v\n", *path, err)
+		os.Exit(2)
+	}
+}
+
+// ApplyConfigFile sets every flag of fs named in the file that was not set
+// on the command line. Files ending in .toml are read as flat TOML
+// (key = value lines, no tables), anything else as a JSON object. Arrays
+// become comma-separated lists, matching flags such as --notes.
+func ApplyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]string
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		values, err = parseTOMLFlags(data)
+	} else {
+		values, err = parseJSONFlags(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	onCommandLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if onCommandLine[name] {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func parseJSONFlags(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		s, err := jsonFlagValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		values[name] = s
+	}
+	return values, nil
+}
+
+func jsonFlagValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			if _, nested := item.([]any); nested {
+				return "", fmt.Errorf("nested arrays are not supported")
+			}
+			s, err := jsonFlagValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", fmt.Errorf("value must be a string, number, bool or array")
+}
+
+func parseTOMLFlags(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			return nil, fmt.Errorf("line %d: tables are not supported", i+1)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		s, err := tomlFlagValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		values[key] = s
+	}
+	return values, nil
+}
+
+func tomlFlagValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := strings.LastIndex(v, `"`)
+		if end == 0 || strings.TrimSpace(stripTOMLComment(v[end+1:])) != "" {
+			return "", fmt.Errorf("malformed string %s", v)
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.LastIndex(v, "'")
+		if end == 0 || strings.TrimSpace(stripTOMLComment(v[end+1:])) != "" {
+			return "", fmt.Errorf("malformed string %s", v)
+		}
+		return v[1:end], nil
+	case strings.HasPrefix(v, "["):
+		v = strings.TrimSpace(stripTOMLComment(v))
+		if !strings.HasSuffix(v, "]") {
+			return "", fmt.Errorf("arrays must be on one line")
+		}
+		var parts []string
+		for _, item := range strings.Split(v[1:len(v)-1], ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			s, err := tomlFlagValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	}
+	v = strings.TrimSpace(stripTOMLComment(v))
+	if v == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return strings.ReplaceAll(v, "_", ""), nil
+}
+
+func stripTOMLComment(v string) string {
+	if i := strings.IndexByte(v, '#'); i >= 0 {
+		return v[:i]
+	}
+	return v
+}
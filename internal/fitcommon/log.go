@@ -0,0 +1,56 @@
+package fitcommon
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// LogConfig selects the console logging of the long-running commands (the
+// fitters and piano-batch): one key=value line or one JSON object per
+// record, filtered by level.
+type LogConfig struct {
+	Format  string // "text" or "json"
+	Quiet   bool   // warnings and errors only
+	Verbose bool   // also debug records
+}
+
+// RegisterLogFlags adds --log-format, --quiet and --verbose to the command
+// line flags.
+func RegisterLogFlags() *LogConfig {
+	c := &LogConfig{}
+	flag.StringVar(&c.Format, "log-format", "text", "Console log format: text (key=value lines) or json (one object per line, for scripts)")
+	flag.BoolVar(&c.Quiet, "quiet", false, "Only log warnings and errors")
+	flag.BoolVar(&c.Verbose, "verbose", false, "Also log debug records (e.g. every evaluation)")
+	return c
+}
+
+// NewLogger returns a logger writing to w. Every record is written with a
+// single Write under the handler's lock, so records of parallel workers
+// never interleave. Text records leave out the time; JSON records keep it.
+func (c LogConfig) NewLogger(w io.Writer) (*slog.Logger, error) {
+	if c.Quiet && c.Verbose {
+		return nil, fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	switch {
+	case c.Quiet:
+		opts.Level = slog.LevelWarn
+	case c.Verbose:
+		opts.Level = slog.LevelDebug
+	}
+	switch c.Format {
+	case "", "text":
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown --log-format %q (use text or json)", c.Format)
+}
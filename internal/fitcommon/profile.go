@@ -0,0 +1,106 @@
+package fitcommon
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof/ on http.DefaultServeMux
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// ProfileConfig selects the profiling of the fitters' optimization loop.
+type ProfileConfig struct {
+	CPUProfile string // CPU profile file
+	PprofAddr  string // listen address of the net/http/pprof server
+	TraceOut   string // runtime/trace execution trace file
+}
+
+// RegisterProfileFlags adds --cpuprofile, --pprof and --trace-out to the
+// command line flags.
+func RegisterProfileFlags() *ProfileConfig {
+	c := &ProfileConfig{}
+	flag.StringVar(&c.CPUProfile, "cpuprofile", "", "Write a CPU profile of the optimization loop to file (samples carry a 'stage' label: render, metrics, ...)")
+	flag.StringVar(&c.PprofAddr, "pprof", "", "Serve net/http/pprof on this address during the optimization loop, e.g. :6060 or localhost:6060")
+	flag.StringVar(&c.TraceOut, "trace-out", "", "Write a runtime execution trace of the optimization loop to file (go tool trace; evaluation stages are user regions)")
+	return c
+}
+
+// Start starts the selected profiling and returns the function that stops
+// it and flushes the files; it is a no-op when nothing is selected.
+func (c ProfileConfig) Start(log *slog.Logger) (stop func(), err error) {
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	defer func() {
+		if err != nil {
+			stop()
+		}
+	}()
+
+	if c.PprofAddr != "" {
+		ln, err := net.Listen("tcp", c.PprofAddr)
+		if err != nil {
+			return nil, fmt.Errorf("pprof: %w", err)
+		}
+		srv := &http.Server{Handler: http.DefaultServeMux}
+		go func() {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Warn("pprof server stopped", "err", err)
+			}
+		}()
+		log.Info("serving pprof", "url", "http://"+ln.Addr().String()+"/debug/pprof/")
+		stops = append(stops, func() { srv.Close() })
+	}
+	if c.CPUProfile != "" {
+		file, err := os.Create(c.CPUProfile)
+		if err != nil {
+			return nil, fmt.Errorf("cpuprofile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cpuprofile: %w", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			file.Close()
+		})
+	}
+	if c.TraceOut != "" {
+		file, err := os.Create(c.TraceOut)
+		if err != nil {
+			return nil, fmt.Errorf("trace-out: %w", err)
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("trace-out: %w", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			file.Close()
+		})
+	}
+	return stop, nil
+}
+
+// StartStage marks the calling goroutine as running the named stage of an
+// evaluation until the returned function is called: CPU profile samples get
+// the pprof label stage=name (go tool pprof -tagfocus stage=render) and the
+// execution trace a user region. Stages do not nest.
+func StartStage(name string) (end func()) {
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("stage", name))
+	pprof.SetGoroutineLabels(ctx)
+	region := trace.StartRegion(ctx, name)
+	return func() {
+		region.End()
+		pprof.SetGoroutineLabels(context.Background())
+	}
+}
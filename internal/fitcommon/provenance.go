@@ -0,0 +1,111 @@
+package fitcommon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Provenance records the run that produced a preset, written under the
+// "meta" key of fitted presets; preset loaders ignore the block (see
+// preset.LoadMeta).
+type Provenance struct {
+	Tool        string          `json:"tool"`
+	CommandLine []string        `json:"command_line,omitempty"`
+	GitCommit   string          `json:"git_commit,omitempty"` // "-dirty" suffix for uncommitted changes
+	Seed        int64           `json:"seed"`
+	References  []ReferenceHash `json:"references,omitempty"`
+	Score       *float64        `json:"score,omitempty"`
+	CreatedAt   string          `json:"created_at,omitempty"` // RFC 3339, UTC
+	// Source is the meta block of the preset the run started from, kept
+	// verbatim so a chain of fits stays traceable.
+	Source json.RawMessage `json:"source,omitempty"`
+}
+
+// ReferenceHash identifies a reference recording by content.
+type ReferenceHash struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewProvenance describes the current run for the meta block of the presets
+// it writes: the command line, the git commit of the build, the seed, the
+// content hashes of the reference recordings and source, the meta block of
+// the preset the run started from (preset.LoadMeta). The caller fills in the
+// score.
+func NewProvenance(tool string, seed int64, references []string, source json.RawMessage) (*Provenance, error) {
+	p := &Provenance{
+		Tool:        tool,
+		CommandLine: append([]string(nil), os.Args...),
+		GitCommit:   gitCommit(),
+		Seed:        seed,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Source:      source,
+	}
+	for _, path := range references {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+		p.References = append(p.References, ReferenceHash{Path: path, SHA256: sum})
+	}
+	return p, nil
+}
+
+// WithScore returns a copy of p carrying score, or nil for a nil p.
+func WithScore(p *Provenance, score float64) *Provenance {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	c.Score = &score
+	return &c
+}
+
+// gitCommit returns the VCS revision stamped into the binary by go build,
+// falling back to asking git (go run does not stamp it); "" when neither
+// knows.
+func gitCommit() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var rev string
+		var modified bool
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				rev = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if rev != "" {
+			if modified {
+				rev += "-dirty"
+			}
+			return rev
+		}
+	}
+	out, err := exec.Command("git", "describe", "--always", "--dirty", "--abbrev=40").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
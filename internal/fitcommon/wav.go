@@ -41,6 +41,36 @@ func ReadWAVMono(path string) ([]float64, int, error) {
 	return out, buf.Format.SampleRate, nil
 }
 
+// ReadWAVChannels reads a WAV file and returns one slice per channel.
+func ReadWAVChannels(path string) ([][]float64, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	dec := wav.NewDecoder(file)
+	if !dec.IsValidFile() {
+		return nil, 0, fmt.Errorf("invalid wav file: %s", path)
+	}
+	buf, err := dec.FullPCMBuffer()
+	if err != nil {
+		return nil, 0, err
+	}
+	if buf == nil || buf.Format == nil || buf.Format.NumChannels < 1 {
+		return nil, 0, fmt.Errorf("invalid wav buffer: %s", path)
+	}
+	ch := buf.Format.NumChannels
+	frames := len(buf.Data) / ch
+	out := make([][]float64, ch)
+	for c := range out {
+		out[c] = make([]float64, frames)
+		for i := 0; i < frames; i++ {
+			out[c][i] = float64(buf.Data[i*ch+c])
+		}
+	}
+	return out, buf.Format.SampleRate, nil
+}
+
 func ResampleIfNeeded(in []float64, fromRate int, toRate int) ([]float64, error) {
 	if fromRate == toRate {
 		return in, nil
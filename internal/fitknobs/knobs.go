@@ -0,0 +1,228 @@
+// Package fitknobs maps fit knob names onto piano.Params fields for the fit
+// tools, with the bounds the preset loader accepts.
+package fitknobs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// A ParamKnob is a fit knob that sets one piano.Params field. Per-note
+// knobs are named per_note.<note>.<Name> and set that note's NoteParams;
+// the others are named Name, as in preset JSON.
+type ParamKnob struct {
+	Name    string
+	PerNote bool
+	// Min and Max bound the values the field accepts (as the preset loader
+	// does); MinOpen and MaxOpen exclude the bound itself.
+	Min, Max         float64
+	MinOpen, MaxOpen bool
+	IsInt            bool
+
+	param func(*piano.Params) *float32
+	note  func(*piano.NoteParams) *float32
+	count func(*piano.Params) *int
+}
+
+var inf = math.Inf(1)
+
+func f32Knob(name string, lo, hi float64, loOpen bool, field func(*piano.Params) *float32) ParamKnob {
+	return ParamKnob{Name: name, Min: lo, Max: hi, MinOpen: loOpen, param: field}
+}
+
+func noteKnob(name string, lo, hi float64, loOpen, hiOpen bool, field func(*piano.NoteParams) *float32) ParamKnob {
+	return ParamKnob{Name: name, PerNote: true, Min: lo, Max: hi, MinOpen: loOpen, MaxOpen: hiOpen, note: field}
+}
+
+// paramKnobs are the Params fields the fit tools search.
+var paramKnobs = []ParamKnob{
+	f32Knob("output_gain", 0, inf, true, func(p *piano.Params) *float32 { return &p.OutputGain }),
+	f32Knob("hammer_stiffness_scale", 0, inf, true, func(p *piano.Params) *float32 { return &p.HammerStiffnessScale }),
+	f32Knob("hammer_exponent_scale", 0, inf, true, func(p *piano.Params) *float32 { return &p.HammerExponentScale }),
+	f32Knob("hammer_damping_scale", 0, inf, true, func(p *piano.Params) *float32 { return &p.HammerDampingScale }),
+	f32Knob("hammer_initial_velocity_scale", 0, inf, true, func(p *piano.Params) *float32 { return &p.HammerInitialVelocityScale }),
+	f32Knob("hammer_contact_time_scale", 0, inf, true, func(p *piano.Params) *float32 { return &p.HammerContactTimeScale }),
+	f32Knob("high_freq_damping", 0, 0.99, false, func(p *piano.Params) *float32 { return &p.HighFreqDamping }),
+	f32Knob("unison_detune_scale", 0, inf, false, func(p *piano.Params) *float32 { return &p.UnisonDetuneScale }),
+	f32Knob("unison_crossfeed", 0, inf, false, func(p *piano.Params) *float32 { return &p.UnisonCrossfeed }),
+	f32Knob("attack_noise_level", 0, inf, false, func(p *piano.Params) *float32 { return &p.AttackNoiseLevel }),
+	f32Knob("attack_noise_duration_ms", 0, 20, true, func(p *piano.Params) *float32 { return &p.AttackNoiseDurationMs }),
+	f32Knob("attack_noise_color", -inf, inf, false, func(p *piano.Params) *float32 { return &p.AttackNoiseColor }),
+	f32Knob("hammer_knock_level", 0, inf, false, func(p *piano.Params) *float32 { return &p.HammerKnockLevel }),
+	f32Knob("hammer_knock_attack_ms", 0, 50, true, func(p *piano.Params) *float32 { return &p.HammerKnockAttackMs }),
+	f32Knob("hammer_knock_decay_ms", 0, 2000, true, func(p *piano.Params) *float32 { return &p.HammerKnockDecayMs }),
+	f32Knob("body_dry", 0, inf, false, func(p *piano.Params) *float32 { return &p.BodyDryMix }),
+	f32Knob("body_gain", 0, inf, true, func(p *piano.Params) *float32 { return &p.BodyIRGain }),
+	f32Knob("room_wet", 0, inf, false, func(p *piano.Params) *float32 { return &p.RoomWetMix }),
+	f32Knob("room_gain", 0, inf, true, func(p *piano.Params) *float32 { return &p.RoomGain }),
+	f32Knob("ir_wet_mix", 0, inf, false, func(p *piano.Params) *float32 { return &p.IRWetMix }),
+	f32Knob("ir_dry_mix", 0, inf, false, func(p *piano.Params) *float32 { return &p.IRDryMix }),
+	f32Knob("ir_gain", 0, inf, true, func(p *piano.Params) *float32 { return &p.IRGain }),
+	f32Knob("coupling_amount", 0, 1, false, func(p *piano.Params) *float32 { return &p.CouplingAmount }),
+	f32Knob("coupling_octave_gain", 0, inf, false, func(p *piano.Params) *float32 { return &p.CouplingOctaveGain }),
+	f32Knob("coupling_fifth_gain", 0, inf, false, func(p *piano.Params) *float32 { return &p.CouplingFifthGain }),
+	f32Knob("coupling_max_force", 0, inf, true, func(p *piano.Params) *float32 { return &p.CouplingMaxForce }),
+	f32Knob("resonance_gain", 0, inf, false, func(p *piano.Params) *float32 { return &p.ResonanceGain }),
+	f32Knob("resonance_attack_ms", 0, inf, false, func(p *piano.Params) *float32 { return &p.ResonanceAttackMs }),
+	f32Knob("damper_engage_ms", 0, inf, false, func(p *piano.Params) *float32 { return &p.DamperEngageMs }),
+	f32Knob("damper_engage_register_slope", 0, inf, false, func(p *piano.Params) *float32 { return &p.DamperEngageRegisterSlope }),
+	f32Knob("damper_reflection", 0, 1, true, func(p *piano.Params) *float32 { return &p.DamperReflection }),
+	{Name: "modal_partials", Min: 1, Max: 32, IsInt: true, count: func(p *piano.Params) *int { return &p.ModalPartials }},
+	f32Knob("modal_gain_exponent", 0, inf, true, func(p *piano.Params) *float32 { return &p.ModalGainExponent }),
+	f32Knob("modal_excitation", 0, inf, true, func(p *piano.Params) *float32 { return &p.ModalExcitation }),
+	f32Knob("modal_undamped_loss", 0, inf, true, func(p *piano.Params) *float32 { return &p.ModalUndampedLoss }),
+	f32Knob("modal_damped_loss", 0, inf, true, func(p *piano.Params) *float32 { return &p.ModalDampedLoss }),
+
+	noteKnob("loss", 0, 1, true, false, func(np *piano.NoteParams) *float32 { return &np.Loss }),
+	noteKnob("inharmonicity", 0, inf, false, false, func(np *piano.NoteParams) *float32 { return &np.Inharmonicity }),
+	noteKnob("strike_position", 0, 1, true, true, func(np *piano.NoteParams) *float32 { return &np.StrikePosition }),
+	noteKnob("hammer_stiffness_scale", 0, inf, true, false, func(np *piano.NoteParams) *float32 { return &np.HammerStiffnessScale }),
+	noteKnob("hammer_exponent_scale", 0, inf, true, false, func(np *piano.NoteParams) *float32 { return &np.HammerExponentScale }),
+	noteKnob("hammer_contact_time_scale", 0, inf, true, false, func(np *piano.NoteParams) *float32 { return &np.HammerContactTimeScale }),
+}
+
+func init() {
+	for b := range piano.HammerKnockBands {
+		paramKnobs = append(paramKnobs, f32Knob(HammerKnockBandKnobName(b), -60, 24, false,
+			func(p *piano.Params) *float32 { return &p.HammerKnockBandsDB[b] }))
+	}
+}
+
+// HammerKnockBandKnobName returns the name of the knob of hammer knock band
+// b, hammer_knock_bands_db.<b>.
+func HammerKnockBandKnobName(b int) string {
+	return "hammer_knock_bands_db." + strconv.Itoa(b)
+}
+
+// PerNoteKnobName returns the name of the per-note knob field of note.
+func PerNoteKnobName(note int, field string) string {
+	return "per_note." + strconv.Itoa(note) + "." + field
+}
+
+// LookupParamKnob returns the Params knob called name and, for a per-note
+// knob, its note. Per-note names must spell the note in canonical form
+// (per_note.60.loss, not per_note.060.loss).
+func LookupParamKnob(name string) (k ParamKnob, note int, ok bool) {
+	field := name
+	note = -1
+	if rest, isNote := strings.CutPrefix(name, "per_note."); isNote {
+		num, f, found := strings.Cut(rest, ".")
+		n, err := strconv.Atoi(num)
+		if !found || err != nil || n < 0 || n > 127 || strconv.Itoa(n) != num {
+			return ParamKnob{}, 0, false
+		}
+		field, note = f, n
+	}
+	for _, k := range paramKnobs {
+		if k.Name == field && k.PerNote == (note >= 0) {
+			return k, note, true
+		}
+	}
+	return ParamKnob{}, 0, false
+}
+
+// Check reports whether v is a value the field accepts.
+func (k ParamKnob) Check(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("value %g is not finite", v)
+	}
+	if v < k.Min || v > k.Max || (k.MinOpen && v == k.Min) || (k.MaxOpen && v == k.Max) {
+		return fmt.Errorf("value %g outside %s", v, k.bounds())
+	}
+	return nil
+}
+
+// bounds formats the accepted range, e.g. (0,1] or [0,+Inf).
+func (k ParamKnob) bounds() string {
+	lo, hi := "[", "]"
+	if k.MinOpen || math.IsInf(k.Min, -1) {
+		lo = "("
+	}
+	if k.MaxOpen || math.IsInf(k.Max, 1) {
+		hi = ")"
+	}
+	return fmt.Sprintf("%s%g,%g%s", lo, k.Min, k.Max, hi)
+}
+
+// SetParamKnob sets the Params field of the knob called name to v, rounding
+// integer fields; unknown names and values the field does not accept are
+// errors.
+func SetParamKnob(p *piano.Params, name string, v float64) error {
+	k, note, ok := LookupParamKnob(name)
+	if !ok {
+		return fmt.Errorf("unknown knob %q", name)
+	}
+	if k.IsInt {
+		v = math.Round(v)
+	}
+	if err := k.Check(v); err != nil {
+		return fmt.Errorf("knob %s: %w", name, err)
+	}
+	switch {
+	case k.PerNote:
+		if p.PerNote == nil {
+			p.PerNote = make(map[int]*piano.NoteParams)
+		}
+		np := p.PerNote[note]
+		if np == nil {
+			np = &piano.NoteParams{}
+			p.PerNote[note] = np
+		}
+		*k.note(np) = float32(v)
+	case k.count != nil:
+		*k.count(p) = int(v)
+	default:
+		*k.param(p) = float32(v)
+	}
+	return nil
+}
+
+// SetParamKnobs sets several knobs with SetParamKnob, in name order.
+func SetParamKnobs(p *piano.Params, knobs map[string]float64) error {
+	names := make([]string, 0, len(knobs))
+	for name := range knobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := SetParamKnob(p, name, knobs[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxLinearSpan is the widest Max/Min ratio of a linearly searched range
+// with Min > 0; wider ones spend almost the whole search on the top decade
+// and must use a log scale.
+const maxLinearSpan = 1000
+
+// CheckSearchRange guards the [min,max] search range of a knob: the bounds
+// must be finite and ordered, log-scaled ranges positive, linear ranges no
+// wider than maxLinearSpan, and the range of a Params knob must lie within
+// the values its field accepts.
+func CheckSearchRange(name string, min, max float64, logScale bool) error {
+	switch {
+	case math.IsNaN(min) || math.IsNaN(max) || math.IsInf(min, 0) || math.IsInf(max, 0):
+		return fmt.Errorf("knob %s: range [%g,%g] is not finite", name, min, max)
+	case max <= min:
+		return fmt.Errorf("knob %s: range [%g,%g] is empty", name, min, max)
+	case logScale && min <= 0:
+		return fmt.Errorf("knob %s: log-scaled range [%g,%g] must be positive", name, min, max)
+	case !logScale && min > 0 && max/min > maxLinearSpan:
+		return fmt.Errorf("knob %s: linear range [%g,%g] spans more than %dx; use a log scale", name, min, max, maxLinearSpan)
+	}
+	if k, _, ok := LookupParamKnob(name); ok {
+		for _, v := range []float64{min, max} {
+			if err := k.Check(v); err != nil {
+				return fmt.Errorf("knob %s: search range [%g,%g]: %w", name, min, max, err)
+			}
+		}
+	}
+	return nil
+}
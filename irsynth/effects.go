@@ -0,0 +1,380 @@
+package irsynth
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+// EffectsConfig controls creative post-processing of a stereo IR. The
+// result is again a plain stereo IR, so the regular convolvers can load it.
+// Effects run in the order speed, freeze, shimmer, reverse.
+type EffectsConfig struct {
+	// Speed resamples the IR as if played back at this speed: 0.5 makes it
+	// twice as long and an octave lower (1 = unchanged).
+	Speed float64
+
+	// FreezeHz freezes the spectrum at and above this frequency: the
+	// magnitudes captured at FreezeStartS are sustained with random phases
+	// until the end of the IR (0 = off). FreezeLevel scales the frozen layer.
+	FreezeHz     float64
+	FreezeStartS float64
+	FreezeLevel  float64
+
+	// ShimmerSemitones feeds pitch-shifted copies of the IR back into its
+	// tail, each ShimmerDelayS later and ShimmerFeedback quieter than the
+	// previous one (0 = off; 12 gives the classic octave-up shimmer).
+	ShimmerSemitones float64
+	ShimmerFeedback  float64
+	ShimmerDelayS    float64
+
+	// Reverse plays the IR backwards, so the tail swells into the direct
+	// sound.
+	Reverse bool
+
+	// FadeOutS fades the end of frozen or shimmered IRs, which otherwise
+	// stop abruptly.
+	FadeOutS      float64
+	Seed          int64
+	NormalizePeak float64
+}
+
+// DefaultEffectsConfig returns a configuration with every effect off.
+func DefaultEffectsConfig() EffectsConfig {
+	return EffectsConfig{
+		Speed:           1.0,
+		FreezeStartS:    0.05,
+		FreezeLevel:     1.0,
+		ShimmerFeedback: 0.5,
+		ShimmerDelayS:   0.05,
+		FadeOutS:        0.05,
+		Seed:            1,
+		NormalizePeak:   0.9,
+	}
+}
+
+// Enabled reports whether any effect changes the IR.
+func (c *EffectsConfig) Enabled() bool {
+	return c.Speed != 1 || c.FreezeHz > 0 || c.ShimmerSemitones != 0 || c.Reverse
+}
+
+func (c *EffectsConfig) Validate() error {
+	if c.Speed < 0.125 || c.Speed > 1 {
+		return fmt.Errorf("speed must be in [0.125,1]")
+	}
+	if c.FreezeHz < 0 {
+		return fmt.Errorf("freeze Hz must be >= 0")
+	}
+	if c.FreezeStartS < 0 {
+		return fmt.Errorf("freeze start must be >= 0")
+	}
+	if c.FreezeLevel <= 0 {
+		return fmt.Errorf("freeze level must be > 0")
+	}
+	if c.ShimmerSemitones < -24 || c.ShimmerSemitones > 24 {
+		return fmt.Errorf("shimmer semitones must be in [-24,24]")
+	}
+	if c.ShimmerFeedback < 0 || c.ShimmerFeedback > 0.95 {
+		return fmt.Errorf("shimmer feedback must be in [0,0.95]")
+	}
+	if c.ShimmerDelayS <= 0 {
+		return fmt.Errorf("shimmer delay must be > 0")
+	}
+	if c.FadeOutS < 0 {
+		return fmt.Errorf("fade-out must be >= 0")
+	}
+	if c.NormalizePeak <= 0 {
+		return fmt.Errorf("normalize peak must be > 0")
+	}
+	return nil
+}
+
+const (
+	freezeFrameS     = 0.04
+	shimmerGrainS    = 0.05
+	shimmerMaxPasses = 8
+	shimmerMinGain   = 1e-3
+	reverseFadeInS   = 0.005
+)
+
+// ApplyEffects applies cfg to a stereo IR and renormalizes the result.
+func ApplyEffects(left []float32, right []float32, sampleRate int, cfg EffectsConfig) ([]float32, []float32, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if sampleRate < 8000 {
+		return nil, nil, fmt.Errorf("sample rate too low: %d", sampleRate)
+	}
+	if len(left) != len(right) {
+		return nil, nil, fmt.Errorf("left/right length mismatch")
+	}
+	if len(left) == 0 {
+		return nil, nil, fmt.Errorf("empty IR")
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	channels := [][]float64{toFloat64(left), toFloat64(right)}
+	for c, x := range channels {
+		if cfg.Speed != 1 {
+			x = resampleSpeed(x, cfg.Speed)
+		}
+		if cfg.FreezeHz > 0 {
+			layer, err := freezeLayer(x, sampleRate, cfg, rng)
+			if err != nil {
+				return nil, nil, err
+			}
+			for i := range x {
+				x[i] += layer[i]
+			}
+		}
+		if cfg.ShimmerSemitones != 0 && cfg.ShimmerFeedback > 0 {
+			x = shimmer(x, sampleRate, cfg)
+		}
+		if cfg.FreezeHz > 0 || (cfg.ShimmerSemitones != 0 && cfg.ShimmerFeedback > 0) {
+			applyFadeOut(x, cfg.FadeOutS, sampleRate)
+		}
+		if cfg.Reverse {
+			for i, j := 0, len(x)-1; i < j; i, j = i+1, j-1 {
+				x[i], x[j] = x[j], x[i]
+			}
+			applyFadeIn(x, reverseFadeInS, sampleRate)
+		}
+		channels[c] = x
+	}
+
+	peak := math.Max(maxAbs(channels[0]), maxAbs(channels[1]))
+	if peak < 1e-12 {
+		peak = 1e-12
+	}
+	s := cfg.NormalizePeak / peak
+	n := len(channels[0])
+	outL := make([]float32, n)
+	outR := make([]float32, n)
+	for i := 0; i < n; i++ {
+		outL[i] = float32(channels[0][i] * s)
+		outR[i] = float32(channels[1][i] * s)
+	}
+	return outL, outR, nil
+}
+
+func toFloat64(x []float32) []float64 {
+	out := make([]float64, len(x))
+	for i, v := range x {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+// resampleSpeed stretches x by 1/speed with cubic Hermite interpolation.
+// Only slow-downs are supported, so no anti-aliasing filter is needed.
+func resampleSpeed(x []float64, speed float64) []float64 {
+	n := int(math.Round(float64(len(x)) / speed))
+	out := make([]float64, n)
+	at := func(i int) float64 {
+		if i < 0 || i >= len(x) {
+			return 0
+		}
+		return x[i]
+	}
+	for i := range out {
+		pos := float64(i) * speed
+		k := int(pos)
+		t := pos - float64(k)
+		out[i] = hermite(at(k-1), at(k), at(k+1), at(k+2), t)
+	}
+	return out
+}
+
+func hermite(xm1, x0, x1, x2, t float64) float64 {
+	c1 := 0.5 * (x1 - xm1)
+	c2 := xm1 - 2.5*x0 + 2*x1 - 0.5*x2
+	c3 := 0.5*(x2-xm1) + 1.5*(x0-x1)
+	return ((c3*t+c2)*t+c1)*t + x0
+}
+
+// freezeLayer captures the magnitude spectrum above cfg.FreezeHz around
+// cfg.FreezeStartS and resynthesizes it with random phases (Hann windows,
+// 75% overlap) from the capture point to the end of x. The layer is scaled
+// to the captured band power times cfg.FreezeLevel.
+func freezeLayer(x []float64, sampleRate int, cfg EffectsConfig, rng *rand.Rand) ([]float64, error) {
+	layer := make([]float64, len(x))
+	nfft := 256
+	for nfft < int(freezeFrameS*float64(sampleRate)) {
+		nfft <<= 1
+	}
+	kFreeze := int(math.Ceil(cfg.FreezeHz * float64(nfft) / float64(sampleRate)))
+	if kFreeze > nfft/2 {
+		return layer, nil
+	}
+	kFreeze = max(kFreeze, 1)
+	plan, err := algofft.NewPlanReal64(nfft)
+	if err != nil {
+		return nil, err
+	}
+
+	hann := make([]float64, nfft)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(nfft))
+	}
+	start := int(cfg.FreezeStartS*float64(sampleRate)) - nfft/2
+	start = max(0, min(start, len(x)-1))
+
+	frame := make([]float64, nfft)
+	for i := 0; i < nfft && start+i < len(x); i++ {
+		frame[i] = x[start+i] * hann[i]
+	}
+	spec := make([]complex128, nfft/2+1)
+	if err := plan.Forward(spec, frame); err != nil {
+		return nil, err
+	}
+	mags := make([]float64, len(spec))
+	var bandPow float64
+	for k := kFreeze; k < len(spec); k++ {
+		mags[k] = cmplx.Abs(spec[k])
+		bandPow += mags[k] * mags[k]
+	}
+	// One-sided Parseval, compensating the analysis window (mean Hann² = 3/8).
+	target := 2 * bandPow / (float64(nfft) * float64(nfft) * 0.375)
+	if target <= 0 {
+		return layer, nil
+	}
+
+	hop := nfft / 4
+	for pos := start; pos < len(x); pos += hop {
+		for k := range spec {
+			if mags[k] == 0 || k == nfft/2 {
+				spec[k] = 0
+				continue
+			}
+			spec[k] = cmplx.Rect(mags[k], 2*math.Pi*rng.Float64())
+		}
+		if err := plan.Inverse(frame, spec); err != nil {
+			return nil, err
+		}
+		for i := 0; i < nfft && pos+i < len(x); i++ {
+			layer[pos+i] += frame[i] * hann[i]
+		}
+	}
+
+	// Match the steady-state layer power (past the overlap-add ramp) to the
+	// captured band power.
+	steady := layer[min(start+nfft, len(layer)):]
+	if len(steady) == 0 {
+		steady = layer[start:]
+	}
+	var pow float64
+	for _, v := range steady {
+		pow += v * v
+	}
+	pow /= float64(len(steady))
+	if pow <= 0 {
+		return layer, nil
+	}
+	g := cfg.FreezeLevel * math.Sqrt(target/pow)
+	for i := range layer {
+		layer[i] *= g
+	}
+	return layer, nil
+}
+
+// shimmer adds pitch-shifted, delayed feedback passes of x: pass k is pass
+// k-1 shifted by cfg.ShimmerSemitones, delayed by cfg.ShimmerDelayS and
+// scaled by cfg.ShimmerFeedback, so upward shifts cascade into higher
+// octaves. Each pass is lowpassed below the shifted Nyquist first.
+func shimmer(x []float64, sampleRate int, cfg EffectsConfig) []float64 {
+	ratio := math.Pow(2, cfg.ShimmerSemitones/12)
+	delay := int(cfg.ShimmerDelayS * float64(sampleRate))
+	grain := int(shimmerGrainS * float64(sampleRate))
+	out := append([]float64(nil), x...)
+	pass := x
+	gain := 1.0
+	for k := 0; k < shimmerMaxPasses; k++ {
+		gain *= cfg.ShimmerFeedback
+		if gain < shimmerMinGain || delay >= len(x) {
+			break
+		}
+		src := pass
+		if ratio > 1 {
+			src = lowpass(pass, 0.45*float64(sampleRate)/ratio, sampleRate)
+		}
+		shifted := pitchShift(src, ratio, grain)
+		next := make([]float64, len(x))
+		copy(next[delay:], shifted)
+		for i := range next {
+			next[i] *= cfg.ShimmerFeedback
+			out[i] += next[i]
+		}
+		pass = next
+	}
+	return out
+}
+
+// pitchShift is a two-head delay-line pitch shifter: each head sweeps a
+// delay of up to grain samples at rate 1-ratio and the heads are
+// crossfaded with complementary Hann weights.
+func pitchShift(x []float64, ratio float64, grain int) []float64 {
+	out := make([]float64, len(x))
+	if grain < 2 {
+		return append(out[:0], x...)
+	}
+	w := float64(grain)
+	step := (1 - ratio) / w
+	phase := 0.0
+	read := func(pos float64) float64 {
+		k := int(math.Floor(pos))
+		t := pos - float64(k)
+		var a, b float64
+		if k >= 0 && k < len(x) {
+			a = x[k]
+		}
+		if k+1 >= 0 && k+1 < len(x) {
+			b = x[k+1]
+		}
+		return a + (b-a)*t
+	}
+	for n := range out {
+		for h := 0; h < 2; h++ {
+			p := phase + 0.5*float64(h)
+			p -= math.Floor(p)
+			weight := 0.5 - 0.5*math.Cos(2*math.Pi*p)
+			out[n] += weight * read(float64(n)-p*w)
+		}
+		phase += step
+		phase -= math.Floor(phase)
+	}
+	return out
+}
+
+// lowpass applies a Butterworth-Q biquad lowpass (RBJ cookbook).
+func lowpass(x []float64, cutoffHz float64, sampleRate int) []float64 {
+	w0 := 2 * math.Pi * cutoffHz / float64(sampleRate)
+	alpha := math.Sin(w0) / math.Sqrt2 // Q = 1/sqrt(2)
+	cw := math.Cos(w0)
+	a0 := 1 + alpha
+	b0 := (1 - cw) / 2 / a0
+	b1 := (1 - cw) / a0
+	b2 := b0
+	a1 := -2 * cw / a0
+	a2 := (1 - alpha) / a0
+	out := make([]float64, len(x))
+	var x1, x2, y1, y2 float64
+	for i, v := range x {
+		y := b0*v + b1*x1 + b2*x2 - a1*y1 - a2*y2
+		x2, x1 = x1, v
+		y2, y1 = y1, y
+		out[i] = y
+	}
+	return out
+}
+
+// applyFadeIn applies a cosine fade-in to the first fadeS seconds of buf.
+func applyFadeIn(buf []float64, fadeS float64, sampleRate int) {
+	fadeSamples := min(int(math.Round(fadeS*float64(sampleRate))), len(buf))
+	for i := 0; i < fadeSamples; i++ {
+		t := float64(i) / float64(fadeSamples)
+		buf[i] *= 0.5 * (1.0 - math.Cos(t*math.Pi))
+	}
+}
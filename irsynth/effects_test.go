@@ -0,0 +1,187 @@
+package irsynth
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+func testRoomIR(t *testing.T) ([]float32, []float32, int) {
+	t.Helper()
+	cfg := DefaultRoomConfig()
+	cfg.SampleRate = 48000
+	cfg.DurationS = 1.0
+	l, r, err := GenerateRoom(cfg)
+	if err != nil {
+		t.Fatalf("GenerateRoom: %v", err)
+	}
+	return l, r, cfg.SampleRate
+}
+
+// segmentRMS returns the RMS of x between the given times in seconds.
+func segmentRMS(x []float32, sampleRate int, fromS, toS float64) float64 {
+	a := int(fromS * float64(sampleRate))
+	b := min(int(toS*float64(sampleRate)), len(x))
+	var sum float64
+	for _, v := range x[a:b] {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(b-a))
+}
+
+// bandRMS returns the RMS magnitude of the bins of x between loHz and hiHz.
+func bandRMS(t *testing.T, x []float32, sampleRate int, loHz, hiHz float64) float64 {
+	t.Helper()
+	n := 1
+	for n < len(x) {
+		n <<= 1
+	}
+	plan, err := algofft.NewPlanReal64(n)
+	if err != nil {
+		t.Fatalf("fft plan: %v", err)
+	}
+	in := make([]float64, n)
+	for i, v := range x {
+		in[i] = float64(v)
+	}
+	spec := make([]complex128, n/2+1)
+	if err := plan.Forward(spec, in); err != nil {
+		t.Fatalf("fft: %v", err)
+	}
+	lo := int(loHz * float64(n) / float64(sampleRate))
+	hi := int(hiHz * float64(n) / float64(sampleRate))
+	var sum float64
+	for k := lo; k <= hi; k++ {
+		m := cmplx.Abs(spec[k])
+		sum += m * m
+	}
+	return math.Sqrt(sum / float64(hi-lo+1))
+}
+
+func TestApplyEffectsDefaultsOnlyRenormalize(t *testing.T) {
+	l, r, sr := testRoomIR(t)
+	cfg := DefaultEffectsConfig()
+	if cfg.Enabled() {
+		t.Fatalf("default effects config reports enabled effects")
+	}
+	cfg.NormalizePeak = 0.9
+	outL, outR, err := ApplyEffects(l, r, sr, cfg)
+	if err != nil {
+		t.Fatalf("ApplyEffects: %v", err)
+	}
+	for i := range l {
+		if math.Abs(float64(outL[i]-l[i])) > 1e-6 || math.Abs(float64(outR[i]-r[i])) > 1e-6 {
+			t.Fatalf("no-op effects changed sample %d", i)
+		}
+	}
+}
+
+// decayingTone is a test IR: a sine at freq decaying with time constant tauS.
+func decayingTone(n int, sampleRate int, freq float64, tauS float64) []float32 {
+	x := make([]float32, n)
+	for i := range x {
+		tt := float64(i) / float64(sampleRate)
+		x[i] = float32(math.Exp(-tt/tauS) * math.Sin(2*math.Pi*freq*tt))
+	}
+	return x
+}
+
+func TestApplyEffectsReverseAndHalfSpeed(t *testing.T) {
+	const sr = 48000
+	l := decayingTone(sr/2, sr, 1000, 0.1)
+	cfg := DefaultEffectsConfig()
+	cfg.Reverse = true
+	cfg.Speed = 0.5
+	outL, outR, err := ApplyEffects(l, l, sr, cfg)
+	if err != nil {
+		t.Fatalf("ApplyEffects: %v", err)
+	}
+	if len(outL) != 2*len(l) || len(outR) != len(outL) {
+		t.Fatalf("half speed length = %d, want %d", len(outL), 2*len(l))
+	}
+	// Reversed: the decay becomes a swell into the former onset.
+	head := segmentRMS(outL, sr, 0, 0.1)
+	tail := segmentRMS(outL, sr, 0.9, 1.0)
+	if tail < 10*head {
+		t.Fatalf("reversed IR does not swell: head %.4g, tail %.4g", head, tail)
+	}
+	// Half speed moves the tone an octave down.
+	if low, high := bandRMS(t, outL, sr, 450, 550), bandRMS(t, outL, sr, 950, 1050); low < 10*high {
+		t.Fatalf("half speed did not transpose down an octave: 500 Hz %.4g, 1 kHz %.4g", low, high)
+	}
+}
+
+func TestApplyEffectsFreezeSustainsHighBand(t *testing.T) {
+	l, r, sr := testRoomIR(t)
+	// Shorten the room tail so the high band has clearly died away late on.
+	for i := range l {
+		g := float32(math.Exp(-float64(i) / (0.08 * float64(sr))))
+		l[i] *= g
+		r[i] *= g
+	}
+	cfg := DefaultEffectsConfig()
+	cfg.FreezeHz = 2000
+	cfg.FadeOutS = 0.01
+	outL, _, err := ApplyEffects(l, r, sr, cfg)
+	if err != nil {
+		t.Fatalf("ApplyEffects: %v", err)
+	}
+	band := func(x []float32, fromS, toS, loHz, hiHz float64) float64 {
+		return bandRMS(t, x[int(fromS*float64(sr)):int(toS*float64(sr))], sr, loHz, hiHz)
+	}
+	if dry := band(l, 0.7, 0.9, 3000, 12000) / band(l, 0.05, 0.25, 3000, 12000); dry > 0.05 {
+		t.Fatalf("test IR high band does not decay: %.3f", dry)
+	}
+	// Frozen, the high band holds its level to the end.
+	if frozen := band(outL, 0.7, 0.9, 3000, 12000) / band(outL, 0.05, 0.25, 3000, 12000); frozen < 0.5 {
+		t.Fatalf("frozen high band decays: late/early %.3f", frozen)
+	}
+	// The band below the threshold still decays.
+	if low := band(outL, 0.7, 0.9, 50, 1000) / band(outL, 0.05, 0.25, 50, 1000); low > 0.1 {
+		t.Fatalf("freeze sustained the low band: late/early %.3f", low)
+	}
+}
+
+func TestApplyEffectsShimmerAddsOctaveUp(t *testing.T) {
+	const sr = 48000
+	// A decaying 500 Hz tone as a stand-in IR: shimmer must add energy at 1 kHz.
+	n := sr
+	l := decayingTone(n, sr, 500, 0.4)
+	cfg := DefaultEffectsConfig()
+	cfg.ShimmerSemitones = 12
+	cfg.ShimmerFeedback = 0.6
+	outL, outR, err := ApplyEffects(l, l, sr, cfg)
+	if err != nil {
+		t.Fatalf("ApplyEffects: %v", err)
+	}
+	if len(outL) != n || len(outR) != n {
+		t.Fatalf("shimmer changed the IR length")
+	}
+	octave := bandRMS(t, outL, sr, 950, 1050) / bandRMS(t, outL, sr, 450, 550)
+	dry := bandRMS(t, l, sr, 950, 1050) / bandRMS(t, l, sr, 450, 550)
+	if octave < 0.1 || octave < 10*dry {
+		t.Fatalf("shimmer octave ratio %.4f (dry %.4f)", octave, dry)
+	}
+	for i, v := range outL {
+		if math.IsNaN(float64(v)) || math.Abs(float64(v)) > 0.9+1e-6 {
+			t.Fatalf("bad shimmer sample %d: %f", i, v)
+		}
+	}
+}
+
+func TestEffectsConfigValidate(t *testing.T) {
+	for name, mutate := range map[string]func(*EffectsConfig){
+		"speed":    func(c *EffectsConfig) { c.Speed = 2 },
+		"freeze":   func(c *EffectsConfig) { c.FreezeHz = -1 },
+		"feedback": func(c *EffectsConfig) { c.ShimmerFeedback = 1 },
+		"shift":    func(c *EffectsConfig) { c.ShimmerSemitones = 36 },
+	} {
+		cfg := DefaultEffectsConfig()
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package irsynth
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+// ExtractConfig controls IR estimation from a recording and a dry proxy.
+type ExtractConfig struct {
+	SampleRate int
+	DurationS  float64 // Length of the extracted IR
+
+	// RegularizationDB sets the Tikhonov floor relative to the peak dry power
+	// spectrum. Bins where the dry proxy has little energy are suppressed
+	// instead of being amplified into noise.
+	RegularizationDB float64
+
+	// WindowDecayS is the time constant of the exponential window applied to
+	// both signals before deconvolution and removed from the IR afterwards.
+	// It de-emphasizes the noisy, poorly-matched late part of the recording
+	// (0 = off).
+	WindowDecayS float64
+
+	// OnsetThresholdDB trims leading silence of both signals (relative to
+	// their peak) so the IR starts at the direct path.
+	OnsetThresholdDB float64
+
+	FadeOutS      float64
+	NormalizePeak float64
+}
+
+func DefaultExtractConfig() ExtractConfig {
+	return ExtractConfig{
+		SampleRate:       48000,
+		DurationS:        0.25,
+		RegularizationDB: -40,
+		WindowDecayS:     1.5,
+		OnsetThresholdDB: -50,
+		FadeOutS:         0.01,
+		NormalizePeak:    0.9,
+	}
+}
+
+func (c *ExtractConfig) Validate() error {
+	if c.SampleRate < 8000 {
+		return fmt.Errorf("sample rate too low: %d", c.SampleRate)
+	}
+	if c.DurationS <= 0 {
+		return fmt.Errorf("duration must be > 0")
+	}
+	if c.RegularizationDB >= 0 {
+		return fmt.Errorf("regularization dB must be < 0")
+	}
+	if c.WindowDecayS < 0 {
+		return fmt.Errorf("window decay must be >= 0")
+	}
+	if c.OnsetThresholdDB >= 0 {
+		return fmt.Errorf("onset threshold dB must be < 0")
+	}
+	if c.FadeOutS < 0 {
+		return fmt.Errorf("fade-out must be >= 0")
+	}
+	if c.NormalizePeak <= 0 {
+		return fmt.Errorf("normalize peak must be > 0")
+	}
+	return nil
+}
+
+// Extract estimates the IR h such that recorded ≈ dry * h using regularized
+// frequency-domain deconvolution. Both signals must share cfg.SampleRate.
+func Extract(recorded []float64, dry []float64, cfg ExtractConfig) ([]float32, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	rec := trimOnset(recorded, cfg.OnsetThresholdDB)
+	src := trimOnset(dry, cfg.OnsetThresholdDB)
+	if len(rec) == 0 || len(src) == 0 {
+		return nil, fmt.Errorf("recorded and dry signals must be non-silent")
+	}
+
+	n := int(math.Round(cfg.DurationS * float64(cfg.SampleRate)))
+	if n < 1 {
+		n = 1
+	}
+
+	nfft := 1
+	for nfft < len(rec)+len(src) {
+		nfft <<= 1
+	}
+	plan, err := algofft.NewPlanReal64(nfft)
+	if err != nil {
+		return nil, err
+	}
+
+	inRec := make([]float64, nfft)
+	inSrc := make([]float64, nfft)
+	copy(inRec, rec)
+	copy(inSrc, src)
+	if cfg.WindowDecayS > 0 {
+		applyExpWindow(inRec, -1.0/(cfg.WindowDecayS*float64(cfg.SampleRate)))
+		applyExpWindow(inSrc, -1.0/(cfg.WindowDecayS*float64(cfg.SampleRate)))
+	}
+
+	specRec := make([]complex128, nfft/2+1)
+	specSrc := make([]complex128, nfft/2+1)
+	if err := plan.Forward(specRec, inRec); err != nil {
+		return nil, err
+	}
+	if err := plan.Forward(specSrc, inSrc); err != nil {
+		return nil, err
+	}
+
+	maxPow := 0.0
+	for _, v := range specSrc {
+		if p := real(v)*real(v) + imag(v)*imag(v); p > maxPow {
+			maxPow = p
+		}
+	}
+	eps := maxPow * math.Pow(10, cfg.RegularizationDB/10)
+	if eps <= 0 {
+		eps = 1e-20
+	}
+	for i := range specRec {
+		d := specSrc[i]
+		p := real(d)*real(d) + imag(d)*imag(d)
+		specRec[i] = specRec[i] * cmplx.Conj(d) / complex(p+eps, 0)
+	}
+
+	h := make([]float64, nfft)
+	if err := plan.Inverse(h, specRec); err != nil {
+		return nil, err
+	}
+	if n > nfft {
+		n = nfft
+	}
+	h = h[:n]
+	if cfg.WindowDecayS > 0 {
+		applyExpWindow(h, 1.0/(cfg.WindowDecayS*float64(cfg.SampleRate)))
+	}
+	applyFadeOut(h, cfg.FadeOutS, cfg.SampleRate)
+
+	peak := maxAbs(h)
+	if peak < 1e-12 {
+		return nil, fmt.Errorf("extracted IR is silent")
+	}
+	s := cfg.NormalizePeak / peak
+	out := make([]float32, n)
+	for i, v := range h {
+		out[i] = float32(v * s)
+	}
+	return out, nil
+}
+
+// applyExpWindow multiplies x[i] by exp(rate*i).
+func applyExpWindow(x []float64, rate float64) {
+	g := 1.0
+	step := math.Exp(rate)
+	for i := range x {
+		x[i] *= g
+		g *= step
+	}
+}
+
+// trimOnset drops samples before the first one reaching thresholdDB below peak.
+func trimOnset(x []float64, thresholdDB float64) []float64 {
+	peak := maxAbs(x)
+	if peak <= 0 {
+		return nil
+	}
+	limit := peak * math.Pow(10, thresholdDB/20)
+	for i, v := range x {
+		if math.Abs(v) >= limit {
+			return x[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package irsynth
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestExtractRecoversKnownIR(t *testing.T) {
+	const sr = 16000
+	rng := rand.New(rand.NewSource(7))
+	dry := make([]float64, sr/2)
+	dry[0] = 1
+	for i := 1; i < len(dry); i++ {
+		dry[i] = rng.NormFloat64() * 0.3 * math.Exp(-float64(i)/float64(sr/8))
+	}
+	ir := make([]float64, 400)
+	ir[0] = 1
+	addModeRec(ir, 0.5, 220, 0.3, math.Exp(-1.0/(0.005*sr)), sr)
+	ir[37] += 0.4
+
+	rec := make([]float64, len(dry)+len(ir)-1)
+	for i, x := range dry {
+		for j, h := range ir {
+			rec[i+j] += x * h
+		}
+	}
+	for i := range rec {
+		rec[i] += 1e-5 * rng.NormFloat64()
+	}
+
+	cfg := DefaultExtractConfig()
+	cfg.SampleRate = sr
+	cfg.DurationS = float64(len(ir)) / sr
+	cfg.FadeOutS = 0
+	got, err := Extract(rec, dry, cfg)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(got) != len(ir) {
+		t.Fatalf("len = %d, want %d", len(got), len(ir))
+	}
+
+	var dot, ea, eb float64
+	for i := range ir {
+		dot += ir[i] * float64(got[i])
+		ea += ir[i] * ir[i]
+		eb += float64(got[i]) * float64(got[i])
+	}
+	if corr := dot / math.Sqrt(ea*eb); corr < 0.99 {
+		t.Fatalf("expected extracted IR to match known IR, correlation %f", corr)
+	}
+	if peak := maxAbs(float32To64(got)); math.Abs(peak-cfg.NormalizePeak) > 1e-6 {
+		t.Fatalf("expected peak %f, got %f", cfg.NormalizePeak, peak)
+	}
+}
+
+func TestExtractRejectsSilentInput(t *testing.T) {
+	cfg := DefaultExtractConfig()
+	if _, err := Extract(make([]float64, 64), []float64{1, 0, 0}, cfg); err == nil {
+		t.Fatal("expected error for silent recording")
+	}
+	cfg.RegularizationDB = 3
+	if _, err := Extract([]float64{1}, []float64{1}, cfg); err == nil {
+		t.Fatal("expected validation error for positive regularization")
+	}
+}
+
+func float32To64(x []float32) []float64 {
+	out := make([]float64, len(x))
+	for i, v := range x {
+		out[i] = float64(v)
+	}
+	return out
+}
@@ -0,0 +1,507 @@
+package irsynth
+
+import (
+	"fmt"
+	"math"
+)
+
+// InspectBandsHz are the octave-band centres Inspect reports RT60 for.
+var InspectBandsHz = []float64{125, 250, 500, 1000, 2000, 4000, 8000}
+
+const (
+	// clipLevel is the magnitude at and above which samples count as clipped.
+	clipLevel = 0.999
+	// inspectOnsetDB locates the direct sound relative to the peak (ISO 3382).
+	inspectOnsetDB = -20.0
+	// inspectBlockS is the block length of the energy envelope used to find
+	// the noise floor and the tail end.
+	inspectBlockS = 0.01
+)
+
+// BandDecay is the reverberation time of one octave band.
+type BandDecay struct {
+	CenterHz float64 `json:"center_hz"`
+	RT60S    float64 `json:"rt60_s"`
+	Method   string  `json:"method,omitempty"`
+}
+
+// Report summarizes an IR so it can be validated before use in a preset.
+// RT60 values are extrapolated from the Schroeder decay curve over -5..-35 dB
+// (T30) or, with less dynamic range, -5..-25 dB (T20); they are 0 with an
+// empty method when neither range is available.
+type Report struct {
+	SampleRate int     `json:"sample_rate"`
+	Channels   int     `json:"channels"`
+	Frames     int     `json:"frames"`
+	DurationS  float64 `json:"duration_s"`
+	PeakDBFS   float64 `json:"peak_dbfs"`
+	OnsetS     float64 `json:"onset_s"`
+
+	// DCOffset is the mean of the last 10% of each channel, where the
+	// response has died away and only a constant offset remains.
+	DCOffset       []float64 `json:"dc_offset"`
+	ClippedSamples []int     `json:"clipped_samples"`
+
+	// StereoCorrelation is the zero-lag correlation of the first two
+	// channels after the onset (1 for mono IRs).
+	StereoCorrelation float64 `json:"stereo_correlation"`
+
+	// C50DB and C80DB are the early/late energy ratios with the boundary
+	// 50 ms and 80 ms after the onset, limited to +-100 dB for IRs that end
+	// before the boundary.
+	C50DB float64 `json:"c50_db"`
+	C80DB float64 `json:"c80_db"`
+
+	// TailLevelDB is the level of the last 10% of the IR relative to the
+	// loudest envelope block: the noise floor, or how far the IR decays.
+	TailLevelDB float64     `json:"tail_level_db"`
+	RT60S       float64     `json:"rt60_s"`
+	RT60Method  string      `json:"rt60_method,omitempty"`
+	Bands       []BandDecay `json:"bands"`
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Inspect measures an IR given as one slice per channel.
+func Inspect(channels [][]float64, sampleRate int) (Report, error) {
+	if err := checkChannels(channels, sampleRate); err != nil {
+		return Report{}, err
+	}
+	n := len(channels[0])
+	sr := float64(sampleRate)
+	rep := Report{
+		SampleRate:     sampleRate,
+		Channels:       len(channels),
+		Frames:         n,
+		DurationS:      float64(n) / sr,
+		DCOffset:       make([]float64, len(channels)),
+		ClippedSamples: make([]int, len(channels)),
+	}
+
+	peak := 0.0
+	for c, x := range channels {
+		for _, v := range x {
+			if math.Abs(v) >= clipLevel {
+				rep.ClippedSamples[c]++
+			}
+		}
+		rep.DCOffset[c] = tailMean(x)
+		peak = math.Max(peak, maxAbs(x))
+	}
+	if peak == 0 {
+		return rep, fmt.Errorf("IR is silent")
+	}
+	rep.PeakDBFS = 20 * math.Log10(peak)
+
+	onset := onsetIndex(channels, peak, inspectOnsetDB)
+	rep.OnsetS = float64(onset) / sr
+
+	energy := channelEnergy(channels, onset)
+	rep.C50DB = clarityDB(energy, int(0.05*sr))
+	rep.C80DB = clarityDB(energy, int(0.08*sr))
+	rep.TailLevelDB = tailLevelDB(energy, sampleRate)
+	rep.RT60S, rep.RT60Method = decayTime(energy, sampleRate)
+
+	rep.StereoCorrelation = 1
+	if len(channels) > 1 {
+		rep.StereoCorrelation = correlation(channels[0][onset:], channels[1][onset:])
+	}
+
+	for _, fc := range InspectBandsHz {
+		if fc*math.Sqrt2 >= 0.5*sr {
+			break
+		}
+		band := make([][]float64, len(channels))
+		for c, x := range channels {
+			band[c] = octaveBand(x, fc, sampleRate)
+		}
+		rt, method := decayTime(channelEnergy(band, onset), sampleRate)
+		rep.Bands = append(rep.Bands, BandDecay{CenterHz: fc, RT60S: rt, Method: method})
+	}
+
+	rep.Warnings = inspectWarnings(rep, channels, peak)
+	return rep, nil
+}
+
+func inspectWarnings(rep Report, channels [][]float64, peak float64) []string {
+	var w []string
+	for c, k := range rep.ClippedSamples {
+		if k > 0 {
+			w = append(w, fmt.Sprintf("channel %d: %d clipped samples", c, k))
+		}
+	}
+	for c, dc := range rep.DCOffset {
+		if math.Abs(dc) > 1e-3*peak {
+			w = append(w, fmt.Sprintf("channel %d: DC offset %.2g", c, dc))
+		}
+	}
+	if rep.OnsetS > 0.01 {
+		w = append(w, fmt.Sprintf("%.1f ms of leading silence", 1000*rep.OnsetS))
+	}
+	for c, x := range channels {
+		if math.Abs(x[len(x)-1]) > 1e-3*peak {
+			w = append(w, fmt.Sprintf("channel %d: ends abruptly (click)", c))
+		}
+	}
+	if rep.TailLevelDB > -40 {
+		w = append(w, fmt.Sprintf("tail only decays to %.1f dB", rep.TailLevelDB))
+	}
+	if rep.RT60Method == "" {
+		w = append(w, "not enough decay range to measure RT60")
+	}
+	if rep.Channels > 1 && rep.StereoCorrelation > 0.95 {
+		w = append(w, fmt.Sprintf("stereo channels nearly identical (correlation %.3f)", rep.StereoCorrelation))
+	}
+	return w
+}
+
+// FixConfig controls clean-up of an IR before it is used in a preset.
+type FixConfig struct {
+	// OnsetThresholdDB trims leading silence up to the first sample within
+	// this level of the peak, keeping PreRollS ahead of it.
+	OnsetThresholdDB float64
+	PreRollS         float64
+
+	// TailThresholdDB trims the tail where the envelope falls below this
+	// level relative to its peak, or into a flat noise floor (0 = off).
+	TailThresholdDB float64
+
+	// RemoveDC subtracts the DC offset measured in the tail.
+	RemoveDC bool
+
+	// FadeInS and FadeOutS are cosine fades that de-click the trimmed ends.
+	FadeInS       float64
+	FadeOutS      float64
+	NormalizePeak float64
+}
+
+// DefaultFixConfig returns conservative clean-up settings.
+func DefaultFixConfig() FixConfig {
+	return FixConfig{
+		OnsetThresholdDB: -40.0,
+		PreRollS:         0.0005,
+		TailThresholdDB:  -90.0,
+		RemoveDC:         true,
+		FadeInS:          0.0005,
+		FadeOutS:         0.05,
+		NormalizePeak:    0.9,
+	}
+}
+
+func (c *FixConfig) Validate() error {
+	if c.OnsetThresholdDB >= 0 {
+		return fmt.Errorf("onset threshold must be < 0 dB")
+	}
+	if c.PreRollS < 0 {
+		return fmt.Errorf("pre-roll must be >= 0")
+	}
+	if c.TailThresholdDB > 0 {
+		return fmt.Errorf("tail threshold must be <= 0 dB")
+	}
+	if c.FadeInS < 0 {
+		return fmt.Errorf("fade-in must be >= 0")
+	}
+	if c.FadeOutS < 0 {
+		return fmt.Errorf("fade-out must be >= 0")
+	}
+	if c.NormalizePeak <= 0 {
+		return fmt.Errorf("normalize peak must be > 0")
+	}
+	return nil
+}
+
+// Fix removes DC, trims leading silence and the noise tail, fades both ends
+// and normalizes the peak. Channels are trimmed together, so their relative
+// timing is preserved.
+func Fix(channels [][]float64, sampleRate int, cfg FixConfig) ([][]float64, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkChannels(channels, sampleRate); err != nil {
+		return nil, err
+	}
+
+	work := make([][]float64, len(channels))
+	for c, x := range channels {
+		work[c] = append([]float64(nil), x...)
+		if cfg.RemoveDC {
+			mean := tailMean(x)
+			for i := range work[c] {
+				work[c][i] -= mean
+			}
+		}
+	}
+
+	peak := 0.0
+	for _, x := range work {
+		peak = math.Max(peak, maxAbs(x))
+	}
+	if peak < 1e-12 {
+		return nil, fmt.Errorf("IR is silent")
+	}
+
+	start := onsetIndex(work, peak, cfg.OnsetThresholdDB) - int(cfg.PreRollS*float64(sampleRate))
+	start = max(start, 0)
+	end := len(work[0])
+	if cfg.TailThresholdDB < 0 {
+		end = start + tailEnd(channelEnergy(work, start), sampleRate, cfg.TailThresholdDB)
+	}
+
+	for c, x := range work {
+		x = x[start:end]
+		applyFadeIn(x, cfg.FadeInS, sampleRate)
+		applyFadeOut(x, cfg.FadeOutS, sampleRate)
+		work[c] = x
+	}
+
+	peak = 0
+	for _, x := range work {
+		peak = math.Max(peak, maxAbs(x))
+	}
+	if peak < 1e-12 {
+		peak = 1e-12
+	}
+	s := cfg.NormalizePeak / peak
+	for _, x := range work {
+		for i := range x {
+			x[i] *= s
+		}
+	}
+	return work, nil
+}
+
+func checkChannels(channels [][]float64, sampleRate int) error {
+	if sampleRate < 8000 {
+		return fmt.Errorf("sample rate too low: %d", sampleRate)
+	}
+	if len(channels) == 0 || len(channels[0]) == 0 {
+		return fmt.Errorf("empty IR")
+	}
+	for _, x := range channels[1:] {
+		if len(x) != len(channels[0]) {
+			return fmt.Errorf("channel length mismatch")
+		}
+	}
+	return nil
+}
+
+// onsetIndex returns the first sample of any channel within thresholdDB of peak.
+func onsetIndex(channels [][]float64, peak float64, thresholdDB float64) int {
+	limit := peak * math.Pow(10, thresholdDB/20)
+	onset := len(channels[0])
+	for _, x := range channels {
+		for i, v := range x[:onset] {
+			if math.Abs(v) >= limit {
+				onset = i
+				break
+			}
+		}
+	}
+	return onset
+}
+
+// tailMean returns the mean of the last 10% of x.
+func tailMean(x []float64) float64 {
+	tail := x[len(x)-max(len(x)/10, 1):]
+	var sum float64
+	for _, v := range tail {
+		sum += v
+	}
+	return sum / float64(len(tail))
+}
+
+// channelEnergy returns the per-sample energy summed over channels from start.
+func channelEnergy(channels [][]float64, start int) []float64 {
+	e := make([]float64, len(channels[0])-start)
+	for _, x := range channels {
+		for i, v := range x[start:] {
+			e[i] += v * v
+		}
+	}
+	return e
+}
+
+func clarityDB(e []float64, boundary int) float64 {
+	boundary = min(boundary, len(e))
+	var early, late float64
+	for i, v := range e {
+		if i < boundary {
+			early += v
+		} else {
+			late += v
+		}
+	}
+	c := 10 * math.Log10(math.Max(early, 1e-30)/math.Max(late, 1e-30))
+	return math.Max(-100, math.Min(100, c))
+}
+
+// blockEnergy returns the mean energy of consecutive inspectBlockS blocks.
+func blockEnergy(e []float64, sampleRate int) ([]float64, int) {
+	size := max(int(inspectBlockS*float64(sampleRate)), 1)
+	blocks := make([]float64, 0, len(e)/size+1)
+	for i := 0; i < len(e); i += size {
+		j := min(i+size, len(e))
+		var sum float64
+		for _, v := range e[i:j] {
+			sum += v
+		}
+		blocks = append(blocks, sum/float64(j-i))
+	}
+	return blocks, size
+}
+
+// tailStats returns the loudest block energy and the mean energy of the last
+// 10% of e, and whether that tail is flat (a noise floor rather than decay).
+func tailStats(e []float64, sampleRate int) (peak float64, floor float64, flat bool) {
+	blocks, _ := blockEnergy(e, sampleRate)
+	for _, b := range blocks {
+		peak = math.Max(peak, b)
+	}
+	tail := e[len(e)-max(len(e)/10, 1):]
+	half := len(tail) / 2
+	var a, b float64
+	for i, v := range tail {
+		if i < half {
+			a += v
+		} else {
+			b += v
+		}
+	}
+	floor = (a + b) / float64(len(tail))
+	flat = half > 0 && a > 0 && b > 0 && math.Abs(10*math.Log10(a/b)) < 3
+	return peak, floor, flat
+}
+
+func tailLevelDB(e []float64, sampleRate int) float64 {
+	peak, floor, _ := tailStats(e, sampleRate)
+	return 10 * math.Log10(math.Max(floor, 1e-30)/math.Max(peak, 1e-30))
+}
+
+// tailEnd returns the length of e up to the last envelope block above
+// thresholdDB relative to the loudest block, or above a flat noise floor.
+func tailEnd(e []float64, sampleRate int, thresholdDB float64) int {
+	peak, floor, flat := tailStats(e, sampleRate)
+	limit := peak * math.Pow(10, thresholdDB/10)
+	if flat {
+		limit = math.Max(limit, 2*floor)
+	}
+	blocks, size := blockEnergy(e, sampleRate)
+	for k := len(blocks) - 1; k >= 0; k-- {
+		if blocks[k] > limit {
+			return min((k+1)*size, len(e))
+		}
+	}
+	return len(e)
+}
+
+// decayTime estimates RT60 from the Schroeder backward integral of e. A flat
+// noise floor is cut off before integration so it does not bend the curve.
+func decayTime(e []float64, sampleRate int) (float64, string) {
+	peak, floor, flat := tailStats(e, sampleRate)
+	dynamic := 10 * math.Log10(math.Max(peak, 1e-30)/math.Max(floor, 1e-30))
+	n := len(e)
+	if flat {
+		n = tailEnd(e, sampleRate, -200)
+	}
+
+	edc := make([]float64, n)
+	var acc float64
+	for i := n - 1; i >= 0; i-- {
+		acc += e[i]
+		edc[i] = acc
+	}
+	if acc <= 0 {
+		return 0, ""
+	}
+	for i := range edc {
+		edc[i] = 10 * math.Log10(math.Max(edc[i]/acc, 1e-30))
+	}
+
+	for _, r := range []struct {
+		method string
+		lo, hi float64
+	}{{"T30", -5, -35}, {"T20", -5, -25}} {
+		if dynamic < -r.hi+10 {
+			continue
+		}
+		if slope, ok := edcSlope(edc, r.lo, r.hi, sampleRate); ok {
+			return -60 / slope, r.method
+		}
+	}
+	return 0, ""
+}
+
+// edcSlope fits a line in dB/s to the decay curve between the first
+// crossings of lo and hi dB.
+func edcSlope(edc []float64, lo float64, hi float64, sampleRate int) (float64, bool) {
+	a, b := -1, -1
+	for i, v := range edc {
+		if a < 0 && v <= lo {
+			a = i
+		}
+		if v <= hi {
+			b = i
+			break
+		}
+	}
+	if a < 0 || b-a < 2 {
+		return 0, false
+	}
+	var st, sy, stt, sty float64
+	for i := a; i <= b; i++ {
+		t := float64(i) / float64(sampleRate)
+		st += t
+		sy += edc[i]
+		stt += t * t
+		sty += t * edc[i]
+	}
+	k := float64(b - a + 1)
+	den := k*stt - st*st
+	if den <= 0 {
+		return 0, false
+	}
+	slope := (k*sty - st*sy) / den
+	return slope, slope < 0
+}
+
+func correlation(a []float64, b []float64) float64 {
+	var ab, aa, bb float64
+	for i := range a {
+		ab += a[i] * b[i]
+		aa += a[i] * a[i]
+		bb += b[i] * b[i]
+	}
+	if aa <= 0 || bb <= 0 {
+		return 0
+	}
+	return ab / math.Sqrt(aa*bb)
+}
+
+// octaveBand isolates the octave around centerHz with cascaded second-order
+// high- and lowpasses at the band edges.
+func octaveBand(x []float64, centerHz float64, sampleRate int) []float64 {
+	y := highpass(highpass(x, centerHz/math.Sqrt2, sampleRate), centerHz/math.Sqrt2, sampleRate)
+	return lowpass(lowpass(y, centerHz*math.Sqrt2, sampleRate), centerHz*math.Sqrt2, sampleRate)
+}
+
+// highpass applies a Butterworth-Q biquad highpass (RBJ cookbook).
+func highpass(x []float64, cutoffHz float64, sampleRate int) []float64 {
+	w0 := 2 * math.Pi * cutoffHz / float64(sampleRate)
+	alpha := math.Sin(w0) / math.Sqrt2
+	cw := math.Cos(w0)
+	a0 := 1 + alpha
+	b0 := (1 + cw) / 2 / a0
+	b1 := -(1 + cw) / a0
+	b2 := b0
+	a1 := -2 * cw / a0
+	a2 := (1 - alpha) / a0
+	out := make([]float64, len(x))
+	var x1, x2, y1, y2 float64
+	for i, v := range x {
+		y := b0*v + b1*x1 + b2*x2 - a1*y1 - a2*y2
+		x2, x1 = x1, v
+		y2, y1 = y1, y
+		out[i] = y
+	}
+	return out
+}
@@ -0,0 +1,157 @@
+package irsynth
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noiseIR is a test IR: independent Gaussian noise per channel decaying
+// exponentially with the given RT60.
+func noiseIR(channels int, n int, sampleRate int, rt60S float64, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	tau := rt60S / (3 * math.Ln10) // amplitude time constant for -60 dB at rt60S
+	out := make([][]float64, channels)
+	for c := range out {
+		out[c] = make([]float64, n)
+		for i := range out[c] {
+			out[c][i] = 0.2 * rng.NormFloat64() * math.Exp(-float64(i)/(tau*float64(sampleRate)))
+		}
+	}
+	return out
+}
+
+func TestInspectMeasuresDecayingNoiseIR(t *testing.T) {
+	const sr = 48000
+	ir := noiseIR(2, 3*sr/2, sr, 0.8, 3)
+	rep, err := Inspect(ir, sr)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if rep.RT60Method != "T30" || math.Abs(rep.RT60S-0.8) > 0.08 {
+		t.Fatalf("broadband RT60 = %.3f s (%s), want 0.8 s (T30)", rep.RT60S, rep.RT60Method)
+	}
+	if len(rep.Bands) != len(InspectBandsHz) {
+		t.Fatalf("got %d bands, want %d", len(rep.Bands), len(InspectBandsHz))
+	}
+	for _, b := range rep.Bands {
+		if math.Abs(b.RT60S-0.8) > 0.1 {
+			t.Fatalf("%.0f Hz RT60 = %.3f s (%s), want 0.8 s", b.CenterHz, b.RT60S, b.Method)
+		}
+	}
+	if math.Abs(rep.StereoCorrelation) > 0.1 {
+		t.Fatalf("independent channels correlate: %.3f", rep.StereoCorrelation)
+	}
+	if rep.OnsetS > 0.001 {
+		t.Fatalf("onset %.4f s, want 0", rep.OnsetS)
+	}
+	// Energy falls by 60 dB per 0.8 s, so C80 is about 10*log10(10^0.6-1).
+	if want := 10 * math.Log10(math.Pow(10, 0.6)-1); math.Abs(rep.C80DB-want) > 1 {
+		t.Fatalf("C80 = %.2f dB, want %.2f", rep.C80DB, want)
+	}
+	for c := range ir {
+		if rep.ClippedSamples[c] != 0 || math.Abs(rep.DCOffset[c]) > 1e-3 {
+			t.Fatalf("channel %d: clipped %d, DC %g", c, rep.ClippedSamples[c], rep.DCOffset[c])
+		}
+	}
+}
+
+func TestInspectFlagsDCClippingAndSilence(t *testing.T) {
+	const sr = 48000
+	ir := noiseIR(1, sr, sr, 0.5, 5)
+	lead := sr / 20
+	x := make([]float64, lead+len(ir[0]))
+	for i, v := range ir[0] {
+		x[lead+i] = 8 * v
+	}
+	for i := range x {
+		x[i] = math.Max(-1, math.Min(1, x[i]+0.02))
+	}
+	rep, err := Inspect([][]float64{x}, sr)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if rep.ClippedSamples[0] == 0 {
+		t.Fatalf("clipping not detected")
+	}
+	if math.Abs(rep.DCOffset[0]-0.02) > 0.005 {
+		t.Fatalf("DC offset = %f, want about 0.02", rep.DCOffset[0])
+	}
+	if math.Abs(rep.OnsetS-0.05) > 0.002 {
+		t.Fatalf("onset = %.4f s, want 0.05", rep.OnsetS)
+	}
+	if rep.StereoCorrelation != 1 {
+		t.Fatalf("mono IR stereo correlation = %f", rep.StereoCorrelation)
+	}
+	if len(rep.Warnings) < 3 {
+		t.Fatalf("expected clipping, DC and silence warnings, got %q", rep.Warnings)
+	}
+}
+
+func TestFixTrimsFadesAndNormalizes(t *testing.T) {
+	const sr = 48000
+	rng := rand.New(rand.NewSource(11))
+	ir := noiseIR(2, sr, sr, 0.3, 7)
+	lead := sr / 10
+	fixedIn := make([][]float64, 2)
+	for c := range fixedIn {
+		x := make([]float64, lead+len(ir[c]))
+		copy(x[lead:], ir[c])
+		for i := range x {
+			// DC plus a flat noise floor about 70 dB below the peak.
+			x[i] += 0.01 + 1e-4*rng.NormFloat64()
+		}
+		fixedIn[c] = x
+	}
+
+	cfg := DefaultFixConfig()
+	out, err := Fix(fixedIn, sr, cfg)
+	if err != nil {
+		t.Fatalf("Fix: %v", err)
+	}
+	if len(out) != 2 || len(out[0]) != len(out[1]) {
+		t.Fatalf("Fix changed channel layout")
+	}
+	// Leading silence and most of the noise tail are gone.
+	if n := len(out[0]); n > sr/2 || n < sr/4 {
+		t.Fatalf("fixed length %d, input %d", n, len(fixedIn[0]))
+	}
+	rep, err := Inspect(out, sr)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if rep.OnsetS > 0.002 {
+		t.Fatalf("fixed IR onset %.4f s", rep.OnsetS)
+	}
+	if math.Abs(rep.PeakDBFS-20*math.Log10(cfg.NormalizePeak)) > 1e-6 {
+		t.Fatalf("fixed peak %.3f dBFS", rep.PeakDBFS)
+	}
+	for c, x := range out {
+		if x[0] != 0 || math.Abs(x[len(x)-1]) > 1e-6 {
+			t.Fatalf("channel %d: ends not faded: %g .. %g", c, x[0], x[len(x)-1])
+		}
+		if math.Abs(rep.DCOffset[c]) > 1e-3 {
+			t.Fatalf("channel %d: DC %g left after fix", c, rep.DCOffset[c])
+		}
+	}
+	if math.Abs(rep.RT60S-0.3) > 0.05 {
+		t.Fatalf("fixed IR RT60 %.3f s, want 0.3", rep.RT60S)
+	}
+}
+
+func TestInspectRejectsBadInput(t *testing.T) {
+	if _, err := Inspect(nil, 48000); err == nil {
+		t.Fatalf("expected error for empty IR")
+	}
+	if _, err := Inspect([][]float64{{1, 0}, {1}}, 48000); err == nil {
+		t.Fatalf("expected error for channel length mismatch")
+	}
+	if _, err := Inspect([][]float64{make([]float64, 16)}, 48000); err == nil {
+		t.Fatalf("expected error for silent IR")
+	}
+	cfg := DefaultFixConfig()
+	cfg.OnsetThresholdDB = 3
+	if _, err := Fix([][]float64{{1, 0.5}}, 48000, cfg); err == nil {
+		t.Fatalf("expected validation error")
+	}
+}
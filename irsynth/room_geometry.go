@@ -0,0 +1,190 @@
+package irsynth
+
+import (
+	"fmt"
+	"math"
+)
+
+// speedOfSound is the speed of sound in air at 20 °C in m/s.
+const speedOfSound = 343.0
+
+// maxImageSourceOrder bounds RoomGeometry.MaxOrder; the image count grows
+// with its cube.
+const maxImageSourceOrder = 12
+
+// RoomGeometry describes a shoebox room whose early reflections GenerateRoom
+// places with the image-source method (Allen & Berkley) instead of random
+// taps. Positions are in metres from the corner at the origin; the listener
+// faces +y, so +x is to the right.
+type RoomGeometry struct {
+	SizeM     [3]float64 // width (x), depth (y), height (z)
+	SourceM   [3]float64
+	ListenerM [3]float64
+
+	// Absorption is the wall absorption coefficient in [0,1] per
+	// InspectBandsHz octave band, from 125 Hz up; bands past the end of the
+	// slice reuse its last value.
+	Absorption []float64
+
+	// MaxOrder is the highest number of wall bounces rendered.
+	MaxOrder int
+}
+
+// DefaultRoomGeometry returns a mid-sized recording room with the piano
+// off centre and the listener a few metres in front of it.
+func DefaultRoomGeometry() RoomGeometry {
+	return RoomGeometry{
+		SizeM:      [3]float64{12, 9, 4.5},
+		SourceM:    [3]float64{4.5, 3, 1.1},
+		ListenerM:  [3]float64{6.5, 6.5, 1.6},
+		Absorption: []float64{0.10, 0.12, 0.15, 0.20, 0.25, 0.30, 0.35},
+		MaxOrder:   4,
+	}
+}
+
+func (g *RoomGeometry) Validate() error {
+	for i, axis := range []string{"width", "depth", "height"} {
+		if g.SizeM[i] <= 0 {
+			return fmt.Errorf("room %s must be > 0", axis)
+		}
+		if g.SourceM[i] < 0 || g.SourceM[i] > g.SizeM[i] || g.ListenerM[i] < 0 || g.ListenerM[i] > g.SizeM[i] {
+			return fmt.Errorf("source and listener must be inside the room (%s)", axis)
+		}
+	}
+	if len(g.Absorption) == 0 || len(g.Absorption) > len(InspectBandsHz) {
+		return fmt.Errorf("absorption needs 1 to %d bands", len(InspectBandsHz))
+	}
+	for _, a := range g.Absorption {
+		if a < 0 || a > 1 {
+			return fmt.Errorf("absorption must be in [0,1]")
+		}
+	}
+	if g.MaxOrder < 1 || g.MaxOrder > maxImageSourceOrder {
+		return fmt.Errorf("max order must be in [1,%d]", maxImageSourceOrder)
+	}
+	return nil
+}
+
+// imageSource is one reflection path as heard by the listener.
+type imageSource struct {
+	idx    int     // arrival after the direct sound in samples
+	amp    float64 // spherical spreading only
+	pan    float64 // -1 (left) .. 1 (right)
+	bounce int
+}
+
+// imageSources lists the reflections of g up to MaxOrder that arrive within
+// n samples of the direct sound.
+func (g RoomGeometry) imageSources(sampleRate int, n int) []imageSource {
+	direct := distance(g.SourceM, g.ListenerM)
+	var out []imageSource
+	order := g.MaxOrder
+	for nx := -order; nx <= order; nx++ {
+		for ny := -order; ny <= order; ny++ {
+			for nz := -order; nz <= order; nz++ {
+				bounce := absInt(nx) + absInt(ny) + absInt(nz)
+				if bounce == 0 || bounce > order {
+					continue
+				}
+				img := [3]float64{
+					imageCoord(nx, g.SizeM[0], g.SourceM[0]),
+					imageCoord(ny, g.SizeM[1], g.SourceM[1]),
+					imageCoord(nz, g.SizeM[2], g.SourceM[2]),
+				}
+				d := distance(img, g.ListenerM)
+				idx := int(math.Round((d - direct) / speedOfSound * float64(sampleRate)))
+				if idx < 0 || idx >= n {
+					continue
+				}
+				dx, dy := img[0]-g.ListenerM[0], img[1]-g.ListenerM[1]
+				pan := 0.0
+				if h := math.Hypot(dx, dy); h > 0 {
+					pan = dx / h
+				}
+				out = append(out, imageSource{idx: idx, amp: 1 / math.Max(d, 1), pan: pan, bounce: bounce})
+			}
+		}
+	}
+	return out
+}
+
+// addImageSourceReflections renders the early reflections of g into left
+// and right. Each octave band's taps are weighted by the wall reflection
+// factor sqrt(1-absorption) per bounce and band-limited with complementary
+// crossovers, so equal absorption in all bands leaves plain impulses.
+func addImageSourceReflections(left, right []float64, g RoomGeometry, sampleRate int, stereoWidth float64) {
+	images := g.imageSources(sampleRate, len(left))
+	if len(images) == 0 {
+		return
+	}
+	bands := crossoverBands(sampleRate)
+	bandL := make([]float64, len(left))
+	bandR := make([]float64, len(right))
+	for b := 0; b < bands; b++ {
+		alpha := g.Absorption[min(b, len(g.Absorption)-1)]
+		reflect := math.Sqrt(1 - alpha)
+		clear(bandL)
+		clear(bandR)
+		for _, im := range images {
+			amp := im.amp * math.Pow(reflect, float64(im.bounce))
+			pan := math.Max(-1, math.Min(1, im.pan*stereoWidth))
+			bandL[im.idx] += amp * (1.0 - 0.5*pan)
+			bandR[im.idx] += amp * (1.0 + 0.5*pan)
+		}
+		for i, v := range crossoverBand(bandL, b, bands, sampleRate) {
+			left[i] += v
+		}
+		for i, v := range crossoverBand(bandR, b, bands, sampleRate) {
+			right[i] += v
+		}
+	}
+}
+
+// crossoverBands is the number of InspectBandsHz bands whose lower crossover
+// lies safely below Nyquist; the last one takes everything above.
+func crossoverBands(sampleRate int) int {
+	bands := 1
+	for bands < len(InspectBandsHz) && InspectBandsHz[bands-1]*math.Sqrt2 < 0.45*float64(sampleRate) {
+		bands++
+	}
+	return bands
+}
+
+// crossoverBand returns band b of x out of a chain of complementary splits
+// at the octave-band edges: each split lowpasses what the bands below left,
+// so the bands of a signal sum back to it exactly.
+func crossoverBand(x []float64, b int, bands int, sampleRate int) []float64 {
+	rest := x
+	for k := 0; k < bands-1; k++ {
+		low := lowpass(rest, InspectBandsHz[k]*math.Sqrt2, sampleRate)
+		if k == b {
+			return low
+		}
+		high := make([]float64, len(rest))
+		for i := range rest {
+			high[i] = rest[i] - low[i]
+		}
+		rest = high
+	}
+	return rest
+}
+
+// imageCoord is the coordinate of the n-th image of pos along a room axis
+// of the given size; |n| is the number of walls it reflects off.
+func imageCoord(n int, size float64, pos float64) float64 {
+	if n%2 == 0 {
+		return float64(n)*size + pos
+	}
+	return float64(n+1)*size - pos
+}
+
+func distance(a, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
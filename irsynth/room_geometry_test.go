@@ -0,0 +1,99 @@
+package irsynth
+
+import (
+	"math"
+	"testing"
+)
+
+// earlyOnlyRoom is a 10x10x3 m room with the source 3 m left of the
+// listener, both at ear height: the floor and ceiling reflections arrive
+// first, from the left.
+func earlyOnlyRoom(absorption ...float64) RoomConfig {
+	cfg := DefaultRoomConfig()
+	cfg.SampleRate = 48000
+	cfg.DurationS = 0.2
+	cfg.LateLevel = 0
+	cfg.FadeOutS = 0
+	cfg.StereoWidth = 1
+	cfg.Geometry = &RoomGeometry{
+		SizeM:      [3]float64{10, 10, 3},
+		SourceM:    [3]float64{2, 5, 1.5},
+		ListenerM:  [3]float64{5, 5, 1.5},
+		Absorption: absorption,
+		MaxOrder:   3,
+	}
+	return cfg
+}
+
+func TestGenerateRoomGeometryPlacesFirstReflection(t *testing.T) {
+	cfg := earlyOnlyRoom(0.2)
+	l, r, err := GenerateRoom(cfg)
+	if err != nil {
+		t.Fatalf("GenerateRoom: %v", err)
+	}
+	// Floor/ceiling path sqrt(3²+3²) m against the 3 m direct path.
+	want := int(math.Round((math.Sqrt(9+9) - 3) / speedOfSound * float64(cfg.SampleRate)))
+	first := -1
+	for i := range l {
+		if l[i] != 0 || r[i] != 0 {
+			first = i
+			break
+		}
+	}
+	if first != want {
+		t.Fatalf("first reflection at sample %d, want %d", first, want)
+	}
+	if !(l[first] > 2*r[first]) {
+		t.Fatalf("reflection from the left has L=%v R=%v", l[first], r[first])
+	}
+	// The left wall bounce (7 m path) follows.
+	side := int(math.Round((7 - 3) / speedOfSound * float64(cfg.SampleRate)))
+	if math.Abs(float64(l[side])) < 0.05*math.Abs(float64(l[first])) {
+		t.Fatalf("no left wall reflection at sample %d", side)
+	}
+}
+
+func TestGenerateRoomGeometryAbsorptionPerBand(t *testing.T) {
+	bandEnergy := func(x []float32, fc float64) float64 {
+		band := octaveBand(toFloat64(x), fc, 48000)
+		e := 0.0
+		for _, v := range band {
+			e += v * v
+		}
+		return e
+	}
+	flatL, _, err := GenerateRoom(earlyOnlyRoom(0.3))
+	if err != nil {
+		t.Fatalf("GenerateRoom: %v", err)
+	}
+	dullL, _, err := GenerateRoom(earlyOnlyRoom(0.3, 0.3, 0.3, 0.3, 0.6, 0.8, 0.9))
+	if err != nil {
+		t.Fatalf("GenerateRoom: %v", err)
+	}
+	flat := bandEnergy(flatL, 4000) / bandEnergy(flatL, 250)
+	dull := bandEnergy(dullL, 4000) / bandEnergy(dullL, 250)
+	if !(dull < 0.5*flat) {
+		t.Fatalf("absorbing highs left the 4k/250 Hz energy ratio at %.3f (flat %.3f)", dull, flat)
+	}
+}
+
+func TestRoomGeometryValidate(t *testing.T) {
+	g := DefaultRoomGeometry()
+	if err := g.Validate(); err != nil {
+		t.Fatalf("default geometry: %v", err)
+	}
+	bad := []func(g *RoomGeometry){
+		func(g *RoomGeometry) { g.SizeM[2] = 0 },
+		func(g *RoomGeometry) { g.ListenerM[0] = g.SizeM[0] + 1 },
+		func(g *RoomGeometry) { g.Absorption = nil },
+		func(g *RoomGeometry) { g.Absorption = []float64{1.2} },
+		func(g *RoomGeometry) { g.MaxOrder = 0 },
+	}
+	for i, mutate := range bad {
+		g := DefaultRoomGeometry()
+		mutate(&g)
+		if err := g.Validate(); err == nil {
+			t.Fatalf("case %d: expected error for %+v", i, g)
+		}
+	}
+}
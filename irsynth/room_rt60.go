@@ -0,0 +1,116 @@
+package irsynth
+
+import (
+	"math"
+	"math/rand"
+)
+
+const (
+	// rt60Tolerance is the relative RT60 error per octave band at which
+	// GenerateRoom stops correcting a RoomConfig.TargetRT60S tail.
+	rt60Tolerance = 0.05
+	// rt60Passes bounds the measure-and-correct passes.
+	rt60Passes = 8
+)
+
+// targetRT60 returns the target reverberation time of crossover band b.
+func (c *RoomConfig) targetRT60(b int) float64 {
+	return c.TargetRT60S[min(b, len(c.TargetRT60S)-1)]
+}
+
+// addTargetRT60Tail adds a diffuse tail to left and right whose octave
+// bands decay with cfg.TargetRT60S. Each band is noise through the
+// complementary crossovers of the image-source model with its own
+// exponential envelope. Inspect measures the finished IR after every pass
+// and the band decay rates are corrected until all bands are within
+// rt60Tolerance, which absorbs crossover overlap and the early reflections.
+func addTargetRT60Tail(left, right []float64, cfg RoomConfig, rng *rand.Rand) {
+	sr := cfg.SampleRate
+	n := len(left)
+	bands := crossoverBands(sr)
+
+	noiseL := make([]float64, n)
+	noiseR := make([]float64, n)
+	for i := range noiseL {
+		noiseL[i] = rng.NormFloat64()
+		noiseR[i] = rng.NormFloat64()
+	}
+	bandL := make([][]float64, bands)
+	bandR := make([][]float64, bands)
+	rt := make([]float64, bands)
+	for b := range bands {
+		// Equal energy per octave, tilted by Brightness towards the top band.
+		g := cfg.LateLevel * math.Pow(2, -0.5*float64(b)) * math.Pow(cfg.Brightness, float64(b)/float64(max(bands-1, 1)))
+		bandL[b] = tailBand(noiseL, b, bands, sr)
+		bandR[b] = tailBand(noiseR, b, bands, sr)
+		for i := range n {
+			bandL[b][i] *= g
+			bandR[b][i] *= g
+		}
+		rt[b] = cfg.targetRT60(b)
+	}
+
+	outL := make([]float64, n)
+	outR := make([]float64, n)
+	finL := make([]float64, n)
+	finR := make([]float64, n)
+	for pass := 0; ; pass++ {
+		copy(outL, left)
+		copy(outR, right)
+		for b := range bands {
+			// Amplitude falls by 60 dB (a factor 1000) over rt[b] seconds.
+			decay := math.Exp(-math.Log(1000) / (rt[b] * float64(sr)))
+			env := 1.0
+			for i := range n {
+				outL[i] += env * bandL[b][i]
+				outR[i] += env * bandR[b][i]
+				env *= decay
+			}
+		}
+		if pass == rt60Passes-1 {
+			break
+		}
+
+		copy(finL, outL)
+		copy(finR, outR)
+		finishRoom(finL, finR, cfg)
+		rep, err := Inspect([][]float64{finL, finR}, sr)
+		if err != nil {
+			break
+		}
+		done := true
+		for b, bd := range rep.Bands {
+			if b >= bands || bd.Method == "" {
+				continue
+			}
+			want := cfg.targetRT60(b)
+			if math.Abs(bd.RT60S-want) > rt60Tolerance*want {
+				done = false
+			}
+			rt[b] *= math.Max(0.5, math.Min(2, want/bd.RT60S))
+		}
+		if done {
+			break
+		}
+	}
+	copy(left, outL)
+	copy(right, outR)
+}
+
+// tailBand isolates octave band b of x with eighth-order slopes, much
+// steeper than the crossovers and the Inspect band filters, so a slowly
+// decaying band does not leak into the measurement of its neighbours. The
+// outer bands extend to DC and Nyquist.
+func tailBand(x []float64, b int, bands int, sampleRate int) []float64 {
+	fc := InspectBandsHz[b]
+	y := x
+	for range 4 {
+		if b > 0 {
+			y = highpass(y, fc/math.Sqrt2, sampleRate)
+		}
+		if b < bands-1 {
+			y = lowpass(y, fc*math.Sqrt2, sampleRate)
+		}
+	}
+	return y
+}
@@ -0,0 +1,52 @@
+package irsynth
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGenerateRoomMatchesTargetRT60(t *testing.T) {
+	geometry := DefaultRoomGeometry()
+	cfg := DefaultRoomConfig()
+	cfg.SampleRate = 48000
+	cfg.DurationS = 2.5
+	cfg.Geometry = &geometry
+	cfg.TargetRT60S = []float64{1.8, 1.6, 1.4, 1.2, 1.0, 0.8, 0.6}
+	l, r, err := GenerateRoom(cfg)
+	if err != nil {
+		t.Fatalf("GenerateRoom: %v", err)
+	}
+	rep, err := Inspect([][]float64{toFloat64(l), toFloat64(r)}, cfg.SampleRate)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if len(rep.Bands) != len(cfg.TargetRT60S) {
+		t.Fatalf("measured %d bands, want %d", len(rep.Bands), len(cfg.TargetRT60S))
+	}
+	for i, bd := range rep.Bands {
+		want := cfg.TargetRT60S[i]
+		if math.Abs(bd.RT60S-want) > rt60Tolerance*want {
+			t.Errorf("%g Hz band RT60 %.3f s, want %.3f s", bd.CenterHz, bd.RT60S, want)
+		}
+	}
+}
+
+func TestRoomConfigValidateTargetRT60(t *testing.T) {
+	cases := []struct {
+		rt60 []float64
+		late float64
+	}{
+		{[]float64{1, 1, 1, 1, 1, 1, 1, 1}, 0.06},
+		{[]float64{1.2, 0}, 0.06},
+		{[]float64{45}, 0.06},
+		{[]float64{1.2}, 0},
+	}
+	for _, tc := range cases {
+		cfg := DefaultRoomConfig()
+		cfg.TargetRT60S = tc.rt60
+		cfg.LateLevel = tc.late
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected error for target %v with late level %v", tc.rt60, tc.late)
+		}
+	}
+}
@@ -483,6 +483,16 @@ type RoomConfig struct {
 	HighDecayS  float64
 	FadeOutS    float64 // Cosine fade-out at the end; 0 = no fade
 
+	// Geometry, when set, derives the early reflections from the room
+	// shape (image-source model) and replaces the EarlyCount random taps.
+	Geometry *RoomGeometry
+
+	// TargetRT60S, when set, shapes the late tail to these reverberation
+	// times in seconds per InspectBandsHz octave band (bands past the end
+	// reuse the last value) instead of LowDecayS/HighDecayS. DurationS
+	// should cover the longest one for the tail to be measurable.
+	TargetRT60S []float64
+
 	NormalizePeak float64
 }
 
@@ -525,12 +535,36 @@ func (c *RoomConfig) Validate() error {
 	if c.LowDecayS <= 0 || c.HighDecayS <= 0 {
 		return fmt.Errorf("decay seconds must be > 0")
 	}
+	if c.Geometry != nil {
+		if err := c.Geometry.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(c.TargetRT60S) > len(InspectBandsHz) {
+		return fmt.Errorf("target RT60 needs at most %d bands", len(InspectBandsHz))
+	}
+	for _, rt := range c.TargetRT60S {
+		if rt <= 0 || rt > 30 {
+			return fmt.Errorf("target RT60 must be in (0,30] seconds")
+		}
+	}
+	if len(c.TargetRT60S) > 0 && c.LateLevel <= 0 {
+		return fmt.Errorf("target RT60 needs late level > 0")
+	}
 	if c.NormalizePeak <= 0 {
 		return fmt.Errorf("normalize peak must be > 0")
 	}
 	return nil
 }
 
+// finishRoom removes DC from a room IR and fades out its end.
+func finishRoom(left, right []float64, cfg RoomConfig) {
+	highpassDC(left, 0.995)
+	highpassDC(right, 0.995)
+	applyFadeOut(left, cfg.FadeOutS, cfg.SampleRate)
+	applyFadeOut(right, cfg.FadeOutS, cfg.SampleRate)
+}
+
 // GenerateRoom synthesizes a stereo room/reverb IR (early reflections + diffuse tail).
 func GenerateRoom(cfg RoomConfig) ([]float32, []float32, error) {
 	if err := cfg.Validate(); err != nil {
@@ -546,23 +580,30 @@ func GenerateRoom(cfg RoomConfig) ([]float32, []float32, error) {
 
 	rng := rand.New(rand.NewSource(cfg.Seed))
 
-	// Early reflections (stereo, 1-50ms range).
-	for i := 0; i < cfg.EarlyCount; i++ {
-		t := 0.001 + 0.049*rng.Float64()
-		idx := int(t * float64(cfg.SampleRate))
-		if idx <= 0 || idx >= n {
-			continue
+	// Early reflections: from the room geometry, or random stereo taps in
+	// the 1-50ms range.
+	if cfg.Geometry != nil {
+		addImageSourceReflections(left, right, *cfg.Geometry, cfg.SampleRate, cfg.StereoWidth)
+	} else {
+		for i := 0; i < cfg.EarlyCount; i++ {
+			t := 0.001 + 0.049*rng.Float64()
+			idx := int(t * float64(cfg.SampleRate))
+			if idx <= 0 || idx >= n {
+				continue
+			}
+			amp := (0.10 + 0.35*rng.Float64()) * math.Exp(-t*20.0)
+			// Brightness rolloff: dampen high-frequency reflections via simple attenuation.
+			amp *= math.Pow(0.5+0.5*rng.Float64(), 1.0/cfg.Brightness)
+			pan := (rng.Float64()*2.0 - 1.0) * cfg.StereoWidth
+			left[idx] += amp * (1.0 - 0.5*pan)
+			right[idx] += amp * (1.0 + 0.5*pan)
 		}
-		amp := (0.10 + 0.35*rng.Float64()) * math.Exp(-t*20.0)
-		// Brightness rolloff: dampen high-frequency reflections via simple attenuation.
-		amp *= math.Pow(0.5+0.5*rng.Float64(), 1.0/cfg.Brightness)
-		pan := (rng.Float64()*2.0 - 1.0) * cfg.StereoWidth
-		left[idx] += amp * (1.0 - 0.5*pan)
-		right[idx] += amp * (1.0 + 0.5*pan)
 	}
 
 	// Diffuse late tail (stereo, frequency-dependent decay).
-	if cfg.LateLevel > 0 {
+	if len(cfg.TargetRT60S) > 0 {
+		addTargetRT60Tail(left, right, cfg, rng)
+	} else if cfg.LateLevel > 0 {
 		maxF := 0.47 * float64(cfg.SampleRate)
 		// Two-band noise: low-pass and band-pass filtered.
 		lpL, lpR := 0.0, 0.0
@@ -593,10 +634,7 @@ func GenerateRoom(cfg RoomConfig) ([]float32, []float32, error) {
 		}
 	}
 
-	highpassDC(left, 0.995)
-	highpassDC(right, 0.995)
-	applyFadeOut(left, cfg.FadeOutS, cfg.SampleRate)
-	applyFadeOut(right, cfg.FadeOutS, cfg.SampleRate)
+	finishRoom(left, right, cfg)
 
 	peak := maxAbs(left)
 	if rp := maxAbs(right); rp > peak {
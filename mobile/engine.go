@@ -0,0 +1,169 @@
+// Package mobile is a gomobile-compatible wrapper around the piano engine.
+//
+// The API only uses types gomobile can bind (int, float64, bool, string,
+// []byte, error) and never blocks or uses channels, so it can be driven
+// directly from an iOS/Android audio callback:
+//
+//	gomobile bind -target=android ./mobile
+//	gomobile bind -target=ios ./mobile
+package mobile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/piano"
+	"github.com/cwbudde/algo-piano/preset"
+)
+
+// MaxBlockFrames is the largest block Process renders per call. Audio
+// callbacks should request short buffers and call Process repeatedly.
+const MaxBlockFrames = 512
+
+const polyphony = 16
+
+// Engine is one piano instance. It is not safe for concurrent use; drive
+// events and Process from the same audio thread or guard it externally.
+type Engine struct {
+	p          *piano.Piano
+	sampleRate int
+	out        []byte
+	block      []float32
+}
+
+// NewEngine creates an engine with the default parameters.
+func NewEngine(sampleRate int) (*Engine, error) {
+	return newEngine(sampleRate, piano.NewDefaultParams())
+}
+
+// NewEngineFromPreset creates an engine from a preset JSON file. IR paths in
+// the preset are resolved relative to the preset file.
+func NewEngineFromPreset(path string, sampleRate int) (*Engine, error) {
+	params, err := preset.LoadJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	return newEngine(sampleRate, params)
+}
+
+func newEngine(sampleRate int, params *piano.Params) (*Engine, error) {
+	if sampleRate < 8000 {
+		return nil, fmt.Errorf("sample rate too low: %d", sampleRate)
+	}
+	return &Engine{
+		p:          piano.NewPiano(sampleRate, polyphony, params),
+		sampleRate: sampleRate,
+		out:        make([]byte, 0, MaxBlockFrames*2*4),
+		block:      make([]float32, MaxBlockFrames*2),
+	}, nil
+}
+
+// SampleRate returns the engine sample rate in Hz.
+func (e *Engine) SampleRate() int {
+	return e.sampleRate
+}
+
+// NoteOn strikes a MIDI note with velocity 1..127.
+func (e *Engine) NoteOn(note int, velocity int) {
+	e.p.NoteOn(note, velocity)
+}
+
+// NoteOnEx strikes a note with per-strike overrides: strikePos in (0,1) and
+// hardness > 0 replace the preset values (0 keeps them), mute strikes without
+// lifting the damper, and harmonicNode >= 2 plays a harmonic (2 = octave,
+// 3 = twelfth; 0 = off).
+func (e *Engine) NoteOnEx(note int, velocity int, strikePos float64, hardness float64, mute bool, harmonicNode int) {
+	e.p.NoteOnEx(note, velocity, piano.NoteOptions{
+		StrikePosition: float32(strikePos),
+		Hardness:       float32(hardness),
+		Mute:           mute,
+		HarmonicNode:   harmonicNode,
+	})
+}
+
+// KeyDown lifts the damper of a note without striking it.
+func (e *Engine) KeyDown(note int) {
+	e.p.KeyDown(note)
+}
+
+// NoteOff releases a note.
+func (e *Engine) NoteOff(note int) {
+	e.p.NoteOff(note)
+}
+
+// SetSustainPedal sets the sustain pedal state.
+func (e *Engine) SetSustainPedal(down bool) {
+	e.p.SetSustainPedal(down)
+}
+
+// SetSoftPedal sets the una corda pedal state.
+func (e *Engine) SetSoftPedal(down bool) {
+	e.p.SetSoftPedal(down)
+}
+
+// SetSoftPedalAmount sets a partial una corda shift in [0,1].
+func (e *Engine) SetSoftPedalAmount(amount float64) {
+	e.p.SetSoftPedalAmount(float32(amount))
+}
+
+// SetOutputGain changes the linear output gain; changes glide to avoid clicks.
+func (e *Engine) SetOutputGain(gain float64) {
+	e.p.SetOutputGain(float32(gain))
+}
+
+// SetCouplingAmount changes the string coupling strength in [0,1].
+func (e *Engine) SetCouplingAmount(amount float64) {
+	e.p.SetCouplingAmount(float32(amount))
+}
+
+// SetLidPosition crossfades between lid-closed (0) and open (1) body IRs.
+func (e *Engine) SetLidPosition(pos float64) {
+	e.p.SetLidPosition(float32(pos))
+}
+
+// SetCouplingMode switches string coupling ("off", "static", "physical").
+func (e *Engine) SetCouplingMode(mode string) bool {
+	return e.p.SetCouplingMode(piano.CouplingMode(strings.ToLower(strings.TrimSpace(mode))))
+}
+
+// Process renders up to MaxBlockFrames stereo frames and returns them as
+// interleaved little-endian float32 samples (8 bytes per frame). The returned
+// slice is reused by the next Process call.
+func (e *Engine) Process(numFrames int) []byte {
+	block := e.render(numFrames)
+	out := e.out[:len(block)*4]
+	for i, v := range block {
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(v))
+	}
+	return out
+}
+
+// ProcessInt16 is Process with interleaved little-endian 16-bit PCM output
+// (4 bytes per frame), for platform APIs that only accept integer PCM.
+func (e *Engine) ProcessInt16(numFrames int) []byte {
+	block := e.render(numFrames)
+	out := e.out[:len(block)*2]
+	for i, v := range block {
+		if v > 1 {
+			v = 1
+		} else if v < -1 {
+			v = -1
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(math.Round(float64(v)*32767))))
+	}
+	return out
+}
+
+func (e *Engine) render(numFrames int) []float32 {
+	if numFrames <= 0 {
+		return nil
+	}
+	if numFrames > MaxBlockFrames {
+		numFrames = MaxBlockFrames
+	}
+	block := e.block[:numFrames*2]
+	e.p.ProcessInto(block)
+	return block
+}
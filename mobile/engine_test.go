@@ -0,0 +1,58 @@
+package mobile
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestEngineProcessProducesStereoFloat32(t *testing.T) {
+	e, err := NewEngine(48000)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	e.NoteOn(60, 100)
+
+	var peak float64
+	for i := 0; i < 20; i++ {
+		b := e.Process(128)
+		if len(b) != 128*2*4 {
+			t.Fatalf("Process returned %d bytes, want %d", len(b), 128*2*4)
+		}
+		for j := 0; j < len(b); j += 4 {
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(b[j:])))
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Fatalf("non-finite sample at block %d", i)
+			}
+			peak = math.Max(peak, math.Abs(v))
+		}
+	}
+	if peak == 0 {
+		t.Fatalf("expected audible output after NoteOn")
+	}
+}
+
+func TestEngineProcessClampsBlockSize(t *testing.T) {
+	e, err := NewEngine(44100)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if got := len(e.Process(MaxBlockFrames * 4)); got != MaxBlockFrames*2*4 {
+		t.Fatalf("Process(oversized) returned %d bytes, want %d", got, MaxBlockFrames*2*4)
+	}
+	if got := len(e.ProcessInt16(64)); got != 64*2*2 {
+		t.Fatalf("ProcessInt16(64) returned %d bytes, want %d", got, 64*2*2)
+	}
+	if got := len(e.Process(0)); got != 0 {
+		t.Fatalf("Process(0) returned %d bytes", got)
+	}
+}
+
+func TestNewEngineRejectsLowSampleRate(t *testing.T) {
+	if _, err := NewEngine(100); err == nil {
+		t.Fatalf("expected error for low sample rate")
+	}
+	if _, err := NewEngineFromPreset("does-not-exist.json", 48000); err == nil {
+		t.Fatalf("expected error for missing preset")
+	}
+}
@@ -0,0 +1,113 @@
+package piano
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cwbudde/algo-piano/assets"
+)
+
+// AssetDirEnv names the environment variable that points the bundled asset
+// paths at an external asset directory, like SetAssetDir.
+const AssetDirEnv = "ALGO_PIANO_ASSETS"
+
+var assetDir struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// SetAssetDir points the bundled asset paths (relative paths under
+// "assets/", e.g. DefaultIRWavPath) at dir: "assets/ir/x.wav" is read from
+// dir/ir/x.wav first. "" restores the default lookup, which honours
+// AssetDirEnv. Cached IRs are dropped.
+func SetAssetDir(dir string) {
+	assetDir.mu.Lock()
+	assetDir.dir = dir
+	assetDir.mu.Unlock()
+	InvalidateIRCache("")
+}
+
+// ReadAsset reads the file at path. A bundled asset path is looked up in
+// the asset directory (SetAssetDir or AssetDirEnv), then relative to the
+// working directory, then in the copies embedded in package assets, so the
+// default IR and preset load from anywhere.
+func ReadAsset(path string) ([]byte, error) {
+	rel, bundled := bundledAssetPath(path)
+	if bundled {
+		if dir := currentAssetDir(); dir != "" {
+			if b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel))); err == nil {
+				return b, nil
+			}
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err == nil || !bundled || !errors.Is(err, fs.ErrNotExist) {
+		return b, err
+	}
+	if embedded, embedErr := fs.ReadFile(assets.FS, rel); embedErr == nil {
+		return embedded, nil
+	}
+	return nil, err
+}
+
+// assetVersion identifies the content of the file behind an asset path by
+// its modification time and size. Embedded copies and missing files have
+// the zero version.
+type assetVersion struct {
+	modTime int64
+	size    int64
+}
+
+// statAsset returns the version of the file ReadAsset(path) reads, looked
+// up the same way.
+func statAsset(path string) assetVersion {
+	if path == "" {
+		return assetVersion{}
+	}
+	stat := func(name string) (assetVersion, bool) {
+		info, err := os.Stat(name)
+		if err != nil {
+			return assetVersion{}, false
+		}
+		return assetVersion{modTime: info.ModTime().UnixNano(), size: info.Size()}, true
+	}
+	if rel, bundled := bundledAssetPath(path); bundled {
+		if dir := currentAssetDir(); dir != "" {
+			if v, ok := stat(filepath.Join(dir, filepath.FromSlash(rel))); ok {
+				return v
+			}
+		}
+	}
+	v, _ := stat(path)
+	return v
+}
+
+// IsBundledAsset reports whether path is a bundled asset path, which
+// ReadAsset also looks up in the asset directory and the embedded copies.
+func IsBundledAsset(path string) bool {
+	_, ok := bundledAssetPath(path)
+	return ok
+}
+
+// bundledAssetPath reports whether path is a relative path under "assets/"
+// and returns the remainder.
+func bundledAssetPath(path string) (string, bool) {
+	if path == "" || filepath.IsAbs(path) {
+		return "", false
+	}
+	return strings.CutPrefix(filepath.ToSlash(filepath.Clean(path)), "assets/")
+}
+
+func currentAssetDir() string {
+	assetDir.mu.Lock()
+	dir := assetDir.dir
+	assetDir.mu.Unlock()
+	if dir == "" {
+		dir = os.Getenv(AssetDirEnv)
+	}
+	return dir
+}
@@ -0,0 +1,65 @@
+package piano
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadAssetFallsBackToEmbeddedCopy(t *testing.T) {
+	// The tests run in piano/, where assets/ does not exist.
+	got, err := ReadAsset(DefaultIRWavPath)
+	if err != nil {
+		t.Fatalf("ReadAsset(%q): %v", DefaultIRWavPath, err)
+	}
+	want, err := os.ReadFile(filepath.Join("..", DefaultIRWavPath))
+	if err != nil {
+		t.Fatalf("read repo asset: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("embedded IR differs from the repository copy")
+	}
+
+	c := NewSoundboardConvolver(48000)
+	if err := c.SetIRFromWAV(DefaultIRWavPath); err != nil {
+		t.Fatalf("SetIRFromWAV(%q): %v", DefaultIRWavPath, err)
+	}
+	if _, err := ReadAsset("assets/ir/missing.wav"); !os.IsNotExist(err) {
+		t.Fatalf("missing bundled asset: err = %v, want not-exist", err)
+	}
+}
+
+func TestAssetDirOverridesBundledAssets(t *testing.T) {
+	envDir, setDir := t.TempDir(), t.TempDir()
+	for dir, content := range map[string]string{envDir: "env", setDir: "set"} {
+		if err := os.MkdirAll(filepath.Join(dir, "ir"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "ir", "default_96k.wav"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() string {
+		t.Helper()
+		b, err := ReadAsset(DefaultIRWavPath)
+		if err != nil {
+			t.Fatalf("ReadAsset: %v", err)
+		}
+		return string(b)
+	}
+
+	t.Setenv(AssetDirEnv, envDir)
+	if got := read(); got != "env" {
+		t.Fatalf("with %s: read %q, want the env dir copy", AssetDirEnv, got)
+	}
+	SetAssetDir(setDir)
+	t.Cleanup(func() { SetAssetDir("") })
+	if got := read(); got != "set" {
+		t.Fatalf("with SetAssetDir: read %q, want the set dir copy", got)
+	}
+	// Files missing from the asset dir still come from the embedded copies.
+	if _, err := ReadAsset("assets/presets/default.json"); err != nil {
+		t.Fatalf("ReadAsset default preset: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package piano
+
+import (
+	"github.com/cwbudde/algo-dsp/dsp/filter/biquad"
+	"github.com/cwbudde/algo-dsp/dsp/filter/design"
+)
+
+// bassMono folds the stereo output to mono below a crossover frequency.
+// Wide synthetic room IRs leave the low end decorrelated between the
+// channels, which thins out when played back in mono. The side signal is
+// high-passed with a 4th-order Linkwitz-Riley filter; the mid signal goes
+// through the matching 2nd-order allpass (the sum of the Linkwitz-Riley
+// low and high pass), so mid and side keep the same phase above the
+// crossover and the image there is unchanged.
+type bassMono struct {
+	mid  *biquad.Chain
+	side *biquad.Chain
+}
+
+// newBassMono builds the stage for Params.BassMonoHz; nil is returned when
+// the frequency is 0 or out of range.
+func newBassMono(sampleRate int, freqHz float32) *bassMono {
+	if sampleRate <= 0 || freqHz <= 0 || float64(freqHz) >= 0.5*float64(sampleRate) {
+		return nil
+	}
+	sr := float64(sampleRate)
+	f := float64(freqHz)
+	const q = 0.7071067811865476
+	hp := design.Highpass(f, q, sr)
+	return &bassMono{
+		mid:  biquad.NewChain([]biquad.Coefficients{design.Allpass(f, q, sr)}),
+		side: biquad.NewChain([]biquad.Coefficients{hp, hp}),
+	}
+}
+
+// ProcessInterleaved filters a stereo interleaved buffer in place.
+func (b *bassMono) ProcessInterleaved(buf []float32) {
+	if b == nil {
+		return
+	}
+	for i := 0; i+1 < len(buf); i += 2 {
+		l, r := float64(buf[i]), float64(buf[i+1])
+		m := b.mid.ProcessSample(0.5 * (l + r))
+		s := b.side.ProcessSample(0.5 * (l - r))
+		buf[i] = float32(m + s)
+		buf[i+1] = float32(m - s)
+	}
+}
@@ -0,0 +1,64 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+// bassMonoGain feeds a stereo sine with the given left/right amplitudes
+// through the stage and returns the steady-state peak gain per channel.
+func bassMonoGain(freqHz float32, freq float64, left, right float64) (float64, float64) {
+	const sampleRate = 48000
+	b := newBassMono(sampleRate, freqHz)
+	buf := make([]float32, 2*sampleRate/2)
+	for i := 0; i < len(buf)/2; i++ {
+		v := math.Sin(2 * math.Pi * freq * float64(i) / sampleRate)
+		buf[i*2] = float32(left * v)
+		buf[i*2+1] = float32(right * v)
+	}
+	b.ProcessInterleaved(buf)
+	steady := buf[len(buf)/2:]
+	l := make([]float32, len(steady)/2)
+	r := make([]float32, len(steady)/2)
+	for i := range l {
+		l[i], r[i] = steady[i*2], steady[i*2+1]
+	}
+	return windowRMS(l) * math.Sqrt2, windowRMS(r) * math.Sqrt2
+}
+
+func TestBassMonoOffBypasses(t *testing.T) {
+	if b := newBassMono(48000, 0); b != nil {
+		t.Fatalf("expected nil stage with bass_mono_hz 0")
+	}
+	var b *bassMono
+	buf := []float32{0.5, -0.5}
+	b.ProcessInterleaved(buf)
+	if buf[0] != 0.5 || buf[1] != -0.5 {
+		t.Fatalf("expected nil stage to pass through, got %v", buf)
+	}
+}
+
+func TestBassMonoRemovesSideBelowCrossover(t *testing.T) {
+	// Anti-phase bass is pure side signal and is removed.
+	if l, r := bassMonoGain(120, 30, 1, -1); l > 0.05 || r > 0.05 {
+		t.Fatalf("anti-phase 30 Hz gain = %.3f/%.3f, want ~0", l, r)
+	}
+	// Mid passes at unity on both sides of the crossover (allpass).
+	for _, freq := range []float64{30, 2000} {
+		if l, r := bassMonoGain(120, freq, 1, 1); math.Abs(l-1) > 0.02 || math.Abs(r-1) > 0.02 {
+			t.Fatalf("in-phase %.0f Hz gain = %.3f/%.3f, want 1", freq, l, r)
+		}
+	}
+}
+
+func TestBassMonoKeepsImageAboveCrossover(t *testing.T) {
+	// A hard-left tone well above the crossover stays hard left: mid and
+	// side are phase aligned, so nothing leaks into the right channel.
+	l, r := bassMonoGain(120, 2000, 1, 0)
+	if math.Abs(l-1) > 0.02 {
+		t.Fatalf("left gain at 2 kHz = %.3f, want 1", l)
+	}
+	if r > 0.02 {
+		t.Fatalf("right leakage at 2 kHz = %.3f, want ~0", r)
+	}
+}
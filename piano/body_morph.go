@@ -0,0 +1,55 @@
+package piano
+
+// lidSmoothingMs is the default time constant used to glide between body IR
+// variants (ControlSmoothing.LidMs).
+const lidSmoothingMs = 30.0
+
+// bodyMorph crossfades between the primary (lid-open) body convolver output
+// and an optional lid-closed variant. Both convolvers run every block so the
+// crossfade never exposes stale convolution history.
+type bodyMorph struct {
+	closed    *BodyConvolver
+	closedOut []float32
+	lid       smoothedParam
+}
+
+func newBodyMorph(sampleRate int, lidPosition float32) *bodyMorph {
+	return &bodyMorph{
+		lid: newSmoothedParam(sampleRate, lidSmoothingMs, clampf(lidPosition, 0, 1)),
+	}
+}
+
+// setLid sets the lid target (0 = closed variant, 1 = open/primary IR).
+func (m *bodyMorph) setLid(pos float32) {
+	if m == nil {
+		return
+	}
+	m.lid.set(clampf(pos, 0, 1))
+}
+
+// setClosedIR installs the lid-closed IR convolver; the lid jumps to its
+// target so a freshly loaded variant does not fade in from a stale position.
+func (m *bodyMorph) setClosedIR(c *BodyConvolver) {
+	if m == nil {
+		return
+	}
+	m.closed = c
+	m.lid.jump(m.lid.target)
+}
+
+// process blends the closed-variant convolution of input into open in place.
+func (m *bodyMorph) process(input []float32, open []float32) []float32 {
+	if m == nil || m.closed == nil {
+		return open
+	}
+	if len(m.closedOut) < len(input) {
+		m.closedOut = make([]float32, len(input))
+	}
+	closed := m.closedOut[:len(input)]
+	m.closed.processInto(closed, input)
+	for i := range open {
+		lid := m.lid.next()
+		open[i] = lid*open[i] + (1-lid)*closed[i]
+	}
+	return open
+}
@@ -0,0 +1,60 @@
+package piano
+
+import "testing"
+
+func TestLidPositionWithoutClosedIRIsNoOp(t *testing.T) {
+	render := func(lid float32) []float32 {
+		params := NewDefaultParams()
+		params.LidPosition = lid
+		p := NewPiano(48000, 16, params)
+		p.NoteOn(60, 100)
+		return p.Process(2048)
+	}
+	a := render(1)
+	b := render(0)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected identical output without closed IR at sample %d: %f vs %f", i, a[i], b[i])
+		}
+	}
+}
+
+func TestLidPositionCrossfadesToClosedIR(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	p.SetBodyIRClosed([]float32{0.25})
+	p.NoteOn(60, 100)
+	open := stereoRMS(p.Process(2048))
+
+	p.SetLidPosition(0)
+	_ = p.Process(48000 / 4) // let the 30 ms glide settle
+	closed := stereoRMS(p.Process(2048))
+	if closed >= open*0.5 {
+		t.Fatalf("expected quieter output with attenuating closed IR: open=%f closed=%f", open, closed)
+	}
+}
+
+func TestBodyMorphGlidesSmoothly(t *testing.T) {
+	m := newBodyMorph(48000, 1)
+	c := NewBodyConvolver(48000)
+	c.SetIR([]float32{0})
+	m.setClosedIR(c)
+	m.setLid(0)
+
+	in := make([]float32, 128)
+	open := make([]float32, 128)
+	for i := range open {
+		in[i] = 1
+		open[i] = 1
+	}
+	out := m.process(in, open)
+	prev := float32(1)
+	for i, v := range out {
+		if prev-v > 0.01 {
+			t.Fatalf("expected smooth glide, jump of %f at sample %d", prev-v, i)
+		}
+		prev = v
+	}
+	if out[len(out)-1] >= 1 || out[len(out)-1] < 0.5 {
+		t.Fatalf("expected partial glide after one block, got %f", out[len(out)-1])
+	}
+}
@@ -0,0 +1,267 @@
+package piano
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Capture is a log of the note and pedal events a Piano received between
+// StartCapture and StopCapture. Frames count from the output frame the
+// capture started on, so Replay on a fresh engine reproduces the session
+// sample for sample (given the same params, sample rate and a host block
+// size that is a multiple of the internal block, see ScheduleEvent).
+type Capture struct {
+	SampleRate int
+	Events     []CapturedEvent
+}
+
+// CapturedEvent is an Event with the frame it took effect on.
+type CapturedEvent struct {
+	Frame int64
+	Event
+}
+
+// capturePrealloc sizes the event log so a typical take does not grow it
+// from the audio callback.
+const capturePrealloc = 4096
+
+// StartCapture starts recording every note and pedal event, whether called
+// directly (NoteOn, SetSustainPedal, ...) or applied from ScheduleEvent,
+// and discards any capture in progress. Strings still ringing from before
+// the capture are not part of it, so start on a silent engine for an exact
+// replay.
+func (p *Piano) StartCapture() {
+	p.capture = &Capture{SampleRate: p.sampleRate, Events: make([]CapturedEvent, 0, capturePrealloc)}
+	p.captureStart = p.framesOut
+}
+
+// StopCapture ends recording and returns the capture, or nil when none was
+// started.
+func (p *Piano) StopCapture() *Capture {
+	c := p.capture
+	p.capture = nil
+	return c
+}
+
+// Replay schedules captured events relative to the next Process call.
+func (p *Piano) Replay(events []CapturedEvent) {
+	for _, e := range events {
+		p.ScheduleEvent(int(e.Frame), e.Event)
+	}
+}
+
+// eventFrame is the frame an event takes effect on: the current segment
+// while renderStrings applies scheduled events, otherwise the next frame to
+// render.
+func (p *Piano) eventFrame() int64 {
+	return p.framesRendered + int64(p.eventPos)
+}
+
+// record logs e at the frame it takes effect on (eventFrame).
+func (p *Piano) record(e Event) {
+	if p.capture == nil {
+		return
+	}
+	frame := p.eventFrame() - p.captureStart
+	p.capture.Events = append(p.capture.Events, CapturedEvent{Frame: max(frame, 0), Event: e})
+}
+
+var eventKindNames = [...]string{
+	EventNoteOn:       "note_on",
+	EventNoteOff:      "note_off",
+	EventKeyDown:      "key_down",
+	EventSustainPedal: "sustain",
+	EventSoftPedal:    "soft",
+	EventKeyPress:     "key_press",
+}
+
+// String returns the name used in capture files: note_on, note_off,
+// key_down, sustain, soft or key_press.
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventKindNames) {
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+	return eventKindNames[k]
+}
+
+// ParseEventKind is the inverse of EventKind.String.
+func ParseEventKind(name string) (EventKind, bool) {
+	for k, n := range eventKindNames {
+		if n == name {
+			return EventKind(k), true
+		}
+	}
+	return 0, false
+}
+
+type captureFile struct {
+	SampleRate int                 `json:"sample_rate"`
+	Events     []capturedEventFile `json:"events"`
+}
+
+type capturedEventFile struct {
+	Frame          int64   `json:"frame"`
+	Type           string  `json:"type"`
+	Note           int     `json:"note,omitempty"`
+	Velocity       int     `json:"velocity,omitempty"`
+	Down           bool    `json:"down,omitempty"`
+	StrikePosition float32 `json:"strike_position,omitempty"`
+	Hardness       float32 `json:"hardness,omitempty"`
+	Mute           bool    `json:"mute,omitempty"`
+	HarmonicNode   int     `json:"harmonic_node,omitempty"`
+}
+
+// WriteJSON writes the capture as {"sample_rate", "events": [{"frame",
+// "type", "note", "velocity", "down", ...}]}.
+func (c *Capture) WriteJSON(w io.Writer) error {
+	f := captureFile{SampleRate: c.SampleRate, Events: make([]capturedEventFile, len(c.Events))}
+	for i, e := range c.Events {
+		f.Events[i] = capturedEventFile{
+			Frame:          e.Frame,
+			Type:           e.Kind.String(),
+			Note:           e.Note,
+			Velocity:       e.Velocity,
+			Down:           e.Down,
+			StrikePosition: e.Options.StrikePosition,
+			Hardness:       e.Options.Hardness,
+			Mute:           e.Options.Mute,
+			HarmonicNode:   e.Options.HarmonicNode,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// ReadCaptureJSON parses and validates a capture written by WriteJSON.
+func ReadCaptureJSON(r io.Reader) (*Capture, error) {
+	var f captureFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	if f.SampleRate <= 0 {
+		return nil, fmt.Errorf("sample_rate must be > 0")
+	}
+	c := &Capture{SampleRate: f.SampleRate, Events: make([]CapturedEvent, len(f.Events))}
+	for i, e := range f.Events {
+		kind, ok := ParseEventKind(e.Type)
+		if !ok {
+			return nil, fmt.Errorf("events[%d].type %q is not a known event", i, e.Type)
+		}
+		if e.Frame < 0 || e.Frame > math.MaxInt32 {
+			return nil, fmt.Errorf("events[%d].frame must be in [0,%d]", i, math.MaxInt32)
+		}
+		if e.Note < 0 || e.Note > 127 || e.Velocity < 0 || e.Velocity > 127 {
+			return nil, fmt.Errorf("events[%d]: note and velocity must be in [0,127]", i)
+		}
+		c.Events[i] = CapturedEvent{Frame: e.Frame, Event: Event{
+			Kind:     kind,
+			Note:     e.Note,
+			Velocity: e.Velocity,
+			Down:     e.Down,
+			Options: NoteOptions{
+				StrikePosition: e.StrikePosition,
+				Hardness:       e.Hardness,
+				Mute:           e.Mute,
+				HarmonicNode:   e.HarmonicNode,
+			},
+		}}
+	}
+	return c, nil
+}
+
+// LoadCapture reads a capture JSON file.
+func LoadCapture(path string) (*Capture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCaptureJSON(file)
+}
+
+// SMF export: one track at 120 bpm with smfDivision ticks per quarter note,
+// i.e. 1920 ticks per second (about half a millisecond).
+const (
+	smfDivision    = 960
+	smfTempoUsec   = 500000
+	smfTicksPerSec = smfDivision * 1000000 / smfTempoUsec
+)
+
+// WriteSMF writes the capture as a format 0 Standard MIDI File on channel
+// 1: notes as note on/off, sustain as CC 64 and soft as CC 67. The export
+// is lossy: strike options, key_down (a silent damper lift) and key_press
+// have no MIDI equivalent and are dropped, and times are rounded to the tick.
+func (c *Capture) WriteSMF(w io.Writer) error {
+	if c.SampleRate <= 0 {
+		return fmt.Errorf("sample rate must be > 0")
+	}
+	events := append([]CapturedEvent(nil), c.Events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Frame < events[j].Frame })
+
+	var track bytes.Buffer
+	writeVLQ(&track, 0)
+	track.Write([]byte{0xFF, 0x51, 0x03, smfTempoUsec >> 16, (smfTempoUsec >> 8) & 0xFF, smfTempoUsec & 0xFF})
+	lastTick := int64(0)
+	for _, e := range events {
+		var msg []byte
+		note := byte(min(max(e.Note, 0), 127))
+		vel := byte(min(max(e.Velocity, 0), 127))
+		switch e.Kind {
+		case EventNoteOn:
+			// Velocity 0 would read as a note off.
+			msg = []byte{0x90, note, max(vel, 1)}
+		case EventNoteOff:
+			msg = []byte{0x80, note, vel}
+		case EventSustainPedal:
+			msg = []byte{0xB0, 64, pedalValue(e.Down)}
+		case EventSoftPedal:
+			msg = []byte{0xB0, 67, pedalValue(e.Down)}
+		default:
+			continue
+		}
+		tick := int64(math.Round(float64(e.Frame) * smfTicksPerSec / float64(c.SampleRate)))
+		writeVLQ(&track, uint32(tick-lastTick))
+		track.Write(msg)
+		lastTick = tick
+	}
+	writeVLQ(&track, 0)
+	track.Write([]byte{0xFF, 0x2F, 0x00})
+
+	var out bytes.Buffer
+	out.WriteString("MThd")
+	binary.Write(&out, binary.BigEndian, uint32(6))
+	binary.Write(&out, binary.BigEndian, [3]uint16{0, 1, smfDivision}) // format 0, one track
+	out.WriteString("MTrk")
+	binary.Write(&out, binary.BigEndian, uint32(track.Len()))
+	out.Write(track.Bytes())
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func pedalValue(down bool) byte {
+	if down {
+		return 127
+	}
+	return 0
+}
+
+// writeVLQ writes a MIDI variable-length quantity.
+func writeVLQ(b *bytes.Buffer, v uint32) {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7F)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		tmp[i] = byte(v&0x7F) | 0x80
+	}
+	b.Write(tmp[i:])
+}
@@ -0,0 +1,124 @@
+package piano
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplayReproducesCapturedSession(t *testing.T) {
+	newPiano := func() *Piano {
+		params := NewDefaultParams()
+		params.ResonanceEnabled = true
+		return NewPiano(48000, 16, params)
+	}
+	const blocks = 30
+
+	live := newPiano()
+	live.StartCapture()
+	want := make([]float32, 0, blocks*internalBlockSize*2)
+	for b := range blocks {
+		switch b {
+		case 1:
+			live.NoteOnEx(60, 90, NoteOptions{Hardness: 1.1})
+			live.ScheduleEvent(53, Event{Kind: EventNoteOn, Note: 67, Velocity: 70})
+		case 4:
+			live.SetSustainPedal(true)
+			live.KeyDown(64)
+		case 9:
+			live.NoteOffEx(60, 110)
+			live.SetSoftPedal(true)
+		case 20:
+			live.SetSustainPedal(false)
+		}
+		want = append(want, live.Process(internalBlockSize)...)
+	}
+	c := live.StopCapture()
+	if live.StopCapture() != nil {
+		t.Fatalf("expected nil capture after StopCapture")
+	}
+	if len(c.Events) != 7 || c.Events[1].Frame != internalBlockSize+53 || c.Events[1].Note != 67 {
+		t.Fatalf("unexpected capture %+v", c.Events)
+	}
+
+	replay := newPiano()
+	replay.Replay(c.Events)
+	for b := range blocks {
+		got := replay.Process(internalBlockSize)
+		for i, v := range got {
+			j := b*internalBlockSize*2 + i
+			if math.Float32bits(v) != math.Float32bits(want[j]) {
+				t.Fatalf("replay sample %d differs: %v vs %v", j/2, v, want[j])
+			}
+		}
+	}
+}
+
+func TestCaptureJSONRoundTrip(t *testing.T) {
+	c := &Capture{SampleRate: 44100, Events: []CapturedEvent{
+		{Frame: 0, Event: Event{Kind: EventNoteOn, Note: 60, Velocity: 100, Options: NoteOptions{StrikePosition: 0.2, Mute: true}}},
+		{Frame: 128, Event: Event{Kind: EventSustainPedal, Down: true}},
+		{Frame: 4410, Event: Event{Kind: EventNoteOff, Note: 60, Velocity: 20}},
+		{Frame: 5000, Event: Event{Kind: EventKeyDown, Note: 48}},
+	}}
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	got, err := ReadCaptureJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadCaptureJSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, c)
+	}
+
+	for _, bad := range []string{
+		`{"sample_rate": 0, "events": []}`,
+		`{"sample_rate": 48000, "events": [{"frame": 0, "type": "pitch_bend"}]}`,
+		`{"sample_rate": 48000, "events": [{"frame": -1, "type": "note_on", "note": 60}]}`,
+		`{"sample_rate": 48000, "events": [{"frame": 0, "type": "note_on", "note": 128}]}`,
+	} {
+		if _, err := ReadCaptureJSON(strings.NewReader(bad)); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestCaptureWriteSMF(t *testing.T) {
+	c := &Capture{SampleRate: 48000, Events: []CapturedEvent{
+		{Frame: 0, Event: Event{Kind: EventNoteOn, Note: 60, Velocity: 100}},
+		{Frame: 100, Event: Event{Kind: EventKeyDown, Note: 62}}, // dropped
+		{Frame: 24000, Event: Event{Kind: EventSustainPedal, Down: true}},
+		{Frame: 48000, Event: Event{Kind: EventNoteOff, Note: 60}},
+	}}
+	var buf bytes.Buffer
+	if err := c.WriteSMF(&buf); err != nil {
+		t.Fatalf("WriteSMF: %v", err)
+	}
+	want := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0x03, 0xC0,
+		'M', 'T', 'r', 'k', 0, 0, 0, 25,
+		0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20,
+		0x00, 0x90, 60, 100,
+		0x87, 0x40, 0xB0, 64, 127, // 960 ticks = 0.5 s
+		0x87, 0x40, 0x80, 60, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("SMF bytes\n got % X\nwant % X", buf.Bytes(), want)
+	}
+}
+
+func TestEventKindNames(t *testing.T) {
+	for k := EventNoteOn; k <= EventSoftPedal; k++ {
+		if got, ok := ParseEventKind(k.String()); !ok || got != k {
+			t.Fatalf("ParseEventKind(%q) = %v, %v", k.String(), got, ok)
+		}
+	}
+	if _, ok := ParseEventKind("EventKind(9)"); ok {
+		t.Fatalf("expected unknown name to fail")
+	}
+}
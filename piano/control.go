@@ -26,8 +26,14 @@ func (k *keyStateTracker) NoteOff(note int) {
 
 type hammerStrike struct {
 	note      int
+	velocity  int
 	strikePos float32
-	hammer    *Hammer
+	hardness  float32 // felt hardness scale the hammer was built with
+	hammer    Hammer
+
+	// provisional marks a strike launched by KeyPress ahead of its note-on,
+	// which confirm corrects to the played velocity.
+	provisional bool
 
 	// Attack noise state.
 	noiseRemaining int     // samples left in noise burst
@@ -42,24 +48,82 @@ type hammerStrike struct {
 type HammerExciter struct {
 	sampleRate int
 	params     *Params
-	softPedal  bool
+	softPedal  smoothedParam // una corda amount in [0,1]
 	active     [128][]*hammerStrike
+	free       []*hammerStrike // finished strikes kept for reuse
 }
 
+// strikesPerNote is the per-note capacity reserved for overlapping strikes
+// (fast repetitions within one contact and noise burst).
+const strikesPerNote = 4
+
 func NewHammerExciter(sampleRate int, params *Params) *HammerExciter {
 	return &HammerExciter{
 		sampleRate: sampleRate,
 		params:     params,
+		softPedal:  newSmoothedParam(sampleRate, controlSmoothing(params).SoftPedalMs, 0),
+	}
+}
+
+// reserve preallocates strikes for polyphony simultaneous hammer events and
+// the per-note event lists, so NoteOn does not allocate in steady state.
+func (h *HammerExciter) reserve(polyphony int) {
+	if polyphony < 1 {
+		return
+	}
+	strikes := make([]hammerStrike, polyphony)
+	h.free = make([]*hammerStrike, 0, polyphony)
+	for i := range strikes {
+		h.free = append(h.free, &strikes[i])
+	}
+	lists := make([]*hammerStrike, len(h.active)*strikesPerNote)
+	for note := range h.active {
+		base := note * strikesPerNote
+		h.active[note] = lists[base : base : base+strikesPerNote]
 	}
 }
 
+// newStrike takes a strike from the free list, allocating only when the pool
+// is exhausted.
+func (h *HammerExciter) newStrike() *hammerStrike {
+	if n := len(h.free); n > 0 {
+		s := h.free[n-1]
+		h.free = h.free[:n-1]
+		*s = hammerStrike{}
+		return s
+	}
+	return &hammerStrike{}
+}
+
+// SetSoftPedal switches the soft pedal fully down or up without gliding.
 func (h *HammerExciter) SetSoftPedal(down bool) {
-	h.softPedal = down
+	if down {
+		h.softPedal.jump(1)
+	} else {
+		h.softPedal.jump(0)
+	}
+}
+
+// SetSoftPedalAmount glides the soft pedal towards amount in [0,1] (partial
+// shift of the action).
+func (h *HammerExciter) SetSoftPedalAmount(amount float32) {
+	h.softPedal.set(clampf(amount, 0, 1))
+}
+
+// advanceControls moves smoothed controls forward by one rendered block.
+func (h *HammerExciter) advanceControls(frames int) {
+	h.softPedal.advance(frames)
 }
 
 func (h *HammerExciter) Trigger(note int, velocity int) {
+	h.trigger(note, velocity, 0, NoteOptions{})
+}
+
+// trigger starts a hammer event with an additive strike-position offset and
+// per-strike overrides and returns it (nil for notes outside 0..127).
+func (h *HammerExciter) trigger(note int, velocity int, strikeOffset float32, opts NoteOptions) *hammerStrike {
 	if note < 0 || note > 127 {
-		return
+		return nil
 	}
 	strikePos := float32(0.18)
 	softStrikeOffset := float32(0.08)
@@ -78,30 +142,32 @@ func (h *HammerExciter) Trigger(note int, velocity int) {
 			}
 		}
 	}
-
-	hammer := NewHammer(h.sampleRate, velocity)
-	if h.params != nil && hammer != nil {
-		hammer.ApplyInfluenceScales(
-			h.params.HammerStiffnessScale,
-			h.params.HammerExponentScale,
-			h.params.HammerDampingScale,
-			h.params.HammerInitialVelocityScale,
-			h.params.HammerContactTimeScale,
-		)
+	if opts.StrikePosition > 0.0 && opts.StrikePosition < 1.0 {
+		strikePos = opts.StrikePosition
 	}
 
-	if h.softPedal {
-		strikePos = minf(strikePos+softStrikeOffset, 0.95)
-		if hammer != nil {
-			hammer.SetHardnessScale(softHardness)
-		}
+	if strikeOffset != 0 {
+		strikePos = clampf(strikePos+strikeOffset, 0.02, 0.95)
 	}
 
-	strike := &hammerStrike{
-		note:      note,
-		strikePos: strikePos,
-		hammer:    hammer,
+	strike := h.newStrike()
+	strike.note = note
+	strike.velocity = velocity
+
+	hardness := float32(1.0)
+	if opts.Hardness > 0 {
+		hardness = opts.Hardness
 	}
+	if soft := h.softPedal.current; soft >= 1 {
+		strikePos = minf(strikePos+softStrikeOffset, 0.95)
+		hardness *= softHardness
+	} else if soft > 0 {
+		strikePos = minf(strikePos+soft*softStrikeOffset, 0.95)
+		hardness *= 1 - soft*(1-softHardness)
+	}
+	strike.hardness = hardness
+	h.initHammer(&strike.hammer, note, velocity, hardness)
+	strike.strikePos = strikePos
 
 	// Initialize attack noise burst if enabled.
 	if h.params != nil && h.params.AttackNoiseLevel > 0 && h.params.AttackNoiseDurationMs > 0 {
@@ -134,11 +200,94 @@ func (h *HammerExciter) Trigger(note int, velocity int) {
 			strike.noiseFilterA = expf(-3.14159 * frac)
 		}
 
-		// Seed PRNG from note + velocity for reproducibility but variation.
-		strike.noiseRNG = uint32(note)*2654435761 + uint32(velocity)*1597334677 + 1
+		// Seed PRNG from the preset seed + note + velocity for reproducibility but variation.
+		strike.noiseRNG = strikeNoiseSeed(h.params.Seed, note, velocity)
 	}
 
 	h.active[note] = append(h.active[note], strike)
+	return strike
+}
+
+// initHammer builds the hammer of a strike of note at velocity with the
+// preset hammer scales and a felt hardness scale.
+func (h *HammerExciter) initHammer(hammer *Hammer, note int, velocity int, hardness float32) {
+	hammer.init(h.sampleRate, velocity)
+	if h.params != nil {
+		stiff, exp, contact := noteHammerScales(h.params, note)
+		hammer.ApplyInfluenceScales(
+			stiff,
+			exp,
+			h.params.HammerDampingScale,
+			h.params.HammerInitialVelocityScale,
+			contact,
+		)
+	}
+	if hardness != 1.0 {
+		hammer.SetHardnessScale(hardness)
+	}
+}
+
+// confirm corrects the provisional strike of note to the played velocity:
+// a hammer still on the string is rebuilt for velocity and advanced to the
+// same fraction of its contact time, so the rest of the contact follows the
+// played velocity. It reports false when no provisional strike is left.
+func (h *HammerExciter) confirm(note int, velocity int) bool {
+	if note < 0 || note > 127 {
+		return false
+	}
+	for _, s := range h.active[note] {
+		if !s.provisional {
+			continue
+		}
+		s.provisional = false
+		if velocity == s.velocity {
+			return true
+		}
+		if s.hammer.InContact() {
+			progress := float64(s.hammer.contactSamples) / float64(max(s.hammer.contactMaxSamples, 1))
+			h.initHammer(&s.hammer, note, velocity, s.hardness)
+			for range int(progress * float64(s.hammer.contactMaxSamples)) {
+				if !s.hammer.InContact() {
+					break
+				}
+				s.hammer.Step(0)
+			}
+		}
+		if s.velocity > 0 {
+			s.noiseLevel *= float32(velocity) / float32(s.velocity)
+		}
+		s.velocity = velocity
+		return true
+	}
+	return false
+}
+
+// settle makes the provisional strikes of note final.
+func (h *HammerExciter) settle(note int) {
+	if note < 0 || note > 127 {
+		return
+	}
+	for _, s := range h.active[note] {
+		s.provisional = false
+	}
+}
+
+// strikeNoiseSeed derives a non-zero xorshift32 state from the engine seed and strike identity.
+func strikeNoiseSeed(seed int64, note int, velocity int) uint32 {
+	s := uint32(seed) ^ uint32(seed>>32)
+	s = s*2246822519 + uint32(note)*2654435761 + uint32(velocity)*1597334677 + 1
+	if s == 0 {
+		s = 1
+	}
+	return s
+}
+
+// isStriking reports whether a hammer event for note is still in flight.
+func (h *HammerExciter) isStriking(note int) bool {
+	if h == nil || note < 0 || note >= len(h.active) {
+		return false
+	}
+	return len(h.active[note]) > 0
 }
 
 // ProcessSample advances active hammer events by one sample and injects force into the string bank.
@@ -153,7 +302,7 @@ func (h *HammerExciter) ProcessSample(bank *StringBank) {
 		}
 		keep := events[:0]
 		for _, ev := range events {
-			if ev == nil || ev.hammer == nil {
+			if ev == nil {
 				continue
 			}
 			alive := false
@@ -181,8 +330,11 @@ func (h *HammerExciter) ProcessSample(bank *StringBank) {
 			}
 			if alive {
 				keep = append(keep, ev)
+			} else {
+				h.free = append(h.free, ev)
 			}
 		}
+		clear(events[len(keep):])
 		h.active[note] = keep
 	}
 }
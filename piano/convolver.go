@@ -1,8 +1,8 @@
 package piano
 
 import (
+	"bytes"
 	"fmt"
-	"os"
 
 	dspconv "github.com/cwbudde/algo-dsp/dsp/conv"
 	dspresample "github.com/cwbudde/algo-dsp/dsp/resample"
@@ -11,25 +11,60 @@ import (
 
 const DefaultIRWavPath = "assets/ir/default_96k.wav"
 
+// convolverPartSize is the partition length of the body and room
+// convolvers. Shorter input blocks are zero-padded to a full partition, so
+// Piano feeds them whole partitions only (see internalBlockSize).
+const convolverPartSize = 128
+
 // SoundboardConvolver implements partitioned convolution for the soundboard/body.
+// The first partition is convolved with the block it arrives in, so the
+// direct path has no latency.
 type SoundboardConvolver struct {
 	sampleRate int
 	partSize   int
 	irLen      int
+	backend    ConvolutionBackend
+
+	// The installed IR pair and a counter bumped on every install, so the
+	// mono render path can follow IR changes. irSwapMs is the crossfade of
+	// the SwapIR that installed the pair, -1 after SetIR.
+	leftIR   []float32
+	rightIR  []float32
+	irGen    int
+	irSwapMs float64
 
-	leftOLA  *dspconv.StreamingOverlapAddT[float32, complex64]
-	rightOLA *dspconv.StreamingOverlapAddT[float32, complex64]
+	leftOLA  blockConvolver
+	rightOLA blockConvolver
 
 	// Pre-allocated buffers for zero-allocation processing
 	leftOut  []float32
 	rightOut []float32
+	padded   []float32
+
+	history inputHistory
+
+	// Previous IR during a SwapIR crossfade; it keeps running until the
+	// fade completes.
+	fadeLeftOLA  blockConvolver
+	fadeRightOLA blockConvolver
+	fadeLeftOut  []float32
+	fadeRightOut []float32
+	fadeLen      int
+	fadePos      int
+
+	// Overlap state the swapped-in IR would carry from the input history,
+	// added to its output until used up.
+	primeLeft  []float32
+	primeRight []float32
+	primePos   int
 }
 
 // NewSoundboardConvolver creates a new soundboard convolver.
 func NewSoundboardConvolver(sampleRate int) *SoundboardConvolver {
 	c := &SoundboardConvolver{
 		sampleRate: sampleRate,
-		partSize:   128,
+		partSize:   convolverPartSize,
+		backend:    ConvolutionAuto,
 	}
 	c.SetIR([]float32{1.0}, []float32{1.0})
 	return c
@@ -38,10 +73,13 @@ func NewSoundboardConvolver(sampleRate int) *SoundboardConvolver {
 // Process convolves mono input with IR and returns stereo output.
 func (c *SoundboardConvolver) Process(input []float32) []float32 {
 	output := make([]float32, len(input)*2)
-	if len(input) == 0 {
-		return output
-	}
+	c.processInto(output, input)
+	return output
+}
 
+// processInto convolves mono input into the interleaved stereo output
+// (len(input)*2 samples) without allocating.
+func (c *SoundboardConvolver) processInto(output []float32, input []float32) {
 	// Handle arbitrary input lengths by processing in partSize blocks
 	processed := 0
 
@@ -55,14 +93,21 @@ func (c *SoundboardConvolver) Process(input []float32) []float32 {
 
 		// Pad to partSize if needed (for last block)
 		if blockLen < c.partSize {
-			padded := make([]float32, c.partSize)
-			copy(padded, block)
-			block = padded
+			clear(c.padded)
+			copy(c.padded, block)
+			block = c.padded
 		}
 
 		// Process block with zero-allocation streaming convolvers
+		c.history.record(block)
 		errL := c.leftOLA.ProcessBlockTo(c.leftOut, block)
 		errR := c.rightOLA.ProcessBlockTo(c.rightOut, block)
+		if errL == nil && errR == nil {
+			c.addPrime()
+			if c.fadeLeftOLA != nil {
+				errL = c.crossfade(block)
+			}
+		}
 		if errL != nil || errR != nil {
 			// Fallback: pass through for this block
 			for i := 0; i < blockLen; i++ {
@@ -81,12 +126,100 @@ func (c *SoundboardConvolver) Process(input []float32) []float32 {
 
 		processed = blockEnd
 	}
+}
 
-	return output
+// Latency returns the delay of the convolver output in frames. It is 0:
+// the first partition is convolved with the block it arrives in.
+func (c *SoundboardConvolver) Latency() int {
+	return 0
+}
+
+// SetBackend selects the convolution backend; a change rebuilds the
+// convolvers for the installed IR and clears their state.
+func (c *SoundboardConvolver) SetBackend(backend ConvolutionBackend) {
+	if backend == "" {
+		backend = ConvolutionAuto
+	}
+	if backend == c.backend {
+		return
+	}
+	c.backend = backend
+	c.SetIR(c.leftIR, c.rightIR)
 }
 
 // SetIR configures left/right impulse responses.
 func (c *SoundboardConvolver) SetIR(leftIR []float32, rightIR []float32) {
+	if !c.installIR(leftIR, rightIR) {
+		return
+	}
+	c.irSwapMs = -1
+
+	// Allocate output buffers
+	c.leftOut = make([]float32, c.partSize)
+	c.rightOut = make([]float32, c.partSize)
+	c.padded = make([]float32, c.partSize)
+
+	c.Reset()
+}
+
+// SwapIR replaces the impulse responses during playback. The new IR starts
+// with the tail the recent input would have left in it, and its output is
+// crossfaded linearly with the old one over crossfadeMs (0 = switch at the
+// next block). A swap during a running crossfade restarts it from the IR
+// that was fading in.
+func (c *SoundboardConvolver) SwapIR(leftIR []float32, rightIR []float32, crossfadeMs float64) {
+	if len(leftIR) == 0 {
+		leftIR = []float32{1.0}
+	}
+	if len(rightIR) == 0 {
+		rightIR = []float32{1.0}
+	}
+	oldLeft, oldRight := c.leftOLA, c.rightOLA
+	if !c.installIR(leftIR, rightIR) {
+		return
+	}
+	c.irSwapMs = crossfadeMs
+
+	// The new convolvers start empty; their missing overlap state is the
+	// part of history*IR that extends past the end of the history.
+	hist := c.history.samples()
+	c.primeLeft = convolveTail(hist, leftIR)
+	c.primeRight = convolveTail(hist, rightIR)
+	c.primePos = 0
+	c.history.resize(c.irLen, c.partSize)
+
+	c.fadeLeftOLA, c.fadeRightOLA = nil, nil
+	c.fadeLen = int(crossfadeMs * 0.001 * float64(c.sampleRate))
+	c.fadePos = 0
+	if c.fadeLen > 0 && oldLeft != nil && oldRight != nil {
+		c.fadeLeftOLA, c.fadeRightOLA = oldLeft, oldRight
+		c.fadeLeftOut = make([]float32, c.partSize)
+		c.fadeRightOut = make([]float32, c.partSize)
+	}
+}
+
+// convolveTail returns the samples of hist*ir after the end of hist.
+func convolveTail(hist []float64, ir []float32) []float32 {
+	if len(hist) == 0 || len(ir) < 2 {
+		return nil
+	}
+	ir64 := make([]float64, len(ir))
+	for i, v := range ir {
+		ir64[i] = float64(v)
+	}
+	full, err := dspconv.Convolve(hist, ir64)
+	if err != nil {
+		return nil
+	}
+	tail := make([]float32, len(full)-len(hist))
+	for i := range tail {
+		tail[i] = float32(full[len(hist)+i])
+	}
+	return tail
+}
+
+// installIR builds the streaming convolvers for an IR pair.
+func (c *SoundboardConvolver) installIR(leftIR []float32, rightIR []float32) bool {
 	if len(leftIR) == 0 {
 		leftIR = []float32{1.0}
 	}
@@ -94,13 +227,17 @@ func (c *SoundboardConvolver) SetIR(leftIR []float32, rightIR []float32) {
 		rightIR = []float32{1.0}
 	}
 
-	leftOLA, errL := dspconv.NewStreamingOverlapAdd32(leftIR, c.partSize)
-	rightOLA, errR := dspconv.NewStreamingOverlapAdd32(rightIR, c.partSize)
+	// Both channels use one backend, chosen for the longer IR.
+	backend := resolveConvolutionBackend(c.backend, max(len(leftIR), len(rightIR)))
+	leftOLA, errL := newBlockConvolver(leftIR, c.partSize, backend)
+	rightOLA, errR := newBlockConvolver(rightIR, c.partSize, backend)
 	if errL != nil || errR != nil {
-		return
+		return false
 	}
 	c.leftOLA = leftOLA
 	c.rightOLA = rightOLA
+	c.leftIR, c.rightIR = leftIR, rightIR
+	c.irGen++
 	c.irLen = len(leftIR)
 	if len(rightIR) > c.irLen {
 		c.irLen = len(rightIR)
@@ -108,42 +245,158 @@ func (c *SoundboardConvolver) SetIR(leftIR []float32, rightIR []float32) {
 	if c.irLen < 1 {
 		c.irLen = 1
 	}
+	return true
+}
 
-	// Allocate output buffers
-	c.leftOut = make([]float32, c.partSize)
-	c.rightOut = make([]float32, c.partSize)
+// midIR returns the mono mid (L+R)/2 of the installed IR pair.
+func (c *SoundboardConvolver) midIR() []float32 {
+	mid := make([]float32, max(len(c.leftIR), len(c.rightIR)))
+	for i, v := range c.leftIR {
+		mid[i] += 0.5 * v
+	}
+	for i, v := range c.rightIR {
+		mid[i] += 0.5 * v
+	}
+	return mid
+}
 
-	c.Reset()
+// inputHistory is a ring of the last input blocks fed to a convolver, long
+// enough to cover its IR, from which SwapIR primes a new IR.
+type inputHistory struct {
+	buf      []float32
+	next     int
+	partSize int
+}
+
+// resize keeps enough partSize blocks to cover irLen samples, preserving
+// the most recent ones.
+func (h *inputHistory) resize(irLen int, partSize int) {
+	blocks := (irLen + partSize - 1) / partSize
+	if h.partSize == partSize && len(h.buf) == blocks*partSize {
+		return
+	}
+	buf := make([]float32, blocks*partSize)
+	if old := len(h.buf) / max(h.partSize, 1); old > 0 && h.partSize == partSize {
+		keep := min(old, blocks)
+		for k := 0; k < keep; k++ {
+			src := ((h.next - keep + k + old) % old) * partSize
+			copy(buf[k*partSize:(k+1)*partSize], h.buf[src:src+partSize])
+		}
+		h.next = keep % blocks
+	} else {
+		h.next = 0
+	}
+	h.buf, h.partSize = buf, partSize
+}
+
+func (h *inputHistory) record(block []float32) {
+	blocks := len(h.buf) / max(h.partSize, 1)
+	if blocks == 0 {
+		return
+	}
+	copy(h.buf[h.next*h.partSize:], block)
+	h.next = (h.next + 1) % blocks
+}
+
+// samples returns the history oldest first.
+func (h *inputHistory) samples() []float64 {
+	out := make([]float64, len(h.buf))
+	blocks := len(h.buf) / max(h.partSize, 1)
+	for k := 0; k < blocks; k++ {
+		at := ((h.next + k) % blocks) * h.partSize
+		for i, v := range h.buf[at : at+h.partSize] {
+			out[k*h.partSize+i] = float64(v)
+		}
+	}
+	return out
+}
+
+// reset clears the history, sized for irLen samples.
+func (h *inputHistory) reset(irLen int, partSize int) {
+	*h = inputHistory{}
+	h.resize(irLen, partSize)
+}
+
+// addPrime adds the pending overlap state of a swapped-in IR to its output.
+func (c *SoundboardConvolver) addPrime() {
+	if c.primePos >= len(c.primeLeft) && c.primePos >= len(c.primeRight) {
+		return
+	}
+	for i := range c.leftOut {
+		if p := c.primePos + i; p < len(c.primeLeft) {
+			c.leftOut[i] += c.primeLeft[p]
+		}
+		if p := c.primePos + i; p < len(c.primeRight) {
+			c.rightOut[i] += c.primeRight[p]
+		}
+	}
+	c.primePos += len(c.leftOut)
 }
 
-// SetIRFromWAV loads a mono/stereo IR from WAV.
+// crossfade runs the previous IR on block and blends it into leftOut/rightOut.
+func (c *SoundboardConvolver) crossfade(block []float32) error {
+	if err := c.fadeLeftOLA.ProcessBlockTo(c.fadeLeftOut, block); err != nil {
+		return err
+	}
+	if err := c.fadeRightOLA.ProcessBlockTo(c.fadeRightOut, block); err != nil {
+		return err
+	}
+	for i := range c.leftOut {
+		g := float32(1)
+		if c.fadePos < c.fadeLen {
+			g = float32(c.fadePos) / float32(c.fadeLen)
+			c.fadePos++
+		}
+		c.leftOut[i] = g*c.leftOut[i] + (1-g)*c.fadeLeftOut[i]
+		c.rightOut[i] = g*c.rightOut[i] + (1-g)*c.fadeRightOut[i]
+	}
+	if c.fadePos >= c.fadeLen {
+		c.fadeLeftOLA, c.fadeRightOLA = nil, nil
+	}
+	return nil
+}
+
+// SetIRFromWAV loads a mono/stereo IR from WAV. Decoded IRs are kept in the
+// process-wide IR cache (see InvalidateIRCache).
 func (c *SoundboardConvolver) SetIRFromWAV(path string) error {
-	f, err := os.Open(path)
+	ir, err := irs.load(irCacheKey{path: path, version: statAsset(path), sampleRate: c.sampleRate}, func() ([][]float32, error) {
+		return c.readIRWAV(path)
+	})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	c.SetIR(ir[0], ir[1])
+	return nil
+}
+
+// readIRWAV decodes a mono/stereo IR WAV into a left/right pair at the
+// convolver rate.
+func (c *SoundboardConvolver) readIRWAV(path string) ([][]float32, error) {
+	data, err := ReadAsset(path)
+	if err != nil {
+		return nil, err
+	}
 
-	dec := wav.NewDecoder(f)
+	dec := wav.NewDecoder(bytes.NewReader(data))
 	if !dec.IsValidFile() {
-		return fmt.Errorf("invalid wav file: %s", path)
+		return nil, fmt.Errorf("invalid wav file: %s", path)
 	}
 	buf, err := dec.FullPCMBuffer()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if buf == nil || buf.Format == nil || buf.Format.NumChannels < 1 {
-		return fmt.Errorf("invalid wav buffer: %s", path)
+		return nil, fmt.Errorf("invalid wav buffer: %s", path)
 	}
 
 	numCh := buf.Format.NumChannels
 	srcRate := buf.Format.SampleRate
 	if srcRate <= 0 {
-		return fmt.Errorf("invalid wav sample-rate: %d", srcRate)
+		return nil, fmt.Errorf("invalid wav sample-rate: %d", srcRate)
 	}
 	frames := len(buf.Data) / numCh
 	if frames == 0 {
-		return fmt.Errorf("empty wav data: %s", path)
+		return nil, fmt.Errorf("empty wav data: %s", path)
 	}
 
 	left := make([]float32, frames)
@@ -163,14 +416,13 @@ func (c *SoundboardConvolver) SetIRFromWAV(path string) error {
 
 	left, err = c.resampleIfNeeded(left, srcRate)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	right, err = c.resampleIfNeeded(right, srcRate)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	c.SetIR(left, right)
-	return nil
+	return [][]float32{left, right}, nil
 }
 
 // Reset clears convolver history and overlap buffers.
@@ -181,21 +433,38 @@ func (c *SoundboardConvolver) Reset() {
 	if c.rightOLA != nil {
 		c.rightOLA.Reset()
 	}
+	c.fadeLeftOLA, c.fadeRightOLA = nil, nil
+	c.primeLeft, c.primeRight = nil, nil
+	c.history.reset(c.irLen, c.partSize)
 }
 
 // BodyConvolver implements mono-to-mono partitioned convolution for body coloration.
 type BodyConvolver struct {
 	sampleRate int
 	partSize   int
-	ola        *dspconv.StreamingOverlapAddT[float32, complex64]
+	backend    ConvolutionBackend
+	ir         []float32
+	ola        blockConvolver
 	out        []float32
+	padded     []float32
+	history    inputHistory
+
+	// Previous IR and pending overlap state of a SwapIR, as in
+	// SoundboardConvolver.
+	fadeOLA  blockConvolver
+	fadeOut  []float32
+	fadeLen  int
+	fadePos  int
+	prime    []float32
+	primePos int
 }
 
 // NewBodyConvolver creates a new mono body convolver with a passthrough IR.
 func NewBodyConvolver(sampleRate int) *BodyConvolver {
 	c := &BodyConvolver{
 		sampleRate: sampleRate,
-		partSize:   128,
+		partSize:   convolverPartSize,
+		backend:    ConvolutionAuto,
 	}
 	c.SetIR([]float32{1.0})
 	return c
@@ -204,10 +473,12 @@ func NewBodyConvolver(sampleRate int) *BodyConvolver {
 // Process convolves mono input with the body IR and returns mono output.
 func (c *BodyConvolver) Process(input []float32) []float32 {
 	output := make([]float32, len(input))
-	if len(input) == 0 {
-		return output
-	}
+	c.processInto(output, input)
+	return output
+}
 
+// processInto convolves input into output (same length) without allocating.
+func (c *BodyConvolver) processInto(output []float32, input []float32) {
 	processed := 0
 	for processed < len(input) {
 		blockEnd := processed + c.partSize
@@ -218,12 +489,20 @@ func (c *BodyConvolver) Process(input []float32) []float32 {
 		block := input[processed:blockEnd]
 
 		if blockLen < c.partSize {
-			padded := make([]float32, c.partSize)
-			copy(padded, block)
-			block = padded
+			clear(c.padded)
+			copy(c.padded, block)
+			block = c.padded
 		}
 
-		if err := c.ola.ProcessBlockTo(c.out, block); err != nil {
+		c.history.record(block)
+		err := c.ola.ProcessBlockTo(c.out, block)
+		if err == nil {
+			c.addPrime()
+			if c.fadeOLA != nil {
+				err = c.crossfade(block)
+			}
+		}
+		if err != nil {
 			copy(output[processed:blockEnd], input[processed:blockEnd])
 			processed = blockEnd
 			continue
@@ -232,7 +511,25 @@ func (c *BodyConvolver) Process(input []float32) []float32 {
 		copy(output[processed:blockEnd], c.out[:blockLen])
 		processed = blockEnd
 	}
-	return output
+}
+
+// Latency returns the delay of the convolver output in frames; 0, as for
+// SoundboardConvolver.
+func (c *BodyConvolver) Latency() int {
+	return 0
+}
+
+// SetBackend selects the convolution backend; a change rebuilds the
+// convolver for the installed IR and clears its state.
+func (c *BodyConvolver) SetBackend(backend ConvolutionBackend) {
+	if backend == "" {
+		backend = ConvolutionAuto
+	}
+	if backend == c.backend {
+		return
+	}
+	c.backend = backend
+	c.SetIR(c.ir)
 }
 
 // SetIR sets the mono body impulse response.
@@ -240,40 +537,114 @@ func (c *BodyConvolver) SetIR(ir []float32) {
 	if len(ir) == 0 {
 		ir = []float32{1.0}
 	}
-	ola, err := dspconv.NewStreamingOverlapAdd32(ir, c.partSize)
+	ola, err := newBlockConvolver(ir, c.partSize, c.backend)
 	if err != nil {
 		return
 	}
 	c.ola = ola
+	c.ir = ir
 	c.out = make([]float32, c.partSize)
+	c.padded = make([]float32, c.partSize)
 	c.Reset()
 }
 
+// SwapIR replaces the impulse response during playback the way
+// SoundboardConvolver.SwapIR does: the new IR starts primed from the
+// recent input and is crossfaded with the old one over crossfadeMs.
+func (c *BodyConvolver) SwapIR(ir []float32, crossfadeMs float64) {
+	if len(ir) == 0 {
+		ir = []float32{1.0}
+	}
+	ola, err := newBlockConvolver(ir, c.partSize, c.backend)
+	if err != nil {
+		return
+	}
+	old := c.ola
+	c.ola = ola
+	c.ir = ir
+	c.prime = convolveTail(c.history.samples(), ir)
+	c.primePos = 0
+	c.history.resize(len(ir), c.partSize)
+
+	c.fadeOLA = nil
+	c.fadeLen = int(crossfadeMs * 0.001 * float64(c.sampleRate))
+	c.fadePos = 0
+	if c.fadeLen > 0 && old != nil {
+		c.fadeOLA = old
+		c.fadeOut = make([]float32, c.partSize)
+	}
+}
+
+// addPrime adds the pending overlap state of a swapped-in IR to its output.
+func (c *BodyConvolver) addPrime() {
+	if c.primePos >= len(c.prime) {
+		return
+	}
+	for i := range c.out {
+		if p := c.primePos + i; p < len(c.prime) {
+			c.out[i] += c.prime[p]
+		}
+	}
+	c.primePos += len(c.out)
+}
+
+// crossfade runs the previous IR on block and blends it into out.
+func (c *BodyConvolver) crossfade(block []float32) error {
+	if err := c.fadeOLA.ProcessBlockTo(c.fadeOut, block); err != nil {
+		return err
+	}
+	for i := range c.out {
+		g := float32(1)
+		if c.fadePos < c.fadeLen {
+			g = float32(c.fadePos) / float32(c.fadeLen)
+			c.fadePos++
+		}
+		c.out[i] = g*c.out[i] + (1-g)*c.fadeOut[i]
+	}
+	if c.fadePos >= c.fadeLen {
+		c.fadeOLA = nil
+	}
+	return nil
+}
+
 // SetIRFromWAV loads a mono IR from a WAV file, resampling if needed.
+// Decoded IRs are kept in the process-wide IR cache (see InvalidateIRCache).
 func (c *BodyConvolver) SetIRFromWAV(path string, targetRate int) error {
-	f, err := os.Open(path)
+	ir, err := irs.load(irCacheKey{path: path, version: statAsset(path), sampleRate: targetRate, mono: true}, func() ([][]float32, error) {
+		mono, err := readMonoIRWAV(path, targetRate)
+		return [][]float32{mono}, err
+	})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	c.SetIR(ir[0])
+	return nil
+}
+
+// readMonoIRWAV decodes an IR WAV mixed to mono at targetRate.
+func readMonoIRWAV(path string, targetRate int) ([]float32, error) {
+	data, err := ReadAsset(path)
+	if err != nil {
+		return nil, err
+	}
 
-	dec := wav.NewDecoder(f)
+	dec := wav.NewDecoder(bytes.NewReader(data))
 	if !dec.IsValidFile() {
-		return fmt.Errorf("invalid wav file: %s", path)
+		return nil, fmt.Errorf("invalid wav file: %s", path)
 	}
 	buf, err := dec.FullPCMBuffer()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if buf == nil || buf.Format == nil || buf.Format.NumChannels < 1 {
-		return fmt.Errorf("invalid wav buffer: %s", path)
+		return nil, fmt.Errorf("invalid wav buffer: %s", path)
 	}
 
 	srcRate := buf.Format.SampleRate
 	numCh := buf.Format.NumChannels
 	frames := len(buf.Data) / numCh
 	if frames == 0 {
-		return fmt.Errorf("empty wav data: %s", path)
+		return nil, fmt.Errorf("empty wav data: %s", path)
 	}
 
 	// Mix to mono.
@@ -293,7 +664,7 @@ func (c *BodyConvolver) SetIRFromWAV(path string, targetRate int) error {
 			dspresample.WithQuality(dspresample.QualityBest),
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		in64 := make([]float64, len(mono))
 		for i, v := range mono {
@@ -305,9 +676,7 @@ func (c *BodyConvolver) SetIRFromWAV(path string, targetRate int) error {
 			mono[i] = float32(v)
 		}
 	}
-
-	c.SetIR(mono)
-	return nil
+	return mono, nil
 }
 
 // Reset clears convolver history.
@@ -315,6 +684,9 @@ func (c *BodyConvolver) Reset() {
 	if c.ola != nil {
 		c.ola.Reset()
 	}
+	c.fadeOLA = nil
+	c.prime = nil
+	c.history.reset(len(c.ir), c.partSize)
 }
 
 func (c *SoundboardConvolver) resampleIfNeeded(in []float32, inRate int) ([]float32, error) {
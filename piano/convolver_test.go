@@ -105,3 +105,94 @@ func TestConvolverLoadsMonoWavAsDualMono(t *testing.T) {
 		}
 	}
 }
+
+// decayingIR returns a deterministic exponentially decaying IR.
+func decayingIR(n int, seed uint32) []float32 {
+	ir := make([]float32, n)
+	state := seed
+	for i := range ir {
+		noise := float32(xorshift32(&state))/float32(math.MaxUint32)*2 - 1
+		ir[i] = noise * float32(math.Exp(-float64(i)/float64(n/4)))
+	}
+	ir[0] = 1
+	return ir
+}
+
+func TestConvolverSwapIRCrossfadesToPrimedIR(t *testing.T) {
+	irA := decayingIR(700, 1)
+	irB := decayingIR(500, 2)
+	input := make([]float32, 128*24)
+	for i := range input {
+		input[i] = float32(math.Sin(float64(i)*0.031)) * 0.5
+	}
+	split := 128 * 10
+
+	c := NewSoundboardConvolver(48000)
+	c.SetIR(irA, irA)
+	out := c.Process(input[:split])
+	c.SwapIR(irB, irB, 128.0/48.0) // 128-sample crossfade
+	out = append(out, c.Process(input[split:])...)
+
+	wantA := directConvolve(input, irA)
+	wantB := directConvolve(input, irB)
+	for i := range input {
+		want := wantA[i]
+		switch g := float32(i-split) / 128; {
+		case i >= split+128:
+			want = wantB[i]
+		case i >= split:
+			want = g*wantB[i] + (1-g)*wantA[i]
+		}
+		if d := math.Abs(float64(out[2*i] - want)); d > 1e-3 {
+			t.Fatalf("sample %d: got %f, want %f", i, out[2*i], want)
+		}
+	}
+}
+
+func TestConvolverSwapIRKeepsDirectPathWithoutLatency(t *testing.T) {
+	c := NewSoundboardConvolver(48000)
+	c.SetIR([]float32{1, 0.5}, []float32{1, 0.5})
+	_ = c.Process(make([]float32, 256))
+	c.SwapIR([]float32{0.25, 0.5}, []float32{0.25, 0.5}, 0)
+	input := make([]float32, 128)
+	input[3] = 1
+	out := c.Process(input)
+	want := []float32{0, 0, 0, 0.25, 0.5, 0}
+	for i, w := range want {
+		if math.Abs(float64(out[2*i]-w)) > 1e-6 {
+			t.Fatalf("swapped IR response delayed: %v", out[:12])
+		}
+	}
+}
+
+func TestLatencyMatchesImpulseDelay(t *testing.T) {
+	firstNonZero := func(x []float32, stride int) int {
+		for i := 0; i < len(x); i += stride {
+			if math.Abs(float64(x[i])) > 1e-6 {
+				return i / stride
+			}
+		}
+		return -1
+	}
+	// A 300-tap IR spans several partitions; its first tap sits at 3.
+	ir := make([]float32, 300)
+	ir[3], ir[299] = 1, 0.5
+	impulse := make([]float32, 2*convolverPartSize)
+	impulse[0] = 1
+
+	room := NewSoundboardConvolver(48000)
+	room.SetIR(ir, ir)
+	if got := firstNonZero(room.Process(impulse), 2); got != 3+room.Latency() {
+		t.Fatalf("room convolver impulse at %d, want %d", got, 3+room.Latency())
+	}
+	body := NewBodyConvolver(48000)
+	body.SetIR(ir)
+	if got := firstNonZero(body.Process(impulse), 1); got != 3+body.Latency() {
+		t.Fatalf("body convolver impulse at %d, want %d", got, 3+body.Latency())
+	}
+
+	p := NewPiano(48000, 16, NewDefaultParams())
+	if got, want := p.Latency(), room.Latency()+body.Latency(); got != want {
+		t.Fatalf("Piano.Latency() = %d, want %d", got, want)
+	}
+}
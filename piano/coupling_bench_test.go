@@ -146,7 +146,7 @@ func lookupEnvInt(name string) (int, bool) {
 func stringCountForNotes(notes []int) int {
 	total := 0
 	for _, note := range notes {
-		detunes, _ := defaultUnisonForNote(note)
+		detunes, _ := unisonForNote(nil, note)
 		total += len(detunes)
 	}
 	return total
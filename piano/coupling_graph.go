@@ -0,0 +1,68 @@
+package piano
+
+// CouplingNode describes one note of the string bank in a coupling graph.
+type CouplingNode struct {
+	Note        int
+	Fundamental float32
+	Strings     int
+}
+
+// CouplingEdge is a directed coupling path from one note to another.
+// Gain is the per-block force gain applied to the target before polyphony scaling.
+type CouplingEdge struct {
+	From int
+	To   int
+	Gain float32
+}
+
+// CouplingGraph is a snapshot of the string bank coupling topology.
+type CouplingGraph struct {
+	Mode  CouplingMode
+	Nodes []CouplingNode
+	Edges []CouplingEdge
+}
+
+// CouplingGraph returns a copy of the current coupling graph.
+// Nodes cover the full note range; edges are empty when coupling is off.
+func (sb *StringBank) CouplingGraph() CouplingGraph {
+	if sb == nil {
+		return CouplingGraph{Mode: CouplingModeOff}
+	}
+	g := CouplingGraph{
+		Mode:  sb.couplingMode,
+		Nodes: make([]CouplingNode, 0, sb.maxNote-sb.minNote+1),
+	}
+	if !sb.couplingEnabled {
+		g.Mode = CouplingModeOff
+	}
+	for note := sb.minNote; note <= sb.maxNote; note++ {
+		g.Nodes = append(g.Nodes, CouplingNode{
+			Note:        note,
+			Fundamental: sb.noteFundamental(note),
+			Strings:     sb.noteStringCount(note),
+		})
+		if !sb.couplingEnabled {
+			continue
+		}
+		for _, e := range sb.coupling[note] {
+			g.Edges = append(g.Edges, CouplingEdge{From: note, To: e.to, Gain: e.gain})
+		}
+	}
+	return g
+}
+
+// CouplingGraph returns a copy of the string bank coupling graph.
+func (r *RingingState) CouplingGraph() CouplingGraph {
+	if r == nil || r.bank == nil {
+		return CouplingGraph{Mode: CouplingModeOff}
+	}
+	return r.bank.CouplingGraph()
+}
+
+// CouplingGraph returns a copy of the engine's current string coupling graph.
+func (p *Piano) CouplingGraph() CouplingGraph {
+	if p == nil {
+		return CouplingGraph{Mode: CouplingModeOff}
+	}
+	return p.ringing.CouplingGraph()
+}
@@ -0,0 +1,90 @@
+package piano
+
+import "math"
+
+const (
+	// couplingGuardGrowthLimit is the largest block power, relative to the
+	// guard reference, that coupling may produce without external drive (+6 dB).
+	couplingGuardGrowthLimit = 4.0
+	// couplingGuardRefDecayDBPerS lets the reference follow decaying strings
+	// slowly enough that unison beating does not read as energy growth.
+	couplingGuardRefDecayDBPerS = 6.0
+	// couplingGuardDriveHoldSec keeps the reference open after a strike or
+	// an external coupling force while the excitation travels around the
+	// strings.
+	couplingGuardDriveHoldSec = 0.1
+	// couplingGuardCut is the coupling gain reduction per offending block.
+	couplingGuardCut = 0.5
+	// couplingGuardRecoverSec is the time constant of the gain recovery.
+	couplingGuardRecoverSec = 0.5
+	// couplingGuardPowerFloor ignores residual power of decayed strings.
+	couplingGuardPowerFloor = 1e-10
+)
+
+// couplingGuard is the energy safety valve of the string coupling (the
+// static/physical coupling graph and the unison crossfeed). Coupling should
+// only move energy between strings, so outside of external drive (hammer
+// strikes, InjectCouplingForce) the total block power of the bank must not
+// rise well above the level the drive left behind. Bridge resonance feeds
+// the bank's own output back into it and is not drive: counting it would
+// hold the guard open for as long as the sustain pedal is down. When it does, the coupling
+// gain is cut until the power is back within the bound, then recovers
+// smoothly.
+type couplingGuard struct {
+	ref       float64 // reference block power
+	driveHold int     // frames left in which drive may raise ref
+	holdLen   int
+	refDecay  float64 // per-frame power decay of ref
+	recoverA  float64 // per-frame recovery coefficient of gain
+	gain      float32
+	trips     int
+}
+
+func newCouplingGuard(sampleRate int) couplingGuard {
+	sr := float64(maxInt(1, sampleRate))
+	return couplingGuard{
+		holdLen:  int(couplingGuardDriveHoldSec * sr),
+		refDecay: math.Pow(10, -couplingGuardRefDecayDBPerS/(10*sr)),
+		recoverA: 1.0 / (couplingGuardRecoverSec * sr),
+		gain:     1,
+	}
+}
+
+// drive marks external energy input; the reference may rise for the hold time.
+func (g *couplingGuard) drive() {
+	g.driveHold = g.holdLen
+}
+
+// update checks the bank power of a finished block and adjusts the coupling
+// gain. armed is false while all coupling is off, so the reference keeps
+// tracking without tripping the valve.
+func (g *couplingGuard) update(power float64, frames int, armed bool) {
+	if g.driveHold > 0 {
+		g.driveHold -= frames
+		if power > g.ref {
+			g.ref = power
+		}
+		g.recover(frames)
+		return
+	}
+	if armed && power > couplingGuardPowerFloor && power > g.ref*couplingGuardGrowthLimit {
+		g.gain *= couplingGuardCut
+		g.trips++
+		return
+	}
+	if power < g.ref {
+		g.ref = math.Max(power, g.ref*math.Pow(g.refDecay, float64(frames)))
+	}
+	g.recover(frames)
+}
+
+func (g *couplingGuard) recover(frames int) {
+	if g.gain >= 1 {
+		return
+	}
+	a := float32(math.Min(1, g.recoverA*float64(frames)))
+	g.gain += (1 - g.gain) * a
+	if g.gain > 0.9999 {
+		g.gain = 1
+	}
+}
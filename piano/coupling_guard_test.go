@@ -0,0 +1,150 @@
+package piano
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCouplingGuardCutsGainOnUndrivenGrowthAndRecovers(t *testing.T) {
+	g := newCouplingGuard(1000)
+	g.drive()
+	g.update(1.0, 100, true)
+	g.update(0.5, 100, true)
+	if g.trips != 0 || g.gain != 1 {
+		t.Fatalf("guard tripped inside the drive window: trips=%d gain=%g", g.trips, g.gain)
+	}
+
+	g.update(0.9, 100, true)
+	g.update(3.4, 100, true)
+	if g.trips != 0 {
+		t.Fatalf("guard tripped within the growth limit")
+	}
+	g.update(4.5, 100, true)
+	if g.trips != 1 || g.gain != couplingGuardCut {
+		t.Fatalf("undriven growth: trips=%d gain=%g, want 1 trip and gain %g", g.trips, g.gain, couplingGuardCut)
+	}
+
+	for i := 0; i < 100; i++ {
+		g.update(0.5, 100, true)
+	}
+	if g.gain != 1 {
+		t.Fatalf("gain did not recover: %g", g.gain)
+	}
+
+	unarmed := newCouplingGuard(1000)
+	unarmed.update(100, 100, false)
+	if unarmed.trips != 0 {
+		t.Fatalf("unarmed guard tripped")
+	}
+}
+
+// bankPower returns the mean block power of the notes the bank kept active.
+func bankPower(sb *StringBank, frames int) float64 {
+	var sum float64
+	for _, note := range sb.activeNotes {
+		sum += sb.blockEnergy[note]
+	}
+	return sum / float64(frames)
+}
+
+// checkCouplingEnergyBound renders blocks and fails when the bank power
+// outside of drive windows exceeds the guard bound (with one block of
+// overshoot margin) or the output is not finite.
+func checkCouplingEnergyBound(t *testing.T, label string, p *Piano, blocks int) {
+	t.Helper()
+	const blockSize = 128
+	sb := p.ringing.bank
+	for b := 0; b < blocks; b++ {
+		out := p.Process(blockSize)
+		for _, s := range out {
+			if math.IsNaN(float64(s)) || math.IsInf(float64(s), 0) {
+				t.Fatalf("%s: non-finite output at block %d", label, b)
+			}
+		}
+		if sb.couplingGuard.driveHold > 0 {
+			continue
+		}
+		power := bankPower(sb, blockSize)
+		if limit := 2 * couplingGuardGrowthLimit * sb.couplingGuard.ref; power > couplingGuardPowerFloor && power > limit {
+			t.Fatalf("%s: block %d power %.4g exceeds bound %.4g", label, b, power, limit)
+		}
+	}
+}
+
+func TestCouplingEnergyStaysBoundedForRandomScenarios(t *testing.T) {
+	const sampleRate = 24000
+	rng := rand.New(rand.NewSource(1))
+	modes := []CouplingMode{CouplingModeStatic, CouplingModePhysical}
+	trips, resonanceTrips := 0, 0
+	for i := 0; i < 24; i++ {
+		// The last third runs with bridge resonance and the pedal down,
+		// where sympathetic coupling is most likely to run away.
+		resonance := i >= 16
+		params := NewDefaultParams()
+		params.CouplingMode = modes[rng.Intn(len(modes))]
+		params.CouplingAmount = 0.25 + 0.75*rng.Float32()
+		// Far beyond physical values: only the guard keeps these stable.
+		params.CouplingOctaveGain = 0.5 * rng.Float32()
+		params.CouplingFifthGain = 0.3 * rng.Float32()
+		params.CouplingMaxForce = float32(math.Pow(10, -3+5*rng.Float64()))
+		if rng.Intn(3) == 0 {
+			params.StringModel = StringModelModal
+		}
+		params.ResonanceEnabled = resonance
+		p := NewPiano(sampleRate, 16, params)
+		p.SetSustainPedal(resonance || rng.Intn(2) == 0)
+		for n := 1 + rng.Intn(5); n > 0; n-- {
+			p.NoteOn(21+rng.Intn(88), 40+rng.Intn(88))
+		}
+		label := string(params.CouplingMode)
+		if resonance {
+			label += "+resonance"
+		}
+		checkCouplingEnergyBound(t, label, p, sampleRate*2/128)
+		if resonance {
+			resonanceTrips += p.ringing.bank.couplingGuard.trips
+		} else {
+			trips += p.ringing.bank.couplingGuard.trips
+		}
+	}
+	if trips == 0 {
+		t.Fatalf("no scenario exercised the guard")
+	}
+	if resonanceTrips == 0 {
+		t.Fatalf("the guard never tripped with resonance and the pedal down")
+	}
+}
+
+func TestCouplingGuardStaysIdleOnDefaultRenders(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 8; i++ {
+		params := NewDefaultParams()
+		if i%2 == 1 {
+			params.CouplingMode = CouplingModePhysical
+		}
+		p := NewPiano(24000, 16, params)
+		p.SetSustainPedal(i%4 < 2)
+		for n := 0; n < 3; n++ {
+			p.NoteOn(28+rng.Intn(60), 50+rng.Intn(70))
+		}
+		for b := 0; b < 24000/128; b++ {
+			p.Process(128)
+		}
+		if trips := p.ringing.bank.couplingGuard.trips; trips != 0 {
+			t.Fatalf("scenario %d: guard tripped %d times on a default render", i, trips)
+		}
+	}
+}
+
+func TestSustainedTrebleCrossfeedStaysBounded(t *testing.T) {
+	params := NewDefaultParams()
+	params.CouplingEnabled = false
+	p := NewPiano(24000, 16, params)
+	p.SetSustainPedal(true)
+	p.NoteOn(105, 127)
+	checkCouplingEnergyBound(t, "treble", p, 24000*6/128)
+	if p.ringing.bank.couplingGuard.trips == 0 {
+		t.Fatalf("expected the unison crossfeed of the sustained treble note to trip the guard")
+	}
+}
@@ -0,0 +1,135 @@
+package piano
+
+import "math"
+
+// DefaultDamperReflection is the DWG loop gain of a string with its damper
+// resting on it.
+const DefaultDamperReflection = float32(0.92)
+
+// DefaultDamperlessFromNote is the lowest note without a damper on a
+// standard grand (F6: the top 20 keys).
+const DefaultDamperlessFromNote = 89
+
+// DefaultReleaseVelocity is the MIDI release velocity of NoteOff.
+const DefaultReleaseVelocity = 64
+
+// noteDamper tracks the felt damper of one note: how firmly it rests on
+// the strings (0 lifted, up to the register strength when resting) and how
+// fast it falls onto them at key-off and lifts off them again.
+type noteDamper struct {
+	level        smoothedParam
+	strength     float32 // register strength: 1 = full damper, 0 = no damper
+	sampleRate   int
+	engageMs     float32 // key-off time constant at DefaultReleaseVelocity
+	velocitySens float32
+	releaseCoeff float32
+
+	releaseVelocity int // of the next engagement
+}
+
+// newNoteDamper creates the damper of note, resting on the strings. Heavier
+// bass dampers on longer strings take longer to stop them: the key-off time
+// constant halves every 1/Params.DamperEngageRegisterSlope octaves up from
+// middle C.
+func newNoteDamper(sampleRate int, params *Params, note int) noteDamper {
+	d := noteDamper{
+		strength:        noteDamperStrength(params, note),
+		sampleRate:      sampleRate,
+		releaseVelocity: DefaultReleaseVelocity,
+	}
+	var releaseMs float32
+	if params != nil {
+		d.engageMs = params.DamperEngageMs * float32(math.Exp2(-float64(params.DamperEngageRegisterSlope)*float64(note-60)/12))
+		d.velocitySens = params.DamperVelocitySensitivity
+		releaseMs = params.DamperReleaseMs
+	}
+	d.level.setTime(sampleRate, releaseMs)
+	d.releaseCoeff = d.level.coeff
+	d.level.jump(d.strength)
+	return d
+}
+
+// retune applies params to the damper while it keeps its position on the
+// strings; the owning group's next set moves it to the new strength.
+func (d *noteDamper) retune(sampleRate int, params *Params, note int) {
+	level, velocity := d.level.current, d.releaseVelocity
+	*d = newNoteDamper(sampleRate, params, note)
+	d.level.jump(level)
+	d.releaseVelocity = velocity
+}
+
+// reset rests the damper fully on the strings, as after newNoteDamper.
+func (d *noteDamper) reset() {
+	d.level.coeff = d.releaseCoeff
+	d.level.jump(d.strength)
+	d.releaseVelocity = DefaultReleaseVelocity
+}
+
+// setReleaseVelocity sets the MIDI release velocity of the next damper
+// engagement; a faster key release drops the damper faster.
+func (d *noteDamper) setReleaseVelocity(velocity int) {
+	d.releaseVelocity = velocity
+}
+
+// engageTime returns the key-off time constant in ms at MIDI release
+// velocity: it halves for every 64 steps above DefaultReleaseVelocity at
+// sensitivity 1.
+func (d *noteDamper) engageTime(velocity int) float32 {
+	if d.engageMs <= 0 {
+		return 0
+	}
+	velocity = min(max(velocity, 1), 127)
+	return d.engageMs * float32(math.Exp2(-float64(d.velocitySens)*float64(velocity-DefaultReleaseVelocity)/64))
+}
+
+// set starts the damper moving onto (engaged) or off the strings and
+// reports whether it has already arrived. Engagements after the next one
+// (pedal releases) use DefaultReleaseVelocity again.
+func (d *noteDamper) set(engaged bool) bool {
+	target := float32(0)
+	d.level.coeff = d.releaseCoeff
+	if engaged {
+		target = d.strength
+		d.level.setTime(d.sampleRate, d.engageTime(d.releaseVelocity))
+	}
+	d.releaseVelocity = DefaultReleaseVelocity
+	d.level.set(target)
+	return d.level.settled()
+}
+
+// damperMix blends an undamped and a damped loop coefficient by the damper
+// amount, exactly at either end.
+func damperMix(undamped, damped, amount float32) float32 {
+	switch {
+	case amount <= 0:
+		return undamped
+	case amount >= 1:
+		return damped
+	}
+	return undamped + amount*(damped-undamped)
+}
+
+// noteDamperStrength resolves the damper strength of note: 0 from
+// Params.DamperlessFromNote up, else Params.DamperCurve clamped to [0,1],
+// 1 for an empty curve.
+func noteDamperStrength(params *Params, note int) float32 {
+	if params == nil {
+		return 1
+	}
+	if params.DamperlessFromNote > 0 && note >= params.DamperlessFromNote {
+		return 0
+	}
+	v, ok := params.DamperCurve.At(note)
+	if !ok {
+		return 1
+	}
+	return clampFloat32(v, 0, 1)
+}
+
+// noteDamperReflection resolves the damped DWG loop gain.
+func noteDamperReflection(params *Params) float32 {
+	if params == nil || params.DamperReflection <= 0 || params.DamperReflection > 1 {
+		return DefaultDamperReflection
+	}
+	return params.DamperReflection
+}
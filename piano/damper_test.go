@@ -0,0 +1,144 @@
+package piano
+
+import "testing"
+
+// releaseTailRMS strikes note, releases it after 200 ms and returns the
+// RMS of the window [from, from+50 ms) after the release.
+func releaseTailRMS(t *testing.T, params *Params, note int, fromMs int) float64 {
+	t.Helper()
+	params.ResonanceEnabled = false
+	params.CouplingEnabled = false
+	const sr = 48000
+	p := NewPiano(sr, 16, params)
+	p.NoteOn(note, 100)
+	p.Process(sr / 5)
+	p.NoteOff(note)
+	if fromMs > 0 {
+		p.Process(sr * fromMs / 1000)
+	}
+	return stereoRMS(p.Process(sr / 20))
+}
+
+func TestDamperCurveZeroKeepsNoteRingingAfterRelease(t *testing.T) {
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		damped := NewDefaultParams()
+		damped.StringModel = model
+		free := NewDefaultParams()
+		free.StringModel = model
+		free.DamperCurve = RegisterCurve{{Note: 60, Value: 0}}
+
+		d := releaseTailRMS(t, damped, 60, 150)
+		f := releaseTailRMS(t, free, 60, 150)
+		if !(f > 10*d) {
+			t.Fatalf("%s: release tail rms without damper=%e, with damper=%e; want >10x", model, f, d)
+		}
+	}
+}
+
+func TestDamperlessFromNoteRingsAfterRelease(t *testing.T) {
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		damped := NewDefaultParams()
+		damped.StringModel = model
+		damped.DamperlessFromNote = 0
+		free := NewDefaultParams()
+		free.StringModel = model
+
+		d := releaseTailRMS(t, damped, 96, 150)
+		f := releaseTailRMS(t, free, 96, 150)
+		if !(f > 10*d) {
+			t.Fatalf("%s: note 96 release tail rms by default=%e, with damper=%e; want >10x", model, f, d)
+		}
+
+		p := NewPiano(48000, 16, free)
+		if g := p.ringing.bank.activeGroup(DefaultDamperlessFromNote - 1); g == nil || g.isUndamped() {
+			t.Fatalf("%s: expected note %d to keep its damper", model, DefaultDamperlessFromNote-1)
+		}
+		if g := p.ringing.bank.activeGroup(DefaultDamperlessFromNote); g == nil || !g.isUndamped() {
+			t.Fatalf("%s: expected note %d to have no damper", model, DefaultDamperlessFromNote)
+		}
+	}
+}
+
+func TestDamperCurveZeroMarksGroupUndamped(t *testing.T) {
+	params := NewDefaultParams()
+	params.DamperlessFromNote = 0
+	params.DamperCurve = RegisterCurve{{Note: 96, Value: 1}, {Note: 97, Value: 0}}
+	p := NewPiano(48000, 16, params)
+	if g := p.ringing.bank.Group(96); g == nil || g.isUndamped() {
+		t.Fatalf("expected note 96 to keep its damper")
+	}
+	if g := p.ringing.bank.Group(100); g == nil || !g.isUndamped() {
+		t.Fatalf("expected note 100 without damper to be undamped")
+	}
+}
+
+func TestDamperEngageTimeSlowsKeyOffDecay(t *testing.T) {
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		instant := NewDefaultParams()
+		instant.StringModel = model
+		instant.DamperEngageMs = 0
+		slow := NewDefaultParams()
+		slow.StringModel = model
+		slow.DamperEngageMs = 40
+
+		i := releaseTailRMS(t, instant, 60, 100)
+		s := releaseTailRMS(t, slow, 60, 100)
+		if !(s > 2*i) {
+			t.Fatalf("%s: rms 100 ms after key-off with 40 ms damper=%e, instant=%e; want >2x", model, s, i)
+		}
+	}
+}
+
+func TestDamperEngageTimeFollowsRegisterAndReleaseVelocity(t *testing.T) {
+	params := NewDefaultParams()
+	bass := newNoteDamper(48000, params, 24)
+	treble := newNoteDamper(48000, params, 96)
+	if b := bass.engageTime(DefaultReleaseVelocity); b < 20 {
+		t.Fatalf("bass key-off time = %.1f ms, want tens of ms", b)
+	}
+	if tr := treble.engageTime(DefaultReleaseVelocity); tr > 5 || tr <= 0 {
+		t.Fatalf("treble key-off time = %.1f ms, want a few ms", tr)
+	}
+	slow, mid, fast := bass.engageTime(1), bass.engageTime(DefaultReleaseVelocity), bass.engageTime(127)
+	if !(slow > mid && mid > fast) {
+		t.Fatalf("key-off times at release velocity 1/64/127 = %.1f/%.1f/%.1f ms, want decreasing", slow, mid, fast)
+	}
+
+	params.DamperVelocitySensitivity = 0
+	flat := newNoteDamper(48000, params, 24)
+	if flat.engageTime(1) != flat.engageTime(127) {
+		t.Fatalf("expected release velocity to be ignored at sensitivity 0")
+	}
+}
+
+func TestNoteOffExFastReleaseDampsFaster(t *testing.T) {
+	render := func(velocity int) float64 {
+		params := NewDefaultParams()
+		params.ResonanceEnabled = false
+		params.CouplingEnabled = false
+		params.DamperEngageMs = 30
+		const sr = 48000
+		p := NewPiano(sr, 16, params)
+		p.NoteOn(48, 100)
+		p.Process(sr / 5)
+		p.NoteOffEx(48, velocity)
+		p.Process(sr / 20)
+		return stereoRMS(p.Process(sr / 20))
+	}
+	slow, fast := render(10), render(127)
+	if !(fast < slow) {
+		t.Fatalf("tail rms after fast release=%e, slow release=%e; want quieter", fast, slow)
+	}
+}
+
+func TestDamperReflectionControlsReleaseDecay(t *testing.T) {
+	hard := NewDefaultParams()
+	soft := NewDefaultParams()
+	soft.DamperReflection = 0.99
+
+	h := releaseTailRMS(t, hard, 60, 30)
+	s := releaseTailRMS(t, soft, 60, 30)
+	if !(s > h) {
+		t.Fatalf("release tail rms with damper_reflection 0.99=%e, 0.92=%e; want louder", s, h)
+	}
+}
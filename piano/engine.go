@@ -1,31 +1,150 @@
 package piano
 
+// internalBlockSize is the fixed block the engine renders in, one convolver
+// partition. Process slices these blocks to any host block length, so the
+// output depends only on when events arrive, not on how the host blocks
+// its calls.
+const internalBlockSize = convolverPartSize
+
 // Piano is the global engine managing note control, excitation, and ringing state.
 type Piano struct {
 	sampleRate    int
+	maxPolyphony  int
 	params        *Params
 	keys          *keyStateTracker
 	hammerExciter *HammerExciter
 	ringing       *RingingState
 	bodyConvolver *BodyConvolver
+	bodyMorph     *bodyMorph
 	roomConvolver *SoundboardConvolver
 	resonance     *ResonanceEngine
+	variation     *strikeVariation
+	tuningDrift   *tuningDrift
+	preTrigger    *preTrigger
+	outputEQ      *outputEQ
+	bassMono      *bassMono
+	boardNL       *soundboardNonlinearity
+	knock         *hammerKnock
 	sustainPedal  bool
-	softPedal     bool
+
+	// IR files the body, lid-closed and room convolvers were loaded from
+	// ("" = passthrough or set from buffers) and their versions at load
+	// time, so Reset can keep them while the files are unchanged.
+	bodyIRPath          string
+	bodyIRClosedPath    string
+	roomIRPath          string
+	bodyIRVersion       assetVersion
+	bodyIRClosedVersion assetVersion
+	roomIRVersion       assetVersion
+
+	// Smoothed output stage controls, primed from params on the first block.
+	// The close and room mic levels are per channel (left, right).
+	outGain    smoothedParam
+	closeLevel [2]smoothedParam
+	roomLevel  [2]smoothedParam
+	mixPrimed  bool
+	closeDelay micDelay
+	roomDelay  micDelay
+
+	// blockLen is the interleaved length of the current internal block and
+	// pendingFrom where the part the host has not taken yet starts;
+	// monoPending marks a block rendered by ProcessMono.
+	blockLen    int
+	pendingFrom int
+	monoPending bool
+
+	// Sample-accurate events (ScheduleEvent), sorted by absolute frame, and
+	// the frames returned by Process and rendered internally so far.
+	scheduled      []scheduledEvent
+	framesOut      int64
+	framesRendered int64
+	monoBlock      []float32
+
+	// Event capture (StartCapture): the log, the output frame it started
+	// on, and the frame within the block renderStrings is applying
+	// scheduled events at.
+	capture      *Capture
+	captureStart int64
+	eventPos     int
+
+	// Per-block scratch for the convolver and output stages.
+	bodyBlock   []float32
+	roomBlock   []float32
+	stereoBlock []float32
+	closeStem   []float32
+	roomStem    []float32
+
+	// Mono render path (ProcessMono): a convolver on the mid of the room
+	// IR, following the room IR changes (monoRoomIR is the room
+	// convolver's IR generation it was built from).
+	monoRoom      *BodyConvolver
+	monoRoomIR    int
+	monoRoomBlock []float32
+	monoOutBlock  []float32
 }
 
 // NewPiano creates a new piano engine.
 func NewPiano(sampleRate int, maxPolyphony int, params *Params) *Piano {
-	_ = maxPolyphony // Retained in API for compatibility; ringing state is persistent.
 	p := &Piano{
-		sampleRate:    sampleRate,
-		params:        params,
-		keys:          newKeyStateTracker(),
-		hammerExciter: NewHammerExciter(sampleRate, params),
-		ringing:       NewRingingState(sampleRate, params),
-		bodyConvolver: NewBodyConvolver(sampleRate),
-		roomConvolver: NewSoundboardConvolver(sampleRate),
+		sampleRate:   sampleRate,
+		maxPolyphony: maxPolyphony,
+	}
+	p.setup(params, nil)
+	return p
+}
+
+// Reset returns the engine to the state NewPiano(sampleRate, maxPolyphony,
+// params) starts in, so one engine can render many presets in turn. The
+// string bank is retuned and silenced in place, and impulse responses
+// loaded from the files params names again are kept rather than reloaded
+// and resampled. IRs installed from buffers (SetBodyIR, SetRoomIR, ...),
+// scheduled events and a running capture are dropped.
+func (p *Piano) Reset(params *Params) {
+	warm := *p
+	*p = Piano{
+		sampleRate:    warm.sampleRate,
+		maxPolyphony:  warm.maxPolyphony,
+		bodyBlock:     warm.bodyBlock,
+		roomBlock:     warm.roomBlock,
+		stereoBlock:   warm.stereoBlock,
+		closeStem:     warm.closeStem,
+		roomStem:      warm.roomStem,
+		monoBlock:     warm.monoBlock,
+		monoRoomBlock: warm.monoRoomBlock,
+		monoOutBlock:  warm.monoOutBlock,
+	}
+	p.setup(params, &warm)
+}
+
+// setup builds the engine state for params. With a warm engine the string
+// bank and the IR convolvers of unchanged IR files are reused.
+func (p *Piano) setup(params *Params, warm *Piano) {
+	sampleRate := p.sampleRate
+	// Ringing state is persistent per string; maxPolyphony sizes the pool of
+	// in-flight hammer strikes so NoteOn does not allocate.
+	p.params = params
+	p.keys = newKeyStateTracker()
+	p.hammerExciter = NewHammerExciter(sampleRate, params)
+	if warm != nil && warm.ringing.bank.ApplyParams(params) {
+		warm.ringing.bank.reset()
+		p.ringing = warm.ringing
+	} else {
+		p.ringing = NewRingingState(sampleRate, params)
+	}
+	p.bodyMorph = newBodyMorph(sampleRate, 1.0)
+	p.variation = newStrikeVariation(params)
+	p.tuningDrift = newTuningDrift(sampleRate, params)
+	if params != nil {
+		p.preTrigger = newPreTrigger(sampleRate, params.HammerPreTriggerMs)
+	}
+	p.hammerExciter.reserve(p.maxPolyphony)
+	smoothing := controlSmoothing(params)
+	p.outGain = newSmoothedParam(sampleRate, smoothing.OutputGainMs, 1)
+	for ch := range 2 {
+		p.closeLevel[ch] = newSmoothedParam(sampleRate, smoothing.IRMixMs, 1)
+		p.roomLevel[ch] = newSmoothedParam(sampleRate, smoothing.IRMixMs, 0)
 	}
+	p.bodyMorph.lid.setTime(sampleRate, smoothing.LidMs)
 	if params == nil || params.ResonanceEnabled {
 		gain := float32(0.00018)
 		perNoteFilter := true
@@ -36,55 +155,243 @@ func NewPiano(sampleRate int, maxPolyphony int, params *Params) *Piano {
 			perNoteFilter = params.ResonancePerNoteFilter
 		}
 		p.resonance = NewResonanceEngine(sampleRate, gain, perNoteFilter)
+		if params != nil {
+			p.resonance.SetBloomShape(params.ResonanceAttackMs, params.ResonanceSaturation)
+		}
 	}
-	// Load body IR from file if specified.
-	if params != nil && params.BodyIRWavPath != "" {
-		_ = p.bodyConvolver.SetIRFromWAV(params.BodyIRWavPath, sampleRate)
+	if params != nil {
+		p.outputEQ = newOutputEQ(sampleRate, params.OutputEQ)
+		p.bassMono = newBassMono(sampleRate, params.BassMonoHz)
+		p.boardNL = newSoundboardNonlinearity(sampleRate, params)
+		p.knock = newHammerKnock(sampleRate, params)
+		p.bodyMorph.setLid(params.LidPosition)
+		p.closeDelay = newMicDelay(sampleRate, params.CloseMic.DelayMs)
+		p.roomDelay = newMicDelay(sampleRate, params.RoomMic.DelayMs)
 	}
-	// Load room IR: prefer RoomIRWavPath, fall back to legacy IRWavPath.
+	p.tuningDrift.apply(p.ringing)
+
+	var bodyPath, closedPath, roomPath string
 	if params != nil {
-		roomPath := params.RoomIRWavPath
+		bodyPath = params.BodyIRWavPath
+		closedPath = params.BodyIRClosedWavPath
+		// Prefer RoomIRWavPath, fall back to legacy IRWavPath.
+		roomPath = params.RoomIRWavPath
 		if roomPath == "" {
 			roomPath = params.IRWavPath
 		}
-		if roomPath != "" {
-			_ = p.roomConvolver.SetIRFromWAV(roomPath)
+	}
+	// Load body IR from file if specified. Reused convolvers only rebuild
+	// when the backend changed; a rewritten file is loaded again.
+	backend := p.convolutionBackend()
+	bodyVersion, closedVersion, roomVersion := statAsset(bodyPath), statAsset(closedPath), statAsset(roomPath)
+	if warm != nil && bodyPath != "" && warm.bodyIRPath == bodyPath && warm.bodyIRVersion == bodyVersion {
+		p.bodyConvolver, p.bodyIRPath, p.bodyIRVersion = warm.bodyConvolver, bodyPath, bodyVersion
+		p.bodyConvolver.Reset()
+		p.bodyConvolver.SetBackend(backend)
+	} else {
+		p.bodyConvolver = p.newBodyConvolver()
+		if bodyPath != "" && p.bodyConvolver.SetIRFromWAV(bodyPath, sampleRate) == nil {
+			p.bodyIRPath, p.bodyIRVersion = bodyPath, bodyVersion
+		}
+	}
+	if warm != nil && closedPath != "" && warm.bodyIRClosedPath == closedPath && warm.bodyIRClosedVersion == closedVersion {
+		closed := warm.bodyMorph.closed
+		closed.Reset()
+		closed.SetBackend(backend)
+		p.bodyMorph.setClosedIR(closed)
+		p.bodyIRClosedPath, p.bodyIRClosedVersion = closedPath, closedVersion
+	} else if closedPath != "" {
+		closed := p.newBodyConvolver()
+		if err := closed.SetIRFromWAV(closedPath, sampleRate); err == nil {
+			p.bodyMorph.setClosedIR(closed)
+			p.bodyIRClosedPath, p.bodyIRClosedVersion = closedPath, closedVersion
 		}
 	}
-	return p
+	if warm != nil && roomPath != "" && warm.roomIRPath == roomPath && warm.roomIRVersion == roomVersion {
+		p.roomConvolver, p.roomIRPath, p.roomIRVersion = warm.roomConvolver, roomPath, roomVersion
+		p.roomConvolver.Reset()
+		p.roomConvolver.SetBackend(backend)
+		if warm.monoRoom != nil {
+			p.monoRoom, p.monoRoomIR = warm.monoRoom, warm.monoRoomIR
+			p.monoRoom.Reset()
+			p.monoRoom.SetBackend(backend)
+		}
+	} else {
+		p.roomConvolver = NewSoundboardConvolver(sampleRate)
+		p.roomConvolver.SetBackend(backend)
+		if roomPath != "" && p.roomConvolver.SetIRFromWAV(roomPath) == nil {
+			p.roomIRPath, p.roomIRVersion = roomPath, roomVersion
+		}
+	}
+}
+
+// convolutionBackend is the backend of the engine's convolvers.
+func (p *Piano) convolutionBackend() ConvolutionBackend {
+	if p.params == nil {
+		return ConvolutionAuto
+	}
+	return p.params.ConvolutionBackend
+}
+
+// newBodyConvolver returns a mono convolver using the engine's backend.
+func (p *Piano) newBodyConvolver() *BodyConvolver {
+	c := NewBodyConvolver(p.sampleRate)
+	c.SetBackend(p.convolutionBackend())
+	return c
+}
+
+const minOutputGain = 1e-6
+
+// NoteOptions overrides the hammer behaviour of a single strike. Zero values
+// keep the preset and per-note settings.
+type NoteOptions struct {
+	// StrikePosition replaces the strike position as a fraction of the
+	// string length in (0,1). Soft pedal and variation offsets still apply.
+	StrikePosition float32
+	// Hardness scales the felt hardness (1 = preset); combined with the soft
+	// pedal and limited to the hammer's [0.5,1.2] range.
+	Hardness float32
+	// Mute strikes without lifting the damper, so the string only rings while
+	// the sustain pedal is down (col legno / muted effects).
+	Mute bool
+	// HarmonicNode plays a harmonic (flageolet): the string is lightly
+	// touched at 1/HarmonicNode of its length during the strike, so 2 sounds
+	// the octave and 3 the twelfth. 0 disables it; values outside
+	// [2, MaxPreparationHarmonic] are ignored.
+	HarmonicNode int
 }
 
 // NoteOn triggers a new note.
+// With Params.VariationAmount > 0 each strike is humanized with small
+// velocity, strike-position and unison detune offsets.
 func (p *Piano) NoteOn(note int, velocity int) {
+	p.NoteOnEx(note, velocity, NoteOptions{})
+}
+
+// NoteOnEx triggers a note like NoteOn with per-strike overrides. After a
+// KeyPress whose hammer was pre-triggered it corrects that strike to
+// velocity instead while the strike still runs; its strike position and
+// hardness options then no longer apply.
+func (p *Piano) NoteOnEx(note int, velocity int, opts NoteOptions) {
+	p.record(Event{Kind: EventNoteOn, Note: note, Velocity: velocity, Options: opts})
 	p.keys.NoteOn(note, velocity)
-	p.ringing.SetKeyDown(note, true)
-	p.hammerExciter.Trigger(note, velocity)
+	if !opts.Mute {
+		p.ringing.SetKeyDown(note, true)
+	}
+	p.ringing.SetHarmonicTouch(note, opts.HarmonicNode, int(harmonicTouchSeconds*float64(p.sampleRate)))
+	preStruck := p.confirmKeyPress(note, velocity)
+	strikeOffset := float32(0)
+	if p.variation.enabled() {
+		off := p.variation.next(p.ringing.StringCount(note))
+		p.ringing.SetDetuneDrift(note, off.detune)
+		if velocity > 0 {
+			velocity += off.velocity
+			if velocity < 1 {
+				velocity = 1
+			}
+			if velocity > 127 {
+				velocity = 127
+			}
+		}
+		strikeOffset = off.strikePos
+	}
+	if preStruck && p.hammerExciter.confirm(note, velocity) {
+		return
+	}
+	// A note-on arriving after its provisional strike has ended has nothing
+	// left to correct, so it strikes at the played velocity as usual.
+	p.hammerExciter.trigger(note, velocity, strikeOffset, opts)
+	p.knock.strike(p.eventPos, note, velocity)
 }
 
 // KeyDown presses a key without hammer excitation (damper lift only).
 func (p *Piano) KeyDown(note int) {
+	p.record(Event{Kind: EventKeyDown, Note: note})
 	p.keys.NoteOn(note, 0)
 	p.ringing.SetKeyDown(note, true)
 }
 
-// NoteOff releases a note.
+// NoteOff releases a note at DefaultReleaseVelocity.
 func (p *Piano) NoteOff(note int) {
+	p.NoteOffEx(note, DefaultReleaseVelocity)
+}
+
+// NoteOffEx releases a note at MIDI release velocity (0 =
+// DefaultReleaseVelocity): faster releases drop the damper faster, scaled
+// by Params.DamperVelocitySensitivity.
+func (p *Piano) NoteOffEx(note int, velocity int) {
+	p.record(Event{Kind: EventNoteOff, Note: note, Velocity: velocity})
+	if velocity <= 0 {
+		velocity = DefaultReleaseVelocity
+	}
 	p.keys.NoteOff(note)
+	p.releaseKeyPress(note)
+	p.ringing.SetReleaseVelocity(note, velocity)
 	p.ringing.SetKeyDown(note, false)
 }
 
+// ActiveVoices returns the number of notes whose strings are still ringing
+// or whose hammer strike is still in flight. Held keys and notes sustained
+// by the pedal count as active; the body and room convolution tails do not.
+func (p *Piano) ActiveVoices() int {
+	n := 0
+	for note := 0; note < 128; note++ {
+		if p.ringing.isNoteActive(note) || p.hammerExciter.isStriking(note) {
+			n++
+		}
+	}
+	return n
+}
+
 // SetSustainPedal sets sustain pedal state (true = down, false = up).
 func (p *Piano) SetSustainPedal(down bool) {
+	p.record(Event{Kind: EventSustainPedal, Down: down})
 	p.sustainPedal = down
 	p.ringing.SetSustain(down)
+	p.resonance.SetSustain(down)
 }
 
 // SetSoftPedal sets una corda / soft pedal state (true = down, false = up).
 func (p *Piano) SetSoftPedal(down bool) {
-	p.softPedal = down
+	p.record(Event{Kind: EventSoftPedal, Down: down})
 	p.hammerExciter.SetSoftPedal(down)
 }
 
+// SetSoftPedalAmount sets a partial una corda shift in [0,1]; strikes use the
+// amount reached by the ControlSmoothing.SoftPedalMs glide.
+func (p *Piano) SetSoftPedalAmount(amount float32) {
+	p.hammerExciter.SetSoftPedalAmount(amount)
+}
+
+// SetOutputGain changes the output gain, gliding over
+// ControlSmoothing.OutputGainMs. Gains are floored at -120 dB because a zero
+// Params.OutputGain selects the default gain.
+func (p *Piano) SetOutputGain(gain float32) {
+	if p.params == nil {
+		p.params = NewDefaultParams()
+	}
+	p.params.OutputGain = maxf(gain, minOutputGain)
+}
+
+// SetIRMix changes the body dry and room wet levels, gliding over
+// ControlSmoothing.IRMixMs.
+func (p *Piano) SetIRMix(bodyDry float32, roomWet float32) {
+	if p.params == nil {
+		p.params = NewDefaultParams()
+	}
+	p.params.BodyDryMix = maxf(bodyDry, 0)
+	p.params.RoomWetMix = maxf(roomWet, 0)
+}
+
+// SetCouplingAmount changes the string coupling strength in [0,1], gliding
+// over ControlSmoothing.CouplingAmountMs.
+func (p *Piano) SetCouplingAmount(amount float32) {
+	p.ringing.SetCouplingAmount(amount)
+	if p.params != nil {
+		p.params.CouplingAmount = clampf(amount, 0, 1)
+	}
+}
+
 // SetCouplingMode updates string-bank coupling mode at runtime.
 func (p *Piano) SetCouplingMode(mode CouplingMode) bool {
 	if p == nil || p.ringing == nil {
@@ -99,6 +406,7 @@ func (p *Piano) SetCouplingMode(mode CouplingMode) bool {
 }
 
 // SetStringModel switches string core (`dwg` or `modal`) and reinitializes ringing state.
+// Notes with a NoteParams.StringModel override keep their own core.
 func (p *Piano) SetStringModel(model StringModel) bool {
 	if p == nil {
 		return false
@@ -123,14 +431,16 @@ func (p *Piano) SetStringModel(model StringModel) bool {
 		velocity = p.keys.lastVelocity
 	}
 	sustain := p.sustainPedal
-	soft := p.softPedal
+	soft := p.hammerExciter.softPedal.target
 
 	p.params.StringModel = model
 	p.keys = newKeyStateTracker()
 	p.hammerExciter = NewHammerExciter(p.sampleRate, p.params)
-	p.hammerExciter.SetSoftPedal(soft)
+	p.hammerExciter.reserve(p.maxPolyphony)
+	p.hammerExciter.softPedal.jump(soft)
 	p.ringing = NewRingingState(p.sampleRate, p.params)
 	p.ringing.SetSustain(sustain)
+	p.tuningDrift.apply(p.ringing)
 	for note := 0; note < 128; note++ {
 		if !held[note] {
 			continue
@@ -141,36 +451,310 @@ func (p *Piano) SetStringModel(model StringModel) bool {
 	return true
 }
 
+// SetNoteStringModel switches one note's string core (`dwg` or `modal`)
+// and records it as a per-note override, leaving the other notes ringing.
+func (p *Piano) SetNoteStringModel(note int, model StringModel) bool {
+	if p == nil || p.ringing == nil {
+		return false
+	}
+	if p.params == nil {
+		p.params = NewDefaultParams()
+	}
+	if !p.ringing.bank.SetNoteStringModel(note, model, p.params) {
+		return false
+	}
+	if p.params.PerNote == nil {
+		p.params.PerNote = make(map[int]*NoteParams)
+	}
+	np := p.params.PerNote[note]
+	if np == nil {
+		np = &NoteParams{}
+		p.params.PerNote[note] = np
+	}
+	np.StringModel = model
+	p.tuningDrift.apply(p.ringing)
+	return true
+}
+
+// NoteStringModel returns the string core the note rings on.
+func (p *Piano) NoteStringModel(note int) StringModel {
+	if p == nil {
+		return StringModelDWG
+	}
+	return p.ringing.NoteStringModel(note)
+}
+
+// ModalGroup returns the note's modal string group for runtime tuning, or
+// nil when the note rings on the DWG core.
+func (p *Piano) ModalGroup(note int) *ModalStringGroup {
+	if p == nil || p.ringing == nil || p.ringing.bank == nil {
+		return nil
+	}
+	return p.ringing.bank.ModalGroup(note)
+}
+
 // SetIR sets the room impulse response from pre-computed stereo buffers.
 // Deprecated: Use SetRoomIR instead.
 func (p *Piano) SetIR(left, right []float32) {
 	p.roomConvolver.SetIR(left, right)
+	p.roomIRPath = ""
 }
 
 // SetBodyIR sets the mono body impulse response from pre-computed buffer.
 func (p *Piano) SetBodyIR(ir []float32) {
 	p.bodyConvolver.SetIR(ir)
+	p.bodyIRPath = ""
+}
+
+// SetBodyIRClosed sets the lid-closed body IR variant from a pre-computed buffer.
+// An empty buffer removes the variant so only the primary body IR is used.
+func (p *Piano) SetBodyIRClosed(ir []float32) {
+	p.bodyIRClosedPath = ""
+	if len(ir) == 0 {
+		p.bodyMorph.setClosedIR(nil)
+		return
+	}
+	closed := p.newBodyConvolver()
+	closed.SetIR(ir)
+	p.bodyMorph.setClosedIR(closed)
+}
+
+// SetLidPosition crossfades between the lid-closed (0) and primary (1) body
+// IRs. Changes are smoothed over a few tens of milliseconds.
+func (p *Piano) SetLidPosition(pos float32) {
+	p.bodyMorph.setLid(pos)
+	if p.params != nil {
+		p.params.LidPosition = clampf(pos, 0, 1)
+	}
+}
+
+// SetConvolutionBackend switches the body and room convolution backend.
+// The convolvers are rebuilt for their IRs, which cuts off the ringing
+// body and room tails.
+func (p *Piano) SetConvolutionBackend(backend ConvolutionBackend) {
+	if p.params != nil {
+		p.params.ConvolutionBackend = backend
+	}
+	p.bodyConvolver.SetBackend(backend)
+	if p.bodyMorph.closed != nil {
+		p.bodyMorph.closed.SetBackend(backend)
+	}
+	p.roomConvolver.SetBackend(backend)
+	if p.monoRoom != nil {
+		p.monoRoom.SetBackend(backend)
+	}
 }
 
 // SetRoomIR sets the stereo room impulse response from pre-computed buffers.
 func (p *Piano) SetRoomIR(left, right []float32) {
 	p.roomConvolver.SetIR(left, right)
+	p.roomIRPath = ""
 }
 
-// Process renders a block of audio samples (stereo interleaved).
+// SwapRoomIR replaces the room impulse response during playback,
+// crossfading from the old one over crossfadeMs without a gap in the tail.
+func (p *Piano) SwapRoomIR(left, right []float32, crossfadeMs float64) {
+	p.roomConvolver.SwapIR(left, right, crossfadeMs)
+	p.roomIRPath = ""
+}
+
+// Latency returns the total algorithmic latency of the output in frames:
+// the delay of the body and room convolvers (the engine has no lookahead or
+// oversampling). Hosts report it for delay compensation. Event timing
+// jitter from internal block quantization (up to internalBlockSize-1
+// frames, see Process) is not included.
+func (p *Piano) Latency() int {
+	return p.bodyConvolver.Latency() + p.roomConvolver.Latency()
+}
+
+// Process renders a block of audio samples (stereo interleaved). Any block
+// length works and gives the same output: the engine renders fixed
+// internalBlockSize blocks and keeps the part of the last one not yet
+// returned. Direct calls (NoteOn, pedals, parameter setters) take effect at
+// the next internal block boundary, i.e. are quantized to internalBlockSize
+// frames; ScheduleEvent places note and pedal events on exact samples.
 func (p *Piano) Process(numFrames int) []float32 {
-	monoMix := p.ringing.Process(numFrames, p.hammerExciter)
+	out := make([]float32, numFrames*2)
+	p.ProcessInto(out)
+	return out
+}
 
-	if p.resonance != nil {
-		p.resonance.InjectFromBridge(monoMix, p.ringing.ResonanceTargets())
+// ProcessInto renders len(out)/2 stereo frames into out (interleaved L/R)
+// like Process, but into a caller-owned buffer. Once notes have been struck
+// it does not allocate, so realtime hosts can call it from the audio thread.
+func (p *Piano) ProcessInto(out []float32) {
+	p.ProcessStemsInto(out, nil, nil)
+}
+
+// ProcessStemsInto renders like ProcessInto and also returns the two mic
+// buses as separate stereo stems: closeOut gets the close mic (dry strings
+// and body IR), roomOut the room mic. Stems carry the bus gain, pan and
+// delay and the output gain but not the output EQ, so with the EQ bypassed
+// they sum to the mixdown. Any of the buffers may be nil; the frame count
+// is that of the first non-nil one.
+func (p *Piano) ProcessStemsInto(mix []float32, closeOut []float32, roomOut []float32) {
+	n := len(mix)
+	switch {
+	case mix != nil:
+	case closeOut != nil:
+		n = len(closeOut)
+	default:
+		n = len(roomOut)
 	}
+	n &^= 1
+	if p.monoPending {
+		p.pendingFrom, p.monoPending = p.blockLen, false
+	}
+	for done := 0; done < n; {
+		if p.pendingFrom >= p.blockLen {
+			p.processBlock(internalBlockSize)
+			p.pendingFrom = 0
+		}
+		from := p.pendingFrom
+		c := min(n-done, p.blockLen-from)
+		if mix != nil {
+			copy(mix[done:done+c], p.stereoBlock[from:from+c])
+		}
+		if closeOut != nil {
+			copy(closeOut[done:done+c], p.closeStem[from:from+c])
+		}
+		if roomOut != nil {
+			copy(roomOut[done:done+c], p.roomStem[from:from+c])
+		}
+		done += c
+		p.pendingFrom += c
+	}
+	p.framesOut += int64(n / 2)
+}
 
-	// Signal flow: string bank → body convolver (mono→mono) → room convolver (mono→stereo)
-	bodyMono := p.bodyConvolver.Process(monoMix)
-	stereoRoom := p.roomConvolver.Process(bodyMono)
+// ProcessMono renders numFrames mono frames. It skips the stereo room
+// convolution: the room stage convolves with the mid (L+R)/2 of the room
+// IR, and the mic buses and their pans are summed, so the result matches
+// the average of the stereo channels for a room IR with equal channels at
+// about half the convolution cost. The bass mono stage does not apply.
+// A room IR swap takes effect at the next block without a crossfade.
+// Render each Piano in one layout: switching between Process and
+// ProcessMono drops the part of the current internal block not yet
+// returned.
+func (p *Piano) ProcessMono(numFrames int) []float32 {
+	out := make([]float32, numFrames)
+	p.ProcessMonoInto(out)
+	return out
+}
 
-	stereoOutput := make([]float32, numFrames*2)
+// ProcessMonoInto renders len(out) mono frames into out like ProcessMono;
+// like ProcessInto it does not allocate once notes have been struck and
+// the room IR has been set.
+func (p *Piano) ProcessMonoInto(out []float32) {
+	if !p.monoPending {
+		p.pendingFrom, p.monoPending = p.blockLen, true
+	}
+	for done := 0; done < len(out); {
+		if p.pendingFrom >= p.blockLen {
+			p.processMonoBlock(internalBlockSize)
+			p.pendingFrom = 0
+		}
+		from := p.pendingFrom
+		c := min(len(out)-done, p.blockLen-from)
+		copy(out[done:done+c], p.monoOutBlock[from:from+c])
+		done += c
+		p.pendingFrom += c
+	}
+	p.framesOut += int64(len(out))
+}
+
+// processBlock renders numFrames frames of every stage into the engine's
+// block buffers; the result is valid until the next call.
+func (p *Piano) processBlock(numFrames int) []float32 {
+	if len(p.stereoBlock) < numFrames*2 {
+		p.bodyBlock = make([]float32, numFrames)
+		p.roomBlock = make([]float32, numFrames*2)
+		p.stereoBlock = make([]float32, numFrames*2)
+		p.closeStem = make([]float32, numFrames*2)
+		p.roomStem = make([]float32, numFrames*2)
+	}
+	// Signal flow: string bank → soundboard nonlinearity → body convolver
+	// (mono→mono) + hammer knock → room convolver (mono→stereo)
+	bodyMono := p.renderBody(numFrames)
+	stereoRoom := p.roomBlock[:numFrames*2]
+	p.roomConvolver.processInto(stereoRoom, bodyMono)
+
+	stereoOutput := p.stereoBlock[:numFrames*2]
+	p.updateMixLevels()
+
+	closeStem := p.closeStem[:numFrames*2]
+	roomStem := p.roomStem[:numFrames*2]
+	for i := 0; i < numFrames; i++ {
+		gain := p.outGain.next()
+		closeL, closeR := p.closeDelay.process(bodyMono[i]*p.closeLevel[0].next(), bodyMono[i]*p.closeLevel[1].next())
+		roomL, roomR := p.roomDelay.process(p.roomLevel[0].next()*stereoRoom[i*2], p.roomLevel[1].next()*stereoRoom[i*2+1])
+		stereoOutput[i*2] = (closeL + roomL) * gain
+		stereoOutput[i*2+1] = (closeR + roomR) * gain
+		closeStem[i*2], closeStem[i*2+1] = closeL*gain, closeR*gain
+		roomStem[i*2], roomStem[i*2+1] = roomL*gain, roomR*gain
+	}
+	p.outputEQ.ProcessInterleaved(stereoOutput)
+	p.bassMono.ProcessInterleaved(stereoOutput)
+	p.blockLen = numFrames * 2
+
+	return stereoOutput
+}
 
+// processMonoBlock is processBlock for ProcessMono: the room stage runs one
+// convolver on the mid of the room IR instead of the stereo pair, and the
+// mic buses are summed to mono.
+func (p *Piano) processMonoBlock(numFrames int) []float32 {
+	if len(p.monoOutBlock) < numFrames {
+		p.monoRoomBlock = make([]float32, numFrames)
+		p.monoOutBlock = make([]float32, numFrames)
+	}
+	if len(p.bodyBlock) < numFrames {
+		p.bodyBlock = make([]float32, numFrames)
+	}
+	bodyMono := p.renderBody(numFrames)
+	switch {
+	case p.monoRoom != nil && p.monoRoomIR == p.roomConvolver.irGen:
+	case p.monoRoom != nil && p.roomConvolver.irSwapMs >= 0:
+		// A SwapRoomIR: crossfade like the stereo pair, keeping the tail.
+		p.monoRoom.SwapIR(p.roomConvolver.midIR(), p.roomConvolver.irSwapMs)
+		p.monoRoomIR = p.roomConvolver.irGen
+	default:
+		p.monoRoom = p.newBodyConvolver()
+		p.monoRoom.SetIR(p.roomConvolver.midIR())
+		p.monoRoomIR = p.roomConvolver.irGen
+	}
+	room := p.monoRoomBlock[:numFrames]
+	p.monoRoom.processInto(room, bodyMono)
+
+	out := p.monoOutBlock[:numFrames]
+	p.updateMixLevels()
+	for i := 0; i < numFrames; i++ {
+		gain := p.outGain.next()
+		closeL, closeR := p.closeDelay.process(bodyMono[i]*p.closeLevel[0].next(), bodyMono[i]*p.closeLevel[1].next())
+		roomL, roomR := p.roomDelay.process(p.roomLevel[0].next()*room[i], p.roomLevel[1].next()*room[i])
+		out[i] = 0.5 * (closeL + closeR + roomL + roomR) * gain
+	}
+	p.outputEQ.processMono(out)
+	p.blockLen = numFrames
+
+	return out
+}
+
+// renderBody renders the strings through the soundboard nonlinearity and
+// the body IR into bodyBlock and adds the hammer knock.
+func (p *Piano) renderBody(numFrames int) []float32 {
+	monoMix := p.renderStrings(numFrames)
+	p.boardNL.process(monoMix)
+	bodyMono := p.bodyBlock[:numFrames]
+	p.bodyConvolver.processInto(bodyMono, monoMix)
+	bodyMono = p.bodyMorph.process(monoMix, bodyMono)
+	p.knock.mixInto(bodyMono)
+	return bodyMono
+}
+
+// updateMixLevels sets the output gain and mic bus level targets from the
+// params; the first block jumps to them.
+func (p *Piano) updateMixLevels() {
 	// Read mix params with backwards-compatible defaults.
 	outGain := float32(1.0)
 	bodyDry := float32(1.0)
@@ -204,13 +788,26 @@ func (p *Piano) Process(numFrames int) []float32 {
 		}
 	}
 
-	for i := 0; i < numFrames; i++ {
-		body := bodyMono[i] * bodyGain
-		l := bodyDry*body + roomWet*stereoRoom[i*2]*roomGain
-		r := bodyDry*body + roomWet*stereoRoom[i*2+1]*roomGain
-		stereoOutput[i*2] = l * outGain
-		stereoOutput[i*2+1] = r * outGain
+	var closeMic, roomMic MicBus
+	if p.params != nil {
+		closeMic, roomMic = p.params.CloseMic, p.params.RoomMic
 	}
+	cl, cr := closeMic.levels()
+	rl, rr := roomMic.levels()
+	closeTarget := [2]float32{bodyDry * bodyGain * cl, bodyDry * bodyGain * cr}
+	roomTarget := [2]float32{roomWet * roomGain * rl, roomWet * roomGain * rr}
 
-	return stereoOutput
+	p.outGain.set(outGain)
+	for ch := range 2 {
+		p.closeLevel[ch].set(closeTarget[ch])
+		p.roomLevel[ch].set(roomTarget[ch])
+	}
+	if !p.mixPrimed {
+		p.outGain.jump(outGain)
+		for ch := range 2 {
+			p.closeLevel[ch].jump(closeTarget[ch])
+			p.roomLevel[ch].jump(roomTarget[ch])
+		}
+		p.mixPrimed = true
+	}
 }
@@ -0,0 +1,102 @@
+package piano
+
+import (
+	"github.com/cwbudde/algo-dsp/dsp/filter/biquad"
+	"github.com/cwbudde/algo-dsp/dsp/filter/design"
+)
+
+// MaxOutputEQBands is the maximum number of bands in Params.OutputEQ.
+const MaxOutputEQBands = 5
+
+type EQBandType string
+
+const (
+	EQBandLowShelf  EQBandType = "low_shelf"
+	EQBandPeak      EQBandType = "peak"
+	EQBandHighShelf EQBandType = "high_shelf"
+)
+
+// EQBand is one section of the parametric output EQ.
+type EQBand struct {
+	Type   EQBandType
+	FreqHz float32
+	GainDB float32
+	Q      float32
+}
+
+// DefaultOutputEQBands returns a neutral 4-band layout (bass, low-mid,
+// presence, treble) that roughly follows the piano registers.
+func DefaultOutputEQBands() []EQBand {
+	return []EQBand{
+		{Type: EQBandLowShelf, FreqHz: 150, GainDB: 0, Q: 0.707},
+		{Type: EQBandPeak, FreqHz: 600, GainDB: 0, Q: 0.9},
+		{Type: EQBandPeak, FreqHz: 2500, GainDB: 0, Q: 0.9},
+		{Type: EQBandHighShelf, FreqHz: 7000, GainDB: 0, Q: 0.707},
+	}
+}
+
+// outputEQ is a stereo biquad cascade applied after the convolvers.
+type outputEQ struct {
+	left  *biquad.Chain
+	right *biquad.Chain
+}
+
+// newOutputEQ builds the EQ stage. Bands with 0 dB gain or an out-of-range
+// frequency are skipped; nil is returned when no band is left.
+func newOutputEQ(sampleRate int, bands []EQBand) *outputEQ {
+	if sampleRate <= 0 || len(bands) == 0 {
+		return nil
+	}
+	sr := float64(sampleRate)
+	coeffs := make([]biquad.Coefficients, 0, len(bands))
+	for i, b := range bands {
+		if i >= MaxOutputEQBands {
+			break
+		}
+		if b.GainDB == 0 || b.FreqHz <= 0 || float64(b.FreqHz) >= 0.5*sr {
+			continue
+		}
+		q := float64(b.Q)
+		if q <= 0 {
+			q = 0.707
+		}
+		freq := float64(b.FreqHz)
+		gain := float64(b.GainDB)
+		switch b.Type {
+		case EQBandLowShelf:
+			coeffs = append(coeffs, design.LowShelf(freq, gain, q, sr))
+		case EQBandHighShelf:
+			coeffs = append(coeffs, design.HighShelf(freq, gain, q, sr))
+		default:
+			coeffs = append(coeffs, design.Peak(freq, gain, q, sr))
+		}
+	}
+	if len(coeffs) == 0 {
+		return nil
+	}
+	return &outputEQ{
+		left:  biquad.NewChain(coeffs),
+		right: biquad.NewChain(coeffs),
+	}
+}
+
+// processMono filters a mono buffer in place with the left channel.
+func (e *outputEQ) processMono(buf []float32) {
+	if e == nil {
+		return
+	}
+	for i, v := range buf {
+		buf[i] = float32(e.left.ProcessSample(float64(v)))
+	}
+}
+
+// ProcessInterleaved filters a stereo interleaved buffer in place.
+func (e *outputEQ) ProcessInterleaved(buf []float32) {
+	if e == nil {
+		return
+	}
+	for i := 0; i+1 < len(buf); i += 2 {
+		buf[i] = float32(e.left.ProcessSample(float64(buf[i])))
+		buf[i+1] = float32(e.right.ProcessSample(float64(buf[i+1])))
+	}
+}
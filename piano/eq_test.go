@@ -0,0 +1,63 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOutputEQNeutralBandsBypass(t *testing.T) {
+	if eq := newOutputEQ(48000, DefaultOutputEQBands()); eq != nil {
+		t.Fatalf("expected nil EQ for all-0 dB bands")
+	}
+	var eq *outputEQ
+	buf := []float32{0.5, -0.5}
+	eq.ProcessInterleaved(buf)
+	if buf[0] != 0.5 || buf[1] != -0.5 {
+		t.Fatalf("expected nil EQ to pass through, got %v", buf)
+	}
+}
+
+func TestOutputEQLowShelfBoostsBassOnly(t *testing.T) {
+	const sampleRate = 48000
+	bands := DefaultOutputEQBands()
+	bands[0].GainDB = 6
+
+	gainAt := func(freq float64) float64 {
+		eq := newOutputEQ(sampleRate, bands)
+		buf := make([]float32, 2*sampleRate/4)
+		for i := 0; i < len(buf)/2; i++ {
+			v := float32(math.Sin(2 * math.Pi * freq * float64(i) / sampleRate))
+			buf[i*2] = v
+			buf[i*2+1] = v
+		}
+		eq.ProcessInterleaved(buf)
+		// Skip the transient half.
+		return stereoRMS(buf[len(buf)/2:]) * math.Sqrt2
+	}
+
+	low := gainAt(50)
+	high := gainAt(5000)
+	if low < 1.8 {
+		t.Fatalf("expected ~+6 dB at 50 Hz, got gain %f", low)
+	}
+	if math.Abs(high-1) > 0.05 {
+		t.Fatalf("expected unity gain at 5 kHz, got %f", high)
+	}
+}
+
+func TestPianoOutputEQChangesRender(t *testing.T) {
+	render := func(params *Params) []float32 {
+		p := NewPiano(48000, 16, params)
+		p.NoteOn(60, 100)
+		return p.Process(4096)
+	}
+	flat := render(NewDefaultParams())
+	cut := NewDefaultParams()
+	cut.OutputEQ = DefaultOutputEQBands()
+	for i := range cut.OutputEQ {
+		cut.OutputEQ[i].GainDB = -12
+	}
+	if got, ref := stereoRMS(render(cut)), stereoRMS(flat); got >= ref*0.5 {
+		t.Fatalf("expected broadband cut to lower output: cut=%f flat=%f", got, ref)
+	}
+}
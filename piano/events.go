@@ -0,0 +1,119 @@
+package piano
+
+import "sort"
+
+// EventKind selects what a scheduled Event does.
+type EventKind int
+
+const (
+	// EventNoteOn strikes Note at Velocity with Options (NoteOnEx).
+	EventNoteOn EventKind = iota
+	// EventNoteOff releases Note at release Velocity (NoteOffEx; 0 =
+	// DefaultReleaseVelocity).
+	EventNoteOff
+	// EventKeyDown lifts the damper of Note without a strike (KeyDown).
+	EventKeyDown
+	// EventSustainPedal sets the sustain pedal to Down.
+	EventSustainPedal
+	// EventSoftPedal sets the soft pedal to Down.
+	EventSoftPedal
+	// EventKeyPress starts the key travel of Note ahead of its note-on
+	// (KeyPress).
+	EventKeyPress
+)
+
+// eventPreStrike launches the provisional strike of a KeyPress; the engine
+// schedules it internally and it is not captured.
+const eventPreStrike EventKind = -1
+
+// Event is a note or pedal change for ScheduleEvent. Fields that do not
+// apply to Kind are ignored.
+type Event struct {
+	Kind     EventKind
+	Note     int
+	Velocity int
+	Options  NoteOptions
+	Down     bool
+}
+
+type scheduledEvent struct {
+	frame int64 // absolute output frame
+	event Event
+}
+
+// ScheduleEvent queues event to happen frameOffset frames into the output
+// of the next Process call (negative offsets mean its first frame), so
+// notes and pedals land on exact samples rather than on block boundaries.
+// Offsets may reach past that call; the event then waits for a later one.
+// Events at the same frame apply in the order they were scheduled.
+//
+// The sample position is exact when every Process call so far returned a
+// multiple of internalBlockSize frames (hosts with 128, 256, ... frame
+// buffers). Otherwise frames up to the next internal block boundary may
+// already be rendered, and an event falling there takes effect at that
+// boundary instead.
+func (p *Piano) ScheduleEvent(frameOffset int, event Event) {
+	p.scheduleAt(p.framesOut+int64(max(frameOffset, 0)), event)
+}
+
+// scheduleAt queues event for the absolute output frame.
+func (p *Piano) scheduleAt(frame int64, event Event) {
+	i := sort.Search(len(p.scheduled), func(i int) bool { return p.scheduled[i].frame > frame })
+	p.scheduled = append(p.scheduled, scheduledEvent{})
+	copy(p.scheduled[i+1:], p.scheduled[i:])
+	p.scheduled[i] = scheduledEvent{frame: frame, event: event}
+}
+
+func (p *Piano) applyEvent(e Event) {
+	switch e.Kind {
+	case EventNoteOn:
+		p.NoteOnEx(e.Note, e.Velocity, e.Options)
+	case EventNoteOff:
+		p.NoteOffEx(e.Note, e.Velocity)
+	case EventKeyDown:
+		p.KeyDown(e.Note)
+	case EventSustainPedal:
+		p.SetSustainPedal(e.Down)
+	case EventSoftPedal:
+		p.SetSoftPedal(e.Down)
+	case EventKeyPress:
+		p.KeyPress(e.Note)
+	case eventPreStrike:
+		p.launchPreStrike(e.Note)
+	}
+}
+
+// renderStrings runs the hammers, strings and resonance for numFrames
+// frames from framesRendered, splitting the block at scheduled events so
+// each applies on its frame. The convolvers after it always see the whole
+// block.
+func (p *Piano) renderStrings(numFrames int) []float32 {
+	if len(p.monoBlock) < numFrames {
+		p.monoBlock = make([]float32, numFrames)
+	}
+	out := p.monoBlock[:numFrames]
+	start := p.framesRendered
+	for pos := 0; pos < numFrames; {
+		for len(p.scheduled) > 0 && p.scheduled[0].frame <= start+int64(pos) {
+			e := p.scheduled[0].event
+			p.scheduled = p.scheduled[1:]
+			p.eventPos = pos
+			p.applyEvent(e)
+		}
+		n := numFrames - pos
+		if len(p.scheduled) > 0 {
+			n = min(n, int(p.scheduled[0].frame-start)-pos)
+		}
+		p.hammerExciter.advanceControls(n)
+		p.tuningDrift.advance(n, p.ringing)
+		seg := p.ringing.Process(n, p.hammerExciter)
+		if p.resonance != nil {
+			p.resonance.InjectFromBridge(seg, p.ringing.ResonanceTargets())
+		}
+		copy(out[pos:], seg)
+		pos += n
+	}
+	p.eventPos = 0
+	p.framesRendered += int64(numFrames)
+	return out
+}
@@ -0,0 +1,87 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScheduledNoteOnLandsOnItsSample(t *testing.T) {
+	render := func(offset int) []float32 {
+		p := NewPiano(48000, 16, NewDefaultParams())
+		p.ScheduleEvent(offset, Event{Kind: EventNoteOn, Note: 60, Velocity: 100})
+		out := make([]float32, 0, 40*internalBlockSize*2)
+		for range 40 {
+			out = append(out, p.Process(internalBlockSize)...)
+		}
+		return out
+	}
+	const offset = 77
+	early := render(0)
+	late := render(offset)
+	// The FFT convolver spreads float rounding noise (~1e-9) through a
+	// partition, so "silent" is below -120 dBFS.
+	for i := 0; i < 2*offset; i++ {
+		if math.Abs(float64(late[i])) > 1e-6 {
+			t.Fatalf("sample %d sounds before the scheduled frame %d", i/2, offset)
+		}
+	}
+	// The strike is the same one shifted by offset frames.
+	var diff, ref float64
+	for i := 0; i < len(late)-2*offset; i++ {
+		d := float64(late[i+2*offset] - early[i])
+		diff += d * d
+		ref += float64(early[i]) * float64(early[i])
+	}
+	if ref == 0 || math.Sqrt(diff/ref) > 1e-3 {
+		t.Fatalf("scheduled strike is not the early one shifted: relative error %g", math.Sqrt(diff/ref))
+	}
+}
+
+func TestScheduledEventsAreIndependentOfHostBlockSize(t *testing.T) {
+	events := []struct {
+		frame int
+		event Event
+	}{
+		{5, Event{Kind: EventNoteOn, Note: 60, Velocity: 90}},
+		{301, Event{Kind: EventSustainPedal, Down: true}},
+		{301, Event{Kind: EventNoteOn, Note: 64, Velocity: 70}},
+		{900, Event{Kind: EventNoteOff, Note: 60}},
+		{1500, Event{Kind: EventSustainPedal, Down: false}},
+	}
+	render := func(blockSize int) []float32 {
+		params := NewDefaultParams()
+		params.ResonanceEnabled = true
+		p := NewPiano(48000, 16, params)
+		out := make([]float32, 0, 4096*2)
+		for frame := 0; frame < 4096; frame += blockSize {
+			for _, e := range events {
+				if e.frame >= frame && e.frame < frame+blockSize {
+					p.ScheduleEvent(e.frame-frame, e.event)
+				}
+			}
+			out = append(out, p.Process(blockSize)...)
+		}
+		return out
+	}
+	want := render(internalBlockSize)
+	for _, blockSize := range []int{2 * internalBlockSize, 8 * internalBlockSize} {
+		got := render(blockSize)
+		for i := range want {
+			if math.Float32bits(got[i]) != math.Float32bits(want[i]) {
+				t.Fatalf("block %d: sample %d differs: %v vs %v", blockSize, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestScheduleEventAheadOfTheCurrentBlock(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	p.ScheduleEvent(3*internalBlockSize+10, Event{Kind: EventNoteOn, Note: 72, Velocity: 100})
+	if out := p.Process(3 * internalBlockSize); stereoRMS(out) != 0 {
+		t.Fatal("an event past the block must wait for a later call")
+	}
+	out := p.Process(internalBlockSize)
+	if stereoRMS(out[:20]) > 1e-6 || stereoRMS(out[20:]) < 1e-4 {
+		t.Fatal("the event must sound from its frame in the later call")
+	}
+}
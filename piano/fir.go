@@ -0,0 +1,105 @@
+package piano
+
+import (
+	"fmt"
+
+	dspconv "github.com/cwbudde/algo-dsp/dsp/conv"
+)
+
+// directFIRMaxTaps is the longest IR ConvolutionAuto convolves directly.
+// Up to it a time-domain FIR beats the FFT of a 128-sample block plus the
+// IR (see BenchmarkConvolverBackends); a 5 ms body IR at 48 kHz is 240 taps.
+const directFIRMaxTaps = 256
+
+// blockConvolver convolves fixed-size blocks with an IR, keeping the state
+// between blocks. dspconv's streaming convolvers implement it.
+type blockConvolver interface {
+	ProcessBlockTo(output []float32, input []float32) error
+	Reset()
+}
+
+// newBlockConvolver builds the convolver the backend selects for ir.
+func newBlockConvolver(ir []float32, blockSize int, backend ConvolutionBackend) (blockConvolver, error) {
+	if resolveConvolutionBackend(backend, len(ir)) == ConvolutionDirect {
+		return newDirectFIR(ir, blockSize)
+	}
+	return dspconv.NewStreamingOverlapAdd32(ir, blockSize)
+}
+
+// resolveConvolutionBackend returns the backend used for an IR of irLen
+// taps: auto (or "") becomes direct or overlap-add by length, and unknown
+// values fall back to overlap-add.
+func resolveConvolutionBackend(backend ConvolutionBackend, irLen int) ConvolutionBackend {
+	switch backend {
+	case ConvolutionDirect, ConvolutionOverlapAdd:
+		return backend
+	case "", ConvolutionAuto:
+		if irLen <= directFIRMaxTaps {
+			return ConvolutionDirect
+		}
+	}
+	return ConvolutionOverlapAdd
+}
+
+// directFIR is a time-domain FIR convolver for short IRs, without the FFT
+// overhead of a block-plus-IR transform.
+type directFIR struct {
+	// rev is the IR reversed, so each output sample is a dot product with
+	// a contiguous window of buf.
+	rev []float32
+	// buf holds the last len(rev)-1 input samples followed by the current
+	// block.
+	buf       []float32
+	blockSize int
+}
+
+func newDirectFIR(ir []float32, blockSize int) (*directFIR, error) {
+	if len(ir) == 0 {
+		return nil, dspconv.ErrEmptyKernel
+	}
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("fir: blockSize must be positive, got %d", blockSize)
+	}
+	rev := make([]float32, len(ir))
+	for i, v := range ir {
+		rev[len(ir)-1-i] = v
+	}
+	return &directFIR{
+		rev:       rev,
+		buf:       make([]float32, len(ir)-1+blockSize),
+		blockSize: blockSize,
+	}, nil
+}
+
+// ProcessBlockTo convolves one block; input and output are blockSize long.
+func (f *directFIR) ProcessBlockTo(output []float32, input []float32) error {
+	if len(input) != f.blockSize || len(output) < f.blockSize {
+		return fmt.Errorf("fir: block of %d samples, want %d", len(input), f.blockSize)
+	}
+	hist := len(f.rev) - 1
+	copy(f.buf[hist:], input)
+	n := len(f.rev)
+	for i := range f.blockSize {
+		window := f.buf[i : i+n : i+n]
+		// Four partial sums break the add dependency chain.
+		var a0, a1, a2, a3 float32
+		k := 0
+		for ; k+4 <= n; k += 4 {
+			h, w := f.rev[k:k+4:k+4], window[k:k+4:k+4]
+			a0 += h[0] * w[0]
+			a1 += h[1] * w[1]
+			a2 += h[2] * w[2]
+			a3 += h[3] * w[3]
+		}
+		for ; k < n; k++ {
+			a0 += f.rev[k] * window[k]
+		}
+		output[i] = (a0 + a1) + (a2 + a3)
+	}
+	copy(f.buf, f.buf[f.blockSize:])
+	return nil
+}
+
+func (f *directFIR) Reset() {
+	clear(f.buf)
+}
@@ -0,0 +1,102 @@
+package piano
+
+import (
+	"math"
+	"testing"
+
+	dspconv "github.com/cwbudde/algo-dsp/dsp/conv"
+)
+
+func TestDirectFIRMatchesOverlapAdd(t *testing.T) {
+	ir := make([]float32, 200)
+	for i := range ir {
+		ir[i] = float32(math.Exp(-float64(i)/40) * math.Cos(float64(i)*0.3))
+	}
+	fir, err := newDirectFIR(ir, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ola, err := dspconv.NewStreamingOverlapAdd32(ir, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make([]float32, 64)
+	got := make([]float32, 64)
+	want := make([]float32, 64)
+	// Several blocks, so the IR spans block boundaries.
+	for block := range 8 {
+		for i := range in {
+			in[i] = float32(math.Sin(float64(block*64+i) * 0.11))
+		}
+		if err := fir.ProcessBlockTo(got, in); err != nil {
+			t.Fatal(err)
+		}
+		if err := ola.ProcessBlockTo(want, in); err != nil {
+			t.Fatal(err)
+		}
+		if d := maxAbsDiff(got, want); d > 1e-4 {
+			t.Fatalf("block %d: direct FIR differs from overlap-add by %g", block, d)
+		}
+	}
+	if err := fir.ProcessBlockTo(got, in[:10]); err == nil {
+		t.Fatal("expected an error for a short block")
+	}
+}
+
+func TestAutoBackendSelectsByIRLength(t *testing.T) {
+	for _, tc := range []struct {
+		backend ConvolutionBackend
+		taps    int
+		want    ConvolutionBackend
+	}{
+		{ConvolutionAuto, 240, ConvolutionDirect},
+		{"", directFIRMaxTaps, ConvolutionDirect},
+		{ConvolutionAuto, directFIRMaxTaps + 1, ConvolutionOverlapAdd},
+		{ConvolutionDirect, 48000, ConvolutionDirect},
+		{ConvolutionOverlapAdd, 1, ConvolutionOverlapAdd},
+		{"unknown", 1, ConvolutionOverlapAdd},
+	} {
+		if got := resolveConvolutionBackend(tc.backend, tc.taps); got != tc.want {
+			t.Errorf("%q with %d taps: got %q, want %q", tc.backend, tc.taps, got, tc.want)
+		}
+	}
+}
+
+func TestConvolutionBackendsRenderAlike(t *testing.T) {
+	body := make([]float32, 240) // 5 ms at 48 kHz
+	for i := range body {
+		body[i] = float32(math.Exp(-float64(i)/30)) * 0.2
+	}
+	room := make([]float32, 2400)
+	for i := range room {
+		room[i] = float32(math.Exp(-float64(i)/600)*math.Sin(float64(i)*0.9)) * 0.05
+	}
+	render := func(backend ConvolutionBackend, switchAfter bool) []float32 {
+		params := NewDefaultParams()
+		params.RoomWetMix = 0.3
+		if !switchAfter {
+			params.ConvolutionBackend = backend
+		}
+		p := NewPiano(48000, 16, params)
+		p.SetBodyIR(body)
+		p.SetRoomIR(room, room)
+		if switchAfter {
+			p.SetConvolutionBackend(backend)
+		}
+		p.NoteOn(60, 100)
+		var out []float32
+		for range 40 {
+			out = append(out, p.Process(128)...)
+		}
+		return out
+	}
+	want := render(ConvolutionOverlapAdd, false)
+	for _, backend := range []ConvolutionBackend{ConvolutionDirect, ConvolutionAuto} {
+		if d := maxAbsDiff(render(backend, false), want); d > 1e-4 {
+			t.Fatalf("%s render differs from overlap-add by %g", backend, d)
+		}
+		if d := maxAbsDiff(render(backend, true), want); d > 1e-4 {
+			t.Fatalf("switching to %s differs from overlap-add by %g", backend, d)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package piano
+
+import "math"
+
+// Utility generators for checking tuning and timing against external
+// instruments. They mix into a host's stereo output after Process; a nil
+// generator mixes nothing.
+
+const (
+	clickFreqHz       = 1000.0
+	clickAccentFreqHz = 1500.0
+	clickDecayMs      = 6.0
+	clickLengthMs     = 40.0
+)
+
+// Metronome is a click track: a short decaying sine burst on every beat,
+// pitched higher on the first beat of each bar.
+type Metronome struct {
+	sampleRate  float64
+	level       float32
+	beatsPerBar int
+
+	period float64 // frames per beat
+	pos    float64 // frames since the current beat
+	beat   int
+
+	clickLen   int
+	clickDecay float64
+}
+
+// NewMetronome creates a click track at bpm with the given peak level
+// (linear, 0..1) and beatsPerBar beats per bar (< 1 = no accent).
+func NewMetronome(sampleRate int, bpm float64, beatsPerBar int, level float32) *Metronome {
+	m := &Metronome{
+		sampleRate:  float64(sampleRate),
+		level:       level,
+		beatsPerBar: beatsPerBar,
+		clickLen:    int(clickLengthMs * 0.001 * float64(sampleRate)),
+		clickDecay:  math.Exp(-1.0 / (clickDecayMs * 0.001 * float64(sampleRate))),
+	}
+	m.SetBPM(bpm)
+	return m
+}
+
+// SetBPM changes the tempo from the next beat on; bpm <= 0 stops the
+// clicks.
+func (m *Metronome) SetBPM(bpm float64) {
+	if bpm <= 0 {
+		m.period = 0
+		return
+	}
+	m.period = 60 * m.sampleRate / bpm
+}
+
+// SetLevel sets the peak click level (linear).
+func (m *Metronome) SetLevel(level float32) {
+	m.level = level
+}
+
+// MixInto adds the clicks to an interleaved stereo buffer.
+func (m *Metronome) MixInto(stereo []float32) {
+	if m == nil || m.period <= 0 || m.level == 0 {
+		return
+	}
+	for i := 0; i+1 < len(stereo); i += 2 {
+		if m.pos >= m.period {
+			m.pos -= m.period
+			m.beat++
+			if m.beatsPerBar > 0 && m.beat >= m.beatsPerBar {
+				m.beat = 0
+			}
+		}
+		if n := int(m.pos); n < m.clickLen {
+			freq := clickFreqHz
+			if m.beatsPerBar > 1 && m.beat == 0 {
+				freq = clickAccentFreqHz
+			}
+			env := math.Pow(m.clickDecay, float64(n))
+			v := m.level * float32(env*math.Sin(2*math.Pi*freq*float64(n)/m.sampleRate))
+			stereo[i] += v
+			stereo[i+1] += v
+		}
+		m.pos++
+	}
+}
+
+// TestTone is a steady reference sine at a MIDI note, tuned to a
+// configurable A4 (e.g. 440 or 442 Hz).
+type TestTone struct {
+	sampleRate float64
+	level      float32
+	phase      float64
+	step       float64 // phase increment per frame, in cycles
+}
+
+// NewTestTone creates a sine at note (69 = A4) with A4 at a4Hz and the
+// given peak level (linear, 0..1).
+func NewTestTone(sampleRate int, a4Hz float64, note int, level float32) *TestTone {
+	t := &TestTone{sampleRate: float64(sampleRate), level: level}
+	t.SetPitch(a4Hz, note)
+	return t
+}
+
+// SetPitch retunes the tone without a phase jump.
+func (t *TestTone) SetPitch(a4Hz float64, note int) {
+	t.step = a4Hz * math.Pow(2, float64(note-69)/12) / t.sampleRate
+}
+
+// Freq returns the tone frequency in Hz.
+func (t *TestTone) Freq() float64 {
+	return t.step * t.sampleRate
+}
+
+// SetLevel sets the peak level (linear); 0 silences the tone.
+func (t *TestTone) SetLevel(level float32) {
+	t.level = level
+}
+
+// MixInto adds the tone to an interleaved stereo buffer.
+func (t *TestTone) MixInto(stereo []float32) {
+	if t == nil || t.level == 0 {
+		return
+	}
+	for i := 0; i+1 < len(stereo); i += 2 {
+		v := t.level * float32(math.Sin(2*math.Pi*t.phase))
+		stereo[i] += v
+		stereo[i+1] += v
+		t.phase += t.step
+		if t.phase >= 1 {
+			t.phase -= 1
+		}
+	}
+}
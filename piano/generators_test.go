@@ -0,0 +1,75 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMetronomeClicksOnEveryBeat(t *testing.T) {
+	const sr = 48000
+	m := NewMetronome(sr, 120, 4, 0.5)
+	buf := make([]float32, 2*2*sr) // two seconds = four beats
+	m.MixInto(buf[:2*1000])        // odd chunks must not shift the grid
+	m.MixInto(buf[2*1000:])
+
+	var onsets []int
+	for i := 0; i < len(buf)/2; i++ {
+		if buf[i*2] != 0 && (i == 0 || buf[(i-1)*2] == 0) && (len(onsets) == 0 || i-onsets[len(onsets)-1] > sr/10) {
+			onsets = append(onsets, i)
+		}
+		if buf[i*2] != buf[i*2+1] {
+			t.Fatalf("frame %d: click not centred", i)
+		}
+	}
+	if len(onsets) != 4 {
+		t.Fatalf("found %d clicks in 2 s at 120 bpm, want 4 (onsets %v)", len(onsets), onsets)
+	}
+	for k, at := range onsets {
+		if want := k * sr / 2; at < want || at > want+2 {
+			t.Fatalf("click %d at frame %d, want %d", k, at, want)
+		}
+	}
+
+	m.SetBPM(0)
+	silent := make([]float32, 2*sr)
+	m.MixInto(silent)
+	if stereoRMS(silent) != 0 {
+		t.Fatal("expected no clicks at 0 bpm")
+	}
+}
+
+func TestTestToneFollowsA4Reference(t *testing.T) {
+	const sr = 48000
+	tone := NewTestTone(sr, 442, 69, 0.25)
+	if math.Abs(tone.Freq()-442) > 1e-9 {
+		t.Fatalf("Freq() = %v, want 442", tone.Freq())
+	}
+	buf := make([]float32, 2*sr)
+	tone.MixInto(buf)
+	left := make([]float32, sr)
+	for i := range left {
+		left[i] = buf[i*2]
+	}
+	if f := measureFundamentalFreq(left, sr); math.Abs(float64(f)-442) > 1 {
+		t.Fatalf("measured %v Hz, want 442", f)
+	}
+	if peak := math.Sqrt2 * windowRMS(left); math.Abs(peak-0.25) > 0.01 {
+		t.Fatalf("peak level %v, want 0.25", peak)
+	}
+
+	tone.SetPitch(440, 57)
+	if math.Abs(tone.Freq()-220) > 1e-9 {
+		t.Fatalf("A3 at A4=440: Freq() = %v, want 220", tone.Freq())
+	}
+}
+
+func TestNilGeneratorsMixNothing(t *testing.T) {
+	var m *Metronome
+	var tone *TestTone
+	buf := []float32{0.5, -0.5}
+	m.MixInto(buf)
+	tone.MixInto(buf)
+	if buf[0] != 0.5 || buf[1] != -0.5 {
+		t.Fatalf("expected nil generators to leave the buffer unchanged, got %v", buf)
+	}
+}
@@ -0,0 +1,250 @@
+package piano
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/analysis"
+	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/wav"
+	"github.com/go-audio/audio"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite testdata/golden renders from the current engine")
+
+const (
+	goldenDir        = "testdata/golden"
+	goldenSampleRate = 24000
+	goldenBlockSize  = 128
+	// goldenHeadroom scales renders into the clamped [-1,1] WAV range.
+	goldenHeadroom = 1.0 / 32
+
+	// Tolerances of a render against its golden file. Compare normalizes
+	// RMS, so the level is checked separately.
+	goldenMaxScore           = 0.02
+	goldenMaxEnvelopeDB      = 1.0
+	goldenMaxSpectralDB      = 2.0
+	goldenMaxDecayDiffDBPerS = 1.5
+	goldenMaxLevelDiffDB     = 0.5
+	// goldenLevelFloorDBFS leaves silent frames and near-empty bins out of
+	// the envelope and spectral checks, where float rounding alone gives
+	// dB-sized ratios.
+	goldenLevelFloorDBFS = -90
+)
+
+// goldenCase is one fixed-seed scenario of the golden-audio regression set.
+type goldenCase struct {
+	name     string
+	duration float64
+	params   func(p *Params)
+	play     func(p *Piano, frame int)
+}
+
+func goldenCases() []goldenCase {
+	noteAt := func(note int, velocity int, onFrame int, offFrame int) func(p *Piano, frame int) {
+		return func(p *Piano, frame int) {
+			switch frame {
+			case onFrame:
+				p.NoteOn(note, velocity)
+			case offFrame:
+				p.NoteOff(note)
+			}
+		}
+	}
+	release := goldenBlockSize * 60
+	return []goldenCase{
+		{
+			name:     "c4_v100",
+			duration: 0.5,
+			params:   func(p *Params) { p.AttackNoiseLevel = 0.2 },
+			play:     noteAt(60, 100, 0, release),
+		},
+		{
+			name:     "a1_v70",
+			duration: 0.5,
+			play:     noteAt(33, 70, 0, -1),
+		},
+		{
+			name:     "c6_v120",
+			duration: 0.5,
+			play:     noteAt(84, 120, 0, release),
+		},
+		{
+			name:     "modal_e4_v90",
+			duration: 0.5,
+			params:   func(p *Params) { p.StringModel = StringModelModal },
+			play:     noteAt(64, 90, 0, -1),
+		},
+		{
+			name:     "chord_pedal_resonance",
+			duration: 0.5,
+			params:   func(p *Params) { p.ResonanceEnabled = true },
+			play: func(p *Piano, frame int) {
+				switch frame {
+				case 0:
+					p.SetSustainPedal(true)
+					p.NoteOn(48, 90)
+					p.NoteOn(64, 80)
+				case goldenBlockSize * 4:
+					p.NoteOn(67, 85)
+				case release:
+					p.NoteOff(48)
+					p.NoteOff(64)
+					p.NoteOff(67)
+				}
+			},
+		},
+	}
+}
+
+// renderGolden renders c at goldenSampleRate and returns the mono downmix.
+// Events fire on block boundaries, so the render is deterministic per seed.
+func renderGolden(c goldenCase, tweak func(p *Params)) []float64 {
+	params := NewDefaultParams()
+	params.Seed = 1
+	if c.params != nil {
+		c.params(params)
+	}
+	if tweak != nil {
+		tweak(params)
+	}
+	p := NewPiano(goldenSampleRate, 16, params)
+	frames := int(c.duration * goldenSampleRate)
+	out := make([]float32, 0, frames*2)
+	for rendered := 0; rendered < frames; rendered += goldenBlockSize {
+		c.play(p, rendered)
+		out = append(out, p.Process(goldenBlockSize)...)
+	}
+	return fitcommon.StereoToMono64(out[:frames*2])
+}
+
+// goldenMismatches returns the metrics of candidate that exceed the golden
+// tolerances against reference.
+func goldenMismatches(reference []float64, candidate []float64) []string {
+	var out []string
+	if len(reference) != len(candidate) {
+		return append(out, fmt.Sprintf("length %d frames, golden %d", len(candidate), len(reference)))
+	}
+	m := analysis.CompareWithOptions(reference, candidate, goldenSampleRate, analysis.CompareOptions{
+		LevelFloorDBFS: goldenLevelFloorDBFS,
+	})
+	check := func(name string, got float64, limit float64) {
+		if !(math.Abs(got) <= limit) {
+			out = append(out, fmt.Sprintf("%s = %.4g exceeds %.4g", name, got, limit))
+		}
+	}
+	check("score", m.Score, goldenMaxScore)
+	check("envelope_rmse_db", m.EnvelopeRMSEDB, goldenMaxEnvelopeDB)
+	check("spectral_rmse_db", m.SpectralRMSEDB, goldenMaxSpectralDB)
+	check("decay_diff_db_per_s", m.DecayDiffDBPerS, goldenMaxDecayDiffDBPerS)
+	check("level_diff_db", 20*math.Log10(rms64(candidate)/rms64(reference)), goldenMaxLevelDiffDB)
+	return out
+}
+
+func rms64(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return math.Sqrt(sum/float64(len(x))) + 1e-12
+}
+
+func loadGolden(t *testing.T, name string) []float64 {
+	t.Helper()
+	golden, sr, err := fitcommon.ReadWAVMono(filepath.Join(goldenDir, name+".wav"))
+	if err != nil {
+		t.Fatalf("read golden %s: %v (regenerate with go test ./piano -run TestGoldenRenders -update-golden)", name, err)
+	}
+	if sr != goldenSampleRate {
+		t.Fatalf("golden %s sample rate = %d, want %d", name, sr, goldenSampleRate)
+	}
+	for i := range golden {
+		golden[i] /= goldenHeadroom
+	}
+	return golden
+}
+
+// writeGolden stores a render as 32-bit float WAV scaled by goldenHeadroom,
+// since renders are not normalized and may exceed full scale.
+func writeGolden(t *testing.T, name string, x []float64) {
+	t.Helper()
+	if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+		t.Fatalf("create golden dir: %v", err)
+	}
+	f, err := os.Create(filepath.Join(goldenDir, name+".wav"))
+	if err != nil {
+		t.Fatalf("create golden: %v", err)
+	}
+	defer f.Close()
+	data := make([]float32, len(x))
+	for i, v := range x {
+		if math.Abs(v*goldenHeadroom) >= 1 {
+			t.Fatalf("golden %s peak %.3g exceeds the headroom", name, v)
+		}
+		data[i] = float32(v * goldenHeadroom)
+	}
+	enc := wav.NewEncoder(f, goldenSampleRate, 32, 1, 3)
+	buf := &audio.Float32Buffer{
+		Format:         &audio.Format{SampleRate: goldenSampleRate, NumChannels: 1},
+		Data:           data,
+		SourceBitDepth: 32,
+	}
+	if err := enc.Write(buf); err != nil {
+		t.Fatalf("write golden: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("close golden: %v", err)
+	}
+}
+
+func TestGoldenRenders(t *testing.T) {
+	for _, c := range goldenCases() {
+		t.Run(c.name, func(t *testing.T) {
+			got := renderGolden(c, nil)
+			if *updateGolden {
+				writeGolden(t, c.name, got)
+				return
+			}
+			for _, msg := range goldenMismatches(loadGolden(t, c.name), got) {
+				t.Errorf("%s: %s", c.name, msg)
+			}
+		})
+	}
+}
+
+func TestGoldenTolerancesPassInaudibleChanges(t *testing.T) {
+	// Sample differences of this size come from a different FFT or
+	// convolution backend, not from an engine change.
+	const perturbation = 1e-5
+	rng := rand.New(rand.NewSource(1))
+	for _, c := range goldenCases() {
+		golden := loadGolden(t, c.name)
+		perturbed := make([]float64, len(golden))
+		for i, v := range golden {
+			perturbed[i] = v + perturbation*(2*rng.Float64()-1)
+		}
+		for _, msg := range goldenMismatches(golden, perturbed) {
+			t.Errorf("%s: %g perturbation: %s", c.name, perturbation, msg)
+		}
+	}
+}
+
+func TestGoldenTolerancesCatchAudibleChanges(t *testing.T) {
+	c := goldenCases()[0]
+	golden := loadGolden(t, c.name)
+	changes := map[string]func(p *Params){
+		"damping": func(p *Params) { p.HighFreqDamping *= 1.5 },
+		"gain":    func(p *Params) { p.OutputGain = 0.8 },
+		"hammer":  func(p *Params) { p.HammerStiffnessScale = 1.3 },
+	}
+	for name, tweak := range changes {
+		if len(goldenMismatches(golden, renderGolden(c, tweak))) == 0 {
+			t.Errorf("%s change passed the golden tolerances", name)
+		}
+	}
+}
@@ -32,6 +32,14 @@ type Hammer struct {
 
 // NewHammer creates a hammer initialized from MIDI velocity.
 func NewHammer(sampleRate int, velocity int) *Hammer {
+	h := &Hammer{}
+	h.init(sampleRate, velocity)
+	return h
+}
+
+// init resets h to a fresh strike at MIDI velocity so pooled hammers can be
+// reused without allocating.
+func (h *Hammer) init(sampleRate int, velocity int) {
 	if velocity < 1 {
 		velocity = 1
 	}
@@ -46,7 +54,7 @@ func NewHammer(sampleRate int, velocity int) *Hammer {
 	contactMax := int(float32(sampleRate) * (0.0040 - 0.0030*v))
 	contactMin := int(float32(sampleRate) * 0.00025)
 
-	return &Hammer{
+	*h = Hammer{
 		sampleRate:        float32(sampleRate),
 		mass:              0.010,
 		stiffness:         stiffness,
@@ -111,6 +119,26 @@ func (h *Hammer) ApplyInfluenceScales(stiffScale, expScale, dampScale, velScale,
 	h.contactMinSamples = h.baseContactMin
 }
 
+// noteHammerScales returns the stiffness, exponent and contact-time scales
+// of note: the global Params scales times the note's NoteParams scales.
+func noteHammerScales(params *Params, note int) (stiff, exp, contact float32) {
+	orOne := func(v float32) float32 {
+		if v <= 0 {
+			return 1
+		}
+		return v
+	}
+	stiff = orOne(params.HammerStiffnessScale)
+	exp = orOne(params.HammerExponentScale)
+	contact = orOne(params.HammerContactTimeScale)
+	if np := params.PerNote[note]; np != nil {
+		stiff *= orOne(np.HammerStiffnessScale)
+		exp *= orOne(np.HammerExponentScale)
+		contact *= orOne(np.HammerContactTimeScale)
+	}
+	return stiff, exp, contact
+}
+
 func maxInt(a int, b int) int {
 	if a > b {
 		return a
@@ -136,3 +136,124 @@ func TestExpDecayPerSample(t *testing.T) {
 		t.Fatalf("expected ~0.001 after 1000 samples, got %f", final)
 	}
 }
+
+func TestNoteOnExOverridesStrikeAndHardness(t *testing.T) {
+	const note = 60
+	p := NewPiano(48000, 16, NewDefaultParams())
+	p.NoteOn(note, 100)
+	base := p.hammerExciter.active[note][0]
+
+	p.NoteOnEx(note, 100, NoteOptions{StrikePosition: 0.5, Hardness: 0.6})
+	ex := p.hammerExciter.active[note][1]
+	if ex.strikePos != 0.5 {
+		t.Fatalf("expected strike position override 0.5, got %f", ex.strikePos)
+	}
+	if ex.hammer.stiffness >= base.hammer.stiffness {
+		t.Fatalf("expected softer felt: base=%f ex=%f", base.hammer.stiffness, ex.hammer.stiffness)
+	}
+
+	// Hardness combines with the soft pedal instead of replacing it.
+	p.SetSoftPedal(true)
+	p.NoteOnEx(note, 100, NoteOptions{Hardness: 1.2})
+	soft := p.hammerExciter.active[note][2]
+	want := base.hammer.baseStiff * 1.2 * 0.78
+	if math.Abs(float64(soft.hammer.stiffness-want)) > 1e-3*float64(want) {
+		t.Fatalf("expected combined hardness stiffness %f, got %f", want, soft.hammer.stiffness)
+	}
+}
+
+func TestNoteOnExMuteKeepsDamperEngaged(t *testing.T) {
+	const note = 60
+	open := NewPiano(48000, 16, NewDefaultParams())
+	open.NoteOn(note, 100)
+	muted := NewPiano(48000, 16, NewDefaultParams())
+	muted.NoteOnEx(note, 100, NoteOptions{Mute: true})
+
+	_ = open.Process(2400)
+	_ = muted.Process(2400)
+	var openTail, mutedTail []float32
+	for i := 0; i < 20; i++ {
+		openTail = open.Process(256)
+		mutedTail = muted.Process(256)
+	}
+	if stereoRMS(mutedTail) >= 0.3*stereoRMS(openTail) {
+		t.Fatalf("expected muted strike to die away: open=%f muted=%f", stereoRMS(openTail), stereoRMS(mutedTail))
+	}
+}
+
+func TestHammerExciterReusesPooledStrikes(t *testing.T) {
+	const sampleRate = 48000
+	params := NewDefaultParams()
+	params.AttackNoiseLevel = 0.2
+	params.AttackNoiseDurationMs = 3
+	exciter := NewHammerExciter(sampleRate, params)
+	exciter.reserve(16)
+	bank := NewStringBank(sampleRate, params)
+
+	strikeAndDrain := func() {
+		for note := 48; note < 64; note++ {
+			exciter.Trigger(note, 100)
+		}
+		for i := 0; i < sampleRate/20; i++ {
+			exciter.ProcessSample(bank)
+		}
+	}
+	strikeAndDrain()
+	if len(exciter.free) != 16 {
+		t.Fatalf("expected finished strikes back in the pool, got %d free", len(exciter.free))
+	}
+	allocs := testing.AllocsPerRun(20, strikeAndDrain)
+	if allocs != 0 {
+		t.Fatalf("expected zero heap allocs per strike within polyphony, got %.3f", allocs)
+	}
+}
+
+func TestPooledStrikeMatchesFreshStrike(t *testing.T) {
+	const sampleRate = 48000
+	params := NewDefaultParams()
+	exciter := NewHammerExciter(sampleRate, params)
+	exciter.reserve(1)
+	bank := NewStringBank(sampleRate, params)
+
+	exciter.Trigger(60, 30)
+	for i := 0; i < sampleRate/20; i++ {
+		exciter.ProcessSample(bank)
+	}
+	exciter.Trigger(60, 110)
+	reused := exciter.active[60][0].hammer
+
+	fresh := NewHammerExciter(sampleRate, params)
+	fresh.Trigger(60, 110)
+	if reused != fresh.active[60][0].hammer {
+		t.Fatalf("expected pooled hammer state to match a fresh strike")
+	}
+}
+
+func TestPerNoteHammerScalesMultiplyGlobal(t *testing.T) {
+	ref := NewPiano(48000, 16, NewDefaultParams())
+	ref.NoteOn(60, 100)
+	ref.NoteOn(61, 100)
+
+	params := NewDefaultParams()
+	params.HammerStiffnessScale = 1.2
+	params.PerNote[60] = &NoteParams{HammerStiffnessScale: 1.5, HammerExponentScale: 1.1, HammerContactTimeScale: 0.5}
+	p := NewPiano(48000, 16, params)
+	p.NoteOn(60, 100)
+	p.NoteOn(61, 100)
+
+	base, got := ref.hammerExciter.active[60][0].hammer, p.hammerExciter.active[60][0].hammer
+	if want := base.baseStiff * 1.2 * 1.5; math.Abs(float64(got.baseStiff-want)) > 1e-4*float64(want) {
+		t.Fatalf("note 60 stiffness %f, want global times per-note scale %f", got.baseStiff, want)
+	}
+	if want := base.baseExp * 1.1; math.Abs(float64(got.baseExp-want)) > 1e-5 {
+		t.Fatalf("note 60 exponent %f, want %f", got.baseExp, want)
+	}
+	if want := int(float32(base.baseContactMax) * 0.5); got.baseContactMax != want {
+		t.Fatalf("note 60 max contact %d samples, want %d", got.baseContactMax, want)
+	}
+
+	other, otherRef := p.hammerExciter.active[61][0].hammer, ref.hammerExciter.active[61][0].hammer
+	if want := otherRef.baseStiff * 1.2; math.Abs(float64(other.baseStiff-want)) > 1e-4*float64(want) || other.baseContactMax != otherRef.baseContactMax {
+		t.Fatalf("note 61 picked up note 60's hammer scales: stiffness %f (want %f)", other.baseStiff, want)
+	}
+}
@@ -2,6 +2,7 @@ package piano
 
 import (
 	"math"
+	"os"
 	"testing"
 
 	algofft "github.com/cwbudde/algo-fft"
@@ -29,6 +30,340 @@ func TestLongRenderHasNoNaNOrInf(t *testing.T) {
 	}
 }
 
+func TestRenderIsIndependentOfHostBlockSize(t *testing.T) {
+	// Events at frame 0, 6400 (NoteOff) and 9600 (pedal); every host
+	// splits its blocks there so each sees them at the same frame.
+	render := func(blockSize int) []float32 {
+		params := NewDefaultParams()
+		params.ResonanceEnabled = true
+		params.RoomWetMix = 0.3
+		p := NewPiano(48000, 16, params)
+		p.SetRoomIR([]float32{0.5, 0.2, -0.1, 0.05}, []float32{0.4, -0.2, 0.1, 0.02})
+		p.NoteOn(48, 90)
+		p.NoteOn(64, 70)
+		out := make([]float32, 0, 12800*2)
+		for _, seg := range []struct {
+			end   int
+			event func()
+		}{
+			{6400, func() { p.NoteOff(48) }},
+			{9600, func() { p.SetSustainPedal(true) }},
+			{12800, func() {}},
+		} {
+			for len(out)/2 < seg.end {
+				out = append(out, p.Process(min(blockSize, seg.end-len(out)/2))...)
+			}
+			seg.event()
+		}
+		return out
+	}
+
+	want := render(128)
+	for _, blockSize := range []int{1, 37, 64, 200, 1000} {
+		got := render(blockSize)
+		if len(got) != len(want) {
+			t.Fatalf("block %d: length %d, want %d", blockSize, len(got), len(want))
+		}
+		for i := range want {
+			if math.Float32bits(got[i]) != math.Float32bits(want[i]) {
+				t.Fatalf("block %d: sample %d differs: %v vs %v", blockSize, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestEventsTakeEffectAtNextInternalBlock(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	silent := p.Process(internalBlockSize / 2)
+	p.NoteOn(60, 100) // mid internal block: starts with the next one
+	rest := p.Process(internalBlockSize)
+	for i, v := range append(silent, rest[:internalBlockSize]...) {
+		if v != 0 {
+			t.Fatalf("sample %d is %v before the internal block boundary", i, v)
+		}
+	}
+	if stereoRMS(rest[internalBlockSize:]) == 0 {
+		t.Fatal("the note must sound from the next internal block on")
+	}
+}
+
+func TestProcessIntoHasNoPerBlockHeapAllocs(t *testing.T) {
+	params := NewDefaultParams()
+	params.AttackNoiseLevel = 0.2
+	params.ResonanceEnabled = true
+	params.RoomWetMix = 0.3
+	p := NewPiano(48000, 16, params)
+	p.SetIR([]float32{1, 0.5, 0.25, 0.1}, []float32{1, 0.4, 0.2, 0.1})
+	p.SetSustainPedal(true)
+	buf := make([]float32, 2*100)
+	strike := func() {
+		p.NoteOn(60, 100)
+		p.NoteOn(64, 90)
+		p.ProcessInto(buf)
+	}
+	strike()
+
+	allocs := testing.AllocsPerRun(200, strike)
+	if allocs != 0 {
+		t.Fatalf("expected zero heap allocs per NoteOn+ProcessInto, got %.3f", allocs)
+	}
+}
+
+func TestProcessMonoMatchesStereoMid(t *testing.T) {
+	params := NewDefaultParams()
+	params.RoomWetMix = 0.5
+	params.CloseMic = MicBus{GainDB: -3, Pan: -0.4}
+	params.RoomMic = MicBus{DelayMs: 5}
+	left := make([]float32, 2400)
+	right := make([]float32, 2400)
+	for i := range left {
+		left[i] = float32(math.Exp(-float64(i)/400)) * 0.05
+		right[i] = float32(math.Exp(-float64(i)/200)) * 0.05 * float32(math.Cos(float64(i)))
+	}
+	newPiano := func() *Piano {
+		p := NewPiano(48000, 8, params)
+		p.SetRoomIR(left, right)
+		p.NoteOn(60, 100)
+		return p
+	}
+
+	const frames = 9600
+	stereo := newPiano().Process(frames)
+	p := newPiano()
+	mono := make([]float32, frames)
+	// Odd chunk sizes cross internal block boundaries.
+	for done := 0; done < frames; {
+		n := min(301, frames-done)
+		p.ProcessMonoInto(mono[done : done+n])
+		done += n
+	}
+	if windowRMS(mono) == 0 {
+		t.Fatal("mono render is silent")
+	}
+	for i, v := range mono {
+		mid := 0.5 * (stereo[i*2] + stereo[i*2+1])
+		if d := math.Abs(float64(v - mid)); d > 1e-5 {
+			t.Fatalf("frame %d: mono %v, stereo mid %v", i, v, mid)
+		}
+	}
+}
+
+func TestProcessMonoFollowsRoomIRChange(t *testing.T) {
+	params := NewDefaultParams()
+	params.RoomWetMix = 1
+	params.BodyDryMix = 0
+	p := NewPiano(48000, 8, params)
+	p.NoteOn(60, 100)
+	before := windowRMS(p.ProcessMono(4800))
+	p.SetRoomIR([]float32{0.1}, []float32{0.1})
+	after := windowRMS(p.ProcessMono(4800))
+	if !(after < 0.2*before) {
+		t.Fatalf("mono rms after a -20 dB room IR = %e, before %e; want the new IR used", after, before)
+	}
+}
+
+func TestProcessMonoSwapRoomIRMatchesStereoMid(t *testing.T) {
+	params := NewDefaultParams()
+	params.RoomWetMix = 1
+	params.BodyDryMix = 0
+	newPiano := func() *Piano {
+		p := NewPiano(48000, 8, params)
+		p.SetRoomIR(decayingIR(4800, 1), decayingIR(4800, 2))
+		p.NoteOn(60, 100)
+		return p
+	}
+	render := func(p *Piano, process func(frames int) []float32) []float32 {
+		out := process(4800)
+		p.SwapRoomIR(decayingIR(2400, 3), decayingIR(2400, 4), 20)
+		return append(out, process(4800)...)
+	}
+
+	s := newPiano()
+	stereo := render(s, s.Process)
+	m := newPiano()
+	mono := render(m, m.ProcessMono)
+	for i, v := range mono {
+		mid := 0.5 * (stereo[i*2] + stereo[i*2+1])
+		if d := math.Abs(float64(v - mid)); d > 1e-4 {
+			t.Fatalf("frame %d: mono %v, stereo mid %v; want the mono room to crossfade with the tail kept", i, v, mid)
+		}
+	}
+}
+
+func TestProcessMonoIntoHasNoPerBlockHeapAllocs(t *testing.T) {
+	params := NewDefaultParams()
+	params.RoomWetMix = 0.3
+	p := NewPiano(48000, 16, params)
+	p.SetRoomIR([]float32{1, 0.5, 0.25, 0.1}, []float32{1, 0.4, 0.2, 0.1})
+	buf := make([]float32, 100)
+	strike := func() {
+		p.NoteOn(60, 100)
+		p.ProcessMonoInto(buf)
+	}
+	strike()
+
+	if allocs := testing.AllocsPerRun(200, strike); allocs != 0 {
+		t.Fatalf("expected zero heap allocs per NoteOn+ProcessMonoInto, got %.3f", allocs)
+	}
+}
+
+func BenchmarkPianoProcessInto(b *testing.B) {
+	params := NewDefaultParams()
+	params.ResonanceEnabled = true
+	p := NewPiano(48000, 16, params)
+	for _, note := range []int{48, 55, 60, 64, 67} {
+		p.NoteOn(note, 100)
+	}
+	buf := make([]float32, 2*internalBlockSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		p.ProcessInto(buf)
+	}
+}
+
+// BenchmarkRoomIRProcess compares stereo and mono rendering with a
+// one-second room IR, where the room convolution dominates.
+func BenchmarkRoomIRProcess(b *testing.B) {
+	ir := make([]float32, 48000)
+	for i := range ir {
+		ir[i] = float32(math.Exp(-float64(i)/8000)) * 0.01
+	}
+	newPiano := func() *Piano {
+		params := NewDefaultParams()
+		params.RoomWetMix = 0.3
+		p := NewPiano(48000, 16, params)
+		p.SetRoomIR(ir, ir)
+		p.NoteOn(60, 100)
+		return p
+	}
+	b.Run("stereo", func(b *testing.B) {
+		p := newPiano()
+		buf := make([]float32, 2*internalBlockSize)
+		for b.Loop() {
+			p.ProcessInto(buf)
+		}
+	})
+	b.Run("mono", func(b *testing.B) {
+		p := newPiano()
+		buf := make([]float32, internalBlockSize)
+		for b.Loop() {
+			p.ProcessMonoInto(buf)
+		}
+	})
+}
+
+func TestRenderIsBitExactForSameSeed(t *testing.T) {
+	render := func(seed int64) []float32 {
+		params := NewDefaultParams()
+		params.Seed = seed
+		params.AttackNoiseLevel = 0.2
+		params.ResonanceEnabled = true
+		p := NewPiano(48000, 16, params)
+		p.NoteOn(48, 80)
+		p.NoteOn(60, 100)
+		out := make([]float32, 0, 40*256*2)
+		for i := 0; i < 40; i++ {
+			out = append(out, p.Process(256)...)
+		}
+		return out
+	}
+
+	a := render(7)
+	b := render(7)
+	if len(a) != len(b) {
+		t.Fatalf("length mismatch: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if math.Float32bits(a[i]) != math.Float32bits(b[i]) {
+			t.Fatalf("render not bit-exact at sample %d: %v vs %v", i, a[i], b[i])
+		}
+	}
+
+	c := render(8)
+	same := true
+	for i := range a {
+		if a[i] != c[i] {
+			same = false
+			break
+		}
+	}
+	if same {
+		t.Fatalf("expected different seeds to change attack noise")
+	}
+}
+
+func TestResetMatchesFreshEngine(t *testing.T) {
+	const ir = "../assets/ir/default_96k.wav"
+	first := NewDefaultParams()
+	first.RoomIRWavPath = ir
+	first.RoomWetMix = 0.3
+	first.PerNote[60] = &NoteParams{StringModel: StringModelModal}
+
+	second := NewDefaultParams()
+	second.RoomIRWavPath = ir
+	second.RoomWetMix = 0.4
+	second.ResonanceEnabled = true
+	second.AttackNoiseLevel = 0.2
+	second.VariationAmount = 0.5
+	second.TuningDriftCents = 2
+	second.UnisonDetuneScale = 0.5
+	second.CouplingMode = CouplingModePhysical
+	second.PerNote[48] = &NoteParams{Loss: 0.9993, Preparations: []Preparation{{Type: PreparationPaper, Amount: 0.6}}}
+	second.PerNote[64] = &NoteParams{StringModel: StringModelModal}
+
+	play := func(p *Piano) []float32 {
+		p.NoteOn(48, 100)
+		p.NoteOn(64, 80)
+		p.SetSustainPedal(true)
+		out := make([]float32, 0, 40*256*2)
+		for i := range 40 {
+			if i == 20 {
+				p.NoteOff(48)
+			}
+			out = append(out, p.Process(256)...)
+		}
+		return append(out, p.ProcessMono(1024)...)
+	}
+
+	warm := NewPiano(48000, 16, first)
+	room := warm.roomConvolver
+	play(warm)
+	warm.SetBodyIR([]float32{0.5, 0.25})
+	warm.Reset(second)
+	if warm.roomConvolver != room {
+		t.Fatal("expected Reset to keep the room IR loaded from the same file")
+	}
+	got := play(warm)
+	want := play(NewPiano(48000, 16, second))
+	for i := range want {
+		if math.Float32bits(got[i]) != math.Float32bits(want[i]) {
+			t.Fatalf("reset engine differs from a fresh one at sample %d: %v vs %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResetReloadsRewrittenIRFile(t *testing.T) {
+	path := writeTempIRWav(t, []float32{1.0, 0.2, 0.1, 0.0}, []float32{0.5, 0.1, 0.05, 0.0}, 48000)
+	t.Cleanup(func() { InvalidateIRCache(path) })
+	params := NewDefaultParams()
+	params.RoomIRWavPath = path
+	p := NewPiano(48000, 8, params)
+	room := p.roomConvolver
+
+	p.Reset(params)
+	if p.roomConvolver != room {
+		t.Fatal("expected Reset to keep the room IR of an unchanged file")
+	}
+	rewritten := writeTempIRWav(t, []float32{0.25, 0.2, 0.15, 0.1, 0.05, 0.0}, []float32{0.25, 0.2, 0.15, 0.1, 0.05, 0.0}, 48000)
+	if err := os.Rename(rewritten, path); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	p.Reset(params)
+	if p.roomConvolver == room || len(p.roomConvolver.leftIR) != 6 {
+		t.Fatalf("got room IR %v after the file was rewritten, want it reloaded", p.roomConvolver.leftIR)
+	}
+}
+
 func TestAlgoFFTConvolveRealMatchesDirect(t *testing.T) {
 	a := []float32{1, 2, 3, 4, 5}
 	b := []float32{0.5, -0.25, 0.125}
@@ -0,0 +1,126 @@
+package piano
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultIRCacheBytes is the default memory cap of the IR cache.
+const DefaultIRCacheBytes = 256 << 20
+
+// irCacheKey identifies one decoded and resampled IR. The body convolver
+// mixes to mono before resampling, so its IR differs from the room pair of
+// the same file. version is the file's state when looked up, so a rewritten
+// file misses the cache.
+type irCacheKey struct {
+	path       string
+	version    assetVersion
+	sampleRate int
+	mono       bool
+}
+
+type irCacheEntry struct {
+	key      irCacheKey
+	channels [][]float32
+	bytes    int64
+}
+
+// irCache keeps IRs loaded from WAV files, decoded and resampled to the
+// engine rate, so engines built again with the same IR path skip the file
+// work. Entries are shared read-only between convolvers; the least recently
+// used ones are dropped once the total size exceeds the limit.
+type irCache struct {
+	mu      sync.Mutex
+	limit   int64
+	size    int64
+	entries map[irCacheKey]*list.Element
+	lru     list.List
+}
+
+var irs = newIRCache(DefaultIRCacheBytes)
+
+func newIRCache(limit int64) *irCache {
+	return &irCache{limit: limit, entries: make(map[irCacheKey]*list.Element)}
+}
+
+// SetIRCacheLimit sets the memory cap of the process-wide IR cache in bytes
+// and evicts entries beyond it. A limit <= 0 disables the cache.
+func SetIRCacheLimit(bytes int64) {
+	irs.setLimit(bytes)
+}
+
+// InvalidateIRCache drops the cached IRs loaded from path at every sample
+// rate. A rewritten file misses the cache anyway; this frees its stale
+// entries. An empty path clears the cache.
+func InvalidateIRCache(path string) {
+	irs.invalidate(path)
+}
+
+// load returns the cached IR for key, calling loader on a miss. Loads run
+// outside the lock, so two engines missing the same key at once both decode
+// the file and the first stored result wins.
+func (c *irCache) load(key irCacheKey, loader func() ([][]float32, error)) ([][]float32, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		channels := e.Value.(*irCacheEntry).channels
+		c.mu.Unlock()
+		return channels, nil
+	}
+	c.mu.Unlock()
+
+	channels, err := loader()
+	if err != nil {
+		return nil, err
+	}
+	var bytes int64
+	for _, ch := range channels {
+		bytes += int64(len(ch)) * 4
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*irCacheEntry).channels, nil
+	}
+	if bytes > c.limit {
+		return channels, nil
+	}
+	c.entries[key] = c.lru.PushFront(&irCacheEntry{key: key, channels: channels, bytes: bytes})
+	c.size += bytes
+	c.evict()
+	return channels, nil
+}
+
+func (c *irCache) setLimit(limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = limit
+	c.evict()
+}
+
+func (c *irCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if path == "" || e.Value.(*irCacheEntry).key.path == path {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+
+// evict drops least recently used entries until the cache fits its limit.
+func (c *irCache) evict() {
+	for c.size > c.limit && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *irCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*irCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.bytes
+}
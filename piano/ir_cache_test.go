@@ -0,0 +1,111 @@
+package piano
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func TestIRCacheSharesDecodedIRUntilInvalidated(t *testing.T) {
+	path := writeTempIRWav(t, []float32{1.0, 0.2, 0.1, 0.0}, []float32{0.5, 0.1, 0.05, 0.0}, 96000)
+	t.Cleanup(func() { InvalidateIRCache(path) })
+
+	a := NewSoundboardConvolver(48000)
+	b := NewSoundboardConvolver(48000)
+	if err := a.SetIRFromWAV(path); err != nil {
+		t.Fatalf("SetIRFromWAV failed: %v", err)
+	}
+	if err := b.SetIRFromWAV(path); err != nil {
+		t.Fatalf("SetIRFromWAV failed: %v", err)
+	}
+	if &a.leftIR[0] != &b.leftIR[0] {
+		t.Fatal("expected the second load to reuse the cached IR")
+	}
+
+	other := NewSoundboardConvolver(44100)
+	if err := other.SetIRFromWAV(path); err != nil {
+		t.Fatalf("SetIRFromWAV failed: %v", err)
+	}
+	if &other.leftIR[0] == &a.leftIR[0] {
+		t.Fatal("expected another sample rate to be cached separately")
+	}
+
+	InvalidateIRCache(path)
+	c := NewSoundboardConvolver(48000)
+	if err := c.SetIRFromWAV(path); err != nil {
+		t.Fatalf("SetIRFromWAV failed: %v", err)
+	}
+	if &c.leftIR[0] == &a.leftIR[0] {
+		t.Fatal("expected InvalidateIRCache to force a reload")
+	}
+	for i := range a.leftIR {
+		if c.leftIR[i] != a.leftIR[i] {
+			t.Fatalf("reloaded IR differs at %d: %v vs %v", i, c.leftIR[i], a.leftIR[i])
+		}
+	}
+}
+
+func TestIRCacheEvictsLeastRecentlyUsedOverLimit(t *testing.T) {
+	c := newIRCache(3 * 4 * 100)
+	loads := 0
+	load := func(path string) {
+		t.Helper()
+		_, err := c.load(irCacheKey{path: path, sampleRate: 48000}, func() ([][]float32, error) {
+			loads++
+			return [][]float32{make([]float32, 100)}, nil
+		})
+		if err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+	}
+
+	load("a")
+	load("b")
+	load("c")
+	load("a")
+	load("d") // over the limit: b is the least recently used
+	if loads != 4 {
+		t.Fatalf("loads = %d, want 4", loads)
+	}
+	if _, ok := c.entries[irCacheKey{path: "b", sampleRate: 48000}]; ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if c.size != 3*4*100 {
+		t.Fatalf("cache size = %d, want %d", c.size, 3*4*100)
+	}
+	load("a")
+	if loads != 4 {
+		t.Fatal("expected a to stay cached")
+	}
+
+	c.setLimit(0)
+	if c.lru.Len() != 0 || c.size != 0 {
+		t.Fatalf("disabled cache keeps %d entries, %d bytes", c.lru.Len(), c.size)
+	}
+	load("a")
+	load("a")
+	if loads != 6 {
+		t.Fatalf("loads = %d with the cache disabled, want 6", loads)
+	}
+}
+
+func TestIRCacheReloadsRewrittenFile(t *testing.T) {
+	path := writeTempIRWav(t, []float32{1.0, 0.2, 0.1, 0.0}, nil, 48000)
+	t.Cleanup(func() { InvalidateIRCache(path) })
+	a := NewBodyConvolver(48000)
+	if err := a.SetIRFromWAV(path, 48000); err != nil {
+		t.Fatalf("SetIRFromWAV failed: %v", err)
+	}
+
+	rewritten := writeTempIRWav(t, []float32{0.5, 0.4, 0.3, 0.2, 0.1, 0.0}, nil, 48000)
+	if err := os.Rename(rewritten, path); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	b := NewBodyConvolver(48000)
+	if err := b.SetIRFromWAV(path, 48000); err != nil {
+		t.Fatalf("SetIRFromWAV failed: %v", err)
+	}
+	if len(b.ir) != 6 || math.Abs(float64(b.ir[0])-0.5) > 1e-3 {
+		t.Fatalf("got IR %v after the file was rewritten, want the new one", b.ir)
+	}
+}
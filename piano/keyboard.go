@@ -0,0 +1,110 @@
+package piano
+
+// Standard 88-key range (A0..C8) used when Params leave MinNote/MaxNote at
+// their defaults.
+const (
+	StandardMinNote = 21
+	StandardMaxNote = 108
+)
+
+// KeyboardRange returns the inclusive MIDI note range of a keyboard with the
+// given number of keys: 88 (A0..C8), 97 (C0..C8, Bösendorfer Imperial) or
+// 102 (C0..F8, Stuart & Sons).
+func KeyboardRange(keys int) (minNote int, maxNote int, ok bool) {
+	switch keys {
+	case 88:
+		return StandardMinNote, StandardMaxNote, true
+	case 97:
+		return 12, 108, true
+	case 102:
+		return 12, 113, true
+	}
+	return 0, 0, false
+}
+
+// MaxUnisonStrings bounds the strings per note a unison table may use.
+const MaxUnisonStrings = 4
+
+// UnisonRegister describes the stringing of all notes below BelowNote that
+// are not covered by an earlier register: one detune (cents) and gain per
+// string.
+type UnisonRegister struct {
+	BelowNote int
+	Detunes   []float32
+	Gains     []float32
+}
+
+// defaultUnisonRegisters covers the full MIDI range from bass to treble, so
+// any configured note range resolves to a stringing: keys below A0 on
+// extended keyboards are single wound strings like the rest of the low bass,
+// and keys above C8 stay trichords.
+var defaultUnisonRegisters = []UnisonRegister{
+	{BelowNote: 40, Detunes: []float32{0.0}, Gains: []float32{1.0}},
+	{BelowNote: 70, Detunes: []float32{-1.8, 1.8}, Gains: []float32{0.52, 0.48}},
+	{BelowNote: 128, Detunes: []float32{-3.0, 0.0, 3.0}, Gains: []float32{0.34, 0.33, 0.33}},
+}
+
+// DefaultUnisonRegisters returns a copy of the grand-piano stringing used
+// when Params.UnisonRegisters is empty, a starting point for custom tables.
+func DefaultUnisonRegisters() []UnisonRegister {
+	out := make([]UnisonRegister, len(defaultUnisonRegisters))
+	for i, r := range defaultUnisonRegisters {
+		out[i] = UnisonRegister{
+			BelowNote: r.BelowNote,
+			Detunes:   append([]float32(nil), r.Detunes...),
+			Gains:     append([]float32(nil), r.Gains...),
+		}
+	}
+	return out
+}
+
+// unisonForNote resolves the stringing of note: the per-note override,
+// else the first Params.UnisonRegisters entry above the note (the last one
+// past the table), else the default table. Missing or mismatched gains
+// split the level equally.
+func unisonForNote(params *Params, note int) (detunes []float32, gains []float32) {
+	registers := defaultUnisonRegisters
+	if params != nil {
+		if np, ok := params.PerNote[note]; ok && np != nil && len(np.UnisonDetunes) > 0 {
+			return np.UnisonDetunes, unisonGains(np.UnisonDetunes, np.UnisonGains)
+		}
+		if len(params.UnisonRegisters) > 0 {
+			registers = params.UnisonRegisters
+		}
+	}
+	r := registers[len(registers)-1]
+	for _, reg := range registers {
+		if note < reg.BelowNote {
+			r = reg
+			break
+		}
+	}
+	return r.Detunes, unisonGains(r.Detunes, r.Gains)
+}
+
+// equalUnisonGains holds the equal level splits of up to MaxUnisonStrings
+// strings, shared so resolving a stringing does not allocate.
+var equalUnisonGains = func() [][]float32 {
+	out := make([][]float32, MaxUnisonStrings+1)
+	for n := 1; n <= MaxUnisonStrings; n++ {
+		out[n] = make([]float32, n)
+		for i := range out[n] {
+			out[n][i] = 1 / float32(n)
+		}
+	}
+	return out
+}()
+
+func unisonGains(detunes []float32, gains []float32) []float32 {
+	if len(gains) == len(detunes) {
+		return gains
+	}
+	if len(detunes) < len(equalUnisonGains) {
+		return equalUnisonGains[len(detunes)]
+	}
+	equal := make([]float32, len(detunes))
+	for i := range equal {
+		equal[i] = 1 / float32(len(detunes))
+	}
+	return equal
+}
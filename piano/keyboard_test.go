@@ -0,0 +1,113 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKeyboardRangeKnownLayouts(t *testing.T) {
+	cases := []struct {
+		keys     int
+		min, max int
+	}{
+		{88, 21, 108},
+		{97, 12, 108},
+		{102, 12, 113},
+	}
+	for _, tc := range cases {
+		lo, hi, ok := KeyboardRange(tc.keys)
+		if !ok || lo != tc.min || hi != tc.max {
+			t.Fatalf("KeyboardRange(%d) = %d..%d ok=%v, want %d..%d", tc.keys, lo, hi, ok, tc.min, tc.max)
+		}
+		if hi-lo+1 != tc.keys {
+			t.Fatalf("KeyboardRange(%d) spans %d keys", tc.keys, hi-lo+1)
+		}
+	}
+	if _, _, ok := KeyboardRange(61); ok {
+		t.Fatalf("expected unknown layout to be rejected")
+	}
+}
+
+func TestStringBankExtendedRangeUsesRegisterTable(t *testing.T) {
+	params := NewDefaultParams()
+	params.MinNote, params.MaxNote, _ = KeyboardRange(102)
+	sb := NewStringBank(48000, params)
+
+	low := sb.Group(12)
+	high := sb.Group(113)
+	if low == nil || high == nil {
+		t.Fatalf("expected extended edge notes to be allocated")
+	}
+	if len(low.strings) != 1 {
+		t.Fatalf("expected C0 to be a single string, got %d", len(low.strings))
+	}
+	if len(high.strings) != 3 {
+		t.Fatalf("expected F8 to be a trichord, got %d", len(high.strings))
+	}
+}
+
+func TestExtendedRangeEdgeNotesRenderFinite(t *testing.T) {
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		for _, sr := range []int{44100, 96000} {
+			params := NewDefaultParams()
+			params.MinNote, params.MaxNote, _ = KeyboardRange(102)
+			params.StringModel = model
+			params.CouplingMode = CouplingModePhysical
+			p := NewPiano(sr, 16, params)
+			p.NoteOn(12, 110)
+			p.NoteOn(113, 110)
+
+			var energy float64
+			for i := 0; i < 40; i++ {
+				out := p.Process(256)
+				for _, v := range out {
+					if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+						t.Fatalf("model=%s sr=%d: non-finite output", model, sr)
+					}
+					energy += float64(v) * float64(v)
+				}
+			}
+			if energy == 0 {
+				t.Fatalf("model=%s sr=%d: expected audible output from extended keys", model, sr)
+			}
+			if _, ok := findLevel(p.Levels(), 113); !ok {
+				t.Fatalf("model=%s sr=%d: expected F8 to sound", model, sr)
+			}
+		}
+	}
+}
+
+func TestUnisonRegistersConfigureStringing(t *testing.T) {
+	params := NewDefaultParams()
+	// Upright-like stringing: bichords down to the tenor, no trichords.
+	params.UnisonRegisters = []UnisonRegister{
+		{BelowNote: 33, Detunes: []float32{0}},
+		{BelowNote: 128, Detunes: []float32{-6, 6}, Gains: []float32{0.5, 0.5}},
+	}
+	params.PerNote[72] = &NoteParams{UnisonDetunes: []float32{-12, 0, 12, 20}}
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		params.StringModel = model
+		sb := NewStringBank(48000, params)
+		for note, want := range map[int]int{21: 1, 40: 2, 96: 2, 72: 4} {
+			if got := sb.noteStringCount(note); got != want {
+				t.Fatalf("model=%s note %d has %d strings, want %d", model, note, got, want)
+			}
+		}
+	}
+
+	detunes, gains := unisonForNote(params, 72)
+	if len(gains) != len(detunes) || gains[0] != 0.25 {
+		t.Fatalf("per-note override without gains: gains %v, want an equal split", gains)
+	}
+	if d, _ := unisonForNote(nil, 60); len(d) != 2 {
+		t.Fatalf("default stringing of C4 has %d strings, want 2", len(d))
+	}
+}
+
+func TestDefaultUnisonRegistersReturnsCopy(t *testing.T) {
+	r := DefaultUnisonRegisters()
+	r[0].Detunes[0] = 5
+	if d, _ := unisonForNote(nil, 21); d[0] != 0 {
+		t.Fatalf("editing the returned table changed the engine default: %v", d)
+	}
+}
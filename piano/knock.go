@@ -0,0 +1,166 @@
+package piano
+
+import (
+	"math"
+
+	"github.com/cwbudde/algo-dsp/dsp/filter/biquad"
+)
+
+// HammerKnockBands is the number of octave bands of the hammer knock.
+const HammerKnockBands = 8
+
+// HammerKnockBandHz are the centre frequencies of the hammer knock filter
+// bank; Params.HammerKnockBandsDB holds one gain per band.
+var HammerKnockBandHz = [HammerKnockBands]float64{63, 125, 250, 500, 1000, 2000, 4000, 8000}
+
+const (
+	// knockBandQ gives each band filter an octave between its -3 dB points.
+	knockBandQ = math.Sqrt2
+	// knockVoices is how many knocks can overlap; a further strike takes
+	// over the quietest one.
+	knockVoices = 8
+	// knockTailMs is how long the band filters run on after the last
+	// excitation sample.
+	knockTailMs = 50.0
+)
+
+// knockVoice is the envelope and noise source of one strike's knock.
+type knockVoice struct {
+	wait      int // frames into the block before the strike
+	pos       int // frames since the strike
+	amp       float64
+	env       float64
+	attackLen int
+	rng       uint32
+}
+
+// hammerKnock mixes the knock of every strike into the body output: white
+// noise per strike under a linear rise and exponential fall, summed and
+// filtered by a bank of octave band-passes with the preset's band gains.
+// The bank output is normalized so HammerKnockLevel is its RMS at the
+// envelope peak of a velocity 127 strike.
+type hammerKnock struct {
+	level     float64
+	seed      int64
+	attackLen int
+	decay     float64 // per-sample envelope factor after the peak
+	bands     []*biquad.Section
+	gains     []float64
+	tailLen   int
+
+	voices [knockVoices]knockVoice
+	active int // voices in use, at the front of voices
+	tail   int // frames the filters still run without excitation
+}
+
+// newHammerKnock builds the knock from the Params.HammerKnock fields; nil
+// is returned when it is off.
+func newHammerKnock(sampleRate int, params *Params) *hammerKnock {
+	if sampleRate <= 0 || params == nil || params.HammerKnockLevel <= 0 {
+		return nil
+	}
+	sr := float64(sampleRate)
+	decaySamples := max(float64(params.HammerKnockDecayMs)*0.001*sr, 1)
+	k := &hammerKnock{
+		seed:      params.Seed,
+		attackLen: max(int(float64(params.HammerKnockAttackMs)*0.001*sr), 1),
+		decay:     math.Pow(10, -3/decaySamples),
+		tailLen:   int(knockTailMs * 0.001 * sr),
+	}
+	// White noise uniform in [-1,1] has variance 1/3; a unit-peak band-pass
+	// passes the share of it in its noise bandwidth, pi/2 times the -3 dB
+	// bandwidth.
+	power := 0.0
+	for b, fc := range HammerKnockBandHz {
+		if fc >= 0.45*sr {
+			break
+		}
+		g := math.Pow(10, float64(params.HammerKnockBandsDB[b])/20)
+		w0 := 2 * math.Pi * fc / sr
+		alpha := math.Sin(w0) / (2 * knockBandQ)
+		a0 := 1 + alpha
+		k.bands = append(k.bands, biquad.NewSection(biquad.Coefficients{
+			B0: alpha / a0,
+			B2: -alpha / a0,
+			A1: -2 * math.Cos(w0) / a0,
+			A2: (1 - alpha) / a0,
+		}))
+		k.gains = append(k.gains, g)
+		power += g * g / 3 * (math.Pi / 2) * (fc / knockBandQ) / (0.5 * sr)
+	}
+	if power <= 0 {
+		return nil
+	}
+	k.level = float64(params.HammerKnockLevel) / math.Sqrt(power)
+	return k
+}
+
+// strike starts the knock of a strike at frame offset into the next block
+// rendered.
+func (k *hammerKnock) strike(offset int, note int, velocity int) {
+	if k == nil || velocity <= 0 {
+		return
+	}
+	i := k.active
+	if i == knockVoices {
+		i = 0
+		for j := range k.voices {
+			if k.voices[j].amp*k.voices[j].env < k.voices[i].amp*k.voices[i].env {
+				i = j
+			}
+		}
+	} else {
+		k.active++
+	}
+	k.voices[i] = knockVoice{
+		wait:      offset,
+		amp:       float64(velocity) / 127,
+		attackLen: k.attackLen,
+		rng:       strikeNoiseSeed(k.seed, note, velocity) ^ 0x9e3779b9,
+	}
+	if k.voices[i].rng == 0 {
+		k.voices[i].rng = 1
+	}
+}
+
+// mixInto adds the knock to a mono block.
+func (k *hammerKnock) mixInto(buf []float32) {
+	if k == nil || (k.active == 0 && k.tail == 0) {
+		return
+	}
+	for n := range buf {
+		x := 0.0
+		for i := 0; i < k.active; i++ {
+			v := &k.voices[i]
+			if v.wait > 0 {
+				v.wait--
+				continue
+			}
+			if v.pos < v.attackLen {
+				v.env = float64(v.pos+1) / float64(v.attackLen)
+			} else {
+				v.env *= k.decay
+			}
+			v.pos++
+			white := float64(xorshift32(&v.rng))*2.3283064365386963e-10*2 - 1
+			x += white * v.env * v.amp
+			if v.pos > v.attackLen && v.env < 1e-3 {
+				k.active--
+				k.voices[i] = k.voices[k.active]
+				i--
+			}
+		}
+		if k.active > 0 {
+			k.tail = k.tailLen
+		} else if k.tail == 0 {
+			return
+		} else {
+			k.tail--
+		}
+		y := 0.0
+		for b, s := range k.bands {
+			y += k.gains[b] * s.ProcessSample(x)
+		}
+		buf[n] += float32(y * k.level)
+	}
+}
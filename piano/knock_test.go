@@ -0,0 +1,118 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHammerKnockOffByDefault(t *testing.T) {
+	k := newHammerKnock(48000, NewDefaultParams())
+	if k != nil {
+		t.Fatalf("expected no hammer knock with default params")
+	}
+	// A nil knock is a no-op.
+	buf := make([]float32, 64)
+	k.strike(0, 60, 100)
+	k.mixInto(buf)
+	for i, v := range buf {
+		if v != 0 {
+			t.Fatalf("nil knock wrote %g at %d", v, i)
+		}
+	}
+}
+
+// knockBlock renders one velocity 127 strike of a knock built from params.
+func knockBlock(sampleRate int, params *Params, frames int) []float32 {
+	k := newHammerKnock(sampleRate, params)
+	k.strike(0, 60, 127)
+	buf := make([]float32, frames)
+	k.mixInto(buf)
+	return buf
+}
+
+func rmsF32(x []float32) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(max(len(x), 1)))
+}
+
+func TestHammerKnockLevelIsPeakRMS(t *testing.T) {
+	const sampleRate = 48000
+	params := NewDefaultParams()
+	params.HammerKnockLevel = 0.1
+	params.HammerKnockAttackMs = 1
+	params.HammerKnockDecayMs = 20000 // near-flat envelope over the window
+	buf := knockBlock(sampleRate, params, sampleRate/4)
+
+	got := rmsF32(buf[sampleRate/50 : sampleRate/50+sampleRate/10])
+	if db := 20 * math.Log10(got/0.1); math.Abs(db) > 1.5 {
+		t.Fatalf("knock RMS = %.4f (%.2f dB off), want ~0.1", got, db)
+	}
+}
+
+func TestHammerKnockDecays(t *testing.T) {
+	const sampleRate = 48000
+	params := NewDefaultParams()
+	params.HammerKnockLevel = 0.1
+	params.HammerKnockDecayMs = 60
+	buf := knockBlock(sampleRate, params, sampleRate/5)
+
+	early := rmsF32(buf[:sampleRate/100])
+	late := rmsF32(buf[sampleRate*6/100 : sampleRate*7/100]) // around -60 dB
+	if !(late < early*0.01) {
+		t.Fatalf("knock RMS %.3g after its decay time vs %.3g at the strike; want at least 40 dB down", late, early)
+	}
+	for i, v := range buf[sampleRate/5-64:] {
+		if math.Abs(float64(v)) > 1e-4 {
+			t.Fatalf("knock still sounds at %g after 200 ms (sample %d)", v, i)
+		}
+	}
+}
+
+func TestHammerKnockBandsShapeTheSpectrum(t *testing.T) {
+	const sampleRate = 48000
+	// First-difference RMS relative to RMS grows with frequency.
+	brightness := func(band int) float64 {
+		params := NewDefaultParams()
+		params.HammerKnockLevel = 0.1
+		params.HammerKnockDecayMs = 20000
+		for b := range params.HammerKnockBandsDB {
+			params.HammerKnockBandsDB[b] = -60
+		}
+		params.HammerKnockBandsDB[band] = 0
+		buf := knockBlock(sampleRate, params, sampleRate/4)[sampleRate/50:]
+		diff := make([]float32, len(buf)-1)
+		for i := range diff {
+			diff[i] = buf[i+1] - buf[i]
+		}
+		return rmsF32(diff) / rmsF32(buf)
+	}
+	low, high := brightness(1), brightness(6) // 125 Hz, 4 kHz
+	if !(high > 5*low) {
+		t.Fatalf("4 kHz band brightness %.4f vs 125 Hz %.4f; want the high band much brighter", high, low)
+	}
+}
+
+func TestPianoHammerKnockStartsOnTheStrike(t *testing.T) {
+	render := func(params *Params) []float32 {
+		p := NewPiano(48000, 16, params)
+		p.ScheduleEvent(77, Event{Kind: EventNoteOn, Note: 60, Velocity: 100})
+		out := make([]float32, 0, 8*internalBlockSize*2)
+		for range 8 {
+			out = append(out, p.Process(internalBlockSize)...)
+		}
+		return out
+	}
+	base := render(NewDefaultParams())
+	knocked := NewDefaultParams()
+	knocked.HammerKnockLevel = 0.05
+	withKnock := render(knocked)
+	if d := maxAbsDiff(withKnock[:2*77], base[:2*77]); d > 1e-6 {
+		t.Fatalf("knock sounds %g before the strike", d)
+	}
+	if d := maxAbsDiff(withKnock, base); d < 1e-3 {
+		t.Fatalf("expected the knock to change the render, max diff %g", d)
+	}
+}
@@ -0,0 +1,44 @@
+package piano
+
+import "math"
+
+// NoteLevel is the string-bank output level of one note over a metering
+// window. Levels are measured before body/room convolution and output gain.
+type NoteLevel struct {
+	Note int
+	RMS  float32
+	Peak float32
+}
+
+// Levels returns the RMS and peak level of every note that produced output
+// since the previous call, in ascending note order, and starts a new metering
+// window. Notes woken by coupling or sympathetic resonance are included.
+func (p *Piano) Levels() []NoteLevel {
+	return p.ringing.takeLevels()
+}
+
+func (r *RingingState) takeLevels() []NoteLevel {
+	if r == nil || r.bank == nil {
+		return nil
+	}
+	return r.bank.takeLevels()
+}
+
+func (sb *StringBank) takeLevels() []NoteLevel {
+	var out []NoteLevel
+	frames := float64(maxInt(1, sb.levelFrames))
+	for note := 0; note < 128; note++ {
+		if sb.levelPeak[note] == 0 {
+			continue
+		}
+		out = append(out, NoteLevel{
+			Note: note,
+			RMS:  float32(math.Sqrt(sb.levelEnergy[note] / frames)),
+			Peak: sb.levelPeak[note],
+		})
+		sb.levelEnergy[note] = 0
+		sb.levelPeak[note] = 0
+	}
+	sb.levelFrames = 0
+	return out
+}
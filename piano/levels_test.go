@@ -0,0 +1,77 @@
+package piano
+
+import "testing"
+
+func TestLevelsReportsStruckNoteAndResets(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	if got := p.Levels(); len(got) != 0 {
+		t.Fatalf("expected no levels before any note, got %v", got)
+	}
+	p.NoteOn(60, 100)
+	_ = p.Process(4800)
+
+	levels := p.Levels()
+	lv, ok := findLevel(levels, 60)
+	if !ok {
+		t.Fatalf("expected a level for note 60, got %v", levels)
+	}
+	if lv.RMS <= 0 || lv.Peak < lv.RMS {
+		t.Fatalf("expected 0 < RMS <= peak, got %+v", lv)
+	}
+	if got := p.Levels(); len(got) != 0 {
+		t.Fatalf("expected Levels to reset its window, got %v", got)
+	}
+
+	p.NoteOff(60)
+	for i := 0; i < 2000 && p.ActiveVoices() > 0; i++ {
+		_ = p.Process(256)
+	}
+	_ = p.Levels()
+	_ = p.Process(256)
+	if got := p.Levels(); len(got) != 0 {
+		t.Fatalf("expected no levels once all voices are inactive, got %v", got)
+	}
+}
+
+func TestLevelsShowCouplingEnergyDistribution(t *testing.T) {
+	params := NewDefaultParams()
+	params.ResonanceEnabled = false
+	params.CouplingEnabled = true
+	params.CouplingMode = CouplingModeStatic
+	params.CouplingOctaveGain = 0.002
+	params.CouplingFifthGain = 0.0
+	params.CouplingMaxForce = 0.005
+	p := NewPiano(48000, 16, params)
+	p.SetSustainPedal(true)
+	p.NoteOn(60, 115)
+	for i := 0; i < 40; i++ {
+		_ = p.Process(128)
+	}
+
+	levels := p.Levels()
+	src, ok := findLevel(levels, 60)
+	if !ok {
+		t.Fatalf("expected a level for the struck note, got %v", levels)
+	}
+	octave, ok := findLevel(levels, 72)
+	if !ok {
+		t.Fatalf("expected coupling to make the octave audible, got %v", levels)
+	}
+	if octave.RMS <= 0 || octave.RMS >= src.RMS {
+		t.Fatalf("expected octave level below the struck note: src=%+v octave=%+v", src, octave)
+	}
+	for i := 1; i < len(levels); i++ {
+		if levels[i].Note <= levels[i-1].Note {
+			t.Fatalf("expected ascending note order, got %v", levels)
+		}
+	}
+}
+
+func findLevel(levels []NoteLevel, note int) (NoteLevel, bool) {
+	for _, lv := range levels {
+		if lv.Note == note {
+			return lv, true
+		}
+	}
+	return NoteLevel{}, false
+}
@@ -0,0 +1,56 @@
+package piano
+
+import "math"
+
+// MaxMicDelayMs bounds MicBus.DelayMs.
+const MaxMicDelayMs = 100
+
+// MicBus places one bus of the two-microphone output model: the close mic
+// picks up the dry strings through the body IR, the room mic the room IR.
+// The zero value is a unity, centred, undelayed bus.
+type MicBus struct {
+	GainDB float32
+	// Pan is a balance in [-1,1] (0 = centre): the side opposite the pan
+	// is attenuated, the other kept, so a centred bus is unchanged.
+	Pan float32
+	// DelayMs delays the bus, e.g. the room mic's extra distance from the
+	// strings. It is fixed when the engine is created.
+	DelayMs float32
+}
+
+// levels returns the bus's left and right channel gains.
+func (b MicBus) levels() (left float32, right float32) {
+	g := float32(1)
+	if b.GainDB != 0 {
+		g = float32(math.Pow(10, float64(b.GainDB)/20))
+	}
+	pan := clampf(b.Pan, -1, 1)
+	return g * min(1, 1-pan), g * min(1, 1+pan)
+}
+
+// micDelay is a fixed stereo delay line; zero length passes frames through.
+type micDelay struct {
+	buf []float32 // interleaved stereo ring
+	pos int
+}
+
+func newMicDelay(sampleRate int, ms float32) micDelay {
+	frames := int(math.Round(float64(clampf(ms, 0, MaxMicDelayMs)) * 0.001 * float64(sampleRate)))
+	if frames <= 0 {
+		return micDelay{}
+	}
+	return micDelay{buf: make([]float32, frames*2)}
+}
+
+func (d *micDelay) process(left float32, right float32) (float32, float32) {
+	if len(d.buf) == 0 {
+		return left, right
+	}
+	outL, outR := d.buf[d.pos], d.buf[d.pos+1]
+	d.buf[d.pos], d.buf[d.pos+1] = left, right
+	d.pos += 2
+	if d.pos == len(d.buf) {
+		d.pos = 0
+	}
+	return outL, outR
+}
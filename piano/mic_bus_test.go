@@ -0,0 +1,100 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMicBusLevelsPanAndGain(t *testing.T) {
+	cases := []struct {
+		bus         MicBus
+		left, right float32
+	}{
+		{MicBus{}, 1, 1},
+		{MicBus{Pan: -1}, 1, 0},
+		{MicBus{Pan: 0.5}, 0.5, 1},
+		{MicBus{GainDB: -6.0206}, 0.5, 0.5},
+		{MicBus{Pan: 3}, 0, 1},
+	}
+	for _, tc := range cases {
+		l, r := tc.bus.levels()
+		if math.Abs(float64(l-tc.left)) > 1e-4 || math.Abs(float64(r-tc.right)) > 1e-4 {
+			t.Fatalf("%+v levels = %v, %v; want %v, %v", tc.bus, l, r, tc.left, tc.right)
+		}
+	}
+}
+
+// newRoomMicPiano strikes note 60 on a piano with a short synthetic room IR.
+func newRoomMicPiano(params *Params) *Piano {
+	p := NewPiano(48000, 8, params)
+	ir := make([]float32, 2400)
+	for i := range ir {
+		ir[i] = float32(math.Exp(-float64(i)/400)) * 0.05
+	}
+	p.SetRoomIR(ir, ir)
+	p.NoteOn(60, 100)
+	return p
+}
+
+// renderStems returns the mixdown and both stems of newRoomMicPiano.
+func renderStems(params *Params, frames int) (mix, closeMic, roomMic []float32) {
+	p := newRoomMicPiano(params)
+	mix = make([]float32, frames*2)
+	closeMic = make([]float32, frames*2)
+	roomMic = make([]float32, frames*2)
+	// Odd chunk sizes cross internal block boundaries.
+	for done := 0; done < frames*2; {
+		n := min(2*301, frames*2-done)
+		p.ProcessStemsInto(mix[done:done+n], closeMic[done:done+n], roomMic[done:done+n])
+		done += n
+	}
+	return mix, closeMic, roomMic
+}
+
+func TestMicStemsSumToMixdown(t *testing.T) {
+	params := NewDefaultParams()
+	params.RoomWetMix = 0.5
+	params.CloseMic = MicBus{GainDB: -3, Pan: -0.4}
+	params.RoomMic = MicBus{Pan: 0.7, DelayMs: 12}
+	mix, closeMic, roomMic := renderStems(params, 9600)
+
+	if stereoRMS(closeMic) == 0 || stereoRMS(roomMic) == 0 {
+		t.Fatal("a stem is silent")
+	}
+	for i := range mix {
+		if d := math.Abs(float64(mix[i] - (closeMic[i] + roomMic[i]))); d > 1e-6 {
+			t.Fatalf("sample %d: mix %v != close %v + room %v", i, mix[i], closeMic[i], roomMic[i])
+		}
+	}
+
+	plain := newRoomMicPiano(params).Process(9600)
+	for i := range plain {
+		if plain[i] != mix[i] {
+			t.Fatalf("sample %d: Process %v differs from the stem mixdown %v", i, plain[i], mix[i])
+		}
+	}
+}
+
+func TestRoomMicDelayShiftsRoomStem(t *testing.T) {
+	params := NewDefaultParams()
+	params.RoomWetMix = 0.5
+	_, close0, room0 := renderStems(params, 4800)
+	params.RoomMic.DelayMs = 10
+	_, close1, room1 := renderStems(params, 4800)
+
+	const shift = 480 * 2
+	for i := range room1 {
+		want := float32(0)
+		if i >= shift {
+			want = room0[i-shift]
+		}
+		if room1[i] != want {
+			t.Fatalf("room stem sample %d = %v, want the undelayed %v", i, room1[i], want)
+		}
+	}
+	for i := range close1 {
+		if close1[i] != close0[i] {
+			t.Fatalf("room delay changed the close stem at sample %d", i)
+		}
+	}
+}
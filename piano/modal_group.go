@@ -6,6 +6,7 @@ const modalMaxPartials = 8
 
 type modalMode struct {
 	order         int
+	freq          float32
 	cosW          float32
 	sinW          float32
 	gain          float32
@@ -24,6 +25,7 @@ type modalString struct {
 type ModalStringGroup struct {
 	note       int
 	f0         float32
+	sampleRate float32
 	strings    []modalString
 	gains      []float32
 	resFilters []noteResonator
@@ -33,17 +35,43 @@ type ModalStringGroup struct {
 	undampedK  float32
 	dampedK    float32
 
+	// Mode sources kept so the tuning setters can rebuild the modes.
+	baseFreqs       []float32 // per-string fundamental including unison detune
+	lossGain        float32
+	inharmonicity   float32
+	highFreqDamping float32
+	partialLoss     []float32 // per-order loss multipliers (missing = 1)
+	partialGain     []float32 // per-order gain multipliers (missing = 1)
+
 	keyDown     bool
 	sustainDown bool
+	damper      noteDamper
 	active      bool
 	quietBlocks int
+
+	touchHarmonic int
+	touchFrames   int
+
+	strikeDetune []float32 // per-string humanization offsets (cents)
+	tuningDrift  float32   // whole-note drift offset (cents)
 }
 
 func newModalStringGroup(sampleRate int, note int, params *Params) *ModalStringGroup {
-	lossGain := float32(0.9998)
+	g := &ModalStringGroup{
+		note:       note,
+		sampleRate: float32(sampleRate),
+		damper:     newNoteDamper(sampleRate, params, note),
+	}
+	g.configure(sampleRate, params)
+	return g
+}
+
+// configure rebuilds the group's modes from params. Modes keep their state
+// unless the note's string count changes; per-partial scales set at runtime
+// are kept.
+func (g *ModalStringGroup) configure(sampleRate int, params *Params) {
 	inharmonicity := float32(0.0)
 	unisonDetuneScale := float32(1.0)
-	highFreqDamping := float32(0.05)
 	maxPartials := modalMaxPartials
 	gainExp := float32(1.1)
 	excitation := float32(1.0)
@@ -54,9 +82,6 @@ func newModalStringGroup(sampleRate int, note int, params *Params) *ModalStringG
 		if params.UnisonDetuneScale >= 0 {
 			unisonDetuneScale = params.UnisonDetuneScale
 		}
-		if params.HighFreqDamping > 0 {
-			highFreqDamping = params.HighFreqDamping
-		}
 		if params.ModalPartials > 0 {
 			maxPartials = params.ModalPartials
 		}
@@ -72,81 +97,187 @@ func newModalStringGroup(sampleRate int, note int, params *Params) *ModalStringG
 		if params.ModalDampedLoss > 0 {
 			dampedK = params.ModalDampedLoss
 		}
-		if np, ok := params.PerNote[note]; ok && np != nil {
-			if np.Loss > 0.0 && np.Loss <= 1.0 {
-				lossGain = np.Loss
-			}
+		if np, ok := params.PerNote[g.note]; ok && np != nil {
 			if np.Inharmonicity > 0.0 {
 				inharmonicity = np.Inharmonicity
 			}
 		}
 	}
 
-	freq := midiNoteToFreq(note)
-	detunes, gains := defaultUnisonForNote(note)
-	strings := make([]modalString, 0, len(detunes))
-
-	sr := float32(sampleRate)
-	nyquist := 0.5 * sr
+	freq := noteFrequency(g.note, params)
+	detunes, gains := unisonForNote(params, g.note)
+	if len(g.strings) != len(detunes) {
+		g.strings = make([]modalString, len(detunes))
+	}
+	g.baseFreqs = g.baseFreqs[:0]
 	for i := range detunes {
-		ratio := centsToRatio(detunes[i] * unisonDetuneScale)
-		baseF := freq * ratio
-		modes := make([]modalMode, 0, maxPartials)
-		for order := 1; order <= maxPartials; order++ {
-			partialF := modalPartialFrequency(baseF, float32(order), inharmonicity)
+		g.baseFreqs = append(g.baseFreqs, freq*centsToRatio(detunes[i]*unisonDetuneScale))
+	}
+	g.gains = append(g.gains[:0], gains...)
+	g.partials = maxPartials
+	g.gainExp = gainExp
+	g.excitation = excitation
+	g.undampedK = undampedK
+	g.dampedK = dampedK
+	g.lossGain = noteLoopLoss(params, g.note)
+	g.inharmonicity = inharmonicity
+	g.highFreqDamping = noteHighFreqDamping(params, g.note)
+	if freq != g.f0 {
+		g.f0 = freq
+		g.initResonanceFilters(sampleRate)
+	}
+	g.buildModes()
+}
+
+// applyParams retunes the group and its damper to params in place.
+func (g *ModalStringGroup) applyParams(sampleRate int, params *Params) {
+	g.damper.retune(sampleRate, params, g.note)
+	g.configure(sampleRate, params)
+	g.updateDamperState()
+}
+
+// reset silences the group and returns its key, pedal, damper and drift
+// state to that of a freshly built group; runtime per-partial scales are
+// dropped.
+func (g *ModalStringGroup) reset() {
+	g.keyDown, g.sustainDown = false, false
+	g.active, g.quietBlocks = false, 0
+	g.touchHarmonic, g.touchFrames = 0, 0
+	g.strikeDetune, g.tuningDrift = g.strikeDetune[:0], 0
+	g.partialLoss, g.partialGain = g.partialLoss[:0], g.partialGain[:0]
+	g.damper.reset()
+	for si := range g.strings {
+		clear(g.strings[si].modes)
+	}
+	for i := range g.resFilters {
+		g.resFilters[i].reset()
+	}
+	g.buildModes()
+}
+
+// buildModes recomputes every string's modes from the group's sources,
+// reusing their storage. A mode whose order survives keeps its phase, so a
+// ringing note carries on.
+func (g *ModalStringGroup) buildModes() {
+	nyquist := 0.5 * g.sampleRate
+	for si, baseF := range g.baseFreqs {
+		// Mode i has order i+1, so each mode is rebuilt over its own old
+		// slot after reading the state it carries over.
+		old := g.strings[si].modes
+		modes := old[:0]
+		for order := 1; order <= g.partials; order++ {
+			partialF := modalPartialFrequency(baseF, float32(order), g.inharmonicity)
 			if partialF >= nyquist*0.95 {
 				break
 			}
-			w := 2.0 * math.Pi * float64(partialF/sr)
-			gain := float32(1.0 / math.Pow(float64(order), float64(gainExp)))
-			m := modalMode{
-				order:         order,
-				cosW:          float32(math.Cos(w)),
-				sinW:          float32(math.Sin(w)),
-				gain:          gain,
-				decayUndamped: modalDecay(lossGain, partialF, order, false, undampedK, highFreqDamping),
-				decayDamped:   modalDecay(lossGain, partialF, order, true, dampedK, highFreqDamping),
+			gain := float32(1.0 / math.Pow(float64(order), float64(g.gainExp)))
+			m := g.newMode(order, partialF, gain)
+			if i := order - 1; i < len(old) {
+				m.re, m.im = old[i].re, old[i].im
 			}
-			m.decay = m.decayDamped
 			modes = append(modes, m)
 		}
 		if len(modes) == 0 {
-			fallbackF := minf(maxf(baseF, 20), nyquist*0.45)
-			w := 2.0 * math.Pi * float64(fallbackF/sr)
-			modes = append(modes, modalMode{
-				order:         1,
-				cosW:          float32(math.Cos(w)),
-				sinW:          float32(math.Sin(w)),
-				gain:          1.0,
-				decayUndamped: modalDecay(lossGain, fallbackF, 1, false, undampedK, highFreqDamping),
-				decayDamped:   modalDecay(lossGain, fallbackF, 1, true, dampedK, highFreqDamping),
-				decay:         modalDecay(lossGain, fallbackF, 1, true, dampedK, highFreqDamping),
-			})
+			m := g.newMode(1, minf(maxf(baseF, 20), nyquist*0.45), 1.0)
+			if len(old) > 0 {
+				m.re, m.im = old[0].re, old[0].im
+			}
+			modes = append(modes, m)
 		}
-		strings = append(strings, modalString{modes: modes})
+		g.strings[si].modes = modes
 	}
+	g.applyDetune()
+	g.applyDamper(g.damper.level.current)
+}
 
-	g := &ModalStringGroup{
-		note:       note,
-		f0:         freq,
-		strings:    strings,
-		gains:      append([]float32(nil), gains...),
-		partials:   maxPartials,
-		gainExp:    gainExp,
-		excitation: excitation,
-		undampedK:  undampedK,
-		dampedK:    dampedK,
-	}
-	g.initResonanceFilters(sampleRate)
-	g.updateDamperState()
-	return g
+func (g *ModalStringGroup) newMode(order int, freq float32, gain float32) modalMode {
+	w := 2.0 * math.Pi * float64(freq/g.sampleRate)
+	loss := g.PartialLoss(order)
+	return modalMode{
+		order:         order,
+		freq:          freq,
+		cosW:          float32(math.Cos(w)),
+		sinW:          float32(math.Sin(w)),
+		gain:          gain * g.PartialGain(order),
+		decayUndamped: modalDecay(g.lossGain, freq, order, false, g.undampedK*loss, g.highFreqDamping),
+		decayDamped:   modalDecay(g.lossGain, freq, order, true, g.dampedK*loss, g.highFreqDamping),
+	}
+}
+
+// Partials returns how many modes per string the group may use; modes at
+// or above 95% of Nyquist are left out.
+func (g *ModalStringGroup) Partials() int {
+	return g.partials
 }
 
+// SetPartials changes the mode count per string (n >= 1) in place.
+func (g *ModalStringGroup) SetPartials(n int) bool {
+	if n < 1 {
+		return false
+	}
+	if n != g.partials {
+		g.partials = n
+		g.buildModes()
+	}
+	return true
+}
+
+// PartialLoss returns the loss multiplier of the given mode order.
+func (g *ModalStringGroup) PartialLoss(order int) float32 {
+	if order >= 1 && order <= len(g.partialLoss) {
+		return g.partialLoss[order-1]
+	}
+	return 1
+}
+
+// SetPartialLoss scales the decay loss of one mode order: 2 makes the mode
+// lose energy twice as fast per sample, 0.5 half as fast. The damper and
+// note-level loss still apply on top.
+func (g *ModalStringGroup) SetPartialLoss(order int, scale float32) bool {
+	if order < 1 || order > g.partials || !(scale > 0) {
+		return false
+	}
+	g.partialLoss = setPartialScale(g.partialLoss, order, scale)
+	g.buildModes()
+	return true
+}
+
+// PartialGain returns the output gain multiplier of the given mode order.
+func (g *ModalStringGroup) PartialGain(order int) float32 {
+	if order >= 1 && order <= len(g.partialGain) {
+		return g.partialGain[order-1]
+	}
+	return 1
+}
+
+// SetPartialGain scales the output level of one mode order on top of the
+// 1/order^ModalGainExponent rolloff; 0 mutes the mode.
+func (g *ModalStringGroup) SetPartialGain(order int, gain float32) bool {
+	if order < 1 || order > g.partials || !(gain >= 0) {
+		return false
+	}
+	g.partialGain = setPartialScale(g.partialGain, order, gain)
+	g.buildModes()
+	return true
+}
+
+func setPartialScale(scales []float32, order int, v float32) []float32 {
+	for len(scales) < order {
+		scales = append(scales, 1)
+	}
+	scales[order-1] = v
+	return scales
+}
+
+// modalInharmonicityScale maps NoteParams.Inharmonicity to the stiffness
+// coefficient B of the modal stretch f_n = n*f0*sqrt(1 + B*n^2).
+const modalInharmonicityScale = 0.12
+
 func modalPartialFrequency(baseF float32, order float32, inharmonicity float32) float32 {
 	if inharmonicity <= 0 {
 		return baseF * order
 	}
-	stretch := float32(math.Sqrt(1.0 + float64(0.12*inharmonicity*order*order)))
+	stretch := float32(math.Sqrt(1.0 + float64(modalInharmonicityScale*inharmonicity*order*order)))
 	return baseF * order * stretch
 }
 
@@ -214,6 +345,11 @@ func (g *ModalStringGroup) setKeyDown(down bool) {
 	}
 }
 
+// setReleaseVelocity sets the MIDI release velocity of the next key-off.
+func (g *ModalStringGroup) setReleaseVelocity(velocity int) {
+	g.damper.setReleaseVelocity(velocity)
+}
+
 func (g *ModalStringGroup) setSustain(down bool) {
 	g.sustainDown = down
 	g.updateDamperState()
@@ -225,20 +361,29 @@ func (g *ModalStringGroup) setSustain(down bool) {
 
 func (g *ModalStringGroup) updateDamperState() {
 	engageDamper := !g.keyDown && !g.sustainDown
+	g.damper.set(engageDamper)
+	g.applyDamper(g.damper.level.current)
+}
+
+// applyDamper blends the mode decays by how firmly the damper rests on the
+// strings; during a damper movement processSample calls it every sample.
+func (g *ModalStringGroup) applyDamper(amount float32) {
 	for si := range g.strings {
 		modes := g.strings[si].modes
 		for mi := range modes {
-			if engageDamper {
+			// A node touch damps every mode without a node at the touch point.
+			touched := g.touchHarmonic > 0 && modes[mi].order%g.touchHarmonic != 0
+			if touched {
 				modes[mi].decay = modes[mi].decayDamped
 			} else {
-				modes[mi].decay = modes[mi].decayUndamped
+				modes[mi].decay = damperMix(modes[mi].decayUndamped, modes[mi].decayDamped, amount)
 			}
 		}
 	}
 }
 
 func (g *ModalStringGroup) isUndamped() bool {
-	return g.keyDown || g.sustainDown
+	return g.keyDown || g.sustainDown || g.damper.strength == 0
 }
 
 func (g *ModalStringGroup) isActive() bool {
@@ -299,7 +444,45 @@ func (g *ModalStringGroup) injectCouplingForce(force float32) {
 	g.injectAtPosition(force, 0.9, 0.45)
 }
 
+func (g *ModalStringGroup) setDetuneDrift(cents []float32) {
+	g.strikeDetune = append(g.strikeDetune[:0], cents...)
+	g.applyDetune()
+}
+
+func (g *ModalStringGroup) setTuningDrift(cents float32) {
+	if d := cents - g.tuningDrift; d < tuningDriftMinStepCents && d > -tuningDriftMinStepCents {
+		return
+	}
+	g.tuningDrift = cents
+	g.applyDetune()
+}
+
+func (g *ModalStringGroup) applyDetune() {
+	if g.sampleRate <= 0 {
+		return
+	}
+	for si := range g.strings {
+		c := g.tuningDrift
+		if si < len(g.strikeDetune) {
+			c += g.strikeDetune[si]
+		}
+		ratio := float32(1.0)
+		if c != 0 {
+			ratio = centsToRatio(c)
+		}
+		modes := g.strings[si].modes
+		for mi := range modes {
+			w := 2.0 * math.Pi * float64(modes[mi].freq*ratio/g.sampleRate)
+			modes[mi].cosW = float32(math.Cos(w))
+			modes[mi].sinW = float32(math.Sin(w))
+		}
+	}
+}
+
 func (g *ModalStringGroup) processSample(unisonCrossfeed float32) float32 {
+	if !g.damper.level.settled() {
+		g.applyDamper(g.damper.level.next())
+	}
 	sample := float32(0)
 	for si := range g.strings {
 		sg := float32(1.0)
@@ -332,8 +515,32 @@ func (g *ModalStringGroup) processSample(unisonCrossfeed float32) float32 {
 	return sample
 }
 
+// setHarmonicTouch damps the modes that have no node at 1/harmonic of the
+// string length for the given number of frames; harmonic 0 lifts the touch.
+func (g *ModalStringGroup) setHarmonicTouch(harmonic int, frames int) {
+	if harmonic < 2 || harmonic > MaxPreparationHarmonic || frames <= 0 {
+		harmonic = 0
+	}
+	g.touchHarmonic = harmonic
+	g.touchFrames = 0
+	if harmonic > 0 {
+		g.touchFrames = frames
+		g.active = true
+		g.quietBlocks = 0
+	}
+	g.updateDamperState()
+}
+
 func (g *ModalStringGroup) endBlock(blockEnergy float64, frames int) bool {
-	if g.isUndamped() {
+	if g.touchFrames > 0 {
+		g.touchFrames -= frames
+		if g.touchFrames <= 0 {
+			g.setHarmonicTouch(0, 0)
+		}
+	}
+	// Held strings stay active; a string without a damper still goes
+	// quiet once it has decayed.
+	if g.keyDown || g.sustainDown {
 		g.active = true
 		g.quietBlocks = 0
 		return true
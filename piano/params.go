@@ -15,12 +15,32 @@ const (
 	StringModelModal StringModel = "modal"
 )
 
+// ConvolutionBackend selects how the body and room convolvers convolve
+// their IRs.
+type ConvolutionBackend string
+
+const (
+	// ConvolutionAuto picks by IR length: direct for short IRs (such as
+	// a few milliseconds of body IR), overlap-add otherwise. "" means auto.
+	ConvolutionAuto ConvolutionBackend = "auto"
+	// ConvolutionOverlapAdd transforms each block padded to the IR length.
+	ConvolutionOverlapAdd ConvolutionBackend = "overlap-add"
+	// ConvolutionDirect is a time-domain FIR, whose cost grows with the IR
+	// length but has no FFT overhead.
+	ConvolutionDirect ConvolutionBackend = "direct"
+)
+
 // Params holds all preset parameters.
 type Params struct {
 	PerNote map[int]*NoteParams
 
 	OutputGain float32
 
+	// Seed drives every stochastic component of the engine (attack noise, and
+	// any IR synthesis done on behalf of this preset). Equal seeds render
+	// bit-identical audio for identical event streams.
+	Seed int64
+
 	// Note range for string-bank allocation and processing (inclusive, MIDI 0..127).
 	MinNote int
 	MaxNote int
@@ -39,9 +59,19 @@ type Params struct {
 	RoomWetMix    float32 // How much room reverb in output
 	RoomGain      float32 // Gain applied to room-convolved signal
 
+	// Optional lid-closed body IR variant. LidPosition crossfades between it
+	// (0) and BodyIRWavPath (1); it has no effect without a closed variant.
+	BodyIRClosedWavPath string
+	LidPosition         float32
+
+	// ConvolutionBackend selects the body and room convolution algorithm.
+	ConvolutionBackend ConvolutionBackend
+
 	ResonanceEnabled       bool
 	ResonanceGain          float32
 	ResonancePerNoteFilter bool
+	ResonanceAttackMs      float32 // Bloom time constant after sustain-pedal down (0 = instant)
+	ResonanceSaturation    float32 // Soft-limit level for per-string resonance drive (0 = off)
 
 	HammerStiffnessScale       float32
 	HammerExponentScale        float32
@@ -55,8 +85,39 @@ type Params struct {
 	// damping terms b1/b2 in the stiff string PDE.
 	HighFreqDamping float32
 
+	// Per-register loop damping. A non-empty HighFreqDampingCurve replaces
+	// HighFreqDamping note by note; LossCurve sets the loop loss of notes
+	// without a NoteParams.Loss override (empty = 0.9998 for every note).
+	HighFreqDampingCurve RegisterCurve
+	LossCurve            RegisterCurve
+
+	// Dampers. DamperReflection is the DWG loop gain of a string with its
+	// damper resting on it (0 = DefaultDamperReflection). DamperEngageMs is
+	// the time constant of the damper falling onto the strings at key-off,
+	// for middle C released at DefaultReleaseVelocity; it halves every
+	// 1/DamperEngageRegisterSlope octaves up the keyboard (tens of ms in the
+	// bass, a few in the treble) and every 64 steps of faster release at
+	// DamperVelocitySensitivity 1. DamperReleaseMs is the time constant of
+	// the damper lifting at key-down or pedal. Either time <= 0 is instant.
+	// DamperCurve sets the damper strength by register, 1 = full damper and
+	// 0 = none (empty = 1 for every note). Real pianos have no dampers on
+	// their top keys, which ring on after release: notes from
+	// DamperlessFromNote up have none regardless of the curve (0 = every
+	// note has a damper).
+	DamperReflection          float32
+	DamperEngageMs            float32
+	DamperEngageRegisterSlope float32
+	DamperVelocitySensitivity float32
+	DamperReleaseMs           float32
+	DamperCurve               RegisterCurve
+	DamperlessFromNote        int
+
 	UnisonDetuneScale float32
 	UnisonCrossfeed   float32
+	// UnisonRegisters sets the strings per note with their detunes and
+	// gains by register (empty = DefaultUnisonRegisters, a grand piano).
+	// Detunes are scaled by UnisonDetuneScale.
+	UnisonRegisters   []UnisonRegister
 	StringModel       StringModel
 	ModalPartials     int
 	ModalGainExponent float32
@@ -85,6 +146,62 @@ type Params struct {
 	AttackNoiseLevel      float32 // Amplitude relative to hammer force (0 = off)
 	AttackNoiseDurationMs float32 // Duration of noise burst in ms (typically 1-5)
 	AttackNoiseColor      float32 // Spectral tilt in dB/octave (0 = white, negative = pink/brown)
+
+	// Hammer knock: the non-harmonic thump of hammer and action heard at the
+	// onset, mixed in after the body IR as noise through an octave filter
+	// bank under a rise/fall envelope. The values are meant to come from a
+	// recording's attack residual (analysis.ExtractAttackResidual).
+	HammerKnockLevel    float32                   // RMS at the envelope peak at velocity 127 (0 = off)
+	HammerKnockAttackMs float32                   // rise from the strike to the peak
+	HammerKnockDecayMs  float32                   // fall from the peak to -60 dB
+	HammerKnockBandsDB  [HammerKnockBands]float32 // band gains at HammerKnockBandHz
+
+	// Live-mode hammer pre-trigger for keyboards that report the start of
+	// the key travel (KeyPress) before the note-on: the hammer is launched
+	// this long ahead of the expected note-on, at a predicted velocity,
+	// hiding that much output latency (0 = off, at most MaxHammerPreTriggerMs).
+	HammerPreTriggerMs float32
+
+	// Humanization: per-strike velocity, strike-position and unison detune
+	// jitter drawn from a seeded stream RNG (0 = off, 1 = maximum variation).
+	VariationAmount float32
+
+	// Tuning drift: slow random walk of every key's tuning, correlated across
+	// neighbouring keys, to simulate an imperfectly tuned instrument.
+	// TuningDriftCents bounds the offsets (0 = off); the walk reverts to
+	// zero over TuningDriftTimeSec and neighbouring keys are correlated over
+	// about TuningDriftCorrelationKeys keys (0 = independent keys).
+	TuningDriftCents           float32
+	TuningDriftTimeSec         float32
+	TuningDriftCorrelationKeys float32
+
+	// Two-microphone output model: CloseMic carries the body dry signal,
+	// RoomMic the room IR, each with its own gain, pan and delay
+	// (Piano.ProcessStemsInto renders them as separate stems).
+	CloseMic MicBus
+	RoomMic  MicBus
+
+	// Soundboard nonlinearity between the strings and the body/room IRs:
+	// louder playing stresses the board, brightening the tone and softly
+	// compressing peaks, which linear convolution misses.
+	// SoundboardNonlinearity is the amount in [0,1] (0 = off);
+	// SoundboardStressLevel the string level at which half of it is engaged
+	// (0 = DefaultSoundboardStressLevel).
+	SoundboardNonlinearity float32
+	SoundboardStressLevel  float32
+
+	// Parametric EQ applied after the body/room convolvers (at most
+	// MaxOutputEQBands bands; empty or all-0 dB = bypass).
+	OutputEQ []EQBand
+
+	// BassMonoHz folds the output to mono below this frequency with a
+	// phase-aligned crossover, so the low end survives mono playback
+	// (0 = off).
+	BassMonoHz float32
+
+	// Glide times of the runtime controls (output gain, IR mix, lid, soft
+	// pedal amount, coupling amount).
+	ControlSmoothing ControlSmoothing
 }
 
 // NoteParams holds parameters for a specific note.
@@ -93,6 +210,20 @@ type NoteParams struct {
 	Inharmonicity  float32
 	Loss           float32
 	StrikePosition float32
+	// Preparations places prepared-piano objects on the strings (DWG only).
+	Preparations []Preparation
+	// UnisonDetunes overrides the note's stringing: one detune in cents per
+	// string, with UnisonGains per string (equal split when the lengths
+	// differ).
+	UnisonDetunes []float32
+	UnisonGains   []float32
+	// Per-note hammer scales multiply the global Params.Hammer*Scale for
+	// this note, so bass and treble hammers can differ (0 = 1).
+	HammerStiffnessScale   float32
+	HammerExponentScale    float32
+	HammerContactTimeScale float32
+	// StringModel overrides Params.StringModel for this note ("" = global).
+	StringModel StringModel
 }
 
 // NewDefaultParams creates default parameters.
@@ -100,8 +231,9 @@ func NewDefaultParams() *Params {
 	return &Params{
 		PerNote:                    make(map[int]*NoteParams),
 		OutputGain:                 1.0,
-		MinNote:                    21,
-		MaxNote:                    108,
+		Seed:                       1,
+		MinNote:                    StandardMinNote,
+		MaxNote:                    StandardMaxNote,
 		IRWavPath:                  "",
 		IRWetMix:                   1.0,
 		IRDryMix:                   0.0,
@@ -110,6 +242,8 @@ func NewDefaultParams() *Params {
 		BodyDryMix:                 1.0,
 		RoomWetMix:                 0.0,
 		RoomGain:                   1.0,
+		LidPosition:                1.0,
+		ConvolutionBackend:         ConvolutionAuto,
 		ResonanceEnabled:           false,
 		ResonanceGain:              0.00018,
 		ResonancePerNoteFilter:     true,
@@ -119,6 +253,12 @@ func NewDefaultParams() *Params {
 		HammerInitialVelocityScale: 1.0,
 		HammerContactTimeScale:     1.0,
 		HighFreqDamping:            0.05,
+		DamperReflection:           DefaultDamperReflection,
+		DamperEngageMs:             8,
+		DamperEngageRegisterSlope:  0.6,
+		DamperVelocitySensitivity:  1,
+		SoundboardStressLevel:      DefaultSoundboardStressLevel,
+		DamperlessFromNote:         DefaultDamperlessFromNote,
 		UnisonDetuneScale:          1.0,
 		UnisonCrossfeed:            0.0008,
 		StringModel:                StringModelDWG,
@@ -142,5 +282,13 @@ func NewDefaultParams() *Params {
 		AttackNoiseLevel:           0.0,
 		AttackNoiseDurationMs:      2.5,
 		AttackNoiseColor:           -3.0,
+		HammerKnockLevel:           0.0,
+		HammerKnockAttackMs:        1.0,
+		HammerKnockDecayMs:         60.0,
+		VariationAmount:            0.0,
+		TuningDriftCents:           0.0,
+		TuningDriftTimeSec:         defaultTuningDriftTimeSec,
+		TuningDriftCorrelationKeys: defaultTuningDriftCorrelationKeys,
+		ControlSmoothing:           DefaultControlSmoothing(),
 	}
 }
@@ -143,3 +143,34 @@ func TestIRDryWetMixCanBypassConvolverOutput(t *testing.T) {
 		t.Fatalf("expected audible body signal with body-only mix, got %f", stereoRMS(dryOut))
 	}
 }
+
+func TestActiveVoicesFollowsReleaseAndPedal(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	if n := p.ActiveVoices(); n != 0 {
+		t.Fatalf("expected no active voices before NoteOn, got %d", n)
+	}
+	p.SetSustainPedal(true)
+	p.NoteOn(60, 100)
+	p.NoteOn(64, 100)
+	if n := p.ActiveVoices(); n != 2 {
+		t.Fatalf("expected pending strikes to count as active, got %d", n)
+	}
+	_ = p.Process(4800)
+	p.NoteOff(60)
+	p.NoteOff(64)
+	for i := 0; i < 100; i++ {
+		_ = p.Process(256)
+	}
+	// Coupling may wake further undamped strings while the pedal is down.
+	if n := p.ActiveVoices(); n < 2 {
+		t.Fatalf("expected sustained notes to stay active, got %d", n)
+	}
+
+	p.SetSustainPedal(false)
+	for i := 0; i < 2000 && p.ActiveVoices() > 0; i++ {
+		_ = p.Process(256)
+	}
+	if n := p.ActiveVoices(); n != 0 {
+		t.Fatalf("expected voices to go inactive after damping, got %d", n)
+	}
+}
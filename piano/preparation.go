@@ -0,0 +1,146 @@
+package piano
+
+type PreparationType string
+
+const (
+	// PreparationRubber is a rubber wedge between the strings: extra loop
+	// loss and lowpass for a short, dull thud.
+	PreparationRubber PreparationType = "rubber"
+	// PreparationPaper is paper resting on the strings: large displacements
+	// are clipped, adding a buzzing rattle to loud strikes.
+	PreparationPaper PreparationType = "paper"
+	// PreparationNode is a light touch at 1/Harmonic of the string length:
+	// only partials with a node there keep ringing (flageolet).
+	PreparationNode PreparationType = "node"
+)
+
+// MaxPreparationHarmonic is the highest harmonic accepted for node damping.
+const MaxPreparationHarmonic = 16
+
+const (
+	defaultPaperThreshold = float32(1.0)
+	paperRattleDecay      = float32(0.995)
+	paperRattleGain       = float32(1.0)
+)
+
+// harmonicTouchSeconds is how long the finger rests on the node after a
+// NoteOptions.HarmonicNode strike before it is lifted.
+const harmonicTouchSeconds = 0.08
+
+// Preparation is one object placed on the strings of a note. Preparations
+// apply to the DWG string model only.
+type Preparation struct {
+	Type PreparationType
+	// Amount in [0,1] sets the strength of the effect.
+	Amount float32
+	// Harmonic selects the kept partial series for PreparationNode (>= 2).
+	Harmonic int
+	// Threshold is the string displacement at which paper starts to buzz
+	// (PreparationPaper; <= 0 selects the default).
+	Threshold float32
+}
+
+// stringPreparation is the per-waveguide state of the preparations of one
+// note, evaluated on the delay-line output every sample.
+type stringPreparation struct {
+	rubberCoeff float32 // one-pole lowpass coefficient, 0 = off
+	rubberGain  float32
+	rubberZ     float32
+
+	paperAmount    float32
+	paperThreshold float32
+	paperRattle    float32 // envelope of the loose paper's rattle
+	paperRNG       uint32
+
+	nodeMix   float32
+	nodeRatio float32 // tap delay relative to the loop delay
+}
+
+func newStringPreparation(preps []Preparation) *stringPreparation {
+	if len(preps) == 0 {
+		return nil
+	}
+	sp := &stringPreparation{rubberGain: 1}
+	active := false
+	for _, p := range preps {
+		amount := clampf(p.Amount, 0, 1)
+		if amount == 0 {
+			continue
+		}
+		switch p.Type {
+		case PreparationRubber:
+			sp.rubberCoeff = 0.7 * amount
+			sp.rubberGain = 1 - 0.02*amount
+			active = true
+		case PreparationPaper:
+			sp.paperAmount = amount
+			sp.paperRNG = 0x9e3779b9
+			sp.paperThreshold = defaultPaperThreshold
+			if p.Threshold > 0 {
+				sp.paperThreshold = p.Threshold
+			}
+			active = true
+		case PreparationNode:
+			if p.Harmonic < 2 || p.Harmonic > MaxPreparationHarmonic {
+				continue
+			}
+			// Mixing in a tap at (n-1)/n of the loop delay keeps unit loop
+			// gain for multiples of the n-th harmonic and attenuates the rest.
+			sp.nodeMix = 0.5 * amount
+			sp.nodeRatio = float32(p.Harmonic-1) / float32(p.Harmonic)
+			active = true
+		}
+	}
+	if !active {
+		return nil
+	}
+	return sp
+}
+
+// reset clears the filter and rattle state of the treatments.
+func (sp *stringPreparation) reset() {
+	sp.rubberZ = 0
+	sp.paperRattle = 0
+	if sp.paperAmount > 0 {
+		sp.paperRNG = 0x9e3779b9
+	}
+}
+
+// setNode configures only the node tap, as used for a harmonic touch;
+// harmonic outside [2, MaxPreparationHarmonic] clears it.
+func (sp *stringPreparation) setNode(harmonic int) {
+	if harmonic < 2 || harmonic > MaxPreparationHarmonic {
+		sp.nodeMix = 0
+		return
+	}
+	sp.nodeMix = 0.5
+	sp.nodeRatio = float32(harmonic-1) / float32(harmonic)
+}
+
+func (sp *stringPreparation) process(s *StringWaveguide, x float32) float32 {
+	if sp.nodeMix > 0 {
+		tap := s.readDelayFractional(maxf(s.delayLength*sp.nodeRatio, 1))
+		x = (1-sp.nodeMix)*x + sp.nodeMix*tap
+	}
+	if sp.paperAmount > 0 {
+		// Displacement beyond the threshold hits the paper: the excess is
+		// taken out of the wave and returned as a short noisy rattle.
+		over := x - clampf(x, -sp.paperThreshold, sp.paperThreshold)
+		if over < 0 {
+			over = -over
+			x += sp.paperAmount * over
+		} else {
+			x -= sp.paperAmount * over
+		}
+		sp.paperRattle = paperRattleDecay*sp.paperRattle + (1-paperRattleDecay)*sp.paperAmount*over
+		if sp.paperRattle > 1e-9 {
+			white := float32(xorshift32(&sp.paperRNG))*2.3283064e-10*2.0 - 1.0
+			x += paperRattleGain * sp.paperRattle * white
+		}
+	}
+	if sp.rubberCoeff > 0 {
+		sp.rubberZ = (1-sp.rubberCoeff)*x + sp.rubberCoeff*sp.rubberZ
+		x = sp.rubberZ * sp.rubberGain
+	}
+	return x
+}
@@ -0,0 +1,146 @@
+package piano
+
+import "testing"
+
+func TestNodePreparationKeepsHarmonicSeries(t *testing.T) {
+	const sr = 48000
+	render := func(preps []Preparation) (fund, second float64) {
+		s := NewStringWaveguide(sr, 220)
+		s.SetLoopLoss(0.9995, 0.05)
+		s.setPreparations(preps)
+		s.ExciteAtPosition(1.0, 0.13)
+		for i := 0; i < sr/4; i++ {
+			s.Process()
+		}
+		// 4800 samples = 10 Hz bins: 220 Hz -> bin 22, 440 Hz -> bin 44.
+		buf := make([]float32, 4800)
+		for i := range buf {
+			buf[i] = s.Process()
+		}
+		return dftBinMagnitude(buf, 22), dftBinMagnitude(buf, 44)
+	}
+
+	f0, h2 := render(nil)
+	pf0, ph2 := render([]Preparation{{Type: PreparationNode, Amount: 1, Harmonic: 2}})
+	if pf0 >= 0.1*f0 {
+		t.Fatalf("expected node damping to suppress the fundamental: open=%e prepared=%e", f0, pf0)
+	}
+	if ph2 < 0.5*h2 {
+		t.Fatalf("expected the 2nd harmonic to keep ringing: open=%e prepared=%e", h2, ph2)
+	}
+}
+
+func TestRubberPreparationShortensDecay(t *testing.T) {
+	open := NewPiano(48000, 16, NewDefaultParams())
+	params := NewDefaultParams()
+	params.PerNote[60] = &NoteParams{Preparations: []Preparation{{Type: PreparationRubber, Amount: 1}}}
+	muted := NewPiano(48000, 16, params)
+
+	open.NoteOn(60, 100)
+	muted.NoteOn(60, 100)
+	_ = open.Process(4800)
+	_ = muted.Process(4800)
+	var openTail, mutedTail []float32
+	for i := 0; i < 40; i++ {
+		openTail = open.Process(256)
+		mutedTail = muted.Process(256)
+	}
+	if stereoRMS(mutedTail) >= 0.3*stereoRMS(openTail) {
+		t.Fatalf("expected rubber mute to shorten the held note: open=%f muted=%f", stereoRMS(openTail), stereoRMS(mutedTail))
+	}
+}
+
+func TestPaperPreparationBrightensLoudStrike(t *testing.T) {
+	render := func(preps []Preparation) []float32 {
+		params := NewDefaultParams()
+		params.PerNote[48] = &NoteParams{Preparations: preps}
+		p := NewPiano(48000, 16, params)
+		p.NoteOn(48, 127)
+		out := p.Process(4096)
+		mono := make([]float32, len(out)/2)
+		for i := range mono {
+			mono[i] = out[i*2]
+			if !isFinite(mono[i]) {
+				t.Fatalf("non-finite output")
+			}
+		}
+		return mono
+	}
+	open := spectralCentroid(render(nil), 48000, 4096)
+	buzz := spectralCentroid(render([]Preparation{{Type: PreparationPaper, Amount: 1, Threshold: 0.2}}), 48000, 4096)
+	if buzz <= open*1.3 {
+		t.Fatalf("expected paper buzz to add upper partials: open=%f paper=%f", open, buzz)
+	}
+}
+
+func TestPaperPreparationStaysBounded(t *testing.T) {
+	tail := func(preps []Preparation) float64 {
+		params := NewDefaultParams()
+		params.PerNote[48] = &NoteParams{Preparations: preps}
+		p := NewPiano(48000, 16, params)
+		p.SetSustainPedal(true)
+		p.NoteOn(48, 127)
+		var out []float32
+		for i := 0; i < 100; i++ {
+			out = p.Process(960)
+		}
+		rms := stereoRMS(out)
+		if !isFinite(float32(rms)) {
+			t.Fatalf("non-finite output")
+		}
+		return rms
+	}
+	open := tail(nil)
+	for _, th := range []float32{0.05, 0.2, 1} {
+		if got := tail([]Preparation{{Type: PreparationPaper, Amount: 1, Threshold: th}}); got > open {
+			t.Fatalf("threshold %.2f: paper rattle must not add energy: open=%f paper=%f", th, open, got)
+		}
+	}
+}
+
+func TestZeroAmountPreparationIsBypassed(t *testing.T) {
+	if sp := newStringPreparation([]Preparation{{Type: PreparationRubber}, {Type: PreparationNode, Amount: 1, Harmonic: 1}}); sp != nil {
+		t.Fatalf("expected inert preparations to be dropped, got %+v", sp)
+	}
+}
+
+func TestHarmonicNodeStrikeSoundsOctave(t *testing.T) {
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		render := func(opts NoteOptions) (fund, octave float64) {
+			params := NewDefaultParams()
+			params.StringModel = model
+			p := NewPiano(48000, 16, params)
+			p.NoteOnEx(57, 100, opts)
+			_ = p.Process(14400)
+			out := p.Process(4800)
+			mono := make([]float32, len(out)/2)
+			for i := range mono {
+				mono[i] = out[i*2]
+			}
+			// 4800 samples = 10 Hz bins: 220 Hz -> bin 22, 440 Hz -> bin 44.
+			return dftBinMagnitude(mono, 22), dftBinMagnitude(mono, 44)
+		}
+
+		f0, h2 := render(NoteOptions{})
+		tf0, th2 := render(NoteOptions{HarmonicNode: 2})
+		if th2/tf0 < 4*h2/f0 {
+			t.Fatalf("%s: expected the touch to favour the octave: open=%.3f touched=%.3f", model, h2/f0, th2/tf0)
+		}
+		if th2 < 0.1*h2 {
+			t.Fatalf("%s: expected the octave to keep ringing: open=%e touched=%e", model, h2, th2)
+		}
+	}
+}
+
+func TestHarmonicTouchIsLiftedAfterStrike(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	p.NoteOnEx(57, 100, NoteOptions{HarmonicNode: 3})
+	g := p.ringing.bank.groups[57]
+	if g.touchFrames <= 0 || g.strings[0].touch.nodeMix == 0 {
+		t.Fatalf("expected the node touch to be applied on strike")
+	}
+	_ = p.Process(48000 / 4)
+	if g.touchFrames > 0 || g.strings[0].touch.nodeMix != 0 {
+		t.Fatalf("expected the node touch to be lifted, %d frames left", g.touchFrames)
+	}
+}
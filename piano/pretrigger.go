@@ -0,0 +1,194 @@
+package piano
+
+import "math"
+
+// MaxHammerPreTriggerMs bounds Params.HammerPreTriggerMs at about the
+// contact time of a mezzo-forte strike: a hammer launched earlier would
+// have left the string before the note-on could correct its velocity.
+const MaxHammerPreTriggerMs = 3
+
+const (
+	// defaultKeyTravelMs is the KeyPress to note-on time assumed until the
+	// first note-on is measured.
+	defaultKeyTravelMs = 20
+	// maxKeyTravelMs ignores longer measurements (a key held half way down).
+	maxKeyTravelMs = 250
+	// keyTravelSmoothing is the weight of a new measurement in the travel
+	// estimate.
+	keyTravelSmoothing = 0.25
+	// defaultPredictedVelocity strikes keys no note-on velocity is known for.
+	defaultPredictedVelocity = 64
+)
+
+// preTrigger launches provisional hammer strikes ahead of the note-on of
+// keys reported with KeyPress (Params.HammerPreTriggerMs). It learns the
+// key travel time from KeyPress to note-on and predicts the velocity from
+// the key's previous note-on.
+type preTrigger struct {
+	lead   float64 // frames the hammer leaves before the expected note-on
+	travel float64 // smoothed KeyPress to note-on time, frames
+	maxLen float64 // longest travel measurement taken, frames
+
+	pressed  [128]int64 // frame of the unconfirmed key press, -1 = none
+	launched [128]bool  // the provisional strike of the press has started
+	velocity [128]int   // last note-on velocity per key (0 = none yet)
+	last     int        // last note-on velocity of any key
+}
+
+// newPreTrigger returns the pre-trigger for a lead of ms milliseconds, or
+// nil when ms <= 0 turns it off.
+func newPreTrigger(sampleRate int, ms float32) *preTrigger {
+	if ms <= 0 {
+		return nil
+	}
+	ms = min(ms, MaxHammerPreTriggerMs)
+	perMs := float64(sampleRate) / 1000
+	t := &preTrigger{
+		lead:   float64(ms) * perMs,
+		travel: defaultKeyTravelMs * perMs,
+		maxLen: maxKeyTravelMs * perMs,
+	}
+	for note := range t.pressed {
+		t.pressed[note] = -1
+	}
+	return t
+}
+
+// press starts the travel of note at frame and returns the frame its
+// provisional strike is due on.
+func (t *preTrigger) press(note int, frame int64) int64 {
+	t.pressed[note] = frame
+	t.launched[note] = false
+	return frame + int64(max(math.Round(t.travel-t.lead), 0))
+}
+
+// launch marks the provisional strike of note as started and returns the
+// velocity to strike at; ok is false once the press was confirmed or
+// released.
+func (t *preTrigger) launch(note int) (velocity int, ok bool) {
+	if t == nil || t.pressed[note] < 0 || t.launched[note] {
+		return 0, false
+	}
+	t.launched[note] = true
+	switch {
+	case t.velocity[note] > 0:
+		return t.velocity[note], true
+	case t.last > 0:
+		return t.last, true
+	}
+	return defaultPredictedVelocity, true
+}
+
+// confirm ends the press of note with its note-on at frame and velocity,
+// learning the travel time, and reports whether a provisional strike was
+// launched for it.
+func (t *preTrigger) confirm(note int, frame int64, velocity int) (launched bool) {
+	if t == nil {
+		return false
+	}
+	if velocity > 0 {
+		t.velocity[note] = velocity
+		t.last = velocity
+	}
+	start := t.pressed[note]
+	if start < 0 {
+		return false
+	}
+	if d := float64(frame - start); d <= t.maxLen {
+		t.travel += keyTravelSmoothing * (d - t.travel)
+	}
+	launched = t.launched[note]
+	t.release(note)
+	return launched
+}
+
+// release forgets the press of note.
+func (t *preTrigger) release(note int) {
+	if t == nil {
+		return
+	}
+	t.pressed[note] = -1
+	t.launched[note] = false
+}
+
+// KeyPress reports that the key of note started moving, for keyboards that
+// send this before the note-on (two-contact actions, MIDI 2.0 or
+// continuous-sensing keyboards). The damper lifts; with
+// Params.HammerPreTriggerMs > 0 the hammer is also launched that long
+// before the expected note-on, at the key's previous velocity, and the
+// note-on then corrects it to the played velocity instead of striking
+// again. A note-on arriving after that strike has ended strikes as usual.
+// The expected note-on is learned from the keys played so far. A key
+// released without a note-on drops its pending strike, but one already
+// launched keeps sounding.
+func (p *Piano) KeyPress(note int) {
+	p.record(Event{Kind: EventKeyPress, Note: note})
+	if note < 0 || note > 127 {
+		return
+	}
+	p.ringing.SetKeyDown(note, true)
+	if p.preTrigger == nil {
+		return
+	}
+	p.dropPreStrike(note)
+	due := p.preTrigger.press(note, p.eventFrame())
+	p.scheduleAt(due, Event{Kind: eventPreStrike, Note: note})
+}
+
+// SetHammerPreTrigger sets Params.HammerPreTriggerMs; 0 turns the
+// pre-trigger off. Keys pressed but not yet played fall back to a normal
+// strike and the learned key travel restarts.
+func (p *Piano) SetHammerPreTrigger(ms float32) {
+	if p.params == nil {
+		p.params = NewDefaultParams()
+	}
+	p.params.HammerPreTriggerMs = max(ms, 0)
+	for note := range 128 {
+		p.releaseKeyPress(note)
+	}
+	p.preTrigger = newPreTrigger(p.sampleRate, p.params.HammerPreTriggerMs)
+}
+
+// launchPreStrike starts the provisional strike of a pressed key.
+func (p *Piano) launchPreStrike(note int) {
+	velocity, ok := p.preTrigger.launch(note)
+	if !ok {
+		return
+	}
+	if s := p.hammerExciter.trigger(note, velocity, 0, NoteOptions{}); s != nil {
+		s.provisional = true
+	}
+	p.knock.strike(p.eventPos, note, velocity)
+}
+
+// confirmKeyPress ends a KeyPress of note with its note-on and reports
+// whether a provisional strike was launched for it.
+func (p *Piano) confirmKeyPress(note int, velocity int) bool {
+	if p.preTrigger == nil || note < 0 || note > 127 {
+		return false
+	}
+	p.dropPreStrike(note)
+	return p.preTrigger.confirm(note, p.eventFrame(), velocity)
+}
+
+// releaseKeyPress drops a KeyPress of note released without a note-on.
+func (p *Piano) releaseKeyPress(note int) {
+	if p.preTrigger == nil || note < 0 || note > 127 {
+		return
+	}
+	p.dropPreStrike(note)
+	p.preTrigger.release(note)
+	p.hammerExciter.settle(note)
+}
+
+// dropPreStrike removes the pending provisional strike of note from the
+// event schedule.
+func (p *Piano) dropPreStrike(note int) {
+	keep := p.scheduled[:0]
+	for _, s := range p.scheduled {
+		if s.event.Kind != eventPreStrike || s.event.Note != note {
+			keep = append(keep, s)
+		}
+	}
+	p.scheduled = keep
+}
@@ -0,0 +1,156 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+// renderPreTriggered renders middle C struck at noteOnFrame, optionally
+// after a KeyPress at frame 0.
+func renderPreTriggered(preTriggerMs float32, keyPress bool, noteOnFrame int, velocity int) []float32 {
+	params := NewDefaultParams()
+	params.HammerPreTriggerMs = preTriggerMs
+	p := NewPiano(48000, 16, params)
+	if keyPress {
+		p.ScheduleEvent(0, Event{Kind: EventKeyPress, Note: 60})
+	}
+	p.ScheduleEvent(noteOnFrame, Event{Kind: EventNoteOn, Note: 60, Velocity: velocity})
+	out := make([]float32, 0, 40*internalBlockSize*2)
+	for range 40 {
+		out = append(out, p.Process(internalBlockSize)...)
+	}
+	return out
+}
+
+func TestKeyPressLaunchesHammerAheadOfNoteOn(t *testing.T) {
+	// Default travel 20 ms, lead 2 ms: the hammer leaves 18 ms after the
+	// key press, 96 frames before the note-on.
+	const noteOn, launch = 960, 864
+	got := renderPreTriggered(2, true, noteOn, defaultPredictedVelocity)
+	want := renderPreTriggered(0, true, launch, defaultPredictedVelocity)
+	for i := 0; i < 2*launch; i++ {
+		if math.Abs(float64(got[i])) > 1e-6 {
+			t.Fatalf("sample %d sounds before the pre-trigger frame %d", i/2, launch)
+		}
+	}
+	if d := maxAbsDiff(got, want); d > 1e-4 {
+		t.Fatalf("pre-triggered strike differs from a strike at frame %d by %g", launch, d)
+	}
+}
+
+func TestConfirmCorrectsProvisionalStrikeVelocity(t *testing.T) {
+	params := NewDefaultParams()
+	h := NewHammerExciter(48000, params)
+	s := h.trigger(60, 64, 0, NoteOptions{})
+	s.provisional = true
+	const ran = 20
+	for range ran {
+		s.hammer.Step(0)
+	}
+	uncorrected := s.hammer
+	softPeak, _ := hammerContactProfile(&uncorrected)
+	softMax := s.hammer.contactMaxSamples
+	if !h.confirm(60, 120) {
+		t.Fatal("no provisional strike to confirm")
+	}
+	if h.confirm(60, 120) {
+		t.Fatal("a strike was confirmed twice")
+	}
+	gotPeak, _ := hammerContactProfile(&s.hammer)
+
+	// A strike played at 120, advanced to the same fraction of its contact.
+	ref := NewHammerExciter(48000, params).trigger(60, 120, 0, NoteOptions{})
+	for range int(float64(ran) / float64(softMax) * float64(ref.hammer.contactMaxSamples)) {
+		ref.hammer.Step(0)
+	}
+	wantPeak, _ := hammerContactProfile(&ref.hammer)
+	if gotPeak != wantPeak {
+		t.Fatalf("corrected contact peaks at %g, a strike played at 120 at %g", gotPeak, wantPeak)
+	}
+	if gotPeak <= softPeak {
+		t.Fatalf("correction to 120 did not raise the remaining force: %g vs %g", gotPeak, softPeak)
+	}
+}
+
+func TestLateNoteOnAfterProvisionalStrikeStrikesAgain(t *testing.T) {
+	// A key press whose provisional strike has launched and ended.
+	pressed := func() *Piano {
+		params := NewDefaultParams()
+		params.HammerPreTriggerMs = 2
+		p := NewPiano(48000, 16, params)
+		p.KeyPress(60)
+		launched := false
+		for range 400 {
+			p.Process(internalBlockSize)
+			if p.hammerExciter.isStriking(60) {
+				launched = true
+			} else if launched {
+				return p
+			}
+		}
+		t.Fatal("expected the provisional strike to launch and end")
+		return nil
+	}
+
+	late, held := pressed(), pressed()
+	late.NoteOn(60, 120)
+	if !late.hammerExciter.isStriking(60) {
+		t.Fatal("a note-on after the provisional strike ended did not strike")
+	}
+	got := late.Process(4 * internalBlockSize)
+	ringing := held.Process(4 * internalBlockSize)
+	if d, rms := windowRMS(diffF32(got, ringing)), windowRMS(ringing); d < 0.1*rms {
+		t.Fatalf("late note-on changed the render by %g RMS against %g; want a new strike", d, rms)
+	}
+}
+
+func TestKeyReleasedBeforeLaunchDoesNotStrike(t *testing.T) {
+	params := NewDefaultParams()
+	params.HammerPreTriggerMs = 2
+	p := NewPiano(48000, 16, params)
+	p.KeyPress(60)
+	p.ScheduleEvent(200, Event{Kind: EventNoteOff, Note: 60})
+	for range 40 {
+		for i, v := range p.Process(internalBlockSize) {
+			if math.Abs(float64(v)) > 1e-6 {
+				t.Fatalf("sample %d sounds after a key press released without note-on", i/2)
+			}
+		}
+	}
+}
+
+func TestPreTriggerLearnsKeyTravel(t *testing.T) {
+	pt := newPreTrigger(48000, 2)
+	if due := pt.press(60, 1000); due != 1000+960-96 {
+		t.Fatalf("first press due at %d, want the default travel less the lead", due)
+	}
+	if launched := pt.confirm(60, 1480, 100); launched {
+		t.Fatal("confirm reports a launch that did not happen")
+	}
+	// One 480-frame measurement moves the 960-frame estimate a quarter of
+	// the way.
+	if want := 960 - 0.25*480; math.Abs(pt.travel-want) > 1e-9 {
+		t.Fatalf("travel %g, want %g", pt.travel, want)
+	}
+	pt.press(62, 0)
+	if v, ok := pt.launch(62); !ok || v != 100 {
+		t.Fatalf("key without a velocity of its own predicted %d (ok %v), want the last played 100", v, ok)
+	}
+	if _, ok := pt.launch(62); ok {
+		t.Fatal("a press launched twice")
+	}
+	if newPreTrigger(48000, 0) != nil {
+		t.Fatal("0 ms does not turn the pre-trigger off")
+	}
+	if _, ok := ParseEventKind("key_press"); !ok {
+		t.Fatal("key_press is not a capture event name")
+	}
+}
+
+func diffF32(a []float32, b []float32) []float32 {
+	out := make([]float32, len(a))
+	for i := range out {
+		out[i] = a[i] - b[i]
+	}
+	return out
+}
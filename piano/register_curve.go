@@ -0,0 +1,86 @@
+package piano
+
+// DefaultLoopLoss is the loop loss of notes without a per-note or register
+// value.
+const DefaultLoopLoss = float32(0.9998)
+
+// defaultHighFreqDamping applies when neither a curve nor the global
+// HighFreqDamping is set.
+const defaultHighFreqDamping = float32(0.05)
+
+// RegisterPoint is one breakpoint of a RegisterCurve: Value at MIDI note
+// Note.
+type RegisterPoint struct {
+	Note  int
+	Value float32
+}
+
+// RegisterCurve describes a parameter across the keyboard as breakpoints
+// sorted by note, linearly interpolated between them and held at the end
+// values outside. Real pianos need far more loop damping in the treble
+// than in the bass, which a single global value cannot express.
+type RegisterCurve []RegisterPoint
+
+// RegisterCurveNotes are the breakpoints tools seed register curves with:
+// the ends of the standard keyboard and every C in between.
+var RegisterCurveNotes = []int{21, 36, 48, 60, 72, 84, 96, 108}
+
+// FlatRegisterCurve returns a curve holding value at every
+// RegisterCurveNotes breakpoint, a starting point that sounds like the
+// global value.
+func FlatRegisterCurve(value float32) RegisterCurve {
+	c := make(RegisterCurve, len(RegisterCurveNotes))
+	for i, n := range RegisterCurveNotes {
+		c[i] = RegisterPoint{Note: n, Value: value}
+	}
+	return c
+}
+
+// At returns the curve's value at note; ok is false for an empty curve.
+func (c RegisterCurve) At(note int) (value float32, ok bool) {
+	if len(c) == 0 {
+		return 0, false
+	}
+	if note <= c[0].Note {
+		return c[0].Value, true
+	}
+	for i := 1; i < len(c); i++ {
+		if note <= c[i].Note {
+			a, b := c[i-1], c[i]
+			t := float32(note-a.Note) / float32(b.Note-a.Note)
+			return a.Value + t*(b.Value-a.Value), true
+		}
+	}
+	return c[len(c)-1].Value, true
+}
+
+// noteLoopLoss resolves the loop loss of note: the per-note override, else
+// Params.LossCurve, else the default.
+func noteLoopLoss(params *Params, note int) float32 {
+	if params == nil {
+		return DefaultLoopLoss
+	}
+	if np, ok := params.PerNote[note]; ok && np != nil && np.Loss > 0 && np.Loss <= 1 {
+		return np.Loss
+	}
+	if v, ok := params.LossCurve.At(note); ok && v > 0 && v <= 1 {
+		return v
+	}
+	return DefaultLoopLoss
+}
+
+// noteHighFreqDamping resolves the high-frequency damping of note:
+// Params.HighFreqDampingCurve, else the global Params.HighFreqDamping,
+// else the default.
+func noteHighFreqDamping(params *Params, note int) float32 {
+	if params == nil {
+		return defaultHighFreqDamping
+	}
+	if v, ok := params.HighFreqDampingCurve.At(note); ok && v > 0 {
+		return v
+	}
+	if params.HighFreqDamping > 0 {
+		return params.HighFreqDamping
+	}
+	return defaultHighFreqDamping
+}
@@ -0,0 +1,69 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRegisterCurveInterpolatesAndHoldsEnds(t *testing.T) {
+	c := RegisterCurve{{Note: 36, Value: 0.1}, {Note: 60, Value: 0.2}, {Note: 84, Value: 0.6}}
+	cases := []struct {
+		note int
+		want float32
+	}{{21, 0.1}, {36, 0.1}, {48, 0.15}, {60, 0.2}, {72, 0.4}, {84, 0.6}, {108, 0.6}}
+	for _, tc := range cases {
+		got, ok := c.At(tc.note)
+		if !ok || math.Abs(float64(got-tc.want)) > 1e-6 {
+			t.Fatalf("At(%d) = %v, %v; want %v", tc.note, got, ok, tc.want)
+		}
+	}
+	if _, ok := RegisterCurve(nil).At(60); ok {
+		t.Fatal("empty curve reported a value")
+	}
+}
+
+func TestRegisterCurvesResolvePerNoteLoss(t *testing.T) {
+	params := NewDefaultParams()
+	params.HighFreqDamping = 0.1
+	if got := noteHighFreqDamping(params, 60); got != 0.1 {
+		t.Fatalf("without a curve HFD = %v, want the global 0.1", got)
+	}
+	params.HighFreqDampingCurve = RegisterCurve{{Note: 48, Value: 0.02}, {Note: 72, Value: 0.3}}
+	if got := noteHighFreqDamping(params, 72); got != 0.3 {
+		t.Fatalf("curve HFD = %v, want 0.3", got)
+	}
+
+	if got := noteLoopLoss(params, 60); got != DefaultLoopLoss {
+		t.Fatalf("without a curve loss = %v, want default", got)
+	}
+	params.LossCurve = RegisterCurve{{Note: 60, Value: 0.999}}
+	if got := noteLoopLoss(params, 60); got != 0.999 {
+		t.Fatalf("curve loss = %v, want 0.999", got)
+	}
+	params.PerNote[60] = &NoteParams{Loss: 0.9995}
+	if got := noteLoopLoss(params, 60); got != 0.9995 {
+		t.Fatalf("per-note loss = %v, want the override 0.9995", got)
+	}
+}
+
+func TestLossCurveShortensTrebleOnly(t *testing.T) {
+	const sr = 48000
+	render := func(params *Params, note int) float64 {
+		p := NewPiano(sr, 16, params)
+		p.NoteOn(note, 100)
+		_ = p.Process(sr)
+		return stereoRMS(p.Process(sr / 2))
+	}
+	curved := NewDefaultParams()
+	curved.LossCurve = RegisterCurve{{Note: 60, Value: DefaultLoopLoss}, {Note: 84, Value: 0.999}}
+	curved.HighFreqDampingCurve = RegisterCurve{{Note: 60, Value: defaultHighFreqDamping}, {Note: 84, Value: 0.3}}
+
+	flat, treble := render(NewDefaultParams(), 84), render(curved, 84)
+	if !(treble < 0.5*flat) {
+		t.Fatalf("treble tail RMS %g with the curves, want well below %g", treble, flat)
+	}
+	// Only coupling to the damped treble strings may reach a bass note.
+	if a, b := render(NewDefaultParams(), 48), render(curved, 48); math.Abs(a-b) > 1e-3*a {
+		t.Fatalf("curves held at the defaults changed a bass note: %g vs %g", b, a)
+	}
+}
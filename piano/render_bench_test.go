@@ -0,0 +1,102 @@
+package piano
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// reportRealtime reports how many times faster than realtime the benchmark
+// rendered frames frames per iteration.
+func reportRealtime(b *testing.B, sampleRate int, frames int) {
+	if s := b.Elapsed().Seconds(); s > 0 {
+		b.ReportMetric(float64(b.N*frames)/float64(sampleRate)/s, "x-realtime")
+	}
+}
+
+// BenchmarkPianoProcess renders the full engine (strings, hammer, coupling,
+// resonance, body and room convolution, mix) with 1, 8 and 32 held notes.
+func BenchmarkPianoProcess(b *testing.B) {
+	for _, voices := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("voices=%d", voices), func(b *testing.B) {
+			params := NewDefaultParams()
+			params.ResonanceEnabled = true
+			p := NewPiano(48000, voices, params)
+			for i := range voices {
+				// Spread the notes over the keyboard, two semitones apart.
+				p.NoteOn(36+2*i, 100)
+			}
+			buf := make([]float32, 2*internalBlockSize)
+			b.ReportAllocs()
+			for b.Loop() {
+				p.ProcessInto(buf)
+			}
+			reportRealtime(b, 48000, internalBlockSize)
+		})
+	}
+}
+
+// BenchmarkConvolver convolves one block with IRs of 0.05, 0.5 and 2
+// seconds, through the stereo soundboard/room convolver and the mono body
+// convolver.
+func BenchmarkConvolver(b *testing.B) {
+	const sampleRate = 48000
+	in := make([]float32, internalBlockSize)
+	for i := range in {
+		in[i] = float32(math.Sin(float64(i) * 0.07))
+	}
+	for _, seconds := range []float64{0.05, 0.5, 2} {
+		ir := make([]float32, int(seconds*sampleRate))
+		for i := range ir {
+			ir[i] = float32(math.Exp(-float64(i)/(0.2*sampleRate))) * 0.01
+		}
+		b.Run(fmt.Sprintf("stereo/ir=%gs", seconds), func(b *testing.B) {
+			c := NewSoundboardConvolver(sampleRate)
+			c.SetIR(ir, ir)
+			out := make([]float32, 2*len(in))
+			b.ReportAllocs()
+			for b.Loop() {
+				c.processInto(out, in)
+			}
+			reportRealtime(b, sampleRate, len(in))
+		})
+		b.Run(fmt.Sprintf("mono/ir=%gs", seconds), func(b *testing.B) {
+			c := NewBodyConvolver(sampleRate)
+			c.SetIR(ir)
+			out := make([]float32, len(in))
+			b.ReportAllocs()
+			for b.Loop() {
+				c.processInto(out, in)
+			}
+			reportRealtime(b, sampleRate, len(in))
+		})
+	}
+}
+
+// BenchmarkConvolverBackends runs the mono convolver with each backend over
+// short IR lengths, locating the direct FIR/overlap-add crossover that
+// ConvolutionAuto uses (directFIRMaxTaps).
+func BenchmarkConvolverBackends(b *testing.B) {
+	in := make([]float32, internalBlockSize)
+	for i := range in {
+		in[i] = float32(math.Sin(float64(i) * 0.07))
+	}
+	for _, taps := range []int{32, 128, 256, 384, 512, 1024} {
+		ir := make([]float32, taps)
+		for i := range ir {
+			ir[i] = float32(math.Exp(-float64(i) / float64(taps)))
+		}
+		for _, backend := range []ConvolutionBackend{ConvolutionDirect, ConvolutionOverlapAdd} {
+			b.Run(fmt.Sprintf("taps=%d/%s", taps, backend), func(b *testing.B) {
+				c := NewBodyConvolver(48000)
+				c.SetBackend(backend)
+				c.SetIR(ir)
+				out := make([]float32, len(in))
+				b.ReportAllocs()
+				for b.Loop() {
+					c.processInto(out, in)
+				}
+			})
+		}
+	}
+}
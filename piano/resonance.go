@@ -1,10 +1,6 @@
 package piano
 
-import (
-	"math"
-
-	dspcore "github.com/cwbudde/algo-dsp/dsp/core"
-)
+import "math"
 
 type resonanceTarget interface {
 	isUndamped() bool
@@ -14,13 +10,22 @@ type resonanceTarget interface {
 
 // ResonanceEngine injects a band-limited bridge signal into undamped strings.
 type ResonanceEngine struct {
+	sampleRate    int
 	injectionGain float32
 	perNoteFilter bool
-	dcR           float32
-	dcPrevIn      float32
-	dcPrevOut     float32
-	lpA           float32
-	lpState       float32
+	sustainDown   bool
+
+	// Bloom shaping: injection gain ramps from 0 to 1 after sustain-pedal
+	// down with a one-pole attack, and per-target drive is soft-limited.
+	bloom      float32
+	bloomA     float32
+	saturation float32
+
+	dcR       float32
+	dcPrevIn  float32
+	dcPrevOut float32
+	lpA       float32
+	lpState   float32
 }
 
 func NewResonanceEngine(sampleRate int, injectionGain float32, perNoteFilter bool) *ResonanceEngine {
@@ -33,20 +38,61 @@ func NewResonanceEngine(sampleRate int, injectionGain float32, perNoteFilter boo
 	cutoffHz := 3200.0
 	a := float32(math.Exp(-2.0 * math.Pi * cutoffHz / float64(sampleRate)))
 	return &ResonanceEngine{
+		sampleRate:    sampleRate,
 		injectionGain: injectionGain,
 		perNoteFilter: perNoteFilter,
+		bloom:         1.0,
 		dcR:           0.995,
 		lpA:           a,
 	}
 }
 
+// SetBloomShape configures how sympathetic energy builds after the sustain
+// pedal goes down. attackMs is the one-pole time constant of the injection
+// gain ramp (0 = instant) and saturation is the soft-limit level applied to
+// the per-string drive (0 = unlimited).
+func (r *ResonanceEngine) SetBloomShape(attackMs float32, saturation float32) {
+	if r == nil {
+		return
+	}
+	r.bloomA = 0
+	if attackMs > 0 {
+		r.bloomA = 1.0 - float32(math.Exp(-1000.0/(float64(attackMs)*float64(r.sampleRate))))
+	} else {
+		r.bloom = 1.0
+	}
+	r.saturation = maxf(saturation, 0)
+}
+
+// SetSustain restarts the bloom ramp on a pedal-down transition.
+func (r *ResonanceEngine) SetSustain(down bool) {
+	if r == nil {
+		return
+	}
+	if down && !r.sustainDown && r.bloomA > 0 {
+		r.bloom = 0
+	}
+	r.sustainDown = down
+}
+
+func (r *ResonanceEngine) saturate(x float32) float32 {
+	if r.saturation <= 0 {
+		return x
+	}
+	a := x
+	if a < 0 {
+		a = -a
+	}
+	return x / (1.0 + a/r.saturation)
+}
+
 func (r *ResonanceEngine) bandLimit(x float32) float32 {
 	dcOut := x - r.dcPrevIn + r.dcR*r.dcPrevOut
 	r.dcPrevIn = x
 	r.dcPrevOut = dcOut
 
 	lp := (1.0-r.lpA)*dcOut + r.lpA*r.lpState
-	lp = float32(dspcore.FlushDenormals(float64(lp)))
+	lp = flushDenormal(lp)
 	r.lpState = lp
 	return lp
 }
@@ -56,18 +102,22 @@ func (r *ResonanceEngine) InjectFromBridge(bridge []float32, targets []resonance
 		return
 	}
 	for i := 0; i < len(bridge); i++ {
+		if r.bloomA > 0 {
+			r.bloom += (1.0 - r.bloom) * r.bloomA
+		}
 		x := r.bandLimit(bridge[i])
 		if x > -1e-8 && x < 1e-8 {
 			continue
 		}
-		energy := x * r.injectionGain
+		gain := r.injectionGain * r.bloom
+		energy := r.saturate(x * gain)
 		for _, t := range targets {
 			if !t.isUndamped() {
 				continue
 			}
 			vEnergy := energy
 			if r.perNoteFilter {
-				vEnergy = t.filterResonanceDrive(x) * r.injectionGain
+				vEnergy = r.saturate(t.filterResonanceDrive(x) * gain)
 			}
 			t.injectResonance(vEnergy)
 		}
@@ -107,9 +157,13 @@ func newNoteResonator(sampleRate int, centerHz float32, bandwidthHz float32, gai
 	return noteResonator{a1: a1, a2: a2, b0: b0, gain: gain}
 }
 
+func (r *noteResonator) reset() {
+	r.y1, r.y2 = 0, 0
+}
+
 func (r *noteResonator) process(x float32) float32 {
 	y := r.b0*x + r.a1*r.y1 + r.a2*r.y2
-	y = float32(dspcore.FlushDenormals(float64(y)))
+	y = flushDenormal(y)
 	r.y2 = r.y1
 	r.y1 = y
 	return y * r.gain
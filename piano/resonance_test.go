@@ -35,3 +35,59 @@ func TestPerNoteResonanceFilterIsFrequencySelective(t *testing.T) {
 		t.Fatalf("expected per-note filter to favor note partial region: near=%f far=%f", near, far)
 	}
 }
+
+func TestResonanceAttackSlowsSustainBloom(t *testing.T) {
+	instantParams := NewDefaultParams()
+	instantParams.ResonanceEnabled = true
+	instantParams.CouplingEnabled = false
+	instant, instantHeld := setupSympatheticScenario(instantParams)
+
+	slowParams := NewDefaultParams()
+	slowParams.ResonanceEnabled = true
+	slowParams.CouplingEnabled = false
+	slowParams.ResonanceAttackMs = 400
+	slow, slowHeld := setupSympatheticScenario(slowParams)
+
+	for i := 0; i < 20; i++ {
+		_ = instant.Process(128)
+		_ = slow.Process(128)
+	}
+
+	instantEnergy := voiceInternalEnergy(instantHeld)
+	slowEnergy := voiceInternalEnergy(slowHeld)
+	if slowEnergy >= instantEnergy*0.5 {
+		t.Fatalf("expected slow resonance attack to delay bloom: slow=%e instant=%e", slowEnergy, instantEnergy)
+	}
+}
+
+func TestResonanceSaturationLimitsDrive(t *testing.T) {
+	r := NewResonanceEngine(48000, 1.0, false)
+	r.SetBloomShape(0, 0.01)
+	if got := r.saturate(1.0); got >= 0.01 {
+		t.Fatalf("expected saturated drive below limit, got %f", got)
+	}
+	if got := r.saturate(-1.0); got <= -0.01 {
+		t.Fatalf("expected symmetric saturation, got %f", got)
+	}
+	if got := r.saturate(1e-6); got < 0.9e-6 {
+		t.Fatalf("expected small drive to pass nearly unchanged, got %g", got)
+	}
+}
+
+func TestResonanceBloomRestartsOnPedalDown(t *testing.T) {
+	r := NewResonanceEngine(48000, 1.0, false)
+	r.SetBloomShape(50, 0)
+	r.SetSustain(true)
+	if r.bloom != 0 {
+		t.Fatalf("expected bloom reset on pedal down, got %f", r.bloom)
+	}
+	bridge := make([]float32, 4800)
+	r.InjectFromBridge(bridge, []resonanceTarget{newRingingStringGroup(48000, 60, NewDefaultParams())})
+	if r.bloom < 0.8 {
+		t.Fatalf("expected bloom to approach unity after several time constants, got %f", r.bloom)
+	}
+	r.SetSustain(true)
+	if r.bloom < 0.8 {
+		t.Fatalf("expected repeated pedal-down without release to keep bloom, got %f", r.bloom)
+	}
+}
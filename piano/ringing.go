@@ -8,9 +8,13 @@ import (
 type ringingGroup interface {
 	resonanceTarget
 	setKeyDown(down bool)
+	setReleaseVelocity(velocity int)
 	setSustain(down bool)
 	injectHammerForce(force float32, strikePos float32)
 	injectCouplingForce(force float32)
+	setDetuneDrift(cents []float32)
+	setTuningDrift(cents float32)
+	setHarmonicTouch(harmonic int, frames int)
 	processSample(unisonCrossfeed float32) float32
 	endBlock(blockEnergy float64, frames int) bool
 	isActive() bool
@@ -28,8 +32,13 @@ type RingingStringGroup struct {
 
 	keyDown     bool
 	sustainDown bool
+	damper      noteDamper
 	active      bool
 	quietBlocks int
+	touchFrames int
+
+	strikeDetune []float32 // per-string humanization offsets (cents)
+	tuningDrift  float32   // whole-note drift offset (cents)
 }
 
 type couplingEdge struct {
@@ -44,51 +53,88 @@ const (
 )
 
 func newRingingStringGroup(sampleRate int, note int, params *Params) *RingingStringGroup {
-	lossGain := float32(0.9998)
-	highFreqDamping := float32(0.05)
+	// Piano starts damped unless key is held or sustain pedal is down.
+	g := &RingingStringGroup{
+		note:   note,
+		damper: newNoteDamper(sampleRate, params, note),
+	}
+	g.configure(sampleRate, params)
+	return g
+}
+
+// configure tunes the group's strings to params. Existing strings are
+// retuned in place and keep ringing; they are only rebuilt when the note's
+// string count changes.
+func (g *RingingStringGroup) configure(sampleRate int, params *Params) {
+	lossGain := noteLoopLoss(params, g.note)
+	highFreqDamping := noteHighFreqDamping(params, g.note)
 	inharmonicity := float32(0.0)
+	var preps []Preparation
 	unisonDetuneScale := float32(1.0)
-	unisonCrossfeed := float32(0.0008)
-	_ = unisonCrossfeed // configured on bank level, kept here for parameter parity.
 
 	if params != nil {
 		if params.UnisonDetuneScale >= 0 {
 			unisonDetuneScale = params.UnisonDetuneScale
 		}
-		if params.HighFreqDamping > 0 {
-			highFreqDamping = params.HighFreqDamping
-		}
-		if np, ok := params.PerNote[note]; ok && np != nil {
-			if np.Loss > 0.0 && np.Loss <= 1.0 {
-				lossGain = np.Loss
-			}
+		if np, ok := params.PerNote[g.note]; ok && np != nil {
 			if np.Inharmonicity > 0.0 {
 				inharmonicity = np.Inharmonicity
 			}
+			preps = np.Preparations
 		}
 	}
 
-	freq := midiNoteToFreq(note)
-	detunes, gains := defaultUnisonForNote(note)
-	strings := make([]*StringWaveguide, 0, len(detunes))
+	freq := noteFrequency(g.note, params)
+	detunes, gains := unisonForNote(params, g.note)
+	damperReflection := noteDamperReflection(params)
+	if len(g.strings) != len(detunes) {
+		g.strings = make([]*StringWaveguide, len(detunes))
+	}
 	for i := range detunes {
-		ratio := centsToRatio(detunes[i] * unisonDetuneScale)
-		str := NewStringWaveguide(sampleRate, freq*ratio)
+		f := freq * centsToRatio(detunes[i]*unisonDetuneScale)
+		str := g.strings[i]
+		if str == nil {
+			str = NewStringWaveguide(sampleRate, f)
+			g.strings[i] = str
+		} else {
+			str.setFrequency(f)
+		}
 		str.SetLoopLoss(lossGain, highFreqDamping)
 		str.SetDispersion(inharmonicity)
-		// Piano starts damped unless key is held or sustain pedal is down.
-		str.SetDamper(true)
-		strings = append(strings, str)
+		str.setPreparations(preps)
+		str.setDamperReflection(damperReflection)
+		str.setDamperAmount(g.damper.level.current)
+	}
+	g.gains = append(g.gains[:0], gains...)
+	if freq != g.f0 {
+		g.f0 = freq
+		g.initResonanceFilters(sampleRate)
 	}
+	g.applyDetune()
+}
 
-	g := &RingingStringGroup{
-		note:    note,
-		f0:      freq,
-		strings: strings,
-		gains:   append([]float32(nil), gains...),
+// applyParams retunes the group and its damper to params in place.
+func (g *RingingStringGroup) applyParams(sampleRate int, params *Params) {
+	g.damper.retune(sampleRate, params, g.note)
+	g.configure(sampleRate, params)
+	g.updateDamperState()
+}
+
+// reset silences the group and returns its key, pedal, damper and drift
+// state to that of a freshly built group.
+func (g *RingingStringGroup) reset() {
+	g.keyDown, g.sustainDown = false, false
+	g.active, g.quietBlocks, g.touchFrames = false, 0, 0
+	g.strikeDetune, g.tuningDrift = g.strikeDetune[:0], 0
+	g.damper.reset()
+	for _, s := range g.strings {
+		s.reset()
 	}
-	g.initResonanceFilters(sampleRate)
-	return g
+	for i := range g.resFilters {
+		g.resFilters[i].reset()
+	}
+	g.applyDamper(g.damper.level.current)
+	g.applyDetune()
 }
 
 func (g *RingingStringGroup) initResonanceFilters(sampleRate int) {
@@ -125,6 +171,11 @@ func (g *RingingStringGroup) setKeyDown(down bool) {
 	}
 }
 
+// setReleaseVelocity sets the MIDI release velocity of the next key-off.
+func (g *RingingStringGroup) setReleaseVelocity(velocity int) {
+	g.damper.setReleaseVelocity(velocity)
+}
+
 func (g *RingingStringGroup) setSustain(down bool) {
 	g.sustainDown = down
 	g.updateDamperState()
@@ -136,13 +187,21 @@ func (g *RingingStringGroup) setSustain(down bool) {
 
 func (g *RingingStringGroup) updateDamperState() {
 	engageDamper := !g.keyDown && !g.sustainDown
+	if g.damper.set(engageDamper) {
+		g.applyDamper(g.damper.level.current)
+	}
+}
+
+// applyDamper sets how firmly the damper rests on the strings; during a
+// damper movement processSample calls it every sample.
+func (g *RingingStringGroup) applyDamper(amount float32) {
 	for _, s := range g.strings {
-		s.SetDamper(engageDamper)
+		s.setDamperAmount(amount)
 	}
 }
 
 func (g *RingingStringGroup) isUndamped() bool {
-	return g.keyDown || g.sustainDown
+	return g.keyDown || g.sustainDown || g.damper.strength == 0
 }
 
 func (g *RingingStringGroup) isActive() bool {
@@ -201,7 +260,33 @@ func (g *RingingStringGroup) injectCouplingForce(force float32) {
 	g.quietBlocks = 0
 }
 
+func (g *RingingStringGroup) setDetuneDrift(cents []float32) {
+	g.strikeDetune = append(g.strikeDetune[:0], cents...)
+	g.applyDetune()
+}
+
+func (g *RingingStringGroup) setTuningDrift(cents float32) {
+	if d := cents - g.tuningDrift; d < tuningDriftMinStepCents && d > -tuningDriftMinStepCents {
+		return
+	}
+	g.tuningDrift = cents
+	g.applyDetune()
+}
+
+func (g *RingingStringGroup) applyDetune() {
+	for i, s := range g.strings {
+		c := g.tuningDrift
+		if i < len(g.strikeDetune) {
+			c += g.strikeDetune[i]
+		}
+		s.SetTuningOffset(c)
+	}
+}
+
 func (g *RingingStringGroup) processSample(unisonCrossfeed float32) float32 {
+	if !g.damper.level.settled() {
+		g.applyDamper(g.damper.level.next())
+	}
 	sample := float32(0)
 	for i, s := range g.strings {
 		sg := float32(1.0)
@@ -219,8 +304,33 @@ func (g *RingingStringGroup) processSample(unisonCrossfeed float32) float32 {
 	return sample
 }
 
+// setHarmonicTouch rests a finger on the node at 1/harmonic of the string
+// length for the given number of frames; harmonic 0 lifts it immediately.
+func (g *RingingStringGroup) setHarmonicTouch(harmonic int, frames int) {
+	if harmonic < 2 || harmonic > MaxPreparationHarmonic || frames <= 0 {
+		harmonic = 0
+	}
+	for _, s := range g.strings {
+		s.setHarmonicTouch(harmonic)
+	}
+	g.touchFrames = 0
+	if harmonic > 0 {
+		g.touchFrames = frames
+		g.active = true
+		g.quietBlocks = 0
+	}
+}
+
 func (g *RingingStringGroup) endBlock(blockEnergy float64, frames int) bool {
-	if g.isUndamped() {
+	if g.touchFrames > 0 {
+		g.touchFrames -= frames
+		if g.touchFrames <= 0 {
+			g.setHarmonicTouch(0, 0)
+		}
+	}
+	// Held strings stay active; a string without a damper still goes
+	// quiet once it has decayed.
+	if g.keyDown || g.sustainDown {
 		g.active = true
 		g.quietBlocks = 0
 		return true
@@ -258,6 +368,7 @@ type StringBank struct {
 	couplingEnabled          bool
 	couplingMode             CouplingMode
 	couplingAmount           float32
+	couplingScale            smoothedParam // runtime coupling amount relative to couplingAmount
 	couplingMaxForce         float32
 	staticOctaveGain         float32
 	staticFifthGain          float32
@@ -265,6 +376,7 @@ type StringBank struct {
 	couplingHarmonicFalloff  float32
 	couplingDetuneSigmaCents float32
 	couplingDistanceExponent float32
+	couplingGuard            couplingGuard
 	groups                   [128]*RingingStringGroup
 	modalGroups              [128]*ModalStringGroup
 	targets                  []resonanceTarget
@@ -273,6 +385,9 @@ type StringBank struct {
 	active                   [128]bool
 	activeNotes              []int
 	blockEnergy              [128]float64
+	levelEnergy              [128]float64
+	levelPeak                [128]float32
+	levelFrames              int
 	couplingSum              [128]float64
 	couplingAbs              [128]float64
 	sampleOut                [128]float32
@@ -299,10 +414,49 @@ func sanitizeNoteRange(minNote int, maxNote int) (int, int) {
 }
 
 func NewStringBank(sampleRate int, params *Params) *StringBank {
+	sb := &StringBank{
+		sampleRate:    sampleRate,
+		couplingGuard: newCouplingGuard(sampleRate),
+		targets:       make([]resonanceTarget, 0, 128),
+		activeNotes:   make([]int, 0, 128),
+	}
+	sb.configure(params)
+	for note := sb.minNote; note <= sb.maxNote; note++ {
+		sb.newGroup(note, noteStringModel(params, note, sb.stringModel), params)
+	}
+	sb.rebuildTargets()
+	sb.initDistanceMap()
+	sb.rebuildCouplingGraph()
+	return sb
+}
+
+// bankNoteRange returns the sanitized note range params configure.
+func bankNoteRange(params *Params) (int, int) {
+	if params == nil {
+		return StandardMinNote, StandardMaxNote
+	}
+	return sanitizeNoteRange(params.MinNote, params.MaxNote)
+}
+
+// noteStringModel resolves the string core of note: its NoteParams
+// override when valid, else def.
+func noteStringModel(params *Params, note int, def StringModel) StringModel {
+	if params != nil {
+		if np := params.PerNote[note]; np != nil {
+			switch np.StringModel {
+			case StringModelDWG, StringModelModal:
+				return np.StringModel
+			}
+		}
+	}
+	return def
+}
+
+// configure sets the bank-level string model, crossfeed and coupling
+// settings from params.
+func (sb *StringBank) configure(params *Params) {
 	unisonCrossfeed := float32(0.0008)
 	stringModel := StringModelDWG
-	minNote := 21
-	maxNote := 108
 	couplingEnabled := true
 	couplingMode := CouplingModeStatic
 	couplingAmount := float32(1.0)
@@ -355,47 +509,121 @@ func NewStringBank(sampleRate int, params *Params) *StringBank {
 		if params.CouplingMaxNeighbors > 0 {
 			couplingMaxNeighbors = params.CouplingMaxNeighbors
 		}
-		minNote = params.MinNote
-		maxNote = params.MaxNote
 	}
-	minNote, maxNote = sanitizeNoteRange(minNote, maxNote)
 	if !couplingEnabled || couplingAmount <= 0 {
 		couplingMode = CouplingModeOff
 	}
 
-	sb := &StringBank{
-		sampleRate:               sampleRate,
-		minNote:                  minNote,
-		maxNote:                  maxNote,
-		stringModel:              stringModel,
-		unisonCrossfeed:          unisonCrossfeed,
-		couplingEnabled:          couplingMode != CouplingModeOff,
-		couplingMode:             couplingMode,
-		couplingAmount:           couplingAmount,
-		couplingMaxForce:         couplingMaxForce,
-		staticOctaveGain:         couplingOctaveGain,
-		staticFifthGain:          couplingFifthGain,
-		couplingMaxNeighbors:     couplingMaxNeighbors,
-		couplingHarmonicFalloff:  couplingHarmonicFalloff,
-		couplingDetuneSigmaCents: couplingDetuneSigmaCents,
-		couplingDistanceExponent: couplingDistanceExponent,
-		targets:                  make([]resonanceTarget, 0, 128),
-		activeNotes:              make([]int, 0, 128),
+	sb.minNote, sb.maxNote = bankNoteRange(params)
+	sb.stringModel = stringModel
+	sb.unisonCrossfeed = unisonCrossfeed
+	sb.couplingEnabled = couplingMode != CouplingModeOff
+	sb.couplingMode = couplingMode
+	sb.couplingAmount = couplingAmount
+	sb.couplingScale = newSmoothedParam(sb.sampleRate, controlSmoothing(params).CouplingAmountMs, 1)
+	sb.couplingMaxForce = couplingMaxForce
+	sb.staticOctaveGain = couplingOctaveGain
+	sb.staticFifthGain = couplingFifthGain
+	sb.couplingMaxNeighbors = couplingMaxNeighbors
+	sb.couplingHarmonicFalloff = couplingHarmonicFalloff
+	sb.couplingDetuneSigmaCents = couplingDetuneSigmaCents
+	sb.couplingDistanceExponent = couplingDistanceExponent
+}
+
+// ApplyParams retunes the bank to params in place, for live preset edits
+// and reuse of a warm engine. String groups keep their buffers and ringing
+// state unless a note changes string core or string count; the coupling
+// graph is rebuilt. A different note range needs a new bank: ApplyParams
+// then changes nothing and reports false.
+func (sb *StringBank) ApplyParams(params *Params) bool {
+	if sb == nil {
+		return false
 	}
+	if minNote, maxNote := bankNoteRange(params); minNote != sb.minNote || maxNote != sb.maxNote {
+		return false
+	}
+	sb.configure(params)
 	for note := sb.minNote; note <= sb.maxNote; note++ {
-		if stringModel == StringModelModal {
-			g := newModalStringGroup(sampleRate, note, params)
-			sb.modalGroups[note] = g
-			sb.targets = append(sb.targets, g)
+		model := noteStringModel(params, note, sb.stringModel)
+		if sb.NoteStringModel(note) != model {
+			sb.replaceGroup(note, model, params)
 			continue
 		}
-		g := newRingingStringGroup(sampleRate, note, params)
-		sb.groups[note] = g
-		sb.targets = append(sb.targets, g)
+		if g := sb.modalGroups[note]; g != nil {
+			g.applyParams(sb.sampleRate, params)
+		} else if g := sb.groups[note]; g != nil {
+			g.applyParams(sb.sampleRate, params)
+		}
 	}
-	sb.initDistanceMap()
+	sb.rebuildTargets()
 	sb.rebuildCouplingGraph()
-	return sb
+	return true
+}
+
+// reset silences every note and clears the bank's activity, level and
+// coupling state, leaving the bank as NewStringBank builds it.
+func (sb *StringBank) reset() {
+	for note := sb.minNote; note <= sb.maxNote; note++ {
+		switch g := sb.activeGroup(note).(type) {
+		case *RingingStringGroup:
+			g.reset()
+		case *ModalStringGroup:
+			g.reset()
+		}
+	}
+	sb.active = [128]bool{}
+	sb.activeNotes = sb.activeNotes[:0]
+	sb.blockEnergy = [128]float64{}
+	sb.levelEnergy = [128]float64{}
+	sb.levelPeak = [128]float32{}
+	sb.levelFrames = 0
+	sb.couplingSum = [128]float64{}
+	sb.couplingAbs = [128]float64{}
+	sb.sampleOut = [128]float32{}
+	sb.couplingGuard = newCouplingGuard(sb.sampleRate)
+	sb.couplingScale.jump(1)
+}
+
+// newGroup builds note's string group on the given core.
+func (sb *StringBank) newGroup(note int, model StringModel, params *Params) ringingGroup {
+	if model == StringModelModal {
+		g := newModalStringGroup(sb.sampleRate, note, params)
+		sb.modalGroups[note] = g
+		return g
+	}
+	g := newRingingStringGroup(sb.sampleRate, note, params)
+	sb.groups[note] = g
+	return g
+}
+
+// replaceGroup rebuilds note on the given core, carrying over the key and
+// sustain state; the note's ringing restarts from silence.
+func (sb *StringBank) replaceGroup(note int, model StringModel, params *Params) {
+	var keyDown, sustainDown bool
+	switch g := sb.activeGroup(note).(type) {
+	case *RingingStringGroup:
+		keyDown, sustainDown = g.keyDown, g.sustainDown
+	case *ModalStringGroup:
+		keyDown, sustainDown = g.keyDown, g.sustainDown
+	}
+	sb.groups[note], sb.modalGroups[note] = nil, nil
+	g := sb.newGroup(note, model, params)
+	if sustainDown {
+		g.setSustain(true)
+	}
+	if keyDown {
+		g.setKeyDown(true)
+	}
+}
+
+// rebuildTargets lists the notes' groups as resonance targets.
+func (sb *StringBank) rebuildTargets() {
+	sb.targets = sb.targets[:0]
+	for note := sb.minNote; note <= sb.maxNote; note++ {
+		if g := sb.activeGroup(note); g != nil {
+			sb.targets = append(sb.targets, g)
+		}
+	}
 }
 
 func (sb *StringBank) ensureOutputBuffer(numFrames int) []float32 {
@@ -438,7 +666,7 @@ func (sb *StringBank) initStaticCouplingGraph(octaveGain float32, fifthGain floa
 	}
 	for note := sb.minNote; note <= sb.maxNote; note++ {
 		srcScale := sb.sourceStringCouplingScale(note)
-		edges := make([]couplingEdge, 0, 4)
+		edges := sb.coupling[note]
 		if octaveGain > 0 {
 			if to := note + 12; sb.noteInRange(to) {
 				edges = append(edges, couplingEdge{
@@ -519,7 +747,7 @@ func (sb *StringBank) initPhysicalCouplingGraph(sampleRate int) {
 		if sumScore <= 0 {
 			continue
 		}
-		edges := make([]couplingEdge, 0, len(candidates))
+		edges := sb.coupling[src]
 		outGain := couplingPhysicalBaseGain * sb.couplingAmount * sb.sourceStringCouplingScale(src)
 		for _, c := range candidates {
 			edges = append(edges, couplingEdge{
@@ -619,6 +847,8 @@ func (sb *StringBank) ModalGroup(note int) *ModalStringGroup {
 	return sb.modalGroups[note]
 }
 
+// StringModel returns the bank's default string core; per-note overrides
+// are reported by NoteStringModel.
 func (sb *StringBank) StringModel() StringModel {
 	if sb == nil {
 		return StringModelDWG
@@ -626,15 +856,43 @@ func (sb *StringBank) StringModel() StringModel {
 	return sb.stringModel
 }
 
+// NoteStringModel returns the string core the note rings on.
+func (sb *StringBank) NoteStringModel(note int) StringModel {
+	if sb == nil || !sb.noteInRange(note) {
+		return StringModelDWG
+	}
+	if sb.modalGroups[note] != nil {
+		return StringModelModal
+	}
+	return StringModelDWG
+}
+
+// SetNoteStringModel rebuilds one note on the given string core and leaves
+// the rest of the bank untouched. The key and sustain state carry over, the
+// note's ringing restarts from silence.
+func (sb *StringBank) SetNoteStringModel(note int, model StringModel, params *Params) bool {
+	if sb == nil || !sb.noteInRange(note) {
+		return false
+	}
+	switch model {
+	case StringModelDWG, StringModelModal:
+	default:
+		return false
+	}
+	if sb.NoteStringModel(note) == model {
+		return true
+	}
+
+	sb.replaceGroup(note, model, params)
+	sb.rebuildTargets()
+	sb.rebuildCouplingGraph()
+	return true
+}
+
 func (sb *StringBank) activeGroup(note int) ringingGroup {
 	if !sb.noteInRange(note) {
 		return nil
 	}
-	if sb.stringModel == StringModelModal {
-		if g := sb.modalGroups[note]; g != nil {
-			return g
-		}
-	}
 	if g := sb.groups[note]; g != nil {
 		return g
 	}
@@ -652,10 +910,8 @@ func (sb *StringBank) noteStringCount(note int) int {
 	if !sb.noteInRange(note) {
 		return 0
 	}
-	detunes, _ := defaultUnisonForNote(note)
-	if len(detunes) == 0 {
-		return 0
-	}
+	// Without a group only the default stringing is known.
+	detunes, _ := unisonForNote(nil, note)
 	return len(detunes)
 }
 
@@ -673,6 +929,14 @@ func (sb *StringBank) noteFundamental(note int) float32 {
 	return midiNoteToFreq(note)
 }
 
+// isNoteActive reports whether note is still being processed by the bank.
+func (sb *StringBank) isNoteActive(note int) bool {
+	if !sb.noteInRange(note) {
+		return false
+	}
+	return sb.active[note]
+}
+
 func (sb *StringBank) markActive(note int) {
 	if !sb.noteInRange(note) || sb.active[note] {
 		return
@@ -692,6 +956,14 @@ func (sb *StringBank) SetKeyDown(note int, down bool) {
 	}
 }
 
+// SetReleaseVelocity sets the MIDI release velocity of the next key-off
+// of note.
+func (sb *StringBank) SetReleaseVelocity(note int, velocity int) {
+	if g := sb.activeGroup(note); g != nil {
+		g.setReleaseVelocity(velocity)
+	}
+}
+
 func (sb *StringBank) SetSustain(down bool) {
 	for note := sb.minNote; note <= sb.maxNote; note++ {
 		g := sb.activeGroup(note)
@@ -702,6 +974,33 @@ func (sb *StringBank) SetSustain(down bool) {
 	}
 }
 
+// SetDetuneDrift applies per-unison-string tuning offsets (cents) to a note.
+func (sb *StringBank) SetDetuneDrift(note int, cents []float32) {
+	g := sb.activeGroup(note)
+	if g == nil {
+		return
+	}
+	g.setDetuneDrift(cents)
+}
+
+// SetTuningDrift offsets the whole tuning of a note (cents) on top of the
+// per-string detune drift.
+func (sb *StringBank) SetTuningDrift(note int, cents float32) {
+	g := sb.activeGroup(note)
+	if g == nil {
+		return
+	}
+	g.setTuningDrift(cents)
+}
+
+func (sb *StringBank) SetHarmonicTouch(note int, harmonic int, frames int) {
+	g := sb.activeGroup(note)
+	if g == nil {
+		return
+	}
+	g.setHarmonicTouch(harmonic, frames)
+}
+
 func (sb *StringBank) InjectHammerForce(note int, force float32, strikePos float32) {
 	g := sb.activeGroup(note)
 	if g == nil {
@@ -709,12 +1008,20 @@ func (sb *StringBank) InjectHammerForce(note int, force float32, strikePos float
 	}
 	g.injectHammerForce(force, strikePos)
 	sb.markActive(note)
+	sb.couplingGuard.drive()
 }
 
+// InjectCouplingForce injects an external coupling force into note. It
+// counts as external drive for the coupling energy guard.
 func (sb *StringBank) InjectCouplingForce(note int, force float32) {
 	if force == 0 {
 		return
 	}
+	sb.couplingGuard.drive()
+	sb.injectCouplingForce(note, force)
+}
+
+func (sb *StringBank) injectCouplingForce(note int, force float32) {
 	if sb.couplingMaxForce > 0 {
 		if force > sb.couplingMaxForce {
 			force = sb.couplingMaxForce
@@ -735,6 +1042,7 @@ func (sb *StringBank) Process(numFrames int, hammer *HammerExciter) []float32 {
 	if numFrames <= 0 {
 		return out
 	}
+	sb.levelFrames += numFrames
 	if len(sb.activeNotes) == 0 {
 		for i := 0; i < numFrames; i++ {
 			if hammer != nil {
@@ -751,6 +1059,7 @@ func (sb *StringBank) Process(numFrames int, hammer *HammerExciter) []float32 {
 		sb.couplingAbs[note] = 0
 	}
 
+	crossfeed := sb.unisonCrossfeed * sb.couplingGuard.gain
 	for i := 0; i < numFrames; i++ {
 		if hammer != nil {
 			hammer.ProcessSample(sb)
@@ -762,21 +1071,34 @@ func (sb *StringBank) Process(numFrames int, hammer *HammerExciter) []float32 {
 			if g == nil || !g.isActive() {
 				continue
 			}
-			s := g.processSample(sb.unisonCrossfeed)
+			s := g.processSample(crossfeed)
 			sb.sampleOut[note] = s
 			mix += s
 			sf := float64(s)
 			sb.blockEnergy[note] += sf * sf
-			sb.couplingSum[note] += sf
 			if s < 0 {
 				sb.couplingAbs[note] -= sf
+				if -s > sb.levelPeak[note] {
+					sb.levelPeak[note] = -s
+				}
 			} else {
 				sb.couplingAbs[note] += sf
+				if s > sb.levelPeak[note] {
+					sb.levelPeak[note] = s
+				}
 			}
+			sb.couplingSum[note] += sf
 		}
 		out[i] = mix
 	}
+	var power float64
+	for _, note := range sb.activeNotes {
+		sb.levelEnergy[note] += sb.blockEnergy[note]
+		power += sb.blockEnergy[note]
+	}
+	sb.couplingGuard.update(power/float64(numFrames), numFrames, sb.couplingEnabled || sb.unisonCrossfeed > 0)
 	if sb.couplingEnabled {
+		sb.couplingScale.advance(numFrames)
 		sb.applySparseCouplingBlockwise(numFrames)
 	}
 
@@ -809,6 +1131,12 @@ func (sb *StringBank) applySparseCouplingBlockwise(numFrames int) {
 	if n := len(sb.activeNotes); n > 1 {
 		polyScale = float32(1.0 / math.Sqrt(float64(n)))
 	}
+	if scale := sb.couplingScale.current; scale != 1 {
+		polyScale *= scale
+	}
+	if guard := sb.couplingGuard.gain; guard != 1 {
+		polyScale *= guard
+	}
 	for _, src := range sb.activeNotes {
 		driveMag := float32(sb.couplingAbs[src]) * invFrames
 		if driveMag > -eps && driveMag < eps {
@@ -824,7 +1152,7 @@ func (sb *StringBank) applySparseCouplingBlockwise(numFrames int) {
 		}
 		edges := sb.coupling[src]
 		for _, e := range edges {
-			sb.InjectCouplingForce(e.to, srcDrive*e.gain*polyScale)
+			sb.injectCouplingForce(e.to, srcDrive*e.gain*polyScale)
 		}
 	}
 }
@@ -864,6 +1192,21 @@ func (sb *StringBank) SetCouplingMode(mode CouplingMode) bool {
 	return true
 }
 
+// SetCouplingAmount changes the coupling strength at runtime. The graph keeps
+// the gains it was built with and the change glides in as a multiplier; with
+// no graph built the amount applies from the next coupling mode change.
+func (sb *StringBank) SetCouplingAmount(amount float32) {
+	if sb == nil {
+		return
+	}
+	amount = clampFloat32(amount, 0, 1)
+	if sb.couplingAmount <= 0 {
+		sb.couplingAmount = amount
+		return
+	}
+	sb.couplingScale.set(amount / sb.couplingAmount)
+}
+
 func clampFloat32(v float32, lo float32, hi float32) float32 {
 	if v < lo {
 		return lo
@@ -889,6 +1232,13 @@ func (r *RingingState) SetKeyDown(note int, down bool) {
 	r.bank.SetKeyDown(note, down)
 }
 
+func (r *RingingState) SetReleaseVelocity(note int, velocity int) {
+	if r == nil || r.bank == nil {
+		return
+	}
+	r.bank.SetReleaseVelocity(note, velocity)
+}
+
 func (r *RingingState) SetSustain(down bool) {
 	if r == nil || r.bank == nil {
 		return
@@ -896,6 +1246,41 @@ func (r *RingingState) SetSustain(down bool) {
 	r.bank.SetSustain(down)
 }
 
+func (r *RingingState) SetDetuneDrift(note int, cents []float32) {
+	if r == nil || r.bank == nil {
+		return
+	}
+	r.bank.SetDetuneDrift(note, cents)
+}
+
+func (r *RingingState) SetTuningDrift(note int, cents float32) {
+	if r == nil || r.bank == nil {
+		return
+	}
+	r.bank.SetTuningDrift(note, cents)
+}
+
+func (r *RingingState) SetHarmonicTouch(note int, harmonic int, frames int) {
+	if r == nil || r.bank == nil {
+		return
+	}
+	r.bank.SetHarmonicTouch(note, harmonic, frames)
+}
+
+func (r *RingingState) StringCount(note int) int {
+	if r == nil || r.bank == nil {
+		return 0
+	}
+	return r.bank.noteStringCount(note)
+}
+
+func (r *RingingState) isNoteActive(note int) bool {
+	if r == nil || r.bank == nil {
+		return false
+	}
+	return r.bank.isNoteActive(note)
+}
+
 func (r *RingingState) Process(numFrames int, hammer *HammerExciter) []float32 {
 	if r == nil || r.bank == nil {
 		return make([]float32, numFrames)
@@ -917,9 +1302,23 @@ func (r *RingingState) SetCouplingMode(mode CouplingMode) bool {
 	return r.bank.SetCouplingMode(mode)
 }
 
+func (r *RingingState) SetCouplingAmount(amount float32) {
+	if r == nil || r.bank == nil {
+		return
+	}
+	r.bank.SetCouplingAmount(amount)
+}
+
 func (r *RingingState) StringModel() StringModel {
 	if r == nil || r.bank == nil {
 		return StringModelDWG
 	}
 	return r.bank.StringModel()
 }
+
+func (r *RingingState) NoteStringModel(note int) StringModel {
+	if r == nil || r.bank == nil {
+		return StringModelDWG
+	}
+	return r.bank.NoteStringModel(note)
+}
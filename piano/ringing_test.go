@@ -234,6 +234,40 @@ func TestStringBankPhysicalCouplingBuildsSparseTopKGraph(t *testing.T) {
 	}
 }
 
+func TestCouplingGraphMirrorsBankEdges(t *testing.T) {
+	params := NewDefaultParams()
+	params.CouplingMode = CouplingModePhysical
+	params.CouplingAmount = 1.0
+	params.CouplingMaxNeighbors = 4
+	sb := NewStringBank(48000, params)
+
+	g := sb.CouplingGraph()
+	if g.Mode != CouplingModePhysical {
+		t.Fatalf("expected physical mode in graph, got %q", g.Mode)
+	}
+	if len(g.Nodes) != sb.maxNote-sb.minNote+1 {
+		t.Fatalf("expected one node per note, got %d", len(g.Nodes))
+	}
+	want := 0
+	for note := sb.minNote; note <= sb.maxNote; note++ {
+		want += len(sb.coupling[note])
+	}
+	if len(g.Edges) != want || want == 0 {
+		t.Fatalf("expected %d edges, got %d", want, len(g.Edges))
+	}
+	for _, e := range g.Edges {
+		if e.From == e.To || e.Gain <= 0 {
+			t.Fatalf("unexpected edge %+v", e)
+		}
+	}
+
+	sb.SetCouplingMode(CouplingModeOff)
+	off := sb.CouplingGraph()
+	if off.Mode != CouplingModeOff || len(off.Edges) != 0 {
+		t.Fatalf("expected empty graph with coupling off, got mode=%q edges=%d", off.Mode, len(off.Edges))
+	}
+}
+
 func TestPhysicalCouplingAmountScalesOutgoingGain(t *testing.T) {
 	fullParams := NewDefaultParams()
 	fullParams.CouplingMode = CouplingModePhysical
@@ -613,3 +647,168 @@ func TestModalExcitationParameterScalesOutputEnergy(t *testing.T) {
 		t.Fatalf("expected higher modal_excitation to increase energy: low=%f high=%f", lowRMS, highRMS)
 	}
 }
+
+func TestModalGroupTuningSettersRebuildModes(t *testing.T) {
+	params := NewDefaultParams()
+	params.StringModel = StringModelModal
+	params.ModalPartials = 6
+	sb := NewStringBank(48000, params)
+	g := sb.ModalGroup(60)
+	if g == nil || g.Partials() != 6 || len(g.strings[0].modes) != 6 {
+		t.Fatalf("expected a modal group with 6 partials")
+	}
+	g.injectHammerForce(1, 0.12)
+	for range 64 {
+		g.processSample(0)
+	}
+	re, im := g.strings[0].modes[0].re, g.strings[0].modes[0].im
+
+	if !g.SetPartials(3) || len(g.strings[0].modes) != 3 {
+		t.Fatalf("SetPartials(3) left %d modes", len(g.strings[0].modes))
+	}
+	if m := g.strings[0].modes[0]; m.re != re || m.im != im {
+		t.Fatalf("surviving mode lost its state: (%f,%f) want (%f,%f)", m.re, m.im, re, im)
+	}
+	if g.SetPartials(0) || g.SetPartialLoss(4, 2) || g.SetPartialLoss(1, 0) || g.SetPartialGain(1, -1) {
+		t.Fatalf("expected invalid tuning values to be rejected")
+	}
+
+	before := g.strings[0].modes[1]
+	if !g.SetPartialGain(2, 0) || !g.SetPartialLoss(2, 4) {
+		t.Fatalf("expected per-partial setters to succeed")
+	}
+	after := g.strings[0].modes[1]
+	if after.gain != 0 || g.PartialGain(2) != 0 || g.PartialLoss(2) != 4 {
+		t.Fatalf("partial 2 gain=%f loss=%f", after.gain, g.PartialLoss(2))
+	}
+	if after.decayUndamped >= before.decayUndamped || after.decayDamped >= before.decayDamped {
+		t.Fatalf("loss scale 4 should shorten the decay: undamped %f -> %f", before.decayUndamped, after.decayUndamped)
+	}
+	if g.PartialGain(3) != 1 || g.PartialLoss(1) != 1 {
+		t.Fatalf("untouched partials should keep unit scales")
+	}
+}
+
+func TestPianoSetNoteStringModelSwitchesOneNote(t *testing.T) {
+	params := NewDefaultParams()
+	params.PerNote[48] = &NoteParams{StringModel: StringModelModal}
+	p := NewPiano(48000, 16, params)
+	if p.NoteStringModel(48) != StringModelModal || p.NoteStringModel(60) != StringModelDWG {
+		t.Fatalf("per-note override not applied at construction")
+	}
+	targets := len(p.ringing.ResonanceTargets())
+
+	p.NoteOn(60, 100)
+	p.Process(256)
+	if !p.SetNoteStringModel(60, StringModelModal) {
+		t.Fatalf("expected per-note switch to modal to succeed")
+	}
+	if p.ModalGroup(60) == nil || p.ringing.bank.Group(60) != nil || p.NoteStringModel(61) != StringModelDWG {
+		t.Fatalf("expected only note 60 to move to the modal core")
+	}
+	if p.ringing.StringModel() != StringModelDWG || params.PerNote[60].StringModel != StringModelModal {
+		t.Fatalf("expected the switch to be recorded as a per-note override")
+	}
+	if got := len(p.ringing.ResonanceTargets()); got != targets {
+		t.Fatalf("resonance targets %d, want %d", got, targets)
+	}
+	if g := p.ModalGroup(60); !g.keyDown {
+		t.Fatalf("expected the held key to carry over to the new group")
+	}
+	if p.SetNoteStringModel(60, StringModel("invalid")) || p.SetNoteStringModel(130, StringModelModal) {
+		t.Fatalf("expected invalid switches to fail")
+	}
+
+	p.NoteOn(60, 100)
+	energy := 0.0
+	for range 16 {
+		energy += stereoRMS(p.Process(128))
+	}
+	if energy <= 0 || math.IsNaN(energy) {
+		t.Fatalf("expected finite output from the switched note, got %f", energy)
+	}
+}
+
+func applyParamsVariant() *Params {
+	p := NewDefaultParams()
+	p.UnisonDetuneScale = 0.4
+	p.HighFreqDamping = 0.2
+	p.DamperReflection = 0.8
+	p.CouplingMode = CouplingModePhysical
+	p.CouplingAmount = 0.7
+	p.ModalPartials = 5
+	p.ModalUndampedLoss = 1.6
+	p.PerNote[60] = &NoteParams{Loss: 0.9991, Inharmonicity: 0.2, F0: 265}
+	p.PerNote[64] = &NoteParams{StringModel: StringModelModal}
+	return p
+}
+
+func TestStringBankApplyParamsMatchesFreshBank(t *testing.T) {
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		base := NewDefaultParams()
+		base.StringModel = model
+		target := applyParamsVariant()
+		target.StringModel = model
+
+		warm := NewStringBank(48000, base)
+		if !warm.ApplyParams(target) {
+			t.Fatalf("%s: ApplyParams rejected a same-range preset", model)
+		}
+		fresh := NewStringBank(48000, target)
+		if warm.couplingMode != fresh.couplingMode || len(warm.targets) != len(fresh.targets) || warm.NoteStringModel(64) != StringModelModal {
+			t.Fatalf("%s: retuned bank differs from a fresh one", model)
+		}
+
+		hw := NewHammerExciter(48000, target)
+		hf := NewHammerExciter(48000, target)
+		for _, sb := range []*StringBank{warm, fresh} {
+			sb.SetKeyDown(60, true)
+			sb.SetKeyDown(64, true)
+		}
+		hw.Trigger(60, 100)
+		hf.Trigger(60, 100)
+		hw.Trigger(64, 90)
+		hf.Trigger(64, 90)
+		for range 40 {
+			if d := maxAbsDiff(warm.Process(128, hw), fresh.Process(128, hf)); d > 1e-6 {
+				t.Fatalf("%s: retuned bank renders differently from a fresh one: max diff %g", model, d)
+			}
+		}
+	}
+}
+
+func TestStringBankApplyParamsKeepsRingingWithoutAllocs(t *testing.T) {
+	params := NewDefaultParams()
+	sb := NewStringBank(48000, params)
+	h := NewHammerExciter(48000, params)
+	sb.SetKeyDown(60, true)
+	h.Trigger(60, 100)
+	for range 16 {
+		_ = sb.Process(128, h)
+	}
+	delayLine := &sb.Group(60).strings[0].delayLine[0]
+
+	params.HighFreqDamping = 0.1
+	params.CouplingAmount = 0.5
+	if !sb.ApplyParams(params) {
+		t.Fatal("expected ApplyParams to succeed")
+	}
+	if &sb.Group(60).strings[0].delayLine[0] != delayLine {
+		t.Fatal("expected the waveguide delay line to be reused")
+	}
+	if rms := stereoRMS(sb.Process(128, h)); rms == 0 {
+		t.Fatal("expected the held note to keep ringing across ApplyParams")
+	}
+	allocs := testing.AllocsPerRun(20, func() {
+		sb.ApplyParams(params)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected ApplyParams to retune without heap allocs, got %.1f", allocs)
+	}
+
+	moved := NewDefaultParams()
+	moved.MinNote = 30
+	if sb.ApplyParams(moved) {
+		t.Fatal("expected a note range change to be rejected")
+	}
+}
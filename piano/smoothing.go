@@ -0,0 +1,104 @@
+package piano
+
+import "math"
+
+// ControlSmoothing holds the glide times in milliseconds of the runtime
+// controls. A value <= 0 applies changes of that control immediately.
+type ControlSmoothing struct {
+	OutputGainMs     float32
+	IRMixMs          float32 // body dry and room wet levels
+	LidMs            float32
+	SoftPedalMs      float32 // continuous soft pedal amount
+	CouplingAmountMs float32
+}
+
+// DefaultControlSmoothing returns the glide times used by NewDefaultParams.
+func DefaultControlSmoothing() ControlSmoothing {
+	return ControlSmoothing{
+		OutputGainMs:     20,
+		IRMixMs:          20,
+		LidMs:            lidSmoothingMs,
+		SoftPedalMs:      50,
+		CouplingAmountMs: 50,
+	}
+}
+
+func controlSmoothing(params *Params) ControlSmoothing {
+	if params == nil {
+		return DefaultControlSmoothing()
+	}
+	return params.ControlSmoothing
+}
+
+// smoothedParam is a one-pole glide of a control value towards its target.
+// Once settled it returns the target exactly, so a constant control leaves
+// the signal bit-identical to an unsmoothed one.
+type smoothedParam struct {
+	current float32
+	target  float32
+	coeff   float32 // per-sample approach factor, 1 = immediate
+}
+
+func newSmoothedParam(sampleRate int, ms float32, initial float32) smoothedParam {
+	s := smoothedParam{current: initial, target: initial}
+	s.setTime(sampleRate, ms)
+	return s
+}
+
+// setTime sets the glide time constant; ms <= 0 makes changes immediate.
+func (s *smoothedParam) setTime(sampleRate int, ms float32) {
+	s.coeff = 1
+	if sampleRate > 0 && ms > 0 {
+		s.coeff = float32(1.0 - math.Exp(-1.0/(float64(ms)*0.001*float64(sampleRate))))
+	}
+}
+
+// set starts a glide towards v.
+func (s *smoothedParam) set(v float32) {
+	s.target = v
+	if s.coeff >= 1 {
+		s.current = v
+	}
+}
+
+// jump moves to v without gliding.
+func (s *smoothedParam) jump(v float32) {
+	s.target = v
+	s.current = v
+}
+
+func (s *smoothedParam) settled() bool {
+	return s.current == s.target
+}
+
+// next advances the glide by one sample and returns the new value.
+func (s *smoothedParam) next() float32 {
+	if s.current == s.target {
+		return s.current
+	}
+	prev := s.current
+	s.current += (s.target - s.current) * s.coeff
+	s.snap(prev)
+	return s.current
+}
+
+// advance glides by frames samples at once, for controls that are only read
+// per block or per event.
+func (s *smoothedParam) advance(frames int) float32 {
+	if s.current == s.target || frames <= 0 {
+		return s.current
+	}
+	k := float32(1.0 - math.Pow(1.0-float64(s.coeff), float64(frames)))
+	prev := s.current
+	s.current += (s.target - s.current) * k
+	s.snap(prev)
+	return s.current
+}
+
+// snap lands on the target once the remaining distance is negligible or a
+// step no longer moves the float32 value.
+func (s *smoothedParam) snap(prev float32) {
+	if d := s.target - s.current; s.current == prev || (d < 1e-6 && d > -1e-6) {
+		s.current = s.target
+	}
+}
@@ -0,0 +1,106 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSmoothedParamGlidesAndSettlesExactly(t *testing.T) {
+	s := newSmoothedParam(48000, 10, 0)
+	s.set(1)
+	first := s.next()
+	if first <= 0 || first >= 0.01 {
+		t.Fatalf("expected a small first step, got %f", first)
+	}
+	// 10 ms at 48 kHz: after 20 time constants the glide must have snapped.
+	for i := 0; i < 48000/5; i++ {
+		s.next()
+	}
+	if !s.settled() || s.current != 1 {
+		t.Fatalf("expected glide to settle exactly on the target, got %v", s.current)
+	}
+
+	blockwise := newSmoothedParam(48000, 10, 0)
+	blockwise.set(1)
+	perSample := newSmoothedParam(48000, 10, 0)
+	perSample.set(1)
+	for i := 0; i < 256; i++ {
+		perSample.next()
+	}
+	if d := math.Abs(float64(blockwise.advance(256) - perSample.current)); d > 1e-4 {
+		t.Fatalf("expected advance to match per-sample glide, diff %e", d)
+	}
+
+	immediate := newSmoothedParam(48000, 0, 0)
+	immediate.set(0.5)
+	if immediate.current != 0.5 {
+		t.Fatalf("expected zero smoothing time to apply immediately, got %f", immediate.current)
+	}
+}
+
+func TestOutputGainChangeIsSmoothed(t *testing.T) {
+	render := func(ms float32) []float32 {
+		params := NewDefaultParams()
+		params.ControlSmoothing.OutputGainMs = ms
+		p := NewPiano(48000, 16, params)
+		p.NoteOn(60, 100)
+		// End on an internal block boundary so the change lands at the
+		// start of the next block.
+		_ = p.Process(38 * internalBlockSize)
+		p.SetOutputGain(0.1)
+		return p.Process(256)
+	}
+
+	// Both renders differ only in gain, so their ratio traces the glide.
+	smooth := render(20)
+	hard := render(0)
+	head := stereoRMS(smooth[:64]) / stereoRMS(hard[:64])
+	tail := stereoRMS(smooth[len(smooth)-64:]) / stereoRMS(hard[len(hard)-64:])
+	if head < 8 {
+		t.Fatalf("expected the block start to keep most of the old gain, ratio %f", head)
+	}
+	if tail >= head || tail <= 1 {
+		t.Fatalf("expected the gain to glide down: head ratio %f tail ratio %f", head, tail)
+	}
+}
+
+func TestSoftPedalAmountGlidesIntoStrikes(t *testing.T) {
+	params := NewDefaultParams()
+	h := NewHammerExciter(48000, params)
+	h.SetSoftPedalAmount(1)
+	h.Trigger(60, 100)
+	early := h.active[60][len(h.active[60])-1].strikePos
+
+	h.advanceControls(48000)
+	h.Trigger(60, 100)
+	late := h.active[60][len(h.active[60])-1].strikePos
+
+	h.SetSoftPedal(true)
+	h.Trigger(60, 100)
+	full := h.active[60][len(h.active[60])-1].strikePos
+
+	if early >= late {
+		t.Fatalf("expected the strike to shift as the pedal glides down: early=%f late=%f", early, late)
+	}
+	if late != full {
+		t.Fatalf("expected a settled glide to match the switched pedal: glide=%f switch=%f", late, full)
+	}
+}
+
+func TestCouplingAmountScalesAtRuntime(t *testing.T) {
+	render := func(amount float32) float64 {
+		params := NewDefaultParams()
+		params.ControlSmoothing.CouplingAmountMs = 0
+		p := NewPiano(48000, 16, params)
+		p.SetSustainPedal(true)
+		p.SetCouplingAmount(amount)
+		p.NoteOn(48, 120)
+		_ = p.Process(9600)
+		return voiceInternalEnergy(p.ringing.bank.groups[60])
+	}
+	full := render(1)
+	off := render(0)
+	if off >= 0.5*full {
+		t.Fatalf("expected runtime coupling amount 0 to reduce sympathetic energy: full=%e off=%e", full, off)
+	}
+}
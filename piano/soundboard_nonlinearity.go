@@ -0,0 +1,82 @@
+package piano
+
+import "math"
+
+// DefaultSoundboardStressLevel is the string level at which the
+// soundboard nonlinearity is half engaged.
+const DefaultSoundboardStressLevel = float32(0.5)
+
+const (
+	soundboardNLAttackMs  = 1.0
+	soundboardNLReleaseMs = 60.0
+	soundboardNLSplitHz   = 1200.0
+)
+
+// soundboardNonlinearity is a mild level-dependent stage between the
+// strings and the body/room IRs. A hard-played piano sounds brighter and
+// denser than a soft one turned up, because the soundboard and bridge do
+// not respond linearly to large string forces; convolution alone keeps the
+// tone the same at every level. A peak follower of the string signal sets
+// the drive: it lifts the highs above soundboardNLSplitHz (a level-dependent
+// spectral tilt) and softly compresses peaks.
+type soundboardNonlinearity struct {
+	amount float32
+	level  float32 // half-drive level
+
+	env          float32
+	attackCoeff  float32
+	releaseCoeff float32
+	lp           float32
+	lpCoeff      float32
+}
+
+// newSoundboardNonlinearity builds the stage from
+// Params.SoundboardNonlinearity; nil is returned when it is off.
+func newSoundboardNonlinearity(sampleRate int, params *Params) *soundboardNonlinearity {
+	if sampleRate <= 0 || params == nil || params.SoundboardNonlinearity <= 0 {
+		return nil
+	}
+	level := params.SoundboardStressLevel
+	if level <= 0 {
+		level = DefaultSoundboardStressLevel
+	}
+	coeff := func(ms float64) float32 {
+		return float32(1.0 - math.Exp(-1.0/(ms*0.001*float64(sampleRate))))
+	}
+	return &soundboardNonlinearity{
+		amount:       min(params.SoundboardNonlinearity, 1),
+		level:        level,
+		attackCoeff:  coeff(soundboardNLAttackMs),
+		releaseCoeff: coeff(soundboardNLReleaseMs),
+		lpCoeff:      float32(1.0 - math.Exp(-2.0*math.Pi*soundboardNLSplitHz/float64(sampleRate))),
+	}
+}
+
+// process applies the stage to a mono block in place.
+func (s *soundboardNonlinearity) process(buf []float32) {
+	if s == nil {
+		return
+	}
+	for i, x := range buf {
+		a := x
+		if a < 0 {
+			a = -a
+		}
+		if a > s.env {
+			s.env += (a - s.env) * s.attackCoeff
+		} else {
+			s.env += (a - s.env) * s.releaseCoeff
+		}
+		s.env = flushDenormal(s.env)
+		drive := s.amount * s.env / (s.env + s.level)
+
+		s.lp = flushDenormal(s.lp + (x-s.lp)*s.lpCoeff)
+		y := x + drive*(x-s.lp)
+
+		ay := y
+		if ay < 0 {
+			ay = -ay
+		}
+		buf[i] = y / (1 + 0.5*drive*ay/s.level)
+	}
+}
@@ -0,0 +1,60 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSoundboardNonlinearityOffBypasses(t *testing.T) {
+	if s := newSoundboardNonlinearity(48000, NewDefaultParams()); s != nil {
+		t.Fatalf("expected nil stage with soundboard_nonlinearity 0")
+	}
+	var s *soundboardNonlinearity
+	buf := []float32{0.5, -0.5}
+	s.process(buf)
+	if buf[0] != 0.5 || buf[1] != -0.5 {
+		t.Fatalf("expected nil stage to pass through, got %v", buf)
+	}
+}
+
+func TestSoundboardNonlinearityBrightensLoudSignals(t *testing.T) {
+	const sampleRate = 48000
+	const n = 4800 // 10 Hz bins
+	params := NewDefaultParams()
+	params.SoundboardNonlinearity = 1
+
+	// Ratio of a 4 kHz to a 200 Hz partial after the stage.
+	tilt := func(amp float64) float64 {
+		s := newSoundboardNonlinearity(sampleRate, params)
+		buf := make([]float32, 2*n)
+		for i := range buf {
+			ph := 2 * math.Pi * float64(i) / sampleRate
+			buf[i] = float32(amp * (math.Sin(200*ph) + 0.2*math.Sin(4000*ph)))
+		}
+		s.process(buf)
+		steady := buf[n:]
+		return dftBinMagnitude(steady, 400) / dftBinMagnitude(steady, 20)
+	}
+
+	soft, loud := tilt(0.01), tilt(1)
+	if math.Abs(soft-0.2) > 0.01 {
+		t.Fatalf("soft 4 kHz/200 Hz ratio = %.4f, want ~0.2 (nearly linear)", soft)
+	}
+	if !(loud > 1.2*soft) {
+		t.Fatalf("loud 4 kHz/200 Hz ratio = %.4f, soft = %.4f; want brighter when loud", loud, soft)
+	}
+}
+
+func TestPianoSoundboardNonlinearityChangesRender(t *testing.T) {
+	render := func(params *Params) []float32 {
+		p := NewPiano(48000, 16, params)
+		p.NoteOn(60, 120)
+		return p.Process(4096)
+	}
+	base := render(NewDefaultParams())
+	driven := NewDefaultParams()
+	driven.SoundboardNonlinearity = 1
+	if d := maxAbsDiff(render(driven), base); d == 0 {
+		t.Fatalf("expected soundboard nonlinearity to change the render")
+	}
+}
@@ -1,7 +1,5 @@
 package piano
 
-import dspcore "github.com/cwbudde/algo-dsp/dsp/core"
-
 // StringWaveguide implements the digital waveguide string model.
 type StringWaveguide struct {
 	sampleRate  float32
@@ -13,7 +11,7 @@ type StringWaveguide struct {
 	reflection       float32
 	baseReflection   float32
 	damperReflection float32
-	damperEngaged    bool
+	damperAmount     float32 // 0 = lifted, 1 = fully resting on the string
 
 	lowpassCoeff float32
 	loopState    float32
@@ -23,6 +21,9 @@ type StringWaveguide struct {
 	dispersionY1    float32
 	dispersionX2    float32
 	dispersionY2    float32
+
+	prep  *stringPreparation
+	touch stringPreparation // node touch of a harmonic strike
 }
 
 // NewStringWaveguide creates a new string waveguide.
@@ -32,8 +33,7 @@ func NewStringWaveguide(sampleRate int, f0 float32) *StringWaveguide {
 		f0:               f0,
 		reflection:       0.9999,
 		baseReflection:   0.9999,
-		damperReflection: 0.92,
-		damperEngaged:    false,
+		damperReflection: DefaultDamperReflection,
 		lowpassCoeff:     0.0,
 		dispersionCoeff:  0.0,
 	}
@@ -51,6 +51,12 @@ func NewStringWaveguide(sampleRate int, f0 float32) *StringWaveguide {
 // Process renders one sample from the string and advances the simulation.
 func (s *StringWaveguide) Process() float32 {
 	delayedSample := s.readDelayFractional(s.delayLength)
+	if s.prep != nil {
+		delayedSample = s.prep.process(s, delayedSample)
+	}
+	if s.touch.nodeMix > 0 {
+		delayedSample = s.touch.process(s, delayedSample)
+	}
 	dispersed := s.processDispersion(delayedSample)
 	loopSample := s.processLoopLoss(dispersed)
 	output := delayedSample
@@ -74,7 +80,7 @@ func (s *StringWaveguide) ExciteAtPosition(force float32, strikePos float32) {
 		strikePos = 0.99
 	}
 
-	basePos := (s.writePos + int(float32(len(s.delayLine))*strikePos)) % len(s.delayLine)
+	basePos := (s.writePos + s.injectOffset(strikePos)) % len(s.delayLine)
 	width := int(float32(len(s.delayLine)) * (0.04 + 0.22*strikePos))
 	if width < 4 {
 		width = 4
@@ -98,10 +104,22 @@ func (s *StringWaveguide) InjectForceAtPosition(force float32, strikePos float32
 	if strikePos > 0.99 {
 		strikePos = 0.99
 	}
-	pos := (s.writePos + int(float32(len(s.delayLine))*strikePos)) % len(s.delayLine)
+	pos := (s.writePos + s.injectOffset(strikePos)) % len(s.delayLine)
 	s.delayLine[pos] += force
 }
 
+// injectOffset maps a fractional string position to a slot ahead of the
+// write pointer. Slots the write pointer reaches before the read pointer are
+// overwritten unheard; on short treble strings the plain mapping lands there
+// for typical strike positions, so the offset is kept inside the live loop.
+func (s *StringWaveguide) injectOffset(strikePos float32) int {
+	off := int(float32(len(s.delayLine)) * strikePos)
+	if dead := len(s.delayLine) - int(s.delayLength) - 1; off < dead {
+		off = dead
+	}
+	return off
+}
+
 // SetLoopLoss configures loop loss.
 func (s *StringWaveguide) SetLoopLoss(gain float32, highFreqDamping float32) {
 	if gain <= 0 {
@@ -116,22 +134,101 @@ func (s *StringWaveguide) SetLoopLoss(gain float32, highFreqDamping float32) {
 	if highFreqDamping > 0.99 {
 		highFreqDamping = 0.99
 	}
-	s.reflection = gain
 	s.baseReflection = gain
-	if s.damperEngaged {
-		s.reflection = s.damperReflection
-	}
+	s.applyDamper()
 	s.lowpassCoeff = highFreqDamping
 }
 
+// setFrequency moves the string to a new construction pitch. The delay
+// line keeps its contents and, within its capacity, its storage; it is
+// resized to the length NewStringWaveguide would give it, since strike
+// positions are mapped onto that length.
+func (s *StringWaveguide) setFrequency(f0 float32) {
+	s.f0 = f0
+	s.delayLength = s.sampleRate / s.f0
+	n := max(int(s.delayLength), 2) + 4
+	if n == len(s.delayLine) {
+		return
+	}
+	if n <= cap(s.delayLine) {
+		old := len(s.delayLine)
+		s.delayLine = s.delayLine[:n]
+		clear(s.delayLine[min(old, n):])
+	} else {
+		grown := make([]float32, n)
+		copy(grown, s.delayLine)
+		s.delayLine = grown
+	}
+	s.writePos %= n
+}
+
+// reset silences the string and lifts a harmonic touch, as on a freshly
+// built string.
+func (s *StringWaveguide) reset() {
+	clear(s.delayLine)
+	s.writePos = 0
+	s.loopState = 0
+	s.dispersionX1, s.dispersionY1 = 0, 0
+	s.dispersionX2, s.dispersionY2 = 0, 0
+	if s.prep != nil {
+		s.prep.reset()
+	}
+	s.touch = stringPreparation{}
+}
+
+// SetTuningOffset detunes the string by cents relative to its construction pitch.
+// Flat offsets are limited by the delay-line headroom allocated at construction.
+func (s *StringWaveguide) SetTuningOffset(cents float32) {
+	if cents == 0 {
+		s.delayLength = s.sampleRate / s.f0
+		return
+	}
+	d := s.sampleRate / (s.f0 * centsToRatio(cents))
+	if maxDelay := float32(len(s.delayLine) - 2); d > maxDelay {
+		d = maxDelay
+	}
+	if d < 2 {
+		d = 2
+	}
+	s.delayLength = d
+}
+
 // SetDamper toggles aggressive damping for release behavior.
 func (s *StringWaveguide) SetDamper(engaged bool) {
-	s.damperEngaged = engaged
 	if engaged {
-		s.reflection = s.damperReflection
+		s.setDamperAmount(1)
 		return
 	}
-	s.reflection = s.baseReflection
+	s.setDamperAmount(0)
+}
+
+// setDamperAmount blends the loop gain between the undamped (0) and the
+// damped reflection (1), for a damper partly on the string or in motion.
+func (s *StringWaveguide) setDamperAmount(amount float32) {
+	s.damperAmount = amount
+	s.applyDamper()
+}
+
+// setDamperReflection sets the loop gain with the damper resting on the
+// string.
+func (s *StringWaveguide) setDamperReflection(r float32) {
+	s.damperReflection = r
+	s.applyDamper()
+}
+
+func (s *StringWaveguide) applyDamper() {
+	s.reflection = damperMix(s.baseReflection, s.damperReflection, s.damperAmount)
+}
+
+// setPreparations installs prepared-piano treatments; nil or empty removes them.
+func (s *StringWaveguide) setPreparations(preps []Preparation) {
+	s.prep = newStringPreparation(preps)
+}
+
+// setHarmonicTouch lightly damps the string at 1/harmonic of its length;
+// 0 lifts the touch.
+func (s *StringWaveguide) setHarmonicTouch(harmonic int) {
+	s.touch.setNode(harmonic)
 }
 
 // SetDispersion maps a small inharmonicity amount [0,1] to allpass coefficient.
@@ -147,9 +244,9 @@ func (s *StringWaveguide) SetDispersion(amount float32) {
 
 func (s *StringWaveguide) processLoopLoss(input float32) float32 {
 	lp := (1.0-s.lowpassCoeff)*input + s.lowpassCoeff*s.loopState
-	lp = float32(dspcore.FlushDenormals(float64(lp)))
+	lp = flushDenormal(lp)
 	s.loopState = lp
-	return float32(dspcore.FlushDenormals(float64(lp * s.reflection)))
+	return flushDenormal(lp * s.reflection)
 }
 
 func (s *StringWaveguide) processDispersion(input float32) float32 {
@@ -213,3 +213,18 @@ func TestUnisonDetuneProducesBeating(t *testing.T) {
 		t.Fatalf("expected larger peak separation for detuned unison: unison=%.2fHz reference=%.2fHz", uSep, rSep)
 	}
 }
+
+func TestShortStringKeepsInjectedForce(t *testing.T) {
+	// C8 at 44.1 kHz has a loop of only ~10.5 samples.
+	s := NewStringWaveguide(44100, 4186.0)
+	s.SetLoopLoss(0.9998, 0.05)
+	s.InjectForceAtPosition(1.0, 0.18)
+	var energy float64
+	for i := 0; i < 64; i++ {
+		v := float64(s.Process())
+		energy += v * v
+	}
+	if energy == 0 {
+		t.Fatalf("expected injected force to reach the output of a short string")
+	}
+}
@@ -0,0 +1,49 @@
+package piano
+
+import "math"
+
+const (
+	// tailSilence is the "true silence" level of FlushTail: below the LSB
+	// of 24-bit output.
+	tailSilence = 1.0 / (1 << 24)
+	// tailBlock is the render block of FlushTail and tailHoldBlocks how
+	// many consecutive silent blocks end it, so a partitioned convolver
+	// that has not yet delivered its last partition is not cut.
+	tailBlock      = 128
+	tailHoldBlocks = 16
+)
+
+// FlushTail renders what is left of the current sound, typically the string
+// release and the body and room convolution tail after the last NoteOff, and
+// returns it as interleaved stereo. Rendering ends once no voice is active and the
+// output has stayed below the LSB of 24-bit audio for a short hold; the
+// trailing silence is trimmed, so the result ends at the last audible
+// sample. Held keys and pedal-sustained notes keep ringing, so release them
+// first; the render never exceeds maxSeconds.
+func (p *Piano) FlushTail(maxSeconds float64) []float32 {
+	maxFrames := int(maxSeconds * float64(p.sampleRate))
+	var out []float32
+	last := 0 // interleaved length up to the last audible sample
+	quiet := 0
+	for frames := 0; frames < maxFrames && quiet < tailHoldBlocks; {
+		n := min(tailBlock, maxFrames-frames)
+		block := p.Process(n)
+		silent := true
+		for i, v := range block {
+			if math.Abs(float64(v)) >= tailSilence {
+				silent = false
+				last = len(out) + i + 1
+			}
+		}
+		out = append(out, block...)
+		frames += n
+		if silent && p.ActiveVoices() == 0 {
+			quiet++
+		} else {
+			quiet = 0
+		}
+	}
+	// Keep whole stereo frames.
+	last += last % 2
+	return out[:last]
+}
@@ -0,0 +1,53 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFlushTailEndsAtTrueSilence(t *testing.T) {
+	const sr = 48000
+	params := NewDefaultParams()
+	params.RoomWetMix = 0.5
+	p := NewPiano(sr, 16, params)
+	// A 0.4 s room tail (-120 dB at its end) rings on after the strings.
+	ir := make([]float32, 2*sr/5)
+	for i := range ir {
+		ir[i] = 0.05 * float32(math.Pow(10, -6*float64(i)/float64(len(ir)))*math.Sin(float64(i)*1.3))
+	}
+	p.SetRoomIR(ir, ir)
+	p.NoteOn(60, 100)
+	_ = p.Process(sr / 10)
+	p.NoteOff(60)
+
+	tail := p.FlushTail(10)
+	if len(tail) == 0 || len(tail)%2 != 0 {
+		t.Fatalf("expected a non-empty stereo tail, got %d samples", len(tail))
+	}
+	if frames := len(tail) / 2; frames < sr/10 || frames >= 10*sr {
+		t.Fatalf("tail of %d frames should carry the room IR and end before the limit", frames)
+	}
+	end := math.Max(math.Abs(float64(tail[len(tail)-2])), math.Abs(float64(tail[len(tail)-1])))
+	if end < tailSilence {
+		t.Fatalf("trailing silence was not trimmed (last frame %g)", end)
+	}
+	// Nothing audible is left after the flush.
+	for i, v := range p.Process(sr / 2) {
+		if math.Abs(float64(v)) >= tailSilence {
+			t.Fatalf("sample %d after the flush is audible: %g", i, v)
+		}
+	}
+}
+
+func TestFlushTailStopsAtMaxSecondsWhileNotesRing(t *testing.T) {
+	const sr = 48000
+	p := NewPiano(sr, 16, NewDefaultParams())
+	p.NoteOn(60, 100) // held
+	tail := p.FlushTail(0.25)
+	if got := len(tail) / 2; got != sr/4 {
+		t.Fatalf("held note must render up to maxSeconds: got %d frames, want %d", got, sr/4)
+	}
+	if p.ActiveVoices() == 0 {
+		t.Fatal("FlushTail must not release held notes")
+	}
+}
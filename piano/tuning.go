@@ -0,0 +1,222 @@
+package piano
+
+import "math"
+
+// noteFrequency returns the nominal string frequency of note: the preset's
+// per-note F0 when set, equal temperament at A4 = 440 Hz otherwise.
+func noteFrequency(note int, params *Params) float32 {
+	if params != nil {
+		if np, ok := params.PerNote[note]; ok && np != nil && np.F0 > 0 {
+			return np.F0
+		}
+	}
+	return midiNoteToFreq(note)
+}
+
+// NotePartials returns the frequencies in Hz of up to n partials of note as
+// the preset's string model renders them at sampleRate. Unison detune,
+// humanization and tuning drift are left out, so this is the tuning of the
+// note as a technician would set it. Partials at or above Nyquist (or beyond
+// the modal model's partial count) are omitted.
+func NotePartials(sampleRate int, params *Params, note int, n int) []float64 {
+	if sampleRate <= 0 || n <= 0 {
+		return nil
+	}
+	if params == nil {
+		params = NewDefaultParams()
+	}
+	nominal := *params
+	nominal.UnisonDetuneScale = 0
+
+	if params.StringModel == StringModelModal {
+		g := newModalStringGroup(sampleRate, note, &nominal)
+		out := make([]float64, 0, n)
+		for _, m := range g.strings[0].modes {
+			if len(out) == n {
+				break
+			}
+			out = append(out, float64(m.freq))
+		}
+		return out
+	}
+	g := newRingingStringGroup(sampleRate, note, &nominal)
+	return g.strings[0].partialFrequencies(n)
+}
+
+// StringImpulseResponse returns frames samples of one string of note, as
+// the preset's string model builds it at sampleRate, after a unit impulse
+// at strikePos (fraction of the string length) with the damper lifted. As
+// in NotePartials, unison detune is left out; the other unison strings and
+// the hammer, coupling and resonance paths are not involved.
+func StringImpulseResponse(sampleRate int, params *Params, note int, strikePos float32, frames int) []float32 {
+	if sampleRate <= 0 || frames <= 0 {
+		return nil
+	}
+	if params == nil {
+		params = NewDefaultParams()
+	}
+	nominal := *params
+	nominal.UnisonDetuneScale = 0
+
+	var g ringingGroup
+	if params.StringModel == StringModelModal {
+		mg := newModalStringGroup(sampleRate, note, &nominal)
+		mg.strings = mg.strings[:1]
+		mg.gains = []float32{1}
+		g = mg
+	} else {
+		rg := newRingingStringGroup(sampleRate, note, &nominal)
+		rg.strings = rg.strings[:1]
+		rg.gains = []float32{1}
+		g = rg
+	}
+	g.setKeyDown(true)
+	g.injectHammerForce(1, strikePos)
+	out := make([]float32, frames)
+	for i := range out {
+		out[i] = g.processSample(0)
+	}
+	return out
+}
+
+// partialFrequencies solves the loop phase condition of the waveguide for
+// its first n resonances: the delay line, the fractional-delay
+// interpolation, both dispersion allpasses and the loss lowpass together
+// must turn by a multiple of 2*pi.
+func (s *StringWaveguide) partialFrequencies(n int) []float64 {
+	intDelay := int(s.delayLength)
+	frac := float64(s.delayLength) - float64(intDelay)
+	a := float64(s.dispersionCoeff)
+	c := float64(s.lowpassCoeff)
+	phase := func(w float64) float64 {
+		sw, cw := math.Sin(w), math.Cos(w)
+		interp := math.Atan2(-frac*sw, 1-frac+frac*cw)
+		// (-a + e^-jw) / (1 - a e^-jw); bypassed when a == 0.
+		ap := 0.0
+		if a != 0 {
+			ap = math.Atan2(-sw, cw-a) - math.Atan2(a*sw, 1-a*cw)
+		}
+		lp := -math.Atan2(c*sw, 1-c*cw)
+		return -w*float64(intDelay) + interp + 2*ap + lp
+	}
+
+	out := make([]float64, 0, n)
+	for k := 1; k <= n; k++ {
+		target := -2 * math.Pi * float64(k)
+		lo, hi := 0.0, math.Pi
+		if phase(hi) > target {
+			break
+		}
+		for i := 0; i < 60; i++ {
+			mid := 0.5 * (lo + hi)
+			if phase(mid) > target {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		if hi == math.Pi {
+			break // the phase only reaches target at Nyquist
+		}
+		out = append(out, 0.5*(lo+hi)*float64(s.sampleRate)/(2*math.Pi))
+	}
+	return out
+}
+
+// dispersionSearchSteps is how many dispersion amounts InharmonicityForB
+// scans across [0, 1] before refining a bracket.
+const dispersionSearchSteps = 40
+
+// InharmonicityForB returns the NoteParams.Inharmonicity at which the
+// string model of note renders the partial stretch of stiffness
+// coefficient b, as in f_n = n*f0*sqrt(1 + b*n^2). Negative b is treated
+// as 0. The modal model maps b in closed form. The waveguide is inverted
+// numerically through its partial frequencies; its two dispersion
+// allpasses reach only a narrow range of B (mostly in the top octaves),
+// and ok is false when b lies outside it.
+func InharmonicityForB(sampleRate int, params *Params, note int, b float64) (amount float32, ok bool) {
+	if params == nil {
+		params = NewDefaultParams()
+	}
+	b = math.Max(b, 0)
+	if noteStringModel(params, note, params.StringModel) == StringModelModal {
+		return float32(b / modalInharmonicityScale), true
+	}
+	if sampleRate <= 0 {
+		return 0, false
+	}
+
+	nominal := *params
+	nominal.UnisonDetuneScale = 0
+	nominal.PerNote = make(map[int]*NoteParams, len(params.PerNote)+1)
+	for k, v := range params.PerNote {
+		nominal.PerNote[k] = v
+	}
+	np := NoteParams{Loss: 0.9990, StrikePosition: 0.18}
+	if cur := params.PerNote[note]; cur != nil {
+		np = *cur
+	}
+	// miss is the measured B at amount minus b; NaN when too few partials
+	// fit below Nyquist.
+	miss := func(amount float32) float64 {
+		np.Inharmonicity = amount
+		nominal.PerNote[note] = &np
+		g := newRingingStringGroup(sampleRate, note, &nominal)
+		return partialStretchB(g.strings[0].partialFrequencies(stretchPartials)) - b
+	}
+
+	lo := float32(0)
+	mlo := miss(lo)
+	if math.IsNaN(mlo) {
+		return 0, false
+	}
+	if mlo == 0 {
+		return 0, true
+	}
+	for i := 1; i <= dispersionSearchSteps; i++ {
+		hi := float32(i) / dispersionSearchSteps
+		mhi := miss(hi)
+		if mhi == 0 {
+			return hi, true
+		}
+		if (mlo < 0) != (mhi < 0) {
+			for j := 0; j < 30; j++ {
+				mid := 0.5 * (lo + hi)
+				if m := miss(mid); (m < 0) == (mlo < 0) {
+					lo, mlo = mid, m
+				} else {
+					hi = mid
+				}
+			}
+			return 0.5 * (lo + hi), true
+		}
+		lo, mlo = hi, mhi
+	}
+	return 0, false
+}
+
+// stretchPartials is how many partials partialStretchB fits, as many as
+// analysis.EstimateInharmonicity uses on a recording.
+const stretchPartials = 16
+
+// partialStretchB fits the stiffness coefficient B of
+// f_k = k*f1*sqrt(1 + B*k^2) to partials (the k-th entry is partial k+1)
+// by regressing (f_k/k)^2 on k^2, like analysis.EstimateInharmonicity.
+// It returns NaN for fewer than three partials.
+func partialStretchB(partials []float64) float64 {
+	if len(partials) < 3 {
+		return math.NaN()
+	}
+	var sx, sy, sxx, sxy float64
+	for i, f := range partials {
+		k := float64(i + 1)
+		v := f / k
+		sx += k * k
+		sy += v * v
+		sxx += k * k * k * k
+		sxy += k * k * v * v
+	}
+	n := float64(len(partials))
+	slope := (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	return slope / ((sy - slope*sx) / n)
+}
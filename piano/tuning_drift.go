@@ -0,0 +1,136 @@
+package piano
+
+import "math"
+
+const (
+	tuningDriftUpdateSec = 0.01
+	// tuningDriftMinStepCents skips retuning groups for inaudible changes.
+	tuningDriftMinStepCents = float32(0.01)
+
+	defaultTuningDriftTimeSec          = float32(60)
+	defaultTuningDriftCorrelationKeys  = float32(4)
+	maxTuningDriftCorrelationRadiusKey = 24
+)
+
+// tuningDrift simulates an instrument slowly going out of tune: every key
+// follows a mean-reverting random walk (Ornstein-Uhlenbeck) whose offsets
+// are smoothed across neighbouring keys with a Gaussian kernel, so nearby
+// notes drift together. The walk starts from its stationary distribution,
+// i.e. the piano is already imperfectly tuned at t=0. The standard deviation
+// is half of TuningDriftCents and offsets are limited to ±TuningDriftCents.
+// The stream is seeded from Params.Seed, so renders stay reproducible.
+type tuningDrift struct {
+	maxCents float32
+	a, b     float64   // per-update walk coefficients
+	kernel   []float64 // neighbour weights with unit sum of squares
+	walk     []float64 // unit-variance walks for notes -radius..127+radius
+	offsets  [128]float32
+
+	rng      uint32
+	interval int
+	pending  int
+}
+
+func newTuningDrift(sampleRate int, params *Params) *tuningDrift {
+	d := &tuningDrift{rng: 1}
+	if params == nil || params.TuningDriftCents <= 0 || sampleRate <= 0 {
+		return d
+	}
+	timeSec := params.TuningDriftTimeSec
+	if timeSec <= 0 {
+		timeSec = defaultTuningDriftTimeSec
+	}
+	corrKeys := params.TuningDriftCorrelationKeys
+	if corrKeys < 0 {
+		corrKeys = defaultTuningDriftCorrelationKeys
+	}
+
+	d.maxCents = params.TuningDriftCents
+	d.interval = maxInt(1, int(tuningDriftUpdateSec*float64(sampleRate)))
+	dt := float64(d.interval) / float64(sampleRate)
+	d.a = math.Exp(-dt / float64(timeSec))
+	d.b = math.Sqrt(1 - d.a*d.a)
+
+	radius := int(math.Ceil(3 * float64(corrKeys)))
+	if radius > maxTuningDriftCorrelationRadiusKey {
+		radius = maxTuningDriftCorrelationRadiusKey
+	}
+	d.kernel = make([]float64, 2*radius+1)
+	var sumSq float64
+	for k := range d.kernel {
+		w := 1.0
+		if corrKeys > 0 {
+			x := float64(k-radius) / float64(corrKeys)
+			w = math.Exp(-0.5 * x * x)
+		}
+		d.kernel[k] = w
+		sumSq += w * w
+	}
+	norm := 1 / math.Sqrt(sumSq)
+	for k := range d.kernel {
+		d.kernel[k] *= norm
+	}
+
+	d.rng = strikeNoiseSeed(params.Seed, 0x3c, 0xc3)
+	d.walk = make([]float64, 128+2*radius)
+	for i := range d.walk {
+		d.walk[i] = d.gaussian()
+	}
+	d.updateOffsets()
+	return d
+}
+
+func (d *tuningDrift) enabled() bool {
+	return d != nil && d.maxCents > 0
+}
+
+// gaussian returns a standard normal draw (Box-Muller).
+func (d *tuningDrift) gaussian() float64 {
+	u1 := (float64(xorshift32(&d.rng)) + 1) / 4294967296.0
+	u2 := float64(xorshift32(&d.rng)) / 4294967296.0
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+func (d *tuningDrift) step() {
+	for i := range d.walk {
+		d.walk[i] = d.a*d.walk[i] + d.b*d.gaussian()
+	}
+	d.updateOffsets()
+}
+
+func (d *tuningDrift) updateOffsets() {
+	half := 0.5 * float64(d.maxCents)
+	for note := range d.offsets {
+		var y float64
+		for k, w := range d.kernel {
+			y += w * d.walk[note+k]
+		}
+		d.offsets[note] = clampf(float32(y*half), -d.maxCents, d.maxCents)
+	}
+}
+
+// advance moves the walk forward by frames and retunes the string groups.
+func (d *tuningDrift) advance(frames int, ringing *RingingState) {
+	if !d.enabled() {
+		return
+	}
+	d.pending += frames
+	if d.pending < d.interval {
+		return
+	}
+	for d.pending >= d.interval {
+		d.pending -= d.interval
+		d.step()
+	}
+	d.apply(ringing)
+}
+
+// apply retunes every string group to the current offsets.
+func (d *tuningDrift) apply(ringing *RingingState) {
+	if !d.enabled() {
+		return
+	}
+	for note := range d.offsets {
+		ringing.SetTuningDrift(note, d.offsets[note])
+	}
+}
@@ -0,0 +1,113 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTuningDriftOffByDefault(t *testing.T) {
+	p := NewPiano(48000, 16, NewDefaultParams())
+	p.NoteOn(60, 100)
+	p.Process(4800)
+	if p.tuningDrift.enabled() {
+		t.Fatalf("tuning drift enabled by default")
+	}
+	for _, s := range p.ringing.bank.Group(60).strings {
+		if s.delayLength != s.sampleRate/s.f0 {
+			t.Fatalf("default render detuned string: delay %f, want %f", s.delayLength, s.sampleRate/s.f0)
+		}
+	}
+}
+
+func TestTuningDriftIsBoundedAndCorrelatedAcrossKeys(t *testing.T) {
+	var nearSum, farSum, sq float64
+	count := 0
+	for seed := int64(1); seed <= 8; seed++ {
+		params := NewDefaultParams()
+		params.Seed = seed
+		params.TuningDriftCents = 8
+		d := newTuningDrift(48000, params)
+		for note := 21; note+12 <= 108; note++ {
+			o := float64(d.offsets[note])
+			if math.Abs(o) > 8 {
+				t.Fatalf("seed %d note %d: offset %.2f cents beyond bound", seed, note, o)
+			}
+			nearSum += math.Abs(o - float64(d.offsets[note+1]))
+			farSum += math.Abs(o - float64(d.offsets[note+12]))
+			sq += o * o
+			count++
+		}
+	}
+	if std := math.Sqrt(sq / float64(count)); std < 2 || std > 6 {
+		t.Fatalf("offset spread %.2f cents, want about half the bound", std)
+	}
+	if near, far := nearSum/float64(count), farSum/float64(count); near > 0.5*far {
+		t.Fatalf("neighbouring keys not correlated: near diff %.2f, octave diff %.2f", near, far)
+	}
+}
+
+func TestTuningDriftWalksOverTimeAndIsReproducible(t *testing.T) {
+	render := func() ([]float32, float32, float32) {
+		params := NewDefaultParams()
+		params.Seed = 9
+		params.TuningDriftCents = 10
+		params.TuningDriftTimeSec = 0.5
+		p := NewPiano(24000, 16, params)
+		start := p.ringing.bank.Group(69).tuningDrift
+		p.SetSustainPedal(true)
+		p.NoteOn(69, 100)
+		out := make([]float32, 0, 2*24000*2)
+		for i := 0; i < 2*24000/128; i++ {
+			out = append(out, p.Process(128)...)
+		}
+		return out, start, p.ringing.bank.Group(69).tuningDrift
+	}
+	a, startA, endA := render()
+	b, startB, endB := render()
+	if startA == 0 {
+		t.Fatalf("instrument starts in tune; expected an initial drift offset")
+	}
+	if startA == endA {
+		t.Fatalf("drift did not move over two seconds")
+	}
+	if startA != startB || endA != endB {
+		t.Fatalf("drift not reproducible: %f/%f vs %f/%f", startA, endA, startB, endB)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("drifting render not reproducible at sample %d", i)
+		}
+	}
+}
+
+func TestTuningDriftAddsToStrikeDetune(t *testing.T) {
+	params := NewDefaultParams()
+	g := newRingingStringGroup(48000, 60, params)
+	if len(g.strings) < 2 {
+		t.Fatalf("expected a unison group for note 60")
+	}
+	g.setDetuneDrift([]float32{1, -1})
+	g.setTuningDrift(5)
+	for i, s := range g.strings {
+		want := float32(5)
+		if i < 2 {
+			want += float32(1 - 2*i)
+		}
+		expected := s.sampleRate / (s.f0 * centsToRatio(want))
+		if math.Abs(float64(s.delayLength-expected)) > 1e-3 {
+			t.Fatalf("string %d: delay %f, want %f for %+.0f cents", i, s.delayLength, expected, want)
+		}
+	}
+
+	params.StringModel = StringModelModal
+	m := newModalStringGroup(48000, 69, params)
+	before := m.strings[0].modes[0].cosW
+	m.setTuningDrift(5)
+	if m.strings[0].modes[0].cosW == before {
+		t.Fatalf("tuning drift did not retune modal group")
+	}
+	m.setTuningDrift(0)
+	if m.strings[0].modes[0].cosW != before {
+		t.Fatalf("zero drift did not restore modal tuning")
+	}
+}
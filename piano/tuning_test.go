@@ -0,0 +1,162 @@
+package piano
+
+import (
+	"math"
+	"testing"
+)
+
+// peakFrequency refines the spectral peak of x near guess (Hz) with a
+// Hann-windowed DTFT on a 0.01 Hz grid.
+func peakFrequency(x []float32, sampleRate float64, guess float64) float64 {
+	best, bestMag := guess, -1.0
+	for f := guess - 2; f <= guess+2; f += 0.01 {
+		var re, im float64
+		w := 2 * math.Pi * f / sampleRate
+		for i, v := range x {
+			win := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(x)-1))
+			re += win * float64(v) * math.Cos(w*float64(i))
+			im -= win * float64(v) * math.Sin(w*float64(i))
+		}
+		if mag := re*re + im*im; mag > bestMag {
+			best, bestMag = f, mag
+		}
+	}
+	return best
+}
+
+func TestNotePartialsMatchRenderedWaveguide(t *testing.T) {
+	const sr = 48000
+	params := NewDefaultParams()
+	params.PerNote[45] = &NoteParams{Loss: 0.9999, Inharmonicity: 0.4}
+	partials := NotePartials(sr, params, 45, 3)
+	if len(partials) != 3 {
+		t.Fatalf("got %d partials, want 3", len(partials))
+	}
+	if partials[0] >= 110 {
+		t.Fatalf("dispersion allpasses should flatten the string: %v", partials)
+	}
+
+	nominal := *params
+	nominal.UnisonDetuneScale = 0
+	s := newRingingStringGroup(sr, 45, &nominal).strings[0]
+	s.SetDamper(false)
+	s.Excite(1)
+	x := make([]float32, 1<<15)
+	for i := range x {
+		x[i] = s.Process()
+	}
+	for k, want := range partials {
+		got := peakFrequency(x, sr, want)
+		if cents := 1200 * math.Log2(got/want); math.Abs(cents) > 1 {
+			t.Fatalf("partial %d: rendered %.3f Hz, predicted %.3f Hz (%.2f cents)", k+1, got, want, cents)
+		}
+	}
+}
+
+func TestNotePartialsUsePerNoteF0(t *testing.T) {
+	params := NewDefaultParams()
+	params.PerNote[60] = &NoteParams{F0: 270}
+	params.StringModel = StringModelModal
+	if p := NotePartials(48000, params, 60, 2); len(p) != 2 || math.Abs(p[0]-270) > 1e-3 || math.Abs(p[1]-540) > 1e-3 {
+		t.Fatalf("modal partials with F0 override = %v, want [270 540]", p)
+	}
+	params.StringModel = StringModelDWG
+	if g := newRingingStringGroup(48000, 60, params); g.f0 != 270 {
+		t.Fatalf("DWG group f0 = %f, want 270", g.f0)
+	}
+	if p := NotePartials(48000, NewDefaultParams(), 69, 1); math.Abs(1200*math.Log2(p[0]/440)) > 5 {
+		t.Fatalf("default A4 fundamental %.3f Hz is far from 440 Hz", p[0])
+	}
+}
+
+func TestPerNoteF0MovesRenderedPitch(t *testing.T) {
+	const sr = 48000
+	const note = 60
+	const f0 = 277.18 // C#4 on the C4 key
+	nominal := float64(midiNoteToFreq(note))
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		params := NewDefaultParams()
+		params.StringModel = model
+		params.UnisonDetuneScale = 0
+		render := func() []float32 {
+			p := NewPiano(sr, 4, params)
+			p.NoteOn(note, 100)
+			p.ProcessMono(sr / 4)
+			return p.ProcessMono(sr / 2)
+		}
+		plain := render()
+		base := peakFrequency(plain, sr, NotePartials(sr, params, note, 1)[0])
+		params.PerNote[note] = &NoteParams{F0: f0}
+		got := peakFrequency(render(), sr, base*f0/nominal)
+		if cents := 1200*math.Log2(got/base) - 1200*math.Log2(f0/nominal); math.Abs(cents) > 2 {
+			t.Fatalf("%s: per-note F0 %.2f Hz moved the pitch from %.3f to %.3f Hz (%.2f cents off)", model, f0, base, got, cents)
+		}
+	}
+}
+
+func TestInharmonicityForBMatchesModalStretch(t *testing.T) {
+	const sr = 48000
+	const b = 0.0006
+	params := NewDefaultParams()
+	params.StringModel = StringModelModal
+	amount, ok := InharmonicityForB(sr, params, 48, b)
+	if !ok {
+		t.Fatal("modal model must reach any B")
+	}
+	params.PerNote[48] = &NoteParams{Loss: 0.9995, Inharmonicity: amount}
+
+	f0 := float64(midiNoteToFreq(48))
+	got := NotePartials(sr, params, 48, 8)
+	if len(got) != 8 {
+		t.Fatalf("got %d partials, want 8", len(got))
+	}
+	for i, f := range got {
+		k := float64(i + 1)
+		want := k * f0 * math.Sqrt(1+b*k*k)
+		if math.Abs(f-want) > 1e-3*want {
+			t.Fatalf("partial %d at %.3f Hz, want %.3f", i+1, f, want)
+		}
+	}
+	if v, _ := InharmonicityForB(sr, params, 48, -1e-4); v != 0 {
+		t.Fatal("negative B must map to 0")
+	}
+}
+
+func TestInharmonicityForBInvertsWaveguideDispersion(t *testing.T) {
+	const sr = 48000
+	const note = 100
+	const b = 1e-4
+	params := NewDefaultParams()
+	amount, ok := InharmonicityForB(sr, params, note, b)
+	if !ok || amount <= 0 {
+		t.Fatalf("InharmonicityForB = %v, %t; want a positive dispersion amount", amount, ok)
+	}
+	params.PerNote[note] = &NoteParams{Loss: 0.9990, StrikePosition: 0.18, Inharmonicity: amount}
+	if got := partialStretchB(NotePartials(sr, params, note, stretchPartials)); math.Abs(got-b) > 0.01*b {
+		t.Fatalf("waveguide at dispersion %v stretches with B = %g, want %g", amount, got, b)
+	}
+
+	// A mid-register waveguide barely stretches at any dispersion.
+	if v, ok := InharmonicityForB(sr, NewDefaultParams(), 60, 4e-4); ok {
+		t.Fatalf("B = 4e-4 at note 60 must be out of reach, got %v", v)
+	}
+}
+
+func TestStringImpulseResponseRingsAtNotePartials(t *testing.T) {
+	const sr = 48000
+	for _, model := range []StringModel{StringModelDWG, StringModelModal} {
+		params := NewDefaultParams()
+		params.StringModel = model
+		params.PerNote[57] = &NoteParams{Loss: 0.9999, Inharmonicity: 0.2}
+		ir := StringImpulseResponse(sr, params, 57, 0.13, sr/2)
+		if len(ir) != sr/2 {
+			t.Fatalf("%s: got %d samples, want %d", model, len(ir), sr/2)
+		}
+		want := NotePartials(sr, params, 57, 2)
+		for k, f := range want {
+			if got := peakFrequency(ir, sr, f); math.Abs(got-f) > 0.5 {
+				t.Fatalf("%s: partial %d rings at %.2f Hz, NotePartials says %.2f", model, k+1, got, f)
+			}
+		}
+	}
+}
@@ -19,17 +19,6 @@ func pow2Approx(x float32) float32 {
 	return approx.FastExp(x * ln2)
 }
 
-func defaultUnisonForNote(note int) ([]float32, []float32) {
-	switch {
-	case note < 40:
-		return []float32{0.0}, []float32{1.0}
-	case note < 70:
-		return []float32{-1.8, 1.8}, []float32{0.52, 0.48}
-	default:
-		return []float32{-3.0, 0.0, 3.0}, []float32{0.34, 0.33, 0.33}
-	}
-}
-
 func centsToRatio(cents float32) float32 {
 	return pow2Approx(cents / 1200.0)
 }
@@ -62,6 +51,16 @@ func clampf(x, lo, hi float32) float32 {
 	return x
 }
 
+// flushDenormal zeroes magnitudes below 1e-30, like dspcore.FlushDenormals
+// but without the round trip through float64 in per-sample feedback loops.
+func flushDenormal(x float32) float32 {
+	const epsilon = 1e-30
+	if x > -epsilon && x < epsilon {
+		return 0
+	}
+	return x
+}
+
 func expf(x float32) float32 {
 	return float32(math.Exp(float64(x)))
 }
@@ -0,0 +1,63 @@
+package piano
+
+const (
+	variationMaxVelocity  = float32(8.0)  // MIDI velocity units at VariationAmount=1
+	variationMaxStrikePos = float32(0.03) // fraction of string length at VariationAmount=1
+	variationMaxDetune    = float32(1.5)  // cents per unison string at VariationAmount=1
+)
+
+// strikeVariation draws small per-strike humanization offsets from a stream RNG.
+// The stream is seeded from Params.Seed, so humanized renders stay reproducible.
+type strikeVariation struct {
+	amount float32
+	rng    uint32
+	drift  []float32
+}
+
+// strikeOffsets holds the humanization applied to a single NoteOn.
+type strikeOffsets struct {
+	velocity  int
+	strikePos float32
+	detune    []float32
+}
+
+func newStrikeVariation(params *Params) *strikeVariation {
+	v := &strikeVariation{
+		rng:   1,
+		drift: make([]float32, 0, 4),
+	}
+	if params == nil {
+		return v
+	}
+	v.amount = clampf(params.VariationAmount, 0, 1)
+	v.rng = strikeNoiseSeed(params.Seed, 0x5a, 0xa5)
+	return v
+}
+
+func (v *strikeVariation) enabled() bool {
+	return v != nil && v.amount > 0
+}
+
+// uniform returns the next stream value in [-1, 1].
+func (v *strikeVariation) uniform() float32 {
+	n := xorshift32(&v.rng)
+	return float32(n)*2.3283064e-10*2.0 - 1.0
+}
+
+// next draws offsets for one strike on a group with the given unison string count.
+// The returned detune slice is reused across calls.
+func (v *strikeVariation) next(stringCount int) strikeOffsets {
+	if !v.enabled() {
+		return strikeOffsets{}
+	}
+	out := strikeOffsets{
+		velocity:  int(v.uniform() * v.amount * variationMaxVelocity),
+		strikePos: v.uniform() * v.amount * variationMaxStrikePos,
+	}
+	v.drift = v.drift[:0]
+	for i := 0; i < stringCount; i++ {
+		v.drift = append(v.drift, v.uniform()*v.amount*variationMaxDetune)
+	}
+	out.detune = v.drift
+	return out
+}
@@ -0,0 +1,102 @@
+package piano
+
+import "testing"
+
+func TestVariationAmountZeroLeavesStrikesUntouched(t *testing.T) {
+	params := NewDefaultParams()
+	p := NewPiano(48000, 16, params)
+	p.NoteOn(60, 100)
+	p.NoteOn(60, 100)
+	events := p.hammerExciter.active[60]
+	if len(events) != 2 {
+		t.Fatalf("expected two hammer events, got %d", len(events))
+	}
+	if events[0].strikePos != events[1].strikePos {
+		t.Fatalf("expected identical strike positions without variation: %f vs %f", events[0].strikePos, events[1].strikePos)
+	}
+	if events[0].hammer.vel != events[1].hammer.vel {
+		t.Fatalf("expected identical hammer velocity without variation: %f vs %f", events[0].hammer.vel, events[1].hammer.vel)
+	}
+}
+
+func TestVariationAmountJittersRepeatedStrikes(t *testing.T) {
+	params := NewDefaultParams()
+	params.VariationAmount = 1.0
+	p := NewPiano(48000, 16, params)
+	for i := 0; i < 4; i++ {
+		p.NoteOn(60, 100)
+	}
+	events := p.hammerExciter.active[60]
+	if len(events) != 4 {
+		t.Fatalf("expected four hammer events, got %d", len(events))
+	}
+	distinctPos := false
+	distinctVel := false
+	for _, ev := range events[1:] {
+		if ev.strikePos != events[0].strikePos {
+			distinctPos = true
+		}
+		if ev.hammer.vel != events[0].hammer.vel {
+			distinctVel = true
+		}
+		if d := ev.strikePos - 0.18; d > variationMaxStrikePos+1e-6 || d < -variationMaxStrikePos-1e-6 {
+			t.Fatalf("strike position jitter out of range: %f", ev.strikePos)
+		}
+	}
+	if !distinctPos || !distinctVel {
+		t.Fatalf("expected repeated strikes to vary (pos=%v vel=%v)", distinctPos, distinctVel)
+	}
+
+	g := p.ringing.bank.Group(60)
+	if g == nil {
+		t.Fatalf("missing group for note 60")
+	}
+	drifted := false
+	for _, s := range g.strings {
+		if s.delayLength != s.sampleRate/s.f0 {
+			drifted = true
+		}
+	}
+	if !drifted {
+		t.Fatalf("expected unison detune drift to move string tuning")
+	}
+}
+
+func TestVariationIsReproducibleForSeed(t *testing.T) {
+	render := func() []float32 {
+		params := NewDefaultParams()
+		params.VariationAmount = 0.7
+		params.Seed = 3
+		p := NewPiano(48000, 16, params)
+		out := make([]float32, 0, 4*20*256*2)
+		for strike := 0; strike < 4; strike++ {
+			p.NoteOn(64, 90)
+			for i := 0; i < 20; i++ {
+				out = append(out, p.Process(256)...)
+			}
+		}
+		return out
+	}
+	a := render()
+	b := render()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("humanized render not reproducible at sample %d", i)
+		}
+	}
+}
+
+func TestModalDetuneDriftRetunesModes(t *testing.T) {
+	params := NewDefaultParams()
+	params.StringModel = StringModelModal
+	g := newModalStringGroup(48000, 69, params)
+	before := g.strings[0].modes[0].cosW
+	g.setDetuneDrift([]float32{10, 0, 0})
+	if g.strings[0].modes[0].cosW == before {
+		t.Fatalf("expected drift to change mode rotation")
+	}
+	g.setDetuneDrift(nil)
+	if g.strings[0].modes[0].cosW != before {
+		t.Fatalf("expected zero drift to restore nominal tuning: %f vs %f", g.strings[0].modes[0].cosW, before)
+	}
+}
@@ -14,93 +14,217 @@ import (
 
 // File is the JSON schema for piano presets.
 type File struct {
-	OutputGain *float32 `json:"output_gain"`
+	OutputGain *float32 `json:"output_gain,omitempty"`
+	Seed       *int64   `json:"seed,omitempty"`
+	Keys       *int     `json:"keys,omitempty"`
 	MinNote    *int     `json:"min_note,omitempty"`
 	MaxNote    *int     `json:"max_note,omitempty"`
 	// Legacy single-IR fields.
-	IRWavPath string   `json:"ir_wav_path"`
-	IRWetMix  *float32 `json:"ir_wet_mix"`
-	IRDryMix  *float32 `json:"ir_dry_mix"`
-	IRGain    *float32 `json:"ir_gain"`
+	IRWavPath string   `json:"ir_wav_path,omitempty"`
+	IRWetMix  *float32 `json:"ir_wet_mix,omitempty"`
+	IRDryMix  *float32 `json:"ir_dry_mix,omitempty"`
+	IRGain    *float32 `json:"ir_gain,omitempty"`
 	// Dual-IR fields.
-	BodyIRWavPath string   `json:"body_ir_wav_path,omitempty"`
-	BodyIRGain    *float32 `json:"body_ir_gain,omitempty"`
-	BodyDryMix    *float32 `json:"body_dry_mix,omitempty"`
-	RoomIRWavPath string   `json:"room_ir_wav_path,omitempty"`
-	RoomWetMix    *float32 `json:"room_wet_mix,omitempty"`
-	RoomGain      *float32 `json:"room_gain,omitempty"`
+	BodyIRWavPath       string   `json:"body_ir_wav_path,omitempty"`
+	BodyIRGain          *float32 `json:"body_ir_gain,omitempty"`
+	BodyDryMix          *float32 `json:"body_dry_mix,omitempty"`
+	BodyIRClosedWavPath string   `json:"body_ir_closed_wav_path,omitempty"`
+	LidPosition         *float32 `json:"lid_position,omitempty"`
+	RoomIRWavPath       string   `json:"room_ir_wav_path,omitempty"`
+	RoomWetMix          *float32 `json:"room_wet_mix,omitempty"`
+	RoomGain            *float32 `json:"room_gain,omitempty"`
+	// ConvolutionBackend is auto|overlap-add|direct.
+	ConvolutionBackend *string `json:"convolution_backend,omitempty"`
 
-	ResonanceEnabled           *bool                  `json:"resonance_enabled"`
-	ResonanceGain              *float32               `json:"resonance_gain"`
-	ResonancePerNoteFilter     *bool                  `json:"resonance_per_note_filter"`
-	HammerStiffnessScale       *float32               `json:"hammer_stiffness_scale"`
-	HammerExponentScale        *float32               `json:"hammer_exponent_scale"`
-	HammerDampingScale         *float32               `json:"hammer_damping_scale"`
-	HammerInitialVelocityScale *float32               `json:"hammer_initial_velocity_scale"`
-	HammerContactTimeScale     *float32               `json:"hammer_contact_time_scale"`
-	HighFreqDamping            *float32               `json:"high_freq_damping,omitempty"`
-	UnisonDetuneScale          *float32               `json:"unison_detune_scale"`
-	UnisonCrossfeed            *float32               `json:"unison_crossfeed"`
-	StringModel                *string                `json:"string_model"`
-	ModalPartials              *int                   `json:"modal_partials"`
-	ModalGainExponent          *float32               `json:"modal_gain_exponent"`
-	ModalExcitation            *float32               `json:"modal_excitation"`
-	ModalUndampedLoss          *float32               `json:"modal_undamped_loss"`
-	ModalDampedLoss            *float32               `json:"modal_damped_loss"`
-	CouplingEnabled            *bool                  `json:"coupling_enabled"`
-	CouplingOctaveGain         *float32               `json:"coupling_octave_gain"`
-	CouplingFifthGain          *float32               `json:"coupling_fifth_gain"`
-	CouplingMaxForce           *float32               `json:"coupling_max_force"`
-	CouplingMode               *string                `json:"coupling_mode"`
-	CouplingAmount             *float32               `json:"coupling_amount"`
-	CouplingHarmonicFalloff    *float32               `json:"coupling_harmonic_falloff"`
-	CouplingDetuneSigmaCents   *float32               `json:"coupling_detune_sigma_cents"`
-	CouplingDistanceExponent   *float32               `json:"coupling_distance_exponent"`
-	CouplingMaxNeighbors       *int                   `json:"coupling_max_neighbors"`
-	SoftPedalStrikeOffset      *float32               `json:"soft_pedal_strike_offset"`
-	SoftPedalHardness          *float32               `json:"soft_pedal_hardness"`
-	AttackNoiseLevel           *float32               `json:"attack_noise_level,omitempty"`
-	AttackNoiseDurationMs      *float32               `json:"attack_noise_duration_ms,omitempty"`
-	AttackNoiseColor           *float32               `json:"attack_noise_color,omitempty"`
-	PerNote                    map[string]NoteSetting `json:"per_note"`
+	SoundboardNonlinearity     *float32                `json:"soundboard_nonlinearity,omitempty"`
+	SoundboardStressLevel      *float32                `json:"soundboard_stress_level,omitempty"`
+	ResonanceEnabled           *bool                   `json:"resonance_enabled,omitempty"`
+	ResonanceGain              *float32                `json:"resonance_gain,omitempty"`
+	ResonancePerNoteFilter     *bool                   `json:"resonance_per_note_filter,omitempty"`
+	ResonanceAttackMs          *float32                `json:"resonance_attack_ms,omitempty"`
+	ResonanceSaturation        *float32                `json:"resonance_saturation,omitempty"`
+	HammerStiffnessScale       *float32                `json:"hammer_stiffness_scale,omitempty"`
+	HammerExponentScale        *float32                `json:"hammer_exponent_scale,omitempty"`
+	HammerDampingScale         *float32                `json:"hammer_damping_scale,omitempty"`
+	HammerInitialVelocityScale *float32                `json:"hammer_initial_velocity_scale,omitempty"`
+	HammerContactTimeScale     *float32                `json:"hammer_contact_time_scale,omitempty"`
+	HighFreqDamping            *float32                `json:"high_freq_damping,omitempty"`
+	HighFreqDampingCurve       []RegisterPointSetting  `json:"high_freq_damping_curve,omitempty"`
+	LossCurve                  []RegisterPointSetting  `json:"loss_curve,omitempty"`
+	DamperReflection           *float32                `json:"damper_reflection,omitempty"`
+	DamperEngageMs             *float32                `json:"damper_engage_ms,omitempty"`
+	DamperEngageRegisterSlope  *float32                `json:"damper_engage_register_slope,omitempty"`
+	DamperVelocitySensitivity  *float32                `json:"damper_velocity_sensitivity,omitempty"`
+	DamperReleaseMs            *float32                `json:"damper_release_ms,omitempty"`
+	DamperCurve                []RegisterPointSetting  `json:"damper_curve,omitempty"`
+	DamperlessFromNote         *int                    `json:"damperless_from_note,omitempty"`
+	UnisonDetuneScale          *float32                `json:"unison_detune_scale,omitempty"`
+	UnisonCrossfeed            *float32                `json:"unison_crossfeed,omitempty"`
+	UnisonRegisters            []UnisonRegisterSetting `json:"unison_registers,omitempty"`
+	StringModel                *string                 `json:"string_model,omitempty"`
+	ModalPartials              *int                    `json:"modal_partials,omitempty"`
+	ModalGainExponent          *float32                `json:"modal_gain_exponent,omitempty"`
+	ModalExcitation            *float32                `json:"modal_excitation,omitempty"`
+	ModalUndampedLoss          *float32                `json:"modal_undamped_loss,omitempty"`
+	ModalDampedLoss            *float32                `json:"modal_damped_loss,omitempty"`
+	CouplingEnabled            *bool                   `json:"coupling_enabled,omitempty"`
+	CouplingOctaveGain         *float32                `json:"coupling_octave_gain,omitempty"`
+	CouplingFifthGain          *float32                `json:"coupling_fifth_gain,omitempty"`
+	CouplingMaxForce           *float32                `json:"coupling_max_force,omitempty"`
+	CouplingMode               *string                 `json:"coupling_mode,omitempty"`
+	CouplingAmount             *float32                `json:"coupling_amount,omitempty"`
+	CouplingHarmonicFalloff    *float32                `json:"coupling_harmonic_falloff,omitempty"`
+	CouplingDetuneSigmaCents   *float32                `json:"coupling_detune_sigma_cents,omitempty"`
+	CouplingDistanceExponent   *float32                `json:"coupling_distance_exponent,omitempty"`
+	CouplingMaxNeighbors       *int                    `json:"coupling_max_neighbors,omitempty"`
+	SoftPedalStrikeOffset      *float32                `json:"soft_pedal_strike_offset,omitempty"`
+	SoftPedalHardness          *float32                `json:"soft_pedal_hardness,omitempty"`
+	AttackNoiseLevel           *float32                `json:"attack_noise_level,omitempty"`
+	AttackNoiseDurationMs      *float32                `json:"attack_noise_duration_ms,omitempty"`
+	AttackNoiseColor           *float32                `json:"attack_noise_color,omitempty"`
+	HammerKnockLevel           *float32                `json:"hammer_knock_level,omitempty"`
+	HammerKnockAttackMs        *float32                `json:"hammer_knock_attack_ms,omitempty"`
+	HammerKnockDecayMs         *float32                `json:"hammer_knock_decay_ms,omitempty"`
+	HammerKnockBandsDB         []float32               `json:"hammer_knock_bands_db,omitempty"`
+	HammerPreTriggerMs         *float32                `json:"hammer_pre_trigger_ms,omitempty"`
+	VariationAmount            *float32                `json:"variation_amount,omitempty"`
+	TuningDriftCents           *float32                `json:"tuning_drift_cents,omitempty"`
+	TuningDriftTimeSec         *float32                `json:"tuning_drift_time_sec,omitempty"`
+	TuningDriftCorrelationKeys *float32                `json:"tuning_drift_correlation_keys,omitempty"`
+	CloseMic                   *MicBusSetting          `json:"close_mic,omitempty"`
+	RoomMic                    *MicBusSetting          `json:"room_mic,omitempty"`
+	OutputEQ                   []EQBandSetting         `json:"output_eq,omitempty"`
+	BassMonoHz                 *float32                `json:"bass_mono_hz,omitempty"`
+	ControlSmoothing           *SmoothingSetting       `json:"control_smoothing,omitempty"`
+	PerNote                    map[string]NoteSetting  `json:"per_note,omitempty"`
+	// Meta is the provenance block of fitted presets (see Provenance). It
+	// is kept raw and does not affect the params.
+	Meta json.RawMessage `json:"meta,omitempty"`
+}
+
+// EQBandSetting is one output EQ band in a preset file.
+type EQBandSetting struct {
+	Type   string   `json:"type"`
+	FreqHz float32  `json:"freq_hz"`
+	GainDB float32  `json:"gain_db"`
+	Q      *float32 `json:"q,omitempty"`
+}
+
+// MicBusSetting partially overrides the close or room mic bus.
+type MicBusSetting struct {
+	GainDB  *float32 `json:"gain_db,omitempty"`
+	Pan     *float32 `json:"pan,omitempty"`
+	DelayMs *float32 `json:"delay_ms,omitempty"`
+}
+
+// RegisterPointSetting is one breakpoint of a per-register curve in a
+// preset file.
+type RegisterPointSetting struct {
+	Note  int     `json:"note"`
+	Value float32 `json:"value"`
+}
+
+// SmoothingSetting overrides the glide times of runtime controls in
+// milliseconds; 0 applies changes immediately.
+type SmoothingSetting struct {
+	OutputGainMs     *float32 `json:"output_gain_ms,omitempty"`
+	IRMixMs          *float32 `json:"ir_mix_ms,omitempty"`
+	LidMs            *float32 `json:"lid_ms,omitempty"`
+	SoftPedalMs      *float32 `json:"soft_pedal_ms,omitempty"`
+	CouplingAmountMs *float32 `json:"coupling_amount_ms,omitempty"`
 }
 
 // NoteSetting is a partial note override entry in a preset file.
 type NoteSetting struct {
-	F0             *float32 `json:"f0"`
-	Inharmonicity  *float32 `json:"inharmonicity"`
-	Loss           *float32 `json:"loss"`
-	StrikePosition *float32 `json:"strike_position"`
+	F0             *float32 `json:"f0,omitempty"`
+	Inharmonicity  *float32 `json:"inharmonicity,omitempty"`
+	Loss           *float32 `json:"loss,omitempty"`
+	StrikePosition *float32 `json:"strike_position,omitempty"`
+	// Preparations replaces the note's prepared-piano objects when present.
+	Preparations []PreparationSetting `json:"preparations,omitempty"`
+	// UnisonDetunesCents overrides the note's stringing: one detune per
+	// string, with optional per-string gains (default: equal split).
+	UnisonDetunesCents []float32 `json:"unison_detunes_cents,omitempty"`
+	UnisonGains        []float32 `json:"unison_gains,omitempty"`
+	// Hammer scales multiply the global hammer_*_scale values for the note.
+	HammerStiffnessScale   *float32 `json:"hammer_stiffness_scale,omitempty"`
+	HammerExponentScale    *float32 `json:"hammer_exponent_scale,omitempty"`
+	HammerContactTimeScale *float32 `json:"hammer_contact_time_scale,omitempty"`
+	// StringModel puts the note on its own string core (dwg|modal).
+	StringModel *string `json:"string_model,omitempty"`
 }
 
+// UnisonRegisterSetting is one register of a unison table: the stringing
+// of the notes below below_note not covered by an earlier register.
+type UnisonRegisterSetting struct {
+	BelowNote    int       `json:"below_note"`
+	DetunesCents []float32 `json:"detunes_cents"`
+	Gains        []float32 `json:"gains,omitempty"`
+}
+
+// PreparationSetting is one prepared-piano object in a preset file.
+type PreparationSetting struct {
+	Type      string   `json:"type"`
+	Amount    *float32 `json:"amount,omitempty"`
+	Harmonic  int      `json:"harmonic,omitempty"`
+	Threshold *float32 `json:"threshold,omitempty"`
+}
+
+// DefaultPresetPath is the bundled default preset. It resolves like
+// piano.DefaultIRWavPath: from the asset directory, the working directory
+// or the embedded copy.
+const DefaultPresetPath = "assets/presets/default.json"
+
 // LoadJSON loads a preset JSON file and applies it on top of default params.
 func LoadJSON(path string) (*piano.Params, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
+	p := piano.NewDefaultParams()
+	if err := ApplyJSON(p, path); err != nil {
 		return nil, err
 	}
+	return p, nil
+}
+
+// ApplyJSON applies a preset JSON file onto existing params, e.g. a diff
+// exported by Session.SaveDiffJSON onto another preset. Relative IR paths
+// in the file are resolved against its directory. The file is read with
+// piano.ReadAsset, so DefaultPresetPath loads from anywhere.
+func ApplyJSON(dst *piano.Params, path string) error {
+	b, err := piano.ReadAsset(path)
+	if err != nil {
+		return err
+	}
 
 	var f File
 	if err := json.Unmarshal(b, &f); err != nil {
-		return nil, err
+		return err
 	}
 
-	p := piano.NewDefaultParams()
-	if err := ApplyFile(p, &f); err != nil {
-		return nil, err
+	for _, irPath := range []*string{&f.IRWavPath, &f.BodyIRWavPath, &f.BodyIRClosedWavPath, &f.RoomIRWavPath} {
+		*irPath = ResolveIRPath(path, *irPath)
 	}
+	return ApplyFile(dst, &f)
+}
 
-	base := filepath.Dir(path)
-	if p.IRWavPath != "" && !filepath.IsAbs(p.IRWavPath) {
-		p.IRWavPath = filepath.Clean(filepath.Join(base, p.IRWavPath))
-	}
-	if p.BodyIRWavPath != "" && !filepath.IsAbs(p.BodyIRWavPath) {
-		p.BodyIRWavPath = filepath.Clean(filepath.Join(base, p.BodyIRWavPath))
-	}
-	if p.RoomIRWavPath != "" && !filepath.IsAbs(p.RoomIRWavPath) {
-		p.RoomIRWavPath = filepath.Clean(filepath.Join(base, p.RoomIRWavPath))
-	}
-	return p, nil
+// ResolveIRPath resolves an IR path stored in the preset file at presetPath:
+// relative paths are taken against the preset's directory, absolute paths
+// are kept. A bundled asset path ("assets/ir/...") that is not found next to
+// the preset is kept as is for piano.ReadAsset, which also covers presets
+// written with paths relative to the repository root.
+func ResolveIRPath(presetPath string, irPath string) string {
+	irPath = strings.TrimSpace(irPath)
+	if irPath == "" || filepath.IsAbs(irPath) {
+		return irPath
+	}
+	resolved := filepath.Clean(filepath.Join(filepath.Dir(presetPath), irPath))
+	if piano.IsBundledAsset(irPath) && !fileExists(resolved) {
+		return filepath.Clean(irPath)
+	}
+	return resolved
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 // ApplyFile applies a parsed preset file onto an existing params object.
@@ -118,8 +242,21 @@ func ApplyFile(dst *piano.Params, f *File) error {
 		}
 		dst.OutputGain = *f.OutputGain
 	}
+	if f.Seed != nil {
+		dst.Seed = *f.Seed
+	}
 	nextMin := dst.MinNote
 	nextMax := dst.MaxNote
+	if f.Keys != nil {
+		if f.MinNote != nil || f.MaxNote != nil {
+			return fmt.Errorf("keys cannot be combined with min_note/max_note")
+		}
+		lo, hi, ok := piano.KeyboardRange(*f.Keys)
+		if !ok {
+			return fmt.Errorf("keys must be one of 88, 97, 102")
+		}
+		nextMin, nextMax = lo, hi
+	}
 	if f.MinNote != nil {
 		if *f.MinNote < 0 || *f.MinNote > 127 {
 			return fmt.Errorf("min_note must be in [0,127]")
@@ -174,6 +311,36 @@ func ApplyFile(dst *piano.Params, f *File) error {
 		}
 		dst.BodyDryMix = *f.BodyDryMix
 	}
+	if f.BodyIRClosedWavPath != "" {
+		dst.BodyIRClosedWavPath = strings.TrimSpace(f.BodyIRClosedWavPath)
+	}
+	if f.LidPosition != nil {
+		if *f.LidPosition < 0 || *f.LidPosition > 1 {
+			return fmt.Errorf("lid_position must be in [0,1]")
+		}
+		dst.LidPosition = *f.LidPosition
+	}
+	if f.ConvolutionBackend != nil {
+		backend := piano.ConvolutionBackend(strings.ToLower(strings.TrimSpace(*f.ConvolutionBackend)))
+		switch backend {
+		case piano.ConvolutionAuto, piano.ConvolutionOverlapAdd, piano.ConvolutionDirect:
+			dst.ConvolutionBackend = backend
+		default:
+			return fmt.Errorf("convolution_backend must be one of auto|overlap-add|direct")
+		}
+	}
+	if f.SoundboardNonlinearity != nil {
+		if *f.SoundboardNonlinearity < 0 || *f.SoundboardNonlinearity > 1 {
+			return fmt.Errorf("soundboard_nonlinearity must be in [0,1]")
+		}
+		dst.SoundboardNonlinearity = *f.SoundboardNonlinearity
+	}
+	if f.SoundboardStressLevel != nil {
+		if *f.SoundboardStressLevel <= 0 {
+			return fmt.Errorf("soundboard_stress_level must be > 0")
+		}
+		dst.SoundboardStressLevel = *f.SoundboardStressLevel
+	}
 	if f.RoomIRWavPath != "" {
 		dst.RoomIRWavPath = strings.TrimSpace(f.RoomIRWavPath)
 	}
@@ -201,6 +368,18 @@ func ApplyFile(dst *piano.Params, f *File) error {
 	if f.ResonancePerNoteFilter != nil {
 		dst.ResonancePerNoteFilter = *f.ResonancePerNoteFilter
 	}
+	if f.ResonanceAttackMs != nil {
+		if *f.ResonanceAttackMs < 0 {
+			return fmt.Errorf("resonance_attack_ms must be >= 0")
+		}
+		dst.ResonanceAttackMs = *f.ResonanceAttackMs
+	}
+	if f.ResonanceSaturation != nil {
+		if *f.ResonanceSaturation < 0 {
+			return fmt.Errorf("resonance_saturation must be >= 0")
+		}
+		dst.ResonanceSaturation = *f.ResonanceSaturation
+	}
 	if f.HammerStiffnessScale != nil {
 		if *f.HammerStiffnessScale <= 0 {
 			return fmt.Errorf("hammer_stiffness_scale must be > 0")
@@ -237,6 +416,63 @@ func ApplyFile(dst *piano.Params, f *File) error {
 		}
 		dst.HighFreqDamping = *f.HighFreqDamping
 	}
+	if f.HighFreqDampingCurve != nil {
+		curve, err := parseRegisterCurve("high_freq_damping_curve", f.HighFreqDampingCurve, func(v float32) bool { return v >= 0 && v <= 0.99 }, "[0,0.99]")
+		if err != nil {
+			return err
+		}
+		dst.HighFreqDampingCurve = curve
+	}
+	if f.LossCurve != nil {
+		curve, err := parseRegisterCurve("loss_curve", f.LossCurve, func(v float32) bool { return v > 0 && v <= 1 }, "(0,1]")
+		if err != nil {
+			return err
+		}
+		dst.LossCurve = curve
+	}
+	if f.DamperReflection != nil {
+		if *f.DamperReflection <= 0 || *f.DamperReflection > 1 {
+			return fmt.Errorf("damper_reflection must be in (0,1]")
+		}
+		dst.DamperReflection = *f.DamperReflection
+	}
+	if f.DamperEngageMs != nil {
+		if *f.DamperEngageMs < 0 {
+			return fmt.Errorf("damper_engage_ms must be >= 0")
+		}
+		dst.DamperEngageMs = *f.DamperEngageMs
+	}
+	if f.DamperEngageRegisterSlope != nil {
+		if *f.DamperEngageRegisterSlope < 0 {
+			return fmt.Errorf("damper_engage_register_slope must be >= 0")
+		}
+		dst.DamperEngageRegisterSlope = *f.DamperEngageRegisterSlope
+	}
+	if f.DamperVelocitySensitivity != nil {
+		if *f.DamperVelocitySensitivity < 0 {
+			return fmt.Errorf("damper_velocity_sensitivity must be >= 0")
+		}
+		dst.DamperVelocitySensitivity = *f.DamperVelocitySensitivity
+	}
+	if f.DamperReleaseMs != nil {
+		if *f.DamperReleaseMs < 0 {
+			return fmt.Errorf("damper_release_ms must be >= 0")
+		}
+		dst.DamperReleaseMs = *f.DamperReleaseMs
+	}
+	if f.DamperCurve != nil {
+		curve, err := parseRegisterCurve("damper_curve", f.DamperCurve, func(v float32) bool { return v >= 0 && v <= 1 }, "[0,1]")
+		if err != nil {
+			return err
+		}
+		dst.DamperCurve = curve
+	}
+	if f.DamperlessFromNote != nil {
+		if *f.DamperlessFromNote < 0 || *f.DamperlessFromNote > 128 {
+			return fmt.Errorf("damperless_from_note must be in [0,128]")
+		}
+		dst.DamperlessFromNote = *f.DamperlessFromNote
+	}
 	if f.UnisonDetuneScale != nil {
 		if *f.UnisonDetuneScale < 0 {
 			return fmt.Errorf("unison_detune_scale must be >= 0")
@@ -249,6 +485,13 @@ func ApplyFile(dst *piano.Params, f *File) error {
 		}
 		dst.UnisonCrossfeed = *f.UnisonCrossfeed
 	}
+	if f.UnisonRegisters != nil {
+		registers, err := parseUnisonRegisters(f.UnisonRegisters)
+		if err != nil {
+			return err
+		}
+		dst.UnisonRegisters = registers
+	}
 	if f.StringModel != nil {
 		model := piano.StringModel(strings.ToLower(strings.TrimSpace(*f.StringModel)))
 		switch model {
@@ -375,6 +618,93 @@ func ApplyFile(dst *piano.Params, f *File) error {
 	if f.AttackNoiseColor != nil {
 		dst.AttackNoiseColor = *f.AttackNoiseColor
 	}
+	if f.HammerKnockLevel != nil {
+		if *f.HammerKnockLevel < 0 {
+			return fmt.Errorf("hammer_knock_level must be >= 0")
+		}
+		dst.HammerKnockLevel = *f.HammerKnockLevel
+	}
+	if f.HammerKnockAttackMs != nil {
+		if *f.HammerKnockAttackMs <= 0 || *f.HammerKnockAttackMs > 50 {
+			return fmt.Errorf("hammer_knock_attack_ms must be in (0,50]")
+		}
+		dst.HammerKnockAttackMs = *f.HammerKnockAttackMs
+	}
+	if f.HammerKnockDecayMs != nil {
+		if *f.HammerKnockDecayMs <= 0 || *f.HammerKnockDecayMs > 2000 {
+			return fmt.Errorf("hammer_knock_decay_ms must be in (0,2000]")
+		}
+		dst.HammerKnockDecayMs = *f.HammerKnockDecayMs
+	}
+	if f.HammerKnockBandsDB != nil {
+		if len(f.HammerKnockBandsDB) > piano.HammerKnockBands {
+			return fmt.Errorf("hammer_knock_bands_db must have at most %d bands", piano.HammerKnockBands)
+		}
+		for i, g := range f.HammerKnockBandsDB {
+			if g < -60 || g > 24 {
+				return fmt.Errorf("hammer_knock_bands_db[%d] must be in [-60,24]", i)
+			}
+			dst.HammerKnockBandsDB[i] = g
+		}
+	}
+	if f.HammerPreTriggerMs != nil {
+		if *f.HammerPreTriggerMs < 0 || *f.HammerPreTriggerMs > piano.MaxHammerPreTriggerMs {
+			return fmt.Errorf("hammer_pre_trigger_ms must be in [0,%d]", piano.MaxHammerPreTriggerMs)
+		}
+		dst.HammerPreTriggerMs = *f.HammerPreTriggerMs
+	}
+	if f.VariationAmount != nil {
+		if *f.VariationAmount < 0 || *f.VariationAmount > 1 {
+			return fmt.Errorf("variation_amount must be in [0,1]")
+		}
+		dst.VariationAmount = *f.VariationAmount
+	}
+	if f.TuningDriftCents != nil {
+		if *f.TuningDriftCents < 0 || *f.TuningDriftCents > 50 {
+			return fmt.Errorf("tuning_drift_cents must be in [0,50]")
+		}
+		dst.TuningDriftCents = *f.TuningDriftCents
+	}
+	if f.TuningDriftTimeSec != nil {
+		if *f.TuningDriftTimeSec <= 0 {
+			return fmt.Errorf("tuning_drift_time_sec must be > 0")
+		}
+		dst.TuningDriftTimeSec = *f.TuningDriftTimeSec
+	}
+	if f.TuningDriftCorrelationKeys != nil {
+		if *f.TuningDriftCorrelationKeys < 0 || *f.TuningDriftCorrelationKeys > 8 {
+			return fmt.Errorf("tuning_drift_correlation_keys must be in [0,8]")
+		}
+		dst.TuningDriftCorrelationKeys = *f.TuningDriftCorrelationKeys
+	}
+	if f.CloseMic != nil {
+		if err := applyMicBus(&dst.CloseMic, "close_mic", f.CloseMic); err != nil {
+			return err
+		}
+	}
+	if f.RoomMic != nil {
+		if err := applyMicBus(&dst.RoomMic, "room_mic", f.RoomMic); err != nil {
+			return err
+		}
+	}
+	if f.OutputEQ != nil {
+		bands, err := parseOutputEQ(f.OutputEQ)
+		if err != nil {
+			return err
+		}
+		dst.OutputEQ = bands
+	}
+	if f.BassMonoHz != nil {
+		if *f.BassMonoHz < 0 {
+			return fmt.Errorf("bass_mono_hz must be >= 0")
+		}
+		dst.BassMonoHz = *f.BassMonoHz
+	}
+	if f.ControlSmoothing != nil {
+		if err := applySmoothing(&dst.ControlSmoothing, f.ControlSmoothing); err != nil {
+			return err
+		}
+	}
 
 	if len(f.PerNote) == 0 {
 		return nil
@@ -393,6 +723,9 @@ func ApplyFile(dst *piano.Params, f *File) error {
 		if err != nil || note < 0 || note > 127 {
 			return fmt.Errorf("invalid per_note key %q (expected 0..127)", k)
 		}
+		if note < dst.MinNote || note > dst.MaxNote {
+			return fmt.Errorf("per_note key %d is outside the note range %d..%d", note, dst.MinNote, dst.MaxNote)
+		}
 		override := f.PerNote[k]
 		np, ok := dst.PerNote[note]
 		if !ok || np == nil {
@@ -423,6 +756,224 @@ func ApplyFile(dst *piano.Params, f *File) error {
 			}
 			np.StrikePosition = *override.StrikePosition
 		}
+		for _, h := range []struct {
+			name string
+			v    *float32
+			dst  *float32
+		}{
+			{"hammer_stiffness_scale", override.HammerStiffnessScale, &np.HammerStiffnessScale},
+			{"hammer_exponent_scale", override.HammerExponentScale, &np.HammerExponentScale},
+			{"hammer_contact_time_scale", override.HammerContactTimeScale, &np.HammerContactTimeScale},
+		} {
+			if h.v == nil {
+				continue
+			}
+			if *h.v <= 0 {
+				return fmt.Errorf("per_note[%d].%s must be > 0", note, h.name)
+			}
+			*h.dst = *h.v
+		}
+		if override.StringModel != nil {
+			model := piano.StringModel(strings.ToLower(strings.TrimSpace(*override.StringModel)))
+			switch model {
+			case piano.StringModelDWG, piano.StringModelModal:
+				np.StringModel = model
+			default:
+				return fmt.Errorf("per_note[%d].string_model must be one of dwg|modal", note)
+			}
+		}
+		if override.Preparations != nil {
+			preps, err := parsePreparations(note, override.Preparations)
+			if err != nil {
+				return err
+			}
+			np.Preparations = preps
+		}
+		if override.UnisonDetunesCents != nil || override.UnisonGains != nil {
+			name := fmt.Sprintf("per_note[%d].unison", note)
+			if err := validateUnison(name, override.UnisonDetunesCents, override.UnisonGains); err != nil {
+				return err
+			}
+			np.UnisonDetunes = override.UnisonDetunesCents
+			np.UnisonGains = override.UnisonGains
+		}
+	}
+	return nil
+}
+
+// parseUnisonRegisters validates a unison table: registers ordered by
+// strictly increasing below_note, each a valid stringing.
+func parseUnisonRegisters(settings []UnisonRegisterSetting) ([]piano.UnisonRegister, error) {
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("unison_registers must not be empty")
+	}
+	registers := make([]piano.UnisonRegister, 0, len(settings))
+	for i, s := range settings {
+		if s.BelowNote < 1 || s.BelowNote > 128 {
+			return nil, fmt.Errorf("unison_registers[%d].below_note must be in [1,128]", i)
+		}
+		if i > 0 && s.BelowNote <= settings[i-1].BelowNote {
+			return nil, fmt.Errorf("unison_registers below_note values must be strictly increasing")
+		}
+		if err := validateUnison(fmt.Sprintf("unison_registers[%d]", i), s.DetunesCents, s.Gains); err != nil {
+			return nil, err
+		}
+		registers = append(registers, piano.UnisonRegister{BelowNote: s.BelowNote, Detunes: s.DetunesCents, Gains: s.Gains})
+	}
+	return registers, nil
+}
+
+// validateUnison checks one stringing: 1..MaxUnisonStrings detunes within
+// a semitone, and either no gains or one non-negative gain per string.
+func validateUnison(name string, detunes, gains []float32) error {
+	if len(detunes) < 1 || len(detunes) > piano.MaxUnisonStrings {
+		return fmt.Errorf("%s needs 1..%d detunes", name, piano.MaxUnisonStrings)
+	}
+	for _, d := range detunes {
+		if d < -100 || d > 100 {
+			return fmt.Errorf("%s detunes must be in [-100,100] cents", name)
+		}
+	}
+	if gains == nil {
+		return nil
+	}
+	if len(gains) != len(detunes) {
+		return fmt.Errorf("%s needs one gain per detune", name)
+	}
+	for _, g := range gains {
+		if g < 0 {
+			return fmt.Errorf("%s gains must be >= 0", name)
+		}
+	}
+	return nil
+}
+
+func parsePreparations(note int, settings []PreparationSetting) ([]piano.Preparation, error) {
+	preps := make([]piano.Preparation, 0, len(settings))
+	for i, s := range settings {
+		prep := piano.Preparation{
+			Type:   piano.PreparationType(strings.ToLower(strings.TrimSpace(s.Type))),
+			Amount: 1,
+		}
+		if s.Amount != nil {
+			if *s.Amount < 0 || *s.Amount > 1 {
+				return nil, fmt.Errorf("per_note[%d].preparations[%d].amount must be in [0,1]", note, i)
+			}
+			prep.Amount = *s.Amount
+		}
+		switch prep.Type {
+		case piano.PreparationRubber:
+		case piano.PreparationPaper:
+			if s.Threshold != nil {
+				if *s.Threshold <= 0 {
+					return nil, fmt.Errorf("per_note[%d].preparations[%d].threshold must be > 0", note, i)
+				}
+				prep.Threshold = *s.Threshold
+			}
+		case piano.PreparationNode:
+			if s.Harmonic < 2 || s.Harmonic > piano.MaxPreparationHarmonic {
+				return nil, fmt.Errorf("per_note[%d].preparations[%d].harmonic must be in [2,%d]", note, i, piano.MaxPreparationHarmonic)
+			}
+			prep.Harmonic = s.Harmonic
+		default:
+			return nil, fmt.Errorf("per_note[%d].preparations[%d].type must be one of rubber|paper|node", note, i)
+		}
+		preps = append(preps, prep)
+	}
+	return preps, nil
+}
+
+func applySmoothing(dst *piano.ControlSmoothing, s *SmoothingSetting) error {
+	fields := []struct {
+		name string
+		src  *float32
+		dst  *float32
+	}{
+		{"output_gain_ms", s.OutputGainMs, &dst.OutputGainMs},
+		{"ir_mix_ms", s.IRMixMs, &dst.IRMixMs},
+		{"lid_ms", s.LidMs, &dst.LidMs},
+		{"soft_pedal_ms", s.SoftPedalMs, &dst.SoftPedalMs},
+		{"coupling_amount_ms", s.CouplingAmountMs, &dst.CouplingAmountMs},
+	}
+	for _, f := range fields {
+		if f.src == nil {
+			continue
+		}
+		if *f.src < 0 || *f.src > 10000 {
+			return fmt.Errorf("control_smoothing.%s must be in [0,10000]", f.name)
+		}
+		*f.dst = *f.src
+	}
+	return nil
+}
+
+func applyMicBus(dst *piano.MicBus, name string, s *MicBusSetting) error {
+	if s.GainDB != nil {
+		if *s.GainDB < -60 || *s.GainDB > 24 {
+			return fmt.Errorf("%s.gain_db must be in [-60,24]", name)
+		}
+		dst.GainDB = *s.GainDB
+	}
+	if s.Pan != nil {
+		if *s.Pan < -1 || *s.Pan > 1 {
+			return fmt.Errorf("%s.pan must be in [-1,1]", name)
+		}
+		dst.Pan = *s.Pan
+	}
+	if s.DelayMs != nil {
+		if *s.DelayMs < 0 || *s.DelayMs > piano.MaxMicDelayMs {
+			return fmt.Errorf("%s.delay_ms must be in [0,%d]", name, piano.MaxMicDelayMs)
+		}
+		dst.DelayMs = *s.DelayMs
 	}
 	return nil
 }
+
+// parseRegisterCurve validates curve breakpoints: notes strictly increasing
+// within MIDI range and values accepted by valid (described by rangeDesc).
+func parseRegisterCurve(name string, settings []RegisterPointSetting, valid func(float32) bool, rangeDesc string) (piano.RegisterCurve, error) {
+	curve := make(piano.RegisterCurve, 0, len(settings))
+	for i, s := range settings {
+		if s.Note < 0 || s.Note > 127 {
+			return nil, fmt.Errorf("%s[%d].note must be in [0,127]", name, i)
+		}
+		if i > 0 && s.Note <= settings[i-1].Note {
+			return nil, fmt.Errorf("%s notes must be strictly increasing", name)
+		}
+		if !valid(s.Value) {
+			return nil, fmt.Errorf("%s[%d].value must be in %s", name, i, rangeDesc)
+		}
+		curve = append(curve, piano.RegisterPoint{Note: s.Note, Value: s.Value})
+	}
+	return curve, nil
+}
+
+func parseOutputEQ(settings []EQBandSetting) ([]piano.EQBand, error) {
+	if len(settings) > piano.MaxOutputEQBands {
+		return nil, fmt.Errorf("output_eq must have at most %d bands", piano.MaxOutputEQBands)
+	}
+	bands := make([]piano.EQBand, 0, len(settings))
+	for i, s := range settings {
+		typ := piano.EQBandType(strings.ToLower(strings.TrimSpace(s.Type)))
+		switch typ {
+		case piano.EQBandLowShelf, piano.EQBandPeak, piano.EQBandHighShelf:
+		default:
+			return nil, fmt.Errorf("output_eq[%d].type must be one of low_shelf|peak|high_shelf", i)
+		}
+		if s.FreqHz <= 0 {
+			return nil, fmt.Errorf("output_eq[%d].freq_hz must be > 0", i)
+		}
+		if s.GainDB < -24 || s.GainDB > 24 {
+			return nil, fmt.Errorf("output_eq[%d].gain_db must be in [-24,24]", i)
+		}
+		q := float32(0.707)
+		if s.Q != nil {
+			if *s.Q <= 0 {
+				return nil, fmt.Errorf("output_eq[%d].q must be > 0", i)
+			}
+			q = *s.Q
+		}
+		bands = append(bands, piano.EQBand{Type: typ, FreqHz: s.FreqHz, GainDB: s.GainDB, Q: q})
+	}
+	return bands, nil
+}
@@ -3,7 +3,10 @@ package preset
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
 )
 
 func TestLoadJSONAppliesGlobalAndPerNote(t *testing.T) {
@@ -15,6 +18,7 @@ func TestLoadJSONAppliesGlobalAndPerNote(t *testing.T) {
 	presetPath := filepath.Join(dir, "preset.json")
 	content := `{
   "output_gain": 0.9,
+  "seed": 42,
   "min_note": 23,
   "max_note": 104,
   "ir_wav_path": "ir.wav",
@@ -49,11 +53,24 @@ func TestLoadJSONAppliesGlobalAndPerNote(t *testing.T) {
   "coupling_max_neighbors": 12,
   "soft_pedal_strike_offset": 0.1,
   "soft_pedal_hardness": 0.75,
+  "variation_amount": 0.3,
+  "tuning_drift_cents": 6,
+  "tuning_drift_time_sec": 120,
+  "tuning_drift_correlation_keys": 3,
+  "body_ir_closed_wav_path": "closed.wav",
+  "lid_position": 0.4,
+  "output_eq": [
+    {"type": "low_shelf", "freq_hz": 120, "gain_db": -2.5},
+    {"type": "peak", "freq_hz": 2500, "gain_db": 1.5, "q": 1.2}
+  ],
   "per_note": {
     "60": {
       "loss": 0.998,
       "inharmonicity": 0.15,
-      "strike_position": 0.22
+      "strike_position": 0.22,
+      "hammer_stiffness_scale": 1.4,
+      "hammer_exponent_scale": 1.05,
+      "hammer_contact_time_scale": 0.8
     }
   }
 }`
@@ -68,6 +85,23 @@ func TestLoadJSONAppliesGlobalAndPerNote(t *testing.T) {
 	if p.OutputGain != 0.9 {
 		t.Fatalf("output_gain mismatch: %f", p.OutputGain)
 	}
+	if p.VariationAmount != 0.3 {
+		t.Fatalf("variation_amount mismatch: %f", p.VariationAmount)
+	}
+	if p.TuningDriftCents != 6 || p.TuningDriftTimeSec != 120 || p.TuningDriftCorrelationKeys != 3 {
+		t.Fatalf("tuning drift mismatch: cents=%f time=%f keys=%f", p.TuningDriftCents, p.TuningDriftTimeSec, p.TuningDriftCorrelationKeys)
+	}
+	if len(p.OutputEQ) != 2 ||
+		p.OutputEQ[0].Type != "low_shelf" || p.OutputEQ[0].GainDB != -2.5 || p.OutputEQ[0].Q != 0.707 ||
+		p.OutputEQ[1].Type != "peak" || p.OutputEQ[1].FreqHz != 2500 || p.OutputEQ[1].Q != 1.2 {
+		t.Fatalf("output_eq mismatch: %+v", p.OutputEQ)
+	}
+	if p.LidPosition != 0.4 || p.BodyIRClosedWavPath != filepath.Join(dir, "closed.wav") {
+		t.Fatalf("lid fields mismatch: lid=%f path=%q", p.LidPosition, p.BodyIRClosedWavPath)
+	}
+	if p.Seed != 42 {
+		t.Fatalf("seed mismatch: %d", p.Seed)
+	}
 	if p.MinNote != 23 || p.MaxNote != 104 {
 		t.Fatalf("note range mismatch: min=%d max=%d", p.MinNote, p.MaxNote)
 	}
@@ -114,6 +148,24 @@ func TestLoadJSONAppliesGlobalAndPerNote(t *testing.T) {
 	if np.Loss != 0.998 || np.Inharmonicity != 0.15 || np.StrikePosition != 0.22 {
 		t.Fatalf("note params mismatch: %+v", np)
 	}
+	if np.HammerStiffnessScale != 1.4 || np.HammerExponentScale != 1.05 || np.HammerContactTimeScale != 0.8 {
+		t.Fatalf("note hammer scales mismatch: %+v", np)
+	}
+}
+
+func TestLoadJSONLoadsEmbeddedDefaultPreset(t *testing.T) {
+	// The tests run in preset/, so the default preset and its IR come from
+	// the embedded copies.
+	p, err := LoadJSON(DefaultPresetPath)
+	if err != nil {
+		t.Fatalf("LoadJSON(%q): %v", DefaultPresetPath, err)
+	}
+	if p.IRWavPath != piano.DefaultIRWavPath {
+		t.Fatalf("IRWavPath = %q, want %q", p.IRWavPath, piano.DefaultIRWavPath)
+	}
+	if _, err := piano.ReadAsset(p.IRWavPath); err != nil {
+		t.Fatalf("ReadAsset(%q): %v", p.IRWavPath, err)
+	}
 }
 
 func TestLoadJSONRejectsInvalidNoteKey(t *testing.T) {
@@ -140,6 +192,18 @@ func TestLoadJSONRejectsInvalidRanges(t *testing.T) {
 	}
 }
 
+func TestLoadJSONRejectsInvalidNoteHammerScale(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{"per_note": {"30": {"hammer_stiffness_scale": 0}}}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	if _, err := LoadJSON(presetPath); err == nil {
+		t.Fatalf("expected error for zero per-note hammer_stiffness_scale")
+	}
+}
+
 func TestLoadJSONRejectsInvalidExtendedFields(t *testing.T) {
 	dir := t.TempDir()
 	presetPath := filepath.Join(dir, "preset.json")
@@ -164,6 +228,18 @@ func TestLoadJSONRejectsInvalidCouplingMode(t *testing.T) {
 	}
 }
 
+func TestLoadJSONRejectsInvalidConvolutionBackend(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{"convolution_backend":"fft"}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	if _, err := LoadJSON(presetPath); err == nil {
+		t.Fatalf("expected error for invalid convolution_backend")
+	}
+}
+
 func TestLoadJSONRejectsInvalidStringModel(t *testing.T) {
 	dir := t.TempDir()
 	presetPath := filepath.Join(dir, "preset.json")
@@ -199,3 +275,297 @@ func TestLoadJSONRejectsInvalidNoteRange(t *testing.T) {
 		t.Fatalf("expected error for invalid min/max note range")
 	}
 }
+
+func TestLoadJSONRejectsInvalidOutputEQ(t *testing.T) {
+	cases := []string{
+		`{"output_eq": [{"type": "notch", "freq_hz": 100, "gain_db": 1}]}`,
+		`{"output_eq": [{"type": "peak", "freq_hz": 0, "gain_db": 1}]}`,
+		`{"output_eq": [{"type": "peak", "freq_hz": 100, "gain_db": 40}]}`,
+		`{"output_eq": [{"type": "peak", "freq_hz": 100, "gain_db": 1, "q": 0}]}`,
+		`{"output_eq": [{"type":"peak","freq_hz":100},{"type":"peak","freq_hz":200},{"type":"peak","freq_hz":300},{"type":"peak","freq_hz":400},{"type":"peak","freq_hz":500},{"type":"peak","freq_hz":600}]}`,
+		`{"bass_mono_hz": -1}`,
+	}
+	for _, content := range cases {
+		dir := t.TempDir()
+		presetPath := filepath.Join(dir, "preset.json")
+		if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJSONRegisterCurves(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{
+  "high_freq_damping_curve": [{"note": 36, "value": 0.02}, {"note": 84, "value": 0.3}],
+  "loss_curve": [{"note": 60, "value": 0.9995}]
+}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	params, err := LoadJSON(presetPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := piano.RegisterCurve{{Note: 36, Value: 0.02}, {Note: 84, Value: 0.3}}
+	if !reflect.DeepEqual(params.HighFreqDampingCurve, want) {
+		t.Fatalf("HighFreqDampingCurve = %+v, want %+v", params.HighFreqDampingCurve, want)
+	}
+	if v, ok := params.LossCurve.At(21); !ok || v != 0.9995 {
+		t.Fatalf("LossCurve.At(21) = %v, %v; want 0.9995", v, ok)
+	}
+}
+
+func TestLoadJSONDamperFields(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{
+  "damper_reflection": 0.95,
+  "damper_engage_ms": 30,
+  "damper_engage_register_slope": 0.4,
+  "damper_velocity_sensitivity": 0,
+  "damper_release_ms": 5,
+  "damper_curve": [{"note": 88, "value": 1}, {"note": 89, "value": 0}],
+  "damperless_from_note": 128
+}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	params, err := LoadJSON(presetPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if params.DamperReflection != 0.95 || params.DamperEngageMs != 30 || params.DamperReleaseMs != 5 {
+		t.Fatalf("damper = %v/%v/%v, want 0.95/30/5", params.DamperReflection, params.DamperEngageMs, params.DamperReleaseMs)
+	}
+	if v, ok := params.DamperCurve.At(100); !ok || v != 0 {
+		t.Fatalf("DamperCurve.At(100) = %v, %v; want 0", v, ok)
+	}
+	if params.DamperEngageRegisterSlope != 0.4 || params.DamperVelocitySensitivity != 0 {
+		t.Fatalf("damper register slope/velocity sensitivity = %v/%v, want 0.4/0", params.DamperEngageRegisterSlope, params.DamperVelocitySensitivity)
+	}
+	if params.DamperlessFromNote != 128 {
+		t.Fatalf("DamperlessFromNote = %d, want 128", params.DamperlessFromNote)
+	}
+}
+
+func TestLoadJSONRejectsInvalidDamperFields(t *testing.T) {
+	cases := []string{
+		`{"damper_reflection": 0}`,
+		`{"damper_reflection": 1.2}`,
+		`{"damper_engage_ms": -1}`,
+		`{"damper_release_ms": -1}`,
+		`{"damper_engage_register_slope": -0.1}`,
+		`{"damper_velocity_sensitivity": -1}`,
+		`{"damperless_from_note": -1}`,
+		`{"damperless_from_note": 129}`,
+	}
+	for _, content := range cases {
+		dir := t.TempDir()
+		presetPath := filepath.Join(dir, "preset.json")
+		if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJSONSoundboardNonlinearity(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{"soundboard_nonlinearity": 0.4, "soundboard_stress_level": 0.2}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	params, err := LoadJSON(presetPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if params.SoundboardNonlinearity != 0.4 || params.SoundboardStressLevel != 0.2 {
+		t.Fatalf("soundboard nonlinearity/stress level = %v/%v, want 0.4/0.2", params.SoundboardNonlinearity, params.SoundboardStressLevel)
+	}
+
+	for _, bad := range []string{
+		`{"soundboard_nonlinearity": -0.1}`,
+		`{"soundboard_nonlinearity": 1.5}`,
+		`{"soundboard_stress_level": 0}`,
+	} {
+		if err := os.WriteFile(presetPath, []byte(bad), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestLoadJSONRejectsInvalidRegisterCurves(t *testing.T) {
+	cases := []string{
+		`{"high_freq_damping_curve": [{"note": 60, "value": 0.1}, {"note": 48, "value": 0.2}]}`,
+		`{"high_freq_damping_curve": [{"note": 60, "value": 0.1}, {"note": 60, "value": 0.2}]}`,
+		`{"high_freq_damping_curve": [{"note": 60, "value": 1.5}]}`,
+		`{"high_freq_damping_curve": [{"note": 128, "value": 0.1}]}`,
+		`{"loss_curve": [{"note": 60, "value": 0}]}`,
+		`{"loss_curve": [{"note": 60, "value": 1.01}]}`,
+		`{"damper_curve": [{"note": 88, "value": 1}, {"note": 89, "value": -0.1}]}`,
+	}
+	for _, content := range cases {
+		dir := t.TempDir()
+		presetPath := filepath.Join(dir, "preset.json")
+		if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJSONRejectsInvalidUnison(t *testing.T) {
+	cases := []string{
+		`{"unison_registers": []}`,
+		`{"unison_registers": [{"below_note": 70, "detunes_cents": [0]}, {"below_note": 40, "detunes_cents": [0]}]}`,
+		`{"unison_registers": [{"below_note": 129, "detunes_cents": [0]}]}`,
+		`{"unison_registers": [{"below_note": 128, "detunes_cents": []}]}`,
+		`{"unison_registers": [{"below_note": 128, "detunes_cents": [-3, -1, 1, 3, 5]}]}`,
+		`{"unison_registers": [{"below_note": 128, "detunes_cents": [0, 150]}]}`,
+		`{"unison_registers": [{"below_note": 128, "detunes_cents": [-2, 2], "gains": [1]}]}`,
+		`{"per_note": {"60": {"unison_gains": [0.5, 0.5]}}}`,
+		`{"per_note": {"60": {"unison_detunes_cents": [-2, 2], "unison_gains": [0.5, -0.5]}}}`,
+		`{"close_mic": {"pan": 1.5}}`,
+		`{"room_mic": {"delay_ms": 250}}`,
+		`{"room_mic": {"gain_db": -90}}`,
+	}
+	for _, content := range cases {
+		dir := t.TempDir()
+		presetPath := filepath.Join(dir, "preset.json")
+		if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJSONKeysSelectsKeyboardRange(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{"keys": 97, "per_note": {"12": {"loss": 0.999}}}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	p, err := LoadJSON(presetPath)
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	if p.MinNote != 12 || p.MaxNote != 108 {
+		t.Fatalf("note range = %d..%d, want 12..108", p.MinNote, p.MaxNote)
+	}
+	if np := p.PerNote[12]; np == nil || np.Loss != 0.999 {
+		t.Fatalf("expected per_note override for extended bass key, got %+v", np)
+	}
+}
+
+func TestLoadJSONRejectsPerNoteOutsideRange(t *testing.T) {
+	cases := []string{
+		`{"per_note": {"12": {"loss": 0.99}}}`,
+		`{"min_note": 60, "max_note": 72, "per_note": {"73": {"loss": 0.99}}}`,
+		`{"keys": 61}`,
+		`{"keys": 88, "min_note": 21}`,
+	}
+	for _, content := range cases {
+		dir := t.TempDir()
+		presetPath := filepath.Join(dir, "preset.json")
+		if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJSONAppliesPreparations(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{"per_note": {"60": {"preparations": [
+  {"type": "rubber", "amount": 0.6},
+  {"type": "paper", "threshold": 0.4},
+  {"type": "node", "harmonic": 3, "amount": 0.8}
+]}}}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	p, err := LoadJSON(presetPath)
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	np := p.PerNote[60]
+	if np == nil || len(np.Preparations) != 3 {
+		t.Fatalf("expected 3 preparations, got %+v", np)
+	}
+	want := []piano.Preparation{
+		{Type: piano.PreparationRubber, Amount: 0.6},
+		{Type: piano.PreparationPaper, Amount: 1, Threshold: 0.4},
+		{Type: piano.PreparationNode, Amount: 0.8, Harmonic: 3},
+	}
+	for i, w := range want {
+		if np.Preparations[i] != w {
+			t.Fatalf("preparation %d = %+v, want %+v", i, np.Preparations[i], w)
+		}
+	}
+}
+
+func TestLoadJSONRejectsInvalidPreparations(t *testing.T) {
+	cases := []string{
+		`{"per_note": {"60": {"preparations": [{"type": "bolt"}]}}}`,
+		`{"per_note": {"60": {"preparations": [{"type": "rubber", "amount": 1.5}]}}}`,
+		`{"per_note": {"60": {"preparations": [{"type": "node"}]}}}`,
+		`{"per_note": {"60": {"preparations": [{"type": "node", "harmonic": 17}]}}}`,
+		`{"per_note": {"60": {"preparations": [{"type": "paper", "threshold": 0}]}}}`,
+	}
+	for _, content := range cases {
+		dir := t.TempDir()
+		presetPath := filepath.Join(dir, "preset.json")
+		if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		if _, err := LoadJSON(presetPath); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestLoadJSONAppliesControlSmoothing(t *testing.T) {
+	dir := t.TempDir()
+	presetPath := filepath.Join(dir, "preset.json")
+	content := `{"control_smoothing": {"output_gain_ms": 5, "soft_pedal_ms": 0}}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	p, err := LoadJSON(presetPath)
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	want := piano.DefaultControlSmoothing()
+	want.OutputGainMs = 5
+	want.SoftPedalMs = 0
+	if p.ControlSmoothing != want {
+		t.Fatalf("control smoothing = %+v, want %+v", p.ControlSmoothing, want)
+	}
+
+	content = `{"control_smoothing": {"ir_mix_ms": -1}}`
+	if err := os.WriteFile(presetPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+	if _, err := LoadJSON(presetPath); err == nil {
+		t.Fatalf("expected error for negative smoothing time")
+	}
+}
@@ -0,0 +1,35 @@
+package preset
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// LoadMeta returns the raw meta block of a preset file, or nil when it has
+// none.
+func LoadMeta(path string) (json.RawMessage, error) {
+	b, err := piano.ReadAsset(path)
+	if err != nil {
+		return nil, err
+	}
+	var f struct {
+		Meta json.RawMessage `json:"meta"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if string(f.Meta) == "null" {
+		return nil, nil
+	}
+	return f.Meta, nil
+}
+
+// SaveJSONWithMeta is SaveJSON that also writes meta (e.g. from LoadMeta of
+// the preset p was loaded from) under the "meta" key.
+func SaveJSONWithMeta(path string, p *piano.Params, meta json.RawMessage) error {
+	f := FileFromParams(p, filepath.Dir(path))
+	f.Meta = meta
+	return writeFile(path, f)
+}
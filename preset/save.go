@@ -0,0 +1,313 @@
+package preset
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// SaveJSON writes p as a preset JSON file that LoadJSON reads back into
+// the same params. Only fields that differ from piano.NewDefaultParams are
+// written, and IR paths are stored relative to the preset file.
+func SaveJSON(path string, p *piano.Params) error {
+	return writeFile(path, FileFromParams(p, filepath.Dir(path)))
+}
+
+func writeFile(path string, f *File) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	return os.WriteFile(path, b, 0o644)
+}
+
+// FileFromParams returns the preset file holding the fields of p that
+// differ from piano.NewDefaultParams, with IR paths made relative to dir
+// (the directory the file is written to; "" keeps them as they are).
+func FileFromParams(p *piano.Params, dir string) *File {
+	return DiffParams(piano.NewDefaultParams(), p, dir)
+}
+
+// DiffParams returns the preset file holding the fields of p that differ
+// from base, so that ApplyFile onto a copy of base reproduces p. Per-note
+// fields and preparation, EQ or register-curve lists that p clears while base sets them
+// cannot be expressed in a preset file and are skipped.
+func DiffParams(base, p *piano.Params, dir string) *File {
+	def := base
+	f := &File{}
+	if p == nil || def == nil {
+		return f
+	}
+
+	f.OutputGain = changedF32(p.OutputGain, def.OutputGain)
+	if p.Seed != def.Seed {
+		seed := p.Seed
+		f.Seed = &seed
+	}
+	f.MinNote = changedInt(p.MinNote, def.MinNote)
+	f.MaxNote = changedInt(p.MaxNote, def.MaxNote)
+
+	if p.IRWavPath != def.IRWavPath {
+		f.IRWavPath = relativeIRPath(dir, p.IRWavPath)
+	}
+	f.IRWetMix = changedF32(p.IRWetMix, def.IRWetMix)
+	f.IRDryMix = changedF32(p.IRDryMix, def.IRDryMix)
+	f.IRGain = changedF32(p.IRGain, def.IRGain)
+	if p.BodyIRWavPath != def.BodyIRWavPath {
+		f.BodyIRWavPath = relativeIRPath(dir, p.BodyIRWavPath)
+	}
+	f.BodyIRGain = changedF32(p.BodyIRGain, def.BodyIRGain)
+	f.BodyDryMix = changedF32(p.BodyDryMix, def.BodyDryMix)
+	if p.BodyIRClosedWavPath != def.BodyIRClosedWavPath {
+		f.BodyIRClosedWavPath = relativeIRPath(dir, p.BodyIRClosedWavPath)
+	}
+	f.LidPosition = changedF32(p.LidPosition, def.LidPosition)
+	f.SoundboardNonlinearity = changedF32(p.SoundboardNonlinearity, def.SoundboardNonlinearity)
+	f.SoundboardStressLevel = changedF32(p.SoundboardStressLevel, def.SoundboardStressLevel)
+	if p.RoomIRWavPath != def.RoomIRWavPath {
+		f.RoomIRWavPath = relativeIRPath(dir, p.RoomIRWavPath)
+	}
+	if p.ConvolutionBackend != def.ConvolutionBackend {
+		backend := string(p.ConvolutionBackend)
+		f.ConvolutionBackend = &backend
+	}
+	f.RoomWetMix = changedF32(p.RoomWetMix, def.RoomWetMix)
+	f.RoomGain = changedF32(p.RoomGain, def.RoomGain)
+
+	f.ResonanceEnabled = changedBool(p.ResonanceEnabled, def.ResonanceEnabled)
+	f.ResonanceGain = changedF32(p.ResonanceGain, def.ResonanceGain)
+	f.ResonancePerNoteFilter = changedBool(p.ResonancePerNoteFilter, def.ResonancePerNoteFilter)
+	f.ResonanceAttackMs = changedF32(p.ResonanceAttackMs, def.ResonanceAttackMs)
+	f.ResonanceSaturation = changedF32(p.ResonanceSaturation, def.ResonanceSaturation)
+
+	f.HammerStiffnessScale = changedF32(p.HammerStiffnessScale, def.HammerStiffnessScale)
+	f.HammerExponentScale = changedF32(p.HammerExponentScale, def.HammerExponentScale)
+	f.HammerDampingScale = changedF32(p.HammerDampingScale, def.HammerDampingScale)
+	f.HammerInitialVelocityScale = changedF32(p.HammerInitialVelocityScale, def.HammerInitialVelocityScale)
+	f.HammerContactTimeScale = changedF32(p.HammerContactTimeScale, def.HammerContactTimeScale)
+	f.HighFreqDamping = changedF32(p.HighFreqDamping, def.HighFreqDamping)
+	f.HighFreqDampingCurve = changedCurve(p.HighFreqDampingCurve, def.HighFreqDampingCurve)
+	f.LossCurve = changedCurve(p.LossCurve, def.LossCurve)
+	f.DamperReflection = changedF32(p.DamperReflection, def.DamperReflection)
+	f.DamperEngageMs = changedF32(p.DamperEngageMs, def.DamperEngageMs)
+	f.DamperEngageRegisterSlope = changedF32(p.DamperEngageRegisterSlope, def.DamperEngageRegisterSlope)
+	f.DamperVelocitySensitivity = changedF32(p.DamperVelocitySensitivity, def.DamperVelocitySensitivity)
+	f.DamperReleaseMs = changedF32(p.DamperReleaseMs, def.DamperReleaseMs)
+	f.DamperCurve = changedCurve(p.DamperCurve, def.DamperCurve)
+	f.DamperlessFromNote = changedInt(p.DamperlessFromNote, def.DamperlessFromNote)
+	f.UnisonDetuneScale = changedF32(p.UnisonDetuneScale, def.UnisonDetuneScale)
+	f.UnisonCrossfeed = changedF32(p.UnisonCrossfeed, def.UnisonCrossfeed)
+	if len(p.UnisonRegisters) > 0 && !reflect.DeepEqual(p.UnisonRegisters, def.UnisonRegisters) {
+		for _, r := range p.UnisonRegisters {
+			f.UnisonRegisters = append(f.UnisonRegisters, UnisonRegisterSetting{BelowNote: r.BelowNote, DetunesCents: r.Detunes, Gains: r.Gains})
+		}
+	}
+
+	if p.StringModel != def.StringModel {
+		model := string(p.StringModel)
+		f.StringModel = &model
+	}
+	f.ModalPartials = changedInt(p.ModalPartials, def.ModalPartials)
+	f.ModalGainExponent = changedF32(p.ModalGainExponent, def.ModalGainExponent)
+	f.ModalExcitation = changedF32(p.ModalExcitation, def.ModalExcitation)
+	f.ModalUndampedLoss = changedF32(p.ModalUndampedLoss, def.ModalUndampedLoss)
+	f.ModalDampedLoss = changedF32(p.ModalDampedLoss, def.ModalDampedLoss)
+
+	f.CouplingEnabled = changedBool(p.CouplingEnabled, def.CouplingEnabled)
+	f.CouplingOctaveGain = changedF32(p.CouplingOctaveGain, def.CouplingOctaveGain)
+	f.CouplingFifthGain = changedF32(p.CouplingFifthGain, def.CouplingFifthGain)
+	f.CouplingMaxForce = changedF32(p.CouplingMaxForce, def.CouplingMaxForce)
+	if p.CouplingMode != def.CouplingMode {
+		mode := string(p.CouplingMode)
+		f.CouplingMode = &mode
+	}
+	f.CouplingAmount = changedF32(p.CouplingAmount, def.CouplingAmount)
+	f.CouplingHarmonicFalloff = changedF32(p.CouplingHarmonicFalloff, def.CouplingHarmonicFalloff)
+	f.CouplingDetuneSigmaCents = changedF32(p.CouplingDetuneSigmaCents, def.CouplingDetuneSigmaCents)
+	f.CouplingDistanceExponent = changedF32(p.CouplingDistanceExponent, def.CouplingDistanceExponent)
+	f.CouplingMaxNeighbors = changedInt(p.CouplingMaxNeighbors, def.CouplingMaxNeighbors)
+
+	f.SoftPedalStrikeOffset = changedF32(p.SoftPedalStrikeOffset, def.SoftPedalStrikeOffset)
+	f.SoftPedalHardness = changedF32(p.SoftPedalHardness, def.SoftPedalHardness)
+	f.AttackNoiseLevel = changedF32(p.AttackNoiseLevel, def.AttackNoiseLevel)
+	f.AttackNoiseDurationMs = changedF32(p.AttackNoiseDurationMs, def.AttackNoiseDurationMs)
+	f.AttackNoiseColor = changedF32(p.AttackNoiseColor, def.AttackNoiseColor)
+	f.HammerKnockLevel = changedF32(p.HammerKnockLevel, def.HammerKnockLevel)
+	f.HammerKnockAttackMs = changedF32(p.HammerKnockAttackMs, def.HammerKnockAttackMs)
+	f.HammerKnockDecayMs = changedF32(p.HammerKnockDecayMs, def.HammerKnockDecayMs)
+	if p.HammerKnockBandsDB != def.HammerKnockBandsDB {
+		f.HammerKnockBandsDB = append([]float32(nil), p.HammerKnockBandsDB[:]...)
+	}
+	f.HammerPreTriggerMs = changedF32(p.HammerPreTriggerMs, def.HammerPreTriggerMs)
+	f.VariationAmount = changedF32(p.VariationAmount, def.VariationAmount)
+	f.TuningDriftCents = changedF32(p.TuningDriftCents, def.TuningDriftCents)
+	f.TuningDriftTimeSec = changedF32(p.TuningDriftTimeSec, def.TuningDriftTimeSec)
+	f.TuningDriftCorrelationKeys = changedF32(p.TuningDriftCorrelationKeys, def.TuningDriftCorrelationKeys)
+
+	f.CloseMic = changedMicBus(p.CloseMic, def.CloseMic)
+	f.RoomMic = changedMicBus(p.RoomMic, def.RoomMic)
+	if len(p.OutputEQ) > 0 && !reflect.DeepEqual(p.OutputEQ, def.OutputEQ) {
+		for _, b := range p.OutputEQ {
+			q := b.Q
+			f.OutputEQ = append(f.OutputEQ, EQBandSetting{Type: string(b.Type), FreqHz: b.FreqHz, GainDB: b.GainDB, Q: &q})
+		}
+	}
+	f.BassMonoHz = changedF32(p.BassMonoHz, def.BassMonoHz)
+	if p.ControlSmoothing != def.ControlSmoothing {
+		s, d := p.ControlSmoothing, def.ControlSmoothing
+		f.ControlSmoothing = &SmoothingSetting{
+			OutputGainMs:     changedF32(s.OutputGainMs, d.OutputGainMs),
+			IRMixMs:          changedF32(s.IRMixMs, d.IRMixMs),
+			LidMs:            changedF32(s.LidMs, d.LidMs),
+			SoftPedalMs:      changedF32(s.SoftPedalMs, d.SoftPedalMs),
+			CouplingAmountMs: changedF32(s.CouplingAmountMs, d.CouplingAmountMs),
+		}
+	}
+
+	notes := make([]int, 0, len(p.PerNote))
+	for note := range p.PerNote {
+		notes = append(notes, note)
+	}
+	sort.Ints(notes)
+	for _, note := range notes {
+		np := p.PerNote[note]
+		if np == nil {
+			continue
+		}
+		// Zero fields of a note entry mean "not set" to the engine.
+		var bn piano.NoteParams
+		if b := def.PerNote[note]; b != nil {
+			bn = *b
+		}
+		s := NoteSetting{
+			F0:                     changedNoteF32(np.F0, bn.F0),
+			Inharmonicity:          changedNoteF32(np.Inharmonicity, bn.Inharmonicity),
+			Loss:                   changedNoteF32(np.Loss, bn.Loss),
+			StrikePosition:         changedNoteF32(np.StrikePosition, bn.StrikePosition),
+			HammerStiffnessScale:   changedNoteF32(np.HammerStiffnessScale, bn.HammerStiffnessScale),
+			HammerExponentScale:    changedNoteF32(np.HammerExponentScale, bn.HammerExponentScale),
+			HammerContactTimeScale: changedNoteF32(np.HammerContactTimeScale, bn.HammerContactTimeScale),
+		}
+		if len(np.Preparations) > 0 && !reflect.DeepEqual(np.Preparations, bn.Preparations) {
+			for _, prep := range np.Preparations {
+				amount := prep.Amount
+				ps := PreparationSetting{Type: string(prep.Type), Amount: &amount, Harmonic: prep.Harmonic}
+				if prep.Threshold > 0 {
+					threshold := prep.Threshold
+					ps.Threshold = &threshold
+				}
+				s.Preparations = append(s.Preparations, ps)
+			}
+		}
+		if len(np.UnisonDetunes) > 0 && !(reflect.DeepEqual(np.UnisonDetunes, bn.UnisonDetunes) && reflect.DeepEqual(np.UnisonGains, bn.UnisonGains)) {
+			s.UnisonDetunesCents = np.UnisonDetunes
+			s.UnisonGains = np.UnisonGains
+		}
+		if np.StringModel != "" && np.StringModel != bn.StringModel {
+			model := string(np.StringModel)
+			s.StringModel = &model
+		}
+		if s.F0 == nil && s.Inharmonicity == nil && s.Loss == nil && s.StrikePosition == nil && s.Preparations == nil && s.UnisonDetunesCents == nil &&
+			s.HammerStiffnessScale == nil && s.HammerExponentScale == nil && s.HammerContactTimeScale == nil && s.StringModel == nil {
+			continue
+		}
+		if f.PerNote == nil {
+			f.PerNote = make(map[string]NoteSetting)
+		}
+		f.PerNote[strconv.Itoa(note)] = s
+	}
+	return f
+}
+
+func changedF32(v, def float32) *float32 {
+	if v == def {
+		return nil
+	}
+	return &v
+}
+
+func changedCurve(c, base piano.RegisterCurve) []RegisterPointSetting {
+	if len(c) == 0 || reflect.DeepEqual(c, base) {
+		return nil
+	}
+	out := make([]RegisterPointSetting, len(c))
+	for i, pt := range c {
+		out[i] = RegisterPointSetting{Note: pt.Note, Value: pt.Value}
+	}
+	return out
+}
+
+func changedMicBus(b, def piano.MicBus) *MicBusSetting {
+	if b == def {
+		return nil
+	}
+	return &MicBusSetting{
+		GainDB:  changedF32(b.GainDB, def.GainDB),
+		Pan:     changedF32(b.Pan, def.Pan),
+		DelayMs: changedF32(b.DelayMs, def.DelayMs),
+	}
+}
+
+// changedNoteF32 is changedF32 for per-note fields, where zero means unset.
+func changedNoteF32(v, base float32) *float32 {
+	if v == 0 {
+		return nil
+	}
+	return changedF32(v, base)
+}
+
+func changedInt(v, def int) *int {
+	if v == def {
+		return nil
+	}
+	return &v
+}
+
+func changedBool(v, def bool) *bool {
+	if v == def {
+		return nil
+	}
+	return &v
+}
+
+// RelativeIRPath returns irPath as stored in a preset file written to
+// presetPath, so that LoadJSON (ResolveIRPath) finds it again.
+func RelativeIRPath(presetPath string, irPath string) string {
+	return relativeIRPath(filepath.Dir(presetPath), irPath)
+}
+
+// relativeIRPath stores irPath relative to dir when both can be resolved,
+// matching how LoadJSON resolves relative IR paths. Bundled asset paths
+// that exist only as embedded copies are kept as they are.
+func relativeIRPath(dir string, irPath string) string {
+	irPath = strings.TrimSpace(irPath)
+	if irPath == "" || dir == "" {
+		return irPath
+	}
+	if piano.IsBundledAsset(irPath) && !fileExists(irPath) {
+		return filepath.ToSlash(filepath.Clean(irPath))
+	}
+	dirAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return irPath
+	}
+	irAbs, err := filepath.Abs(irPath)
+	if err != nil {
+		return irPath
+	}
+	rel, err := filepath.Rel(dirAbs, irAbs)
+	if err != nil {
+		return irPath
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,190 @@
+package preset
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestSaveJSONRoundTripsThroughLoadJSON(t *testing.T) {
+	dir := t.TempDir()
+	p := piano.NewDefaultParams()
+	p.OutputGain = 0.8
+	p.Seed = 9
+	p.MinNote, p.MaxNote = 24, 103
+	p.BodyIRWavPath = filepath.Join(dir, "ir", "body.wav")
+	p.RoomWetMix = 0.35
+	p.ConvolutionBackend = piano.ConvolutionDirect
+	p.ResonanceEnabled = true
+	p.HammerStiffnessScale = 1.3
+	p.StringModel = piano.StringModelModal
+	p.ModalPartials = 12
+	p.CouplingEnabled = false
+	p.CouplingMode = piano.CouplingModePhysical
+	p.AttackNoiseColor = 0
+	p.HammerKnockLevel = 0.02
+	p.HammerKnockBandsDB = [piano.HammerKnockBands]float32{-12, -6, 0, 0, -3, -6, -12, -24}
+	p.HammerPreTriggerMs = 2
+	p.TuningDriftCents = 3
+	p.OutputEQ = []piano.EQBand{{Type: piano.EQBandPeak, FreqHz: 2500, GainDB: -3, Q: 1.4}}
+	p.ControlSmoothing.LidMs = 80
+	p.RoomMic = piano.MicBus{GainDB: -4, Pan: 0.3, DelayMs: 15}
+	p.HighFreqDampingCurve = piano.RegisterCurve{{Note: 36, Value: 0.03}, {Note: 96, Value: 0.2}}
+	p.LossCurve = piano.RegisterCurve{{Note: 60, Value: 0.9996}}
+	p.DamperEngageMs = 25
+	p.DamperVelocitySensitivity = 0.5
+	p.DamperlessFromNote = 0
+	p.DamperCurve = piano.RegisterCurve{{Note: 88, Value: 1}, {Note: 89, Value: 0}}
+	p.UnisonRegisters = []piano.UnisonRegister{{BelowNote: 50, Detunes: []float32{0}}, {BelowNote: 128, Detunes: []float32{-8, 8}, Gains: []float32{0.6, 0.4}}}
+	p.PerNote[60] = &piano.NoteParams{Loss: 0.9997, Inharmonicity: 0.1}
+	p.PerNote[61] = &piano.NoteParams{Preparations: []piano.Preparation{{Type: piano.PreparationNode, Amount: 1, Harmonic: 3}}}
+	p.PerNote[62] = &piano.NoteParams{}
+	p.PerNote[63] = &piano.NoteParams{UnisonDetunes: []float32{-20, 0, 20}}
+	p.PerNote[36] = &piano.NoteParams{HammerStiffnessScale: 0.8, HammerContactTimeScale: 1.3, StringModel: piano.StringModelModal}
+
+	path := filepath.Join(dir, "presets", "tuned.json")
+	if err := SaveJSON(path, p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"body_ir_wav_path": "../ir/body.wav"`) {
+		t.Fatalf("expected IR path relative to the preset:\n%s", raw)
+	}
+	got, err := LoadJSON(path)
+	if err != nil {
+		t.Fatalf("load: %v\n%s", err, raw)
+	}
+	delete(p.PerNote, 62)
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("round trip changed params:\n got %+v\nwant %+v", got, p)
+	}
+}
+
+func TestRelativeIRPathRelativizesFromPresetDir(t *testing.T) {
+	presetPath := filepath.Join("presets", "fitted-c4.json")
+	irPath := filepath.Join("ir", "default_96k.wav")
+
+	got := RelativeIRPath(presetPath, irPath)
+	want := filepath.ToSlash(filepath.Join("..", "ir", "default_96k.wav"))
+	if got != want {
+		t.Fatalf("RelativeIRPath() = %q, want %q", got, want)
+	}
+	if got := RelativeIRPath(presetPath, ""); got != "" {
+		t.Fatalf("RelativeIRPath() = %q, want empty", got)
+	}
+}
+
+func TestBundledIRPathRoundTripsOutsideRepo(t *testing.T) {
+	// The tests run in preset/, where assets/ exists only as embedded copies.
+	dir := t.TempDir()
+	p := piano.NewDefaultParams()
+	p.IRWavPath = piano.DefaultIRWavPath
+	path := filepath.Join(dir, "fitted.json")
+	if err := SaveJSON(path, p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"ir_wav_path": "assets/ir/default_96k.wav"`) {
+		t.Fatalf("expected the bundled IR path to be kept:\n%s", raw)
+	}
+	got, err := LoadJSON(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.IRWavPath != piano.DefaultIRWavPath {
+		t.Fatalf("IRWavPath = %q, want %q", got.IRWavPath, piano.DefaultIRWavPath)
+	}
+
+	// An IR next to the preset wins over the bundled lookup.
+	local := filepath.Join(dir, "assets", "ir", "default_96k.wav")
+	if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ResolveIRPath(path, "assets/ir/default_96k.wav"); got != local {
+		t.Fatalf("ResolveIRPath() = %q, want %q", got, local)
+	}
+	abs := filepath.Join(t.TempDir(), "ir.wav")
+	if got := ResolveIRPath(path, abs); got != abs {
+		t.Fatalf("ResolveIRPath() = %q, want the absolute path unchanged", got)
+	}
+}
+
+func TestSaveJSONWritesOnlyChangedFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.json")
+	p := piano.NewDefaultParams()
+	p.RoomWetMix = 0.2
+	if err := SaveJSON(path, p); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(raw)); got != "{\n  \"room_wet_mix\": 0.2\n}" {
+		t.Fatalf("unexpected preset:\n%s", got)
+	}
+}
+
+func TestMetaBlockIsIgnoredOnLoadAndPreservedOnSave(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "fitted.json")
+	body := `{"output_gain": 0.7, "meta": {"tool": "piano-fit", "seed": 3, "score": 0.12, "future_field": [1, 2]}}`
+	if err := os.WriteFile(src, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadJSON(src)
+	if err != nil {
+		t.Fatalf("LoadJSON with meta: %v", err)
+	}
+	if p.OutputGain != 0.7 {
+		t.Fatalf("output_gain = %v, want 0.7", p.OutputGain)
+	}
+
+	meta, err := LoadMeta(src)
+	if err != nil {
+		t.Fatalf("LoadMeta: %v", err)
+	}
+	dst := filepath.Join(dir, "tweaked.json")
+	if err := SaveJSONWithMeta(dst, p, meta); err != nil {
+		t.Fatalf("SaveJSONWithMeta: %v", err)
+	}
+	var prov struct {
+		Tool  string   `json:"tool"`
+		Seed  int64    `json:"seed"`
+		Score *float64 `json:"score"`
+	}
+	got, err := LoadMeta(dst)
+	if err != nil {
+		t.Fatalf("LoadMeta of saved preset: %v", err)
+	}
+	if err := json.Unmarshal(got, &prov); err != nil {
+		t.Fatalf("saved meta: %v", err)
+	}
+	if prov.Tool != "piano-fit" || prov.Seed != 3 || prov.Score == nil || *prov.Score != 0.12 {
+		t.Fatalf("saved meta = %+v", prov)
+	}
+	if !strings.Contains(string(got), `"future_field"`) {
+		t.Fatalf("unknown meta fields were dropped: %s", got)
+	}
+
+	if err := SaveJSON(dst, p); err != nil {
+		t.Fatal(err)
+	}
+	if meta, err := LoadMeta(dst); err != nil || meta != nil {
+		t.Fatalf("SaveJSON should write no meta, got %s, %v", meta, err)
+	}
+}
@@ -0,0 +1,150 @@
+package preset
+
+import (
+	"path/filepath"
+	"reflect"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// Session is a preset editing session: a journal of parameter edits made
+// on top of a base preset, with undo/redo and export of the net change as
+// a diff that ApplyJSON applies onto other presets.
+//
+// Each journal entry holds a snapshot of the params after the edit, so
+// undo and redo restore exact states whatever the edit touched.
+type Session struct {
+	base    *piano.Params
+	entries []sessionEntry // entries[0] is the base state
+	pos     int            // index of the current entry
+	saved   int            // index of the entry last marked saved
+}
+
+type sessionEntry struct {
+	label  string
+	params *piano.Params
+}
+
+// NewSession starts a session on a copy of base.
+func NewSession(base *piano.Params) *Session {
+	if base == nil {
+		base = piano.NewDefaultParams()
+	}
+	b := cloneParams(base)
+	return &Session{
+		base:    b,
+		entries: []sessionEntry{{params: cloneParams(b)}},
+	}
+}
+
+// Base returns the params the session started from. Callers must not
+// modify them.
+func (s *Session) Base() *piano.Params { return s.base }
+
+// Params returns the current params. Callers must not modify them; edits
+// go through Edit so they are journaled.
+func (s *Session) Params() *piano.Params { return s.entries[s.pos].params }
+
+// Edit applies fn to a copy of the current params and journals the result
+// under label, discarding anything that could be redone. Consecutive edits
+// with the same label merge into one entry, so nudging a knob repeatedly
+// undoes in one step. It reports whether fn changed anything.
+func (s *Session) Edit(label string, fn func(p *piano.Params)) bool {
+	cur := s.Params()
+	next := cloneParams(cur)
+	fn(next)
+	if reflect.DeepEqual(next, cur) {
+		return false
+	}
+	s.entries = s.entries[:s.pos+1]
+	if s.saved > s.pos {
+		s.saved = -1 // the saved state was undone and is now discarded
+	}
+	if s.pos > 0 && s.pos != s.saved && s.entries[s.pos].label == label {
+		s.entries[s.pos].params = next
+		return true
+	}
+	s.entries = append(s.entries, sessionEntry{label: label, params: next})
+	s.pos++
+	return true
+}
+
+// Undo steps back one entry and returns its label.
+func (s *Session) Undo() (string, bool) {
+	if s.pos == 0 {
+		return "", false
+	}
+	label := s.entries[s.pos].label
+	s.pos--
+	return label, true
+}
+
+// Redo re-applies the entry Undo last reverted and returns its label.
+func (s *Session) Redo() (string, bool) {
+	if s.pos+1 >= len(s.entries) {
+		return "", false
+	}
+	s.pos++
+	return s.entries[s.pos].label, true
+}
+
+// CanUndo reports whether there is an edit to undo.
+func (s *Session) CanUndo() bool { return s.pos > 0 }
+
+// CanRedo reports whether there is an undone edit to redo.
+func (s *Session) CanRedo() bool { return s.pos+1 < len(s.entries) }
+
+// MarkSaved records the current params as saved.
+func (s *Session) MarkSaved() { s.saved = s.pos }
+
+// Modified reports whether the current params differ from the ones last
+// marked saved (initially the base).
+func (s *Session) Modified() bool {
+	if s.saved < 0 {
+		return true
+	}
+	return !reflect.DeepEqual(s.Params(), s.entries[s.saved].params)
+}
+
+// Diff returns the net change of the session as a preset file holding
+// only the fields that differ from the base, with IR paths made relative
+// to dir ("" keeps them as they are).
+func (s *Session) Diff(dir string) *File {
+	return DiffParams(s.base, s.Params(), dir)
+}
+
+// SaveDiffJSON writes Diff as a preset JSON file.
+func (s *Session) SaveDiffJSON(path string) error {
+	return writeFile(path, s.Diff(filepath.Dir(path)))
+}
+
+func cloneParams(src *piano.Params) *piano.Params {
+	d := *src
+	if src.PerNote != nil {
+		d.PerNote = make(map[int]*piano.NoteParams, len(src.PerNote))
+		for k, v := range src.PerNote {
+			if v == nil {
+				d.PerNote[k] = nil
+				continue
+			}
+			nv := *v
+			nv.Preparations = append([]piano.Preparation(nil), v.Preparations...)
+			nv.UnisonDetunes = append([]float32(nil), v.UnisonDetunes...)
+			nv.UnisonGains = append([]float32(nil), v.UnisonGains...)
+			d.PerNote[k] = &nv
+		}
+	}
+	d.OutputEQ = append([]piano.EQBand(nil), src.OutputEQ...)
+	d.HighFreqDampingCurve = append(piano.RegisterCurve(nil), src.HighFreqDampingCurve...)
+	d.LossCurve = append(piano.RegisterCurve(nil), src.LossCurve...)
+	d.DamperCurve = append(piano.RegisterCurve(nil), src.DamperCurve...)
+	d.UnisonRegisters = nil
+	for _, r := range src.UnisonRegisters {
+		d.UnisonRegisters = append(d.UnisonRegisters, piano.UnisonRegister{
+			BelowNote: r.BelowNote,
+			Detunes:   append([]float32(nil), r.Detunes...),
+			Gains:     append([]float32(nil), r.Gains...),
+		})
+	}
+	return &d
+}
@@ -0,0 +1,102 @@
+package preset
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestSessionUndoRedoAndMergedEdits(t *testing.T) {
+	base := piano.NewDefaultParams()
+	s := NewSession(base)
+
+	for i := 0; i < 3; i++ {
+		s.Edit("output gain", func(p *piano.Params) { p.OutputGain += 0.1 })
+	}
+	s.Edit("room wet mix", func(p *piano.Params) { p.RoomWetMix = 0.4 })
+	if s.Edit("room wet mix", func(p *piano.Params) { p.RoomWetMix = 0.4 }) {
+		t.Fatal("an edit that changes nothing was journaled")
+	}
+
+	if label, ok := s.Undo(); !ok || label != "room wet mix" || s.Params().RoomWetMix != base.RoomWetMix {
+		t.Fatalf("undo = %q,%v with room wet mix %v", label, ok, s.Params().RoomWetMix)
+	}
+	// The three gain nudges merged into one entry.
+	if label, ok := s.Undo(); !ok || label != "output gain" || s.Params().OutputGain != base.OutputGain {
+		t.Fatalf("undo = %q,%v with output gain %v", label, ok, s.Params().OutputGain)
+	}
+	if _, ok := s.Undo(); ok {
+		t.Fatal("undo past the base succeeded")
+	}
+	if label, ok := s.Redo(); !ok || label != "output gain" {
+		t.Fatalf("redo = %q,%v", label, ok)
+	}
+
+	s.Edit("lid position", func(p *piano.Params) { p.LidPosition = 0.5 })
+	if s.CanRedo() {
+		t.Fatal("a new edit kept the undone room wet mix entry")
+	}
+	if base.OutputGain != piano.NewDefaultParams().OutputGain {
+		t.Fatal("session edits leaked into the base params")
+	}
+}
+
+func TestSessionModifiedFollowsSavedState(t *testing.T) {
+	s := NewSession(piano.NewDefaultParams())
+	if s.Modified() {
+		t.Fatal("fresh session reports modified")
+	}
+	s.Edit("gain", func(p *piano.Params) { p.OutputGain = 2 })
+	s.MarkSaved()
+	s.Edit("gain", func(p *piano.Params) { p.OutputGain = 3 })
+	if !s.Modified() {
+		t.Fatal("edit after save not reported")
+	}
+	if s.Params().OutputGain != 3 || !s.CanUndo() {
+		t.Fatal("edit after save merged into the saved entry")
+	}
+	s.Undo()
+	if s.Modified() {
+		t.Fatal("undo back to the saved state still reports modified")
+	}
+	s.Undo()
+	s.Edit("lid", func(p *piano.Params) { p.LidPosition = 0.2 })
+	if !s.Modified() {
+		t.Fatal("discarding the saved state must count as modified")
+	}
+}
+
+func TestSessionDiffAppliesOntoAnotherPreset(t *testing.T) {
+	base := piano.NewDefaultParams()
+	base.PerNote[60] = &piano.NoteParams{Loss: 0.9995, StrikePosition: 0.12}
+	s := NewSession(base)
+	s.Edit("hammer", func(p *piano.Params) { p.HammerStiffnessScale = 1.4 })
+	s.Edit("loss", func(p *piano.Params) { p.PerNote[60].Loss = 0.9990 })
+	s.Edit("model", func(p *piano.Params) { p.StringModel = piano.StringModelModal })
+
+	path := filepath.Join(t.TempDir(), "tweak.diff.json")
+	if err := s.SaveDiffJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	f := s.Diff("")
+	if f.OutputGain != nil || f.PerNote["60"].StrikePosition != nil {
+		t.Fatalf("diff holds unchanged fields: %+v", f)
+	}
+
+	other := piano.NewDefaultParams()
+	other.OutputGain = 0.5
+	other.PerNote[60] = &piano.NoteParams{Loss: 0.9995, Inharmonicity: 0.3}
+	if err := ApplyJSON(other, path); err != nil {
+		t.Fatal(err)
+	}
+	want := piano.NewDefaultParams()
+	want.OutputGain = 0.5
+	want.HammerStiffnessScale = 1.4
+	want.StringModel = piano.StringModelModal
+	want.PerNote[60] = &piano.NoteParams{Loss: 0.9990, Inharmonicity: 0.3}
+	if !reflect.DeepEqual(other, want) {
+		t.Fatalf("diff applied onto other preset:\n got %+v\nwant %+v", other, want)
+	}
+}
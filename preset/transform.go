@@ -0,0 +1,139 @@
+package preset
+
+import (
+	"math"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// The transforms below derive characterful variants from a (fitted)
+// preset. Each edits p in place, keeps it loadable by LoadJSON and
+// replaces rather than modifies any slice or map p may share with other
+// params.
+
+// ApplyDetune widens (spreadCents > 0) or narrows the unison detune of
+// every multi-string note: the first and last string of each stringing
+// move spreadCents further apart, the strings between them proportionally.
+// spreadCents is heard detune, compensated for UnisonDetuneScale (which
+// mutes it at 0). Honky-tonk pianos sit around 10-20 cents.
+func ApplyDetune(p *piano.Params, spreadCents float32) {
+	if spreadCents == 0 {
+		return
+	}
+	if p.UnisonDetuneScale > 0 {
+		spreadCents /= p.UnisonDetuneScale
+	}
+	registers := p.UnisonRegisters
+	if len(registers) == 0 {
+		registers = piano.DefaultUnisonRegisters()
+	}
+	out := make([]piano.UnisonRegister, len(registers))
+	for i, r := range registers {
+		out[i] = piano.UnisonRegister{
+			BelowNote: r.BelowNote,
+			Detunes:   spreadDetunes(r.Detunes, spreadCents),
+			Gains:     append([]float32(nil), r.Gains...),
+		}
+	}
+	p.UnisonRegisters = out
+	p.PerNote = mapNotes(p.PerNote, func(np *piano.NoteParams) {
+		if len(np.UnisonDetunes) > 0 {
+			np.UnisonDetunes = spreadDetunes(np.UnisonDetunes, spreadCents)
+		}
+	})
+}
+
+func spreadDetunes(detunes []float32, spreadCents float32) []float32 {
+	out := append([]float32(nil), detunes...)
+	n := len(out)
+	if n < 2 {
+		return out
+	}
+	for i := range out {
+		pos := 2*float32(i)/float32(n-1) - 1
+		out[i] = clampF32(out[i]+0.5*spreadCents*pos, -100, 100)
+	}
+	return out
+}
+
+// ApplyHardness makes the hammers harder (delta > 0) or softer: delta is
+// the change of hammer stiffness in octaves, with shorter contact and more
+// and brighter attack noise for harder felt. A tack piano is around +1.5.
+func ApplyHardness(p *piano.Params, delta float32) {
+	if delta == 0 {
+		return
+	}
+	p.HammerStiffnessScale *= float32(math.Exp2(float64(delta)))
+	p.HammerContactTimeScale *= float32(math.Exp2(float64(-delta / 2)))
+	p.AttackNoiseLevel = clampF32(p.AttackNoiseLevel+0.05*delta, 0, 1)
+	p.AttackNoiseColor = clampF32(p.AttackNoiseColor+3*delta, -12, 6)
+}
+
+// ApplyAge models an instrument worn by amount in [0,1]: strings that lose
+// energy faster and sound duller, unisons and tuning drifting apart,
+// uneven regulation and compacted (harder) hammer felt.
+func ApplyAge(p *piano.Params, amount float32) {
+	amount = clampF32(amount, 0, 1)
+	if amount == 0 {
+		return
+	}
+	// Loop losses: the distance from lossless grows.
+	lossFactor := 1 + 3*amount
+	ageLoss := func(v float32) float32 {
+		return clampF32(1-(1-v)*lossFactor, 0.9, 1)
+	}
+	loss := p.LossCurve
+	if len(loss) == 0 {
+		loss = piano.FlatRegisterCurve(piano.DefaultLoopLoss)
+	}
+	p.LossCurve = mapCurve(loss, ageLoss)
+	p.PerNote = mapNotes(p.PerNote, func(np *piano.NoteParams) {
+		if np.Loss > 0 {
+			np.Loss = ageLoss(np.Loss)
+		}
+	})
+
+	dampFactor := 1 + amount
+	p.HighFreqDamping = clampF32(p.HighFreqDamping*dampFactor, 0, 0.99)
+	if len(p.HighFreqDampingCurve) > 0 {
+		p.HighFreqDampingCurve = mapCurve(p.HighFreqDampingCurve, func(v float32) float32 {
+			return clampF32(v*dampFactor, 0, 0.99)
+		})
+	}
+
+	p.UnisonDetuneScale *= 1 + 2*amount
+	p.TuningDriftCents = clampF32(p.TuningDriftCents+8*amount, 0, 50)
+	p.VariationAmount = clampF32(p.VariationAmount+0.3*amount, 0, 1)
+	ApplyHardness(p, 0.5*amount)
+}
+
+func mapCurve(c piano.RegisterCurve, fn func(float32) float32) piano.RegisterCurve {
+	out := make(piano.RegisterCurve, len(c))
+	for i, pt := range c {
+		out[i] = piano.RegisterPoint{Note: pt.Note, Value: fn(pt.Value)}
+	}
+	return out
+}
+
+// mapNotes returns a copy of notes with fn applied to a copy of every
+// entry.
+func mapNotes(notes map[int]*piano.NoteParams, fn func(np *piano.NoteParams)) map[int]*piano.NoteParams {
+	if notes == nil {
+		return nil
+	}
+	out := make(map[int]*piano.NoteParams, len(notes))
+	for note, np := range notes {
+		if np == nil {
+			out[note] = nil
+			continue
+		}
+		nv := *np
+		fn(&nv)
+		out[note] = &nv
+	}
+	return out
+}
+
+func clampF32(v, lo, hi float32) float32 {
+	return min(max(v, lo), hi)
+}
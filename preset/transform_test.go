@@ -0,0 +1,64 @@
+package preset
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestApplyDetuneSpreadsUnisonsWithoutTouchingSource(t *testing.T) {
+	p := piano.NewDefaultParams()
+	p.UnisonDetuneScale = 2
+	p.PerNote[60] = &piano.NoteParams{UnisonDetunes: []float32{-1, 1}}
+	orig := cloneParams(p)
+
+	ApplyDetune(p, 12)
+	want := []piano.UnisonRegister{
+		{BelowNote: 40, Detunes: []float32{0}, Gains: []float32{1}},
+		{BelowNote: 70, Detunes: []float32{-4.8, 4.8}, Gains: []float32{0.52, 0.48}},
+		{BelowNote: 128, Detunes: []float32{-6, 0, 6}, Gains: []float32{0.34, 0.33, 0.33}},
+	}
+	if !reflect.DeepEqual(p.UnisonRegisters, want) {
+		t.Fatalf("registers = %+v, want %+v", p.UnisonRegisters, want)
+	}
+	if got := p.PerNote[60].UnisonDetunes; !reflect.DeepEqual(got, []float32{-4, 4}) {
+		t.Fatalf("per-note detunes = %v, want [-4 4]", got)
+	}
+	if !reflect.DeepEqual(orig.PerNote[60], &piano.NoteParams{UnisonDetunes: []float32{-1, 1}}) {
+		t.Fatal("ApplyDetune modified a shared per-note entry")
+	}
+}
+
+func TestApplyHardnessAndAgeStayLoadable(t *testing.T) {
+	p := piano.NewDefaultParams()
+	p.PerNote[60] = &piano.NoteParams{Loss: 0.9995}
+	ApplyHardness(p, 1)
+	if p.HammerStiffnessScale != 2 || p.HammerContactTimeScale >= 1 || p.AttackNoiseLevel <= 0 {
+		t.Fatalf("hardness +1: stiffness %v, contact %v, noise %v", p.HammerStiffnessScale, p.HammerContactTimeScale, p.AttackNoiseLevel)
+	}
+
+	ApplyAge(p, 1)
+	if loss := p.PerNote[60].Loss; loss >= 0.9995 {
+		t.Fatalf("aged per-note loss = %v, want below 0.9995", loss)
+	}
+	if v, ok := p.LossCurve.At(90); !ok || v >= piano.DefaultLoopLoss {
+		t.Fatalf("aged loss curve at 90 = %v, %v; want below the default", v, ok)
+	}
+	if p.TuningDriftCents <= 0 || p.VariationAmount <= 0 || p.UnisonDetuneScale <= 1 {
+		t.Fatalf("age did not detune: drift %v, variation %v, unison %v", p.TuningDriftCents, p.VariationAmount, p.UnisonDetuneScale)
+	}
+
+	path := filepath.Join(t.TempDir(), "aged.json")
+	if err := SaveJSON(path, p); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadJSON(path)
+	if err != nil {
+		t.Fatalf("transformed preset does not load: %v", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("reloaded params differ:\n got %+v\nwant %+v", got, p)
+	}
+}
@@ -0,0 +1,91 @@
+package preset
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// watchInterval is how often Watch polls the preset file. Polling works
+// the same on every platform and with editors that save by replacing the
+// file.
+var watchInterval = 250 * time.Millisecond
+
+// fileStamp identifies one version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statStamp(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Watch reloads the preset at path with LoadJSON whenever the file changes
+// and passes the new params to onChange, on a goroutine of its own. A
+// change is loaded once the file has been stable for one poll, so a save
+// in progress is not read half-written. Loads that fail are skipped until
+// the next change; WatchErrors reports them.
+//
+// The returned stop ends watching and waits for a running onChange, so it
+// must not be called from onChange itself.
+func Watch(path string, onChange func(*piano.Params)) (stop func(), err error) {
+	return WatchErrors(path, onChange, nil)
+}
+
+// WatchErrors is Watch with onError (if not nil) receiving the reloads
+// that fail, such as a preset saved with invalid JSON or values.
+func WatchErrors(path string, onChange func(*piano.Params), onError func(error)) (stop func(), err error) {
+	last, err := statStamp(path)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		pending := false
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			st, err := statStamp(path)
+			if err != nil {
+				continue // briefly missing while an editor replaces it
+			}
+			if st != last {
+				last, pending = st, true
+				continue
+			}
+			if !pending {
+				continue
+			}
+			pending = false
+			params, err := LoadJSON(path)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			onChange(params)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}, nil
+}
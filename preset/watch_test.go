@@ -0,0 +1,72 @@
+package preset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestWatchReloadsChangedPreset(t *testing.T) {
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 5 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "preset.json")
+	write := func(content string, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write preset: %v", err)
+		}
+		// Distinct modification times even on coarse-grained filesystems.
+		mod := time.Now().Add(-age)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"output_gain": 0.5}`, time.Hour)
+
+	changes := make(chan *piano.Params, 4)
+	errs := make(chan error, 4)
+	stop, err := WatchErrors(path, func(p *piano.Params) { changes <- p }, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatalf("WatchErrors: %v", err)
+	}
+	defer stop()
+
+	write(`{"output_gain": 0.7}`, 2*time.Minute)
+	select {
+	case p := <-changes:
+		if p.OutputGain != 0.7 {
+			t.Fatalf("reloaded output_gain %v, want 0.7", p.OutputGain)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the preset changed")
+	}
+
+	write(`{"output_gain": `, time.Minute)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("nil error for an invalid preset")
+		}
+	case p := <-changes:
+		t.Fatalf("invalid preset reloaded as %+v", p)
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid preset not reported")
+	}
+
+	stop()
+	write(`{"output_gain": 0.9}`, 0)
+	time.Sleep(50 * time.Millisecond)
+	if len(changes) != 0 {
+		t.Fatal("reloaded after stop")
+	}
+}
+
+func TestWatchRequiresExistingFile(t *testing.T) {
+	if _, err := Watch(filepath.Join(t.TempDir(), "missing.json"), func(*piano.Params) {}); err == nil {
+		t.Fatal("expected error for a missing preset")
+	}
+}
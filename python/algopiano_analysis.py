@@ -0,0 +1,93 @@
+"""Python access to the algo-piano objective metric and feature extraction.
+
+Thin wrapper around the ``piano-analyze`` command, so notebooks evaluate
+external synths with exactly the same code path as piano-fit. Inputs are WAV
+paths or 1-D float sequences (numpy arrays work) in [-1, 1].
+
+The binary is taken from $ALGOPIANO_ANALYZE, then ``piano-analyze`` on PATH,
+and otherwise run via ``go run`` from this repository:
+
+    go build -o bin/piano-analyze ./cmd/piano-analyze
+    export ALGOPIANO_ANALYZE=$PWD/bin/piano-analyze
+
+Example::
+
+    import algopiano_analysis as aa
+    m = aa.compare("reference/c4.wav", my_render, sample_rate=48000)
+    print(m["score"], m["dominant"])
+    parts = aa.partials("reference/c4.wav", note=60, count=12)
+"""
+
+import json
+import os
+import shutil
+import struct
+import subprocess
+import tempfile
+import wave
+
+_REPO = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+
+
+def _command():
+    exe = os.environ.get("ALGOPIANO_ANALYZE") or shutil.which("piano-analyze")
+    if exe:
+        return [exe]
+    return ["go", "run", "./cmd/piano-analyze"]
+
+
+def _as_wav(signal, sample_rate, tmpdir, name):
+    if isinstance(signal, (str, os.PathLike)):
+        return os.fspath(signal)
+    path = os.path.join(tmpdir, name + ".wav")
+    with wave.open(path, "wb") as w:
+        w.setnchannels(1)
+        w.setsampwidth(2)
+        w.setframerate(sample_rate)
+        frames = bytearray()
+        for v in signal:
+            v = max(-1.0, min(1.0, float(v)))
+            frames += struct.pack("<h", int(round(v * 32767)))
+        w.writeframes(bytes(frames))
+    return path
+
+
+def analyze(reference, candidate=None, sample_rate=48000, envelope=False,
+            partials=0, note=60, f0=None, gain_match=False, release_weight=0.0):
+    """Run piano-analyze and return its JSON report as a dict."""
+    with tempfile.TemporaryDirectory() as tmp:
+        args = _command() + [
+            "--reference", _as_wav(reference, sample_rate, tmp, "reference"),
+            "--sample-rate", str(sample_rate),
+            "--partials", str(partials),
+            "--note", str(note),
+            "--release-weight", str(release_weight),
+        ]
+        if candidate is not None:
+            args += ["--candidate", _as_wav(candidate, sample_rate, tmp, "candidate")]
+        if envelope:
+            args.append("--envelope")
+        if f0 is not None:
+            args += ["--f0", str(f0)]
+        if gain_match:
+            args.append("--gain-match")
+        cwd = None if args[0] != "go" else _REPO
+        out = subprocess.run(args, cwd=cwd, check=True, capture_output=True, text=True)
+    return json.loads(out.stdout)
+
+
+def compare(reference, candidate, sample_rate=48000, gain_match=False, release_weight=0.0):
+    """Return analysis.Metrics (score, similarity, components) as a dict."""
+    return analyze(reference, candidate, sample_rate,
+                   gain_match=gain_match, release_weight=release_weight)["metrics"]
+
+
+def envelope(signal, sample_rate=48000):
+    """Return {"hop_sec": ..., "levels_db": [...]} for a signal."""
+    return analyze(signal, sample_rate=sample_rate, envelope=True)["reference"]["envelope"]
+
+
+def partials(signal, sample_rate=48000, note=60, f0=None, count=16):
+    """Return a list of {"index", "freq_hz", "amplitude_db", "decay_db_per_s"}."""
+    rep = analyze(signal, sample_rate=sample_rate, partials=count, note=note, f0=f0)
+    return rep["reference"].get("partials", [])
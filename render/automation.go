@@ -0,0 +1,211 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+// Parameter names an automation lane can target.
+const (
+	AutomateOutputGain     = "output_gain"
+	AutomateSoftPedal      = "soft_pedal"
+	AutomateLidPosition    = "lid_position"
+	AutomateRoomWet        = "room_wet"
+	AutomateBodyDry        = "body_dry"
+	AutomateCouplingAmount = "coupling_amount"
+)
+
+// AutomationPoint sets a lane to Value at At seconds.
+type AutomationPoint struct {
+	At    float64 `json:"at_seconds"`
+	Value float64 `json:"value"`
+	// Ramp glides linearly from the previous point instead of stepping.
+	Ramp bool `json:"ramp,omitempty"`
+}
+
+// AutomationLane is a list of timestamped values of one named parameter.
+type AutomationLane struct {
+	Param  string            `json:"param"`
+	Points []AutomationPoint `json:"points"`
+}
+
+// Validate checks the parameter name, the point order and the value range.
+func (l *AutomationLane) Validate() error {
+	lo, hi := 0.0, 1.0
+	switch l.Param {
+	case AutomateSoftPedal, AutomateLidPosition, AutomateCouplingAmount:
+	case AutomateOutputGain, AutomateRoomWet, AutomateBodyDry:
+		hi = math.Inf(1)
+	default:
+		return fmt.Errorf("param must be one of %s|%s|%s|%s|%s|%s", AutomateOutputGain, AutomateSoftPedal,
+			AutomateLidPosition, AutomateRoomWet, AutomateBodyDry, AutomateCouplingAmount)
+	}
+	if len(l.Points) == 0 {
+		return fmt.Errorf("points must not be empty")
+	}
+	for i, pt := range l.Points {
+		if pt.At < 0 {
+			return fmt.Errorf("points[%d].at_seconds must be >= 0", i)
+		}
+		if i > 0 && pt.At < l.Points[i-1].At {
+			return fmt.Errorf("points[%d].at_seconds must not be before the previous point", i)
+		}
+		if pt.Value < lo || pt.Value > hi || math.IsNaN(pt.Value) {
+			if math.IsInf(hi, 1) {
+				return fmt.Errorf("points[%d].value must be >= %g", i, lo)
+			}
+			return fmt.Errorf("points[%d].value must be in [%g,%g]", i, lo, hi)
+		}
+	}
+	return nil
+}
+
+// LoadAutomation reads a JSON array of automation lanes.
+func LoadAutomation(path string) ([]AutomationLane, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lanes []AutomationLane
+	if err := json.Unmarshal(b, &lanes); err != nil {
+		return nil, err
+	}
+	for i := range lanes {
+		if err := lanes[i].Validate(); err != nil {
+			return nil, fmt.Errorf("automation[%d]: %w", i, err)
+		}
+	}
+	return lanes, nil
+}
+
+// Automation plays automation lanes into a Piano during a block-wise
+// render. Values are applied at block boundaries through the engine's
+// runtime controls, which glide to them over their ControlSmoothing times;
+// ramps are re-evaluated every block.
+type Automation struct {
+	lanes []automationLane
+
+	// The engine sets both IR mix levels at once, so the current pair is
+	// tracked here.
+	bodyDry float32
+	roomWet float32
+}
+
+type automationLane struct {
+	param   string
+	points  []AutomationPoint
+	frames  []int
+	applied bool
+	last    float64
+}
+
+// NewAutomation validates lanes and converts their times to frames. params
+// are the preset values the piano was created with (nil = defaults).
+func NewAutomation(lanes []AutomationLane, sampleRate int, params *piano.Params) (*Automation, error) {
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("sample rate must be > 0")
+	}
+	if params == nil {
+		params = piano.NewDefaultParams()
+	}
+	a := &Automation{bodyDry: params.BodyDryMix, roomWet: params.RoomWetMix}
+	for i := range lanes {
+		if err := lanes[i].Validate(); err != nil {
+			return nil, fmt.Errorf("automation[%d]: %w", i, err)
+		}
+		l := automationLane{param: lanes[i].Param, points: lanes[i].Points}
+		for _, pt := range l.points {
+			l.frames = append(l.frames, int(math.Round(pt.At*float64(sampleRate))))
+		}
+		a.lanes = append(a.lanes, l)
+	}
+	return a, nil
+}
+
+// Apply sets every lane's value at frame on p. Lanes before their first
+// point leave the parameter at its preset value.
+func (a *Automation) Apply(p *piano.Piano, frame int) {
+	if a == nil {
+		return
+	}
+	for i := range a.lanes {
+		l := &a.lanes[i]
+		v, ok := l.valueAt(frame)
+		if !ok || (l.applied && v == l.last) {
+			continue
+		}
+		l.applied, l.last = true, v
+		a.set(p, l.param, float32(v))
+	}
+}
+
+func (a *Automation) set(p *piano.Piano, param string, v float32) {
+	switch param {
+	case AutomateOutputGain:
+		p.SetOutputGain(v)
+	case AutomateSoftPedal:
+		p.SetSoftPedalAmount(v)
+	case AutomateLidPosition:
+		p.SetLidPosition(v)
+	case AutomateRoomWet:
+		a.roomWet = v
+		p.SetIRMix(a.bodyDry, a.roomWet)
+	case AutomateBodyDry:
+		a.bodyDry = v
+		p.SetIRMix(a.bodyDry, a.roomWet)
+	case AutomateCouplingAmount:
+		p.SetCouplingAmount(v)
+	}
+}
+
+// NextFrame returns the first point frame after frame, or -1 when no point
+// is left. Renderers end blocks there so steps land on their frame.
+func (a *Automation) NextFrame(frame int) int {
+	next := -1
+	if a == nil {
+		return next
+	}
+	for _, l := range a.lanes {
+		for _, f := range l.frames {
+			if f > frame {
+				if next < 0 || f < next {
+					next = f
+				}
+				break
+			}
+		}
+	}
+	return next
+}
+
+// LastFrame returns the frame of the last point of any lane.
+func (a *Automation) LastFrame() int {
+	last := 0
+	if a == nil {
+		return last
+	}
+	for _, l := range a.lanes {
+		last = max(last, l.frames[len(l.frames)-1])
+	}
+	return last
+}
+
+// valueAt returns the lane value at frame, or false before its first point.
+func (l *automationLane) valueAt(frame int) (float64, bool) {
+	k := 0
+	for k < len(l.frames) && l.frames[k] <= frame {
+		k++
+	}
+	if k < len(l.frames) && k > 0 && l.points[k].Ramp {
+		t := float64(frame-l.frames[k-1]) / float64(l.frames[k]-l.frames[k-1])
+		return l.points[k-1].Value + t*(l.points[k].Value-l.points[k-1].Value), true
+	}
+	if k == 0 {
+		return 0, false
+	}
+	return l.points[k-1].Value, true
+}
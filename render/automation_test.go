@@ -0,0 +1,97 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+	"github.com/cwbudde/algo-piano/piano"
+)
+
+func TestAutomationStepsAndRamps(t *testing.T) {
+	a, err := NewAutomation([]AutomationLane{
+		{Param: AutomateRoomWet, Points: []AutomationPoint{{At: 0.1, Value: 0.2}, {At: 0.3, Value: 0.6, Ramp: true}}},
+		{Param: AutomateLidPosition, Points: []AutomationPoint{{At: 0, Value: 1}, {At: 0.25, Value: 0}}},
+	}, 1000, nil)
+	if err != nil {
+		t.Fatalf("NewAutomation: %v", err)
+	}
+	room, lid := &a.lanes[0], &a.lanes[1]
+	if _, ok := room.valueAt(50); ok {
+		t.Fatalf("lane has a value before its first point")
+	}
+	for _, c := range []struct {
+		lane  *automationLane
+		frame int
+		want  float64
+	}{
+		{room, 100, 0.2}, {room, 200, 0.4}, {room, 300, 0.6}, {room, 900, 0.6},
+		{lid, 0, 1}, {lid, 249, 1}, {lid, 250, 0},
+	} {
+		if v, ok := c.lane.valueAt(c.frame); !ok || math.Abs(v-c.want) > 1e-9 {
+			t.Fatalf("%s at frame %d = %g, want %g", c.lane.param, c.frame, v, c.want)
+		}
+	}
+	for _, c := range []struct{ frame, want int }{{0, 100}, {100, 250}, {250, 300}, {300, -1}} {
+		if got := a.NextFrame(c.frame); got != c.want {
+			t.Fatalf("NextFrame(%d) = %d, want %d", c.frame, got, c.want)
+		}
+	}
+	if a.LastFrame() != 300 {
+		t.Fatalf("LastFrame = %d, want 300", a.LastFrame())
+	}
+}
+
+func TestAutomationDrivesPianoControls(t *testing.T) {
+	const sr = 48000
+	params := piano.NewDefaultParams()
+	params.ControlSmoothing.OutputGainMs = 5
+	a, err := NewAutomation([]AutomationLane{
+		{Param: AutomateOutputGain, Points: []AutomationPoint{{At: 0, Value: 1}, {At: 0.2, Value: 0}}},
+	}, sr, params)
+	if err != nil {
+		t.Fatalf("NewAutomation: %v", err)
+	}
+	p := piano.NewPiano(sr, 16, params)
+	p.NoteOn(60, 100)
+	var before, after []float32
+	for rendered := 0; rendered < sr/2; {
+		a.Apply(p, rendered)
+		n := 128
+		if f := a.NextFrame(rendered); f >= 0 && f-rendered < n {
+			n = f - rendered
+		}
+		block := p.Process(n)
+		if rendered < sr/5 {
+			before = append(before, block...)
+		} else if rendered >= sr/5+sr/20 {
+			after = append(after, block...)
+		}
+		rendered += n
+	}
+	if rms := fitcommon.StereoRMS(before); rms < 1e-3 {
+		t.Fatalf("note too quiet before the gain automation: %g", rms)
+	}
+	if rms := fitcommon.StereoRMS(after); rms > 1e-4 {
+		t.Fatalf("output gain automation did not mute the render: %g", rms)
+	}
+}
+
+func TestAutomationLaneValidate(t *testing.T) {
+	for name, lane := range map[string]AutomationLane{
+		"param":    {Param: "tempo", Points: []AutomationPoint{{Value: 1}}},
+		"empty":    {Param: AutomateLidPosition},
+		"time":     {Param: AutomateLidPosition, Points: []AutomationPoint{{At: -1, Value: 1}}},
+		"order":    {Param: AutomateLidPosition, Points: []AutomationPoint{{At: 1, Value: 1}, {At: 0.5, Value: 0}}},
+		"range":    {Param: AutomateSoftPedal, Points: []AutomationPoint{{Value: 1.5}}},
+		"negative": {Param: AutomateOutputGain, Points: []AutomationPoint{{Value: -1}}},
+	} {
+		if err := lane.Validate(); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+	ok := AutomationLane{Param: AutomateOutputGain, Points: []AutomationPoint{{Value: 2}, {At: 1, Value: 0, Ramp: true}}}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("valid lane rejected: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Package render holds offline rendering helpers shared by the command-line
+// tools.
+package render
+
+import (
+	"errors"
+	"math"
+
+	"github.com/cwbudde/algo-piano/internal/fitcommon"
+)
+
+// StopMode selects the condition an AutoStopper waits for.
+type StopMode int
+
+const (
+	// StopOnDecay stops once the stereo block RMS stays below DecayDBFS.
+	StopOnDecay StopMode = iota
+	// StopOnVoiceInactive stops once the engine reports no active voices,
+	// independent of the output level (body and room tails are cut).
+	StopOnVoiceInactive
+)
+
+// AutoStopConfig describes when a decaying render may end.
+type AutoStopConfig struct {
+	SampleRate  int
+	DecayDBFS   float64
+	HoldBlocks  int
+	MinDuration float64
+	MaxDuration float64
+	Mode        StopMode
+	// Channels is the interleaved channel count of the observed blocks
+	// (0 = stereo).
+	Channels int
+}
+
+// AutoStopper decides when to end a block-wise render. The caller asks for
+// the next block length, renders it, and reports it back via Observe.
+type AutoStopper struct {
+	mode       StopMode
+	threshold  float64
+	holdBlocks int
+	minFrames  int
+	maxFrames  int
+	channels   int
+	rendered   int
+	below      int
+	stopped    bool
+}
+
+// NewAutoStopper validates cfg. A negative MinDuration is treated as zero, a
+// MaxDuration below MinDuration is raised to it, and HoldBlocks is at least 1.
+func NewAutoStopper(cfg AutoStopConfig) (*AutoStopper, error) {
+	if cfg.SampleRate <= 0 {
+		return nil, errors.New("sample rate must be > 0")
+	}
+	minDuration := math.Max(cfg.MinDuration, 0)
+	maxDuration := math.Max(cfg.MaxDuration, minDuration)
+	a := &AutoStopper{
+		mode:       cfg.Mode,
+		threshold:  math.Pow(10.0, cfg.DecayDBFS/20.0),
+		holdBlocks: fitcommon.MaxInt(cfg.HoldBlocks, 1),
+		minFrames:  int(float64(cfg.SampleRate) * minDuration),
+		maxFrames:  int(float64(cfg.SampleRate) * maxDuration),
+		channels:   cfg.Channels,
+	}
+	if a.channels <= 0 {
+		a.channels = 2
+	}
+	if a.maxFrames < 1 {
+		return nil, errors.New("max duration too small")
+	}
+	return a, nil
+}
+
+// MaxFrames returns the hard render limit in frames.
+func (a *AutoStopper) MaxFrames() int {
+	return a.maxFrames
+}
+
+// Rendered returns the number of frames reported so far.
+func (a *AutoStopper) Rendered() int {
+	return a.rendered
+}
+
+// Done reports whether the render should end.
+func (a *AutoStopper) Done() bool {
+	return a.stopped || a.rendered >= a.maxFrames
+}
+
+// NextBlock returns blockSize clamped to the frames left before MaxFrames.
+func (a *AutoStopper) NextBlock(blockSize int) int {
+	return fitcommon.MinInt(blockSize, a.maxFrames-a.rendered)
+}
+
+// Observe accounts for a rendered interleaved block and reports
+// whether the render should stop. activeVoices is only consulted in
+// StopOnVoiceInactive mode. Blocks ending before MinDuration never count
+// towards the hold.
+func (a *AutoStopper) Observe(block []float32, activeVoices int) bool {
+	a.rendered += len(block) / a.channels
+	if a.rendered < a.minFrames {
+		return a.Done()
+	}
+	quiet := false
+	switch a.mode {
+	case StopOnVoiceInactive:
+		quiet = activeVoices == 0
+	default:
+		quiet = fitcommon.StereoRMS(block) < a.threshold
+	}
+	if quiet {
+		a.below++
+		if a.below >= a.holdBlocks {
+			a.stopped = true
+		}
+	} else {
+		a.below = 0
+	}
+	return a.Done()
+}
+
+// Skip accounts for frames that must not end the render, e.g. while further
+// note onsets are still pending. The hold count is left untouched.
+func (a *AutoStopper) Skip(frames int) bool {
+	a.rendered += frames
+	return a.Done()
+}
@@ -0,0 +1,113 @@
+package render
+
+import "testing"
+
+func TestAutoStopperStopsAfterHoldBlocksBelowThreshold(t *testing.T) {
+	a, err := NewAutoStopper(AutoStopConfig{
+		SampleRate:  1000,
+		DecayDBFS:   -40,
+		HoldBlocks:  3,
+		MinDuration: 0.2,
+		MaxDuration: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loud := constBlock(100, 0.5)
+	quiet := constBlock(100, 1e-4)
+
+	// Quiet blocks before MinDuration must not count towards the hold.
+	if a.Observe(quiet, 0) {
+		t.Fatal("stopped before min duration")
+	}
+	if a.Observe(quiet, 0) || a.Observe(quiet, 0) {
+		t.Fatal("stopped before hold count was reached")
+	}
+	// A loud block resets the hold.
+	if a.Observe(loud, 0) || a.Observe(quiet, 0) || a.Observe(quiet, 0) {
+		t.Fatal("loud block did not reset the hold")
+	}
+	if !a.Observe(quiet, 0) {
+		t.Fatal("expected stop after three quiet blocks")
+	}
+	if got := a.Rendered(); got != 700 {
+		t.Fatalf("Rendered() = %d, want 700", got)
+	}
+}
+
+func TestAutoStopperClampsToMaxDuration(t *testing.T) {
+	a, err := NewAutoStopper(AutoStopConfig{SampleRate: 1000, DecayDBFS: -90, MinDuration: 0.5, MaxDuration: 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.MaxFrames() != 500 {
+		t.Fatalf("MaxFrames() = %d, want max raised to min duration", a.MaxFrames())
+	}
+	total := 0
+	for !a.Done() {
+		n := a.NextBlock(128)
+		total += n
+		a.Observe(constBlock(n, 0.5), 1)
+	}
+	if total != 500 {
+		t.Fatalf("rendered %d frames, want 500", total)
+	}
+	if _, err := NewAutoStopper(AutoStopConfig{SampleRate: 1000}); err == nil {
+		t.Fatal("expected error for zero max duration")
+	}
+}
+
+func TestAutoStopperVoiceInactiveIgnoresLevel(t *testing.T) {
+	a, err := NewAutoStopper(AutoStopConfig{
+		SampleRate:  1000,
+		DecayDBFS:   -40,
+		HoldBlocks:  2,
+		MaxDuration: 10,
+		Mode:        StopOnVoiceInactive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quiet := constBlock(100, 1e-4)
+	loud := constBlock(100, 0.5)
+	if a.Observe(quiet, 1) || a.Observe(quiet, 1) {
+		t.Fatal("stopped while voices were active")
+	}
+	if a.Observe(loud, 0) || !a.Observe(loud, 0) {
+		t.Fatal("expected stop after two inactive blocks regardless of level")
+	}
+}
+
+func TestAutoStopperSkipKeepsHold(t *testing.T) {
+	a, err := NewAutoStopper(AutoStopConfig{SampleRate: 1000, DecayDBFS: -40, HoldBlocks: 2, MaxDuration: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quiet := constBlock(100, 1e-4)
+	a.Observe(quiet, 0)
+	if a.Skip(100) {
+		t.Fatal("Skip must not stop the render")
+	}
+	if !a.Observe(quiet, 0) {
+		t.Fatal("expected the hold count to survive a skipped block")
+	}
+}
+
+func TestAutoStopperCountsMonoFrames(t *testing.T) {
+	a, err := NewAutoStopper(AutoStopConfig{SampleRate: 1000, DecayDBFS: -40, MaxDuration: 10, Channels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Observe(make([]float32, 100), 0)
+	if got := a.Rendered(); got != 100 {
+		t.Fatalf("Rendered() = %d after a 100-sample mono block, want 100", got)
+	}
+}
+
+func constBlock(frames int, v float32) []float32 {
+	out := make([]float32, frames*2)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
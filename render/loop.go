@@ -0,0 +1,184 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	algofft "github.com/cwbudde/algo-fft"
+)
+
+// loopFrameS is the STFT frame length used for the loop crossfade.
+const loopFrameS = 0.04
+
+// LoopConfig selects the steady part of a render that MakeLoop turns into a
+// seamless loop.
+type LoopConfig struct {
+	SampleRate int
+	// StartS is where the loop begins; it should be past the attack.
+	StartS float64
+	// LengthS is the loop length.
+	LengthS float64
+	// CrossfadeS is the length of the spectral crossfade at the loop start.
+	CrossfadeS float64
+}
+
+// DefaultLoopConfig returns a 4 s loop starting 1 s into the render with a
+// 0.5 s crossfade.
+func DefaultLoopConfig(sampleRate int) LoopConfig {
+	return LoopConfig{SampleRate: sampleRate, StartS: 1, LengthS: 4, CrossfadeS: 0.5}
+}
+
+// Validate checks the loop times.
+func (c LoopConfig) Validate() error {
+	if c.SampleRate <= 0 {
+		return fmt.Errorf("sample rate must be > 0")
+	}
+	if c.StartS < 0 {
+		return fmt.Errorf("loop start must be >= 0")
+	}
+	if c.LengthS <= 0 {
+		return fmt.Errorf("loop length must be > 0")
+	}
+	if c.CrossfadeS <= 0 {
+		return fmt.Errorf("loop crossfade must be > 0")
+	}
+	return nil
+}
+
+// RequiredFrames returns how many frames of input MakeLoop reads: the loop
+// plus the crossfade and one STFT frame of context on either side.
+func (c LoopConfig) RequiredFrames() int {
+	start, length, xfade, nfft := c.frames()
+	return start + length + xfade + nfft
+}
+
+func (c LoopConfig) frames() (start, length, xfade, nfft int) {
+	nfft = 256
+	for nfft < int(loopFrameS*float64(c.SampleRate)) {
+		nfft <<= 1
+	}
+	start = int(math.Round(c.StartS * float64(c.SampleRate)))
+	length = int(math.Round(c.LengthS * float64(c.SampleRate)))
+	xfade = int(math.Round(c.CrossfadeS * float64(c.SampleRate)))
+	return start, length, xfade, nfft
+}
+
+// MakeLoop cuts a seamlessly looping segment out of interleaved stereo
+// samples. The audio that follows the loop end is crossfaded into the loop
+// start in the STFT domain: per bin the magnitude moves linearly from the
+// tail to the head while the phase rotates the shorter way between them.
+// Unlike a time-domain crossfade, partials that are out of phase between
+// head and tail do not cancel, so the loop point has no level dip.
+func MakeLoop(samples []float32, cfg LoopConfig) ([]float32, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	start, length, xfade, nfft := cfg.frames()
+	if length < xfade+nfft {
+		return nil, fmt.Errorf("loop length must exceed the crossfade by at least %.3f s", float64(nfft)/float64(cfg.SampleRate))
+	}
+	if start < nfft {
+		return nil, fmt.Errorf("loop start must be >= %.3f s", float64(nfft)/float64(cfg.SampleRate))
+	}
+	frames := len(samples) / 2
+	if frames < cfg.RequiredFrames() {
+		return nil, fmt.Errorf("render has %d frames, loop needs %d", frames, cfg.RequiredFrames())
+	}
+
+	plan, err := algofft.NewPlanReal64(nfft)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float32, 2*length)
+	for i := 0; i < length; i++ {
+		out[2*i] = samples[2*(start+i)]
+		out[2*i+1] = samples[2*(start+i)+1]
+	}
+
+	// The blend spans [-nfft, xfade+nfft) relative to the loop start. It
+	// equals the tail before -nfft/2 and the head from xfade+nfft/2 on;
+	// in between it replaces the loop start and, wrapped, the loop end.
+	half := nfft / 2
+	region := xfade + 2*nfft
+	for ch := 0; ch < 2; ch++ {
+		head := make([]float64, region)
+		tail := make([]float64, region)
+		for i := range region {
+			head[i] = float64(samples[2*(start-nfft+i)+ch])
+			tail[i] = float64(samples[2*(start+length-nfft+i)+ch])
+		}
+		blend, err := spectralCrossfade(plan, tail, head, nfft, xfade)
+		if err != nil {
+			return nil, err
+		}
+		for i := nfft - half; i < nfft+xfade+half; i++ {
+			j := i - nfft
+			if j < 0 {
+				j += length
+			}
+			out[2*j+ch] = float32(blend[i])
+		}
+	}
+	return out, nil
+}
+
+// spectralCrossfade overlap-adds Hann-windowed frames of from and to whose
+// spectra are blended with weight t, which ramps from 0 to 1 over xfade
+// samples starting nfft samples into the signals (at the frame centers).
+func spectralCrossfade(plan *algofft.PlanRealT[float64, complex128], from, to []float64, nfft, xfade int) ([]float64, error) {
+	n := len(from)
+	hop := nfft / 4
+	hann := make([]float64, nfft)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(nfft))
+	}
+	out := make([]float64, n)
+	norm := make([]float64, n)
+	frameA := make([]float64, nfft)
+	frameB := make([]float64, nfft)
+	specA := make([]complex128, nfft/2+1)
+	specB := make([]complex128, nfft/2+1)
+
+	for pos := -nfft + hop; pos < n; pos += hop {
+		for i := range nfft {
+			frameA[i], frameB[i] = 0, 0
+			if k := pos + i; k >= 0 && k < n {
+				frameA[i] = from[k] * hann[i]
+				frameB[i] = to[k] * hann[i]
+			}
+		}
+		t := float64(pos+nfft/2-nfft) / float64(xfade)
+		t = math.Max(0, math.Min(1, t))
+		if err := plan.Forward(specA, frameA); err != nil {
+			return nil, err
+		}
+		if err := plan.Forward(specB, frameB); err != nil {
+			return nil, err
+		}
+		last := len(specA) - 1
+		specA[0] = complex((1-t)*real(specA[0])+t*real(specB[0]), 0)
+		specA[last] = complex((1-t)*real(specA[last])+t*real(specB[last]), 0)
+		for k := 1; k < last; k++ {
+			a, b := specA[k], specB[k]
+			mag := (1-t)*cmplx.Abs(a) + t*cmplx.Abs(b)
+			dphi := math.Remainder(cmplx.Phase(b)-cmplx.Phase(a), 2*math.Pi)
+			specA[k] = cmplx.Rect(mag, cmplx.Phase(a)+t*dphi)
+		}
+		if err := plan.Inverse(frameA, specA); err != nil {
+			return nil, err
+		}
+		for i := range nfft {
+			if k := pos + i; k >= 0 && k < n {
+				out[k] += frameA[i] * hann[i]
+				norm[k] += hann[i] * hann[i]
+			}
+		}
+	}
+	for i := range out {
+		if norm[i] > 0 {
+			out[i] /= norm[i]
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"math"
+	"testing"
+)
+
+func stereoSine(frames, sampleRate int, freq float64) []float32 {
+	out := make([]float32, 2*frames)
+	for i := range frames {
+		v := float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		out[2*i], out[2*i+1] = v, v
+	}
+	return out
+}
+
+func TestMakeLoopKeepsPeriodicSegment(t *testing.T) {
+	cfg := LoopConfig{SampleRate: 8000, StartS: 0.5, LengthS: 1, CrossfadeS: 0.25}
+	// 200 Hz completes whole cycles in the 1 s loop, so head and tail match.
+	in := stereoSine(cfg.RequiredFrames(), cfg.SampleRate, 200)
+	out, err := MakeLoop(in, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2*8000 {
+		t.Fatalf("expected %d samples, got %d", 2*8000, len(out))
+	}
+	for i := range out {
+		if d := math.Abs(float64(out[i] - in[2*4000+i])); d > 1e-4 {
+			t.Fatalf("sample %d differs by %g", i, d)
+		}
+	}
+}
+
+func TestMakeLoopSeamHasNoJumpOrDip(t *testing.T) {
+	cfg := LoopConfig{SampleRate: 8000, StartS: 0.5, LengthS: 1, CrossfadeS: 0.25}
+	// 200.4 Hz ends the loop 0.4 cycles off: the tail is 144 degrees away from
+	// the head, so a time-domain crossfade would dip to 0.31 at its middle.
+	in := stereoSine(cfg.RequiredFrames(), cfg.SampleRate, 200.4)
+	out, err := MakeLoop(in, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := len(out) / 2
+	maxStep := 2 * math.Pi * 200.4 / 8000 * 0.5
+	for i := range frames {
+		prev := out[2*((i+frames-1)%frames)]
+		if d := math.Abs(float64(out[2*i] - prev)); d > 1.2*maxStep {
+			t.Fatalf("step of %g at frame %d exceeds the sine slope %g", d, i, maxStep)
+		}
+	}
+	win := 200
+	for i := 0; i+win <= frames; i += win {
+		var pow float64
+		for j := i; j < i+win; j++ {
+			pow += float64(out[2*j]) * float64(out[2*j])
+		}
+		if rms := math.Sqrt(pow / float64(win)); math.Abs(rms-0.5/math.Sqrt2) > 0.05 {
+			t.Fatalf("window at frame %d has rms %g, want about %g", i, rms, 0.5/math.Sqrt2)
+		}
+	}
+}
+
+func TestMakeLoopRejectsShortInput(t *testing.T) {
+	cfg := DefaultLoopConfig(8000)
+	if _, err := MakeLoop(make([]float32, 2*(cfg.RequiredFrames()-1)), cfg); err == nil {
+		t.Fatal("expected an error for a render shorter than the loop needs")
+	}
+	cfg.CrossfadeS = cfg.LengthS
+	if _, err := MakeLoop(make([]float32, 2*cfg.RequiredFrames()), cfg); err == nil {
+		t.Fatal("expected an error for a crossfade as long as the loop")
+	}
+}
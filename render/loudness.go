@@ -0,0 +1,39 @@
+package render
+
+import (
+	"errors"
+	"math"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+// Normalization reports a loudness normalization applied to a render.
+type Normalization struct {
+	MeasuredLUFS float64
+	GainDB       float64
+	PeakDBFS     float64 // sample peak after the gain; > 0 clips in a PCM WAV
+}
+
+// NormalizeLUFS scales interleaved stereo samples in place so their
+// integrated loudness (ITU-R BS.1770) equals targetLUFS.
+func NormalizeLUFS(samples []float32, sampleRate int, targetLUFS float64) (Normalization, error) {
+	return NormalizeLUFSInterleaved(samples, 2, sampleRate, targetLUFS)
+}
+
+// NormalizeLUFSInterleaved is NormalizeLUFS for samples with the given
+// number of interleaved channels.
+func NormalizeLUFSInterleaved(samples []float32, channels int, sampleRate int, targetLUFS float64) (Normalization, error) {
+	measured := analysis.IntegratedLoudnessInterleaved(samples, channels, sampleRate)
+	if math.IsInf(measured, -1) {
+		return Normalization{}, errors.New("cannot normalize loudness: render is silent")
+	}
+	n := Normalization{MeasuredLUFS: measured, GainDB: targetLUFS - measured}
+	gain := float32(math.Pow(10, n.GainDB/20))
+	peak := 0.0
+	for i := range samples {
+		samples[i] *= gain
+		peak = math.Max(peak, math.Abs(float64(samples[i])))
+	}
+	n.PeakDBFS = 20 * math.Log10(peak)
+	return n, nil
+}
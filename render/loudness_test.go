@@ -0,0 +1,51 @@
+package render
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cwbudde/algo-piano/analysis"
+)
+
+func TestNormalizeLUFSReachesTarget(t *testing.T) {
+	const sr = 48000
+	samples := make([]float32, 2*sr)
+	for i := 0; i < sr; i++ {
+		v := float32(0.05 * math.Sin(2*math.Pi*440*float64(i)/sr))
+		samples[2*i] = v
+		samples[2*i+1] = 0.5 * v
+	}
+	n, err := NormalizeLUFS(samples, sr, -16)
+	if err != nil {
+		t.Fatalf("NormalizeLUFS: %v", err)
+	}
+	if got := analysis.IntegratedLoudnessInterleaved(samples, 2, sr); math.Abs(got+16) > 0.01 {
+		t.Fatalf("normalized loudness = %.3f LUFS, want -16", got)
+	}
+	if math.Abs(n.MeasuredLUFS+n.GainDB+16) > 1e-9 || n.GainDB <= 0 {
+		t.Fatalf("normalization = %+v", n)
+	}
+	if n.PeakDBFS >= 0 {
+		t.Fatalf("peak = %.2f dBFS, want below full scale", n.PeakDBFS)
+	}
+}
+
+func TestNormalizeLUFSInterleavedMono(t *testing.T) {
+	const sr = 48000
+	samples := make([]float32, sr)
+	for i := range samples {
+		samples[i] = float32(0.05 * math.Sin(2*math.Pi*440*float64(i)/sr))
+	}
+	if _, err := NormalizeLUFSInterleaved(samples, 1, sr, -20); err != nil {
+		t.Fatalf("NormalizeLUFSInterleaved: %v", err)
+	}
+	if got := analysis.IntegratedLoudnessInterleaved(samples, 1, sr); math.Abs(got+20) > 0.01 {
+		t.Fatalf("normalized loudness = %.3f LUFS, want -20", got)
+	}
+}
+
+func TestNormalizeLUFSRejectsSilence(t *testing.T) {
+	if _, err := NormalizeLUFS(make([]float32, 2000), 48000, -16); err == nil {
+		t.Fatal("expected error for a silent render")
+	}
+}
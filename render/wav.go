@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cwbudde/wav"
+	"github.com/go-audio/audio"
+)
+
+// wavWriteFrames is how many frames WAVWriter collects before encoding
+// them, so short render blocks do not each cost a file write.
+const wavWriteFrames = 4096
+
+// WAVWriter streams interleaved samples to a 16-bit PCM WAV file, so a long
+// render never has to be held in memory. The sizes in the header are fixed
+// up on Close.
+type WAVWriter struct {
+	file     *os.File
+	enc      *wav.Encoder
+	buf      *audio.Float32Buffer
+	channels int
+	frames   int
+}
+
+// CreateWAV creates the WAV file at path, and its directory, for samples
+// with the given rate and channel count.
+func CreateWAV(path string, sampleRate int, channels int) (*WAVWriter, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count: %d", channels)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &WAVWriter{
+		file: file,
+		enc:  wav.NewEncoder(file, sampleRate, 16, channels, 1),
+		buf: &audio.Float32Buffer{
+			Format:         &audio.Format{SampleRate: sampleRate, NumChannels: channels},
+			Data:           make([]float32, 0, wavWriteFrames*channels),
+			SourceBitDepth: 16,
+		},
+		channels: channels,
+	}, nil
+}
+
+// Write appends interleaved samples, whole frames only.
+func (w *WAVWriter) Write(samples []float32) error {
+	if len(samples)%w.channels != 0 {
+		return fmt.Errorf("%d samples are not whole %d-channel frames", len(samples), w.channels)
+	}
+	w.frames += len(samples) / w.channels
+	for len(samples) > 0 {
+		n := min(len(samples), cap(w.buf.Data)-len(w.buf.Data))
+		w.buf.Data = append(w.buf.Data, samples[:n]...)
+		samples = samples[n:]
+		if len(w.buf.Data) == cap(w.buf.Data) {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Frames returns the number of frames written so far.
+func (w *WAVWriter) Frames() int {
+	return w.frames
+}
+
+// Close writes the pending samples, fixes up the header and closes the file.
+func (w *WAVWriter) Close() error {
+	// An empty flush still writes the header of a file without samples.
+	err := w.flush()
+	if cerr := w.enc.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *WAVWriter) flush() error {
+	err := w.enc.Write(w.buf)
+	w.buf.Data = w.buf.Data[:0]
+	return err
+}
@@ -0,0 +1,71 @@
+package render
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fitcommon "github.com/cwbudde/algo-piano/internal/fitcommon"
+)
+
+func TestWAVWriterMatchesOneShotWrite(t *testing.T) {
+	const sr = 48000
+	samples := make([]float32, 2*10000)
+	for i := range samples {
+		samples[i] = float32(0.8 * math.Sin(float64(i)*0.01))
+	}
+	dir := t.TempDir()
+	want := filepath.Join(dir, "oneshot.wav")
+	if err := fitcommon.WriteStereoInterleavedWAV(want, samples, sr); err != nil {
+		t.Fatal(err)
+	}
+
+	got := filepath.Join(dir, "sub", "streamed.wav")
+	w, err := CreateWAV(got, sr, 2)
+	if err != nil {
+		t.Fatalf("CreateWAV: %v", err)
+	}
+	// Uneven blocks straddle the internal buffer.
+	for rest, n := samples, 0; len(rest) > 0; n++ {
+		k := min(len(rest), []int{256, 74, 9000}[n%3])
+		if err := w.Write(rest[:k]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[k:]
+	}
+	if w.Frames() != len(samples)/2 {
+		t.Fatalf("Frames = %d, want %d", w.Frames(), len(samples)/2)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	a, _ := os.ReadFile(want)
+	b, _ := os.ReadFile(got)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("streamed WAV (%d bytes) differs from one-shot WAV (%d bytes)", len(b), len(a))
+	}
+	if err := w.Write(make([]float32, 3)); err == nil {
+		t.Fatal("expected an error for a partial frame")
+	}
+}
+
+func TestWAVWriterWritesEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.wav")
+	w, err := CreateWAV(path, 44100, 1)
+	if err != nil {
+		t.Fatalf("CreateWAV: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	ch, rate, err := fitcommon.ReadWAVChannels(path)
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if rate != 44100 || len(ch) != 1 || len(ch[0]) != 0 {
+		t.Fatalf("read back %d channels at %d Hz, %d frames", len(ch), rate, len(ch[0]))
+	}
+}
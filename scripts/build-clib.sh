@@ -0,0 +1,21 @@
+#!/bin/bash
+set -e
+
+# Build libalgopiano as a C shared library plus its stable header.
+
+mkdir -p dist/clib
+
+case "$(go env GOOS)" in
+darwin) lib=libalgopiano.dylib ;;
+windows) lib=algopiano.dll ;;
+*) lib=libalgopiano.so ;;
+esac
+
+echo "Building dist/clib/$lib..."
+go build -buildmode=c-shared -o "dist/clib/$lib" ./cmd/libalgopiano
+
+# The cgo-generated header is an implementation detail; ship the stable one.
+rm -f "dist/clib/${lib%.*}.h"
+cp cmd/libalgopiano/algopiano.h dist/clib/
+
+echo "Build complete! Files in dist/clib/"
@@ -0,0 +1,27 @@
+#!/bin/bash
+set -e
+
+# Build gomobile bindings for the piano engine (package ./mobile).
+# Usage: ./scripts/build-mobile.sh [android|ios|all]
+
+target="${1:-all}"
+
+if ! command -v gomobile >/dev/null 2>&1; then
+	echo "Error: gomobile not found. Install with:"
+	echo "  go install golang.org/x/mobile/cmd/gomobile@latest && gomobile init"
+	exit 1
+fi
+
+mkdir -p dist/mobile
+
+if [ "$target" = "android" ] || [ "$target" = "all" ]; then
+	echo "Building Android AAR..."
+	gomobile bind -target=android -o dist/mobile/algopiano.aar ./mobile
+fi
+
+if [ "$target" = "ios" ] || [ "$target" = "all" ]; then
+	echo "Building iOS xcframework..."
+	gomobile bind -target=ios -o dist/mobile/AlgoPiano.xcframework ./mobile
+fi
+
+echo "Build complete! Files in dist/mobile/"
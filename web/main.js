@@ -21,6 +21,19 @@ let sustainReleaseMs = 1800;
 let noteVelocity = 96;
 let couplingMode = 'static';
 let stringModel = 'dwg';
+let lidPosition = 1;
+const reference = {
+    clickOn: false,
+    bpm: 100,
+    toneOn: false,
+    a4Hz: 440
+};
+const take = {
+    events: [],
+    startMs: 0
+};
+const CLICK_LEVEL = 0.3;
+const TONE_LEVEL = 0.15;
 const velocityCurve = {
     mode: 'power',
     exponent: 1.7,
@@ -234,6 +247,7 @@ function setCouplingMode(mode) {
     if (ok === false) {
         console.warn('Failed to set coupling mode:', couplingMode);
     }
+    updateEditButtons();
 }
 
 function normalizeStringModel(model) {
@@ -257,6 +271,203 @@ function setStringModel(model) {
     if (ok === false) {
         console.warn('Failed to set string model:', stringModel);
     }
+    updateEditButtons();
+}
+
+// sliderFill is the background of a range slider filled to value percent.
+function sliderFill(value) {
+    const pct = `${value}%`;
+    return `linear-gradient(90deg, rgba(222, 189, 126, 0.9) 0%, rgba(222, 189, 126, 0.42) ${pct}, rgba(31, 34, 41, 0.85) ${pct}, rgba(31, 34, 41, 0.85) 100%)`;
+}
+
+function syncLidUI() {
+    const pct = Math.round(lidPosition * 100);
+    const slider = document.getElementById('lid-position');
+    if (slider) {
+        slider.value = String(pct);
+        slider.style.background = sliderFill(pct);
+    }
+    const label = document.getElementById('lid-position-value');
+    if (label) label.textContent = `${pct}%`;
+}
+
+// The lid crossfades the body IR between the lid-closed variant (0) and
+// the primary one (1); without a lid-closed IR it has no effect.
+function setLidPosition(position) {
+    const value = Number(position);
+    lidPosition = Number.isFinite(value) ? Math.max(0, Math.min(1, value)) : 1;
+    syncLidUI();
+    if (!audioReady || typeof wasmSetLidPosition === 'undefined') {
+        return;
+    }
+    wasmSetLidPosition(lidPosition);
+    updateEditButtons();
+}
+
+// loadLidClosedIR decodes a WAV file, resampled to the context rate by
+// decodeAudioData, and installs its first channel as the lid-closed body IR.
+async function loadLidClosedIR(file) {
+    if (!file) return;
+    try {
+        if (!audioReady) {
+            await initAudio();
+        }
+        const buffer = await audioContext.decodeAudioData(await file.arrayBuffer());
+        if (wasmSetBodyIRClosed(buffer.getChannelData(0)) === false) {
+            console.warn('Failed to set lid-closed IR:', file.name);
+            return;
+        }
+        updateStatus(`Lid-closed IR: ${file.name}`);
+    } catch (error) {
+        console.warn('Failed to load lid-closed IR:', error);
+    }
+}
+
+// Click track and A4 reference tone for checking timing and tuning against
+// other instruments; mixed into the output by the WASM side.
+function applyReference() {
+    const clickButton = document.getElementById('click-toggle');
+    const toneButton = document.getElementById('tone-toggle');
+    if (clickButton) clickButton.setAttribute('aria-pressed', String(reference.clickOn));
+    if (toneButton) toneButton.setAttribute('aria-pressed', String(reference.toneOn));
+    if (!audioReady || typeof wasmSetMetronome === 'undefined') {
+        return;
+    }
+    wasmSetMetronome(reference.clickOn ? reference.bpm : 0, CLICK_LEVEL);
+    wasmSetTestTone(reference.a4Hz, reference.toneOn ? TONE_LEVEL : 0);
+}
+
+function toggleReference(key) {
+    reference[key] = !reference[key];
+    applyReference();
+    if (!audioReady) {
+        initAudio().catch(() => {
+            // initAudio already updates UI with the error details.
+        });
+    }
+}
+
+function readReferenceInput(id, key, min, max) {
+    const input = document.getElementById(id);
+    if (!input) return;
+    input.addEventListener('change', () => {
+        const v = parseFloat(input.value);
+        if (Number.isFinite(v)) {
+            reference[key] = Math.min(max, Math.max(min, v));
+        }
+        input.value = reference[key];
+        applyReference();
+    });
+}
+
+// Every note and pedal event is logged with its time so the take can be
+// re-rendered offline by wasmRenderOffline and downloaded as a WAV file.
+function recordEvent(type, note, velocity, down) {
+    const now = performance.now();
+    if (take.events.length === 0) {
+        take.startMs = now;
+    }
+    take.events.push({ time: (now - take.startMs) / 1000, type, note, velocity, down });
+    updateTakeButtons();
+}
+
+function updateTakeButtons() {
+    const downloadButton = document.getElementById('take-download');
+    const clearButton = document.getElementById('take-clear');
+    if (downloadButton) downloadButton.disabled = take.events.length === 0;
+    if (clearButton) clearButton.disabled = take.events.length === 0;
+}
+
+function clearTake() {
+    take.events = [];
+    updateTakeButtons();
+}
+
+function encodeWAV(samples, sampleRate) {
+    const frames = samples.length / 2;
+    const view = new DataView(new ArrayBuffer(44 + frames * 4));
+    const writeTag = (offset, tag) => {
+        for (let i = 0; i < 4; i++) view.setUint8(offset + i, tag.charCodeAt(i));
+    };
+    writeTag(0, 'RIFF');
+    view.setUint32(4, 36 + frames * 4, true);
+    writeTag(8, 'WAVE');
+    writeTag(12, 'fmt ');
+    view.setUint32(16, 16, true);
+    view.setUint16(20, 1, true);
+    view.setUint16(22, 2, true);
+    view.setUint32(24, sampleRate, true);
+    view.setUint32(28, sampleRate * 4, true);
+    view.setUint16(32, 4, true);
+    view.setUint16(34, 16, true);
+    writeTag(36, 'data');
+    view.setUint32(40, frames * 4, true);
+    for (let i = 0; i < samples.length; i++) {
+        const s = Math.max(-1, Math.min(1, samples[i]));
+        view.setInt16(44 + i * 2, Math.round(s * 32767), true);
+    }
+    return new Blob([view.buffer], { type: 'audio/wav' });
+}
+
+function downloadTake() {
+    if (!audioReady || typeof wasmRenderOffline === 'undefined' || take.events.length === 0) return;
+    const samples = wasmRenderOffline(take.events);
+    if (!samples) return;
+    const url = URL.createObjectURL(encodeWAV(samples, audioContext.sampleRate));
+    const link = document.createElement('a');
+    link.href = url;
+    link.download = 'algo-piano-take.wav';
+    link.click();
+    URL.revokeObjectURL(url);
+}
+
+// Edits to the coupling mode and string model are journaled by the WASM
+// side (preset.Session); these helpers drive undo/redo and diff export.
+function applySessionState(state) {
+    if (!state) {
+        return;
+    }
+    couplingMode = normalizeCouplingMode(state.couplingMode);
+    stringModel = normalizeStringModel(state.stringModel);
+    lidPosition = Number.isFinite(state.lidPosition) ? state.lidPosition : lidPosition;
+    syncLidUI();
+    const couplingSelect = document.getElementById('coupling-mode');
+    if (couplingSelect) couplingSelect.value = couplingMode;
+    const modelSelect = document.getElementById('string-model');
+    if (modelSelect) modelSelect.value = stringModel;
+    updateEditButtons(state);
+}
+
+function updateEditButtons(state) {
+    if (!state && audioReady && typeof wasmGetSessionState !== 'undefined') {
+        state = wasmGetSessionState();
+    }
+    const undoButton = document.getElementById('edit-undo');
+    const redoButton = document.getElementById('edit-redo');
+    if (undoButton) undoButton.disabled = !(state && state.canUndo);
+    if (redoButton) redoButton.disabled = !(state && state.canRedo);
+}
+
+function undoEdit() {
+    if (!audioReady || typeof wasmUndo === 'undefined') return;
+    applySessionState(wasmUndo());
+}
+
+function redoEdit() {
+    if (!audioReady || typeof wasmRedo === 'undefined') return;
+    applySessionState(wasmRedo());
+}
+
+function exportEditDiff() {
+    if (!audioReady || typeof wasmExportDiff === 'undefined') return;
+    const diff = wasmExportDiff();
+    if (typeof diff !== 'string') return;
+    const url = URL.createObjectURL(new Blob([diff + '\n'], { type: 'application/json' }));
+    const link = document.createElement('a');
+    link.href = url;
+    link.download = 'algo-piano-diff.json';
+    link.click();
+    URL.revokeObjectURL(url);
 }
 
 function syncKeyVisual(note) {
@@ -310,12 +521,14 @@ function sendKeyDownToWasm(note, midiVelocity) {
     const v = Math.max(0, Math.min(127, midiVelocity | 0));
     if (v <= 0) {
         if (typeof wasmKeyDown !== 'undefined') {
+            recordEvent('key_down', note, 0, false);
             wasmKeyDown(note);
             return;
         }
         return;
     }
     if (typeof wasmNoteOn !== 'undefined') {
+        recordEvent('note_on', note, v, false);
         wasmNoteOn(note, v);
     }
 }
@@ -331,8 +544,7 @@ function attachKeyboardListeners() {
     const stringModelSelect = document.getElementById('string-model');
 
     function updateSliderFill(value) {
-        const pct = `${value}%`;
-        sustainLevelSlider.style.background = `linear-gradient(90deg, rgba(222, 189, 126, 0.9) 0%, rgba(222, 189, 126, 0.42) ${pct}, rgba(31, 34, 41, 0.85) ${pct}, rgba(31, 34, 41, 0.85) 100%)`;
+        sustainLevelSlider.style.background = sliderFill(value);
     }
 
     function updateSustainReleaseFromLevel(value) {
@@ -373,6 +585,7 @@ function attachKeyboardListeners() {
     syncPedalUI();
     setCouplingMode(couplingModeSelect ? couplingModeSelect.value : couplingMode);
     setStringModel(stringModelSelect ? stringModelSelect.value : stringModel);
+    syncLidUI();
 
     keys.forEach(key => {
         const note = parseInt(key.dataset.note, 10);
@@ -507,6 +720,21 @@ function attachKeyboardListeners() {
             setStringModel(event.target.value);
         });
     }
+    document.getElementById('lid-position')?.addEventListener('input', (event) => {
+        setLidPosition(parseInt(event.target.value, 10) / 100);
+    });
+    document.getElementById('lid-closed-ir')?.addEventListener('change', (event) => {
+        loadLidClosedIR(event.target.files[0]);
+    });
+    document.getElementById('edit-undo')?.addEventListener('click', undoEdit);
+    document.getElementById('edit-redo')?.addEventListener('click', redoEdit);
+    document.getElementById('edit-export')?.addEventListener('click', exportEditDiff);
+    document.getElementById('click-toggle')?.addEventListener('click', () => toggleReference('clickOn'));
+    document.getElementById('tone-toggle')?.addEventListener('click', () => toggleReference('toneOn'));
+    document.getElementById('take-download')?.addEventListener('click', downloadTake);
+    document.getElementById('take-clear')?.addEventListener('click', clearTake);
+    readReferenceInput('click-bpm', 'bpm', 20, 300);
+    readReferenceInput('tone-a4', 'a4Hz', 400, 480);
 
     // Computer keyboard
     const keyMap = buildKeyMap();
@@ -559,6 +787,7 @@ function releaseNote(note) {
     if (!audioReady) return;
 
     if (typeof wasmNoteOff !== 'undefined') {
+        recordEvent('note_off', note, 0, false);
         wasmNoteOff(note);
     }
 }
@@ -684,7 +913,10 @@ async function initAudio() {
         audioReady = true;
         setCouplingMode(couplingMode);
         setStringModel(stringModel);
+        setLidPosition(lidPosition);
+        applyReference();
         if (sustainPedalDown && typeof wasmSetSustain !== 'undefined') {
+            recordEvent('sustain', 0, 0, true);
             wasmSetSustain(sustainPedalDown);
         }
         updateStatus(`Ready! Sample rate: ${audioContext.sampleRate} Hz`);
@@ -766,6 +998,7 @@ function handleSustain(down) {
     if (!audioReady) return;
 
     if (typeof wasmSetSustain !== 'undefined') {
+        recordEvent('sustain', 0, 0, down);
         wasmSetSustain(down);
     }
 }